@@ -0,0 +1,273 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache shares the gateway caches between replicas. Every cache
+// keeps its entries in process (see Loading); with a distributed backend
+// configured, values may also be shared through it so one replica's fetch
+// serves the others, and invalidations are broadcast over pub/sub so a
+// change made through one replica applies on all of them at once.
+//
+// The backend is selected by app.conf / env:
+//
+//	cacheBackend  = redis                      ; "redis" or "valkey"; empty keeps caches per process
+//	cacheRedisUrl = redis://:secret@valkey:6379/0
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+)
+
+// Backend stores cache entries shared by all replicas and carries their
+// invalidation messages.
+type Backend interface {
+	// Get returns the value of key and whether it exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key, expiring it after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys.
+	Delete(ctx context.Context, keys ...string) error
+	// DeletePrefix removes every key starting with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+	// IncrBy adds delta to the counter at key, creating it with ttl when
+	// missing, and returns its new value.
+	IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// GetInts returns the counters at keys, 0 for missing ones.
+	GetInts(ctx context.Context, keys ...string) ([]int64, error)
+	// Publish sends message to the subscribers of channel.
+	Publish(ctx context.Context, channel string, message string) error
+	// Subscribe calls handler with every message on channel until ctx ends.
+	Subscribe(ctx context.Context, channel string, handler func(message string)) error
+	Close() error
+}
+
+const (
+	keyPrefix           = "cloud:cache:"
+	invalidationChannel = "cloud:cache:invalidate"
+
+	// backendTimeout bounds every backend call made on a request path; a
+	// slow backend degrades to the in-process caches instead of stalling.
+	backendTimeout = 500 * time.Millisecond
+)
+
+// invalidation is the pub/sub message announcing a dropped entry.
+type invalidation struct {
+	Cache  string `json:"cache"`
+	Key    string `json:"key"`
+	Prefix bool   `json:"prefix,omitempty"`
+	Origin string `json:"origin"`
+}
+
+var (
+	backend   Backend
+	backendMu sync.RWMutex
+
+	// origin identifies this replica, so it skips its own invalidations.
+	origin = util.GenerateId()
+
+	handlers   = map[string]func(key string, prefix bool){}
+	handlersMu sync.RWMutex
+)
+
+// InitCache connects the configured backend and starts listening for
+// invalidations. Without a backend configured the caches stay per process.
+func InitCache() {
+	kind := strings.ToLower(conf.GetConfigString("cacheBackend"))
+	switch kind {
+	case "", "memory":
+		return
+	case "redis", "valkey":
+	default:
+		panic(fmt.Sprintf("cache: unknown cacheBackend %q", kind))
+	}
+
+	b, err := NewRedisBackend(conf.GetConfigString("cacheRedisUrl"))
+	if err != nil {
+		panic(fmt.Sprintf("cache: failed to connect to %s: %v", kind, err))
+	}
+	SetBackend(b)
+	logs.Info("cache: sharing gateway caches through %s", kind)
+}
+
+// SetBackend installs b as the distributed backend and subscribes to its
+// invalidations; nil returns to per-process caches.
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	previous := backend
+	backend = b
+	backendMu.Unlock()
+	if previous != nil {
+		_ = previous.Close()
+	}
+	if b == nil {
+		return
+	}
+
+	go func() {
+		err := b.Subscribe(context.Background(), invalidationChannel, handleInvalidation)
+		if err != nil {
+			logs.Warn("cache: invalidation subscription ended: %v", err)
+		}
+	}()
+}
+
+// getBackend returns the distributed backend, nil when caches are per
+// process.
+func getBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
+
+// Distributed reports whether a distributed backend is configured.
+func Distributed() bool {
+	return getBackend() != nil
+}
+
+// OnInvalidate registers the handler dropping entries of the named cache
+// when another replica invalidates them; prefix is true when key is a
+// prefix of the keys to drop.
+func OnInvalidate(name string, handler func(key string, prefix bool)) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[name] = handler
+}
+
+// Invalidate tells the other replicas to drop key from the named cache. The
+// caller drops its own copy.
+func Invalidate(name string, key string) {
+	publishInvalidation(invalidation{Cache: name, Key: key})
+}
+
+// InvalidatePrefix tells the other replicas to drop the keys of the named
+// cache starting with prefix.
+func InvalidatePrefix(name string, prefix string) {
+	publishInvalidation(invalidation{Cache: name, Key: prefix, Prefix: true})
+}
+
+func publishInvalidation(message invalidation) {
+	b := getBackend()
+	if b == nil {
+		return
+	}
+	message.Origin = origin
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	if err = b.Publish(ctx, invalidationChannel, string(data)); err != nil {
+		logs.Warn("cache: failed to publish invalidation of %s/%s: %v", message.Cache, message.Key, err)
+	}
+}
+
+func handleInvalidation(data string) {
+	var message invalidation
+	if err := json.Unmarshal([]byte(data), &message); err != nil || message.Origin == origin {
+		return
+	}
+	handlersMu.RLock()
+	handler := handlers[message.Cache]
+	handlersMu.RUnlock()
+	if handler != nil {
+		handler(message.Key, message.Prefix)
+	}
+}
+
+// GetShared reads a value shared through the backend under the named cache.
+func GetShared(name string, key string) ([]byte, bool) {
+	b := getBackend()
+	if b == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	value, ok, err := b.Get(ctx, keyPrefix+name+":"+key)
+	if err != nil {
+		logs.Warn("cache: failed to read %s/%s: %v", name, key, err)
+		return nil, false
+	}
+	return value, ok
+}
+
+// SetShared shares value through the backend under the named cache.
+func SetShared(name string, key string, value []byte, ttl time.Duration) {
+	b := getBackend()
+	if b == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	if err := b.Set(ctx, keyPrefix+name+":"+key, value, ttl); err != nil {
+		logs.Warn("cache: failed to write %s/%s: %v", name, key, err)
+	}
+}
+
+// DeleteShared removes a shared value of the named cache; with prefix set,
+// every value whose key starts with key.
+func DeleteShared(name string, key string, prefix bool) {
+	b := getBackend()
+	if b == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	var err error
+	if prefix {
+		err = b.DeletePrefix(ctx, keyPrefix+name+":"+key)
+	} else {
+		err = b.Delete(ctx, keyPrefix+name+":"+key)
+	}
+	if err != nil {
+		logs.Warn("cache: failed to delete %s/%s: %v", name, key, err)
+	}
+}
+
+// AddShared adds delta to a counter shared through the backend under the
+// named cache, and returns its new value. It fails without a backend, so
+// callers can fall back to counting per process.
+func AddShared(name string, key string, delta int64, ttl time.Duration) (int64, error) {
+	b := getBackend()
+	if b == nil {
+		return 0, fmt.Errorf("cache: no distributed backend")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	return b.IncrBy(ctx, keyPrefix+name+":"+key, delta, ttl)
+}
+
+// GetSharedInts reads counters shared through the backend under the named
+// cache, 0 for missing ones.
+func GetSharedInts(name string, keys []string) ([]int64, error) {
+	b := getBackend()
+	if b == nil {
+		return nil, fmt.Errorf("cache: no distributed backend")
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = keyPrefix + name + ":" + key
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	return b.GetInts(ctx, prefixed...)
+}
@@ -0,0 +1,259 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBackend is an in-process Backend standing in for Redis.
+type memoryBackend struct {
+	mu          sync.Mutex
+	values      map[string][]byte
+	subscribers []func(message string)
+	published   []string
+	closed      chan struct{}
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{values: map[string][]byte{}, closed: make(chan struct{})}
+}
+
+func (b *memoryBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.values[key]
+	return value, ok, nil
+}
+
+func (b *memoryBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = value
+	return nil
+}
+
+func (b *memoryBackend) Delete(ctx context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		delete(b.values, key)
+	}
+	return nil
+}
+
+func (b *memoryBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key := range b.values {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.values, key)
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, _ := strconv.ParseInt(string(b.values[key]), 10, 64)
+	value += delta
+	b.values[key] = []byte(strconv.FormatInt(value, 10))
+	return value, nil
+}
+
+func (b *memoryBackend) GetInts(ctx context.Context, keys ...string) ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	values := make([]int64, len(keys))
+	for i, key := range keys {
+		values[i], _ = strconv.ParseInt(string(b.values[key]), 10, 64)
+	}
+	return values, nil
+}
+
+func (b *memoryBackend) Publish(ctx context.Context, channel string, message string) error {
+	b.mu.Lock()
+	b.published = append(b.published, message)
+	subscribers := append([]func(string){}, b.subscribers...)
+	b.mu.Unlock()
+	for _, subscriber := range subscribers {
+		subscriber(message)
+	}
+	return nil
+}
+
+func (b *memoryBackend) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, handler)
+	b.mu.Unlock()
+	<-b.closed
+	return nil
+}
+
+func (b *memoryBackend) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func (b *memoryBackend) waitSubscribed(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		b.mu.Lock()
+		n := len(b.subscribers)
+		b.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("the invalidation subscription never started")
+}
+
+func useMemoryBackend(t *testing.T) *memoryBackend {
+	b := newMemoryBackend()
+	SetBackend(b)
+	b.waitSubscribed(t)
+	t.Cleanup(func() { SetBackend(nil) })
+	return b
+}
+
+func TestLoadingSharesValues(t *testing.T) {
+	b := useMemoryBackend(t)
+	c := NewLoading[int64]("test-shared", Options{MaxEntries: 10, TTL: time.Minute, Shared: true})
+
+	loads := 0
+	load := func() (int64, error) {
+		loads++
+		return 500, nil
+	}
+	if value, err := c.Get("hanzo/alice", load); err != nil || value != 500 {
+		t.Fatalf("Get() = %d, %v, want 500", value, err)
+	}
+	if _, ok := b.values[keyPrefix+"test-shared:hanzo/alice"]; !ok {
+		t.Fatal("the loaded value was not shared")
+	}
+
+	// Another replica (here: an empty local cache) reads the shared value.
+	c.local.Remove("hanzo/alice")
+	if value, _ := c.Get("hanzo/alice", load); value != 500 || loads != 1 {
+		t.Errorf("Get() = %d after %d loads, want the shared 500 after 1", value, loads)
+	}
+
+	c.Invalidate("hanzo/alice")
+	if _, ok := b.values[keyPrefix+"test-shared:hanzo/alice"]; ok {
+		t.Error("Invalidate() left the shared value")
+	}
+}
+
+func TestLoadingAppliesRemoteInvalidations(t *testing.T) {
+	b := useMemoryBackend(t)
+	c := NewLoading[string]("test-remote", Options{MaxEntries: 10, TTL: time.Minute})
+	c.Set("acme/fireworks", "a")
+	c.Set("acme/openai", "b")
+	c.Set("admin/fireworks", "c")
+
+	remote, _ := json.Marshal(invalidation{Cache: "test-remote", Key: "acme/", Prefix: true, Origin: "another-replica"})
+	_ = b.Publish(context.Background(), invalidationChannel, string(remote))
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d after the remote prefix invalidation, want 1", c.Len())
+	}
+
+	// This replica's own invalidations are published once and not reapplied.
+	c.Invalidate("admin/fireworks")
+	if len(b.published) != 2 {
+		t.Fatalf("published %d messages, want 2", len(b.published))
+	}
+	var own invalidation
+	_ = json.Unmarshal([]byte(b.published[1]), &own)
+	if own.Cache != "test-remote" || own.Key != "admin/fireworks" || own.Origin != origin {
+		t.Errorf("published %+v", own)
+	}
+}
+
+func TestLoadingHashesCredentialKeys(t *testing.T) {
+	b := useMemoryBackend(t)
+	c := NewLoading[string]("test-hashed", Options{MaxEntries: 10, TTL: time.Minute, Shared: true, HashKeys: true})
+	c.Set("hk-secret-key", "hanzo/alice")
+	c.Invalidate("hk-secret-key")
+	for key := range b.values {
+		if strings.Contains(key, "hk-secret-key") {
+			t.Errorf("shared key %q contains the credential", key)
+		}
+	}
+	for _, message := range b.published {
+		if strings.Contains(message, "hk-secret-key") {
+			t.Errorf("invalidation %q contains the credential", message)
+		}
+	}
+
+	c.Set("hk-secret-key", "hanzo/alice")
+	c.local.RemoveFunc(func(string) bool { return true })
+	if userKey, ok := c.Peek("hk-secret-key"); !ok || userKey != "hanzo/alice" {
+		t.Errorf("Peek() = %q, %v, want the shared hanzo/alice", userKey, ok)
+	}
+}
+
+func TestLoadingWithoutBackend(t *testing.T) {
+	c := NewLoading[int]("test-local", Options{MaxEntries: 10, TTL: time.Minute, Shared: true})
+	value, err := c.Get("k", func() (int, error) { return 7, nil })
+	if err != nil || value != 7 {
+		t.Errorf("Get() = %d, %v, want 7", value, err)
+	}
+	c.Invalidate("k")
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d after Invalidate, want 0", c.Len())
+	}
+}
+
+func TestLoadingOnInvalidate(t *testing.T) {
+	b := useMemoryBackend(t)
+	var dropped []string
+	c := NewLoading[string]("test-hook", Options{MaxEntries: 10, TTL: time.Minute, OnInvalidate: func(key string, prefix bool) {
+		if prefix {
+			key += "*"
+		}
+		dropped = append(dropped, key)
+	}})
+
+	c.Invalidate("acme/openai")
+	remote, _ := json.Marshal(invalidation{Cache: "test-hook", Key: "acme/", Prefix: true, Origin: "another-replica"})
+	_ = b.Publish(context.Background(), invalidationChannel, string(remote))
+	if strings.Join(dropped, ",") != "acme/openai,acme/*" {
+		t.Errorf("OnInvalidate saw %v, want [acme/openai acme/*]", dropped)
+	}
+}
+
+func TestSharedCounters(t *testing.T) {
+	if _, err := AddShared("test-counter", "a", 1, time.Minute); err == nil {
+		t.Fatal("AddShared() without a backend = nil error, want one")
+	}
+
+	useMemoryBackend(t)
+	for i, want := range []int64{5, 12} {
+		if value, err := AddShared("test-counter", "a", []int64{5, 7}[i], time.Minute); err != nil || value != want {
+			t.Fatalf("AddShared() = %d, %v, want %d", value, err, want)
+		}
+	}
+	values, err := GetSharedInts("test-counter", []string{"a", "missing"})
+	if err != nil || len(values) != 2 || values[0] != 12 || values[1] != 0 {
+		t.Errorf("GetSharedInts() = %v, %v, want [12 0]", values, err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/util"
+)
+
+// Options configures a Loading cache.
+type Options struct {
+	MaxEntries int
+	TTL        time.Duration // entries younger than this are fresh
+	StaleTTL   time.Duration // then served this much longer while reloaded
+
+	// Shared stores loaded values in the distributed backend, so a value
+	// one replica fetched serves the others. Leave it off for values that
+	// carry secrets; their invalidations are broadcast either way.
+	Shared bool
+	// HashKeys keys entries by the SHA-256 of their key, for caches keyed
+	// by credentials, so neither the backend nor invalidation messages see
+	// them. InvalidatePrefix is not supported then.
+	HashKeys bool
+	// OnInvalidate is called after an invalidation dropped entries, on the
+	// replica that made it and on the others, with the key (or prefix) the
+	// entries were dropped by. Caches use it to drop state derived from
+	// their values.
+	OnInvalidate func(key string, prefix bool)
+}
+
+// Loading is a bounded in-process cache of loaded values (see
+// util.LruCache) whose invalidations reach every replica, optionally
+// backed by values shared through the distributed backend.
+type Loading[V any] struct {
+	name    string
+	options Options
+	local   *util.LruCache[V]
+}
+
+// NewLoading creates the cache called name, which must be unique: it
+// addresses the cache's shared values and invalidations.
+func NewLoading[V any](name string, options Options) *Loading[V] {
+	c := &Loading[V]{
+		name:    name,
+		options: options,
+		local:   util.NewLruCache[V](options.MaxEntries, options.TTL, options.StaleTTL),
+	}
+	OnInvalidate(name, c.dropLocal)
+	return c
+}
+
+// Get returns the value of key, calling load when neither this replica nor
+// the shared backend has a current one.
+func (c *Loading[V]) Get(key string, load func() (V, error)) (V, error) {
+	key = c.key(key)
+	return c.local.Get(key, func() (V, error) {
+		if value, ok := c.getShared(key); ok {
+			return value, nil
+		}
+		value, err := load()
+		if err == nil {
+			c.share(key, value)
+		}
+		return value, err
+	})
+}
+
+// Peek returns the current value of key without loading one.
+func (c *Loading[V]) Peek(key string) (V, bool) {
+	key = c.key(key)
+	if value, ok := c.local.Peek(key); ok {
+		return value, true
+	}
+	value, ok := c.getShared(key)
+	if ok {
+		c.local.Set(key, value)
+	}
+	return value, ok
+}
+
+// Set stores value for key.
+func (c *Loading[V]) Set(key string, value V) {
+	key = c.key(key)
+	c.local.Set(key, value)
+	c.share(key, value)
+}
+
+// Invalidate drops key on every replica.
+func (c *Loading[V]) Invalidate(key string) {
+	key = c.key(key)
+	c.dropLocal(key, false)
+	if c.options.Shared {
+		DeleteShared(c.name, key, false)
+	}
+	Invalidate(c.name, key)
+}
+
+// InvalidatePrefix drops the keys starting with prefix on every replica.
+func (c *Loading[V]) InvalidatePrefix(prefix string) {
+	c.dropLocal(prefix, true)
+	if c.options.Shared {
+		DeleteShared(c.name, prefix, true)
+	}
+	InvalidatePrefix(c.name, prefix)
+}
+
+// Len returns the number of entries cached in process.
+func (c *Loading[V]) Len() int {
+	return c.local.Len()
+}
+
+func (c *Loading[V]) dropLocal(key string, prefix bool) {
+	if !prefix {
+		c.local.Remove(key)
+	} else {
+		c.local.RemoveFunc(func(k string) bool {
+			return strings.HasPrefix(k, key)
+		})
+	}
+	if c.options.OnInvalidate != nil {
+		c.options.OnInvalidate(key, prefix)
+	}
+}
+
+func (c *Loading[V]) getShared(key string) (V, bool) {
+	var value V
+	if !c.options.Shared {
+		return value, false
+	}
+	data, ok := GetShared(c.name, key)
+	if !ok || json.Unmarshal(data, &value) != nil {
+		return value, false
+	}
+	return value, true
+}
+
+func (c *Loading[V]) share(key string, value V) {
+	if !c.options.Shared || !Distributed() {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	SetShared(c.name, key, data, c.options.TTL)
+}
+
+func (c *Loading[V]) key(key string) string {
+	if !c.options.HashKeys {
+		return key
+	}
+	return HashKey(key)
+}
+
+// HashKey returns the SHA-256 of key, for caches keyed by credentials.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,150 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend is a Backend on Redis or Valkey, which speaks the same
+// protocol.
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// NewRedisBackend connects to the server at url
+// (redis://[user:password@]host:port[/db], or rediss:// for TLS).
+func NewRedisBackend(url string) (*RedisBackend, error) {
+	if url == "" {
+		return nil, fmt.Errorf("cacheRedisUrl is not configured")
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &RedisBackend{client: client}, nil
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := b.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.client.Del(ctx, keys...).Err()
+}
+
+// DeletePrefix scans for the keys rather than using KEYS, which blocks the
+// server on large keyspaces.
+func (b *RedisBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := b.client.Scan(ctx, 0, prefix+"*", 500).Iterator()
+	keys := []string{}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := b.Delete(ctx, keys...); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return b.Delete(ctx, keys...)
+}
+
+// IncrBy sets the expiry only on the counter's creation, so it stays
+// anchored to the first increment.
+func (b *RedisBackend) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	pipe := b.client.TxPipeline()
+	incr := pipe.IncrBy(ctx, key, delta)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (b *RedisBackend) GetInts(ctx context.Context, keys ...string) ([]int64, error) {
+	values := make([]int64, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	results, err := b.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if s, ok := result.(string); ok {
+			values[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return values, nil
+}
+
+func (b *RedisBackend) Publish(ctx context.Context, channel string, message string) error {
+	return b.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe resubscribes on its own after connection losses; messages
+// published while disconnected are lost, which the cache TTLs bound.
+func (b *RedisBackend) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	pubsub := b.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler(message.Payload)
+		}
+	}
+}
+
+func (b *RedisBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,264 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// accessKeyCacheTTL controls how long a resolved hk- key → user mapping
+	// is served from memory before IAM is consulted again.
+	accessKeyCacheTTL = 5 * time.Minute
+
+	// accessKeyNegativeTTL controls how long an IAM rejection is remembered,
+	// by every replica. Kept short so a newly created key starts working
+	// quickly, but long enough that brute-force or misconfigured clients
+	// cannot hammer IAM.
+	accessKeyNegativeTTL = 30 * time.Second
+
+	// defaultAccessKeyFailedLookupsPerMinute bounds the IAM lookups of keys
+	// IAM then rejects, per client, when accessKeyFailedLookupsPerMinute is
+	// not configured. Each costs a get-user call and a scan for rotated
+	// keys, and a client can make up as many distinct bad keys as it likes.
+	defaultAccessKeyFailedLookupsPerMinute = 600
+
+	// accessKeyFailedLookupsTTL is how long the failed lookups of a client
+	// are counted after its first one.
+	accessKeyFailedLookupsTTL = 10 * time.Minute
+
+	// accessKeyCacheMaxEntries bounds memory; the cache is reset when full.
+	accessKeyCacheMaxEntries = 100000
+)
+
+// accessKeyRejectedError marks an IAM response that definitively rejects a
+// key (as opposed to a transport failure). Only rejections are negatively
+// cached, so an IAM outage never locks out valid keys.
+type accessKeyRejectedError struct {
+	msg string
+}
+
+func (e *accessKeyRejectedError) Error() string {
+	return "IAM error: " + e.msg
+}
+
+// errAccessKeyLookupsThrottled is returned for a key that is not cached
+// while too many lookups of its client are failing.
+var errAccessKeyLookupsThrottled = errors.New("too many invalid API keys, retry later")
+
+// accessKeyCacheEntry holds a resolved user.
+type accessKeyCacheEntry struct {
+	user      *iamsdk.User
+	expiresAt time.Time
+}
+
+// accessKeyCache caches IAM accessKey lookups with singleflight so that a
+// burst of requests for the same key results in a single IAM call.
+// Resolved users stay on this replica; rejections are shared, so a bad key
+// costs one IAM lookup per negative TTL across the deployment, and while a
+// client's lookups keep failing faster than failedLookupsPerMinute allows,
+// the keys it sends that are not cached are refused without asking IAM.
+// Other clients' keys are still looked up.
+type accessKeyCache struct {
+	mu                     sync.RWMutex
+	entries                map[string]*accessKeyCacheEntry
+	rejected               *cache.Loading[string]        // key -> IAM's rejection message
+	failedLookups          *cache.Loading[*rate.Limiter] // client -> its failed lookups
+	failedLookupsPerMinute int
+	group                  singleflight.Group
+	fetch                  func(accessKey string) (*iamsdk.User, error)
+}
+
+// userByAccessKeyCache is the process-wide cache in front of IAM get-user.
+// The users it holds carry credentials, so replicas don't share them, but a
+// key invalidated on one replica (e.g. on rotation) is dropped on all.
+var userByAccessKeyCache = newAccessKeyCache(accessKeyCacheName, fetchUserByAccessKeyOrRotated)
+
+// accessKeyCacheName addresses the invalidations of userByAccessKeyCache,
+// which carry the SHA-256 of the key rather than the key.
+const accessKeyCacheName = "access-key"
+
+func init() {
+	cache.OnInvalidate(accessKeyCacheName, func(hash string, prefix bool) {
+		userByAccessKeyCache.dropHashed(hash)
+	})
+}
+
+// newAccessKeyCache creates an access key cache whose shared rejections are
+// addressed by name.
+func newAccessKeyCache(name string, fetch func(string) (*iamsdk.User, error)) *accessKeyCache {
+	perMinute := conf.GetConfigInt("accessKeyFailedLookupsPerMinute")
+	if perMinute <= 0 {
+		perMinute = defaultAccessKeyFailedLookupsPerMinute
+	}
+	return &accessKeyCache{
+		entries: make(map[string]*accessKeyCacheEntry),
+		rejected: cache.NewLoading[string](name+"-rejected", cache.Options{
+			MaxEntries: accessKeyCacheMaxEntries,
+			TTL:        accessKeyNegativeTTL,
+			Shared:     true,
+			HashKeys:   true,
+		}),
+		failedLookups: cache.NewLoading[*rate.Limiter](name+"-failed-lookups", cache.Options{
+			MaxEntries: accessKeyCacheMaxEntries,
+			TTL:        accessKeyFailedLookupsTTL,
+		}),
+		failedLookupsPerMinute: perMinute,
+		fetch:                  fetch,
+	}
+}
+
+// failedLookupsOf returns the limiter of the failed lookups of source, or nil
+// when source is empty: lookups of unknown clients are not throttled.
+func (kc *accessKeyCache) failedLookupsOf(source string) *rate.Limiter {
+	if source == "" {
+		return nil
+	}
+	limiter, _ := kc.failedLookups.Get(source, func() (*rate.Limiter, error) {
+		perMinute := kc.failedLookupsPerMinute
+		return rate.NewLimiter(rate.Limit(float64(perMinute)/60), max(perMinute/10, 1)), nil
+	})
+	return limiter
+}
+
+// get returns the user for accessKey, sent by the client source (its IP),
+// consulting IAM at most once per TTL (or per negative TTL for rejected
+// keys). The returned user is a copy and safe for the caller to mutate.
+func (kc *accessKeyCache) get(accessKey string, source string) (*iamsdk.User, error) {
+	kc.mu.RLock()
+	entry, ok := kc.entries[accessKey]
+	kc.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return copyUser(entry.user), nil
+	}
+	if msg, ok := kc.rejected.Peek(accessKey); ok {
+		return nil, rejectedAccessKeyError(msg)
+	}
+	failedLookups := kc.failedLookupsOf(source)
+	if failedLookups != nil && failedLookups.Tokens() < 1 {
+		return nil, errAccessKeyLookupsThrottled
+	}
+
+	v, err, _ := kc.group.Do(accessKey, func() (interface{}, error) {
+		user, err := kc.fetch(accessKey)
+		switch {
+		case err == nil && user != nil:
+			kc.store(accessKey, &accessKeyCacheEntry{user: user, expiresAt: time.Now().Add(accessKeyCacheTTL)})
+		case isAccessKeyRejected(err) || (err == nil && user == nil):
+			msg := ""
+			var rejected *accessKeyRejectedError
+			if errors.As(err, &rejected) {
+				msg = rejected.msg
+			}
+			kc.rejected.Set(accessKey, msg)
+			if failedLookups != nil {
+				failedLookups.Allow()
+			}
+		}
+		return user, err
+	})
+
+	user, _ := v.(*iamsdk.User)
+	return copyUser(user), err
+}
+
+// cachedOwner returns the org of the user cached for accessKey, without
+// consulting IAM. It is empty when the key is not cached or was rejected.
+func (kc *accessKeyCache) cachedOwner(accessKey string) string {
+	kc.mu.RLock()
+	defer kc.mu.RUnlock()
+
+	entry, ok := kc.entries[accessKey]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return ""
+	}
+	return entry.user.Owner
+}
+
+// CachedAccessKeyOrg returns the org of an hk- key this replica has already
+// resolved, or "" if it has not. It never blocks on IAM, so the gateway rate
+// limiter can use it before the request is authenticated.
+func CachedAccessKeyOrg(accessKey string) string {
+	return userByAccessKeyCache.cachedOwner(accessKey)
+}
+
+// invalidate drops any cached result for accessKey on every replica.
+func (kc *accessKeyCache) invalidate(accessKey string) {
+	kc.mu.Lock()
+	delete(kc.entries, accessKey)
+	kc.mu.Unlock()
+	kc.rejected.Invalidate(accessKey)
+	cache.Invalidate(accessKeyCacheName, cache.HashKey(accessKey))
+}
+
+// dropHashed drops the cached result of the key whose SHA-256 is hash.
+func (kc *accessKeyCache) dropHashed(hash string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	for accessKey := range kc.entries {
+		if cache.HashKey(accessKey) == hash {
+			delete(kc.entries, accessKey)
+		}
+	}
+}
+
+func (kc *accessKeyCache) store(accessKey string, entry *accessKeyCacheEntry) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if len(kc.entries) >= accessKeyCacheMaxEntries {
+		now := time.Now()
+		for k, e := range kc.entries {
+			if now.After(e.expiresAt) {
+				delete(kc.entries, k)
+			}
+		}
+		if len(kc.entries) >= accessKeyCacheMaxEntries {
+			kc.entries = make(map[string]*accessKeyCacheEntry)
+		}
+	}
+	kc.entries[accessKey] = entry
+}
+
+// rejectedAccessKeyError returns the error of a cached rejection: nil when
+// IAM found no user for the key, IAM's message otherwise.
+func rejectedAccessKeyError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return &accessKeyRejectedError{msg: msg}
+}
+
+func isAccessKeyRejected(err error) bool {
+	var rejected *accessKeyRejectedError
+	return errors.As(err, &rejected)
+}
+
+func copyUser(user *iamsdk.User) *iamsdk.User {
+	if user == nil {
+		return nil
+	}
+	u := *user
+	return &u
+}
@@ -0,0 +1,195 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hanzoai/cloud/cache"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func TestAccessKeyCacheCachesUser(t *testing.T) {
+	calls := 0
+	kc := newAccessKeyCache(t.Name(), func(string) (*iamsdk.User, error) {
+		calls++
+		return &iamsdk.User{Owner: "hanzo", Name: "alice"}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		user, err := kc.get("hk-good", "")
+		if err != nil || user == nil || user.Name != "alice" {
+			t.Fatalf("get() = %v, %v; want alice", user, err)
+		}
+		user.Name = "mutated"
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+
+	kc.invalidate("hk-good")
+	kc.get("hk-good", "")
+	if calls != 2 {
+		t.Errorf("fetch called %d times after invalidate, want 2", calls)
+	}
+
+	// Another replica invalidating the key sends its hash
+	kc.dropHashed(cache.HashKey("hk-good"))
+	kc.get("hk-good", "")
+	if calls != 3 {
+		t.Errorf("fetch called %d times after a remote invalidation, want 3", calls)
+	}
+}
+
+func TestAccessKeyCacheNegative(t *testing.T) {
+	calls := 0
+	kc := newAccessKeyCache(t.Name(), func(string) (*iamsdk.User, error) {
+		calls++
+		return nil, &accessKeyRejectedError{msg: "invalid access key"}
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := kc.get("hk-bad", ""); !isAccessKeyRejected(err) {
+			t.Fatalf("get() err = %v, want rejection", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+}
+
+func TestAccessKeyCacheThrottlesFailedLookups(t *testing.T) {
+	calls := 0
+	kc := newAccessKeyCache(t.Name(), func(accessKey string) (*iamsdk.User, error) {
+		calls++
+		if strings.HasPrefix(accessKey, "hk-good") {
+			return &iamsdk.User{Owner: "hanzo", Name: "alice"}, nil
+		}
+		return nil, &accessKeyRejectedError{msg: "invalid access key"}
+	})
+	kc.failedLookupsPerMinute = 1
+
+	kc.get("hk-good", "10.0.0.1")
+	if _, err := kc.get("hk-bad-1", "10.0.0.1"); !isAccessKeyRejected(err) {
+		t.Fatalf("get() err = %v, want rejection", err)
+	}
+	if _, err := kc.get("hk-bad-2", "10.0.0.1"); err != errAccessKeyLookupsThrottled {
+		t.Errorf("get() after the failed lookups ran out = %v, want errAccessKeyLookupsThrottled", err)
+	}
+	if _, err := kc.get("hk-bad-1", "10.0.0.1"); !isAccessKeyRejected(err) {
+		t.Errorf("get() of a rejected key = %v, want its cached rejection", err)
+	}
+	if user, err := kc.get("hk-good", "10.0.0.1"); err != nil || user == nil {
+		t.Errorf("get() of a cached key = %v, %v; want alice", user, err)
+	}
+	if calls != 2 {
+		t.Errorf("fetch called %d times, want 2", calls)
+	}
+
+	// The failures of one client do not lock the others out.
+	if user, err := kc.get("hk-good-2", "10.0.0.2"); err != nil || user == nil {
+		t.Errorf("get() from another client = %v, %v; want alice", user, err)
+	}
+	if _, err := kc.get("hk-bad-3", "10.0.0.2"); !isAccessKeyRejected(err) {
+		t.Errorf("get() of a bad key from another client = %v, want rejection", err)
+	}
+	if _, err := kc.get("hk-bad-4", ""); !isAccessKeyRejected(err) {
+		t.Errorf("get() from an unknown client = %v, want rejection", err)
+	}
+}
+
+func TestAccessKeyCacheSkipsTransportErrors(t *testing.T) {
+	calls := 0
+	kc := newAccessKeyCache(t.Name(), func(string) (*iamsdk.User, error) {
+		calls++
+		return nil, fmt.Errorf("dial tcp: connection refused")
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := kc.get("hk-any", ""); err == nil {
+			t.Fatal("get() err = nil, want transport error")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("fetch called %d times, want 3 (transport errors must not be cached)", calls)
+	}
+}
+
+func TestAccessKeyCacheCachedOwner(t *testing.T) {
+	kc := newAccessKeyCache(t.Name(), func(accessKey string) (*iamsdk.User, error) {
+		if accessKey == "hk-bad" {
+			return nil, &accessKeyRejectedError{msg: "invalid access key"}
+		}
+		return &iamsdk.User{Owner: "hanzo", Name: "alice"}, nil
+	})
+
+	if owner := kc.cachedOwner("hk-good"); owner != "" {
+		t.Errorf("cachedOwner() before get = %q, want empty", owner)
+	}
+	kc.get("hk-good", "")
+	kc.get("hk-bad", "")
+	if owner := kc.cachedOwner("hk-good"); owner != "hanzo" {
+		t.Errorf("cachedOwner() = %q, want hanzo", owner)
+	}
+	if owner := kc.cachedOwner("hk-bad"); owner != "" {
+		t.Errorf("cachedOwner() of a rejected key = %q, want empty", owner)
+	}
+}
+
+func TestGetCatalogOrgForAccessKey(t *testing.T) {
+	previous := userByAccessKeyCache
+	defer func() { userByAccessKeyCache = previous }()
+	userByAccessKeyCache = newAccessKeyCache(t.Name(), func(accessKey string) (*iamsdk.User, error) {
+		if accessKey != "hk-acme" {
+			return nil, &accessKeyRejectedError{msg: "unknown key"}
+		}
+		return &iamsdk.User{Owner: "acme", Name: "alice"}, nil
+	})
+
+	if got := getCatalogOrgForToken("hk-acme", ""); got != "acme" {
+		t.Errorf("getCatalogOrgForToken(hk-acme) = %q, want acme", got)
+	}
+	if got := getCatalogOrgForToken("hk-unknown", ""); got != "" {
+		t.Errorf("getCatalogOrgForToken(hk-unknown) = %q, want the public catalog", got)
+	}
+}
+
+func TestVerifyAccessKey(t *testing.T) {
+	previous := userByAccessKeyCache
+	defer func() { userByAccessKeyCache = previous }()
+	userByAccessKeyCache = newAccessKeyCache(t.Name(), func(accessKey string) (*iamsdk.User, error) {
+		switch accessKey {
+		case "hk-acme":
+			return &iamsdk.User{Owner: "acme", Name: "alice"}, nil
+		case "hk-down":
+			return nil, errors.New("IAM request failed: connection refused")
+		}
+		return nil, &accessKeyRejectedError{msg: "unknown key"}
+	})
+	t.Setenv("CLOUD_AGENT_KEY", "")
+
+	if user, rejected := VerifyAccessKey("hk-acme", ""); user == nil || user.Name != "alice" || rejected {
+		t.Errorf("VerifyAccessKey(hk-acme) = %v, %v, want alice", user, rejected)
+	}
+	if user, rejected := VerifyAccessKey("hk-revoked", ""); user != nil || !rejected {
+		t.Errorf("VerifyAccessKey(hk-revoked) = %v, %v, want rejected", user, rejected)
+	}
+	if user, rejected := VerifyAccessKey("hk-down", ""); user != nil || rejected {
+		t.Errorf("VerifyAccessKey(hk-down) = %v, %v, want unverified", user, rejected)
+	}
+}
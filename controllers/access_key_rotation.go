@@ -0,0 +1,274 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/google/uuid"
+	"github.com/hanzoai/cloud/conf"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+// IAM user properties used for hk- key lifecycle. Timestamps are RFC 3339.
+const (
+	accessKeyExpiresAtProperty         = "accessKeyExpiresAt"
+	previousAccessKeyProperty          = "previousAccessKey"
+	previousAccessKeyExpiresAtProperty = "previousAccessKeyExpiresAt"
+)
+
+// defaultAccessKeyRotationOverlap is how long the old key keeps working after
+// a rotation when accessKeyRotationOverlapMinutes is not configured.
+const defaultAccessKeyRotationOverlap = 24 * time.Hour
+
+// errAccessKeyExpired is returned for hk- keys past their expiry.
+var errAccessKeyExpired = errors.New("API key has expired")
+
+// fetchUserByAccessKeyOrRotated resolves a key via IAM, falling back to users
+// whose previous (rotated-out) key matches, so the old key keeps working for
+// the overlap window.
+func fetchUserByAccessKeyOrRotated(accessKey string) (*iamsdk.User, error) {
+	user, err := fetchUserByAccessKey(accessKey)
+	if !isAccessKeyRejected(err) && !(err == nil && user == nil) {
+		return user, err
+	}
+
+	rotatedUser, rotatedErr := fetchUserByPreviousAccessKey(accessKey)
+	if rotatedErr != nil {
+		logs.Warn("access key rotation lookup failed: %v", rotatedErr)
+		return user, err
+	}
+	if rotatedUser == nil {
+		return user, err
+	}
+	return rotatedUser, nil
+}
+
+// fetchUserByPreviousAccessKey searches IAM for a user whose properties record
+// accessKey as the previous key. Returns nil, nil when no user matches.
+func fetchUserByPreviousAccessKey(accessKey string) (*iamsdk.User, error) {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return nil, fmt.Errorf("iamEndpoint is not configured")
+	}
+
+	reqURL := fmt.Sprintf("%s/api/get-global-users?p=1&pageSize=10&field=properties&value=%s%s",
+		iamEndpoint, url.QueryEscape(accessKey), iamAuthQuery())
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(reqURL)
+	if err != nil {
+		return nil, fmt.Errorf("IAM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IAM returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status string         `json:"status"`
+		Msg    string         `json:"msg"`
+		Data   []*iamsdk.User `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse IAM response: %w", err)
+	}
+	if result.Status != "ok" {
+		return nil, fmt.Errorf("IAM error: %s", result.Msg)
+	}
+
+	// The IAM filter is a substring match, so confirm the exact property.
+	for _, user := range result.Data {
+		if user != nil && user.Properties[previousAccessKeyProperty] == accessKey {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkAccessKeyExpiry returns errAccessKeyExpired if accessKey is the user's
+// current key and past accessKeyExpiresAt, or a rotated-out key whose
+// overlap window has ended. Keys without an expiry never expire.
+func checkAccessKeyExpiry(user *iamsdk.User, accessKey string, now time.Time) error {
+	if user == nil {
+		return nil
+	}
+
+	var expiresAt string
+	switch accessKey {
+	case user.AccessKey:
+		expiresAt = user.Properties[accessKeyExpiresAtProperty]
+	case user.Properties[previousAccessKeyProperty]:
+		expiresAt = user.Properties[previousAccessKeyExpiresAtProperty]
+		if expiresAt == "" {
+			return errAccessKeyExpired
+		}
+	default:
+		return nil
+	}
+
+	if expiresAt == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		logs.Warn("access key for %s/%s has malformed expiry %q", user.Owner, user.Name, expiresAt)
+		return nil
+	}
+	if !now.Before(t) {
+		return errAccessKeyExpired
+	}
+	return nil
+}
+
+func getAccessKeyRotationOverlap() time.Duration {
+	if conf.GetConfigString("accessKeyRotationOverlapMinutes") == "" {
+		return defaultAccessKeyRotationOverlap
+	}
+	return time.Duration(conf.GetConfigInt("accessKeyRotationOverlapMinutes")) * time.Minute
+}
+
+// rotateUserAccessKey returns a copy of user with a fresh hk- key. The old key
+// is recorded as the previous key and stays valid for overlap, or until its
+// own expiry if that comes first. expiresIn, when non-zero, sets the expiry
+// of the new key.
+func rotateUserAccessKey(user *iamsdk.User, now time.Time, overlap time.Duration, expiresIn time.Duration) *iamsdk.User {
+	rotated := *user
+	rotated.Properties = make(map[string]string, len(user.Properties)+3)
+	for k, v := range user.Properties {
+		rotated.Properties[k] = v
+	}
+
+	rotated.AccessKey = "hk-" + uuid.NewString()
+	if user.AccessKey != "" {
+		rotated.Properties[previousAccessKeyProperty] = user.AccessKey
+		previousExpiresAt := now.Add(overlap)
+		if expiresAt, err := time.Parse(time.RFC3339, user.Properties[accessKeyExpiresAtProperty]); err == nil && expiresAt.Before(previousExpiresAt) {
+			previousExpiresAt = expiresAt
+		}
+		rotated.Properties[previousAccessKeyExpiresAtProperty] = previousExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if expiresIn > 0 {
+		rotated.Properties[accessKeyExpiresAtProperty] = now.Add(expiresIn).UTC().Format(time.RFC3339)
+	} else {
+		delete(rotated.Properties, accessKeyExpiresAtProperty)
+	}
+	return &rotated
+}
+
+// updateIAMUserAccessKey persists the key and properties columns of user.
+func updateIAMUserAccessKey(user *iamsdk.User) error {
+	return updateIAMUser(user, "accessKey,properties")
+}
+
+// updateIAMUser persists the given comma-separated columns of user.
+func updateIAMUser(user *iamsdk.User, columns string) error {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return fmt.Errorf("iamEndpoint is not configured")
+	}
+
+	body, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	reqURL := fmt.Sprintf("%s/api/update-user?id=%s&columns=%s%s",
+		iamEndpoint, url.QueryEscape(user.Owner+"/"+user.Name), url.QueryEscape(columns), iamAuthQuery())
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(reqURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("IAM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string `json:"status"`
+		Msg    string `json:"msg"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse IAM response: %w", err)
+	}
+	if result.Status != "ok" {
+		return fmt.Errorf("IAM error: %s", result.Msg)
+	}
+	return nil
+}
+
+// RotateAccessKey
+// @Title RotateAccessKey
+// @Tag Account API
+// @Description issue a new hk- API key; the current key stays valid for the configured overlap window
+// @Param   expiresInDays     query    int  false        "days until the new key expires (0 = never)"
+// @Success 200 {object} controllers.Response The Response object
+// @router /rotate-access-key [post]
+func (c *ApiController) RotateAccessKey() {
+	token := strings.TrimPrefix(c.Ctx.Request.Header.Get("Authorization"), "Bearer ")
+	if !isIAMApiKey(token) {
+		c.ResponseError("rotation requires authenticating with the hk- key being rotated")
+		return
+	}
+
+	user, err := fetchUserByAccessKey(token)
+	if err != nil {
+		c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
+		return
+	}
+	if user == nil {
+		c.ResponseError("invalid API key")
+		return
+	}
+	if err = checkAccessKeyExpiry(user, token, time.Now()); err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	var expiresIn time.Duration
+	if value := c.Input().Get("expiresInDays"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			c.ResponseError("expiresInDays must be a non-negative integer")
+			return
+		}
+		expiresIn = time.Duration(days) * 24 * time.Hour
+	}
+
+	now := time.Now()
+	rotated := rotateUserAccessKey(user, now, getAccessKeyRotationOverlap(), expiresIn)
+	if err = updateIAMUserAccessKey(rotated); err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	userByAccessKeyCache.invalidate(token)
+	logs.Info("access key rotated for %s/%s", rotated.Owner, rotated.Name)
+
+	c.ResponseOk(map[string]string{
+		"accessKey":                  rotated.AccessKey,
+		"accessKeyExpiresAt":         rotated.Properties[accessKeyExpiresAtProperty],
+		"previousAccessKeyExpiresAt": rotated.Properties[previousAccessKeyExpiresAtProperty],
+	})
+}
@@ -0,0 +1,71 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func TestRotateUserAccessKey(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := &iamsdk.User{
+		Owner:      "hanzo",
+		Name:       "alice",
+		AccessKey:  "hk-old",
+		Properties: map[string]string{"plan": "pro", accessKeyExpiresAtProperty: "2026-01-02T00:00:00Z"},
+	}
+
+	rotated := rotateUserAccessKey(user, now, time.Hour, 0)
+	if !strings.HasPrefix(rotated.AccessKey, "hk-") || rotated.AccessKey == "hk-old" {
+		t.Fatalf("new key = %q, want a fresh hk- key", rotated.AccessKey)
+	}
+	if user.AccessKey != "hk-old" || user.Properties[previousAccessKeyProperty] != "" {
+		t.Fatal("rotateUserAccessKey mutated its input")
+	}
+	if rotated.Properties["plan"] != "pro" {
+		t.Error("unrelated properties were dropped")
+	}
+	if _, ok := rotated.Properties[accessKeyExpiresAtProperty]; ok {
+		t.Error("new key inherited the old key's expiry")
+	}
+
+	if err := checkAccessKeyExpiry(rotated, "hk-old", now.Add(30*time.Minute)); err != nil {
+		t.Errorf("old key within overlap: err = %v, want nil", err)
+	}
+	if err := checkAccessKeyExpiry(rotated, "hk-old", now.Add(2*time.Hour)); err != errAccessKeyExpired {
+		t.Errorf("old key after overlap: err = %v, want errAccessKeyExpired", err)
+	}
+	if err := checkAccessKeyExpiry(rotated, rotated.AccessKey, now.Add(1000*time.Hour)); err != nil {
+		t.Errorf("new key without expiry: err = %v, want nil", err)
+	}
+
+	expiring := rotateUserAccessKey(rotated, now, time.Hour, 24*time.Hour)
+	if err := checkAccessKeyExpiry(expiring, expiring.AccessKey, now.Add(25*time.Hour)); err != errAccessKeyExpired {
+		t.Errorf("new key past expiry: err = %v, want errAccessKeyExpired", err)
+	}
+
+	// Rotating a key that expires within the overlap does not extend it.
+	soon := rotateUserAccessKey(expiring, now.Add(23*time.Hour+30*time.Minute), time.Hour, 0)
+	if got := soon.Properties[previousAccessKeyExpiresAtProperty]; got != "2026-01-02T00:00:00Z" {
+		t.Errorf("previous key expires at %s, want its own expiry 2026-01-02T00:00:00Z", got)
+	}
+	if err := checkAccessKeyExpiry(soon, expiring.AccessKey, now.Add(24*time.Hour+time.Minute)); err != errAccessKeyExpired {
+		t.Errorf("previous key past its own expiry: err = %v, want errAccessKeyExpired", err)
+	}
+}
@@ -64,6 +64,7 @@ func InitAuthConfig() {
 	}
 
 	iamsdk.InitConfig(iamEndpoint, clientId, clientSecret, cert.Certificate, iamOrganization, iamApplication)
+	jwtCertificate = cert.Certificate
 }
 
 // Signin
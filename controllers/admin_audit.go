@@ -0,0 +1,107 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"github.com/beego/beego/logs"
+	"github.com/beego/beego/utils/pagination"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+// AuthorizedUserKey holds the "owner/name" of the caller a route policy
+// authorized in the request context data; see routers/route_policy.go.
+const AuthorizedUserKey = "authorizedUser"
+
+// getRequestId returns the caller's X-Request-Id, or a new one, and echoes
+// it in the response so audit rows can be matched to client logs.
+func (c *ApiController) getRequestId() string {
+	requestId := c.Ctx.Input.Header("X-Request-Id")
+	if requestId == "" {
+		requestId = util.GenerateUUID()
+	}
+	c.Ctx.Output.Header("X-Request-Id", requestId)
+	return requestId
+}
+
+// recordAdminAudit stores an admin audit row for a successful mutation.
+// before is nil for adds and after is nil for deletes. Failures are logged
+// rather than returned: the mutation has already been applied.
+func (c *ApiController) recordAdminAudit(action, resourceType, owner, resourceId string, before, after interface{}) {
+	audit := &object.AdminAudit{
+		Owner:        owner,
+		RequestId:    c.getRequestId(),
+		Actor:        c.getAuditActor(),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceId:   resourceId,
+		ClientIp:     c.getClientIp(),
+	}
+	if err := object.AddAdminAudit(audit, before, after); err != nil {
+		logs.Error("admin audit: failed to record %s %s %s by %s: %v", action, resourceType, resourceId, audit.Actor, err)
+	}
+}
+
+// getAuditActor returns the signed-in user, or the caller a route policy
+// authorized with an API key or a JWT.
+func (c *ApiController) getAuditActor() string {
+	if username := c.GetSessionUsername(); username != "" {
+		return username
+	}
+	actor, _ := c.Ctx.Input.GetData(AuthorizedUserKey).(string)
+	return actor
+}
+
+// GetAdminAudits
+// @Title GetAdminAudits
+// @Tag Admin Audit API
+// @Description get the audit trail of admin configuration changes, newest first
+// @Param   owner       query    string  false    "owner of the changed resource"
+// @Param   field       query    string  false    "field to filter on, e.g. resourceType or actor"
+// @Param   value       query    string  false    "value of the filter field"
+// @Param   pageSize    query    int     false    "page size (default 50)"
+// @Param   p           query    int     false    "page number"
+// @Success 200 {array} object.AdminAudit The Response object
+// @router /get-admin-audits [get]
+func (c *ApiController) GetAdminAudits() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	limit := util.ParseInt(c.Input().Get("pageSize"))
+	if limit <= 0 {
+		limit = 50
+	}
+	field := c.Input().Get("field")
+	value := c.Input().Get("value")
+	sortField := c.Input().Get("sortField")
+	sortOrder := c.Input().Get("sortOrder")
+
+	count, err := object.GetAdminAuditCount(owner, field, value)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	paginator := pagination.SetPaginator(c.Ctx, limit, count)
+	audits, err := object.GetPaginationAdminAudits(owner, paginator.Offset(), limit, field, value, sortField, sortOrder)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(audits, paginator.Nums())
+}
@@ -18,11 +18,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/beego/beego/context"
 	"github.com/hanzoai/cloud/model"
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/proxy"
 	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 	"github.com/sashabaranov/go-openai"
@@ -62,8 +65,20 @@ func (m *AnthropicMessage) ContentText() string {
 
 // AnthropicContentBlock is a content block in the response.
 type AnthropicContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"` // of thinking blocks; empty, the reasoning is not Anthropic's
+}
+
+// MarshalJSON leaves the text out of thinking blocks, which always carry a
+// signature.
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	if b.Type == "thinking" {
+		return json.Marshal(map[string]string{"type": b.Type, "thinking": b.Thinking, "signature": b.Signature})
+	}
+	type block AnthropicContentBlock
+	return json.Marshal(block(b))
 }
 
 // rawContentToText converts a json.RawMessage that is either a JSON string
@@ -124,46 +139,96 @@ type AnthropicErrorBody struct {
 // and emitting SSE events in Anthropic format for streaming.
 type AnthropicWriter struct {
 	context.Response
-	Cleaner    Cleaner
-	Buffer     []byte
-	MessageBuf []byte
-	RequestID  string
-	Stream     bool
-	StreamSent bool
-	Model      string
-	headerSent bool
+	Cleaner      Cleaner
+	ThinkTags    thinkTagSplitter
+	Buffer       []byte
+	MessageBuf   []byte
+	ReasoningBuf []byte
+	RequestID    string
+	Stream       bool
+	StreamSent   bool
+	Model        string
+	Timing       streamTiming
+	Live         *liveRequest
+	Keepalive    *sseKeepalive
+	headerSent   bool
+	blockIndex   int    // Index of the open content block
+	blockType    string // "text" or "thinking" once a block is open
 }
 
 // Write processes incoming data chunks from the model provider.
 func (w *AnthropicWriter) Write(p []byte) (n int, err error) {
-	var content string
+	var parts []thinkTagPart
 
 	if bytes.HasPrefix(p, []byte("event: message\ndata: ")) {
 		prefix := []byte("event: message\ndata: ")
 		suffix := []byte("\n\n")
-		content = string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
-		w.MessageBuf = append(w.MessageBuf, []byte(content)...)
+		parts = w.ThinkTags.split(string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix)))
 	} else if bytes.HasPrefix(p, []byte("event: reason\ndata: ")) {
+		// Reasoning streamed apart is sent as ThinkTags says; see think_tags.go
 		prefix := []byte("event: reason\ndata: ")
 		suffix := []byte("\n\n")
-		content = string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
-	} else {
-		content = w.Cleaner.CleanString(string(p))
-		if content != "" {
-			w.MessageBuf = append(w.MessageBuf, []byte(content)...)
+		content := string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
+		for _, part := range w.ThinkTags.reason(content) {
+			if part.reasoning {
+				w.ReasoningBuf = append(w.ReasoningBuf, []byte(part.text)...)
+			}
+			if err = w.send(part); err != nil {
+				return 0, err
+			}
 		}
+		w.Buffer = append(w.Buffer, p...)
+		return len(p), nil
+	} else {
+		parts = w.ThinkTags.raw(string(p), &w.Cleaner)
 	}
 
 	w.Buffer = append(w.Buffer, p...)
+	for _, part := range parts {
+		w.buffer(part)
+		if err = w.send(part); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
 
-	if !w.Stream {
-		return len(p), nil
+// FlushThinkTags sends the end of the answer ThinkTags held back, once the
+// upstream has answered.
+func (w *AnthropicWriter) FlushThinkTags() error {
+	for _, part := range w.ThinkTags.flush() {
+		w.buffer(part)
+		if err := w.send(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *AnthropicWriter) buffer(part thinkTagPart) {
+	if part.reasoning {
+		w.ReasoningBuf = append(w.ReasoningBuf, []byte(part.text)...)
+	} else {
+		w.MessageBuf = append(w.MessageBuf, []byte(part.text)...)
 	}
+}
 
-	if content == "" {
-		return len(p), nil
+// send streams a part of the answer as a delta of a text block, or of a
+// thinking block for reasoning.
+func (w *AnthropicWriter) send(part thinkTagPart) error {
+	if part.text == "" {
+		return nil
+	}
+	w.Timing.markToken()
+	w.Live.progress()
+
+	if !w.Stream {
+		return nil
 	}
 
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
+
 	// Emit header events on first content chunk.
 	if !w.headerSent {
 		w.headerSent = true
@@ -184,38 +249,87 @@ func (w *AnthropicWriter) Write(p []byte) (n int, err error) {
 			},
 		}
 		if err := w.writeSSE("message_start", msgStart); err != nil {
-			return 0, err
+			return err
 		}
+	}
 
-		// content_block_start
-		blockStart := map[string]interface{}{
-			"type":  "content_block_start",
-			"index": 0,
-			"content_block": map[string]interface{}{
-				"type": "text",
-				"text": "",
-			},
-		}
-		if err := w.writeSSE("content_block_start", blockStart); err != nil {
-			return 0, err
-		}
+	blockType, deltaType, field := "text", "text_delta", "text"
+	if part.reasoning {
+		blockType, deltaType, field = "thinking", "thinking_delta", "thinking"
+	}
+	if err := w.startBlock(blockType, field); err != nil {
+		return err
 	}
 
 	// content_block_delta
 	delta := map[string]interface{}{
 		"type":  "content_block_delta",
-		"index": 0,
+		"index": w.blockIndex,
 		"delta": map[string]interface{}{
-			"type": "text_delta",
-			"text": content,
+			"type": deltaType,
+			field:  part.text,
 		},
 	}
 	if err := w.writeSSE("content_block_delta", delta); err != nil {
-		return 0, err
+		return err
 	}
 
 	w.StreamSent = true
-	return len(p), nil
+	return nil
+}
+
+// startBlock opens a content block of blockType, closing the open one if
+// it is of another type.
+func (w *AnthropicWriter) startBlock(blockType string, field string) error {
+	if w.blockType == blockType {
+		return nil
+	}
+	if w.blockType != "" {
+		if err := w.stopBlock(); err != nil {
+			return err
+		}
+		w.blockIndex++
+	}
+	w.blockType = blockType
+
+	// content_block_start
+	contentBlock := map[string]interface{}{
+		"type": blockType,
+		field:  "",
+	}
+	if blockType == "thinking" {
+		contentBlock["signature"] = ""
+	}
+	blockStart := map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         w.blockIndex,
+		"content_block": contentBlock,
+	}
+	return w.writeSSE("content_block_start", blockStart)
+}
+
+func (w *AnthropicWriter) stopBlock() error {
+	if w.blockType == "thinking" {
+		// Thinking blocks end with their signature, empty as the reasoning
+		// is not Anthropic's.
+		signature := map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": w.blockIndex,
+			"delta": map[string]interface{}{
+				"type":      "signature_delta",
+				"signature": "",
+			},
+		}
+		if err := w.writeSSE("content_block_delta", signature); err != nil {
+			return err
+		}
+	}
+	// content_block_stop
+	blockStop := map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": w.blockIndex,
+	}
+	return w.writeSSE("content_block_stop", blockStop)
 }
 
 // MessageString returns the full accumulated message text.
@@ -223,6 +337,28 @@ func (w *AnthropicWriter) MessageString() string {
 	return string(w.MessageBuf)
 }
 
+// ReasoningString returns the reasoning sent apart from the message text.
+func (w *AnthropicWriter) ReasoningString() string {
+	return string(w.ReasoningBuf)
+}
+
+// Started reports whether anything reached the client, after which the
+// response status can no longer change.
+func (w *AnthropicWriter) Started() bool {
+	return w.StreamSent || w.Keepalive.Sent()
+}
+
+// WriteError ends a started stream with an Anthropic error event.
+func (w *AnthropicWriter) WriteError(errType string, message string) error {
+	body := AnthropicErrorBody{Type: "error"}
+	body.Error.Type = errType
+	body.Error.Message = message
+
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
+	return w.writeSSE("error", body)
+}
+
 // Close finalizes the streaming response with stop events.
 func (w *AnthropicWriter) Close(promptTokens, completionTokens, totalTokens int) error {
 	if !w.Stream {
@@ -233,12 +369,10 @@ func (w *AnthropicWriter) Close(promptTokens, completionTokens, totalTokens int)
 		return nil
 	}
 
-	// content_block_stop
-	blockStop := map[string]interface{}{
-		"type":  "content_block_stop",
-		"index": 0,
-	}
-	if err := w.writeSSE("content_block_stop", blockStop); err != nil {
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
+
+	if err := w.stopBlock(); err != nil {
 		return err
 	}
 
@@ -335,12 +469,21 @@ func (c *ApiController) AnthropicMessages() {
 		return
 	}
 
-	// Parse request body.
-	var request AnthropicRequest
-	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &request); err != nil {
+	// Parse request body, with the hanzo extension alongside the Anthropic
+	// fields.
+	var body struct {
+		AnthropicRequest
+		hanzoRequestExtension
+	}
+	if err := c.decodeRequestBody(&body); err != nil {
+		if message := requestBodyTooLarge(err); message != "" {
+			c.respondAnthropicError("request_too_large", message, 413)
+			return
+		}
 		c.respondAnthropicError("invalid_request_error", fmt.Sprintf("Failed to parse request: %s", err.Error()), 400)
 		return
 	}
+	request := body.AnthropicRequest
 
 	if request.Model == "" {
 		c.respondAnthropicError("invalid_request_error", "model is required", 400)
@@ -364,8 +507,11 @@ func (c *ApiController) AnthropicMessages() {
 	var isPremium bool
 	var err error
 
+	// Resolve org context for per-org model routing and branding.
+	orgId := c.GetEffectiveOrg()
+
 	if isIAMApiKey(token) {
-		provider, authUser, upstreamModel, err = resolveProviderFromIAMKey(token, request.Model, c.GetAcceptLanguage())
+		provider, authUser, upstreamModel, err = resolveProviderFromIAMKey(token, util.GetClientIP(c.Ctx.Request), request.Model, c.GetAcceptLanguage())
 		if err != nil {
 			c.respondAnthropicError("authentication_error", fmt.Sprintf("Authentication failed: %s", err.Error()), 401)
 			return
@@ -373,7 +519,8 @@ func (c *ApiController) AnthropicMessages() {
 		if authUser != nil {
 			c.Ctx.Input.SetParam("recordUserId", authUser.Owner+"/"+authUser.Name)
 		}
-		if route := resolveModelRoute(request.Model); route != nil {
+		orgId = c.getCallerOrg(authUser)
+		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
 			isPremium = route.premium
 		}
 	} else if isJwtToken(token) {
@@ -385,7 +532,8 @@ func (c *ApiController) AnthropicMessages() {
 		if authUser != nil {
 			c.Ctx.Input.SetParam("recordUserId", authUser.Owner+"/"+authUser.Name)
 		}
-		if route := resolveModelRoute(request.Model); route != nil {
+		orgId = c.getCallerOrg(authUser)
+		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
 			isPremium = route.premium
 		}
 	} else {
@@ -398,11 +546,51 @@ func (c *ApiController) AnthropicMessages() {
 			c.respondAnthropicError("authentication_error", "Invalid API key", 401)
 			return
 		}
-		if route := resolveModelRoute(request.Model); route != nil {
+		// The key of an organization's provider serves that organization.
+		if provider.Owner != "admin" {
+			orgId = provider.Owner
+		}
+		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
 			upstreamModel = route.upstreamModel
 			isPremium = route.premium
 		}
 	}
+	caller := requestSecretCaller(token, authUser, provider)
+	c.Ctx.Request = c.Ctx.Request.WithContext(withSecretCaller(c.Ctx.Request.Context(), caller))
+
+	// Hold the request to the limits of the store it is made for; see
+	// store_limits.go.
+	store, err := resolveRequestStore(authUser, body.Hanzo.Store)
+	if err != nil {
+		c.respondAnthropicError("permission_error", err.Error(), 403)
+		return
+	}
+	if retryAfter, err := checkStoreFrequency(store, c.storeLimitUser(authUser)); err != nil {
+		c.Ctx.ResponseWriter.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		c.respondAnthropicError("rate_limit_error", err.Error(), 429)
+		return
+	}
+	request.Messages = trimAnthropicStoreMemory(store, request.Messages)
+
+	// Keep the request inside the organization's data residency region.
+	route, err := applyTenantResidency(request.Model, resolveModelRouteForOrg(request.Model, orgId), orgId, caller)
+	if err == nil && route == nil {
+		err = checkProviderResidency(request.Model, provider, orgId)
+	}
+	if err != nil {
+		c.respondAnthropicError("permission_error", err.Error(), 403)
+		return
+	}
+	provider, upstreamModel, err = resolveResidentProvider(route, orgId, caller, provider, upstreamModel)
+	if err != nil {
+		c.respondAnthropicError("api_error", fmt.Sprintf("Failed to get provider: %s", err.Error()), 500)
+		return
+	}
+
+	// Record and bill the org's own name for a zen model as that model; the
+	// caller still sees its own name.
+	brandedModel := request.Model
+	request.Model = resolveBrandedModel(request.Model, orgId)
 
 	if provider.Category != "Model" {
 		c.respondAnthropicError("invalid_request_error", fmt.Sprintf("Provider %s is not a model provider", provider.Name), 400)
@@ -436,18 +624,25 @@ func (c *ApiController) AnthropicMessages() {
 		})
 	}
 
-	// Inject Zen identity prompt.
-	if zenPrompt := zenIdentityPrompt(request.Model); zenPrompt != "" {
-		hasSystem := len(oaiMessages) > 0 && oaiMessages[0].Role == "system"
-		if hasSystem {
-			oaiMessages[0].Content = zenPrompt + "\n\n" + oaiMessages[0].Content
-		} else {
-			oaiMessages = append([]openai.ChatCompletionMessage{{
-				Role:    "system",
-				Content: zenPrompt,
-			}}, oaiMessages...)
-		}
+	// Scrub PII before any of the messages leave the gateway; see pii.go.
+	piiReport, err := scrubPromptPii(orgId, oaiMessages)
+	if err != nil {
+		c.respondAnthropicError("api_error", err.Error(), 503)
+		return
 	}
+	c.setPiiRedactionsHeader(piiReport)
+
+	// Inject Zen identity prompt. The mode is the choice of the caller's
+	// org; widget and provider keys belong to no org, so they always get
+	// the default mode.
+	identityMode, err := body.zenIdentityMode(identityModeOrg(authUser, orgId))
+	if err != nil {
+		c.respondAnthropicError("invalid_request_error", err.Error(), 400)
+		return
+	}
+	identityPrompt := zenIdentityPrompt(brandedModel, orgId)
+	oaiMessages = injectZenIdentity(oaiMessages, identityPrompt, identityMode)
+	scanIdentity := identityPrompt != "" && identityMode != zenIdentityOff
 
 	// Extract question, system, history — mirrors OpenAI endpoint logic.
 	var question string
@@ -473,11 +668,35 @@ func (c *ApiController) AnthropicMessages() {
 		return
 	}
 
+	// Run the organization's input guardrails on the user's message; see
+	// guardrail.go.
+	input := applyGuardrails(c.Ctx.Request.Context(), orgId, object.GuardrailStageInput, question, c.GetAcceptLanguage())
+	if input.Blocked != nil {
+		c.recordGuardrailBlock(authUser, request.Model, isPremium, request.Stream, input, time.Now().UTC())
+		c.respondAnthropicError("invalid_request_error", input.blockedMessage(), 400)
+		return
+	}
+	question = input.Text
+
 	if systemPrompt != "" {
 		question = fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, question)
 	}
 
+	// Enforce the organization's daily and monthly quotas and the member's
+	// spend limit.
+	if authUser != nil {
+		if quota := checkTenantQuota(authUser.Owner); !c.setQuotaHeaders(quota) {
+			c.respondAnthropicError("rate_limit_error", quotaExceededMessage(quota), 429)
+			return
+		}
+		if err = checkMemberSpend(authUser); err != nil {
+			c.respondAnthropicError("rate_limit_error", err.Error(), 429)
+			return
+		}
+	}
+
 	// ── Call model provider ─────────────────────────────────────────────
+	requestStartTime := time.Now().UTC()
 	requestId := util.GenerateUUID()
 
 	if request.Stream {
@@ -492,20 +711,45 @@ func (c *ApiController) AnthropicMessages() {
 		RequestID: requestId,
 		Stream:    request.Stream,
 		Cleaner:   *NewCleaner(6),
-		Model:     request.Model,
+		ThinkTags: newThinkTagSplitter(getRouteThinkTags(route)),
+		Model:     brandedModel,
+		Timing:    newStreamTiming(requestStartTime),
+	}
+	writer.Live = startLiveRequest(requestId, request.Model, provider.Name, tailUserId(authUser), request.Stream, requestStartTime)
+	if request.Stream {
+		writer.Keepalive = startSSEKeepalive(writer.ResponseWriter, getSSEKeepaliveInterval())
+		defer writer.Keepalive.Stop()
+	}
+	if authUser != nil {
+		defer trackTenantInflight(authUser.Owner)()
 	}
 
 	knowledge := []*model.RawMessage{}
 
-	// Resolve the route for failover (may have fallback providers)
-	route := resolveModelRoute(request.Model)
-
+	// Call the model provider with failover support (the route may have
+	// fallback providers)
 	var modelResult *model.ModelResult
 	var actualProvider string
 
+	// With output guardrails the answer is collected and released once it
+	// has been checked, and so is a non-streamed zen answer that may be
+	// retried for an identity leak.
+	target := io.Writer(writer)
+	var held *CarrierWriter
+	if holdsGuardrailOutput(orgId) || (scanIdentity && !request.Stream && retriesIdentityLeaks()) {
+		held = &CarrierWriter{}
+		target = held
+	}
+
+	// The request context is canceled when the client disconnects, which
+	// stops the upstream generation; the route's timeouts bound it as well.
+	deadline := startUpstreamDeadline(c.Ctx.Request.Context(), getRouteTimeouts(route))
+	defer deadline.Stop()
+	ctx, limits := proxy.WithRateLimitRecorder(deadline.Context())
+	upstreamWriter := deadline.Writer(target)
 	if route != nil && len(route.fallbacks) > 0 {
 		modelResult, actualProvider, err = failoverQueryText(
-			route, question, writer, history, knowledge,
+			ctx, orgId, route, question, upstreamWriter, history, knowledge,
 			c.GetAcceptLanguage(),
 			func() bool { return writer.StreamSent },
 		)
@@ -517,32 +761,81 @@ func (c *ApiController) AnthropicMessages() {
 			c.respondAnthropicError("api_error", fmt.Sprintf("Failed to get model provider: %s", err.Error()), 500)
 			return
 		}
-		modelResult, err = modelProvider.QueryText(question, writer, history, "", knowledge, nil, c.GetAcceptLanguage())
+		modelResult, err = modelProvider.QueryText(ctx, question, upstreamWriter, history, "", knowledge, nil, c.GetAcceptLanguage())
+		err = upstreamCallError(ctx, err)
 		actualProvider = provider.Name
+		if !clientGone(ctx) {
+			providerHealth.record(actualProvider, err)
+		}
 	}
 
-	if err != nil {
+	writer.Keepalive.Stop()
+
+	disconnected := clientDisconnected(c.Ctx.Request.Context(), modelResult, err)
+	if err != nil && !disconnected {
+		errorClass := classifyUpstreamError(err)
 		if authUser != nil {
 			recordUsage(&usageRecord{
-				Owner:     authUser.Owner,
-				User:      authUser.Owner + "/" + authUser.Name,
-				Model:     request.Model,
-				Provider:  actualProvider,
-				Premium:   isPremium,
-				Stream:    request.Stream,
-				Status:    "error",
-				ErrorMsg:  err.Error(),
-				ClientIP:  c.Ctx.Request.RemoteAddr,
-				RequestID: requestId,
+				Owner:      authUser.Owner,
+				User:       authUser.Owner + "/" + authUser.Name,
+				Model:      request.Model,
+				Provider:   actualProvider,
+				Premium:    isPremium,
+				Stream:     request.Stream,
+				Status:     "error",
+				ErrorMsg:   err.Error(),
+				ErrorClass: errorClass,
+				ClientIP:   c.getClientIp(),
+				RequestID:  requestId,
+				LatencyMs:  time.Since(requestStartTime).Milliseconds(),
+				Prompt:     question,
+				Guardrails: input.Decisions,
 			})
 		}
-		c.respondAnthropicError("api_error", err.Error(), 500)
+		c.setUpstreamRetryAfter(errorClass, actualProvider, limits)
+		if writer.Started() {
+			// The stream is committed to a 200; end it with an error event.
+			_ = writer.WriteError(getUpstreamErrorResponse(errorClass).anthropicType, err.Error())
+			c.EnableRender = false
+			return
+		}
+		c.respondAnthropicUpstreamError(errorClass, err.Error())
 		return
 	}
 
-	// Record successful usage (actualProvider reflects which provider served the request).
+	// Scan a zen answer for an identity leak, retrying a held one; see
+	// identity_leak.go.
+	if scanIdentity && !disconnected {
+		if held == nil {
+			checkIdentityLeak(request.Model, writer.MessageString(), nil)
+		} else {
+			retry := newIdentityLeakRetry(ctx, orgId, route, provider, actualProvider, question, history, knowledge, c.GetAcceptLanguage())
+			answer, retried := checkIdentityLeak(request.Model, held.MessageString(), retry)
+			if retried != nil {
+				addRetryUsage(modelResult, retried)
+				held = &CarrierWriter{}
+				_ = replayGuardedAnswer(held, answer)
+			}
+		}
+	}
+
+	// Check the held answer against the output guardrails and release it.
+	guardrails := input.Decisions
+	var output *guardrailOutcome
+	if held != nil && !disconnected {
+		output = applyGuardrails(c.Ctx.Request.Context(), orgId, object.GuardrailStageOutput, held.MessageString(), c.GetAcceptLanguage())
+		guardrails = append(guardrails, output.Decisions...)
+		if output.Blocked == nil {
+			_ = replayGuardedAnswer(writer, output.Text)
+		}
+	}
+	_ = writer.FlushThinkTags()
+
+	// Record successful usage (actualProvider reflects which provider served the
+	// request), including the part generated before the client disconnected.
+	// An answer the output guardrails blocked was still generated and billed.
 	if authUser != nil {
-		recordUsage(&usageRecord{
+		successRecord := &usageRecord{
 			Owner:            authUser.Owner,
 			User:             authUser.Owner + "/" + authUser.Name,
 			Organization:     authUser.Owner,
@@ -555,23 +848,52 @@ func (c *ApiController) AnthropicMessages() {
 			Premium:          isPremium,
 			Stream:           request.Stream,
 			Status:           "success",
-			ClientIP:         c.Ctx.Request.RemoteAddr,
+			ClientIP:         c.getClientIp(),
 			RequestID:        requestId,
-		})
+			LatencyMs:        time.Since(requestStartTime).Milliseconds(),
+		}
+		writer.Timing.apply(successRecord)
+		successRecord.Prompt = question
+		successRecord.Response = writer.MessageString()
+		successRecord.Guardrails = guardrails
+		if !disconnected {
+			successRecord.FinishReason = finishReasonOf(modelResult)
+		}
+		if disconnected {
+			successRecord.ErrorMsg = "client disconnected: " + err.Error()
+		}
+		if output != nil && output.Blocked != nil {
+			successRecord.ErrorMsg = output.blockedMessage()
+		}
+		recordUsage(successRecord)
+	}
+	if disconnected {
+		return
+	}
+	if output != nil && output.Blocked != nil {
+		if writer.Started() {
+			_ = writer.WriteError("invalid_request_error", output.blockedMessage())
+			c.EnableRender = false
+			return
+		}
+		c.respondAnthropicError("invalid_request_error", output.blockedMessage(), 400)
+		return
 	}
 
 	// ── Build response ──────────────────────────────────────────────────
 	if !request.Stream {
 		answer := writer.MessageString()
+		content := []AnthropicContentBlock{{Type: "text", Text: answer}}
+		if reasoning := writer.ReasoningString(); reasoning != "" {
+			content = append([]AnthropicContentBlock{{Type: "thinking", Thinking: reasoning}}, content...)
+		}
 
 		response := AnthropicResponse{
-			ID:   "msg_" + requestId,
-			Type: "message",
-			Role: "assistant",
-			Content: []AnthropicContentBlock{
-				{Type: "text", Text: answer},
-			},
-			Model:      request.Model,
+			ID:         "msg_" + requestId,
+			Type:       "message",
+			Role:       "assistant",
+			Content:    content,
+			Model:      brandedModel,
 			StopReason: "end_turn",
 			Usage: AnthropicUsage{
 				InputTokens:  modelResult.PromptTokenCount,
@@ -23,6 +23,7 @@ import (
 	"github.com/beego/beego"
 	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 )
 
@@ -92,11 +93,14 @@ func (c *ApiController) GetSessionUsername() string {
 	return GetUserName(user)
 }
 
+// GetRequestTenantOrgID returns the org of the request's verified
+// X-IAM-Org-Id header or credentials, or "" when there is none.
 func (c *ApiController) GetRequestTenantOrgID() string {
 	if c == nil || c.Ctx == nil {
 		return ""
 	}
-	return strings.TrimSpace(c.Ctx.Input.Header("X-IAM-Org-Id"))
+	orgID, _ := c.Ctx.Input.GetData(TenantOrgKey).(string)
+	return strings.TrimSpace(orgID)
 }
 
 func (c *ApiController) GetRequestTenantProjectID() string {
@@ -214,11 +218,17 @@ func wrapActionResponse2(affected bool, data interface{}, e ...error) *Response
 }
 
 func (c *ApiController) Finish() {
-	if strings.HasPrefix(c.Ctx.Input.URL(), "/api") {
+	if url := c.Ctx.Input.URL(); strings.HasPrefix(url, "/api") || strings.HasPrefix(url, "/v1/") {
+		// Label by the route pattern, so URLs carrying IDs share a series.
+		pattern, _ := c.Ctx.Input.GetData("RouterPattern").(string)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		object.ApiThroughput.WithLabelValues(pattern, c.Ctx.Input.Method()).Inc()
 		startTime := c.Ctx.Input.GetData("startTime")
 		if startTime != nil {
 			latency := time.Since(startTime.(time.Time)).Milliseconds()
-			object.ApiLatency.WithLabelValues(c.Ctx.Input.URL(), c.Ctx.Input.Method()).Observe(float64(latency))
+			object.ObserveApiLatency(pattern, c.Ctx.Input.Method(), float64(latency), util.GetTraceId(c.Ctx.Request))
 		}
 	}
 	c.errorLogFilter()
@@ -0,0 +1,211 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Commerce balance webhook. Commerce posts an event whenever a user's
+// balance changes (a deposit, a charge, a refund), signed like the
+// gateway's own webhooks with commerceWebhookSecret:
+//
+//	X-Webhook-Signature: t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+//	{"id": "evt_…", "type": "balance.updated", "user": "acme/alice", "currency": "usd", "available": 1250, "created": 1760000000}
+//
+// The balance gate then serves the new balance, or looks it up again when
+// the event carries none, instead of waiting out its cache TTL: a user who
+// topped up is let in at once and a drained one is stopped at once.
+//
+// Commerce retries deliveries, so an event may arrive twice or after a
+// newer one. Events already seen (by id) and events older than the last
+// one applied to their user are acknowledged and ignored, so neither a
+// replay nor a late retry overwrites a newer balance.
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+)
+
+// commerceWebhookTolerance is how far a signature's timestamp may be from
+// the current time.
+const commerceWebhookTolerance = 5 * time.Minute
+
+const (
+	// commerceEventTTL is how long an event id is remembered; longer than
+	// the signature tolerance, past which a replay is rejected anyway.
+	commerceEventTTL = 2 * commerceWebhookTolerance
+	// commerceEventTimeTTL is how long the time of the last event applied
+	// to a user is remembered.
+	commerceEventTimeTTL = 24 * time.Hour
+
+	commerceEventCacheMaxEntries = 100000
+)
+
+var (
+	// commerceEvents holds the ids of the events applied recently.
+	commerceEvents = cache.NewLoading[bool]("commerce-event", cache.Options{
+		MaxEntries: commerceEventCacheMaxEntries,
+		TTL:        commerceEventTTL,
+		Shared:     true,
+	})
+	// commerceEventTimes holds, per user, the time of the last event
+	// applied to them.
+	commerceEventTimes = cache.NewLoading[int64]("commerce-event-time", cache.Options{
+		MaxEntries: commerceEventCacheMaxEntries,
+		TTL:        commerceEventTimeTTL,
+		Shared:     true,
+	})
+	commerceEventMutex sync.Mutex
+)
+
+// CommerceBalanceEvent is a balance change posted by Commerce.
+type CommerceBalanceEvent struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	User     string `json:"user"` // "owner/name"
+	Currency string `json:"currency"`
+	// Available is the balance after the change in cents; nil when the
+	// event only says the balance changed.
+	Available *int64 `json:"available"`
+	// Created is when the change was made, in Unix seconds; when it is
+	// missing the signature's timestamp is used.
+	Created int64 `json:"created"`
+}
+
+var balanceChangeHandlers = struct {
+	sync.RWMutex
+	handlers []func(user string, available *int64)
+}{}
+
+// OnCommerceBalanceChange registers handler to be called with every balance
+// change Commerce posts; available is nil when the new balance is unknown.
+func OnCommerceBalanceChange(handler func(user string, available *int64)) {
+	balanceChangeHandlers.Lock()
+	defer balanceChangeHandlers.Unlock()
+	balanceChangeHandlers.handlers = append(balanceChangeHandlers.handlers, handler)
+}
+
+func notifyBalanceChange(user string, available *int64) {
+	balanceChangeHandlers.RLock()
+	handlers := balanceChangeHandlers.handlers
+	balanceChangeHandlers.RUnlock()
+	for _, handler := range handlers {
+		handler(user, available)
+	}
+}
+
+// CommerceBalanceWebhook
+// @Title CommerceBalanceWebhook
+// @Tag Webhook API
+// @Description receive a signed balance change from Commerce and refresh the cached balance of its user
+// @Param   X-Webhook-Signature header string true "t=<unix timestamp>,v1=<signature>"
+// @Param   body body controllers.CommerceBalanceEvent true "The balance change"
+// @Success 200 {object} controllers.Response The Response object
+// @router /commerce-balance-webhook [post]
+func (c *ApiController) CommerceBalanceWebhook() {
+	secret := conf.GetConfigString("commerceWebhookSecret")
+	if secret == "" {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.ResponseError("the Commerce webhook is not configured")
+		return
+	}
+
+	body := c.Ctx.Input.RequestBody
+	signature := c.Ctx.Request.Header.Get("X-Webhook-Signature")
+	if err := object.VerifyWebhookSignature(secret, signature, body, commerceWebhookTolerance, time.Now()); err != nil {
+		logs.Warn("commerce_webhook: rejected event from %s: %v", c.Ctx.Input.IP(), err)
+		c.Ctx.Output.SetStatus(http.StatusUnauthorized)
+		c.ResponseError("invalid webhook signature")
+		return
+	}
+
+	var event CommerceBalanceEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.ResponseError(err.Error())
+		return
+	}
+	owner, name, _ := strings.Cut(event.User, "/")
+	if owner == "" || name == "" {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.ResponseError("the user must be \"owner/name\"")
+		return
+	}
+
+	eventTime := event.Created
+	if eventTime == 0 {
+		eventTime = signatureTimestamp(signature)
+	}
+	if reason := claimCommerceEvent(event.Id, event.User, eventTime); reason != "" {
+		logs.Info("commerce_webhook: ignored %s %s for user=%s (event=%s)", reason, event.Type, event.User, event.Id)
+		c.ResponseOk()
+		return
+	}
+
+	// The gate caches USD balances; a change in another currency still
+	// means the cached one may be off, so it is looked up again.
+	available := event.Available
+	if event.Currency != "" && !strings.EqualFold(event.Currency, "usd") {
+		available = nil
+	}
+	notifyBalanceChange(event.User, available)
+
+	logs.Info("commerce_webhook: %s for user=%s (event=%s)", event.Type, event.User, event.Id)
+	c.ResponseOk()
+}
+
+// claimCommerceEvent records that the event id for user, made at
+// eventTime, is being applied. It returns why the event must be ignored
+// instead: "duplicate" when the id was applied already, "stale" when a
+// newer event was applied to the user.
+func claimCommerceEvent(id string, user string, eventTime int64) string {
+	commerceEventMutex.Lock()
+	defer commerceEventMutex.Unlock()
+
+	if id != "" {
+		if _, ok := commerceEvents.Peek(id); ok {
+			return "duplicate"
+		}
+	}
+	if last, ok := commerceEventTimes.Peek(user); ok && eventTime < last {
+		return "stale"
+	}
+
+	if id != "" {
+		commerceEvents.Set(id, true)
+	}
+	commerceEventTimes.Set(user, eventTime)
+	return ""
+}
+
+// signatureTimestamp returns the t= timestamp of a verified webhook
+// signature.
+func signatureTimestamp(signature string) int64 {
+	for _, field := range strings.Split(signature, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		if name == "t" {
+			timestamp, _ := strconv.ParseInt(value, 10, 64)
+			return timestamp
+		}
+	}
+	return 0
+}
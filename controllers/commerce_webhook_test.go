@@ -0,0 +1,47 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import "testing"
+
+func TestClaimCommerceEvent(t *testing.T) {
+	user := "acme/claim-test"
+	tests := []struct {
+		id        string
+		eventTime int64
+		want      string
+	}{
+		{"evt_claim_1", 100, ""},
+		{"evt_claim_1", 100, "duplicate"},
+		{"evt_claim_2", 200, ""},
+		{"evt_claim_3", 150, "stale"},
+		{"evt_claim_4", 200, ""},
+		{"evt_claim_3", 300, ""},
+	}
+	for _, test := range tests {
+		if got := claimCommerceEvent(test.id, user, test.eventTime); got != test.want {
+			t.Errorf("claimCommerceEvent(%s, %d) = %q, want %q", test.id, test.eventTime, got, test.want)
+		}
+	}
+}
+
+func TestSignatureTimestamp(t *testing.T) {
+	if got := signatureTimestamp("t=1760000000,v1=abc"); got != 1760000000 {
+		t.Errorf("signatureTimestamp = %d, want 1760000000", got)
+	}
+	if got := signatureTimestamp("v1=abc"); got != 0 {
+		t.Errorf("signatureTimestamp without t = %d, want 0", got)
+	}
+}
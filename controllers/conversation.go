@@ -0,0 +1,396 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Conversation persistence. A conversation is a Chat of the
+// object.ConversationCategory owned by the caller's organization, and its
+// turns are Messages of that chat. Clients create one, then continue it by
+// ID with only the new messages; the gateway puts the stored history in
+// front of them and stores the new messages and the answer once the answer
+// is delivered:
+//
+//	POST /v1/conversations                       {"title": "…", "messages": [...]}
+//	POST /v1/conversations/{id}/messages         {"messages": [...]}
+//	POST /v1/conversations/{id}/completions      a chat request with only the new messages
+//	POST /v1/chat                                {"hanzo": {"conversation": "{id}"}, ...}
+
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
+)
+
+// conversationTurnKey is the context data key of the conversation a chat
+// request continues.
+const conversationTurnKey = "conversationTurn"
+
+// Authors of stored messages other than the user's.
+const (
+	conversationAssistantAuthor = "AI"
+	conversationSystemAuthor    = "System"
+)
+
+// ConversationRequest creates a conversation or appends messages to one.
+type ConversationRequest struct {
+	Title    string                         `json:"title"`
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+}
+
+// ConversationMessage is a stored message of a conversation.
+type ConversationMessage struct {
+	Id          string `json:"id"`
+	Role        string `json:"role"`
+	Content     string `json:"content"`
+	CreatedTime string `json:"createdTime"`
+}
+
+// Conversation is a conversation with its messages, oldest first.
+type Conversation struct {
+	Id          string                `json:"id"`
+	Object      string                `json:"object"` // "conversation"
+	Title       string                `json:"title,omitempty"`
+	CreatedTime string                `json:"createdTime"`
+	UpdatedTime string                `json:"updatedTime"`
+	Messages    []ConversationMessage `json:"messages"`
+}
+
+// conversationTurn is a chat request continuing a conversation: the
+// messages the client sent, to be stored with the answer.
+type conversationTurn struct {
+	chat     *object.Chat
+	messages []openai.ChatCompletionMessage
+}
+
+// messageText returns the text of a chat message, joining its text parts
+// when it has no plain content.
+func messageText(message openai.ChatCompletionMessage) string {
+	if message.Content != "" || len(message.MultiContent) == 0 {
+		return message.Content
+	}
+	var parts []string
+	for _, part := range message.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText && part.Text != "" {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toConversationMessages converts chat messages to messages of user's
+// conversation. Only system, developer, user and assistant messages can be
+// stored; tool calls and their results are not kept.
+func toConversationMessages(messages []openai.ChatCompletionMessage, user string) ([]*object.Message, error) {
+	res := make([]*object.Message, 0, len(messages))
+	for i, message := range messages {
+		author := user
+		switch message.Role {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleDeveloper:
+			author = conversationSystemAuthor
+		case openai.ChatMessageRoleAssistant:
+			author = conversationAssistantAuthor
+		case openai.ChatMessageRoleUser:
+		default:
+			return nil, fmt.Errorf("messages[%d]: role %q cannot be stored in a conversation", i, message.Role)
+		}
+		if len(message.ToolCalls) > 0 {
+			return nil, fmt.Errorf("messages[%d]: tool calls cannot be stored in a conversation", i)
+		}
+		res = append(res, &object.Message{Author: author, Text: messageText(message)})
+	}
+	return res, nil
+}
+
+// fromConversationMessages converts stored messages of a conversation back
+// to chat messages.
+func fromConversationMessages(messages []*object.Message) []openai.ChatCompletionMessage {
+	res := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, message := range messages {
+		role := openai.ChatMessageRoleUser
+		switch message.Author {
+		case conversationAssistantAuthor:
+			role = openai.ChatMessageRoleAssistant
+		case conversationSystemAuthor:
+			role = openai.ChatMessageRoleSystem
+		}
+		res = append(res, openai.ChatCompletionMessage{Role: role, Content: message.Text})
+	}
+	return res
+}
+
+// getConversationForUser returns the conversation id of user, or nil when
+// user has none by that ID.
+func getConversationForUser(id string, user *iamsdk.User) (*object.Chat, error) {
+	chat, err := object.GetConversation(user.Owner, id)
+	if err != nil || chat == nil || chat.User != user.Name {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// newConversation returns the API view of chat and its messages.
+func newConversation(chat *object.Chat, messages []*object.Message) *Conversation {
+	res := &Conversation{
+		Id:          chat.Name,
+		Object:      "conversation",
+		Title:       chat.DisplayName,
+		CreatedTime: chat.CreatedTime,
+		UpdatedTime: chat.UpdatedTime,
+		Messages:    []ConversationMessage{},
+	}
+	for i, message := range fromConversationMessages(messages) {
+		res.Messages = append(res.Messages, ConversationMessage{
+			Id:          messages[i].Name,
+			Role:        message.Role,
+			Content:     message.Content,
+			CreatedTime: messages[i].CreatedTime,
+		})
+	}
+	return res
+}
+
+// authorizeConversationCall authenticates the caller of the conversation
+// endpoints. It writes the error response and returns nil when the call may
+// not proceed.
+func (c *ApiController) authorizeConversationCall() *iamsdk.User {
+	user, err := c.resolveApiUser()
+	if err != nil {
+		c.respondOpenAIError(http.StatusUnauthorized, "authentication_error", "invalid_api_key", err.Error())
+		return nil
+	}
+	return user
+}
+
+// respondConversation writes the conversation chat with its messages.
+func (c *ApiController) respondConversation(chat *object.Chat) {
+	messages, err := object.GetConversationMessages(chat)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+	c.Data["json"] = newConversation(chat, messages)
+	c.ServeJSON()
+}
+
+func (c *ApiController) respondConversationNotFound(id string) {
+	c.respondOpenAIError(http.StatusNotFound, "invalid_request_error", "conversation_not_found",
+		fmt.Sprintf("The conversation '%s' does not exist", id))
+}
+
+// CreateConversation
+// @Title CreateConversation
+// @Tag Conversation API
+// @Description create a conversation kept by the server, optionally with its first messages
+// @Param   body    body    controllers.ConversationRequest  true    "The title and first messages"
+// @Success 200 {object} controllers.Conversation
+// @router /conversations [post]
+func (c *ApiController) CreateConversation() {
+	user := c.authorizeConversationCall()
+	if user == nil {
+		return
+	}
+
+	var request ConversationRequest
+	if err := c.decodeRequestBody(&request); err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	messages, err := toConversationMessages(request.Messages, user.Name)
+	if err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+
+	currentTime := util.GetCurrentTime()
+	chat := &object.Chat{
+		Owner:         user.Owner,
+		Name:          "conv_" + strings.ReplaceAll(util.GenerateUUID(), "-", ""),
+		CreatedTime:   currentTime,
+		UpdatedTime:   currentTime,
+		Organization:  user.Owner,
+		DisplayName:   request.Title,
+		Category:      object.ConversationCategory,
+		Type:          "AI",
+		User:          user.Name,
+		Users:         []string{user.Name},
+		ClientIp:      c.getClientIp(),
+		UserAgent:     c.getUserAgent(),
+		ClientIpDesc:  util.GetDescFromIP(c.getClientIp()),
+		UserAgentDesc: util.GetDescFromUserAgent(c.getUserAgent()),
+	}
+	if _, err = object.AddChat(chat); err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+	if err = object.AppendConversationMessages(chat, messages); err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+
+	c.respondConversation(chat)
+}
+
+// GetConversation
+// @Title GetConversation
+// @Tag Conversation API
+// @Description get a conversation with its messages
+// @Param   id      path    string  true    "The conversation ID"
+// @Success 200 {object} controllers.Conversation
+// @router /conversations/:id [get]
+func (c *ApiController) GetConversation() {
+	user := c.authorizeConversationCall()
+	if user == nil {
+		return
+	}
+
+	id := c.Ctx.Input.Param(":id")
+	chat, err := getConversationForUser(id, user)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+	if chat == nil {
+		c.respondConversationNotFound(id)
+		return
+	}
+
+	c.respondConversation(chat)
+}
+
+// AppendConversationMessages
+// @Title AppendConversationMessages
+// @Tag Conversation API
+// @Description append messages to a conversation without asking for an answer
+// @Param   id      path    string  true    "The conversation ID"
+// @Param   body    body    controllers.ConversationRequest  true    "The messages to append"
+// @Success 200 {object} controllers.Conversation
+// @router /conversations/:id/messages [post]
+func (c *ApiController) AppendConversationMessages() {
+	user := c.authorizeConversationCall()
+	if user == nil {
+		return
+	}
+
+	id := c.Ctx.Input.Param(":id")
+	chat, err := getConversationForUser(id, user)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+	if chat == nil {
+		c.respondConversationNotFound(id)
+		return
+	}
+
+	var request ConversationRequest
+	if err = c.decodeRequestBody(&request); err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	messages, err := toConversationMessages(request.Messages, user.Name)
+	if err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	if err = object.AppendConversationMessages(chat, messages); err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+
+	c.respondConversation(chat)
+}
+
+// ContinueConversation
+// @Title ContinueConversation
+// @Tag Conversation API
+// @Description continue a conversation: a chat completion request with only the new messages, answered with the stored history in front of them
+// @Param   id      path    string  true    "The conversation ID"
+// @Param   body    body    openai.ChatCompletionRequest  true    "The chat request with the new messages"
+// @Success 200 {object} openai.ChatCompletionResponse
+// @router /conversations/:id/completions [post]
+func (c *ApiController) ContinueConversation() {
+	c.ChatCompletions()
+}
+
+// loadConversation puts the history of the conversation a chat request
+// continues, from its hanzo.conversation field or the path, in front of the
+// request's messages. It writes the error response and returns false when
+// the request cannot continue it.
+func (c *ApiController) loadConversation(id string, authUser *iamsdk.User, request *openai.ChatCompletionRequest) bool {
+	if id == "" {
+		id = c.Ctx.Input.Param(":id")
+	}
+	if id == "" {
+		return true
+	}
+
+	if authUser == nil {
+		c.respondOpenAIError(http.StatusUnauthorized, "authentication_error", "invalid_api_key",
+			"Conversations need an hk- API key, a hanzo.id token or a signed-in session")
+		return false
+	}
+	if len(request.Tools) > 0 || request.ToolChoice != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request",
+			"Tools cannot be used in a conversation")
+		return false
+	}
+	if _, err := toConversationMessages(request.Messages, authUser.Name); err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return false
+	}
+
+	chat, err := getConversationForUser(id, authUser)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return false
+	}
+	if chat == nil {
+		c.respondConversationNotFound(id)
+		return false
+	}
+	history, err := object.GetConversationMessages(chat)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return false
+	}
+
+	c.Ctx.Input.SetData(conversationTurnKey, &conversationTurn{chat: chat, messages: request.Messages})
+	request.Messages = append(fromConversationMessages(history), request.Messages...)
+	return true
+}
+
+// saveConversationTurn stores the messages of a chat request continuing a
+// conversation and the answer it got. The answer has been delivered, so a
+// failure is only logged.
+func (c *ApiController) saveConversationTurn(answer string) {
+	turn, ok := c.Ctx.Input.GetData(conversationTurnKey).(*conversationTurn)
+	if !ok {
+		return
+	}
+
+	messages, err := toConversationMessages(turn.messages, turn.chat.User)
+	if err == nil {
+		messages = append(messages, &object.Message{Author: conversationAssistantAuthor, Text: answer})
+		err = object.AppendConversationMessages(turn.chat, messages)
+	}
+	if err != nil {
+		logs.Error("conversation: failed to store a turn of %s/%s: %v", turn.chat.Owner, turn.chat.Name, err)
+	}
+}
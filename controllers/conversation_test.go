@@ -0,0 +1,94 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestConversationMessagesRoundTrip(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "What is in"},
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "https://example.com/a.png"}},
+			{Type: openai.ChatMessagePartTypeText, Text: "this picture?"},
+		}},
+		{Role: openai.ChatMessageRoleAssistant, Content: "A cat."},
+		{Role: openai.ChatMessageRoleDeveloper, Content: "Answer in French."},
+	}
+
+	stored, err := toConversationMessages(messages, "alice")
+	if err != nil {
+		t.Fatalf("toConversationMessages() error = %v", err)
+	}
+	var authors []string
+	for _, message := range stored {
+		authors = append(authors, message.Author)
+	}
+	if want := []string{"System", "alice", "AI", "System"}; !reflect.DeepEqual(authors, want) {
+		t.Errorf("authors = %v, want %v", authors, want)
+	}
+	if stored[1].Text != "What is in\nthis picture?" {
+		t.Errorf("text of a multi-part message = %q", stored[1].Text)
+	}
+
+	want := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleUser, Content: "What is in\nthis picture?"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "A cat."},
+		{Role: openai.ChatMessageRoleSystem, Content: "Answer in French."},
+	}
+	if got := fromConversationMessages(stored); !reflect.DeepEqual(got, want) {
+		t.Errorf("fromConversationMessages() = %+v, want %+v", got, want)
+	}
+}
+
+func TestToConversationMessagesRejectsTools(t *testing.T) {
+	tests := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleTool, Content: "72F", ToolCallID: "call_1"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{{ID: "call_1", Type: openai.ToolTypeFunction}}},
+	}
+	for _, message := range tests {
+		if _, err := toConversationMessages([]openai.ChatCompletionMessage{message}, "alice"); err == nil {
+			t.Errorf("toConversationMessages(%+v) succeeded, want an error", message)
+		}
+	}
+}
+
+func TestNewConversation(t *testing.T) {
+	chat := &object.Chat{Owner: "acme", Name: "conv_1", DisplayName: "Trip", CreatedTime: "2026-01-02T03:04:05Z", UpdatedTime: "2026-01-02T03:05:00Z"}
+
+	empty := newConversation(chat, nil)
+	if empty.Id != "conv_1" || empty.Object != "conversation" || empty.Title != "Trip" || empty.Messages == nil {
+		t.Errorf("newConversation() = %+v", empty)
+	}
+
+	conversation := newConversation(chat, []*object.Message{
+		{Name: "message_1", Author: "alice", Text: "Hi", CreatedTime: "2026-01-02T03:04:06Z"},
+		{Name: "message_2", Author: "AI", Text: "Hello!", CreatedTime: "2026-01-02T03:04:07Z"},
+	})
+	want := []ConversationMessage{
+		{Id: "message_1", Role: "user", Content: "Hi", CreatedTime: "2026-01-02T03:04:06Z"},
+		{Id: "message_2", Role: "assistant", Content: "Hello!", CreatedTime: "2026-01-02T03:04:07Z"},
+	}
+	if !reflect.DeepEqual(conversation.Messages, want) {
+		t.Errorf("messages = %+v, want %+v", conversation.Messages, want)
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/embedding"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/proxy"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+// embeddingRoute maps a user-facing embedding or rerank model to the
+// Embedding-category provider serving it and the model ID sent upstream.
+type embeddingRoute struct {
+	providerName  string
+	upstreamModel string
+}
+
+// embeddingRoutes serves /v1/embeddings. Keys are lowercase model names.
+var embeddingRoutes = map[string]embeddingRoute{
+	"embed-v4.0":              {providerName: "cohere-embedding", upstreamModel: "embed-v4.0"},
+	"embed-english-v3.0":      {providerName: "cohere-embedding", upstreamModel: "embed-english-v3.0"},
+	"embed-multilingual-v3.0": {providerName: "cohere-embedding", upstreamModel: "embed-multilingual-v3.0"},
+	"mistral-embed":           {providerName: "mistral-embedding", upstreamModel: "mistral-embed"},
+	"codestral-embed":         {providerName: "mistral-embedding", upstreamModel: "codestral-embed"},
+}
+
+// rerankRoutes serves /v1/rerank. Keys are lowercase model names. Rerank
+// models are billed per search unit, one query over up to 100 documents,
+// whatever unit the upstream uses.
+var rerankRoutes = map[string]embeddingRoute{
+	"rerank-v3.5":                        {providerName: "cohere-embedding", upstreamModel: "rerank-v3.5"},
+	"rerank-english-v3.0":                {providerName: "cohere-embedding", upstreamModel: "rerank-english-v3.0"},
+	"rerank-multilingual-v3.0":           {providerName: "cohere-embedding", upstreamModel: "rerank-multilingual-v3.0"},
+	"jina-reranker-v2-base-multilingual": {providerName: "jina-embedding", upstreamModel: "jina-reranker-v2-base-multilingual"},
+	"jina-reranker-m0":                   {providerName: "jina-embedding", upstreamModel: "jina-reranker-m0"},
+	"jina-colbert-v2":                    {providerName: "jina-embedding", upstreamModel: "jina-colbert-v2"},
+}
+
+type embeddingsRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// maxRerankDocuments caps the documents of one rerank request, the limit
+// Cohere recommends and Jina enforces.
+const maxRerankDocuments = 1000
+
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// parseEmbeddingsInput accepts the OpenAI input forms with text: a string or
+// an array of strings.
+func parseEmbeddingsInput(raw json.RawMessage) ([]string, error) {
+	var text *string
+	if err := json.Unmarshal(raw, &text); err == nil && text != nil {
+		return []string{*text}, nil
+	}
+	var texts []string
+	if err := json.Unmarshal(raw, &texts); err != nil || len(texts) == 0 {
+		return nil, fmt.Errorf("input must be a string or a non-empty array of strings")
+	}
+	return texts, nil
+}
+
+// resolveApiUser identifies the caller of the gateway's non-chat endpoints
+// from a Bearer hk- key, or the signed-in session when no Authorization
+// header is sent.
+func (c *ApiController) resolveApiUser() (*iamsdk.User, error) {
+	authHeader := c.Ctx.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		if user := c.GetSessionUser(); user != nil {
+			return user, nil
+		}
+		return nil, fmt.Errorf("please sign in or provide an hk- API key")
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !isIAMApiKey(token) {
+		return nil, fmt.Errorf("invalid API key format, expected 'Bearer hk-...'")
+	}
+	user, err := getUserByAccessKey(token, util.GetClientIP(c.Ctx.Request))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	if err = checkMemberKeys(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// authorizeEmbeddingCall authenticates the caller and enforces the quotas
+// chat completions enforce. It writes the error response and returns nil
+// when the call may not proceed.
+func (c *ApiController) authorizeEmbeddingCall() *iamsdk.User {
+	user, err := c.resolveApiUser()
+	if err != nil {
+		c.respondOpenAIError(http.StatusUnauthorized, "authentication_error", "invalid_api_key", err.Error())
+		return nil
+	}
+	if quota := checkTenantQuota(user.Owner); !c.setQuotaHeaders(quota) {
+		c.respondOpenAIError(http.StatusTooManyRequests, "insufficient_quota", "quota_exceeded", quotaExceededMessage(quota))
+		return nil
+	}
+	if err = checkMemberSpend(user); err != nil {
+		c.respondOpenAIError(http.StatusTooManyRequests, "insufficient_quota", "member_spend_limit", err.Error())
+		return nil
+	}
+	return user
+}
+
+// getRoutedEmbeddingProvider returns the provider serving route for org,
+// set to the route's upstream model.
+func getRoutedEmbeddingProvider(route embeddingRoute, org string, caller string, lang string) (*object.Provider, embedding.EmbeddingProvider, error) {
+	provider, err := object.GetEmbeddingProviderForOrg(org, route.providerName, caller)
+	if err != nil {
+		return nil, nil, err
+	}
+	if provider == nil {
+		return nil, nil, fmt.Errorf("embedding provider %s is not configured", route.providerName)
+	}
+	provider.SubType = route.upstreamModel
+	embeddingProvider, err := provider.GetEmbeddingProvider(lang)
+	if err != nil {
+		return nil, nil, err
+	}
+	return provider, embeddingProvider, nil
+}
+
+// embeddingFanOut is the most inputs of one embeddings request embedded at
+// once.
+const embeddingFanOut = 8
+
+// queryVectors embeds texts in parallel, at most embeddingFanOut at once,
+// and returns their vectors in order with the tokens counted. The first
+// failure cancels the calls still running and is returned with the tokens
+// of the calls that succeeded, which were still billed upstream.
+func queryVectors(ctx context.Context, embeddingProvider embedding.EmbeddingProvider, texts []string, lang string) ([][]float32, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	vectors := make([][]float32, len(texts))
+	tokens := 0
+	var firstErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inflight := make(chan struct{}, embeddingFanOut)
+	for i, text := range texts {
+		inflight <- struct{}{}
+		if ctx.Err() != nil {
+			<-inflight
+			break
+		}
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			vector, result, err := embeddingProvider.QueryVector(text, ctx, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			if result != nil {
+				tokens += result.TokenCount
+			}
+			vectors[i] = vector
+		}(i, text)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return vectors, tokens, firstErr
+}
+
+// recordEmbeddingUsage records and bills a call to an embedding or rerank
+// model; tokens counts input tokens, or search units for rerank models.
+func (c *ApiController) recordEmbeddingUsage(user *iamsdk.User, model string, providerName string, tokens int, err error, requestId string, startTime time.Time) {
+	record := &usageRecord{
+		Owner:        user.Owner,
+		User:         user.Owner + "/" + user.Name,
+		Organization: user.Owner,
+		Model:        model,
+		Provider:     providerName,
+		PromptTokens: tokens,
+		TotalTokens:  tokens,
+		Currency:     "USD",
+		Status:       "success",
+		ClientIP:     c.getClientIp(),
+		RequestID:    requestId,
+		LatencyMs:    time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		record.Status = "error"
+		record.ErrorMsg = err.Error()
+		record.ErrorClass = classifyUpstreamError(err)
+	}
+	recordUsage(record)
+}
+
+// Embeddings
+// @Title Embeddings
+// @Tag OpenAI Compatible API
+// @Description Create embeddings with an OpenAI-compatible request, served by Cohere or Mistral embedding models.
+// @Param Authorization header string true "Bearer hk- API key"
+// @Param body body object true "{model, input}: input is a string or an array of strings"
+// @Success 200 {object} object
+// @router /embeddings [post]
+func (c *ApiController) Embeddings() {
+	startTime := time.Now()
+	var request embeddingsRequest
+	if err := c.decodeRequestBody(&request); err != nil {
+		if message := requestBodyTooLarge(err); message != "" {
+			c.respondOpenAIError(http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large", message)
+			return
+		}
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_json", fmt.Sprintf("Failed to parse request: %s", err.Error()))
+		return
+	}
+	texts, err := parseEmbeddingsInput(request.Input)
+	if err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_input", err.Error())
+		return
+	}
+	route, ok := embeddingRoutes[strings.ToLower(request.Model)]
+	if !ok {
+		c.respondOpenAIError(http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("The embedding model %q does not exist", request.Model))
+		return
+	}
+
+	user := c.authorizeEmbeddingCall()
+	if user == nil {
+		return
+	}
+	provider, embeddingProvider, err := getRoutedEmbeddingProvider(route, user.Owner, user.Owner+"/"+user.Name, c.GetAcceptLanguage())
+	if err != nil {
+		c.respondOpenAIError(http.StatusServiceUnavailable, "api_error", "provider_unavailable", err.Error())
+		return
+	}
+	if err = checkProviderResidency(request.Model, provider, user.Owner); err != nil {
+		c.respondOpenAIError(http.StatusForbidden, "invalid_request_error", "data_residency", err.Error())
+		return
+	}
+
+	requestId := util.GenerateUUID()
+	ctx, limits := proxy.WithRateLimitRecorder(c.Ctx.Request.Context())
+	vectors, tokens, err := queryVectors(ctx, embeddingProvider, texts, c.GetAcceptLanguage())
+	if err != nil {
+		if !clientGone(ctx) {
+			providerHealth.record(provider.Name, err)
+		}
+		c.recordEmbeddingUsage(user, request.Model, provider.Name, tokens, err, requestId, startTime)
+		errorClass := classifyUpstreamError(err)
+		c.setUpstreamRetryAfter(errorClass, provider.Name, limits)
+		c.respondOpenAIUpstreamError(errorClass, err.Error())
+		return
+	}
+	data := make([]map[string]interface{}, 0, len(texts))
+	for i, vector := range vectors {
+		data = append(data, map[string]interface{}{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": vector,
+		})
+	}
+	providerHealth.record(provider.Name, nil)
+	c.recordEmbeddingUsage(user, request.Model, provider.Name, tokens, nil, requestId, startTime)
+
+	c.Data["json"] = map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  request.Model,
+		"usage": map[string]int{
+			"prompt_tokens": tokens,
+			"total_tokens":  tokens,
+		},
+	}
+	c.ServeJSON()
+}
+
+// Rerank
+// @Title Rerank
+// @Tag OpenAI Compatible API
+// @Description Order documents by relevance to a query with a Cohere-compatible request, served by Cohere or Jina rerank models and billed per search unit of up to 100 documents.
+// @Param Authorization header string true "Bearer hk- API key"
+// @Param body body object true "{model, query, documents, top_n}"
+// @Success 200 {object} object
+// @router /rerank [post]
+func (c *ApiController) Rerank() {
+	startTime := time.Now()
+	var request rerankRequest
+	if err := c.decodeRequestBody(&request); err != nil {
+		if message := requestBodyTooLarge(err); message != "" {
+			c.respondOpenAIError(http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large", message)
+			return
+		}
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_json", fmt.Sprintf("Failed to parse request: %s", err.Error()))
+		return
+	}
+	if request.Query == "" || len(request.Documents) == 0 {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_input", "query and documents are required")
+		return
+	}
+	if len(request.Documents) > maxRerankDocuments {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "too_many_documents", fmt.Sprintf("At most %d documents can be reranked per request", maxRerankDocuments))
+		return
+	}
+	route, ok := rerankRoutes[strings.ToLower(request.Model)]
+	if !ok {
+		c.respondOpenAIError(http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("The rerank model %q does not exist", request.Model))
+		return
+	}
+
+	user := c.authorizeEmbeddingCall()
+	if user == nil {
+		return
+	}
+	provider, embeddingProvider, err := getRoutedEmbeddingProvider(route, user.Owner, user.Owner+"/"+user.Name, c.GetAcceptLanguage())
+	if err != nil {
+		c.respondOpenAIError(http.StatusServiceUnavailable, "api_error", "provider_unavailable", err.Error())
+		return
+	}
+	if err = checkProviderResidency(request.Model, provider, user.Owner); err != nil {
+		c.respondOpenAIError(http.StatusForbidden, "invalid_request_error", "data_residency", err.Error())
+		return
+	}
+	reranker, ok := embeddingProvider.(embedding.Reranker)
+	if !ok {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "unsupported_model", fmt.Sprintf("Provider %s cannot rerank", provider.Name))
+		return
+	}
+
+	requestId := util.GenerateUUID()
+	ctx, limits := proxy.WithRateLimitRecorder(c.Ctx.Request.Context())
+	results, result, err := reranker.Rerank(ctx, route.upstreamModel, request.Query, request.Documents, request.TopN)
+	if err != nil {
+		providerHealth.record(provider.Name, err)
+		c.recordEmbeddingUsage(user, request.Model, provider.Name, 0, err, requestId, startTime)
+		errorClass := classifyUpstreamError(err)
+		c.setUpstreamRetryAfter(errorClass, provider.Name, limits)
+		c.respondOpenAIUpstreamError(errorClass, err.Error())
+		return
+	}
+	providerHealth.record(provider.Name, nil)
+	searchUnits := embedding.RerankSearchUnits(len(request.Documents))
+	if result != nil && result.TokenCount > searchUnits {
+		// Cohere counts a long document as several.
+		searchUnits = result.TokenCount
+	}
+	c.recordEmbeddingUsage(user, request.Model, provider.Name, searchUnits, nil, requestId, startTime)
+
+	items := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		items = append(items, map[string]interface{}{
+			"index":           result.Index,
+			"relevance_score": result.RelevanceScore,
+		})
+	}
+	c.Data["json"] = map[string]interface{}{
+		"id":      "rerank-" + requestId,
+		"model":   request.Model,
+		"results": items,
+		"usage": map[string]int{
+			"documents":    len(request.Documents),
+			"search_units": searchUnits,
+		},
+	}
+	c.ServeJSON()
+}
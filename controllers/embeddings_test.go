@@ -0,0 +1,125 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hanzoai/cloud/embedding"
+)
+
+func TestParseEmbeddingsInput(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: `"hello"`, want: []string{"hello"}},
+		{input: `["a", "b"]`, want: []string{"a", "b"}},
+		{input: `[]`, wantErr: true},
+		{input: `[1, 2, 3]`, wantErr: true},
+		{input: `null`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseEmbeddingsInput(json.RawMessage(tt.input))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEmbeddingsInput(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseEmbeddingsInput(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEmbeddingRoutes_ArePriced(t *testing.T) {
+	for _, routes := range []map[string]embeddingRoute{embeddingRoutes, rerankRoutes} {
+		for name := range routes {
+			if name != strings.ToLower(name) {
+				t.Errorf("model key %q is not lowercase", name)
+			}
+			if price, ok := modelPricing[name]; !ok || price.InputPerMillion <= 0 {
+				t.Errorf("model %q has no input price", name)
+			}
+		}
+	}
+}
+
+func TestRerankSearchUnits(t *testing.T) {
+	for documents, want := range map[int]int{0: 1, 1: 1, 100: 1, 101: 2, 250: 3} {
+		if got := embedding.RerankSearchUnits(documents); got != want {
+			t.Errorf("RerankSearchUnits(%d) = %d, want %d", documents, got, want)
+		}
+	}
+}
+
+// fakeEmbeddingProvider embeds a text as its length, one token per byte,
+// and fails the text "fail".
+type fakeEmbeddingProvider struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (p *fakeEmbeddingProvider) GetPricing() string { return "" }
+
+func (p *fakeEmbeddingProvider) QueryVector(text string, ctx context.Context, lang string) ([]float32, *embedding.EmbeddingResult, error) {
+	p.mu.Lock()
+	p.running++
+	p.peak = max(p.peak, p.running)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+	if text == "fail" {
+		return nil, nil, errors.New("upstream 500")
+	}
+	return []float32{float32(len(text))}, &embedding.EmbeddingResult{TokenCount: len(text)}, nil
+}
+
+func TestQueryVectors(t *testing.T) {
+	texts := make([]string, 3*embeddingFanOut)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1)
+	}
+	provider := &fakeEmbeddingProvider{}
+	vectors, tokens, err := queryVectors(context.Background(), provider, texts, "en")
+	if err != nil {
+		t.Fatalf("queryVectors() error = %v", err)
+	}
+	for i, vector := range vectors {
+		if len(vector) != 1 || vector[0] != float32(i+1) {
+			t.Fatalf("vectors[%d] = %v, want the vector of its own text", i, vector)
+		}
+	}
+	if want := len(texts) * (len(texts) + 1) / 2; tokens != want {
+		t.Errorf("tokens = %d, want %d", tokens, want)
+	}
+	if peak := provider.peak; peak > embeddingFanOut {
+		t.Errorf("%d calls ran at once, want at most %d", peak, embeddingFanOut)
+	}
+
+	if _, _, err = queryVectors(context.Background(), provider, []string{"a", "fail", "b"}, "en"); err == nil || err.Error() != "upstream 500" {
+		t.Errorf("queryVectors() error = %v, want the failed call's", err)
+	}
+}
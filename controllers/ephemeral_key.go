@@ -0,0 +1,222 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+const (
+	// defaultEphemeralKeyTTL is used when the caller does not ask for one.
+	defaultEphemeralKeyTTL = 5 * time.Minute
+
+	// defaultEphemeralKeyMaxTTL caps requested lifetimes when
+	// ephemeralKeyMaxTTLMinutes is not configured.
+	defaultEphemeralKeyMaxTTL = 15 * time.Minute
+)
+
+// gatewayTokenRevocationList holds revoked gateway token IDs until the tokens
+// would have expired anyway. Revocations are broadcast through the cache
+// invalidation channel, so every running replica refuses a revoked token;
+// the short token lifetime bounds the exposure on a replica that starts
+// after a revocation.
+type gatewayTokenRevocationList struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// gatewayTokenRevocationCache addresses the revocation broadcasts, whose
+// key is "id|expiry (unix seconds)".
+const gatewayTokenRevocationCache = "gateway-token-revoked"
+
+var gatewayTokenRevocations = &gatewayTokenRevocationList{revoked: make(map[string]time.Time)}
+
+func init() {
+	cache.OnInvalidate(gatewayTokenRevocationCache, applyGatewayTokenRevocation)
+}
+
+// applyGatewayTokenRevocation records a revocation broadcast by another
+// replica.
+func applyGatewayTokenRevocation(key string, prefix bool) {
+	id, expiry, ok := strings.Cut(key, "|")
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if !ok || err != nil || prefix {
+		return
+	}
+	gatewayTokenRevocations.add(id, time.Unix(seconds, 0))
+}
+
+// revoke revokes the token id, which expires at expiresAt, on every replica.
+func (rl *gatewayTokenRevocationList) revoke(id string, expiresAt time.Time) {
+	rl.add(id, expiresAt)
+	cache.Invalidate(gatewayTokenRevocationCache, fmt.Sprintf("%s|%d", id, expiresAt.Unix()))
+}
+
+// add records a revocation on this replica.
+func (rl *gatewayTokenRevocationList) add(id string, expiresAt time.Time) {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for k, exp := range rl.revoked {
+		if now.After(exp) {
+			delete(rl.revoked, k)
+		}
+	}
+	rl.revoked[id] = expiresAt
+}
+
+func (rl *gatewayTokenRevocationList) isRevoked(id string) bool {
+	if id == "" {
+		return false
+	}
+	rl.mu.RLock()
+	_, ok := rl.revoked[id]
+	rl.mu.RUnlock()
+	return ok
+}
+
+func getEphemeralKeyMaxTTL() time.Duration {
+	if minutes := conf.GetConfigInt("ephemeralKeyMaxTTLMinutes"); minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultEphemeralKeyMaxTTL
+}
+
+// resolveKeyOrSessionUser identifies the caller from a Bearer hk- key, or the
+// signed-in session when no Authorization header is sent.
+func (c *ApiController) resolveKeyOrSessionUser() (*iamsdk.User, error) {
+	authHeader := c.Ctx.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		if user := c.GetSessionUser(); user != nil {
+			return user, nil
+		}
+		return nil, fmt.Errorf("please sign in or provide an hk- API key")
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !isIAMApiKey(token) {
+		return nil, fmt.Errorf("only hk- API keys or a signed-in session can be exchanged")
+	}
+	user, err := getUserByAccessKey(token, util.GetClientIP(c.Ctx.Request))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	return user, nil
+}
+
+// CreateEphemeralKey
+// @Title CreateEphemeralKey
+// @Tag Account API
+// @Description exchange a session or hk- key for a short-lived gt- token that browsers can use against the streaming chat endpoints
+// @Param   ttlMinutes    query    int     false    "token lifetime in minutes (default 5, capped by ephemeralKeyMaxTTLMinutes)"
+// @Param   models        query    string  false    "comma-separated models the token is limited to"
+// @Success 200 {object} controllers.Response The Response object
+// @router /create-ephemeral-key [post]
+func (c *ApiController) CreateEphemeralKey() {
+	user, err := c.resolveKeyOrSessionUser()
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	ttl := defaultEphemeralKeyTTL
+	if value := c.Input().Get("ttlMinutes"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			c.ResponseError("ttlMinutes must be a positive integer")
+			return
+		}
+		ttl = time.Duration(minutes) * time.Minute
+	}
+	if maxTTL := getEphemeralKeyMaxTTL(); ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	claims := &gatewayClaims{
+		Owner:  user.Owner,
+		Name:   user.Name,
+		Org:    user.Owner,
+		Scope:  gatewayScopeChat,
+		Models: splitConfigList(c.Input().Get("models")),
+	}
+	token, err := mintGatewayToken(claims, ttl)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(map[string]interface{}{
+		"id":        claims.ID,
+		"token":     token,
+		"expiresAt": claims.ExpiresAt.Time.UTC().Format(time.RFC3339),
+		"models":    claims.Models,
+	})
+}
+
+// RevokeEphemeralKey
+// @Title RevokeEphemeralKey
+// @Tag Account API
+// @Description revoke a gt- token before it expires. The caller must own the token or present it as the Bearer credential
+// @Param   token    query    string  false    "the token to revoke (defaults to the Bearer token)"
+// @Success 200 {object} controllers.Response The Response object
+// @router /revoke-ephemeral-key [post]
+func (c *ApiController) RevokeEphemeralKey() {
+	bearer := strings.TrimPrefix(c.Ctx.Request.Header.Get("Authorization"), "Bearer ")
+	token := c.Input().Get("token")
+	if token == "" {
+		token = bearer
+	}
+	if !isGatewayToken(token) {
+		c.ResponseError("a gt- token is required")
+		return
+	}
+
+	claims, err := parseGatewayToken(token)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	if token != bearer {
+		user, err := c.resolveKeyOrSessionUser()
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		if user.Owner != claims.Owner || user.Name != claims.Name {
+			c.ResponseError("token belongs to a different user")
+			return
+		}
+	}
+
+	gatewayTokenRevocations.revoke(claims.ID, claims.ExpiresAt.Time)
+	logs.Info("gateway token %s revoked for %s/%s", claims.ID, claims.Owner, claims.Name)
+
+	c.ResponseOk()
+}
@@ -0,0 +1,600 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Evals. An admin keeps suites of golden prompts in the eval_suite table
+// and runs them against models, to check a routing change or the zen
+// identity before and after it ships. A run sends every case of the suite
+// to every model as the suite's organization would: through its routes,
+// residency and branded names, with the zen identity prompt. The answers
+// are scored by exact match, by a regular expression, or by the judge
+// model (the suite's judgeModel, else evalJudgeModel in app.conf), and the
+// results are kept in the eval_run table.
+//
+// Runs start from POST /v1/run-eval-suite or from the suite's cron
+// schedule, and go on in the background: poll GET /v1/get-eval-run for the
+// results. Eval calls are admitted and billed like chat requests: against
+// the org's quotas and the balance of the run's user (the caller of a
+// manual run, the last to save the suite for a scheduled one), and recorded
+// as usage. At most evalMaxRuns runs (app.conf) go on at once per instance,
+// each for at most evalRunTimeout.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	"github.com/robfig/cron/v3"
+	"github.com/sashabaranov/go-openai"
+)
+
+// evalCaseTimeout bounds one answer of a model without a total timeout,
+// and every verdict of the judge model.
+const evalCaseTimeout = 2 * time.Minute
+
+// evalRunTimeout bounds a whole run. Runs still running after it, and a
+// grace period, were left behind by an instance that stopped.
+const evalRunTimeout = time.Hour
+
+// defaultEvalMaxRuns is how many runs go on at once per instance when
+// evalMaxRuns is not configured.
+const defaultEvalMaxRuns = 2
+
+var (
+	// evalRunsCtx is the parent of every run's context, canceled by
+	// StopEvalRuns on shutdown.
+	evalRunsCtx, stopEvalRuns = context.WithCancel(context.Background())
+	evalRunSlots              chan struct{}
+	evalRunSlotsOnce          sync.Once
+)
+
+// acquireEvalRunSlot takes one of the evalMaxRuns run slots, or reports
+// that they are all taken.
+func acquireEvalRunSlot() bool {
+	evalRunSlotsOnce.Do(func() {
+		maxRuns := conf.GetConfigInt("evalMaxRuns")
+		if maxRuns <= 0 {
+			maxRuns = defaultEvalMaxRuns
+		}
+		evalRunSlots = make(chan struct{}, maxRuns)
+	})
+	select {
+	case evalRunSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func releaseEvalRunSlot() {
+	<-evalRunSlots
+}
+
+// StopEvalRuns cancels the runs going on; they are stored as failed.
+func StopEvalRuns() {
+	stopEvalRuns()
+}
+
+// evalJudgePrompt asks the judge model for a verdict on an answer.
+const evalJudgePrompt = `You are grading an AI assistant's answer. Reply with "pass" if the answer meets the criteria below, or with "fail: " followed by a short reason if it does not. Reply with nothing else.
+
+Criteria:
+%s
+
+Question:
+%s
+
+Answer:
+%s`
+
+// evalRunRequest is the optional body of POST /v1/run-eval-suite.
+type evalRunRequest struct {
+	Models []string `json:"models"` // run against these instead of the suite's models
+}
+
+// InitEvalScheduler marks failed the runs a stopped instance left running,
+// and starts the scheduled runs of eval suites when they are due, checking
+// every minute.
+func InitEvalScheduler() {
+	failStaleEvalRuns()
+	cronJob := cron.New()
+	_, err := cronJob.AddFunc("@every 1m", runScheduledEvals)
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
+
+// failStaleEvalRuns marks failed the runs older than any run can be.
+func failStaleEvalRuns() {
+	failed, err := object.FailStaleEvalRuns(time.Now().Add(-evalRunTimeout - time.Minute))
+	if err != nil {
+		logs.Warn("eval: failed to mark interrupted runs: %v", err)
+	} else if failed > 0 {
+		logs.Info("eval: marked %d interrupted runs failed", failed)
+	}
+}
+
+func runScheduledEvals() {
+	failStaleEvalRuns()
+	suites, err := object.GetScheduledEvalSuites()
+	if err != nil {
+		logs.Warn("eval: failed to list scheduled suites: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, suite := range suites {
+		if !evalScheduleDue(suite, now) {
+			continue
+		}
+		// Every replica runs this check; only the one that moves the
+		// scheduled time starts the run.
+		claimed, err := object.ClaimEvalSchedule(suite, now.Format(time.RFC3339))
+		if err != nil {
+			logs.Warn("eval: failed to claim the scheduled run of %s: %v", suite.GetId(), err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if _, err = startEvalRun(suite, suite.Models, object.EvalTriggerSchedule, suite.User); err != nil {
+			logs.Warn("eval: failed to start the scheduled run of %s: %v", suite.GetId(), err)
+		}
+	}
+}
+
+// evalScheduleDue reports whether the suite's schedule has come round
+// since its last scheduled run.
+func evalScheduleDue(suite *object.EvalSuite, now time.Time) bool {
+	schedule, err := object.ParseEvalSchedule(suite.Schedule)
+	if err != nil {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339, suite.ScheduledTime)
+	if err != nil {
+		return true
+	}
+	return !schedule.Next(last).After(now)
+}
+
+// startEvalRun records a run of suite against models, billed to user, and
+// runs it in the background.
+func startEvalRun(suite *object.EvalSuite, models []string, trigger string, user string) (*object.EvalRun, error) {
+	if user == "" {
+		return nil, fmt.Errorf("the eval suite %s has no user to bill its runs to: save it again", suite.GetId())
+	}
+	if !acquireEvalRunSlot() {
+		return nil, fmt.Errorf("too many eval runs are in progress, try again later")
+	}
+	run := &object.EvalRun{
+		Owner:       suite.Owner,
+		Name:        "run_" + strings.ReplaceAll(util.GenerateUUID(), "-", ""),
+		Suite:       suite.Name,
+		CreatedTime: time.Now().Format(time.RFC3339),
+		Trigger:     trigger,
+		State:       object.EvalRunRunning,
+		User:        user,
+		Models:      models,
+		Summary:     []object.EvalModelSummary{},
+		Results:     []object.EvalResult{},
+	}
+	if err := object.AddEvalRun(run); err != nil {
+		releaseEvalRunSlot()
+		return nil, err
+	}
+
+	go func() {
+		defer releaseEvalRunSlot()
+		ctx, cancel := context.WithTimeout(evalRunsCtx, evalRunTimeout)
+		defer cancel()
+		finished := *run
+	cases:
+		for _, modelName := range finished.Models {
+			for _, evalCase := range suite.Cases {
+				if ctx.Err() != nil {
+					finished.Error = fmt.Sprintf("the run stopped: %v", ctx.Err())
+					break cases
+				}
+				finished.Results = append(finished.Results, runEvalCase(ctx, suite, &finished, modelName, evalCase))
+			}
+		}
+		if err := object.FinishEvalRun(&finished); err != nil {
+			logs.Error("eval: failed to store run %s: %v", finished.GetId(), err)
+		}
+	}()
+	return run, nil
+}
+
+// runEvalCase asks modelName the case's prompt and scores the answer.
+func runEvalCase(ctx context.Context, suite *object.EvalSuite, run *object.EvalRun, modelName string, evalCase object.EvalCase) object.EvalResult {
+	result := object.EvalResult{Model: modelName, Case: evalCase.Name}
+	start := time.Now()
+	answer, err := queryEvalModel(ctx, run, modelName, evalCase.System, evalCase.Prompt)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.Answer = answer
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Passed, result.Reason, err = scoreEvalAnswer(ctx, suite, run, evalCase, answer)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// scoreEvalAnswer scores answer with the case's scorer, returning why a
+// failed answer failed.
+func scoreEvalAnswer(ctx context.Context, suite *object.EvalSuite, run *object.EvalRun, evalCase object.EvalCase, answer string) (bool, string, error) {
+	switch evalCase.Scorer {
+	case object.EvalScorerExact:
+		if strings.TrimSpace(answer) != strings.TrimSpace(evalCase.Expected) {
+			return false, "the answer is not the expected text", nil
+		}
+		return true, "", nil
+	case object.EvalScorerRegex:
+		matched, err := regexp.MatchString(evalCase.Expected, answer)
+		if err != nil || matched {
+			return matched, "", err
+		}
+		return false, fmt.Sprintf("the answer does not match %q", evalCase.Expected), nil
+	case object.EvalScorerJudge:
+		return judgeEvalAnswer(ctx, suite, run, evalCase, answer)
+	}
+	return false, "", fmt.Errorf("unknown scorer %q", evalCase.Scorer)
+}
+
+// queryEvalModel asks modelName the prompt the way a chat request of the
+// run's org would be served, with the zen identity prompt in front of
+// system, and bills the call to the run's user.
+func queryEvalModel(ctx context.Context, run *object.EvalRun, modelName string, system string, prompt string) (string, error) {
+	org := run.Owner
+	zenModel := resolveBrandedModel(modelName, org)
+	route, err := applyTenantResidency(zenModel, resolveModelRouteForOrg(zenModel, org), org, run.User)
+	if err != nil {
+		return "", err
+	}
+	if route == nil {
+		return "", fmt.Errorf("the model %s has no route", modelName)
+	}
+	if quota := checkTenantQuota(org); quota.Exceeded != "" {
+		return "", fmt.Errorf("the %s of %s is exhausted", quota.Exceeded, org)
+	}
+	if _, _, err = checkModelBalance(run.User, modelName, route.premium); err != nil {
+		return "", err
+	}
+
+	messages := []openai.ChatCompletionMessage{}
+	if system != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: system})
+	}
+	messages = injectZenIdentity(messages, zenIdentityPrompt(modelName, org), zenIdentityPrepend)
+	question := prompt
+	if len(messages) > 0 {
+		question = fmt.Sprintf("System: %s\n\nUser: %s", messages[0].Content, prompt)
+	}
+
+	timeouts := route.timeouts
+	if timeouts.total <= 0 {
+		timeouts.total = evalCaseTimeout
+	}
+	start := time.Now()
+	deadline := startUpstreamDeadline(withSecretCaller(ctx, run.User), timeouts)
+	defer deadline.Stop()
+	writer := &CarrierWriter{}
+	modelResult, provider, err := failoverQueryText(deadline.Context(), org, route, question, deadline.Writer(writer), nil, nil, "en",
+		func() bool { return writer.MessageString() != "" },
+	)
+	recordEvalUsage(run, modelName, provider, route.premium, modelResult, question, writer.MessageString(), start, err)
+	return writer.MessageString(), err
+}
+
+// recordEvalUsage records an eval call as usage of the run's user, billed
+// like a chat request: a call that failed after the provider generated part
+// of the answer is billed for that part.
+func recordEvalUsage(run *object.EvalRun, modelName string, provider string, premium bool, modelResult *model.ModelResult, question string, answer string, start time.Time, err error) {
+	owner, _ := util.GetOwnerAndNameFromIdNoCheck(run.User)
+	record := &usageRecord{
+		Owner:        owner,
+		User:         run.User,
+		Organization: run.Owner,
+		Model:        modelName,
+		Provider:     provider,
+		Currency:     "USD",
+		Premium:      premium,
+		Status:       "success",
+		RequestID:    util.GenerateUUID(),
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Prompt:       question,
+		Response:     answer,
+	}
+	if modelResult != nil {
+		record.PromptTokens = modelResult.PromptTokenCount
+		record.CompletionTokens = modelResult.ResponseTokenCount
+		record.TotalTokens = modelResult.TotalTokenCount
+	}
+	if err != nil {
+		record.ErrorMsg = err.Error()
+		if modelResult == nil {
+			record.Status = "error"
+		}
+	} else {
+		record.FinishReason = finishReasonOf(modelResult)
+	}
+	recordUsage(record)
+}
+
+// judgeEvalAnswer asks the judge model whether answer meets the case's
+// criteria.
+func judgeEvalAnswer(ctx context.Context, suite *object.EvalSuite, run *object.EvalRun, evalCase object.EvalCase, answer string) (bool, string, error) {
+	judgeModel := suite.JudgeModel
+	if judgeModel == "" {
+		judgeModel = conf.GetConfigString("evalJudgeModel")
+	}
+	if judgeModel == "" {
+		return false, "", fmt.Errorf("no judge model: set the suite's judgeModel or evalJudgeModel")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, evalCaseTimeout)
+	defer cancel()
+	verdict, err := queryEvalModel(ctx, run, judgeModel, "", fmt.Sprintf(evalJudgePrompt, evalCase.Expected, evalCase.Prompt, answer))
+	if err != nil {
+		return false, "", fmt.Errorf("judge %s: %v", judgeModel, err)
+	}
+	return parseJudgeVerdict(verdict)
+}
+
+// parseJudgeVerdict reads the judge model's "pass" or "fail: reason" reply.
+func parseJudgeVerdict(verdict string) (bool, string, error) {
+	verdict = strings.TrimSpace(verdict)
+	lower := strings.ToLower(verdict)
+	switch {
+	case strings.HasPrefix(lower, "pass"):
+		return true, "", nil
+	case strings.HasPrefix(lower, "fail"):
+		reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(verdict[len("fail"):]), ":"))
+		if reason == "" {
+			reason = "the judge failed the answer"
+		}
+		return false, reason, nil
+	}
+	return false, "", fmt.Errorf("unreadable verdict from the judge: %q", verdict)
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetEvalSuites
+// @Title GetEvalSuites
+// @Tag Eval API
+// @Description get the eval suites of an organization
+// @Param owner query string true "The owner (org) of the suites"
+// @Success 200 {array} object.EvalSuite The Response object
+// @router /get-eval-suites [get]
+func (c *ApiController) GetEvalSuites() {
+	suites, err := object.GetEvalSuites(c.Input().Get("owner"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(suites)
+}
+
+// GetEvalSuite
+// @Title GetEvalSuite
+// @Tag Eval API
+// @Description get an eval suite
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the suite"
+// @Success 200 {object} object.EvalSuite The Response object
+// @router /get-eval-suite [get]
+func (c *ApiController) GetEvalSuite() {
+	suite, err := object.GetEvalSuite(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(suite)
+}
+
+// AddEvalSuite
+// @Title AddEvalSuite
+// @Tag Eval API
+// @Description add an eval suite for an organization
+// @Param body body object.EvalSuite true "The details of the suite"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-eval-suite [post]
+func (c *ApiController) AddEvalSuite() {
+	var suite object.EvalSuite
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	suite.User = c.getAuditActor()
+
+	success, err := object.AddEvalSuite(&suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "eval-suite", suite.Owner, suite.GetId(), nil, &suite)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateEvalSuite
+// @Title UpdateEvalSuite
+// @Tag Eval API
+// @Description update an eval suite
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the suite"
+// @Param body body object.EvalSuite true "The details of the suite"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-eval-suite [post]
+func (c *ApiController) UpdateEvalSuite() {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var suite object.EvalSuite
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetEvalSuite(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	suite.User = c.getAuditActor()
+	success, err := object.UpdateEvalSuite(owner, name, &suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "eval-suite", owner, suite.GetId(), before, &suite)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteEvalSuite
+// @Title DeleteEvalSuite
+// @Tag Eval API
+// @Description delete an eval suite with its runs
+// @Param body body object.EvalSuite true "The details of the suite"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-eval-suite [post]
+func (c *ApiController) DeleteEvalSuite() {
+	var suite object.EvalSuite
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetEvalSuite(suite.Owner, suite.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteEvalSuite(&suite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "eval-suite", suite.Owner, suite.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
+
+// RunEvalSuite
+// @Title RunEvalSuite
+// @Tag Eval API
+// @Description start a run of an eval suite against its models, or against the models in the body
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the suite"
+// @Param body body controllers.evalRunRequest false "The models to run against"
+// @Success 200 {object} object.EvalRun The Response object
+// @router /run-eval-suite [post]
+func (c *ApiController) RunEvalSuite() {
+	var request evalRunRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		err := json.Unmarshal(c.Ctx.Input.RequestBody, &request)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	suite, err := object.GetEvalSuite(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if suite == nil {
+		c.ResponseError(fmt.Sprintf("The eval suite: %s/%s is not found", c.Input().Get("owner"), c.Input().Get("name")))
+		return
+	}
+
+	models := request.Models
+	if len(models) == 0 {
+		models = suite.Models
+	}
+	run, err := startEvalRun(suite, models, object.EvalTriggerManual, c.getAuditActor())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	c.recordAdminAudit("run", "eval-suite", suite.Owner, suite.GetId(), nil, run)
+
+	c.ResponseOk(run)
+}
+
+// GetEvalRuns
+// @Title GetEvalRuns
+// @Tag Eval API
+// @Description get the runs of an eval suite, newest first
+// @Param owner query string true "The owner (org)"
+// @Param suite query string true "The name of the suite"
+// @Success 200 {array} object.EvalRun The Response object
+// @router /get-eval-runs [get]
+func (c *ApiController) GetEvalRuns() {
+	runs, err := object.GetEvalRuns(c.Input().Get("owner"), c.Input().Get("suite"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(runs)
+}
+
+// GetEvalRun
+// @Title GetEvalRun
+// @Tag Eval API
+// @Description get an eval run with its results
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the run"
+// @Success 200 {object} object.EvalRun The Response object
+// @router /get-eval-run [get]
+func (c *ApiController) GetEvalRun() {
+	run, err := object.GetEvalRun(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(run)
+}
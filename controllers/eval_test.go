@@ -0,0 +1,115 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestParseJudgeVerdict(t *testing.T) {
+	tests := []struct {
+		verdict string
+		passed  bool
+		reason  string
+	}{
+		{"pass", true, ""},
+		{"  PASS.\n", true, ""},
+		{"fail: the answer names the wrong company", false, "the answer names the wrong company"},
+		{"Fail", false, "the judge failed the answer"},
+	}
+	for _, tt := range tests {
+		passed, reason, err := parseJudgeVerdict(tt.verdict)
+		if err != nil || passed != tt.passed || reason != tt.reason {
+			t.Errorf("parseJudgeVerdict(%q) = %v, %q, %v; want %v, %q", tt.verdict, passed, reason, err, tt.passed, tt.reason)
+		}
+	}
+	if _, _, err := parseJudgeVerdict("It depends."); err == nil {
+		t.Error("an unreadable verdict was accepted")
+	}
+}
+
+func TestEvalScheduleDue(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	suite := &object.EvalSuite{Schedule: "@every 6h", ScheduledTime: now.Add(-5 * time.Hour).Format(time.RFC3339)}
+	if evalScheduleDue(suite, now) {
+		t.Error("a suite run 5h ago on a 6h schedule is due")
+	}
+	suite.ScheduledTime = now.Add(-6 * time.Hour).Format(time.RFC3339)
+	if !evalScheduleDue(suite, now) {
+		t.Error("a suite run 6h ago on a 6h schedule is not due")
+	}
+	suite.ScheduledTime = ""
+	if !evalScheduleDue(suite, now) {
+		t.Error("a suite never run is not due")
+	}
+	suite.Schedule = "whenever"
+	if evalScheduleDue(suite, now) {
+		t.Error("a suite with an invalid schedule is due")
+	}
+}
+
+func TestScoreEvalAnswer(t *testing.T) {
+	suite := &object.EvalSuite{Owner: "acme"}
+	tests := []struct {
+		evalCase object.EvalCase
+		answer   string
+		passed   bool
+	}{
+		{object.EvalCase{Scorer: object.EvalScorerExact, Expected: "4"}, " 4\n", true},
+		{object.EvalCase{Scorer: object.EvalScorerExact, Expected: "4"}, "The answer is 4.", false},
+		{object.EvalCase{Scorer: object.EvalScorerRegex, Expected: `(?i)\bzen4\b`}, "I am Zen4 by Hanzo AI.", true},
+		{object.EvalCase{Scorer: object.EvalScorerRegex, Expected: `(?i)\bzen4\b`}, "I am GLM-5.", false},
+	}
+	for _, tt := range tests {
+		passed, reason, err := scoreEvalAnswer(context.Background(), suite, nil, tt.evalCase, tt.answer)
+		if err != nil || passed != tt.passed || (reason == "") != passed {
+			t.Errorf("scoreEvalAnswer(%+v, %q) = %v, %q, %v; want %v", tt.evalCase, tt.answer, passed, reason, err, tt.passed)
+		}
+	}
+	if _, _, err := scoreEvalAnswer(context.Background(), suite, nil, object.EvalCase{Scorer: "fuzzy"}, "4"); err == nil {
+		t.Error("an unknown scorer was accepted")
+	}
+}
+
+func TestRunEvalCaseWithoutRoute(t *testing.T) {
+	suite := &object.EvalSuite{Owner: "acme"}
+	run := &object.EvalRun{Owner: "acme", User: "acme/alice"}
+	result := runEvalCase(context.Background(), suite, run, "no-such-model", object.EvalCase{Name: "name", Prompt: "Who are you?", Scorer: object.EvalScorerExact, Expected: "Zen"})
+	if result.Model != "no-such-model" || result.Case != "name" || result.Passed || result.Error == "" {
+		t.Errorf("result for a model without a route = %+v", result)
+	}
+}
+
+func TestStartEvalRunLimits(t *testing.T) {
+	suite := &object.EvalSuite{Owner: "acme", Name: "identity"}
+	if _, err := startEvalRun(suite, []string{"zen4"}, object.EvalTriggerSchedule, ""); err == nil {
+		t.Error("a run without a user to bill was started")
+	}
+
+	for acquireEvalRunSlot() {
+	}
+	defer func() {
+		for len(evalRunSlots) > 0 {
+			releaseEvalRunSlot()
+		}
+	}()
+	if _, err := startEvalRun(suite, []string{"zen4"}, object.EvalTriggerManual, "acme/alice"); err == nil {
+		t.Error("a run was started with every run slot taken")
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Experiments. An admin A/B tests a model's parameters, such as a default
+// temperature or the identity prompt of zen4, by defining an experiment
+// with two or more arms in the experiment table and starting it. While it
+// runs, each chat request for the model is served with the arm its user
+// is bucketed into, by hash, so a user stays on one arm. The arm is
+// recorded with the request's usage and in the request log, and callers
+// rate answers through POST /v1/feedback; GET /v1/get-experiment-results
+// totals requests, errors, tokens, latency and ratings per arm, and the
+// cloud_experiment_* metrics follow the same split.
+//
+// An experiment owned by an organization applies to its requests; one
+// owned by "admin" to every organization without its own.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// experimentKey holds the request's experimentAssignment in the request
+// context data.
+const experimentKey = "experiment"
+
+// experimentAssignment is the experiment arm serving a request.
+type experimentAssignment struct {
+	experiment  string // owner/name
+	arm         *object.ExperimentArm
+	temperature *float32 // set when the arm chose the request's temperature
+}
+
+// providerTemperatureKey carries an experiment's temperature to the
+// providers a request fails over to.
+type providerTemperatureKey struct{}
+
+func withProviderTemperature(ctx context.Context, temperature float32) context.Context {
+	return context.WithValue(ctx, providerTemperatureKey{}, temperature)
+}
+
+func providerTemperature(ctx context.Context) (float32, bool) {
+	temperature, ok := ctx.Value(providerTemperatureKey{}).(float32)
+	return temperature, ok
+}
+
+// FeedbackRequest rates the answer of a chat completion.
+type FeedbackRequest struct {
+	Id     string `json:"id"`     // the completion's id, e.g. "chatcmpl-..."
+	Rating int    `json:"rating"` // 1 good, -1 bad, 0 to clear
+}
+
+// applyModelExperiment serves the request with the arm of the running
+// experiment on its model that user is assigned to, if any, and sets the
+// arm's temperature when the request has none; temperatureSet tells an
+// explicit temperature of 0 from none.
+func (c *ApiController) applyModelExperiment(org string, user string, request *openai.ChatCompletionRequest, temperatureSet bool) {
+	experiment, err := object.GetRunningExperiment(org, request.Model)
+	if err != nil || experiment == nil {
+		return
+	}
+	arm := experiment.Assign(user)
+	if arm == nil {
+		return
+	}
+
+	assignment := &experimentAssignment{experiment: experiment.GetId(), arm: arm}
+	if arm.Temperature != nil && !temperatureSet {
+		request.Temperature = *arm.Temperature
+		assignment.temperature = arm.Temperature
+	}
+	c.Ctx.Input.SetData(experimentKey, assignment)
+}
+
+// experimentIdentityPrompt returns the identity prompt of the request's
+// experiment arm, or identityPrompt when the arm keeps the model's.
+func (c *ApiController) experimentIdentityPrompt(identityPrompt string) string {
+	assignment, ok := c.Ctx.Input.GetData(experimentKey).(*experimentAssignment)
+	if !ok || assignment.arm.IdentityPrompt == "" {
+		return identityPrompt
+	}
+	return assignment.arm.IdentityPrompt
+}
+
+// experimentTemperature returns the temperature the request's experiment
+// arm set, for the paths that take it from the provider rather than the
+// request.
+func (c *ApiController) experimentTemperature() (float32, bool) {
+	assignment, ok := c.Ctx.Input.GetData(experimentKey).(*experimentAssignment)
+	if !ok || assignment.temperature == nil {
+		return 0, false
+	}
+	return *assignment.temperature, true
+}
+
+// applyExperiment records the request's experiment arm on record.
+func (c *ApiController) applyExperiment(record *usageRecord) {
+	assignment, ok := c.Ctx.Input.GetData(experimentKey).(*experimentAssignment)
+	if !ok {
+		return
+	}
+	record.Experiment = assignment.experiment
+	record.ExperimentArm = assignment.arm.Name
+}
+
+func observeExperiment(record *usageRecord) {
+	if record.Experiment == "" {
+		return
+	}
+	status := modelMetricLabels(record)[3]
+	object.ExperimentRequests.WithLabelValues(record.Experiment, record.ExperimentArm, status).Inc()
+	if record.Status != "success" {
+		return
+	}
+	object.ExperimentTokens.WithLabelValues(record.Experiment, record.ExperimentArm, "prompt").Add(float64(record.PromptTokens))
+	object.ExperimentTokens.WithLabelValues(record.Experiment, record.ExperimentArm, "completion").Add(float64(record.CompletionTokens))
+	if record.LatencyMs > 0 {
+		object.ExperimentLatency.WithLabelValues(record.Experiment, record.ExperimentArm).Observe(float64(record.LatencyMs) / 1000)
+	}
+}
+
+// Feedback
+// @Title Feedback
+// @Tag Experiment API
+// @Description rate the answer of one of the caller's chat completions
+// @Param   body    body    controllers.FeedbackRequest  true    "The completion's id and the rating"
+// @Success 200 {object} controllers.Response The Response object
+// @router /feedback [post]
+func (c *ApiController) Feedback() {
+	user, err := c.resolveApiUser()
+	if err != nil {
+		c.respondOpenAIError(http.StatusUnauthorized, "authentication_error", "invalid_api_key", err.Error())
+		return
+	}
+
+	var request FeedbackRequest
+	if err = c.decodeRequestBody(&request); err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	if request.Rating < -1 || request.Rating > 1 {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", "rating must be 1, -1 or 0")
+		return
+	}
+
+	requestId := strings.TrimPrefix(request.Id, "chatcmpl-")
+	found, err := object.SetRequestLogRating(user.Owner, user.Owner+"/"+user.Name, requestId, request.Rating)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return
+	}
+	if !found {
+		c.respondOpenAIError(http.StatusNotFound, "invalid_request_error", "completion_not_found",
+			fmt.Sprintf("The completion '%s' does not exist", request.Id))
+		return
+	}
+
+	c.ResponseOk(true)
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetExperiments
+// @Title GetExperiments
+// @Tag Experiment API
+// @Description get the experiments of an organization, or the global ones with owner "admin"
+// @Param owner query string true "The owner (org) of the experiments"
+// @Success 200 {array} object.Experiment The Response object
+// @router /get-experiments [get]
+func (c *ApiController) GetExperiments() {
+	experiments, err := object.GetExperiments(c.Input().Get("owner"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(experiments)
+}
+
+// GetExperiment
+// @Title GetExperiment
+// @Tag Experiment API
+// @Description get an experiment
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the experiment"
+// @Success 200 {object} object.Experiment The Response object
+// @router /get-experiment [get]
+func (c *ApiController) GetExperiment() {
+	experiment, err := object.GetExperiment(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(experiment)
+}
+
+// GetExperimentResults
+// @Title GetExperimentResults
+// @Tag Experiment API
+// @Description get the requests, errors, tokens, latency and ratings of each arm of an experiment
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the experiment"
+// @Success 200 {array} object.ExperimentArmResult The Response object
+// @router /get-experiment-results [get]
+func (c *ApiController) GetExperimentResults() {
+	experiment, err := object.GetExperiment(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if experiment == nil {
+		c.ResponseError(fmt.Sprintf("The experiment: %s/%s is not found", c.Input().Get("owner"), c.Input().Get("name")))
+		return
+	}
+
+	results, err := object.GetExperimentResults(experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(results)
+}
+
+// AddExperiment
+// @Title AddExperiment
+// @Tag Experiment API
+// @Description add an experiment, as a draft
+// @Param body body object.Experiment true "The details of the experiment"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-experiment [post]
+func (c *ApiController) AddExperiment() {
+	var experiment object.Experiment
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddExperiment(&experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "experiment", experiment.Owner, experiment.GetId(), nil, &experiment)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateExperiment
+// @Title UpdateExperiment
+// @Tag Experiment API
+// @Description update an experiment that has not started
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the experiment"
+// @Param body body object.Experiment true "The details of the experiment"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-experiment [post]
+func (c *ApiController) UpdateExperiment() {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var experiment object.Experiment
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetExperiment(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateExperiment(owner, name, &experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "experiment", owner, experiment.GetId(), before, &experiment)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteExperiment
+// @Title DeleteExperiment
+// @Tag Experiment API
+// @Description delete an experiment
+// @Param body body object.Experiment true "The details of the experiment"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-experiment [post]
+func (c *ApiController) DeleteExperiment() {
+	var experiment object.Experiment
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetExperiment(experiment.Owner, experiment.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteExperiment(&experiment)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "experiment", experiment.Owner, experiment.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
+
+// StartExperiment
+// @Title StartExperiment
+// @Tag Experiment API
+// @Description start a draft experiment
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the experiment"
+// @Success 200 {object} controllers.Response The Response object
+// @router /start-experiment [post]
+func (c *ApiController) StartExperiment() {
+	c.setExperimentState("start", object.StartExperiment)
+}
+
+// StopExperiment
+// @Title StopExperiment
+// @Tag Experiment API
+// @Description stop a running experiment; its results are kept
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the experiment"
+// @Success 200 {object} controllers.Response The Response object
+// @router /stop-experiment [post]
+func (c *ApiController) StopExperiment() {
+	c.setExperimentState("stop", object.StopExperiment)
+}
+
+func (c *ApiController) setExperimentState(action string, set func(owner string, name string) (bool, error)) {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	before, err := object.GetExperiment(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := set(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		after, err := object.GetExperiment(owner, name)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		c.recordAdminAudit(action, "experiment", owner, fmt.Sprintf("%s/%s", owner, name), before, after)
+	}
+
+	c.ResponseOk(success)
+}
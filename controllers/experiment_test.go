@@ -0,0 +1,58 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProviderTemperature(t *testing.T) {
+	if _, ok := providerTemperature(context.Background()); ok {
+		t.Error("a context without an experiment carries a temperature")
+	}
+	temperature, ok := providerTemperature(withProviderTemperature(context.Background(), 0.3))
+	if !ok || temperature != 0.3 {
+		t.Errorf("providerTemperature = %v, %v; want 0.3, true", temperature, ok)
+	}
+}
+
+func TestObserveExperiment(t *testing.T) {
+	record := &usageRecord{
+		Model:            "zen4",
+		Provider:         "fireworks",
+		Status:           "success",
+		PromptTokens:     100,
+		CompletionTokens: 20,
+		LatencyMs:        500,
+		Experiment:       "admin/observe-test",
+		ExperimentArm:    "cool",
+	}
+	observeExperiment(record)
+	observeExperiment(&usageRecord{Model: "zen4", Status: "success", PromptTokens: 100})
+
+	if got := testutil.ToFloat64(object.ExperimentRequests.WithLabelValues("admin/observe-test", "cool", "success")); got != 1 {
+		t.Errorf("requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(object.ExperimentTokens.WithLabelValues("admin/observe-test", "cool", "prompt")); got != 100 {
+		t.Errorf("prompt tokens = %v, want 100", got)
+	}
+	if got := testutil.ToFloat64(object.ExperimentTokens.WithLabelValues("admin/observe-test", "cool", "completion")); got != 20 {
+		t.Errorf("completion tokens = %v, want 20", got)
+	}
+}
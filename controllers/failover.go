@@ -15,9 +15,14 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/model"
@@ -52,7 +57,15 @@ func isRetryableError(err error) bool {
 	return false
 }
 
+// isUpstreamAuthError returns true if the upstream answered 401 or 403: it
+// rejected our credentials, which usually means the key was rotated.
+func isUpstreamAuthError(err error) bool {
+	status, _ := upstreamErrorStatus(err)
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
 // failoverQueryText tries the primary provider, then each fallback in order.
+// Weighted routes first move the upstream picked by weightedRoute to the front.
 // It returns the first successful result. If all providers fail, it returns
 // the last error. The providerName output indicates which provider succeeded.
 //
@@ -61,7 +74,12 @@ func isRetryableError(err error) bool {
 // response data. For non-streaming, the writer buffers internally and a fresh
 // writer is created per attempt by the caller. For streaming, failover is
 // only possible if no bytes have been flushed to the client yet.
+//
+// Canceling ctx (the client disconnected, or a route timeout expired) stops
+// the upstream call; the partial result is returned with the error.
 func failoverQueryText(
+	ctx context.Context,
+	org string,
 	route *modelRoute,
 	question string,
 	writer io.Writer,
@@ -70,12 +88,23 @@ func failoverQueryText(
 	lang string,
 	writerHasData func() bool,
 ) (*model.ModelResult, string, error) {
+	route = weightedRoute(route, rand.Intn, func(providerName string) bool {
+		provider, err := object.GetModelProviderForOrg(org, providerName, getSecretCaller(ctx))
+		return err == nil && provider != nil && providerHealth.isHealthy(providerName, time.Now())
+	})
+
 	// Try primary provider
-	result, err := callProvider(route.providerName, route.upstreamModel, question, writer, history, knowledge, lang)
+	result, err := callProviderRefreshingSecrets(ctx, org, route.providerName, route.upstreamModel, question, writer, history, knowledge, lang, writerHasData)
 	if err == nil {
 		return result, route.providerName, nil
 	}
 
+	// The client went away or the route's deadline passed: nothing to fail
+	// over for, and the partial result is kept for billing.
+	if ctx.Err() != nil {
+		return result, route.providerName, err
+	}
+
 	// If the writer already sent data to the client (streaming), we cannot
 	// retry — the response is partially committed.
 	if writerHasData != nil && writerHasData() {
@@ -103,7 +132,7 @@ func failoverQueryText(
 		logs.Info("failover: attempting fallback[%d] provider=%s upstream=%s",
 			i, fb.providerName, fb.upstreamModel)
 
-		result, fbErr := callProvider(fb.providerName, fb.upstreamModel, question, writer, history, knowledge, lang)
+		result, fbErr := callProviderRefreshingSecrets(ctx, org, fb.providerName, fb.upstreamModel, question, writer, history, knowledge, lang, writerHasData)
 		if fbErr == nil {
 			logs.Info("failover: fallback[%d] provider=%s succeeded", i, fb.providerName)
 			return result, fb.providerName, nil
@@ -126,10 +155,72 @@ func failoverQueryText(
 	return nil, route.providerName, lastErr
 }
 
-// callProvider creates a model provider from the DB-stored provider entry and
-// calls QueryText. This is the same flow as the existing code in the OpenAI
-// and Anthropic handlers, extracted for reuse by the failover loop.
+// providerSecretRefreshInterval limits credential re-resolution to once per
+// provider per interval, so a revoked key does not turn every request into
+// two upstream calls plus a KMS fetch.
+const providerSecretRefreshInterval = time.Minute
+
+var (
+	providerSecretRefreshMu sync.Mutex
+	providerSecretRefreshAt = make(map[string]time.Time)
+)
+
+func allowProviderSecretRefresh(providerName string) bool {
+	providerSecretRefreshMu.Lock()
+	defer providerSecretRefreshMu.Unlock()
+
+	if last, ok := providerSecretRefreshAt[providerName]; ok && time.Since(last) < providerSecretRefreshInterval {
+		return false
+	}
+	providerSecretRefreshAt[providerName] = time.Now()
+	return true
+}
+
+// callProviderRefreshingSecrets calls the provider and, if the upstream rejects
+// its credentials before any data was written, drops the cached provider and
+// KMS secrets and retries once with freshly resolved ones. This lets rotated
+// keys recover without a restart.
+func callProviderRefreshingSecrets(
+	ctx context.Context,
+	org string,
+	providerName string,
+	upstreamModel string,
+	question string,
+	writer io.Writer,
+	history []*model.RawMessage,
+	knowledge []*model.RawMessage,
+	lang string,
+	writerHasData func() bool,
+) (*model.ModelResult, error) {
+	result, err := callProvider(ctx, org, providerName, upstreamModel, question, writer, history, knowledge, lang)
+	if !isUpstreamAuthError(err) || (writerHasData != nil && writerHasData()) {
+		return result, err
+	}
+
+	if !allowProviderSecretRefresh(providerName) {
+		return result, err
+	}
+
+	logs.Warn("failover: provider %s rejected credentials (%v), re-resolving secrets", providerName, err)
+	// Drop both rows the lookup may have used: the org's and the admin one.
+	invErr := object.InvalidateModelProviderByName(providerName)
+	if invErr == nil && org != "" {
+		invErr = object.InvalidateModelProviderForOrg(org, providerName)
+	}
+	if invErr != nil {
+		logs.Warn("failover: failed to invalidate provider %s: %v", providerName, invErr)
+		return result, err
+	}
+	return callProvider(ctx, org, providerName, upstreamModel, question, writer, history, knowledge, lang)
+}
+
+// callProvider creates a model provider from the DB-stored provider entry,
+// preferring one owned by org, and calls QueryText. This is the same flow as
+// the existing code in the OpenAI and Anthropic handlers, extracted for reuse
+// by the failover loop.
 func callProvider(
+	ctx context.Context,
+	org string,
 	providerName string,
 	upstreamModel string,
 	question string,
@@ -138,7 +229,24 @@ func callProvider(
 	knowledge []*model.RawMessage,
 	lang string,
 ) (*model.ModelResult, error) {
-	provider, err := object.GetModelProviderByName(providerName)
+	modelProvider, err := getUpstreamModelProvider(ctx, org, providerName, upstreamModel, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := modelProvider.QueryText(ctx, question, writer, history, "", knowledge, nil, lang)
+	err = upstreamCallError(ctx, err)
+	if !clientGone(ctx) {
+		providerHealth.record(providerName, err)
+	}
+	return result, err
+}
+
+// getUpstreamModelProvider returns the model provider serving upstreamModel
+// from the DB-stored provider entry, preferring one owned by org, with the
+// temperature of the request's experiment arm if ctx carries one.
+func getUpstreamModelProvider(ctx context.Context, org string, providerName string, upstreamModel string, lang string) (model.ModelProvider, error) {
+	provider, err := object.GetModelProviderForOrg(org, providerName, getSecretCaller(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -147,11 +255,22 @@ func callProvider(
 	}
 
 	provider.SubType = upstreamModel
-
-	modelProvider, err := provider.GetModelProvider(lang)
-	if err != nil {
-		return nil, err
+	if temperature, ok := providerTemperature(ctx); ok {
+		provider.Temperature = temperature
 	}
+	return provider.GetModelProvider(lang)
+}
+
+// secretCallerKey carries the caller of a request ("owner/name", or
+// "job:<name>" for a background job) to the provider lookups it makes, for
+// the secret audit log.
+type secretCallerKey struct{}
+
+func withSecretCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, secretCallerKey{}, caller)
+}
 
-	return modelProvider.QueryText(question, writer, history, "", knowledge, nil, lang)
+func getSecretCaller(ctx context.Context) string {
+	caller, _ := ctx.Value(secretCallerKey{}).(string)
+	return caller
 }
@@ -15,9 +15,14 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/hanzoai/cloud/model"
+	"github.com/sashabaranov/go-openai"
 )
 
 func TestIsRetryableError(t *testing.T) {
@@ -95,10 +100,9 @@ models:
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
-		t.Fatalf("loadFromFile failed: %v", err)
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatalf("loadFromSource failed: %v", err)
 	}
 
 	// claude-opus-4-6 should have 2 fallbacks
@@ -136,3 +140,49 @@ func writeYAML(t *testing.T, path string, content string) error {
 	t.Helper()
 	return os.WriteFile(path, []byte(content), 0o644)
 }
+
+func TestIsUpstreamAuthError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&openai.APIError{HTTPStatusCode: 401, Message: "Incorrect API key provided"}, true},
+		{fmt.Errorf("stream: %w", &openai.RequestError{HTTPStatusCode: 403}), true},
+		{&anthropic.Error{StatusCode: 401}, true},
+		{&openai.APIError{HTTPStatusCode: 429}, false},
+		{fmt.Errorf("HTTP 401 Unauthorized"), false}, // no status to go by
+		{fmt.Errorf("502 bad gateway"), false},
+	}
+	for _, tc := range cases {
+		if got := isUpstreamAuthError(tc.err); got != tc.want {
+			t.Errorf("isUpstreamAuthError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestAllowProviderSecretRefresh(t *testing.T) {
+	if !allowProviderSecretRefresh("test-refresh-provider") {
+		t.Fatal("first refresh was not allowed")
+	}
+	if allowProviderSecretRefresh("test-refresh-provider") {
+		t.Error("second refresh within the interval was allowed")
+	}
+}
+
+func TestClientDisconnected(t *testing.T) {
+	partial := &model.ModelResult{ResponseTokenCount: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if clientDisconnected(ctx, partial, fmt.Errorf("503 service unavailable")) {
+		t.Error("an upstream error on a live request was taken for a disconnect")
+	}
+
+	cancel()
+	if !clientDisconnected(ctx, partial, context.Canceled) {
+		t.Error("a canceled call with a partial result was not taken for a disconnect")
+	}
+	if clientDisconnected(ctx, nil, context.Canceled) {
+		t.Error("a canceled call without a result was taken for a disconnect")
+	}
+}
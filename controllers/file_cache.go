@@ -16,6 +16,8 @@ package controllers
 
 import (
 	"fmt"
+	"io"
+	"mime/multipart"
 	"strings"
 
 	"github.com/beego/beego/logs"
@@ -60,6 +62,20 @@ func addFileToCache(key string, filename string, bs []byte) error {
 	return err
 }
 
+// addUploadedFileToCache caches an uploaded file that was already streamed to
+// storage, reading it again from the start.
+func addUploadedFileToCache(key string, filename string, file multipart.File) error {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	bs, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	return addFileToCache(key, filename, bs)
+}
+
 // ActivateFile
 // @Title ActivateFile
 // @Tag File API
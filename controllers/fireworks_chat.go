@@ -0,0 +1,363 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/proxy"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
+)
+
+// nativeChatTimeout bounds a native Fireworks call whose route sets no total
+// timeout.
+const nativeChatTimeout = 120 * time.Second
+
+// needsNativeChat reports whether request uses features the plain text
+// QueryText pipeline drops: tools, images, JSON mode or stop sequences.
+func needsNativeChat(request *openai.ChatCompletionRequest) bool {
+	if len(request.Tools) > 0 || request.ToolChoice != nil || request.ResponseFormat != nil || len(request.Stop) > 0 {
+		return true
+	}
+	for _, msg := range request.Messages {
+		for _, part := range msg.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeImageURL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getNativeChatFallback returns the native client of a fallback of the
+// route, or nil when the fallback is not a Fireworks provider and so cannot
+// serve the request without dropping its tools, images or JSON mode.
+func getNativeChatFallback(ctx context.Context, org string, fallback modelRouteFallback, lang string) (*model.FireworksModelProvider, error) {
+	modelProvider, err := getUpstreamModelProvider(ctx, org, fallback.providerName, fallback.upstreamModel, lang)
+	if err != nil {
+		return nil, err
+	}
+	fireworks, _ := modelProvider.(*model.FireworksModelProvider)
+	return fireworks, nil
+}
+
+// fireworksChatCompletion serves request through the native Fireworks client,
+// keeping tools, images, JSON mode and stop sequences, and passes the usage
+// Fireworks reports through to the client and to billing. recordModel is
+// the model usage is recorded and billed as, responseModel the one the
+// client sees. A call that fails before answering fails over to the
+// route's Fireworks fallbacks. The call is bounded by the route's timeouts,
+// its total by nativeChatTimeout when the route sets none. With
+// scanIdentity, the answer is scanned for an identity leak like the
+// pipeline's, and a leaking non-streamed one retried.
+func (c *ApiController) fireworksChatCompletion(
+	provider *object.Provider,
+	request *openai.ChatCompletionRequest,
+	recordModel string,
+	responseModel string,
+	requestStartTime time.Time,
+	authUser *iamsdk.User,
+	isPremium bool,
+	requestId string,
+	org string,
+	route *modelRoute,
+	scanIdentity bool,
+) {
+	modelProvider, err := provider.GetModelProvider(c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(fmt.Sprintf("Failed to get model provider: %s", err.Error()))
+		return
+	}
+	fireworks, ok := modelProvider.(*model.FireworksModelProvider)
+	if !ok {
+		c.ResponseError(fmt.Sprintf("Provider %s is not a Fireworks provider", provider.Name))
+		return
+	}
+	timeouts := getRouteTimeouts(route)
+
+	record := &usageRecord{
+		Model:     recordModel,
+		Provider:  provider.Name,
+		Premium:   isPremium,
+		Stream:    request.Stream,
+		ClientIP:  c.getClientIp(),
+		RequestID: requestId,
+	}
+	if authUser != nil {
+		record.Owner = authUser.Owner
+		record.User = authUser.Owner + "/" + authUser.Name
+	}
+	if timeouts.total == 0 {
+		timeouts.total = nativeChatTimeout
+	}
+	if !request.Stream {
+		// A non-streamed completion produces no output before it is done.
+		timeouts.firstToken = 0
+	}
+	deadline := startUpstreamDeadline(c.Ctx.Request.Context(), timeouts)
+	defer deadline.Stop()
+	ctx, limits := proxy.WithRateLimitRecorder(deadline.Context())
+
+	// next moves to the route's next Fireworks fallback after err, and
+	// reports false when there is none to try.
+	fallbacks := []modelRouteFallback{}
+	if route != nil {
+		fallbacks = route.fallbacks
+	}
+	next := func(err error) bool {
+		if deadline.Err() != nil || ctx.Err() != nil || !isRetryableError(err) {
+			return false
+		}
+		for len(fallbacks) > 0 {
+			fallback := fallbacks[0]
+			fallbacks = fallbacks[1:]
+			native, fbErr := getNativeChatFallback(ctx, org, fallback, c.GetAcceptLanguage())
+			if fbErr != nil || native == nil {
+				logs.Warn("failover: skipping fallback provider=%s for a native chat: %v", fallback.providerName, fbErr)
+				continue
+			}
+			logs.Warn("failover: provider %s failed (%v), trying fallback provider=%s", record.Provider, err, fallback.providerName)
+			fireworks = native
+			record.Provider = fallback.providerName
+			return true
+		}
+		return false
+	}
+
+	fail := func(err error) {
+		if timeoutErr := deadline.Err(); timeoutErr != nil {
+			err = timeoutErr
+		}
+		errorClass := classifyUpstreamError(err)
+		if authUser != nil {
+			record.Status = "error"
+			record.ErrorMsg = err.Error()
+			record.ErrorClass = errorClass
+			record.LatencyMs = time.Since(requestStartTime).Milliseconds()
+			c.applyExperiment(record)
+			recordUsage(record)
+			recordTrace(record, requestStartTime)
+		}
+		c.setUpstreamRetryAfter(errorClass, record.Provider, limits)
+		c.respondOpenAIUpstreamError(errorClass, fmt.Sprintf("Upstream request failed: %s", err.Error()))
+	}
+
+	var usage *openai.Usage
+	var answer strings.Builder
+	var streamErr error
+	answered := true
+	if !request.Stream {
+		resp, err := fireworks.CreateChatCompletion(ctx, *request)
+		for err != nil {
+			providerHealth.record(record.Provider, err)
+			if !next(err) {
+				fail(err)
+				return
+			}
+			resp, err = fireworks.CreateChatCompletion(ctx, *request)
+		}
+		if scanIdentity && len(resp.Choices) > 0 {
+			resp = checkNativeIdentityLeak(ctx, fireworks, request, recordModel, resp)
+		}
+		resp.Model = responseModel
+		body, err := json.Marshal(resp)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		usage = &resp.Usage
+		if len(resp.Choices) > 0 {
+			record.FinishReason = string(resp.Choices[0].FinishReason)
+			answer.WriteString(resp.Choices[0].Message.Content)
+		}
+		c.Ctx.Output.Header("Content-Type", "application/json")
+		c.Ctx.Output.Body(body)
+	} else {
+		stream, err := fireworks.CreateChatCompletionStream(ctx, *request)
+		for err != nil {
+			providerHealth.record(record.Provider, err)
+			if !next(err) {
+				fail(err)
+				return
+			}
+			stream, err = fireworks.CreateChatCompletionStream(ctx, *request)
+		}
+		defer stream.Close()
+
+		c.Ctx.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
+		c.Ctx.ResponseWriter.Header().Set("Cache-Control", "no-cache")
+		c.Ctx.ResponseWriter.Header().Set("Connection", "keep-alive")
+		// Keep the stream alive while the model works on its first chunk;
+		// see sse_keepalive.go.
+		keepalive := startSSEKeepalive(c.Ctx.ResponseWriter, getSSEKeepaliveInterval())
+		defer keepalive.Stop()
+		for {
+			chunk, err := stream.Recv()
+			keepalive.Stop()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// The response is committed; end the stream with the error.
+				if timeoutErr := deadline.Err(); timeoutErr != nil {
+					err = timeoutErr
+				}
+				streamErr = err
+				if !clientGone(ctx) {
+					providerHealth.record(record.Provider, err)
+				}
+				data, _ := json.Marshal(map[string]interface{}{"error": map[string]string{"message": err.Error(), "type": "upstream_error"}})
+				_, _ = fmt.Fprintf(c.Ctx.ResponseWriter, "data: %s\n\n", data)
+				c.Ctx.ResponseWriter.Flush()
+				answered = false
+				break
+			}
+			deadline.markToken()
+			if record.TtftMs == 0 {
+				record.TtftMs = time.Since(requestStartTime).Milliseconds()
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			if len(chunk.Choices) > 0 {
+				answer.WriteString(chunk.Choices[0].Delta.Content)
+				if chunk.Choices[0].FinishReason != "" {
+					record.FinishReason = string(chunk.Choices[0].FinishReason)
+				}
+			}
+			chunk.Model = responseModel
+			data, err := json.Marshal(chunk)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(c.Ctx.ResponseWriter, "data: %s\n\n", data)
+			c.Ctx.ResponseWriter.Flush()
+		}
+		_, _ = fmt.Fprint(c.Ctx.ResponseWriter, "data: [DONE]\n\n")
+		c.Ctx.ResponseWriter.Flush()
+		if scanIdentity && answered {
+			// Already sent: only counted.
+			checkIdentityLeak(recordModel, answer.String(), nil)
+		}
+	}
+	c.EnableRender = false
+	if streamErr == nil {
+		providerHealth.record(record.Provider, nil)
+	}
+	if answered {
+		c.saveConversationTurn(answer.String())
+	}
+
+	if authUser != nil {
+		record.Organization = authUser.Owner
+		record.Currency = "USD"
+		record.Status = "success"
+		record.LatencyMs = time.Since(requestStartTime).Milliseconds()
+		if streamErr != nil {
+			// The part streamed before the stream ended is billed as the
+			// pipeline bills it; Fireworks reports usage only at the end.
+			if usage == nil {
+				usage = streamedUsage(request, provider.SubType, answer.String())
+			}
+			if clientGone(ctx) {
+				record.ErrorMsg = "client disconnected: " + streamErr.Error()
+			} else {
+				record.Status = "error"
+				record.ErrorMsg = streamErr.Error()
+				record.ErrorClass = classifyUpstreamError(streamErr)
+			}
+		}
+		if usage != nil {
+			record.PromptTokens = usage.PromptTokens
+			record.CompletionTokens = usage.CompletionTokens
+			record.TotalTokens = usage.TotalTokens
+			if usage.PromptTokensDetails != nil {
+				record.CacheReadTokens = usage.PromptTokensDetails.CachedTokens
+			}
+		}
+		c.applyPromptEstimate(record)
+		c.applyExperiment(record)
+		recordUsage(record)
+		recordTrace(record, requestStartTime)
+	}
+}
+
+// streamedUsage counts the usage of a native stream that ended before
+// Fireworks reported it: the prompt of request and the answer streamed so
+// far, with the tokenizer of upstreamModel or the closest OpenAI one.
+func streamedUsage(request *openai.ChatCompletionRequest, upstreamModel string, answer string) *openai.Usage {
+	usage := &openai.Usage{}
+	if tokens, err := model.OpenaiNumTokensFromMessages(request.Messages, upstreamModel); err == nil {
+		usage.PromptTokens = tokens
+	}
+	if tokens, err := model.GetTokenSize(upstreamModel, answer); err == nil {
+		usage.CompletionTokens = tokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// checkNativeIdentityLeak scans a native answer for an identity leak and,
+// when leaking answers are retried, asks again with the reminder ahead of
+// the messages. It returns the response to serve, with the usage of both
+// calls when one was retried.
+func checkNativeIdentityLeak(ctx context.Context, fireworks *model.FireworksModelProvider, request *openai.ChatCompletionRequest, modelName string, resp openai.ChatCompletionResponse) openai.ChatCompletionResponse {
+	var retry identityLeakRetry
+	var retried openai.ChatCompletionResponse
+	if retriesIdentityLeaks() {
+		retry = func(reminder string) (string, *model.ModelResult, error) {
+			reminded := *request
+			reminded.Messages = append([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: reminder}}, request.Messages...)
+			var err error
+			retried, err = fireworks.CreateChatCompletion(ctx, reminded)
+			if err != nil {
+				return "", nil, err
+			}
+			if len(retried.Choices) == 0 {
+				return "", nil, fmt.Errorf("the retry returned no choices")
+			}
+			result := &model.ModelResult{
+				PromptTokenCount:   retried.Usage.PromptTokens,
+				ResponseTokenCount: retried.Usage.CompletionTokens,
+				TotalTokenCount:    retried.Usage.TotalTokens,
+			}
+			return retried.Choices[0].Message.Content, result, nil
+		}
+	}
+
+	original := resp.Choices[0].Message.Content
+	answer, result := checkIdentityLeak(modelName, original, retry)
+	if result == nil {
+		return resp
+	}
+	usage := resp.Usage
+	if answer != original {
+		resp = retried
+	}
+	resp.Usage.PromptTokens = usage.PromptTokens + retried.Usage.PromptTokens
+	resp.Usage.CompletionTokens = usage.CompletionTokens + retried.Usage.CompletionTokens
+	resp.Usage.TotalTokens = usage.TotalTokens + retried.Usage.TotalTokens
+	return resp
+}
@@ -0,0 +1,122 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestNeedsNativeChat(t *testing.T) {
+	text := []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}
+	image := []openai.ChatCompletionMessage{{Role: "user", MultiContent: []openai.ChatMessagePart{
+		{Type: openai.ChatMessagePartTypeText, Text: "what is this?"},
+		{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "https://example.com/a.png"}},
+	}}}
+
+	tests := []struct {
+		name    string
+		request openai.ChatCompletionRequest
+		want    bool
+	}{
+		{"plain text", openai.ChatCompletionRequest{Messages: text}, false},
+		{"tools", openai.ChatCompletionRequest{Messages: text, Tools: []openai.Tool{{Type: openai.ToolTypeFunction}}}, true},
+		{"image", openai.ChatCompletionRequest{Messages: image}, true},
+		{"json mode", openai.ChatCompletionRequest{Messages: text, ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}}, true},
+		{"stop", openai.ChatCompletionRequest{Messages: text, Stop: []string{"\n\n"}}, true},
+	}
+	for _, tt := range tests {
+		if got := needsNativeChat(&tt.request); got != tt.want {
+			t.Errorf("%s: needsNativeChat = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// rewriteTransport sends every request to target, whatever its URL.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCheckNativeIdentityLeak(t *testing.T) {
+	cfg, err := buildModelConfig(&ModelConfigFile{Version: 1, Features: FeatureFlags{IdentityLeak: IdentityLeakDefs{Retry: true}}})
+	if err != nil {
+		t.Fatalf("buildModelConfig() error = %v", err)
+	}
+	previous := globalModelConfig
+	globalModelConfig = cfg
+	t.Cleanup(func() { globalModelConfig = previous })
+
+	var reminded bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request openai.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		reminded = request.Messages[0].Content == identityLeakReminder
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: "I am Zen4."}}},
+			Usage:   openai.Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16},
+		})
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	fireworks, _ := model.NewFireworksProvider("accounts/fireworks/models/glm-5", "key", 0, 0, 0, 0)
+	fireworks.SetHttpClient(&http.Client{Transport: rewriteTransport{target: target}})
+
+	request := &openai.ChatCompletionRequest{Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "who are you?"}}}
+	leaking := openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: "I am GLM-5."}}},
+		Usage:   openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+	resp := checkNativeIdentityLeak(context.Background(), fireworks, request, "zen4", leaking)
+	if !reminded {
+		t.Error("the retry was not sent with the reminder")
+	}
+	if got := resp.Choices[0].Message.Content; got != "I am Zen4." {
+		t.Errorf("served %q, want the retried answer", got)
+	}
+	if resp.Usage != (openai.Usage{PromptTokens: 22, CompletionTokens: 9, TotalTokens: 31}) {
+		t.Errorf("usage = %+v, want both calls", resp.Usage)
+	}
+}
+
+func TestStreamedUsage(t *testing.T) {
+	request := &openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Tell me a story"}},
+	}
+	usage := streamedUsage(request, "accounts/fireworks/models/gpt-oss-120b", "Once upon a time")
+	if usage.PromptTokens == 0 || usage.CompletionTokens == 0 {
+		t.Fatalf("usage = %+v, want the prompt and the partial answer counted", usage)
+	}
+	if usage.TotalTokens != usage.PromptTokens+usage.CompletionTokens {
+		t.Errorf("TotalTokens = %d, want %d", usage.TotalTokens, usage.PromptTokens+usage.CompletionTokens)
+	}
+
+	empty := streamedUsage(request, "accounts/fireworks/models/kimi-k2", "")
+	if empty.PromptTokens == 0 || empty.CompletionTokens != 0 {
+		t.Errorf("usage before any answer = %+v, want the prompt only", empty)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+// Gateway tokens are short-lived HS256 JWTs minted by this service and
+// validated locally, so authenticated requests need no IAM round trip.
+// They carry the "gt-" prefix to keep them apart from IAM-issued JWTs.
+const (
+	gatewayTokenPrefix = "gt-"
+	gatewayTokenIssuer = "hanzo-cloud"
+
+	// defaultGatewayTokenTTL applies when gatewayTokenTTLMinutes is unset.
+	defaultGatewayTokenTTL = 15 * time.Minute
+
+	// gatewayScopeChat grants access to the chat completion endpoints.
+	gatewayScopeChat = "chat"
+)
+
+// gatewayClaims are the claims embedded in a gateway token.
+type gatewayClaims struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	Org   string `json:"org"`
+	Scope string `json:"scope"`
+
+	// Models, when set, restricts the token to these request models.
+	Models []string `json:"models,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// hasScope reports whether the space-separated scope claim includes scope.
+func (c *gatewayClaims) hasScope(scope string) bool {
+	return scopeIncludes(c.Scope, scope)
+}
+
+// scopeIncludes reports whether the space-separated scopes include scope,
+// "*" including every scope.
+func scopeIncludes(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// capGatewayScope returns the requested scopes, each of which must be held
+// by the issuer: granted are the scopes IAM granted it, the chat scope when
+// IAM granted none.
+func capGatewayScope(requested string, granted string) (string, error) {
+	if strings.TrimSpace(granted) == "" {
+		granted = gatewayScopeChat
+	}
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !scopeIncludes(granted, scope) {
+			return "", fmt.Errorf("the scope %q is not granted to the client", scope)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// allowsModel reports whether the token may be used for model.
+func (c *gatewayClaims) allowsModel(model string) bool {
+	if len(c.Models) == 0 {
+		return true
+	}
+	for _, m := range c.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// user returns the identity billed for requests made with the token.
+func (c *gatewayClaims) user() *iamsdk.User {
+	return &iamsdk.User{Owner: c.Owner, Name: c.Name}
+}
+
+// gatewayTokenSecretRetry is how long a failed resolution of the gateway
+// token secret is remembered before KMS and the config are asked again.
+const gatewayTokenSecretRetry = 30 * time.Second
+
+var (
+	gatewayTokenSecretMu      sync.Mutex
+	gatewayTokenSecret        []byte
+	gatewayTokenSecretRetryAt time.Time
+)
+
+// errGatewayTokenSecret is returned when no HMAC key is configured: every
+// replica must sign with the same key, so gateway tokens are disabled
+// rather than signed with one of their own.
+var errGatewayTokenSecret = fmt.Errorf("gateway tokens are disabled: GATEWAY_TOKEN_SECRET is not configured")
+
+// getGatewayTokenSecret returns the HMAC key for gateway tokens, nil
+// without one. Only a resolved key is kept: a failed resolution is retried
+// after gatewayTokenSecretRetry, so a transient KMS error does not disable
+// gateway tokens until restart.
+func getGatewayTokenSecret() []byte {
+	gatewayTokenSecretMu.Lock()
+	defer gatewayTokenSecretMu.Unlock()
+
+	if gatewayTokenSecret != nil || time.Now().Before(gatewayTokenSecretRetryAt) {
+		return gatewayTokenSecret
+	}
+	secret := resolveGatewayTokenSecret()
+	if secret == "" {
+		logs.Warn("gateway token: no GATEWAY_TOKEN_SECRET configured, gateway tokens are disabled")
+		gatewayTokenSecretRetryAt = time.Now().Add(gatewayTokenSecretRetry)
+		return nil
+	}
+	gatewayTokenSecret = []byte(secret)
+	return gatewayTokenSecret
+}
+
+// resolveGatewayTokenSecret reads the HMAC key from KMS
+// (GATEWAY_TOKEN_SECRET) or the gatewayTokenSecret config, "" without
+// either; swapped out by tests.
+var resolveGatewayTokenSecret = func() string {
+	if v, err := object.GetKMSSecret("GATEWAY_TOKEN_SECRET"); err == nil && v != "" {
+		return strings.TrimSpace(v)
+	}
+	v, err := object.ResolveSecretRef(conf.GetConfigString("gatewayTokenSecret"))
+	if err != nil {
+		logs.Error("gateway token: failed to resolve gatewayTokenSecret: %v", err)
+	}
+	return v
+}
+
+func getGatewayTokenTTL() time.Duration {
+	if minutes := conf.GetConfigInt("gatewayTokenTTLMinutes"); minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultGatewayTokenTTL
+}
+
+func isGatewayToken(token string) bool {
+	return strings.HasPrefix(token, gatewayTokenPrefix)
+}
+
+// mintGatewayToken signs claims with a fresh ID and the given lifetime.
+func mintGatewayToken(claims *gatewayClaims, ttl time.Duration) (string, error) {
+	secret := getGatewayTokenSecret()
+	if secret == nil {
+		return "", errGatewayTokenSecret
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    gatewayTokenIssuer,
+		Subject:   claims.Owner + "/" + claims.Name,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", err
+	}
+	return gatewayTokenPrefix + signed, nil
+}
+
+// parseGatewayToken verifies a gt- token locally and returns its claims.
+func parseGatewayToken(token string) (*gatewayClaims, error) {
+	claims := &gatewayClaims{}
+	t, err := jwt.ParseWithClaims(strings.TrimPrefix(token, gatewayTokenPrefix), claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		secret := getGatewayTokenSecret()
+		if secret == nil {
+			return nil, errGatewayTokenSecret
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway token: %s", err.Error())
+	}
+	if !t.Valid || claims.Issuer != gatewayTokenIssuer || claims.Owner == "" || claims.Name == "" {
+		return nil, fmt.Errorf("invalid gateway token")
+	}
+	if gatewayTokenRevocations.isRevoked(claims.ID) {
+		return nil, fmt.Errorf("gateway token has been revoked")
+	}
+	return claims, nil
+}
+
+// parseScopedGatewayToken verifies a gt- token and checks it grants scope.
+func parseScopedGatewayToken(token string, scope string) (*gatewayClaims, error) {
+	claims, err := parseGatewayToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.hasScope(scope) {
+		return nil, fmt.Errorf("gateway token is missing the %q scope", scope)
+	}
+	return claims, nil
+}
+
+// exchangeClientCredentials verifies clientId/clientSecret with IAM's OAuth2
+// token endpoint, asking for scope, and returns the identity of the service
+// account with the scopes IAM granted it.
+func exchangeClientCredentials(clientId string, clientSecret string, scope string) (*iamsdk.User, string, error) {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return nil, "", fmt.Errorf("iamEndpoint is not configured")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientId)
+	form.Set("client_secret", clientSecret)
+	form.Set("scope", scope)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(iamEndpoint+"/api/login/oauth/access_token", form)
+	if err != nil {
+		return nil, "", fmt.Errorf("IAM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		Scope            string `json:"scope"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse IAM response: %w", err)
+	}
+	if result.Error != "" || result.AccessToken == "" {
+		return nil, "", fmt.Errorf("IAM rejected client credentials: %s %s", result.Error, result.ErrorDescription)
+	}
+
+	// The token comes straight from IAM over an authenticated channel, so
+	// only its identity claims are needed here.
+	claims := &iamsdk.Claims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(result.AccessToken, claims); err != nil {
+		return nil, "", fmt.Errorf("failed to parse IAM token: %w", err)
+	}
+	if claims.Owner == "" || claims.Name == "" {
+		return nil, "", fmt.Errorf("IAM token does not identify a service account")
+	}
+	return &claims.User, result.Scope, nil
+}
+
+// writeOAuthError writes an RFC 6749 error response.
+func (c *ApiController) writeOAuthError(status int, code string, description string) {
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = map[string]string{"error": code, "error_description": description}
+	c.ServeJSON()
+}
+
+// OAuthToken
+// @Title OAuthToken
+// @Tag Account API
+// @Description OAuth2 client-credentials grant: exchange a service account's clientId/clientSecret for a short-lived gateway token
+// @Param   grant_type     formData    string  true     "must be client_credentials"
+// @Param   client_id      formData    string  false    "client ID (or HTTP Basic auth)"
+// @Param   client_secret  formData    string  false    "client secret (or HTTP Basic auth)"
+// @Param   scope          formData    string  false    "space-separated scopes, defaults to chat; each must be granted to the client by IAM"
+// @Success 200 {object} map[string]interface{} The OAuth2 token response
+// @router /oauth/token [post]
+func (c *ApiController) OAuthToken() {
+	if grantType := c.Input().Get("grant_type"); grantType != "client_credentials" {
+		c.writeOAuthError(http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientId, clientSecret, ok := c.Ctx.Request.BasicAuth()
+	if !ok {
+		clientId = c.Input().Get("client_id")
+		clientSecret = c.Input().Get("client_secret")
+	}
+	if clientId == "" || clientSecret == "" {
+		c.writeOAuthError(http.StatusUnauthorized, "invalid_client", "client_id and client_secret are required")
+		return
+	}
+
+	scope := strings.Join(strings.Fields(c.Input().Get("scope")), " ")
+	if scope == "" {
+		scope = gatewayScopeChat
+	}
+
+	user, granted, err := exchangeClientCredentials(clientId, clientSecret, scope)
+	if err != nil {
+		logs.Info("oauth token: client %s rejected: %v", clientId, err)
+		c.writeOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	scope, err = capGatewayScope(scope, granted)
+	if err != nil {
+		c.writeOAuthError(http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	ttl := getGatewayTokenTTL()
+	token, err := mintGatewayToken(&gatewayClaims{
+		Owner: user.Owner,
+		Name:  user.Name,
+		Org:   user.Owner,
+		Scope: scope,
+	}, ttl)
+	if err != nil {
+		c.writeOAuthError(http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Data["json"] = map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+		"scope":        scope,
+	}
+	c.ServeJSON()
+}
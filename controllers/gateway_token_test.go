@@ -0,0 +1,170 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+)
+
+func init() {
+	gatewayTokenSecret = []byte("test-gateway-secret")
+}
+
+func TestGatewayTokenRoundTrip(t *testing.T) {
+	token, err := mintGatewayToken(&gatewayClaims{Owner: "acme", Name: "svc", Org: "acme", Scope: "chat models"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isGatewayToken(token) {
+		t.Fatalf("token %q lacks the gt- prefix", token)
+	}
+
+	claims, err := parseScopedGatewayToken(token, gatewayScopeChat)
+	if err != nil {
+		t.Fatalf("parseScopedGatewayToken() err = %v", err)
+	}
+	if claims.Owner != "acme" || claims.Name != "svc" || claims.Org != "acme" || claims.ID == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err = parseScopedGatewayToken(token, "admin"); err == nil {
+		t.Error("token without the admin scope was accepted for admin")
+	}
+}
+
+func TestGatewayTokenRejectsInvalid(t *testing.T) {
+	expired, err := mintGatewayToken(&gatewayClaims{Owner: "acme", Name: "svc", Scope: "chat"}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := mintGatewayToken(&gatewayClaims{Owner: "acme", Name: "svc", Scope: "chat"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+
+	for name, token := range map[string]string{"expired": expired, "tampered": tampered, "garbage": "gt-not-a-jwt"} {
+		if _, err := parseGatewayToken(token); err == nil {
+			t.Errorf("%s token was accepted", name)
+		}
+	}
+}
+
+func TestGatewayTokenRevocationAndModels(t *testing.T) {
+	claims := &gatewayClaims{Owner: "acme", Name: "alice", Scope: "chat", Models: []string{"zen-mini"}}
+	token, err := mintGatewayToken(claims, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseGatewayToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.allowsModel("zen-mini") || parsed.allowsModel("zen-max") {
+		t.Errorf("model restriction not applied: %v", parsed.Models)
+	}
+
+	gatewayTokenRevocations.revoke(claims.ID, claims.ExpiresAt.Time)
+	if _, err = parseGatewayToken(token); err == nil {
+		t.Error("revoked token was accepted")
+	}
+}
+
+func TestCapGatewayScope(t *testing.T) {
+	tests := []struct {
+		requested string
+		granted   string
+		want      string
+		ok        bool
+	}{
+		{"chat", "", "chat", true},
+		{"*", "", "", false},
+		{"chat models", "", "", false},
+		{"chat  models", "chat models admin", "chat models", true},
+		{"admin", "*", "admin", true},
+		{"*", "chat", "", false},
+	}
+	for _, tt := range tests {
+		got, err := capGatewayScope(tt.requested, tt.granted)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("capGatewayScope(%q, %q) = %q, %v; want %q, ok %v", tt.requested, tt.granted, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGatewayTokenRevocationBroadcast(t *testing.T) {
+	backend := &counterBackend{counters: map[string]int64{}}
+	cache.SetBackend(backend)
+	t.Cleanup(func() { cache.SetBackend(nil) })
+
+	expiresAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	gatewayTokenRevocations.revoke("gt-id-local", expiresAt)
+	key := fmt.Sprintf("gt-id-local|%d", expiresAt.Unix())
+	if len(backend.published) != 1 || !strings.Contains(backend.published[0], key) {
+		t.Fatalf("published %v, want a revocation of %s", backend.published, key)
+	}
+
+	// Another replica's broadcast revokes the token here.
+	applyGatewayTokenRevocation(fmt.Sprintf("gt-id-remote|%d", expiresAt.Unix()), false)
+	if !gatewayTokenRevocations.isRevoked("gt-id-remote") {
+		t.Error("a broadcast revocation was not applied")
+	}
+	applyGatewayTokenRevocation("gt-id-malformed", false)
+	if gatewayTokenRevocations.isRevoked("gt-id-malformed") {
+		t.Error("a malformed broadcast was applied")
+	}
+}
+
+func TestGetGatewayTokenSecretRetriesFailures(t *testing.T) {
+	previous, previousResolve := gatewayTokenSecret, resolveGatewayTokenSecret
+	t.Cleanup(func() {
+		gatewayTokenSecret, resolveGatewayTokenSecret = previous, previousResolve
+		gatewayTokenSecretRetryAt = time.Time{}
+	})
+
+	calls := 0
+	secret := ""
+	resolveGatewayTokenSecret = func() string {
+		calls++
+		return secret
+	}
+	gatewayTokenSecret = nil
+
+	// KMS is down: the failure is remembered for a while, not for good.
+	if got := getGatewayTokenSecret(); got != nil {
+		t.Fatalf("secret = %q while KMS is down, want none", got)
+	}
+	getGatewayTokenSecret()
+	if calls != 1 {
+		t.Errorf("resolved %d times within the retry window, want 1", calls)
+	}
+
+	secret = "recovered-secret"
+	gatewayTokenSecretRetryAt = time.Now()
+	if got := string(getGatewayTokenSecret()); got != "recovered-secret" {
+		t.Errorf("secret after the retry window = %q, want recovered-secret", got)
+	}
+	getGatewayTokenSecret()
+	if calls != 2 {
+		t.Errorf("resolved %d times, want a resolved secret to be kept", calls)
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Guardrails. Each organization can have a chain of guardrail policies,
+// stored in the guardrail_policy table, that run on the user's prompt
+// before any path sends it upstream and on the answer after it. Keyword and regex policies
+// match locally; moderation policies ask the guard model (guardrailModel,
+// default zen3-guard) to classify the text. A policy blocks, flags or
+// redacts what it matches, and every match is recorded with the request's
+// usage. When an organization has output policies, the answer is held back
+// until it has been checked, so streamed answers arrive in one chunk.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultGuardrailModel classifies text for moderation policies when
+// guardrailModel is unset.
+const defaultGuardrailModel = "zen3-guard"
+
+// guardrailModerationPrompt asks the guard model for a verdict on the text.
+const guardrailModerationPrompt = `You are a content safety classifier. Reply with "safe" if the text below is safe, or with "unsafe: " followed by the violated categories if it is not. Reply with nothing else.
+
+Text:
+%s`
+
+// guardrailDecision records a policy that matched a request.
+type guardrailDecision struct {
+	Policy string `json:"policy"`
+	Stage  string `json:"stage"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"` // moderation categories
+}
+
+func (d guardrailDecision) String() string {
+	return fmt.Sprintf("%s:%s:%s", d.Stage, d.Policy, d.Action)
+}
+
+// guardrailOutcome is the result of running a stage of the chain: the text
+// after redaction, the policies that matched, and the block that ended the
+// chain, if any.
+type guardrailOutcome struct {
+	Text      string
+	Decisions []guardrailDecision
+	Blocked   *guardrailDecision
+	Message   string // the blocking policy's message
+}
+
+// guardrailModerator classifies text, returning whether it is unsafe and
+// the violated categories. Replaced in tests, like loadGuardrailPolicies.
+var (
+	guardrailModerator    = moderateWithGuardModel
+	loadGuardrailPolicies = object.GetActiveGuardrailPolicies
+)
+
+// getGuardrailPolicies returns org's enabled policies. Lookups that fail
+// let the request through.
+func getGuardrailPolicies(org string) []*object.GuardrailPolicy {
+	if org == "" {
+		return nil
+	}
+	policies, err := loadGuardrailPolicies(org)
+	if err != nil {
+		logs.Warn("guardrail: policy lookup for %s failed: %v (skipping)", org, err)
+		return nil
+	}
+	return policies
+}
+
+// holdsGuardrailOutput reports whether org has output policies, so answers
+// must be held back until they have been checked.
+func holdsGuardrailOutput(org string) bool {
+	for _, policy := range getGuardrailPolicies(org) {
+		if policy.AppliesTo(object.GuardrailStageOutput) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyGuardrails runs org's policies for stage on text. The guard model is
+// asked at most once. When it cannot be asked, blocking moderation policies
+// block the text and the others let it through.
+func applyGuardrails(ctx context.Context, org string, stage string, text string, lang string) *guardrailOutcome {
+	outcome := &guardrailOutcome{Text: text}
+	moderated := false
+	var unsafe bool
+	var categories string
+	var moderationErr error
+	for _, policy := range getGuardrailPolicies(org) {
+		if !policy.AppliesTo(stage) {
+			continue
+		}
+
+		decision := guardrailDecision{Policy: policy.Name, Stage: stage, Action: policy.Action}
+		if policy.Type == object.GuardrailTypeModeration {
+			if !moderated {
+				unsafe, categories, moderationErr = guardrailModerator(ctx, org, outcome.Text, lang)
+				if moderationErr != nil {
+					logs.Warn("guardrail: moderation for %s failed: %v", org, moderationErr)
+					object.GuardrailErrors.Inc()
+				}
+				moderated = true
+			}
+			switch {
+			case moderationErr != nil && policy.Action == object.GuardrailActionBlock:
+				decision.Reason = "moderation unavailable"
+			case moderationErr != nil || !unsafe:
+				continue
+			default:
+				decision.Reason = categories
+			}
+		} else if policy.Match(outcome.Text) == "" {
+			continue
+		}
+
+		object.GuardrailDecisions.WithLabelValues(stage, policy.Action).Inc()
+		outcome.Decisions = append(outcome.Decisions, decision)
+		switch policy.Action {
+		case object.GuardrailActionBlock:
+			outcome.Blocked = &outcome.Decisions[len(outcome.Decisions)-1]
+			outcome.Message = policy.Message
+			return outcome
+		case object.GuardrailActionRedact:
+			outcome.Text = policy.Redact(outcome.Text)
+		}
+	}
+	return outcome
+}
+
+// blockedMessage is the error returned for a blocked request.
+func (o *guardrailOutcome) blockedMessage() string {
+	if o.Message != "" {
+		return o.Message
+	}
+	if o.Blocked.Stage == object.GuardrailStageOutput {
+		return fmt.Sprintf("The response was blocked by the guardrail policy %q.", o.Blocked.Policy)
+	}
+	return fmt.Sprintf("The request was blocked by the guardrail policy %q.", o.Blocked.Policy)
+}
+
+// getGuardrailModel returns the guard model: guardrailModel, or
+// defaultGuardrailModel when unset.
+func getGuardrailModel() string {
+	if modelName := conf.GetConfigString("guardrailModel"); modelName != "" {
+		return modelName
+	}
+	return defaultGuardrailModel
+}
+
+// moderateWithGuardModel asks the guard model to classify text.
+func moderateWithGuardModel(ctx context.Context, org string, text string, lang string) (bool, string, error) {
+	modelName := getGuardrailModel()
+	route := resolveModelRouteForOrg(modelName, org)
+	if route == nil {
+		return false, "", fmt.Errorf("the guard model %s has no route", modelName)
+	}
+
+	writer := &CarrierWriter{}
+	_, err := callProvider(ctx, org, route.providerName, route.upstreamModel, fmt.Sprintf(guardrailModerationPrompt, text), writer, nil, nil, lang)
+	if err != nil {
+		return false, "", err
+	}
+	unsafe, categories := parseModerationVerdict(writer.MessageString())
+	return unsafe, categories, nil
+}
+
+// parseModerationVerdict reads the guard model's "safe" or
+// "unsafe: categories" reply. Anything else is treated as safe.
+func parseModerationVerdict(answer string) (bool, string) {
+	verdict := strings.TrimSpace(answer)
+	if !strings.HasPrefix(strings.ToLower(verdict), "unsafe") {
+		return false, ""
+	}
+	categories := strings.TrimSpace(verdict[len("unsafe"):])
+	categories = strings.TrimSpace(strings.TrimPrefix(categories, ":"))
+	return true, categories
+}
+
+// replayGuardedAnswer writes an answer held back for the output guardrails
+// to the response writer as one message event.
+func replayGuardedAnswer(writer io.Writer, answer string) error {
+	if answer == "" {
+		return nil
+	}
+	_, err := fmt.Fprintf(writer, "event: message\ndata: %s\n\n", answer)
+	return err
+}
+
+// lastUserMessageText returns the text of the last user message, the one
+// the input guardrails check.
+func lastUserMessageText(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messageText(messages[i])
+		}
+	}
+	return ""
+}
+
+// redactUserMessage puts the redacted text of the last user message back
+// into messages, for the paths that send them upstream as they are. Images
+// and other non-text parts are kept.
+func redactUserMessage(messages []openai.ChatCompletionMessage, text string) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		if len(messages[i].MultiContent) == 0 {
+			messages[i].Content = text
+			return
+		}
+		parts := []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: text}}
+		for _, part := range messages[i].MultiContent {
+			if part.Type != openai.ChatMessagePartTypeText {
+				parts = append(parts, part)
+			}
+		}
+		messages[i].MultiContent = parts
+		return
+	}
+}
+
+// recordGuardrailBlock records a request the input guardrails blocked
+// before it reached a provider.
+func (c *ApiController) recordGuardrailBlock(authUser *iamsdk.User, model string, premium bool, stream bool, outcome *guardrailOutcome, startTime time.Time) {
+	if authUser == nil {
+		return
+	}
+	recordUsage(&usageRecord{
+		Owner:      authUser.Owner,
+		User:       authUser.Owner + "/" + authUser.Name,
+		Model:      model,
+		Premium:    premium,
+		Stream:     stream,
+		Status:     "blocked",
+		ErrorMsg:   outcome.blockedMessage(),
+		ClientIP:   c.getClientIp(),
+		RequestID:  util.GenerateUUID(),
+		LatencyMs:  time.Since(startTime).Milliseconds(),
+		Prompt:     outcome.Text,
+		Guardrails: outcome.Decisions,
+	})
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetGuardrailPolicies
+// @Title GetGuardrailPolicies
+// @Tag Guardrail API
+// @Description get the guardrail policies of an organization
+// @Param owner query string true "The owner (org) of the policies"
+// @Success 200 {array} object.GuardrailPolicy The Response object
+// @router /get-guardrail-policies [get]
+func (c *ApiController) GetGuardrailPolicies() {
+	policies, err := object.GetGuardrailPolicies(c.Input().Get("owner"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(policies)
+}
+
+// AddGuardrailPolicy
+// @Title AddGuardrailPolicy
+// @Tag Guardrail API
+// @Description add a guardrail policy for an organization
+// @Param body body object.GuardrailPolicy true "The details of the policy"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-guardrail-policy [post]
+func (c *ApiController) AddGuardrailPolicy() {
+	var policy object.GuardrailPolicy
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddGuardrailPolicy(&policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "guardrail-policy", policy.Owner, policy.GetId(), nil, &policy)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateGuardrailPolicy
+// @Title UpdateGuardrailPolicy
+// @Tag Guardrail API
+// @Description update an organization's guardrail policy
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the policy"
+// @Param body body object.GuardrailPolicy true "The details of the policy"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-guardrail-policy [post]
+func (c *ApiController) UpdateGuardrailPolicy() {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var policy object.GuardrailPolicy
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetGuardrailPolicy(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateGuardrailPolicy(owner, name, &policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "guardrail-policy", owner, policy.GetId(), before, &policy)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteGuardrailPolicy
+// @Title DeleteGuardrailPolicy
+// @Tag Guardrail API
+// @Description delete an organization's guardrail policy
+// @Param body body object.GuardrailPolicy true "The details of the policy"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-guardrail-policy [post]
+func (c *ApiController) DeleteGuardrailPolicy() {
+	var policy object.GuardrailPolicy
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetGuardrailPolicy(policy.Owner, policy.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteGuardrailPolicy(&policy)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "guardrail-policy", policy.Owner, policy.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
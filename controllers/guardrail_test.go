@@ -0,0 +1,171 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// useGuardrailPolicies serves policies to the guardrail chain for the test.
+func useGuardrailPolicies(t *testing.T, policies ...*object.GuardrailPolicy) {
+	for _, policy := range policies {
+		if err := policy.Compile(); err != nil {
+			t.Fatalf("Compile(%s) = %v", policy.Name, err)
+		}
+	}
+	previous := loadGuardrailPolicies
+	loadGuardrailPolicies = func(string) ([]*object.GuardrailPolicy, error) { return policies, nil }
+	t.Cleanup(func() { loadGuardrailPolicies = previous })
+}
+
+func TestApplyGuardrails(t *testing.T) {
+	useGuardrailPolicies(t,
+		&object.GuardrailPolicy{Name: "a-email", Stage: object.GuardrailStageBoth, Type: object.GuardrailTypeRegex, Patterns: object.StringSlice{`[\w.]+@[\w.]+`}, Action: object.GuardrailActionRedact},
+		&object.GuardrailPolicy{Name: "b-competitor", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeKeyword, Patterns: object.StringSlice{"acme corp"}, Action: object.GuardrailActionFlag},
+		&object.GuardrailPolicy{Name: "c-secret", Stage: object.GuardrailStageOutput, Type: object.GuardrailTypeKeyword, Patterns: object.StringSlice{"internal only"}, Action: object.GuardrailActionBlock, Message: "Nope."},
+	)
+
+	input := applyGuardrails(context.Background(), "hanzo", object.GuardrailStageInput, "Mail bob@example.com about Acme Corp, internal only", "en")
+	if input.Blocked != nil {
+		t.Fatalf("input blocked by %s; the block policy is output only", input.Blocked.Policy)
+	}
+	if want := "Mail [REDACTED] about Acme Corp, internal only"; input.Text != want {
+		t.Errorf("input Text = %q, want %q", input.Text, want)
+	}
+	if len(input.Decisions) != 2 || input.Decisions[0].String() != "input:a-email:redact" || input.Decisions[1].String() != "input:b-competitor:flag" {
+		t.Errorf("input Decisions = %v", input.Decisions)
+	}
+
+	output := applyGuardrails(context.Background(), "hanzo", object.GuardrailStageOutput, "This is internal only.", "en")
+	if output.Blocked == nil || output.Blocked.Policy != "c-secret" {
+		t.Fatalf("output Blocked = %v, want c-secret", output.Blocked)
+	}
+	if got := output.blockedMessage(); got != "Nope." {
+		t.Errorf("blockedMessage() = %q, want the policy's message", got)
+	}
+
+	if !holdsGuardrailOutput("hanzo") {
+		t.Error("holdsGuardrailOutput() = false with output policies")
+	}
+}
+
+func TestApplyGuardrailsModeration(t *testing.T) {
+	useGuardrailPolicies(t,
+		&object.GuardrailPolicy{Name: "flag", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeModeration, Action: object.GuardrailActionFlag},
+		&object.GuardrailPolicy{Name: "block", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeModeration, Action: object.GuardrailActionBlock},
+	)
+	calls := 0
+	previous := guardrailModerator
+	guardrailModerator = func(ctx context.Context, org string, text string, lang string) (bool, string, error) {
+		calls++
+		return text == "bad", "violence", nil
+	}
+	defer func() { guardrailModerator = previous }()
+
+	outcome := applyGuardrails(context.Background(), "hanzo", object.GuardrailStageInput, "bad", "en")
+	if outcome.Blocked == nil || outcome.Blocked.Reason != "violence" || len(outcome.Decisions) != 2 {
+		t.Errorf("outcome = %+v, want flagged then blocked for violence", outcome)
+	}
+	if calls != 1 {
+		t.Errorf("guard model asked %d times, want once per stage", calls)
+	}
+
+	if outcome = applyGuardrails(context.Background(), "hanzo", object.GuardrailStageInput, "fine", "en"); len(outcome.Decisions) != 0 {
+		t.Errorf("safe text Decisions = %v", outcome.Decisions)
+	}
+	if holdsGuardrailOutput("hanzo") {
+		t.Error("holdsGuardrailOutput() = true with input policies only")
+	}
+}
+
+func TestApplyGuardrailsModerationError(t *testing.T) {
+	previous := guardrailModerator
+	guardrailModerator = func(ctx context.Context, org string, text string, lang string) (bool, string, error) {
+		return false, "", fmt.Errorf("guard model unavailable")
+	}
+	defer func() { guardrailModerator = previous }()
+
+	useGuardrailPolicies(t,
+		&object.GuardrailPolicy{Name: "flag", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeModeration, Action: object.GuardrailActionFlag},
+	)
+	if outcome := applyGuardrails(context.Background(), "hanzo", object.GuardrailStageInput, "text", "en"); outcome.Blocked != nil || len(outcome.Decisions) != 0 {
+		t.Errorf("flag policy outcome = %+v, want the text let through", outcome)
+	}
+
+	useGuardrailPolicies(t,
+		&object.GuardrailPolicy{Name: "flag", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeModeration, Action: object.GuardrailActionFlag},
+		&object.GuardrailPolicy{Name: "block", Stage: object.GuardrailStageInput, Type: object.GuardrailTypeModeration, Action: object.GuardrailActionBlock},
+	)
+	if outcome := applyGuardrails(context.Background(), "hanzo", object.GuardrailStageInput, "text", "en"); outcome.Blocked == nil || outcome.Blocked.Policy != "block" {
+		t.Errorf("block policy outcome = %+v, want blocked", outcome)
+	}
+}
+
+func TestLastUserMessageText(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", MultiContent: []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: "second"}}},
+		{Role: "tool", Content: "result"},
+	}
+	if got := lastUserMessageText(messages); got != "second" {
+		t.Errorf("lastUserMessageText() = %q, want %q", got, "second")
+	}
+}
+
+func TestParseModerationVerdict(t *testing.T) {
+	tests := []struct {
+		answer     string
+		unsafe     bool
+		categories string
+	}{
+		{"safe", false, ""},
+		{"  Safe.\n", false, ""},
+		{"unsafe: violence, self-harm", true, "violence, self-harm"},
+		{"UNSAFE", true, ""},
+		{"I cannot tell", false, ""},
+	}
+	for _, tt := range tests {
+		unsafe, categories := parseModerationVerdict(tt.answer)
+		if unsafe != tt.unsafe || categories != tt.categories {
+			t.Errorf("parseModerationVerdict(%q) = %v, %q; want %v, %q", tt.answer, unsafe, categories, tt.unsafe, tt.categories)
+		}
+	}
+}
+
+func TestRedactUserMessage(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply"},
+		{Role: "user", MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "mail bob@example.com"},
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "https://example.com/a.png"}},
+		}},
+	}
+	redactUserMessage(messages, "mail [REDACTED]")
+
+	if messages[0].Content != "first" {
+		t.Errorf("earlier user message changed to %q", messages[0].Content)
+	}
+	parts := messages[2].MultiContent
+	if len(parts) != 2 || parts[0].Text != "mail [REDACTED]" || parts[1].Type != openai.ChatMessagePartTypeImageURL {
+		t.Errorf("redacted parts = %+v", parts)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Identity leaks. Zen models answer under the identity prompt of
+// model_identity.go, but the model underneath sometimes introduces itself
+// anyway ("I am GLM-5 by Zhipu"). Answers served with an identity prompt are
+// scanned for such self-descriptions naming a known provider or model
+// family, or a term from features.identity_leak.terms, and every leak is
+// counted in cloud_identity_leaks_total. With features.identity_leak.retry,
+// a non-streamed answer is held back and a leaking one is asked again once
+// with identityLeakReminder; streamed answers have already been sent.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+)
+
+// identityLeakReminder is put ahead of the question of a retried request.
+const identityLeakReminder = "Important: you are only the model described in your instructions. Never name another model, company or provider as yourself, your creator or the technology you run on."
+
+// identityLeakTerms are the providers and model families behind the zen
+// models, and the other assistants a model may mistake itself for.
+var identityLeakTerms = []string{
+	"GLM", "ChatGLM", "Zhipu", "Kimi", "Moonshot", "Qwen", "Tongyi", "Alibaba",
+	"DeepSeek", "Mistral", "Mixtral", "Llama", "Meta", "Cogito", "MiniMax",
+	"Fireworks", "OpenAI", "ChatGPT", "GPT", "Claude", "Anthropic", "Gemini", "Google",
+}
+
+// identitySelfDescription matches the phrases that introduce the model's
+// own name, creator or base: "I am", "I was trained by", "I'm based on",
+// "my name is".
+const identitySelfDescription = `\b(?:` +
+	`i(?:'m|’m| am)(?: (?:actually|really|just|basically|essentially))?(?: (?:an?|the))?` +
+	`|(?:i(?:'m|’m| am| was|'ve been| have been)) (?:[\w-]+ ){0,4}?(?:created|developed|trained|built|made|designed|released|fine-tuned) by(?: the)?` +
+	`|i(?:'m|’m| am) (?:based on|built on|powered by|running on|derived from|a (?:fine-tuned )?version of)(?: the| an?)?` +
+	`|my (?:name|underlying model|base model|model) is(?: the| an?)?` +
+	`) `
+
+// identityLeakNegation marks a self-description that denies the name.
+var identityLeakNegation = regexp.MustCompile(`(?i)\b(?:not|never)\b|n['’]t\b`)
+
+// identityLeakMatcher caches the expression for the configured terms.
+var identityLeakMatcher struct {
+	sync.Mutex
+	terms string
+	re    *regexp.Regexp
+}
+
+// getIdentityLeakRegexp returns the expression matching a self-description
+// followed by one of the built-in or extra terms, optionally versioned
+// ("Qwen3", "GPT-4o").
+func getIdentityLeakRegexp(extra []string) *regexp.Regexp {
+	key := strings.Join(extra, "\x00")
+	identityLeakMatcher.Lock()
+	defer identityLeakMatcher.Unlock()
+	if identityLeakMatcher.re != nil && identityLeakMatcher.terms == key {
+		return identityLeakMatcher.re
+	}
+
+	terms := []string{}
+	for _, term := range append(append([]string{}, identityLeakTerms...), extra...) {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+	identityLeakMatcher.terms = key
+	identityLeakMatcher.re = regexp.MustCompile(`(?i)` + identitySelfDescription + `(` + strings.Join(terms, "|") + `)[\d.]*\b`)
+	return identityLeakMatcher.re
+}
+
+// detectIdentityLeak returns the provider or model name the answer claims
+// to be, be made by or run on, or "" when it claims none.
+func detectIdentityLeak(answer string) string {
+	re := getIdentityLeakRegexp(GetModelConfig().IdentityLeak().Terms)
+	for _, match := range re.FindAllStringSubmatchIndex(answer, -1) {
+		if identityLeakNegation.MatchString(answer[match[0]:match[2]]) {
+			continue
+		}
+		return answer[match[2]:match[3]]
+	}
+	return ""
+}
+
+// identityLeakRetry asks the question again with reminder ahead of it and
+// returns the new answer.
+type identityLeakRetry func(reminder string) (string, *model.ModelResult, error)
+
+// retriesIdentityLeaks reports whether leaking answers are asked again, in
+// which case non-streamed zen answers are held back until scanned.
+func retriesIdentityLeaks() bool {
+	return GetModelConfig().IdentityLeak().Retry
+}
+
+// newIdentityLeakRetry returns a retry of question on the upstream that
+// served the answer: servedBy, a provider of route after failover, or else
+// the request's provider.
+func newIdentityLeakRetry(ctx context.Context, org string, route *modelRoute, provider *object.Provider, servedBy string, question string, history []*model.RawMessage, knowledge []*model.RawMessage, lang string) identityLeakRetry {
+	providerName, upstreamModel := identityLeakUpstream(route, provider, servedBy)
+	return func(reminder string) (string, *model.ModelResult, error) {
+		writer := &CarrierWriter{}
+		result, err := callProvider(ctx, org, providerName, upstreamModel, fmt.Sprintf("System: %s\n\n%s", reminder, question), writer, history, knowledge, lang)
+		if err != nil {
+			return "", nil, err
+		}
+		return writer.MessageString(), result, nil
+	}
+}
+
+// identityLeakUpstream returns the provider and upstream model of the
+// upstream of route named servedBy, or the request's provider when route has
+// none by that name.
+func identityLeakUpstream(route *modelRoute, provider *object.Provider, servedBy string) (string, string) {
+	if route != nil && servedBy != provider.Name {
+		for _, upstream := range route.upstreams() {
+			if upstream.providerName == servedBy {
+				return upstream.providerName, upstream.upstreamModel
+			}
+		}
+	}
+	return provider.Name, provider.SubType
+}
+
+// checkIdentityLeak scans the answer of a zen model and counts a leak. A
+// leaking answer is retried once when retry is set; the retried answer is
+// served unless it leaks too. It returns the answer to serve and the usage
+// of the retry, or nil when none ran.
+func checkIdentityLeak(modelName string, answer string, retry identityLeakRetry) (string, *model.ModelResult) {
+	leak := detectIdentityLeak(answer)
+	if leak == "" {
+		return answer, nil
+	}
+	if retry == nil {
+		logs.Warn("identity leak: model=%s named %q", modelName, leak)
+		object.IdentityLeaks.WithLabelValues(modelName, "served").Inc()
+		return answer, nil
+	}
+
+	retried, result, err := retry(identityLeakReminder)
+	if err != nil {
+		logs.Warn("identity leak: model=%s named %q, retry failed: %v", modelName, leak, err)
+		object.IdentityLeaks.WithLabelValues(modelName, "served").Inc()
+		return answer, nil
+	}
+	if again := detectIdentityLeak(retried); again != "" {
+		logs.Warn("identity leak: model=%s named %q, and %q after a retry", modelName, leak, again)
+		object.IdentityLeaks.WithLabelValues(modelName, "served").Inc()
+		return answer, result
+	}
+	logs.Info("identity leak: model=%s named %q, retried", modelName, leak)
+	object.IdentityLeaks.WithLabelValues(modelName, "retried").Inc()
+	return retried, result
+}
+
+// addRetryUsage adds the tokens of a retry to the request's result, so both
+// generations are billed.
+func addRetryUsage(result *model.ModelResult, retry *model.ModelResult) {
+	if result == nil || retry == nil {
+		return
+	}
+	result.PromptTokenCount += retry.PromptTokenCount
+	result.ResponseTokenCount += retry.ResponseTokenCount
+	result.TotalTokenCount += retry.TotalTokenCount
+}
@@ -0,0 +1,115 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestDetectIdentityLeak(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"I am GLM-5, a large language model by Zhipu AI.", "GLM"},
+		{"Hello! I'm Qwen3, how can I help?", "Qwen"},
+		{"I was developed by Moonshot AI.", "Moonshot"},
+		{"I'm a large language model trained by Google.", "Google"},
+		{"Sure. I am based on DeepSeek-V3.", "DeepSeek"},
+		{"My underlying model is Kimi K2.", "Kimi"},
+		{"I'm Zen4, created by Hanzo AI Inc.", ""},
+		{"No, I'm not ChatGPT. I'm Zen4 by Hanzo AI Inc.", ""},
+		{"I was not trained by OpenAI.", ""},
+		{"GPT-4o and Claude are popular; I'm happy to compare them.", ""},
+		{"I am metaphorically speaking a llama farmer.", ""},
+	}
+	for _, tt := range tests {
+		if got := detectIdentityLeak(tt.answer); got != tt.want {
+			t.Errorf("detectIdentityLeak(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIdentityLeak(t *testing.T) {
+	if answer, retried := checkIdentityLeak("zen4", "I'm Zen4.", nil); answer != "I'm Zen4." || retried != nil {
+		t.Errorf("clean answer = %q, %v; want it unchanged", answer, retried)
+	}
+
+	calls := 0
+	retry := func(answer string, err error) identityLeakRetry {
+		return func(reminder string) (string, *model.ModelResult, error) {
+			calls++
+			if reminder != identityLeakReminder {
+				t.Errorf("reminder = %q", reminder)
+			}
+			return answer, &model.ModelResult{PromptTokenCount: 10, ResponseTokenCount: 5, TotalTokenCount: 15}, err
+		}
+	}
+
+	answer, retried := checkIdentityLeak("zen4", "I am GLM-5.", retry("I am Zen4.", nil))
+	if answer != "I am Zen4." || retried == nil {
+		t.Errorf("retried answer = %q, %v; want the retry's answer", answer, retried)
+	}
+	answer, retried = checkIdentityLeak("zen4", "I am GLM-5.", retry("I am Kimi.", nil))
+	if answer != "I am GLM-5." || retried == nil {
+		t.Errorf("answer = %q, %v; want the first answer and the retry's usage when both leak", answer, retried)
+	}
+	answer, retried = checkIdentityLeak("zen4", "I am GLM-5.", retry("", fmt.Errorf("upstream down")))
+	if answer != "I am GLM-5." || retried != nil {
+		t.Errorf("answer = %q, %v; want the first answer when the retry fails", answer, retried)
+	}
+	if calls != 3 {
+		t.Errorf("retries = %d, want 3", calls)
+	}
+
+	result := &model.ModelResult{PromptTokenCount: 20, ResponseTokenCount: 8, TotalTokenCount: 28}
+	addRetryUsage(result, &model.ModelResult{PromptTokenCount: 10, ResponseTokenCount: 5, TotalTokenCount: 15})
+	if result.PromptTokenCount != 30 || result.ResponseTokenCount != 13 || result.TotalTokenCount != 43 {
+		t.Errorf("addRetryUsage() = %+v", result)
+	}
+}
+
+func TestIdentityLeakUpstream(t *testing.T) {
+	route := &modelRoute{
+		providerName:  "fireworks",
+		upstreamModel: "accounts/fireworks/models/qwen3",
+		fallbacks:     []modelRouteFallback{{providerName: "do-ai", upstreamModel: "qwen3-instruct"}},
+	}
+	provider := &object.Provider{Name: "fireworks", SubType: "accounts/fireworks/models/qwen3"}
+
+	tests := []struct {
+		servedBy     string
+		wantProvider string
+		wantModel    string
+	}{
+		{"fireworks", "fireworks", "accounts/fireworks/models/qwen3"},
+		{"do-ai", "do-ai", "qwen3-instruct"},
+		{"unknown", "fireworks", "accounts/fireworks/models/qwen3"},
+	}
+	for _, tt := range tests {
+		providerName, upstreamModel := identityLeakUpstream(route, provider, tt.servedBy)
+		if providerName != tt.wantProvider || upstreamModel != tt.wantModel {
+			t.Errorf("identityLeakUpstream(%q) = %s, %s, want %s, %s",
+				tt.servedBy, providerName, upstreamModel, tt.wantProvider, tt.wantModel)
+		}
+	}
+	if providerName, _ := identityLeakUpstream(nil, provider, "do-ai"); providerName != "fireworks" {
+		t.Errorf("identityLeakUpstream without a route = %s, want fireworks", providerName)
+	}
+}
@@ -0,0 +1,522 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultJwtClockSkew is the tolerance applied to exp/nbf/iat when
+	// jwtClockSkewSeconds is not configured.
+	defaultJwtClockSkew = 60 * time.Second
+
+	// jwksMaxAge is how long a fetched JWKS is trusted before it is
+	// refetched on the next lookup.
+	jwksMaxAge = time.Hour
+
+	// jwksMinRefreshInterval rate-limits refreshes triggered by unknown key
+	// IDs, so forged tokens with random kids cannot hammer IAM.
+	jwksMinRefreshInterval = time.Minute
+
+	// jwtRevocationCacheTTL controls how long an introspection result is
+	// reused. It bounds how long a revoked token keeps working.
+	jwtRevocationCacheTTL = time.Minute
+
+	jwtHTTPTimeout = 5 * time.Second
+)
+
+// jwtPolicy holds the per-deployment JWT validation settings.
+//
+// Config keys (env var or app.conf):
+//   - jwtAudience: comma-separated accepted audiences (empty = not checked)
+//   - jwtIssuer: comma-separated accepted issuers (empty = not checked)
+//   - jwtClockSkewSeconds: tolerance for exp/nbf/iat (default 60)
+//   - jwtRevocationCheck: "true" to introspect tokens against IAM; tokens
+//     are rejected while IAM cannot answer
+type jwtPolicy struct {
+	audiences       []string
+	issuers         []string
+	clockSkew       time.Duration
+	checkRevocation bool
+}
+
+var (
+	jwtValidationPolicy = loadJwtPolicy()
+
+	// jwtCertificate is the PEM certificate of the IAM application, used
+	// when a token carries no kid or the JWKS endpoint is unavailable.
+	jwtCertificate string
+
+	jwtKeys        = newJwksCache()
+	jwtRevocations = newJwtRevocationChecker()
+)
+
+func loadJwtPolicy() *jwtPolicy {
+	p := &jwtPolicy{
+		audiences:       splitConfigList(conf.GetConfigString("jwtAudience")),
+		issuers:         splitConfigList(conf.GetConfigString("jwtIssuer")),
+		clockSkew:       defaultJwtClockSkew,
+		checkRevocation: conf.GetConfigBool("jwtRevocationCheck"),
+	}
+	if conf.GetConfigString("jwtClockSkewSeconds") != "" {
+		p.clockSkew = time.Duration(conf.GetConfigInt("jwtClockSkewSeconds")) * time.Second
+	}
+	return p
+}
+
+func splitConfigList(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// jwtValidationError is returned for any rejected token. reason is the
+// metrics label recorded in cloud_jwt_rejected_total.
+type jwtValidationError struct {
+	reason string
+	err    error
+}
+
+func (e *jwtValidationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *jwtValidationError) Unwrap() error {
+	return e.err
+}
+
+// validateJwtToken verifies the signature of an IAM-issued JWT and applies the
+// deployment's audience, issuer, clock-skew and revocation policy.
+func validateJwtToken(token string) (*iamsdk.Claims, error) {
+	claims, err := jwtValidationPolicy.validate(token, time.Now())
+	if err != nil {
+		reason := "invalid"
+		var validationErr *jwtValidationError
+		if errors.As(err, &validationErr) {
+			reason = validationErr.reason
+		}
+		object.JwtRejected.WithLabelValues(reason).Inc()
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (p *jwtPolicy) validate(token string, now time.Time) (*iamsdk.Claims, error) {
+	// Claims are validated below with clock-skew tolerance instead of the
+	// parser's strict defaults.
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	t, err := parser.ParseWithClaims(token, &iamsdk.Claims{}, jwtKeyFunc)
+	if err != nil {
+		return nil, &jwtValidationError{reason: parseErrorReason(err), err: err}
+	}
+
+	claims, ok := t.Claims.(*iamsdk.Claims)
+	if !ok || !t.Valid {
+		return nil, &jwtValidationError{reason: "invalid", err: fmt.Errorf("token is invalid")}
+	}
+
+	if err = p.verifyClaims(claims, now); err != nil {
+		return nil, err
+	}
+
+	if p.checkRevocation {
+		revoked, err := jwtRevocations.isRevoked(token, claims)
+		if err != nil {
+			return nil, &jwtValidationError{reason: "revocation_unavailable", err: fmt.Errorf("could not check whether the token has been revoked: %w", err)}
+		}
+		if revoked {
+			return nil, &jwtValidationError{reason: "revoked", err: fmt.Errorf("token has been revoked")}
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *jwtPolicy) verifyClaims(claims *iamsdk.Claims, now time.Time) error {
+	if claims.ExpiresAt != nil && now.Add(-p.clockSkew).After(claims.ExpiresAt.Time) {
+		return &jwtValidationError{reason: "expired", err: fmt.Errorf("token is expired")}
+	}
+	if claims.NotBefore != nil && now.Add(p.clockSkew).Before(claims.NotBefore.Time) {
+		return &jwtValidationError{reason: "not_yet_valid", err: fmt.Errorf("token is not valid yet")}
+	}
+	if claims.IssuedAt != nil && now.Add(p.clockSkew).Before(claims.IssuedAt.Time) {
+		return &jwtValidationError{reason: "not_yet_valid", err: fmt.Errorf("token used before issued")}
+	}
+
+	if len(p.audiences) > 0 {
+		matched := false
+		for _, aud := range p.audiences {
+			if claims.VerifyAudience(aud, true) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &jwtValidationError{reason: "audience", err: fmt.Errorf("token audience %v is not accepted", []string(claims.Audience))}
+		}
+	}
+
+	if len(p.issuers) > 0 {
+		matched := false
+		for _, iss := range p.issuers {
+			if claims.Issuer == iss {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &jwtValidationError{reason: "issuer", err: fmt.Errorf("token issuer %q is not accepted", claims.Issuer)}
+		}
+	}
+
+	return nil
+}
+
+func parseErrorReason(err error) string {
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) {
+		switch {
+		case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+			return "malformed"
+		case validationErr.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0:
+			return "signature"
+		}
+	}
+	return "invalid"
+}
+
+// jwtKeyFunc resolves the verification key for a token: first by kid from
+// the IAM JWKS (refreshing on rotation), then the configured certificate.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	switch alg {
+	case jwt.SigningMethodES256.Alg(), jwt.SigningMethodES512.Alg(),
+		jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS512.Alg():
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %v", token.Header["alg"])
+	}
+
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		if key := jwtKeys.get(kid); key != nil {
+			return key, nil
+		}
+	}
+
+	if jwtCertificate == "" {
+		return nil, fmt.Errorf("no verification key available")
+	}
+	if strings.HasPrefix(alg, "ES") {
+		return jwt.ParseECPublicKeyFromPEM([]byte(jwtCertificate))
+	}
+	return jwt.ParseRSAPublicKeyFromPEM([]byte(jwtCertificate))
+}
+
+// ── JWKS ────────────────────────────────────────────────────────────────────
+
+// jwksCache holds the IAM signing keys by kid. Unknown kids trigger a
+// rate-limited refetch so key rotation is picked up without a restart.
+type jwksCache struct {
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	group       singleflight.Group
+	client      *http.Client
+	fetch       func() (map[string]interface{}, error)
+}
+
+func newJwksCache() *jwksCache {
+	kc := &jwksCache{
+		keys:   make(map[string]interface{}),
+		client: &http.Client{Timeout: jwtHTTPTimeout},
+	}
+	kc.fetch = kc.fetchFromIAM
+	return kc
+}
+
+func (kc *jwksCache) get(kid string) interface{} {
+	kc.mu.RLock()
+	key, ok := kc.keys[kid]
+	fresh := time.Since(kc.fetchedAt) < jwksMaxAge
+	canRefresh := time.Since(kc.lastAttempt) >= jwksMinRefreshInterval
+	kc.mu.RUnlock()
+
+	if ok && fresh {
+		return key
+	}
+	if !canRefresh {
+		return key
+	}
+
+	kc.group.Do("jwks", func() (interface{}, error) {
+		kc.refresh()
+		return nil, nil
+	})
+
+	kc.mu.RLock()
+	defer kc.mu.RUnlock()
+	return kc.keys[kid]
+}
+
+func (kc *jwksCache) refresh() {
+	kc.mu.Lock()
+	kc.lastAttempt = time.Now()
+	kc.mu.Unlock()
+
+	keys, err := kc.fetch()
+	if err != nil {
+		logs.Warn("jwt: JWKS refresh failed: %v", err)
+		return
+	}
+
+	kc.mu.Lock()
+	kc.keys = keys
+	kc.fetchedAt = time.Now()
+	kc.mu.Unlock()
+}
+
+type jsonWebKey struct {
+	Kid string   `json:"kid"`
+	Kty string   `json:"kty"`
+	Crv string   `json:"crv"`
+	N   string   `json:"n"`
+	E   string   `json:"e"`
+	X   string   `json:"x"`
+	Y   string   `json:"y"`
+	X5c []string `json:"x5c"`
+}
+
+func (kc *jwksCache) fetchFromIAM() (map[string]interface{}, error) {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return nil, fmt.Errorf("iamEndpoint not configured")
+	}
+
+	resp, err := kc.client.Get(iamEndpoint + "/.well-known/jwks")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			logs.Warn("jwt: skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	if len(jwk.X5c) > 0 {
+		der, err := base64.StdEncoding.DecodeString(jwk.X5c[0])
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeJwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeJwkInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ── Revocation ──────────────────────────────────────────────────────────────
+
+type jwtRevocationEntry struct {
+	active    bool
+	expiresAt time.Time
+}
+
+// jwtRevocationChecker asks IAM's token introspection endpoint whether a token
+// is still active. Results are cached briefly; IAM failures are not cached
+// and reject the token, so an outage cannot let a revoked token back in.
+type jwtRevocationChecker struct {
+	mu         sync.RWMutex
+	entries    map[string]*jwtRevocationEntry
+	group      singleflight.Group
+	client     *http.Client
+	introspect func(token string) (bool, error)
+}
+
+func newJwtRevocationChecker() *jwtRevocationChecker {
+	rc := &jwtRevocationChecker{
+		entries: make(map[string]*jwtRevocationEntry),
+		client:  &http.Client{Timeout: jwtHTTPTimeout},
+	}
+	rc.introspect = rc.introspectWithIAM
+	return rc
+}
+
+func (rc *jwtRevocationChecker) isRevoked(token string, claims *iamsdk.Claims) (bool, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	rc.mu.RLock()
+	entry, ok := rc.entries[key]
+	rc.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return !entry.active, nil
+	}
+
+	v, err, _ := rc.group.Do(key, func() (interface{}, error) {
+		active, err := rc.introspect(token)
+		if err != nil {
+			logs.Warn("jwt: revocation check failed, rejecting token: %v", err)
+			return false, err
+		}
+
+		expiresAt := now.Add(jwtRevocationCacheTTL)
+		if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(expiresAt) {
+			expiresAt = claims.ExpiresAt.Time
+		}
+
+		rc.mu.Lock()
+		for k, e := range rc.entries {
+			if now.After(e.expiresAt) {
+				delete(rc.entries, k)
+			}
+		}
+		rc.entries[key] = &jwtRevocationEntry{active: active, expiresAt: expiresAt}
+		rc.mu.Unlock()
+		return active, nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+	active, _ := v.(bool)
+	return !active, nil
+}
+
+func (rc *jwtRevocationChecker) introspectWithIAM(token string) (bool, error) {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return false, fmt.Errorf("iamEndpoint not configured")
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest(http.MethodPost, iamEndpoint+"/api/login/oauth/introspect", strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(conf.GetConfigString("clientId"), conf.GetConfigString("clientSecret"))
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("introspection returned HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Active bool `json:"active"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Active, nil
+}
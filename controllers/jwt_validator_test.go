@@ -0,0 +1,186 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func signTestJwt(t *testing.T, key *rsa.PrivateKey, kid string, claims *iamsdk.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func TestJwtPolicyValidate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origKeys := jwtKeys
+	defer func() { jwtKeys = origKeys }()
+	jwtKeys = newJwksCache()
+	jwtKeys.fetch = func() (map[string]interface{}, error) {
+		return map[string]interface{}{"cert-1": &key.PublicKey}, nil
+	}
+
+	now := time.Now()
+	policy := &jwtPolicy{
+		audiences: []string{"app-client"},
+		issuers:   []string{"https://hanzo.id"},
+		clockSkew: time.Minute,
+	}
+	newClaims := func() *iamsdk.Claims {
+		return &iamsdk.Claims{
+			User: iamsdk.User{Owner: "hanzo", Name: "alice"},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://hanzo.id",
+				Audience:  jwt.ClaimStrings{"app-client"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		}
+	}
+
+	cases := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		mutate func(c *iamsdk.Claims)
+		reason string
+	}{
+		{"valid", key, func(c *iamsdk.Claims) {}, ""},
+		{"expired within skew", key, func(c *iamsdk.Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) }, ""},
+		{"expired", key, func(c *iamsdk.Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }, "expired"},
+		{"not yet valid", key, func(c *iamsdk.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(5 * time.Minute)) }, "not_yet_valid"},
+		{"wrong audience", key, func(c *iamsdk.Claims) { c.Audience = jwt.ClaimStrings{"other"} }, "audience"},
+		{"wrong issuer", key, func(c *iamsdk.Claims) { c.Issuer = "https://evil.example" }, "issuer"},
+		{"bad signature", otherKey, func(c *iamsdk.Claims) {}, "signature"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := newClaims()
+			tc.mutate(claims)
+			token := signTestJwt(t, tc.key, "cert-1", claims)
+
+			got, err := policy.validate(token, now)
+			if tc.reason == "" {
+				if err != nil || got.Name != "alice" {
+					t.Fatalf("validate() = %v, %v; want alice", got, err)
+				}
+				return
+			}
+
+			var validationErr *jwtValidationError
+			if !errors.As(err, &validationErr) || validationErr.reason != tc.reason {
+				t.Fatalf("validate() err = %v, want reason %q", err, tc.reason)
+			}
+		})
+	}
+}
+
+func TestJwtPolicyRevocation(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origKeys, origRevocations := jwtKeys, jwtRevocations
+	defer func() { jwtKeys, jwtRevocations = origKeys, origRevocations }()
+	jwtKeys = newJwksCache()
+	jwtKeys.fetch = func() (map[string]interface{}, error) {
+		return map[string]interface{}{"cert-1": &key.PublicKey}, nil
+	}
+
+	calls := 0
+	jwtRevocations = newJwtRevocationChecker()
+	jwtRevocations.introspect = func(string) (bool, error) {
+		calls++
+		return false, nil
+	}
+
+	now := time.Now()
+	token := signTestJwt(t, key, "cert-1", &iamsdk.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+	})
+
+	policy := &jwtPolicy{clockSkew: time.Minute, checkRevocation: true}
+	for i := 0; i < 2; i++ {
+		var validationErr *jwtValidationError
+		if _, err = policy.validate(token, now); !errors.As(err, &validationErr) || validationErr.reason != "revoked" {
+			t.Fatalf("validate() err = %v, want revoked", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("introspect called %d times, want 1", calls)
+	}
+}
+
+func TestJwtPolicyRevocationCheckFails(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origKeys, origRevocations := jwtKeys, jwtRevocations
+	defer func() { jwtKeys, jwtRevocations = origKeys, origRevocations }()
+	jwtKeys = newJwksCache()
+	jwtKeys.fetch = func() (map[string]interface{}, error) {
+		return map[string]interface{}{"cert-1": &key.PublicKey}, nil
+	}
+
+	iamDown := true
+	jwtRevocations = newJwtRevocationChecker()
+	jwtRevocations.introspect = func(string) (bool, error) {
+		if iamDown {
+			return false, errors.New("introspection returned HTTP 502")
+		}
+		return true, nil
+	}
+
+	now := time.Now()
+	token := signTestJwt(t, key, "cert-1", &iamsdk.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+	})
+
+	policy := &jwtPolicy{clockSkew: time.Minute, checkRevocation: true}
+	var validationErr *jwtValidationError
+	if _, err = policy.validate(token, now); !errors.As(err, &validationErr) || validationErr.reason != "revocation_unavailable" {
+		t.Fatalf("validate() with IAM down err = %v, want revocation_unavailable", err)
+	}
+
+	// The failure is not cached: the token works once IAM answers again.
+	iamDown = false
+	if _, err = policy.validate(token, now); err != nil {
+		t.Errorf("validate() with IAM back err = %v, want nil", err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/beego/beego/logs"
+	"github.com/beego/beego/utils/pagination"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+// kmsSecretNameRegex restricts secret names to characters that are safe in
+// the KMS URL path and in "kms://NAME" provider references.
+var kmsSecretNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+type kmsSecretRequest struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	ProjectId string `json:"projectId"`
+}
+
+// UpdateKmsSecret
+// @Title UpdateKmsSecret
+// @Tag KMS API
+// @Description create or update a KMS secret, e.g. to onboard a provider key referenced as kms://NAME
+// @Param body body controllers.kmsSecretRequest true "name, value and optional projectId (defaults to KMS_PROJECT_ID)"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-kms-secret [post]
+func (c *ApiController) UpdateKmsSecret() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var req kmsSecretRequest
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &req)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	if !kmsSecretNameRegex.MatchString(req.Name) {
+		c.ResponseError("invalid secret name: use letters, digits, '_', '.' or '-'")
+		return
+	}
+	if req.Value == "" {
+		c.ResponseError("secret value must not be empty")
+		return
+	}
+
+	created, err := object.SetKMSSecret(req.Name, req.Value, req.ProjectId)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	action := "updated"
+	if created {
+		action = "created"
+	}
+	logs.Info("kms: secret %q %s by %s", req.Name, action, c.GetSessionUsername())
+
+	c.ResponseOk(map[string]interface{}{
+		"reference": "kms://" + req.Name,
+		"created":   created,
+	})
+}
+
+// RefreshKmsSecrets
+// @Title RefreshKmsSecrets
+// @Tag KMS API
+// @Description drop cached KMS secrets so the next read re-fetches them, on every replica when a cache backend is configured. With provider set, the provider's cached record, HTTP client and all of its kms:// secrets are dropped; otherwise name/projectId select secrets (both empty = everything)
+// @Param name      query string false "secret name"
+// @Param projectId query string false "KMS project ID"
+// @Param provider  query string false "model provider name"
+// @Param owner     query string false "owner of the model provider (default admin)"
+// @Success 200 {object} controllers.Response The Response object
+// @router /refresh-kms-secrets [post]
+func (c *ApiController) RefreshKmsSecrets() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	if providerName := c.Input().Get("provider"); providerName != "" {
+		owner := c.Input().Get("owner")
+		if owner == "" {
+			owner = "admin"
+		}
+		err := object.InvalidateModelProviderForOrg(owner, providerName)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		c.ResponseOk()
+		return
+	}
+
+	count := object.InvalidateKMSSecret(c.Input().Get("name"), c.Input().Get("projectId"))
+	c.ResponseOk(count)
+}
+
+// GetSecretAudits
+// @Title GetSecretAudits
+// @Tag KMS API
+// @Description get the secret access audit log, newest first
+// @Param   owner       query    string  false    "provider owner to filter by"
+// @Param   pageSize    query    int     true     "page size"
+// @Param   p           query    int     true     "page number"
+// @Param   field       query    string  false    "field to filter by, e.g. provider or caller"
+// @Param   value       query    string  false    "value of the filter field"
+// @Success 200 {array} object.SecretAudit The Response object
+// @router /get-secret-audits [get]
+func (c *ApiController) GetSecretAudits() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	limit := util.ParseInt(c.Input().Get("pageSize"))
+	if limit <= 0 {
+		limit = 50
+	}
+	field := c.Input().Get("field")
+	value := c.Input().Get("value")
+	sortField := c.Input().Get("sortField")
+	sortOrder := c.Input().Get("sortOrder")
+
+	count, err := object.GetSecretAuditCount(owner, field, value)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	paginator := pagination.SetPaginator(c.Ctx, limit, count)
+	audits, err := object.GetPaginationSecretAudits(owner, paginator.Offset(), limit, field, value, sortField, sortOrder)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(audits, paginator.Nums())
+}
+
+// VerifySecretAudits
+// @Title VerifySecretAudits
+// @Tag KMS API
+// @Description verify the hash chain of the secret access audit log and report the first tampered row
+// @Success 200 {object} object.SecretAuditVerification The Response object
+// @router /verify-secret-audits [get]
+func (c *ApiController) VerifySecretAudits() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	res, err := object.VerifySecretAudits()
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(res)
+}
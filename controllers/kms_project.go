@@ -0,0 +1,149 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// GetKmsProjects
+// @Title GetKmsProjects
+// @Tag KmsProject API
+// @Description get the KMS project registered for each organization
+// @Success 200 {array} object.KmsProject The Response object
+// @router /get-kms-projects [get]
+func (c *ApiController) GetKmsProjects() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	projects, err := object.GetKmsProjects()
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(projects)
+}
+
+// AddKmsProject
+// @Title AddKmsProject
+// @Tag KmsProject API
+// @Description register the KMS project of an organization's provider secrets
+// @Param body body object.KmsProject true "The details of the KMS project"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-kms-project [post]
+func (c *ApiController) AddKmsProject() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var project object.KmsProject
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddKmsProject(&project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "kms-project", project.Owner, project.Owner, nil, &project)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateKmsProject
+// @Title UpdateKmsProject
+// @Tag KmsProject API
+// @Description change the KMS project of an organization
+// @Param owner query string true "The owner (org)"
+// @Param body body object.KmsProject true "The details of the KMS project"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-kms-project [post]
+func (c *ApiController) UpdateKmsProject() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+
+	var project object.KmsProject
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetKmsProject(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateKmsProject(owner, &project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "kms-project", owner, owner, before, &project)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteKmsProject
+// @Title DeleteKmsProject
+// @Tag KmsProject API
+// @Description remove an organization's KMS project
+// @Param body body object.KmsProject true "The details of the KMS project"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-kms-project [post]
+func (c *ApiController) DeleteKmsProject() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var project object.KmsProject
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetKmsProject(project.Owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteKmsProject(&project)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "kms-project", project.Owner, project.Owner, before, nil)
+	}
+
+	c.ResponseOk(success)
+}
@@ -0,0 +1,327 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// MCP (Model Context Protocol) server. Implements the stateless subset of the
+// Streamable HTTP transport: clients POST JSON-RPC 2.0 messages to /v1/mcp
+// and get application/json responses. Tools and resources are thin adapters
+// over the ZAP method registry, so they share its auth, billing and limits.
+//
+// Tools:     chat, list_models, get_balance
+// Resources: hanzo://usage
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	mcpProtocolVersion = "2025-03-26"
+	mcpServerName      = "hanzo-cloud"
+	mcpServerVersion   = "1.0.0"
+	mcpUsageUri        = "hanzo://usage"
+)
+
+// mcpSupportedVersions are the protocol versions initialize accepts as-is.
+var mcpSupportedVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// JSON-RPC 2.0 error codes.
+const (
+	jsonRpcParseError     = -32700
+	jsonRpcInvalidRequest = -32600
+	jsonRpcMethodNotFound = -32601
+	jsonRpcInvalidParams  = -32602
+
+	// jsonRpcRateLimited is the implementation-defined server error of a
+	// call over the caller's rate limits.
+	jsonRpcRateLimited = -32000
+)
+
+type mcpRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+// mcpTool maps an MCP tool onto a ZAP method.
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	zapMethod   string
+}
+
+func getMcpTools() []*mcpTool {
+	tools := []*mcpTool{
+		{Name: "chat", Description: "Generate a chat completion with a Hanzo Cloud model", zapMethod: "chat.completions"},
+		{Name: "list_models", Description: "List the models available on Hanzo Cloud", zapMethod: "models.list"},
+		{Name: "get_balance", Description: "Get the caller's Hanzo Cloud balance in USD", zapMethod: "balance"},
+	}
+	for _, tool := range tools {
+		tool.InputSchema = zapSchema(map[string]interface{}{})
+		if method := zapMethods[tool.zapMethod]; method != nil && method.Params != nil {
+			tool.InputSchema = method.Params
+		}
+	}
+	return tools
+}
+
+func getMcpTool(name string) *mcpTool {
+	for _, tool := range getMcpTools() {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// mcpCallZap runs a ZAP method for MCP and returns its status and body, or
+// the error message as the body on failure.
+func mcpCallZap(ctx context.Context, method string, auth string, args []byte) (uint32, []byte) {
+	msg, err := zapDispatch(ctx, method, auth, args)
+	if err != nil {
+		return 500, []byte(err.Error())
+	}
+	root := msg.Root()
+	status := root.Uint32(object.CloudRespStatus)
+	if status >= 400 {
+		return status, []byte(root.Text(object.CloudRespError))
+	}
+	return status, root.Bytes(object.CloudRespBody)
+}
+
+// mcpChatText reduces a chat.completion response to the assistant's text.
+func mcpChatText(body []byte) string {
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(body, &response) != nil || len(response.Choices) == 0 {
+		return string(body)
+	}
+	return response.Choices[0].Message.Content
+}
+
+func mcpCallTool(ctx context.Context, auth string, params json.RawMessage) (interface{}, *mcpError) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, &mcpError{Code: jsonRpcInvalidParams, Message: err.Error()}
+	}
+	tool := getMcpTool(call.Name)
+	if tool == nil {
+		return nil, &mcpError{Code: jsonRpcInvalidParams, Message: "unknown tool: " + call.Name}
+	}
+
+	status, body := mcpCallZap(ctx, tool.zapMethod, auth, call.Arguments)
+	text := string(body)
+	if status < 400 && tool.zapMethod == "chat.completions" {
+		text = mcpChatText(body)
+	}
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": status >= 400,
+	}, nil
+}
+
+func mcpReadResource(ctx context.Context, auth string, params json.RawMessage) (interface{}, *mcpError) {
+	var read struct {
+		Uri string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &read); err != nil {
+		return nil, &mcpError{Code: jsonRpcInvalidParams, Message: err.Error()}
+	}
+	if read.Uri != mcpUsageUri {
+		return nil, &mcpError{Code: jsonRpcInvalidParams, Message: "unknown resource: " + read.Uri}
+	}
+
+	status, body := mcpCallZap(ctx, "usage.query", auth, nil)
+	if status >= 400 {
+		return nil, &mcpError{Code: jsonRpcInvalidRequest, Message: string(body)}
+	}
+	return map[string]interface{}{
+		"contents": []map[string]string{{"uri": mcpUsageUri, "mimeType": "application/json", "text": string(body)}},
+	}, nil
+}
+
+func mcpInitialize(params json.RawMessage) interface{} {
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &init)
+
+	version := mcpProtocolVersion
+	for _, supported := range mcpSupportedVersions {
+		if init.ProtocolVersion == supported {
+			version = supported
+		}
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{"name": mcpServerName, "version": mcpServerVersion},
+	}
+}
+
+// mcpRateLimitError counts a tools/call or resources/read message against
+// the caller's API rate limits, since each runs a metered ZAP method, and
+// returns the error answering it when they are exhausted. Other messages
+// are not counted.
+func mcpRateLimitError(auth string, clientIP string, request *mcpRequest) *mcpError {
+	if zapRateLimit == nil || (request.Method != "tools/call" && request.Method != "resources/read") {
+		return nil
+	}
+	if allowed, retryAfter := zapRateLimit(auth, clientIP); !allowed {
+		return &mcpError{Code: jsonRpcRateLimited, Message: fmt.Sprintf("Rate limit exceeded. Retry after %d seconds.", retryAfter)}
+	}
+	return nil
+}
+
+// handleMcpRequest runs one JSON-RPC message. Notifications (no id) return
+// nil: they get no response.
+func handleMcpRequest(ctx context.Context, auth string, request *mcpRequest) *mcpResponse {
+	var result interface{}
+	var rpcErr *mcpError
+
+	switch {
+	case request.JsonRpc != "2.0" || request.Method == "":
+		rpcErr = &mcpError{Code: jsonRpcInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+	case strings.HasPrefix(request.Method, "notifications/"):
+		return nil
+	case request.Method == "initialize":
+		result = mcpInitialize(request.Params)
+	case request.Method == "ping":
+		result = map[string]interface{}{}
+	case request.Method == "tools/list":
+		result = map[string]interface{}{"tools": getMcpTools()}
+	case request.Method == "tools/call":
+		result, rpcErr = mcpCallTool(ctx, auth, request.Params)
+	case request.Method == "resources/list":
+		result = map[string]interface{}{
+			"resources": []map[string]string{{
+				"uri":         mcpUsageUri,
+				"name":        "usage",
+				"description": "Per-model request and token totals for the last 30 days",
+				"mimeType":    "application/json",
+			}},
+		}
+	case request.Method == "resources/read":
+		result, rpcErr = mcpReadResource(ctx, auth, request.Params)
+	default:
+		rpcErr = &mcpError{Code: jsonRpcMethodNotFound, Message: "method not found: " + request.Method}
+	}
+
+	id := request.Id
+	if len(id) == 0 {
+		if rpcErr == nil || rpcErr.Code != jsonRpcInvalidRequest {
+			return nil
+		}
+		id = json.RawMessage("null")
+	}
+	if rpcErr != nil {
+		return &mcpResponse{JsonRpc: "2.0", Id: id, Error: rpcErr}
+	}
+	return &mcpResponse{JsonRpc: "2.0", Id: id, Result: result}
+}
+
+// McpServer
+// @Title McpServer
+// @Tag MCP API
+// @Description Model Context Protocol server (Streamable HTTP, stateless)
+// @Param   body    body   object  true        "JSON-RPC 2.0 message or batch"
+// @Success 200 {object} object JSON-RPC 2.0 response
+// @router /mcp [post]
+func (c *ApiController) McpServer() {
+	auth := c.Ctx.Request.Header.Get("Authorization")
+	if auth == "" {
+		c.Ctx.Output.SetStatus(http.StatusUnauthorized)
+		c.Data["json"] = &mcpResponse{JsonRpc: "2.0", Id: json.RawMessage("null"), Error: &mcpError{Code: jsonRpcInvalidRequest, Message: "authentication required"}}
+		c.ServeJSON()
+		return
+	}
+
+	body := c.Ctx.Input.RequestBody
+	batch := strings.HasPrefix(strings.TrimSpace(string(body)), "[")
+	var requests []*mcpRequest
+	var err error
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		var request mcpRequest
+		err = json.Unmarshal(body, &request)
+		requests = []*mcpRequest{&request}
+	}
+	if err != nil {
+		c.Data["json"] = &mcpResponse{JsonRpc: "2.0", Id: json.RawMessage("null"), Error: &mcpError{Code: jsonRpcParseError, Message: err.Error()}}
+		c.ServeJSON()
+		return
+	}
+
+	// Every call in a batch counts against the rate limits, like the HTTP
+	// requests it stands in for.
+	ctx := c.Ctx.Request.Context()
+	clientIP := util.GetClientIP(c.Ctx.Request)
+	responses := []*mcpResponse{}
+	for _, request := range requests {
+		if rpcErr := mcpRateLimitError(auth, clientIP, request); rpcErr != nil {
+			if len(request.Id) != 0 {
+				responses = append(responses, &mcpResponse{JsonRpc: "2.0", Id: request.Id, Error: rpcErr})
+			}
+			continue
+		}
+		if response := handleMcpRequest(ctx, auth, request); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	if len(responses) == 0 {
+		c.EnableRender = false
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if batch {
+		c.Data["json"] = responses
+	} else {
+		c.Data["json"] = responses[0]
+	}
+	c.ServeJSON()
+}
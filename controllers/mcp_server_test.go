@@ -0,0 +1,91 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestHandleMcpRequest(t *testing.T) {
+	call := func(message string) *mcpResponse {
+		var request mcpRequest
+		if err := json.Unmarshal([]byte(message), &request); err != nil {
+			t.Fatalf("bad test message %s: %v", message, err)
+		}
+		return handleMcpRequest(context.Background(), "Bearer hk-test", &request)
+	}
+
+	response := call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	if response == nil || response.Error != nil {
+		t.Fatalf("initialize failed: %+v", response)
+	}
+	if version := response.Result.(map[string]interface{})["protocolVersion"]; version != "2024-11-05" {
+		t.Errorf("negotiated protocolVersion = %v, want 2024-11-05", version)
+	}
+
+	response = call(`{"jsonrpc":"2.0","id":"t","method":"tools/list"}`)
+	data, _ := json.Marshal(response)
+	var list struct {
+		Id     string `json:"id"`
+		Result struct {
+			Tools []struct {
+				Name        string                 `json:"name"`
+				InputSchema map[string]interface{} `json:"inputSchema"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil || list.Id != "t" || len(list.Result.Tools) != 3 {
+		t.Fatalf("tools/list = %s", data)
+	}
+	for _, tool := range list.Result.Tools {
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("tool %s has no object input schema", tool.Name)
+		}
+	}
+
+	if response = call(`{"jsonrpc":"2.0","method":"notifications/initialized"}`); response != nil {
+		t.Errorf("expected no response to a notification, got %+v", response)
+	}
+	if response = call(`{"jsonrpc":"2.0","id":2,"method":"prompts/list"}`); response == nil || response.Error == nil || response.Error.Code != jsonRpcMethodNotFound {
+		t.Errorf("expected method not found, got %+v", response)
+	}
+	if response = call(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"rm_rf"}}`); response == nil || response.Error == nil || response.Error.Code != jsonRpcInvalidParams {
+		t.Errorf("expected invalid params for unknown tool, got %+v", response)
+	}
+}
+
+func TestMcpRateLimitError(t *testing.T) {
+	previous := zapRateLimit
+	defer func() { zapRateLimit = previous }()
+
+	calls := 0
+	zapRateLimit = func(auth string, clientIP string) (bool, int) {
+		calls++
+		return calls < 2, 3
+	}
+
+	if err := mcpRateLimitError("Bearer hk", "10.0.0.1", &mcpRequest{Method: "tools/list"}); err != nil || calls != 0 {
+		t.Fatalf("tools/list: err = %v, calls = %d; want it uncounted", err, calls)
+	}
+	if err := mcpRateLimitError("Bearer hk", "10.0.0.1", &mcpRequest{Method: "tools/call"}); err != nil {
+		t.Fatalf("first call: err = %v, want allowed", err)
+	}
+	err := mcpRateLimitError("Bearer hk", "10.0.0.1", &mcpRequest{Method: "resources/read"})
+	if err == nil || err.Code != jsonRpcRateLimited {
+		t.Fatalf("second call: err = %v, want code %d", err, jsonRpcRateLimited)
+	}
+}
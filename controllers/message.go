@@ -307,8 +307,7 @@ func (c *ApiController) AddMessage() {
 		}
 	}
 
-	host := c.Ctx.Request.Host
-	origin := getOriginFromHost(host)
+	origin := c.getCloudUrl()
 	err = object.RefineMessageFiles(&message, origin, c.GetAcceptLanguage())
 	if err != nil {
 		c.ResponseError(err.Error())
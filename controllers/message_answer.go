@@ -15,10 +15,12 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/agent"
 	"github.com/hanzoai/cloud/embedding"
 	"github.com/hanzoai/cloud/model"
@@ -138,12 +140,8 @@ func (c *ApiController) GetMessageAnswer() {
 		}
 	}
 
-	modelProviderName := store.ModelProvider
-	if chat.ModelProvider != "" {
-		modelProviderName = chat.ModelProvider
-	}
-
-	modelProvider, modelProviderObj, err := object.GetModelProviderFromContext("admin", modelProviderName, c.GetAcceptLanguage())
+	modelProviderNames := orderStoreModelProviders(store, chat.ModelProvider, isStoreProviderHealthy)
+	modelProvider, modelProviderObj, fallbackProviderNames, err := getStoreModelProvider(modelProviderNames, c.GetAcceptLanguage())
 	if err != nil {
 		c.ResponseErrorStream(message, err.Error())
 		return
@@ -223,36 +221,17 @@ func (c *ApiController) GetMessageAnswer() {
 	// fmt.Printf("Refined Question: [%s]\n", realQuestion)
 	fmt.Printf("Answer: [")
 
-	prompt := store.Prompt
-	if modelProvider.Type != "Dummy" && !isReasonModel(modelProvider.SubType) {
-		if modelProvider.Type == "Alibaba Cloud" && webSearchEnabled {
-			prompt, err = getPromptWithCarrier(prompt, store.SuggestionCount, chat.NeedTitle)
-		} else {
-			question, err = getQuestionWithCarriers(question, store.SuggestionCount, chat.NeedTitle)
-		}
-		if err != nil {
-			c.ResponseErrorStream(message, err.Error())
-			return
-		}
-	}
-
-	var modelResult *model.ModelResult
-	if agentClients != nil {
-		messages := &model.AgentMessages{
-			Messages:  []*model.RawMessage{},
-			ToolCalls: nil,
-		}
-		agentInfo := &model.AgentInfo{
-			AgentClients:  agentClients,
-			AgentMessages: messages,
-		}
-		modelResult, err = model.QueryTextWithTools(modelProviderObj, question, writer, history, prompt, knowledge, agentInfo, c.GetAcceptLanguage())
-	} else {
-		if isReasonModel(modelProvider.SubType) {
-			modelResult, err = QueryCarrierText(question, writer, history, prompt, knowledge, modelProviderObj, chat.NeedTitle, store.SuggestionCount, c.GetAcceptLanguage())
-		} else {
-			modelResult, err = modelProviderObj.QueryText(question, writer, history, prompt, knowledge, nil, c.GetAcceptLanguage())
+	modelResult, err := c.queryStoreModel(store, chat, modelProvider, modelProviderObj, question, writer, history, knowledge, agentClients, webSearchEnabled)
+	recordStoreProviderHealth(modelProvider.Name, err)
+	for err != nil && len(fallbackProviderNames) > 0 && len(writer.buf) == 0 && isRetryableError(err) {
+		logs.Warn("store %s: model provider %s failed (%v), trying the next one", store.GetId(), modelProvider.Name, err)
+		nextProvider, nextProviderObj, nextNames, nextErr := getStoreModelProvider(fallbackProviderNames, c.GetAcceptLanguage())
+		if nextErr != nil {
+			break
 		}
+		modelProvider, modelProviderObj, fallbackProviderNames = nextProvider, nextProviderObj, nextNames
+		modelResult, err = c.queryStoreModel(store, chat, modelProvider, modelProviderObj, question, writer, history, knowledge, agentClients, webSearchEnabled)
+		recordStoreProviderHealth(modelProvider.Name, err)
 	}
 	if err != nil {
 		if strings.Contains(err.Error(), "write tcp") {
@@ -379,6 +358,43 @@ func (c *ApiController) GetMessageAnswer() {
 	}
 }
 
+// queryStoreModel asks modelProvider the question of a store chat,
+// streaming the answer to writer.
+func (c *ApiController) queryStoreModel(store *object.Store, chat *object.Chat, modelProvider *object.Provider, modelProviderObj model.ModelProvider, question string, writer *RefinedWriter, history []*model.RawMessage, knowledge []*model.RawMessage, agentClients *agent.AgentClients, webSearchEnabled bool) (*model.ModelResult, error) {
+	var err error
+	prompt := store.Prompt
+	if modelProvider.Type != "Dummy" && !isReasonModel(modelProvider.SubType) {
+		if modelProvider.Type == "Alibaba Cloud" && webSearchEnabled {
+			prompt, err = getPromptWithCarrier(prompt, store.SuggestionCount, chat.NeedTitle)
+		} else {
+			question, err = getQuestionWithCarriers(question, store.SuggestionCount, chat.NeedTitle)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var modelResult *model.ModelResult
+	if agentClients != nil {
+		messages := &model.AgentMessages{
+			Messages:  []*model.RawMessage{},
+			ToolCalls: nil,
+		}
+		agentInfo := &model.AgentInfo{
+			AgentClients:  agentClients,
+			AgentMessages: messages,
+		}
+		modelResult, err = model.QueryTextWithTools(context.Background(), modelProviderObj, question, writer, history, prompt, knowledge, agentInfo, c.GetAcceptLanguage())
+	} else {
+		if isReasonModel(modelProvider.SubType) {
+			modelResult, err = QueryCarrierText(question, writer, history, prompt, knowledge, modelProviderObj, chat.NeedTitle, store.SuggestionCount, c.GetAcceptLanguage())
+		} else {
+			modelResult, err = modelProviderObj.QueryText(context.Background(), question, writer, history, prompt, knowledge, nil, c.GetAcceptLanguage())
+		}
+	}
+	return modelResult, err
+}
+
 // GetAnswer
 // @Title GetAnswer
 // @Tag Message API
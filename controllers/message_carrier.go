@@ -15,6 +15,7 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -143,7 +144,7 @@ Examples of generated title:
 - Do NOT include any explanations or extra text—just output the title.`)
 	}
 
-	carrierResult, err := modelProviderObj.QueryText(fullPrompt.String(), writer, nil, "", nil, nil, lang)
+	carrierResult, err := modelProviderObj.QueryText(context.Background(), fullPrompt.String(), writer, nil, "", nil, nil, lang)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +165,7 @@ func QueryCarrierText(question string, writer *RefinedWriter, history []*model.R
 	go func() {
 		defer wg.Done()
 		var err error
-		modelResult, err = modelProviderObj.QueryText(question, writer, history, prompt, knowledge, nil, lang)
+		modelResult, err = modelProviderObj.QueryText(context.Background(), question, writer, history, prompt, knowledge, nil, lang)
 		if err != nil {
 			mainErr = err
 		}
@@ -15,6 +15,7 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hanzoai/cloud/model"
@@ -69,7 +70,7 @@ func validateTransactionBeforeAIGeneration(
 
 	// Use dryRunWriter which implements both io.Writer and http.Flusher
 	// Some model providers require http.Flusher even for dry run
-	dryRunResult, err := modelProviderObj.QueryText(dryRunQuestion, &dryRunWriter{}, history, store.Prompt, nil, nil, acceptLanguage)
+	dryRunResult, err := modelProviderObj.QueryText(context.Background(), dryRunQuestion, &dryRunWriter{}, history, store.Prompt, nil, nil, acceptLanguage)
 	if err != nil {
 		responseErrorFunc(message, fmt.Sprintf("failed to estimate token count: %s", err.Error()))
 		return err
@@ -0,0 +1,260 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+)
+
+// modelCatalogMaxAge is how long clients may reuse a model listing before
+// revalidating it with its ETag.
+const modelCatalogMaxAge = 60 * time.Second
+
+// modelCatalogCache holds marshaled model listings by ETag. An ETag covers
+// everything a listing depends on, so entries never go stale; the TTL only
+// reclaims listings no longer asked for.
+var modelCatalogCache = cache.NewLoading[[]byte]("model_catalog", cache.Options{
+	MaxEntries: 1024,
+	TTL:        time.Hour,
+})
+
+// modelCapabilities are the capabilities a model's config may list.
+var modelCapabilities = map[string]bool{
+	"chat":      true, // chat completions
+	"tools":     true, // function calling
+	"vision":    true, // image input
+	"reasoning": true, // thinks before answering
+	"json":      true, // JSON mode and structured outputs
+	"audio":     true, // audio input or output
+}
+
+// normalizeModelCapabilities lowercases, deduplicates and sorts capabilities.
+func normalizeModelCapabilities(capabilities []string) []string {
+	if len(capabilities) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(capabilities))
+	for _, capability := range capabilities {
+		capability = strings.ToLower(strings.TrimSpace(capability))
+		if capability != "" && !seen[capability] {
+			seen[capability] = true
+			normalized = append(normalized, capability)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// modelDetail is a model as retrieved by GET /v1/models/{id} and listed by
+// GET /v1/models?verbose=true: the listing's fields, in the OpenAI
+// retrieve-model shape, and the model's metadata.
+type modelDetail struct {
+	modelInfo
+	ContextWindow    int               `json:"context_window,omitempty"`
+	MaxOutputTokens  int               `json:"max_output_tokens,omitempty"`
+	Capabilities     []string          `json:"capabilities,omitempty"`
+	InputModalities  []string          `json:"input_modalities"`
+	OutputModalities []string          `json:"output_modalities"`
+	Pricing          modelDetailPrices `json:"pricing"`
+	Deprecated       bool              `json:"deprecated"`
+	Deprecation      string            `json:"deprecation,omitempty"` // the notice, e.g. "retired 2026-12-31, use zen4"
+	Provider         string            `json:"provider,omitempty"`    // omitted for zen and branded models
+}
+
+// modelDetailPrices are a model's prices in dollars per million tokens.
+type modelDetailPrices struct {
+	Currency             string  `json:"currency"`
+	InputPerMillion      float64 `json:"input_per_million"`
+	OutputPerMillion     float64 `json:"output_per_million"`
+	CacheReadPerMillion  float64 `json:"cache_read_per_million,omitempty"`
+	CacheWritePerMillion float64 `json:"cache_write_per_million,omitempty"`
+}
+
+// modelCatalogScope is what the model listing of an org depends on: the
+// config generation, the org's entitlements and its branded model names.
+type modelCatalogScope struct {
+	cfg          *ModelConfig
+	orgId        string
+	entitlements map[string]bool
+	branded      map[string]string
+}
+
+// getModelCatalogScope returns the scope of the catalog of orgId. An empty
+// orgId gets the public catalog.
+func getModelCatalogScope(orgId string) *modelCatalogScope {
+	scope := &modelCatalogScope{cfg: GetModelConfig(), orgId: orgId}
+	if orgId == "" {
+		return scope
+	}
+	entitlements, err := object.GetActiveEntitlements(orgId)
+	if err != nil {
+		logs.Warn("Model catalog: entitlements of %s: %v (serving the public catalog)", orgId, err)
+		entitlements = nil
+	}
+	scope.entitlements = entitlements
+	scope.branded = scope.cfg.BrandedModels(orgId)
+	return scope
+}
+
+func (s *modelCatalogScope) models() []modelInfo {
+	return withBrandedModels(s.cfg.ListModelsForEntitlements(s.entitlements), s.branded, s.orgId)
+}
+
+// model returns the details of model id if the catalog lists it.
+func (s *modelCatalogScope) model(id string) (*modelDetail, bool) {
+	for _, info := range s.models() {
+		if strings.EqualFold(info.ID, id) {
+			return s.detail(info), true
+		}
+	}
+	return nil, false
+}
+
+// details returns the details of every listed model, the verbose listing.
+func (s *modelCatalogScope) details() []*modelDetail {
+	models := s.models()
+	details := make([]*modelDetail, 0, len(models))
+	for _, info := range models {
+		details = append(details, s.detail(info))
+	}
+	return details
+}
+
+// detail adds a listed model's metadata to it. An org's own name for a zen
+// model gets the zen model's metadata.
+func (s *modelCatalogScope) detail(info modelInfo) *modelDetail {
+	target := info.ID
+	if zen, ok := s.branded[strings.ToLower(info.ID)]; ok {
+		target = zen
+	}
+	detail := &modelDetail{modelInfo: info}
+	var capabilities []string
+	if route := s.cfg.ResolveRoute(target); route != nil {
+		detail.ContextWindow = route.contextWindow
+		detail.MaxOutputTokens = route.maxOutputTokens
+		capabilities = route.capabilities
+		// Zen models are owned by Hanzo; where they run is not disclosed.
+		if route.ownedBy == "" && target == info.ID {
+			detail.Provider = route.providerName
+		}
+	}
+	detail.Capabilities = capabilities
+	detail.InputModalities, detail.OutputModalities = modelModalities(capabilities)
+	price := s.cfg.GetPriceForOrg(target, s.orgId)
+	detail.Pricing = modelDetailPrices{
+		Currency:             "usd",
+		InputPerMillion:      price.InputPerMillion,
+		OutputPerMillion:     price.OutputPerMillion,
+		CacheReadPerMillion:  price.CacheReadPerMillion,
+		CacheWritePerMillion: price.CacheWritePerMillion,
+	}
+	detail.Deprecation = s.cfg.GetDeprecation(target)
+	detail.Deprecated = detail.Deprecation != ""
+	return detail
+}
+
+// modelModalities returns what a model with capabilities takes and
+// produces: text, plus images for vision and audio for audio.
+func modelModalities(capabilities []string) (input []string, output []string) {
+	input, output = []string{"text"}, []string{"text"}
+	for _, capability := range capabilities {
+		switch capability {
+		case "vision":
+			input = append(input, "image")
+		case "audio":
+			input = append(input, "audio")
+			output = append(output, "audio")
+		}
+	}
+	return input, output
+}
+
+// etag returns a weak ETag of the listing: replicas that applied the same
+// config at different times list different created times.
+func (s *modelCatalogScope) etag() string {
+	var b strings.Builder
+	b.WriteString(s.cfg.catalogVersion())
+	b.WriteString("\n" + s.orgId)
+
+	entitlements := make([]string, 0, len(s.entitlements))
+	for entitlement, active := range s.entitlements {
+		if active {
+			entitlements = append(entitlements, entitlement)
+		}
+	}
+	sort.Strings(entitlements)
+	b.WriteString("\n" + strings.Join(entitlements, ","))
+
+	branded := make([]string, 0, len(s.branded))
+	for name, target := range s.branded {
+		branded = append(branded, name+"="+target)
+	}
+	sort.Strings(branded)
+	b.WriteString("\n" + strings.Join(branded, ","))
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(sum[:16]))
+}
+
+// getModelCatalog returns the ETag and the marshaled listing of the catalog
+// of orgId. The verbose listing has the models' details; since live pricing
+// and DB routes change prices within a config generation, its ETag is the
+// hash of its content instead.
+func getModelCatalog(orgId string, verbose bool) (string, []byte, error) {
+	scope := getModelCatalogScope(orgId)
+	if verbose {
+		body, err := json.Marshal(map[string]interface{}{
+			"object": "list",
+			"data":   scope.details(),
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		sum := sha256.Sum256(body)
+		return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])), body, nil
+	}
+
+	etag := scope.etag()
+	body, err := modelCatalogCache.Get(etag, func() ([]byte, error) {
+		return json.Marshal(map[string]interface{}{
+			"object": "list",
+			"data":   scope.models(),
+		})
+	})
+	return etag, body, err
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModelCatalogCreatedIsStable(t *testing.T) {
+	mc := &ModelConfig{
+		routes:  make(map[string]modelRoute),
+		pricing: make(map[string]modelPrice),
+		prompts: make(map[string]string),
+	}
+	if err := mc.loadFromSource(writeTestConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := mc.ListModels(), mc.ListModels()
+	if len(first) == 0 {
+		t.Fatal("no models listed")
+	}
+	for i := range first {
+		if first[i].Created != mc.appliedAt || second[i].Created != first[i].Created {
+			t.Errorf("%s created = %d then %d, want the generation's %d", first[i].ID, first[i].Created, second[i].Created, mc.appliedAt)
+		}
+	}
+	if version := mc.catalogVersion(); version != mc.history[0].Checksum {
+		t.Errorf("catalogVersion() = %q, want the config checksum", version)
+	}
+}
+
+func TestModelCatalogETag(t *testing.T) {
+	mc := &ModelConfig{
+		routes: map[string]modelRoute{
+			"zen4":       {providerName: "fireworks", upstreamModel: "glm-5"},
+			"zen4-ultra": {providerName: "fireworks", upstreamModel: "glm-5", entitlement: "enterprise"},
+		},
+		appliedAt: 1700000000,
+	}
+	public := &modelCatalogScope{cfg: mc}
+	etag := public.etag()
+	if etag != (&modelCatalogScope{cfg: mc}).etag() {
+		t.Fatal("the ETag of an unchanged catalog changed")
+	}
+
+	scopes := map[string]*modelCatalogScope{
+		"org":         {cfg: mc, orgId: "acme"},
+		"entitlement": {cfg: mc, entitlements: map[string]bool{"enterprise": true}},
+		"branded":     {cfg: mc, branded: map[string]string{"acme-1": "zen4"}},
+	}
+	for name, scope := range scopes {
+		if scope.etag() == etag {
+			t.Errorf("changing the %s kept the ETag", name)
+		}
+	}
+	if (&modelCatalogScope{cfg: mc, entitlements: map[string]bool{"enterprise": false}}).etag() != etag {
+		t.Error("an inactive entitlement changed the ETag")
+	}
+
+	mc.appliedAt++
+	if public.etag() == etag {
+		t.Error("a new generation of the static config kept the ETag")
+	}
+}
+
+func TestModelCatalogModelDetail(t *testing.T) {
+	mc := &ModelConfig{
+		routes:  make(map[string]modelRoute),
+		pricing: make(map[string]modelPrice),
+		prompts: make(map[string]string),
+	}
+	if err := mc.loadFromSource(writeTestConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+	scope := &modelCatalogScope{cfg: mc, orgId: "acme", branded: map[string]string{"acme-1": "zen4"}}
+
+	gpt, ok := scope.model("GPT-4o")
+	if !ok {
+		t.Fatal("gpt-4o not found")
+	}
+	want := modelDetail{
+		modelInfo:        modelInfo{ID: "gpt-4o", Object: "model", Created: mc.appliedAt, OwnedBy: "do-ai"},
+		ContextWindow:    128000,
+		MaxOutputTokens:  16384,
+		Capabilities:     []string{"chat", "tools", "vision"},
+		InputModalities:  []string{"text", "image"},
+		OutputModalities: []string{"text"},
+		Pricing:          modelDetailPrices{Currency: "usd", InputPerMillion: 2.50, OutputPerMillion: 10.00},
+		Deprecated:       true,
+		Deprecation:      "retired 2026-12-31, use zen4",
+		Provider:         "do-ai",
+	}
+	if !reflect.DeepEqual(*gpt, want) {
+		t.Errorf("gpt-4o = %+v, want %+v", *gpt, want)
+	}
+
+	zen, ok := scope.model("zen4")
+	if !ok {
+		t.Fatal("zen4 not found")
+	}
+	if zen.Provider != "" || zen.OwnedBy != "hanzo" || !zen.Premium || zen.ContextWindow != 200000 || zen.Pricing.InputPerMillion != 3.00 {
+		t.Errorf("zen4 = %+v, want hanzo's premium model without its provider", *zen)
+	}
+	body, err := json.Marshal(zen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "fireworks") || strings.Contains(string(body), "glm") {
+		t.Errorf("zen4 details disclose the upstream: %s", body)
+	}
+
+	branded, ok := scope.model("acme-1")
+	if !ok {
+		t.Fatal("acme-1 not found")
+	}
+	if branded.ID != "acme-1" || branded.OwnedBy != "acme" || branded.Provider != "" || branded.ContextWindow != 200000 {
+		t.Errorf("acme-1 = %+v, want zen4's details under acme's name", *branded)
+	}
+
+	for _, id := range []string{"zen", "openai/gpt-4o", "fireworks/deepseek-r1", "gpt-9"} {
+		if _, ok := scope.model(id); ok {
+			t.Errorf("%s: found a model the catalog does not list", id)
+		}
+	}
+}
+
+func TestModelCatalogVerbose(t *testing.T) {
+	mc := &ModelConfig{
+		routes:  make(map[string]modelRoute),
+		pricing: make(map[string]modelPrice),
+		prompts: make(map[string]string),
+	}
+	if err := mc.loadFromSource(writeTestConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+	scope := &modelCatalogScope{cfg: mc}
+
+	details := scope.details()
+	models := scope.models()
+	if len(details) != len(models) {
+		t.Fatalf("verbose listing has %d models, want the listing's %d", len(details), len(models))
+	}
+	for i, detail := range details {
+		if detail.modelInfo != models[i] {
+			t.Errorf("verbose listing[%d] = %+v, want %+v", i, detail.modelInfo, models[i])
+		}
+		if detail.Pricing.InputPerMillion == 0 || len(detail.InputModalities) == 0 {
+			t.Errorf("%s: missing pricing or modalities: %+v", detail.ID, detail)
+		}
+	}
+}
+
+func TestModelModalities(t *testing.T) {
+	tests := []struct {
+		capabilities []string
+		input        []string
+		output       []string
+	}{
+		{nil, []string{"text"}, []string{"text"}},
+		{[]string{"chat", "tools"}, []string{"text"}, []string{"text"}},
+		{[]string{"audio", "vision"}, []string{"text", "audio", "image"}, []string{"text", "audio"}},
+	}
+	for _, test := range tests {
+		input, output := modelModalities(test.capabilities)
+		if !reflect.DeepEqual(input, test.input) || !reflect.DeepEqual(output, test.output) {
+			t.Errorf("modelModalities(%q) = %q, %q, want %q, %q", test.capabilities, input, output, test.input, test.output)
+		}
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `W/"abc"`
+	for header, want := range map[string]bool{
+		`W/"abc"`:        true,
+		`"abc"`:          true,
+		`"xyz", W/"abc"`: true,
+		`*`:              true,
+		`"xyz"`:          false,
+		``:               false,
+		`W/"abcd", "ab"`: false,
+	} {
+		if got := etagMatches(header, etag); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
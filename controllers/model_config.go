@@ -15,6 +15,7 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sort"
@@ -44,7 +45,12 @@ type ModelConfigFile struct {
 	Cache          CacheTTLs           `yaml:"cache"`
 	Features       FeatureFlags        `yaml:"features"`
 	DefaultPricing ModelPriceDef       `yaml:"default_pricing"`
+	Identity       IdentityConfig      `yaml:"identity"`
 	Models         map[string]ModelDef `yaml:"models"`
+
+	// UpstreamCosts is what each provider charges for its upstream models,
+	// keyed by provider then upstream model ID; see pricing_sync.go.
+	UpstreamCosts map[string]map[string]ModelPriceDef `yaml:"upstream_costs,omitempty"`
 }
 
 // ServiceEndpoints holds URLs for external pricing/model services.
@@ -55,13 +61,36 @@ type ServiceEndpoints struct {
 // CacheTTLs defines TTL durations for cached data.
 type CacheTTLs struct {
 	PricingTTL string `yaml:"pricing_ttl"`
+	ConfigTTL  string `yaml:"config_ttl"` // how often live mode re-fetches the config source
 }
 
 // FeatureFlags controls runtime behavior.
 type FeatureFlags struct {
-	LiveMode      bool    `yaml:"live_mode"`
-	PremiumGate   bool    `yaml:"premium_gate"`
-	StarterCredit float64 `yaml:"starter_credit"`
+	LiveMode       bool    `yaml:"live_mode"`
+	PremiumGate    bool    `yaml:"premium_gate"`
+	StarterCredit  float64 `yaml:"starter_credit"`
+	FreezePricing  bool    `yaml:"freeze_pricing"`   // keep config prices, ignore live pricing
+	MaxPriceChange float64 `yaml:"max_price_change"` // max % a live refresh may move a price; default 50
+
+	RateLimits   RateLimitDefs    `yaml:"rate_limits"`
+	IdentityLeak IdentityLeakDefs `yaml:"identity_leak"`
+}
+
+// IdentityLeakDefs configure the scan of zen answers for the names of the
+// models and providers underneath; see identity_leak.go.
+type IdentityLeakDefs struct {
+	Retry bool     `yaml:"retry,omitempty"` // ask a non-streamed leaking answer again once
+	Terms []string `yaml:"terms,omitempty"` // names to catch besides the built-in ones
+}
+
+// RateLimitDefs are the gateway's per-minute request limits. Env overrides
+// (RATE_LIMIT_*) take precedence, and zero or missing entries keep the
+// built-in defaults; see routers/ratelimit.go.
+type RateLimitDefs struct {
+	Tiers map[string]int `yaml:"tiers,omitempty"` // tier or plan name → limit
+	IP    int            `yaml:"ip,omitempty"`    // requests without an API key, per client IP
+	Org   int            `yaml:"org,omitempty"`   // shared by all keys of an org; 0 is unlimited
+	Orgs  map[string]int `yaml:"orgs,omitempty"`  // org → limit, overriding org
 }
 
 // ModelPriceDef holds per-million token pricing.
@@ -72,103 +101,356 @@ type ModelPriceDef struct {
 	Output           float64 `yaml:"output,omitempty"`
 }
 
+// toModelPrice supports both {input, output} and {input_per_million,
+// output_per_million}.
+func (def *ModelPriceDef) toModelPrice() modelPrice {
+	p := modelPrice{}
+	if def.Input > 0 {
+		p.InputPerMillion = def.Input
+	} else {
+		p.InputPerMillion = def.InputPerMillion
+	}
+	if def.Output > 0 {
+		p.OutputPerMillion = def.Output
+	} else {
+		p.OutputPerMillion = def.OutputPerMillion
+	}
+	return p
+}
+
 // FallbackDef describes an alternate provider+upstream for failover.
 type FallbackDef struct {
 	Provider string `yaml:"provider"`
 	Upstream string `yaml:"upstream"`
+	Weight   int    `yaml:"weight,omitempty"` // share of traffic sent here first; 0 only on failover
 }
 
 // ModelDef describes a single model entry in the config.
 type ModelDef struct {
-	Provider       string         `yaml:"provider"`
-	Upstream       string         `yaml:"upstream"`
-	Fallbacks      []FallbackDef  `yaml:"fallbacks,omitempty"`
-	Premium        bool           `yaml:"premium"`
-	Hidden         bool           `yaml:"hidden"`
-	OwnedBy        string         `yaml:"owned_by"`
-	IdentityPrompt string         `yaml:"identity_prompt"`
-	AliasOf        string         `yaml:"alias_of"`
-	AliasPricing   string         `yaml:"alias_pricing"`
-	PricingOnly    bool           `yaml:"pricing_only"`
-	Pricing        *ModelPriceDef `yaml:"pricing,omitempty"`
+	Provider        string            `yaml:"provider"`
+	Upstream        string            `yaml:"upstream"`
+	Fallbacks       []FallbackDef     `yaml:"fallbacks,omitempty"`
+	Weight          int               `yaml:"weight,omitempty"` // primary's share when fallbacks are weighted; default 1
+	Premium         bool              `yaml:"premium"`
+	Hidden          bool              `yaml:"hidden"`
+	OwnedBy         string            `yaml:"owned_by"`
+	IdentityPrompt  string            `yaml:"identity_prompt"` // literal prompt; takes precedence over identity
+	Identity        *ModelIdentityDef `yaml:"identity,omitempty"`
+	AliasOf         string            `yaml:"alias_of"`
+	AliasPricing    string            `yaml:"alias_pricing"`
+	PricingOnly     bool              `yaml:"pricing_only"`
+	Pricing         *ModelPriceDef    `yaml:"pricing,omitempty"`
+	Deprecated      string            `yaml:"deprecated,omitempty"`  // deprecation notice, e.g. "retired 2026-12-31, use zen4"
+	Entitlement     string            `yaml:"entitlement,omitempty"` // org entitlement required to list the model, e.g. "enterprise"
+	Timeouts        *ModelTimeoutsDef `yaml:"timeouts,omitempty"`
+	ContextWindow   int               `yaml:"context_window,omitempty"`    // tokens, shown in the model's details
+	MaxOutputTokens int               `yaml:"max_output_tokens,omitempty"` // tokens, shown in the model's details
+	Capabilities    []string          `yaml:"capabilities,omitempty"`      // see modelCapabilities
+	Shadow          *ModelShadowDef   `yaml:"shadow,omitempty"`
+	ThinkTags       string            `yaml:"think_tags,omitempty"` // "passthrough", "strip" or "reasoning"; see think_tags.go
+}
+
+// ModelTimeoutsDef bounds the upstream calls of a model with durations such
+// as "30s"; see route_timeouts.go.
+type ModelTimeoutsDef struct {
+	Connect    string `yaml:"connect,omitempty"`
+	FirstToken string `yaml:"first_token,omitempty"`
+	Total      string `yaml:"total,omitempty"`
+}
+
+// ModelShadowDef mirrors a sample of a model's requests to a candidate
+// upstream for comparison; see shadow_traffic.go.
+type ModelShadowDef struct {
+	Provider string  `yaml:"provider"`
+	Upstream string  `yaml:"upstream"`
+	Percent  float64 `yaml:"percent"` // share of requests mirrored, in (0, 100]
+}
+
+// toRouteShadow returns nil when no shadow is configured.
+func (def *ModelShadowDef) toRouteShadow() *routeShadow {
+	if def == nil {
+		return nil
+	}
+	return &routeShadow{providerName: def.Provider, upstreamModel: def.Upstream, percent: def.Percent}
+}
+
+// toRouteTimeouts parses the durations; validateModelConfig rejects invalid
+// ones, which are left unbounded here.
+func (def *ModelTimeoutsDef) toRouteTimeouts() routeTimeouts {
+	t := routeTimeouts{}
+	if def == nil {
+		return t
+	}
+	for _, field := range []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{def.Connect, &t.connect},
+		{def.FirstToken, &t.firstToken},
+		{def.Total, &t.total},
+	} {
+		if d, err := time.ParseDuration(field.value); err == nil && d > 0 {
+			*field.dest = d
+		}
+	}
+	return t
 }
 
 // ── Singleton ───────────────────────────────────────────────────────────
 
 var (
 	globalModelConfig *ModelConfig
+	staticModelConfig *ModelConfig
 	configOnce        sync.Once
 )
 
 // ModelConfig is the runtime singleton that serves model routing, pricing,
 // and identity prompts from a parsed YAML config file.
 type ModelConfig struct {
-	mu       sync.RWMutex
-	routes   map[string]modelRoute // lowercase key → route
-	pricing  map[string]modelPrice // lowercase key → price
-	prompts  map[string]string     // lowercase key → identity prompt
-	features FeatureFlags
-	defaults modelPrice
+	mu         sync.RWMutex
+	routes     map[string]modelRoute        // lowercase key → route
+	pricing    map[string]modelPrice        // lowercase key → price
+	costs      map[upstreamRef]modelPrice   // upstream model → provider's cost, see pricing_sync.go
+	servedBy   map[string]upstreamRef       // lowercase key → upstream a priced model is served from
+	prompts    map[string]string            // lowercase key → identity prompt
+	orgPrompts map[string]map[string]string // org → lowercase key → branded identity prompt
+	orgModels  map[string]map[string]string // org → lowercase org-facing name → lowercase zen model
+	notices    map[string]string            // lowercase key → deprecation notice
+	features   FeatureFlags
+	defaults   modelPrice
+	static     bool // built from the compiled-in tables, not a config file
 
 	// Live refresh state
-	configPath    string
+	configPath    string // source: file, directory or URL, see model_config_source.go
+	configSum     string // checksum of the applied source content
+	configTTL     time.Duration
 	pricingURL    string
 	pricingTTL    time.Duration
 	lastPricingAt time.Time
-	stopCh        chan struct{}
+	pricingFrozen bool // set by FreezeLivePricing; survives reloads
+
+	// Background refresh lifecycle, see model_config_live.go
+	lifeMu    sync.Mutex
+	ctx       context.Context // cancelled by Stop
+	cancel    context.CancelFunc
+	running   bool          // backgroundRefresh has started
+	stopped   bool          // Stop was called; nothing new starts
+	refreshCh chan struct{} // pending live pricing request, buffered 1
+	wg        sync.WaitGroup
+
+	// Applied generations, oldest first, see model_config_history.go
+	history    []*modelConfigGeneration
+	generation int
+	appliedAt  int64 // Unix time the current generation was applied, the created time of listed models
 }
 
-// InitModelConfig loads the YAML config and optionally starts a background
-// refresh goroutine (when live_mode is true). Returns an error if the file
+// InitModelConfig loads the YAML config from path, which may be any source
+// fetchModelConfig accepts, and optionally starts a background refresh
+// goroutine (when live_mode is true). Returns an error if the source
 // cannot be read or parsed. This is non-fatal — the caller can log and fall
-// back to static maps.
+// back to static maps. Differences from the static maps are logged as
+// warnings, since they mean the compiled-in fallback has gone stale.
 func InitModelConfig(path string) error {
 	mc := &ModelConfig{
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
+		notices: make(map[string]string),
 	}
 
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		return err
 	}
 
 	mc.configPath = path
+	for _, warning := range mc.divergence(getStaticModelConfig()) {
+		logs.Warn("Model config: %s", warning)
+	}
 	globalModelConfig = mc
 
 	if mc.features.LiveMode {
-		go mc.backgroundRefresh()
+		mc.startBackgroundRefresh()
 	}
 
 	return nil
 }
 
-// GetModelConfig returns the singleton. Returns nil if not initialized.
+// GetModelConfig returns the singleton. It is never nil: until a YAML config
+// is loaded, it serves the compiled-in tables of model_routes.go and
+// model_pricing.go. All model routing, pricing and prompt lookups go
+// through it, so the YAML file is authoritative once loaded.
 func GetModelConfig() *ModelConfig {
-	return globalModelConfig
+	if globalModelConfig != nil {
+		return globalModelConfig
+	}
+	return getStaticModelConfig()
+}
+
+// getStaticModelConfig returns the fallback config built from the static
+// modelRoutes, modelPricing, aliasPricing and zenIdentityPrompts maps.
+func getStaticModelConfig() *ModelConfig {
+	configOnce.Do(func() {
+		pricing := make(map[string]modelPrice, len(modelPricing)+len(aliasPricing))
+		for name, price := range modelPricing {
+			pricing[strings.ToLower(name)] = price
+		}
+		for alias, base := range aliasPricing {
+			if price, ok := modelPricing[base]; ok {
+				pricing[strings.ToLower(alias)] = price
+			}
+		}
+
+		routes := make(map[string]modelRoute, len(modelRoutes))
+		servedBy := make(map[string]upstreamRef, len(modelRoutes))
+		for name, route := range modelRoutes {
+			routes[strings.ToLower(name)] = route
+			if _, ok := pricing[strings.ToLower(name)]; ok {
+				servedBy[strings.ToLower(name)] = upstreamRef{provider: route.providerName, upstream: route.upstreamModel}
+			}
+		}
+		costs := make(map[upstreamRef]modelPrice, len(upstreamCosts))
+		for ref, cost := range upstreamCosts {
+			costs[ref] = cost
+		}
+		prompts := make(map[string]string, len(zenIdentityPrompts))
+		for name, prompt := range zenIdentityPrompts {
+			prompts[strings.ToLower(name)] = prompt
+		}
+
+		staticModelConfig = &ModelConfig{
+			routes:    routes,
+			pricing:   pricing,
+			costs:     costs,
+			servedBy:  servedBy,
+			prompts:   prompts,
+			notices:   map[string]string{},
+			defaults:  modelPrice{InputPerMillion: 1.00, OutputPerMillion: 4.00},
+			static:    true,
+			appliedAt: time.Now().Unix(),
+		}
+	})
+	return staticModelConfig
+}
+
+// divergence lists where mc disagrees with the fallback tables: models the
+// fallback routes that mc does not, and routes, prices or identity prompts
+// that differ. Models only mc knows are expected and not reported.
+func (mc *ModelConfig) divergence(fallback *ModelConfig) []string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	fallback.mu.RLock()
+	defer fallback.mu.RUnlock()
+
+	warnings := []string{}
+	for name, want := range fallback.routes {
+		got, ok := mc.routes[name]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("model %s has a static route but no config entry", name))
+			continue
+		}
+		if got.providerName != want.providerName || got.upstreamModel != want.upstreamModel {
+			warnings = append(warnings, fmt.Sprintf("model %s routes to %s/%s, static table has %s/%s",
+				name, got.providerName, got.upstreamModel, want.providerName, want.upstreamModel))
+		}
+	}
+	for name, want := range fallback.pricing {
+		if got, ok := mc.pricing[name]; ok && (got.InputPerMillion != want.InputPerMillion || got.OutputPerMillion != want.OutputPerMillion) {
+			warnings = append(warnings, fmt.Sprintf("model %s is priced $%g/$%g per million, static table has $%g/$%g",
+				name, got.InputPerMillion, got.OutputPerMillion, want.InputPerMillion, want.OutputPerMillion))
+		}
+	}
+	for ref, want := range fallback.costs {
+		if got, ok := mc.costs[ref]; ok && (got.InputPerMillion != want.InputPerMillion || got.OutputPerMillion != want.OutputPerMillion) {
+			warnings = append(warnings, fmt.Sprintf("upstream %s/%s costs $%g/$%g per million, static table has $%g/$%g",
+				ref.provider, ref.upstream, got.InputPerMillion, got.OutputPerMillion, want.InputPerMillion, want.OutputPerMillion))
+		}
+	}
+	for name, want := range fallback.prompts {
+		if got, ok := mc.prompts[name]; !ok {
+			warnings = append(warnings, fmt.Sprintf("model %s has a static identity prompt but none in config", name))
+		} else if got != strings.TrimSpace(want) {
+			warnings = append(warnings, fmt.Sprintf("model %s identity prompt differs from the static table", name))
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
 }
 
 // ── Loading ─────────────────────────────────────────────────────────────
 
-func (mc *ModelConfig) loadFromFile(path string) error {
-	data, err := os.ReadFile(path)
+func (mc *ModelConfig) loadFromSource(source string) error {
+	data, err := fetchModelConfig(source)
 	if err != nil {
-		return fmt.Errorf("model config: read %s: %w", path, err)
+		return err
 	}
+	return mc.loadData(source, data)
+}
 
+// loadData applies config content already fetched from source.
+func (mc *ModelConfig) loadData(source string, data []byte) error {
 	var file ModelConfigFile
 	if err := yaml.Unmarshal(data, &file); err != nil {
-		return fmt.Errorf("model config: parse %s: %w", path, err)
+		return fmt.Errorf("model config: parse %s: %w", source, err)
 	}
 
-	return mc.applyConfig(&file)
+	if err := mc.applyConfig(&file); err != nil {
+		return err
+	}
+	mc.mu.Lock()
+	mc.configSum = modelConfigChecksum(data)
+	mc.mu.Unlock()
+	mc.recordGeneration(source, data, 0)
+	return nil
 }
 
 func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
+	next, err := buildModelConfig(file)
+	if err != nil {
+		return err
+	}
+
+	// Apply under write lock
+	mc.mu.Lock()
+	mc.routes = next.routes
+	mc.pricing = next.pricing
+	mc.costs = next.costs
+	mc.servedBy = next.servedBy
+	mc.prompts = next.prompts
+	mc.orgPrompts = next.orgPrompts
+	mc.orgModels = next.orgModels
+	mc.notices = next.notices
+	mc.features = next.features
+	mc.defaults = next.defaults
+	mc.pricingURL = next.pricingURL
+	mc.pricingTTL = next.pricingTTL
+	mc.configTTL = next.configTTL
+	mc.mu.Unlock()
+
+	logs.Info("Model config loaded: %d routes, %d pricing entries, %d identity prompts",
+		len(next.routes), len(next.pricing), len(next.prompts))
+	mc.loadApprovedUpstreamCosts()
+
+	return nil
+}
+
+// buildModelConfig builds the lookup tables of a parsed config file without
+// installing them, so a candidate config can be inspected before a reload.
+func buildModelConfig(file *ModelConfigFile) (*ModelConfig, error) {
 	routes := make(map[string]modelRoute, len(file.Models))
 	pricing := make(map[string]modelPrice, len(file.Models))
-	prompts := make(map[string]string)
+	prompts, orgPrompts, err := renderIdentityPrompts(file)
+	if err != nil {
+		return nil, fmt.Errorf("model config: %w", err)
+	}
+	notices := make(map[string]string)
+	servedBy := make(map[string]upstreamRef)
+	costs := make(map[upstreamRef]modelPrice)
+	for provider, upstreams := range file.UpstreamCosts {
+		for upstream, def := range upstreams {
+			costs[upstreamRef{provider: provider, upstream: upstream}] = def.toModelPrice()
+		}
+	}
 
 	// Build alias pricing map for resolution
 	aliasPricingMap := make(map[string]string)
@@ -179,16 +461,25 @@ func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
 		// Build route (skip pricing-only entries)
 		if !def.PricingOnly {
 			r := modelRoute{
-				providerName:  def.Provider,
-				upstreamModel: def.Upstream,
-				premium:       def.Premium,
-				hidden:        def.Hidden,
-				ownedBy:       def.OwnedBy,
+				providerName:    def.Provider,
+				upstreamModel:   def.Upstream,
+				premium:         def.Premium,
+				hidden:          def.Hidden,
+				ownedBy:         def.OwnedBy,
+				entitlement:     def.Entitlement,
+				weight:          def.Weight,
+				timeouts:        def.Timeouts.toRouteTimeouts(),
+				contextWindow:   def.ContextWindow,
+				maxOutputTokens: def.MaxOutputTokens,
+				capabilities:    normalizeModelCapabilities(def.Capabilities),
+				shadow:          def.Shadow.toRouteShadow(),
+				thinkTags:       def.ThinkTags,
 			}
 			for _, fb := range def.Fallbacks {
 				r.fallbacks = append(r.fallbacks, modelRouteFallback{
 					providerName:  fb.Provider,
 					upstreamModel: fb.Upstream,
+					weight:        fb.Weight,
 				})
 			}
 			routes[key] = r
@@ -196,19 +487,10 @@ func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
 
 		// Build pricing
 		if def.Pricing != nil {
-			p := modelPrice{}
-			// Support both {input, output} and {input_per_million, output_per_million}
-			if def.Pricing.Input > 0 {
-				p.InputPerMillion = def.Pricing.Input
-			} else {
-				p.InputPerMillion = def.Pricing.InputPerMillion
+			pricing[key] = def.Pricing.toModelPrice()
+			if def.Provider != "" && def.Upstream != "" {
+				servedBy[key] = upstreamRef{provider: def.Provider, upstream: def.Upstream}
 			}
-			if def.Pricing.Output > 0 {
-				p.OutputPerMillion = def.Pricing.Output
-			} else {
-				p.OutputPerMillion = def.Pricing.OutputPerMillion
-			}
-			pricing[key] = p
 		}
 
 		// Track alias pricing for second-pass resolution
@@ -216,9 +498,9 @@ func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
 			aliasPricingMap[key] = strings.ToLower(def.AliasPricing)
 		}
 
-		// Identity prompts
-		if def.IdentityPrompt != "" {
-			prompts[key] = strings.TrimSpace(def.IdentityPrompt)
+		// Deprecation notices
+		if def.Deprecated != "" {
+			notices[key] = strings.TrimSpace(def.Deprecated)
 		}
 	}
 
@@ -243,6 +525,12 @@ func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
 			pricingTTL = d
 		}
 	}
+	configTTL := defaultModelConfigTTL
+	if file.Cache.ConfigTTL != "" {
+		if d, err := time.ParseDuration(file.Cache.ConfigTTL); err == nil && d > 0 {
+			configTTL = d
+		}
+	}
 
 	// Default pricing
 	defaults := modelPrice{InputPerMillion: 1.00, OutputPerMillion: 4.00}
@@ -253,26 +541,36 @@ func (mc *ModelConfig) applyConfig(file *ModelConfigFile) error {
 		defaults.OutputPerMillion = file.DefaultPricing.OutputPerMillion
 	}
 
-	// Apply under write lock
-	mc.mu.Lock()
-	mc.routes = routes
-	mc.pricing = pricing
-	mc.prompts = prompts
-	mc.features = file.Features
-	mc.defaults = defaults
-	mc.pricingURL = pricingURL
-	mc.pricingTTL = pricingTTL
-	mc.mu.Unlock()
-
-	logs.Info("Model config loaded: %d routes, %d pricing entries, %d identity prompts",
-		len(routes), len(pricing), len(prompts))
-
-	return nil
+	return &ModelConfig{
+		routes:     routes,
+		pricing:    pricing,
+		costs:      costs,
+		servedBy:   servedBy,
+		prompts:    prompts,
+		notices:    notices,
+		features:   file.Features,
+		defaults:   defaults,
+		pricingURL: pricingURL,
+		pricingTTL: pricingTTL,
+		configTTL:  configTTL,
+		orgPrompts: orgPrompts,
+		orgModels:  brandedModelNames(file.Identity),
+	}, nil
 }
 
-// Reload re-reads the config file and triggers a live pricing fetch if enabled.
+// Reload re-reads the config source and requests a live pricing fetch if enabled.
 func (mc *ModelConfig) Reload() error {
-	if err := mc.loadFromFile(mc.configPath); err != nil {
+	data, err := fetchModelConfig(mc.configPath)
+	if err != nil {
+		return err
+	}
+	return mc.reloadData(data)
+}
+
+// reloadData applies config content fetched from the config source and
+// triggers a live pricing fetch if enabled.
+func (mc *ModelConfig) reloadData(data []byte) error {
+	if err := mc.loadData(mc.configPath, data); err != nil {
 		return err
 	}
 
@@ -281,7 +579,7 @@ func (mc *ModelConfig) Reload() error {
 	mc.mu.RUnlock()
 
 	if live {
-		go mc.fetchLivePricing()
+		mc.requestLivePricing()
 	}
 
 	return nil
@@ -314,28 +612,41 @@ func (mc *ModelConfig) GetPrice(model string) modelPrice {
 	return mc.defaults
 }
 
+// GetDeprecation returns the deprecation notice of a model, or "" when the
+// model is not deprecated.
+func (mc *ModelConfig) GetDeprecation(model string) string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.notices[strings.ToLower(model)]
+}
+
 // GetIdentityPrompt returns the identity system prompt for a zen model.
 // Falls back through version aliases (zen-mini → zen4-mini → zen3-mini)
 // and a generic zen catch-all.
 func (mc *ModelConfig) GetIdentityPrompt(model string) string {
+	return mc.GetIdentityPromptForOrg(model, "")
+}
+
+// GetIdentityPromptForOrg returns the identity prompt of a zen model with
+// org's branding, or the default prompt when org has no branding.
+func (mc *ModelConfig) GetIdentityPromptForOrg(model string, org string) string {
 	key := strings.ToLower(model)
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
 
-	if prompt, ok := mc.prompts[key]; ok {
+	if prompt, ok := findIdentityPrompt(mc.orgPrompts[org], key); ok {
 		return prompt
 	}
-
-	// Try stripping version prefix for versionless aliases
-	if strings.HasPrefix(key, "zen-") {
-		suffix := key[4:]
-		if prompt, ok := mc.prompts["zen4-"+suffix]; ok {
-			return prompt
-		}
-		if prompt, ok := mc.prompts["zen3-"+suffix]; ok {
+	// An org's own name for a zen model gets that model's prompt.
+	if target, ok := mc.orgModels[org][key]; ok {
+		key = target
+		if prompt, ok := findIdentityPrompt(mc.orgPrompts[org], key); ok {
 			return prompt
 		}
 	}
+	if prompt, ok := findIdentityPrompt(mc.prompts, key); ok {
+		return prompt
+	}
 
 	// Generic zen fallback
 	if strings.HasPrefix(key, "zen") {
@@ -345,9 +656,40 @@ func (mc *ModelConfig) GetIdentityPrompt(model string) string {
 	return ""
 }
 
-// ListModels returns visible models sorted by name (excludes hidden).
+// ResolveBrandedModel returns the zen model an organization's own model name
+// stands for, or model itself when org has no such name.
+func (mc *ModelConfig) ResolveBrandedModel(model string, org string) string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if target, ok := mc.orgModels[org][strings.ToLower(model)]; ok {
+		return target
+	}
+	return model
+}
+
+// BrandedModels returns an organization's own model names mapped to the zen
+// models they stand for.
+func (mc *ModelConfig) BrandedModels(org string) map[string]string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	names := make(map[string]string, len(mc.orgModels[org]))
+	for name, target := range mc.orgModels[org] {
+		names[name] = target
+	}
+	return names
+}
+
+// ListModels returns the public catalog: visible models sorted by name
+// (excludes hidden and entitlement-gated models).
 func (mc *ModelConfig) ListModels() []modelInfo {
-	now := time.Now().Unix()
+	return mc.ListModelsForEntitlements(nil)
+}
+
+// ListModelsForEntitlements returns visible models sorted by name, including
+// the entitlement-gated models whose entitlement is in entitlements.
+func (mc *ModelConfig) ListModelsForEntitlements(entitlements map[string]bool) []modelInfo {
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
 
@@ -356,6 +698,9 @@ func (mc *ModelConfig) ListModels() []modelInfo {
 		if route.hidden {
 			continue
 		}
+		if route.entitlement != "" && !entitlements[route.entitlement] {
+			continue
+		}
 		owner := route.ownedBy
 		if owner == "" {
 			owner = route.providerName
@@ -363,7 +708,7 @@ func (mc *ModelConfig) ListModels() []modelInfo {
 		models = append(models, modelInfo{
 			ID:      name,
 			Object:  "model",
-			Created: now,
+			Created: mc.appliedAt,
 			OwnedBy: owner,
 			Premium: route.premium,
 		})
@@ -395,6 +740,15 @@ func (mc *ModelConfig) ListModelsWithUpstream() []zapModelEntry {
 	return models
 }
 
+// ProviderNames returns the distinct providers referenced by the routes,
+// including fallbacks.
+func (mc *ModelConfig) ProviderNames() []string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	return routeProviderNames(mc.routes)
+}
+
 // StarterCreditDollars returns the configured starter credit amount.
 func (mc *ModelConfig) StarterCreditDollars() float64 {
 	mc.mu.RLock()
@@ -403,7 +757,7 @@ func (mc *ModelConfig) StarterCreditDollars() float64 {
 	if mc.features.StarterCredit > 0 {
 		return mc.features.StarterCredit
 	}
-	return 5.00
+	return StarterCreditDollars
 }
 
 // PremiumGateEnabled returns whether the premium gate feature is active.
@@ -413,8 +767,50 @@ func (mc *ModelConfig) PremiumGateEnabled() bool {
 	return mc.features.PremiumGate
 }
 
+// RateLimits returns the rate limits configured under features.rate_limits.
+func (mc *ModelConfig) RateLimits() RateLimitDefs {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.features.RateLimits
+}
+
+// IdentityLeak returns the identity leak scan configured under
+// features.identity_leak.
+func (mc *ModelConfig) IdentityLeak() IdentityLeakDefs {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.features.IdentityLeak
+}
+
 // ── Admin endpoint ──────────────────────────────────────────────────────
 
+// modelConfigSummary is what the admin audit trail records of a reload.
+type modelConfigSummary struct {
+	Generation  int          `json:"generation,omitempty"`
+	Routes      int          `json:"routes"`
+	Prices      int          `json:"prices"`
+	Features    FeatureFlags `json:"features"`
+	LastPricing string       `json:"lastPricing,omitempty"`
+	Frozen      bool         `json:"frozen,omitempty"`
+}
+
+func (mc *ModelConfig) auditSummary() *modelConfigSummary {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	summary := &modelConfigSummary{
+		Generation: mc.generation,
+		Routes:     len(mc.routes),
+		Prices:     len(mc.pricing),
+		Features:   mc.features,
+		Frozen:     mc.pricingFrozen,
+	}
+	if !mc.lastPricingAt.IsZero() {
+		summary.LastPricing = mc.lastPricingAt.UTC().Format(time.RFC3339)
+	}
+	return summary
+}
+
 // ReloadModelConfig handles POST /api/reload-model-config.
 // @Title ReloadModelConfig
 // @Tag Admin
@@ -423,15 +819,17 @@ func (mc *ModelConfig) PremiumGateEnabled() bool {
 // @router /reload-model-config [post]
 func (c *ApiController) ReloadModelConfig() {
 	cfg := GetModelConfig()
-	if cfg == nil {
+	if cfg.static {
 		c.ResponseError("model config not initialized")
 		return
 	}
 
+	before := cfg.auditSummary()
 	if err := cfg.Reload(); err != nil {
 		c.ResponseError(fmt.Sprintf("reload failed: %s", err.Error()))
 		return
 	}
+	c.recordAdminAudit("reload", "model-config", "admin", cfg.configPath, before, cfg.auditSummary())
 
 	c.ResponseOk()
 }
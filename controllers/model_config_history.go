@@ -0,0 +1,200 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultModelConfigHistorySize is how many loaded config generations are
+// kept when modelConfigHistorySize is unset.
+const defaultModelConfigHistorySize = 10
+
+// modelConfigGeneration is one applied config: every load, reload, live
+// refresh and rollback adds a generation.
+type modelConfigGeneration struct {
+	Generation     int    `json:"generation"`
+	Checksum       string `json:"checksum"`
+	Source         string `json:"source"`
+	LoadedAt       string `json:"loadedAt"`
+	Routes         int    `json:"routes"`
+	Prices         int    `json:"prices"`
+	RolledBackFrom int    `json:"rolledBackFrom,omitempty"` // generation whose content was restored
+	Active         bool   `json:"active"`
+
+	data []byte
+}
+
+func getModelConfigHistorySize() int {
+	if size := conf.GetConfigInt("modelConfigHistorySize"); size > 0 {
+		return size
+	}
+	return defaultModelConfigHistorySize
+}
+
+// recordGeneration adds the config just applied from data to the history,
+// dropping the oldest generations beyond modelConfigHistorySize.
+func (mc *ModelConfig) recordGeneration(source string, data []byte, rolledBackFrom int) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	now := time.Now()
+	mc.generation++
+	mc.appliedAt = now.Unix()
+	mc.history = append(mc.history, &modelConfigGeneration{
+		Generation:     mc.generation,
+		Checksum:       modelConfigChecksum(data),
+		Source:         source,
+		LoadedAt:       now.UTC().Format(time.RFC3339),
+		Routes:         len(mc.routes),
+		Prices:         len(mc.pricing),
+		RolledBackFrom: rolledBackFrom,
+		data:           data,
+	})
+	if excess := len(mc.history) - getModelConfigHistorySize(); excess > 0 {
+		mc.history = mc.history[excess:]
+	}
+}
+
+// catalogVersion identifies the model catalog of the current generation:
+// the checksum of its content, or the time the compiled-in tables were
+// loaded for the static config.
+func (mc *ModelConfig) catalogVersion() string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	if len(mc.history) == 0 {
+		return fmt.Sprintf("static-%d", mc.appliedAt)
+	}
+	return mc.history[len(mc.history)-1].Checksum
+}
+
+// Generation returns the generation of the applied config, 0 for the
+// compiled-in tables.
+func (mc *ModelConfig) Generation() int {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.generation
+}
+
+// Generations returns the kept config generations, newest first.
+func (mc *ModelConfig) Generations() []modelConfigGeneration {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	generations := make([]modelConfigGeneration, 0, len(mc.history))
+	for i := len(mc.history) - 1; i >= 0; i-- {
+		generation := *mc.history[i]
+		generation.Active = generation.Generation == mc.generation
+		generations = append(generations, generation)
+	}
+	return generations
+}
+
+// Rollback re-applies the content of a kept generation as a new generation.
+// The tables are swapped under the write lock, so requests see either the
+// old or the restored config. The source checksum is left alone: live
+// refresh will not undo the rollback until the source itself changes.
+func (mc *ModelConfig) Rollback(generation int) error {
+	mc.mu.RLock()
+	var target *modelConfigGeneration
+	for _, g := range mc.history {
+		if g.Generation == generation {
+			target = g
+		}
+	}
+	current := mc.generation
+	live := mc.features.LiveMode
+	mc.mu.RUnlock()
+
+	if target == nil {
+		return fmt.Errorf("generation %d is not in the history", generation)
+	}
+	if generation == current {
+		return fmt.Errorf("generation %d is already active", generation)
+	}
+
+	var file ModelConfigFile
+	if err := yaml.Unmarshal(target.data, &file); err != nil {
+		return fmt.Errorf("model config: parse generation %d: %w", generation, err)
+	}
+	if err := mc.applyConfig(&file); err != nil {
+		return err
+	}
+	mc.recordGeneration(target.Source, target.data, generation)
+
+	if live {
+		mc.requestLivePricing()
+	}
+	return nil
+}
+
+// GetModelConfigGenerations
+// @Title GetModelConfigGenerations
+// @Tag Admin
+// @Description List the kept model config generations, newest first.
+// @Success 200 {object} controllers.Response
+// @router /get-model-config-generations [get]
+func (c *ApiController) GetModelConfigGenerations() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	cfg := GetModelConfig()
+	if cfg.static {
+		c.ResponseError("model config not initialized")
+		return
+	}
+
+	c.ResponseOk(cfg.Generations())
+}
+
+// RollbackModelConfig
+// @Title RollbackModelConfig
+// @Tag Admin
+// @Description Atomically revert the model config to a kept generation.
+// @Param   generation    query    int    true    "generation to restore"
+// @Success 200 {object} controllers.Response
+// @router /rollback-model-config [post]
+func (c *ApiController) RollbackModelConfig() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	cfg := GetModelConfig()
+	if cfg.static {
+		c.ResponseError("model config not initialized")
+		return
+	}
+
+	generation, err := strconv.Atoi(c.Input().Get("generation"))
+	if err != nil {
+		c.ResponseError("generation must be a number")
+		return
+	}
+
+	before := cfg.auditSummary()
+	if err = cfg.Rollback(generation); err != nil {
+		c.ResponseError(fmt.Sprintf("rollback failed: %s", err.Error()))
+		return
+	}
+	c.recordAdminAudit("rollback", "model-config", "admin", cfg.configPath, before, cfg.auditSummary())
+
+	c.ResponseOk(cfg.Generations())
+}
@@ -15,40 +15,128 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
 )
 
+// modelConfigShutdownTimeout bounds how long Shutdown waits for an
+// in-flight pricing or config fetch to return.
+const modelConfigShutdownTimeout = 10 * time.Second
+
+// startBackgroundRefresh starts the backgroundRefresh goroutine. It reports
+// whether it started one: it is a no-op once running or after Stop.
+func (mc *ModelConfig) startBackgroundRefresh() bool {
+	mc.lifeMu.Lock()
+	defer mc.lifeMu.Unlock()
+
+	if mc.running || mc.stopped {
+		return false
+	}
+	mc.ctx, mc.cancel = context.WithCancel(context.Background())
+	mc.refreshCh = make(chan struct{}, 1)
+	mc.running = true
+
+	mc.wg.Add(1)
+	go func() {
+		defer mc.wg.Done()
+		mc.backgroundRefresh(mc.ctx, mc.refreshCh)
+	}()
+	return true
+}
+
+// requestLivePricing asks the background goroutine for a live pricing
+// fetch, starting it if needed. Requests made while a fetch is running
+// coalesce into one follow-up fetch, so fetches never overlap.
+func (mc *ModelConfig) requestLivePricing() {
+	if mc.startBackgroundRefresh() {
+		return // the new goroutine fetches immediately
+	}
+
+	mc.lifeMu.Lock()
+	defer mc.lifeMu.Unlock()
+	if mc.stopped {
+		return
+	}
+	select {
+	case mc.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
 // backgroundRefresh is a long-running goroutine that periodically refreshes
-// pricing data from pricing.hanzo.ai. It runs only when live_mode is true.
-func (mc *ModelConfig) backgroundRefresh() {
+// pricing data from pricing.hanzo.ai and re-fetches the config source. It
+// runs only when live_mode is true, until ctx is cancelled.
+func (mc *ModelConfig) backgroundRefresh(ctx context.Context, refreshCh <-chan struct{}) {
 	mc.mu.RLock()
 	ttl := mc.pricingTTL
+	configTTL := mc.configTTL
 	mc.mu.RUnlock()
 
 	if ttl <= 0 {
 		ttl = 6 * time.Hour
 	}
+	if configTTL <= 0 {
+		configTTL = defaultModelConfigTTL
+	}
 
 	// Do an initial fetch immediately
-	mc.fetchLivePricing()
+	mc.fetchLivePricing(ctx)
 
 	ticker := time.NewTicker(ttl)
 	defer ticker.Stop()
+	configTicker := time.NewTicker(configTTL)
+	defer configTicker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			mc.fetchLivePricing()
-		case <-mc.stopCh:
+			mc.fetchLivePricing(ctx)
+		case <-refreshCh:
+			mc.fetchLivePricing(ctx)
+		case <-configTicker.C:
+			mc.refreshConfig(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshConfig re-fetches the config source and applies it when its
+// content changed, so deployments sharing one config artifact converge
+// without a manual reload. Unchanged content is skipped to keep the live
+// pricing merged into the current tables.
+func (mc *ModelConfig) refreshConfig(ctx context.Context) {
+	data, err := fetchModelConfigContext(ctx, mc.configPath)
+	if err != nil {
+		logs.Warn("Model config refresh failed: %v", err)
+		return
+	}
+
+	mc.mu.RLock()
+	unchanged := modelConfigChecksum(data) == mc.configSum
+	mc.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := mc.reloadData(data); err != nil {
+		if ctx.Err() != nil {
 			return
 		}
+		logs.Warn("Model config refresh failed: %v", err)
+		return
 	}
+	logs.Info("Model config refreshed from %s", mc.configPath)
 }
 
 // livePricingResponse is the expected response from pricing.hanzo.ai.
@@ -66,10 +154,14 @@ type livePricingEntry struct {
 	Output float64 `json:"output"`
 }
 
+// defaultMaxPriceChange is the largest change, in percent, a live refresh
+// may make to a known price when features.max_price_change is unset.
+const defaultMaxPriceChange = 50.0
+
 // fetchLivePricing fetches current pricing from the pricing service and
-// merges it into the runtime config. Only overwrites pricing for models
-// that exist in the response — never removes existing entries.
-func (mc *ModelConfig) fetchLivePricing() {
+// merges it into the runtime config, recording the outcome in the live
+// pricing metrics. It does nothing while pricing is frozen.
+func (mc *ModelConfig) fetchLivePricing(ctx context.Context) {
 	mc.mu.RLock()
 	url := mc.pricingURL
 	mc.mu.RUnlock()
@@ -77,50 +169,189 @@ func (mc *ModelConfig) fetchLivePricing() {
 	if url == "" {
 		return
 	}
+	if mc.PricingFrozen() {
+		object.LivePricingFetches.WithLabelValues("frozen").Inc()
+		return
+	}
 
+	result, err := mc.refreshLivePricing(ctx, url)
+	switch {
+	case ctx.Err() != nil:
+		return // shutting down
+	case err != nil:
+		logs.Warn("Live pricing refresh failed: %v", err)
+		object.LivePricingFetches.WithLabelValues("error").Inc()
+	case result == nil:
+		logs.Info("Live pricing: no models in response, keeping current data")
+		object.LivePricingFetches.WithLabelValues("empty").Inc()
+	default:
+		object.LivePricingFetches.WithLabelValues("ok").Inc()
+		object.LivePricingLastSuccess.SetToCurrentTime()
+	}
+	mc.updatePricingStaleness()
+}
+
+// refreshLivePricing fetches and applies live pricing from the pricing
+// service at url. It returns a nil
+// result when the service has no models, which keeps the current prices.
+func (mc *ModelConfig) refreshLivePricing(ctx context.Context, url string) (*livePricingResult, error) {
 	url = strings.TrimRight(url, "/") + "/v1/pricing/models"
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
-		logs.Warn("Live pricing fetch failed: %v", err)
-		return
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		logs.Warn("Live pricing returned status %d", resp.StatusCode)
-		return
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
 	}
 
-	var result livePricingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		logs.Warn("Live pricing parse failed: %v", err)
-		return
+	var response livePricingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", url, err)
 	}
 
-	if len(result.Models) == 0 {
-		logs.Info("Live pricing: no models in response, keeping current data")
-		return
+	if len(response.Models) == 0 {
+		return nil, nil
+	}
+
+	result := mc.applyLivePricing(response.Models)
+	for _, rejected := range result.Rejected {
+		logs.Warn("Live pricing: %s", rejected)
 	}
+	logs.Info("Live pricing refreshed: %d models updated, %d rejected from %s", result.Updated, len(result.Rejected), url)
+	return result, nil
+}
 
-	// Merge live pricing into existing map
+// livePricingResult is the outcome of merging one live pricing response.
+type livePricingResult struct {
+	Updated  int
+	Rejected []string // one reason per rejected model
+}
+
+// applyLivePricing merges live prices into the runtime config. Only models
+// in the response are overwritten — existing entries are never removed —
+// and a price moving more than max_price_change percent from the current
+// one is rejected as anomalous, keeping the current price.
+func (mc *ModelConfig) applyLivePricing(models []livePricingModel) *livePricingResult {
 	mc.mu.Lock()
-	updated := 0
-	for _, m := range result.Models {
+	defer mc.mu.Unlock()
+
+	maxChange := mc.features.MaxPriceChange
+	if maxChange <= 0 {
+		maxChange = defaultMaxPriceChange
+	}
+
+	result := &livePricingResult{}
+	for _, m := range models {
 		key := strings.ToLower(m.Name)
-		if m.Pricing.Input > 0 || m.Pricing.Output > 0 {
-			mc.pricing[key] = modelPrice{
-				InputPerMillion:  m.Pricing.Input,
-				OutputPerMillion: m.Pricing.Output,
+		if m.Pricing.Input <= 0 && m.Pricing.Output <= 0 {
+			continue
+		}
+		next := modelPrice{
+			InputPerMillion:  m.Pricing.Input,
+			OutputPerMillion: m.Pricing.Output,
+		}
+		if current, ok := mc.pricing[key]; ok {
+			change := math.Max(priceChangePercent(current.InputPerMillion, next.InputPerMillion),
+				priceChangePercent(current.OutputPerMillion, next.OutputPerMillion))
+			if change > maxChange {
+				result.Rejected = append(result.Rejected, fmt.Sprintf("%s: rejected %s, %.0f%% from %s",
+					key, describeModelPrice(next), change, describeModelPrice(current)))
+				object.LivePricingRejected.WithLabelValues(key).Inc()
+				continue
 			}
-			updated++
 		}
+		mc.pricing[key] = next
+		result.Updated++
 	}
 	mc.lastPricingAt = time.Now()
+	return result
+}
+
+// priceChangePercent returns how far next is from current, in percent of
+// current. Any move away from a zero price counts as unbounded.
+func priceChangePercent(current float64, next float64) float64 {
+	if current == next {
+		return 0
+	}
+	if current == 0 {
+		return math.Inf(1)
+	}
+	return math.Abs(next-current) / current * 100
+}
+
+// updatePricingStaleness sets the stale gauge when live pricing has not
+// refreshed for two pricing TTLs, so it can be alerted on.
+func (mc *ModelConfig) updatePricingStaleness() {
+	mc.mu.RLock()
+	ttl := mc.pricingTTL
+	last := mc.lastPricingAt
+	mc.mu.RUnlock()
+
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	if last.IsZero() || time.Since(last) > 2*ttl {
+		object.LivePricingStale.Set(1)
+		logs.Warn("Live pricing is stale: last refreshed %s", formatPricingRefresh(last))
+		return
+	}
+	object.LivePricingStale.Set(0)
+}
+
+func formatPricingRefresh(at time.Time) string {
+	if at.IsZero() {
+		return "never"
+	}
+	return at.UTC().Format(time.RFC3339)
+}
+
+// pricingFreezeCache names the flag through which an admin's pricing
+// freeze reaches every replica sharing the cache backend.
+const pricingFreezeCache = "pricing-freeze"
+
+// PricingFrozen reports whether live pricing is frozen, by the
+// freeze_pricing feature flag or by an admin through FreezeLivePricing on
+// any replica.
+func (mc *ModelConfig) PricingFrozen() bool {
+	mc.syncPricingFrozen()
+
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.pricingFrozen || mc.features.FreezePricing
+}
+
+// SetPricingFrozen freezes or unfreezes live pricing at runtime, on every
+// replica when they share a cache backend. Unfreezing does not restore the
+// config prices; reload the config for that.
+func (mc *ModelConfig) SetPricingFrozen(frozen bool) {
+	mc.mu.Lock()
+	mc.pricingFrozen = frozen
 	mc.mu.Unlock()
+	cache.SetShared(pricingFreezeCache, "frozen", []byte(strconv.FormatBool(frozen)), 0)
+}
 
-	logs.Info("Live pricing refreshed: %d models updated from %s", updated, url)
+// syncPricingFrozen adopts the shared freeze flag, if one was set. Without
+// a cache backend, or before any admin set it, the local flag stands.
+func (mc *ModelConfig) syncPricingFrozen() {
+	value, ok := cache.GetShared(pricingFreezeCache, "frozen")
+	if !ok {
+		return
+	}
+	frozen, err := strconv.ParseBool(string(value))
+	if err != nil {
+		return
+	}
+	mc.mu.Lock()
+	mc.pricingFrozen = frozen
+	mc.mu.Unlock()
 }
 
 // LastPricingRefresh returns when pricing was last refreshed from live source.
@@ -130,14 +361,50 @@ func (mc *ModelConfig) LastPricingRefresh() time.Time {
 	return mc.lastPricingAt
 }
 
-// Stop signals the background refresh goroutine to exit.
+// Stop cancels the background refresh and any in-flight fetch. It is
+// idempotent and safe to call concurrently; no refresh starts after it.
 func (mc *ModelConfig) Stop() {
+	mc.lifeMu.Lock()
+	defer mc.lifeMu.Unlock()
+
+	mc.stopped = true
+	if mc.cancel != nil {
+		mc.cancel()
+	}
+}
+
+// Shutdown stops the background refresh and waits up to
+// modelConfigShutdownTimeout for its goroutine to exit.
+func (mc *ModelConfig) Shutdown() error {
+	mc.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		mc.wg.Wait()
+		close(done)
+	}()
+
 	select {
-	case mc.stopCh <- struct{}{}:
-	default:
+	case <-done:
+		return nil
+	case <-time.After(modelConfigShutdownTimeout):
+		return fmt.Errorf("model config: background refresh did not exit within %s", modelConfigShutdownTimeout)
 	}
 }
 
+// ShutdownModelConfig shuts down the loaded model config, if any. It is
+// called at process exit.
+func ShutdownModelConfig() {
+	if globalModelConfig == nil {
+		return
+	}
+	if err := globalModelConfig.Shutdown(); err != nil {
+		logs.Error("%v", err)
+		return
+	}
+	logs.Info("Model config refresh stopped")
+}
+
 // Status returns a human-readable status string for diagnostics.
 func (mc *ModelConfig) Status() string {
 	mc.mu.RLock()
@@ -150,8 +417,48 @@ func (mc *ModelConfig) Status() string {
 		} else {
 			liveStr = fmt.Sprintf("enabled (last: %s)", mc.lastPricingAt.Format(time.RFC3339))
 		}
+		if mc.pricingFrozen || mc.features.FreezePricing {
+			liveStr += " frozen"
+		}
 	}
 
 	return fmt.Sprintf("routes=%d pricing=%d prompts=%d live=%s",
 		len(mc.routes), len(mc.pricing), len(mc.prompts), liveStr)
 }
+
+// FreezeLivePricing
+// @Title FreezeLivePricing
+// @Tag Admin
+// @Description Freeze or unfreeze live pricing refreshes, keeping the current prices while frozen.
+// @Param   frozen    query    bool    true    "true to freeze, false to resume live refreshes"
+// @Success 200 {object} controllers.Response
+// @router /freeze-live-pricing [post]
+func (c *ApiController) FreezeLivePricing() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	cfg := GetModelConfig()
+	if cfg.static {
+		c.ResponseError("model config not initialized")
+		return
+	}
+
+	frozen, err := strconv.ParseBool(c.Input().Get("frozen"))
+	if err != nil {
+		c.ResponseError("frozen must be true or false")
+		return
+	}
+
+	before := cfg.auditSummary()
+	cfg.SetPricingFrozen(frozen)
+	c.recordAdminAudit("freeze-pricing", "model-config", "admin", cfg.configPath, before, cfg.auditSummary())
+
+	cfg.mu.RLock()
+	live := cfg.features.LiveMode
+	cfg.mu.RUnlock()
+	if !frozen && live {
+		cfg.requestLivePricing()
+	}
+	c.ResponseOk(cfg.Status())
+}
@@ -0,0 +1,240 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Model config sources. modelConfigPath may name:
+//
+//	conf/models.yaml                   a local file
+//	/etc/hanzo/models                  a mounted directory (e.g. a ConfigMap) holding models.yaml
+//	https://config.hanzo.ai/models.yaml
+//	s3://bucket/path/models.yaml       default AWS credential chain; modelConfigS3Endpoint for MinIO
+//	gs://bucket/path/models.yaml       Google application default credentials
+//
+// When modelConfigEnv is set, the overlay next to the base with the
+// environment before the extension (models.prod.yaml) is merged over it.
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/oauth2/google"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hanzoai/cloud/conf"
+)
+
+const (
+	// modelConfigFileName is the base file read from a directory source.
+	modelConfigFileName = "models.yaml"
+
+	modelConfigFetchTimeout = 30 * time.Second
+
+	// defaultModelConfigTTL is how often live mode re-fetches the config
+	// source when cache.config_ttl is unset.
+	defaultModelConfigTTL = 5 * time.Minute
+)
+
+// errModelConfigNotFound reports a missing config object, which is only an
+// error for the base: overlays are optional.
+var errModelConfigNotFound = errors.New("not found")
+
+// isRemoteModelConfigSource reports whether source is a URL rather than a
+// local file or directory.
+func isRemoteModelConfigSource(source string) bool {
+	return strings.Contains(source, "://")
+}
+
+// getModelConfigBase returns the base file of source: models.yaml inside a
+// directory source, source itself otherwise.
+func getModelConfigBase(source string) string {
+	if !isRemoteModelConfigSource(source) {
+		if info, err := os.Stat(source); err == nil && info.IsDir() {
+			return filepath.Join(source, modelConfigFileName)
+		}
+	}
+	return source
+}
+
+// getModelConfigOverlay returns the overlay location of base for env, with
+// the environment inserted before the extension.
+func getModelConfigOverlay(base string, env string) string {
+	ext := path.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// fetchModelConfig reads the config at source and merges the overlay of
+// modelConfigEnv over it, when one exists.
+func fetchModelConfig(source string) ([]byte, error) {
+	return fetchModelConfigContext(context.Background(), source)
+}
+
+// fetchModelConfigContext is fetchModelConfig, abandoned when ctx is done.
+func fetchModelConfigContext(ctx context.Context, source string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, modelConfigFetchTimeout)
+	defer cancel()
+
+	base := getModelConfigBase(source)
+	data, err := readModelConfigLocation(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("model config: read %s: %w", base, err)
+	}
+
+	env := conf.GetConfigString("modelConfigEnv")
+	if env == "" {
+		return data, nil
+	}
+	overlay := getModelConfigOverlay(base, env)
+	overlayData, err := readModelConfigLocation(ctx, overlay)
+	if errors.Is(err, errModelConfigNotFound) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("model config: read overlay %s: %w", overlay, err)
+	}
+
+	merged, err := mergeModelConfigYAML(data, overlayData)
+	if err != nil {
+		return nil, fmt.Errorf("model config: merge overlay %s: %w", overlay, err)
+	}
+	return merged, nil
+}
+
+// modelConfigChecksum identifies the content of a fetched config.
+func modelConfigChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func readModelConfigLocation(ctx context.Context, location string) ([]byte, error) {
+	scheme, rest, _ := strings.Cut(location, "://")
+	switch {
+	case !isRemoteModelConfigSource(location):
+		data, err := os.ReadFile(location)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errModelConfigNotFound
+		}
+		return data, err
+	case scheme == "http" || scheme == "https":
+		return readModelConfigHttp(ctx, http.DefaultClient, location)
+	case scheme == "s3":
+		return readModelConfigS3(ctx, rest)
+	case scheme == "gs":
+		bucket, object, _ := strings.Cut(rest, "/")
+		client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_only")
+		if err != nil {
+			return nil, fmt.Errorf("gcs credentials: %w", err)
+		}
+		return readModelConfigHttp(ctx, client, fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media",
+			url.PathEscape(bucket), url.PathEscape(object)))
+	default:
+		return nil, fmt.Errorf("unsupported config source scheme %q", scheme)
+	}
+}
+
+func readModelConfigHttp(ctx context.Context, client *http.Client, location string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errModelConfigNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func readModelConfigS3(ctx context.Context, bucketKey string) ([]byte, error) {
+	bucket, key, _ := strings.Cut(bucketKey, "/")
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("aws config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint := conf.GetConfigString("modelConfigS3Endpoint"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, errModelConfigNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+// mergeModelConfigYAML deep-merges overlay over base. Maps merge key by key,
+// so an overlay can change one field of a model; other values replace, and
+// a null value removes the key.
+func mergeModelConfigYAML(base []byte, overlay []byte) ([]byte, error) {
+	merged := map[string]interface{}{}
+	if err := yaml.Unmarshal(base, &merged); err != nil {
+		return nil, err
+	}
+	overrides := map[string]interface{}{}
+	if err := yaml.Unmarshal(overlay, &overrides); err != nil {
+		return nil, err
+	}
+
+	mergeYAMLMaps(merged, overrides)
+	return yaml.Marshal(merged)
+}
+
+func mergeYAMLMaps(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeYAMLMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
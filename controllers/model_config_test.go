@@ -15,9 +15,17 @@
 package controllers
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
 )
 
 const testYAML = `
@@ -43,12 +51,18 @@ models:
     provider: do-ai
     upstream: openai-gpt-4o
     pricing: { input: 2.50, output: 10.00 }
+    context_window: 128000
+    max_output_tokens: 16384
+    capabilities: [tools, Vision, chat, tools]
+    deprecated: "retired 2026-12-31, use zen4"
 
   zen4:
     provider: fireworks
     upstream: accounts/fireworks/models/glm-5
     premium: true
     owned_by: hanzo
+    context_window: 200000
+    capabilities: [chat, reasoning]
     identity_prompt: |
       You are Zen4 by Hanzo AI.
     pricing: { input: 3.00, output: 9.60 }
@@ -102,11 +116,10 @@ func TestLoadConfig(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
 
-	if err := mc.loadFromFile(path); err != nil {
-		t.Fatalf("loadFromFile failed: %v", err)
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatalf("loadFromSource failed: %v", err)
 	}
 
 	// Should have routes for non-pricing-only entries
@@ -132,9 +145,8 @@ func TestResolveRoute(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -182,9 +194,8 @@ func TestGetPrice(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -226,9 +237,8 @@ func TestGetIdentityPrompt(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -267,9 +277,8 @@ func TestListModels(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -310,9 +319,8 @@ func TestListModelsWithUpstream(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -338,9 +346,8 @@ func TestStarterCreditDollars(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -357,9 +364,8 @@ func TestReload(t *testing.T) {
 		routes:  make(map[string]modelRoute),
 		pricing: make(map[string]modelPrice),
 		prompts: make(map[string]string),
-		stopCh:  make(chan struct{}),
 	}
-	if err := mc.loadFromFile(path); err != nil {
+	if err := mc.loadFromSource(path); err != nil {
 		t.Fatal(err)
 	}
 
@@ -376,3 +382,549 @@ func TestReload(t *testing.T) {
 		t.Error("expected route for gpt-4o after reload")
 	}
 }
+
+func TestRefreshConfigFetchesOnce(t *testing.T) {
+	data, err := os.ReadFile(writeTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	mc := &ModelConfig{
+		routes:     make(map[string]modelRoute),
+		pricing:    make(map[string]modelPrice),
+		prompts:    make(map[string]string),
+		configPath: server.URL + "/models.yaml",
+	}
+	mc.refreshConfig(context.Background())
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("refreshing a changed config fetched it %d times, want 1", n)
+	}
+	if mc.ResolveRoute("gpt-4o") == nil {
+		t.Error("expected route for gpt-4o after refresh")
+	}
+
+	mc.refreshConfig(context.Background())
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("refreshing an unchanged config fetched it %d times in total, want 2", n)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	path := writeTestConfig(t)
+
+	mc := &ModelConfig{
+		routes:  make(map[string]modelRoute),
+		pricing: make(map[string]modelPrice),
+		prompts: make(map[string]string),
+	}
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback := &ModelConfig{
+		routes: map[string]modelRoute{
+			"gpt-4o": {providerName: "do-ai", upstreamModel: "openai-gpt-4o"},
+			"zen4":   {providerName: "fireworks", upstreamModel: "accounts/fireworks/models/glm-4p7"},
+			"o3":     {providerName: "openai-direct", upstreamModel: "o3"},
+		},
+		pricing: map[string]modelPrice{
+			"gpt-4o":    {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+			"zen4-mini": {InputPerMillion: 0.30, OutputPerMillion: 0.60},
+		},
+		prompts: map[string]string{
+			"zen4":      "You are Zen4 by Hanzo AI.\n",
+			"zen4-mini": "You are Zen4 Mini.",
+		},
+	}
+
+	want := []string{
+		"model o3 has a static route but no config entry",
+		"model zen4 routes to fireworks/accounts/fireworks/models/glm-5, static table has fireworks/accounts/fireworks/models/glm-4p7",
+		"model zen4-mini identity prompt differs from the static table",
+		"model zen4-mini is priced $0.6/$0.6 per million, static table has $0.3/$0.6",
+	}
+	got := mc.divergence(fallback)
+	if len(got) != len(want) {
+		t.Fatalf("divergence() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("divergence()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetModelConfigStaticFallback(t *testing.T) {
+	if globalModelConfig != nil {
+		t.Skip("a YAML model config is loaded")
+	}
+
+	cfg := GetModelConfig()
+	if cfg == nil || !cfg.static {
+		t.Fatal("GetModelConfig() should serve the static tables when no config is loaded")
+	}
+	if route := cfg.ResolveRoute("GPT-4O"); route == nil || route.providerName != modelRoutes["gpt-4o"].providerName {
+		t.Errorf("static ResolveRoute(GPT-4O) = %+v", route)
+	}
+	if price := cfg.GetPrice("openai/gpt-4o"); price != modelPricing["gpt-4o"] {
+		t.Errorf("static alias price = %+v, want %+v", price, modelPricing["gpt-4o"])
+	}
+}
+
+func TestValidateModelConfig(t *testing.T) {
+	live := &ModelConfig{
+		routes: map[string]modelRoute{
+			"gpt-4o": {providerName: "do-ai", upstreamModel: "openai-gpt-4o"},
+			"o3":     {providerName: "openai-direct", upstreamModel: "o3"},
+		},
+		pricing: map[string]modelPrice{
+			"gpt-4o": {InputPerMillion: 2.00, OutputPerMillion: 10.00},
+		},
+	}
+	knownProviders := func(name string) bool { return name == "do-ai" }
+
+	report := validateModelConfig([]byte(testYAML), live, knownProviders)
+	if !report.Valid || len(report.Errors) != 0 {
+		t.Fatalf("testYAML should be valid, got errors %q", report.Errors)
+	}
+	if len(report.UnknownProviders) != 1 || report.UnknownProviders[0] != "fireworks" {
+		t.Errorf("unknownProviders = %q, want [fireworks]", report.UnknownProviders)
+	}
+	if len(report.MissingPricing) != 0 {
+		t.Errorf("missingPricing = %q, want none", report.MissingPricing)
+	}
+
+	changes := map[string]string{}
+	for _, change := range report.RouteChanges {
+		changes[change.Model] = change.Change
+	}
+	if changes["o3"] != "removed" || changes["zen4"] != "added" || changes["gpt-4o"] != "" {
+		t.Errorf("routeChanges = %+v", report.RouteChanges)
+	}
+	if len(report.PriceChanges) == 0 || report.PriceChanges[0].Model != "fireworks/deepseek-r1" {
+		t.Errorf("priceChanges = %+v", report.PriceChanges)
+	}
+
+	invalid := `
+version: 1
+models:
+  a:
+    provider: do-ai
+    upstream: a
+    alias_pricing: b
+  b:
+    provider: do-ai
+    alias_pricing: a
+    colour: blue
+`
+	report = validateModelConfig([]byte(invalid), live, knownProviders)
+	if report.Valid || len(report.Errors) != 1 || !strings.HasPrefix(report.Errors[0], "parse:") {
+		t.Errorf("unknown field should fail parsing, got %+v", report)
+	}
+
+	badMetadata := strings.Replace(invalid, "    colour: blue\n", "", 1) + `
+  c:
+    provider: do-ai
+    upstream: c
+    context_window: -1
+    capabilities: [chat, telepathy]
+  d:
+    provider: do-ai
+    upstream: d
+    context_window: 1000
+    max_output_tokens: 2000
+`
+	report = validateModelConfig([]byte(badMetadata), live, knownProviders)
+	wantErrors := map[string]bool{
+		"models.c.context_window: must not be negative":         true,
+		`models.c.capabilities: unknown capability "telepathy"`: true,
+		"models.d.max_output_tokens: exceeds context_window":    true,
+	}
+	for _, err := range report.Errors {
+		delete(wantErrors, err)
+	}
+	if len(wantErrors) != 0 {
+		t.Errorf("errors = %q, missing %v", report.Errors, wantErrors)
+	}
+
+	invalid = strings.Replace(invalid, "    colour: blue\n", "", 1)
+	report = validateModelConfig([]byte(invalid), live, knownProviders)
+	if report.Valid {
+		t.Error("config with an alias cycle should be invalid")
+	}
+	if len(report.Errors) != 1 || report.Errors[0] != "models.b: provider and upstream are required" {
+		t.Errorf("errors = %q", report.Errors)
+	}
+	if len(report.AliasCycles) != 1 || report.AliasCycles[0] != "a -> b -> a" {
+		t.Errorf("aliasCycles = %q, want [a -> b -> a]", report.AliasCycles)
+	}
+	if len(report.MissingPricing) != 2 {
+		t.Errorf("missingPricing = %q, want [a b]", report.MissingPricing)
+	}
+}
+
+func TestLoadFromDirectoryWithOverlay(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), []byte(testYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	overlay := `
+models:
+  gpt-4o:
+    pricing: { input: 2.00 }
+  zen4-mini: null
+  o3:
+    provider: openai-direct
+    upstream: o3
+`
+	if err := os.WriteFile(filepath.Join(dir, "models.prod.yaml"), []byte(overlay), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("modelConfigEnv", "prod")
+
+	mc := &ModelConfig{}
+	if err := mc.loadFromSource(dir); err != nil {
+		t.Fatalf("loadFromSource(dir) failed: %v", err)
+	}
+
+	// Overlay fields merge into the base model
+	route := mc.ResolveRoute("gpt-4o")
+	if route == nil || route.providerName != "do-ai" {
+		t.Fatalf("gpt-4o route = %+v, want the base do-ai route", route)
+	}
+	if price := mc.GetPrice("gpt-4o"); price.InputPerMillion != 2.00 || price.OutputPerMillion != 10.00 {
+		t.Errorf("gpt-4o price = %+v, want input 2.00 from overlay, output 10.00 from base", price)
+	}
+	if mc.ResolveRoute("zen4-mini") != nil {
+		t.Error("zen4-mini should be removed by the null overlay entry")
+	}
+	if mc.ResolveRoute("o3") == nil {
+		t.Error("o3 should be added by the overlay")
+	}
+
+	// Without an environment overlay the base loads unchanged
+	t.Setenv("modelConfigEnv", "staging")
+	if err := mc.loadFromSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	if mc.ResolveRoute("zen4-mini") == nil {
+		t.Error("zen4-mini should be in the base config")
+	}
+}
+
+func TestModelConfigRollback(t *testing.T) {
+	path := writeTestConfig(t)
+	mc := &ModelConfig{}
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bad edit reroutes gpt-4o
+	bad := strings.Replace(testYAML, "upstream: openai-gpt-4o\n    pricing", "upstream: broken-model\n    pricing", 1)
+	if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mc.configPath = path
+	if err := mc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if route := mc.ResolveRoute("gpt-4o"); route.upstreamModel != "broken-model" {
+		t.Fatalf("gpt-4o upstream = %q after bad reload", route.upstreamModel)
+	}
+
+	if err := mc.Rollback(2); err == nil {
+		t.Error("rolling back to the active generation should fail")
+	}
+	if err := mc.Rollback(7); err == nil {
+		t.Error("rolling back to an unknown generation should fail")
+	}
+	if err := mc.Rollback(1); err != nil {
+		t.Fatalf("Rollback(1) failed: %v", err)
+	}
+	if route := mc.ResolveRoute("gpt-4o"); route.upstreamModel != "openai-gpt-4o" {
+		t.Errorf("gpt-4o upstream = %q after rollback, want openai-gpt-4o", route.upstreamModel)
+	}
+
+	generations := mc.Generations()
+	if len(generations) != 3 {
+		t.Fatalf("expected 3 generations, got %d", len(generations))
+	}
+	latest := generations[0]
+	if latest.Generation != 3 || !latest.Active || latest.RolledBackFrom != 1 || latest.Checksum != generations[2].Checksum {
+		t.Errorf("latest generation = %+v, want active 3 restoring generation 1", latest)
+	}
+	if generations[1].Active || generations[2].Active {
+		t.Error("only the latest generation should be active")
+	}
+}
+
+func TestIdentityPromptTemplate(t *testing.T) {
+	yamlText := `
+version: 1
+identity:
+  template: "You are {{.Name}} ({{.Tier}}, gen {{.Generation}}) by {{.Company}}.{{with .Note}} {{.}}{{end}}"
+  orgs:
+    acme: { company: "Acme Corp" }
+models:
+  zen4-mini:
+    provider: fireworks
+    upstream: accounts/fireworks/models/qwen3-8b
+    identity: { name: "Zen4 Mini", tier: "efficient tier", generation: 4, note: "Be brief." }
+  zen4:
+    provider: fireworks
+    upstream: accounts/fireworks/models/glm-5
+    identity_prompt: You are Zen4.
+`
+	path := filepath.Join(t.TempDir(), "models.yaml")
+	if err := os.WriteFile(path, []byte(yamlText), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mc := &ModelConfig{}
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model string
+		org   string
+		want  string
+	}{
+		{"zen4-mini", "", "You are Zen4 Mini (efficient tier, gen 4) by Hanzo AI Inc. Be brief."},
+		{"zen-mini", "", "You are Zen4 Mini (efficient tier, gen 4) by Hanzo AI Inc. Be brief."},
+		{"zen4-mini", "acme", "You are Zen4 Mini (efficient tier, gen 4) by Acme Corp. Be brief."},
+		{"zen-mini", "acme", "You are Zen4 Mini (efficient tier, gen 4) by Acme Corp. Be brief."},
+		{"zen4", "acme", "You are Zen4."},
+	}
+	for _, tc := range tests {
+		if got := mc.GetIdentityPromptForOrg(tc.model, tc.org); got != tc.want {
+			t.Errorf("GetIdentityPromptForOrg(%q, %q) = %q, want %q", tc.model, tc.org, got, tc.want)
+		}
+	}
+
+	// A template referencing an unknown variable fails the load
+	bad := strings.Replace(yamlText, "{{.Tier}}", "{{.Colour}}", 1)
+	if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.loadFromSource(path); err == nil {
+		t.Error("expected an error for an unknown template variable")
+	}
+}
+
+func TestBrandedModels(t *testing.T) {
+	yamlText := `
+version: 1
+identity:
+  template: "You are {{.Name}} ({{.Tier}}) by {{.Company}}."
+  orgs:
+    acme:
+      company: "Acme Corp"
+      models:
+        acme-1: { model: zen4-mini }
+        acme-pro: { model: zen4-mini, name: "Acme Pro", tier: "pro tier" }
+        zen4: { identity_prompt: You are Acme Zen. }
+models:
+  zen4-mini:
+    provider: fireworks
+    upstream: accounts/fireworks/models/qwen3-8b
+    identity: { name: "Zen4 Mini", tier: "efficient tier" }
+  zen4:
+    provider: fireworks
+    upstream: accounts/fireworks/models/glm-5
+    identity_prompt: You are Zen4.
+`
+	path := filepath.Join(t.TempDir(), "models.yaml")
+	if err := os.WriteFile(path, []byte(yamlText), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mc := &ModelConfig{}
+	if err := mc.loadFromSource(path); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts := []struct {
+		model string
+		org   string
+		want  string
+	}{
+		{"acme-1", "acme", "You are Zen4 Mini (efficient tier) by Acme Corp."},
+		{"ACME-Pro", "acme", "You are Acme Pro (pro tier) by Acme Corp."},
+		{"zen4", "acme", "You are Acme Zen."},
+		{"zen4", "", "You are Zen4."},
+		{"acme-1", "", ""},
+	}
+	for _, tc := range prompts {
+		if got := mc.GetIdentityPromptForOrg(tc.model, tc.org); got != tc.want {
+			t.Errorf("GetIdentityPromptForOrg(%q, %q) = %q, want %q", tc.model, tc.org, got, tc.want)
+		}
+	}
+
+	if got := mc.ResolveBrandedModel("Acme-1", "acme"); got != "zen4-mini" {
+		t.Errorf("ResolveBrandedModel(Acme-1, acme) = %q, want zen4-mini", got)
+	}
+	if got := mc.ResolveBrandedModel("acme-1", "other"); got != "acme-1" {
+		t.Errorf("ResolveBrandedModel(acme-1, other) = %q, want acme-1", got)
+	}
+	if got := len(mc.BrandedModels("acme")); got != 2 {
+		t.Errorf("BrandedModels(acme) has %d names, want 2", got)
+	}
+
+	// A branded name for an undefined model fails the load
+	bad := strings.Replace(yamlText, "acme-1: { model: zen4-mini }", "acme-1: { model: zen9 }", 1)
+	if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.loadFromSource(path); err == nil {
+		t.Error("expected an error for a branded name of an undefined model")
+	}
+}
+
+func TestApplyLivePricingBounds(t *testing.T) {
+	mc := &ModelConfig{
+		pricing: map[string]modelPrice{
+			"gpt-4o": {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+			"zen4":   {InputPerMillion: 1.00, OutputPerMillion: 4.00},
+		},
+		features: FeatureFlags{MaxPriceChange: 50},
+	}
+
+	result := mc.applyLivePricing([]livePricingModel{
+		{Name: "gpt-4o", Pricing: livePricingEntry{Input: 3.00, Output: 12.00}}, // +20%
+		{Name: "zen4", Pricing: livePricingEntry{Input: 0.01, Output: 4.00}},    // -99% input
+		{Name: "o3", Pricing: livePricingEntry{Input: 2.00, Output: 8.00}},      // new model
+		{Name: "free-model", Pricing: livePricingEntry{Input: 0, Output: 0}},    // ignored
+	})
+	if result.Updated != 2 || len(result.Rejected) != 1 {
+		t.Fatalf("applyLivePricing = %+v, want 2 updated and zen4 rejected", result)
+	}
+	if price := mc.GetPrice("gpt-4o"); price.InputPerMillion != 3.00 {
+		t.Errorf("gpt-4o input = %v, want 3.00", price.InputPerMillion)
+	}
+	if price := mc.GetPrice("zen4"); price.InputPerMillion != 1.00 {
+		t.Errorf("zen4 input = %v, want the config price 1.00 kept", price.InputPerMillion)
+	}
+	if price := mc.GetPrice("o3"); price.OutputPerMillion != 8.00 {
+		t.Errorf("o3 output = %v, want 8.00", price.OutputPerMillion)
+	}
+
+	if mc.PricingFrozen() {
+		t.Error("pricing should not start frozen")
+	}
+	mc.SetPricingFrozen(true)
+	if !mc.PricingFrozen() {
+		t.Error("SetPricingFrozen(true) should freeze pricing")
+	}
+	mc.SetPricingFrozen(false)
+	mc.features.FreezePricing = true
+	if !mc.PricingFrozen() {
+		t.Error("the freeze_pricing feature flag should freeze pricing")
+	}
+}
+
+func TestPricingFrozenShared(t *testing.T) {
+	cache.SetBackend(&counterBackend{counters: map[string]int64{}})
+	t.Cleanup(func() { cache.SetBackend(nil) })
+
+	// Two replicas, each with its own config.
+	admin, other := &ModelConfig{}, &ModelConfig{}
+	if other.PricingFrozen() {
+		t.Fatal("pricing should not start frozen")
+	}
+	admin.SetPricingFrozen(true)
+	if !other.PricingFrozen() {
+		t.Error("a freeze on one replica should freeze pricing on the other")
+	}
+	admin.SetPricingFrozen(false)
+	if other.PricingFrozen() {
+		t.Error("unfreezing on one replica should unfreeze the other")
+	}
+}
+
+func TestModelConfigLifecycle(t *testing.T) {
+	var inflight, overlapped, fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&inflight, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		defer atomic.AddInt32(&inflight, -1)
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"models":[{"name":"gpt-4o","pricing":{"input":2.5,"output":10}}]}`))
+	}))
+	defer server.Close()
+
+	mc := &ModelConfig{
+		pricing:    map[string]modelPrice{},
+		features:   FeatureFlags{LiveMode: true},
+		pricingURL: server.URL,
+	}
+	if !mc.startBackgroundRefresh() {
+		t.Fatal("startBackgroundRefresh should start the goroutine")
+	}
+	if mc.startBackgroundRefresh() {
+		t.Error("a second startBackgroundRefresh should be a no-op")
+	}
+	for i := 0; i < 5; i++ {
+		mc.requestLivePricing()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&fetches) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Error("live pricing fetches overlapped")
+	}
+	if price := mc.GetPrice("gpt-4o"); price.InputPerMillion != 2.5 {
+		t.Errorf("gpt-4o input = %v, want 2.5 from live pricing", price.InputPerMillion)
+	}
+
+	mc.Stop()
+	mc.Stop()
+	if err := mc.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	before := atomic.LoadInt32(&fetches)
+	mc.requestLivePricing()
+	if mc.startBackgroundRefresh() {
+		t.Error("startBackgroundRefresh should be a no-op after Stop")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after := atomic.LoadInt32(&fetches); after != before {
+		t.Errorf("%d fetches ran after Shutdown", after-before)
+	}
+}
+
+func TestListModelsForEntitlements(t *testing.T) {
+	mc := &ModelConfig{
+		routes: map[string]modelRoute{
+			"gpt-4o":     {providerName: "do-ai", upstreamModel: "openai-gpt-4o"},
+			"zen4-ultra": {providerName: "fireworks", upstreamModel: "glm-5", entitlement: "enterprise"},
+			"zen4-alias": {providerName: "fireworks", upstreamModel: "glm-5", entitlement: "enterprise", hidden: true},
+		},
+	}
+
+	ids := func(models []modelInfo) string {
+		names := []string{}
+		for _, m := range models {
+			names = append(names, m.ID)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := ids(mc.ListModels()); got != "gpt-4o" {
+		t.Errorf("public catalog = %s, want gpt-4o", got)
+	}
+	if got := ids(mc.ListModelsForEntitlements(map[string]bool{"research": true})); got != "gpt-4o" {
+		t.Errorf("catalog without enterprise = %s, want gpt-4o", got)
+	}
+	if got := ids(mc.ListModelsForEntitlements(map[string]bool{"enterprise": true})); got != "gpt-4o,zen4-ultra" {
+		t.Errorf("enterprise catalog = %s, want gpt-4o,zen4-ultra", got)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	"gopkg.in/yaml.v3"
+)
+
+// modelConfigValidation is the dry-run report of a candidate model config.
+// Errors and alias cycles make a config invalid; unknown providers and
+// missing pricing are reported but do not, since providers can be added
+// before the reload and unpriced models bill at default_pricing.
+type modelConfigValidation struct {
+	Valid            bool                `json:"valid"`
+	Errors           []string            `json:"errors"`
+	UnknownProviders []string            `json:"unknownProviders"`
+	MissingPricing   []string            `json:"missingPricing"`
+	AliasCycles      []string            `json:"aliasCycles"`
+	RouteChanges     []modelConfigChange `json:"routeChanges"`
+	PriceChanges     []modelConfigChange `json:"priceChanges"`
+}
+
+// modelConfigChange is one model whose route or price the candidate would
+// change. Change is "added", "removed" or "changed".
+type modelConfigChange struct {
+	Model  string `json:"model"`
+	Change string `json:"change"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// validateModelConfig checks a candidate models.yaml and diffs it against
+// live. providerExists reports whether a provider name is configured.
+func validateModelConfig(data []byte, live *ModelConfig, providerExists func(name string) bool) *modelConfigValidation {
+	report := &modelConfigValidation{
+		Errors:           []string{},
+		UnknownProviders: []string{},
+		MissingPricing:   []string{},
+		AliasCycles:      []string{},
+		RouteChanges:     []modelConfigChange{},
+		PriceChanges:     []modelConfigChange{},
+	}
+
+	var file ModelConfigFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("parse: %s", err.Error()))
+		return report
+	}
+
+	if file.Version != 1 {
+		report.Errors = append(report.Errors, fmt.Sprintf("version: unsupported version %d, want 1", file.Version))
+	}
+	for field, ttl := range map[string]string{"pricing_ttl": file.Cache.PricingTTL, "config_ttl": file.Cache.ConfigTTL} {
+		if ttl == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(ttl); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("cache.%s: %s", field, err.Error()))
+		}
+	}
+	if file.Features.MaxPriceChange < 0 {
+		report.Errors = append(report.Errors, "features.max_price_change: must not be negative")
+	}
+	if len(file.Models) == 0 {
+		report.Errors = append(report.Errors, "models: no models defined")
+	}
+
+	names := make([]string, 0, len(file.Models))
+	defined := map[string]bool{}
+	for name := range file.Models {
+		names = append(names, name)
+		defined[strings.ToLower(name)] = true
+	}
+	sort.Strings(names)
+
+	providers := map[string]bool{}
+	for _, name := range names {
+		def := file.Models[name]
+		if !def.PricingOnly {
+			if def.Provider == "" || def.Upstream == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("models.%s: provider and upstream are required", name))
+			}
+			providers[def.Provider] = true
+		}
+		if def.Weight < 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.weight: must not be negative", name))
+		}
+		for i, fb := range def.Fallbacks {
+			if fb.Provider == "" || fb.Upstream == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("models.%s.fallbacks[%d]: provider and upstream are required", name, i))
+			}
+			if fb.Weight < 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("models.%s.fallbacks[%d].weight: must not be negative", name, i))
+			}
+			providers[fb.Provider] = true
+		}
+		if t := def.Timeouts; t != nil {
+			report.Errors = append(report.Errors, validateModelTimeouts(name, t)...)
+		}
+		if def.Shadow != nil {
+			report.Errors = append(report.Errors, validateModelShadow(name, def.Shadow)...)
+			providers[def.Shadow.Provider] = true
+		}
+		if err := validateThinkTags(def.ThinkTags); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.think_tags: %s", name, err.Error()))
+		}
+		if def.ContextWindow < 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.context_window: must not be negative", name))
+		}
+		if def.MaxOutputTokens < 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.max_output_tokens: must not be negative", name))
+		} else if def.ContextWindow > 0 && def.MaxOutputTokens > def.ContextWindow {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.max_output_tokens: exceeds context_window", name))
+		}
+		for _, capability := range def.Capabilities {
+			if !modelCapabilities[strings.ToLower(strings.TrimSpace(capability))] {
+				report.Errors = append(report.Errors, fmt.Sprintf("models.%s.capabilities: unknown capability %q", name, capability))
+			}
+		}
+		if p := def.Pricing; p != nil && (p.Input < 0 || p.Output < 0 || p.InputPerMillion < 0 || p.OutputPerMillion < 0) {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.pricing: prices must not be negative", name))
+		}
+		if def.AliasOf != "" && !defined[strings.ToLower(def.AliasOf)] {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.alias_of: %s is not defined", name, def.AliasOf))
+		}
+		if def.AliasPricing != "" && !defined[strings.ToLower(def.AliasPricing)] {
+			report.Errors = append(report.Errors, fmt.Sprintf("models.%s.alias_pricing: %s is not defined", name, def.AliasPricing))
+		}
+	}
+	for provider, upstreams := range file.UpstreamCosts {
+		for upstream, cost := range upstreams {
+			if cost.Input < 0 || cost.Output < 0 || cost.InputPerMillion < 0 || cost.OutputPerMillion < 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("upstream_costs.%s.%s: costs must not be negative", provider, upstream))
+			}
+		}
+	}
+	delete(providers, "")
+	for provider := range providers {
+		if !providerExists(provider) {
+			report.UnknownProviders = append(report.UnknownProviders, provider)
+		}
+	}
+	sort.Strings(report.UnknownProviders)
+
+	report.AliasCycles = append(findAliasCycles(file.Models, func(def ModelDef) string { return def.AliasOf }),
+		findAliasCycles(file.Models, func(def ModelDef) string { return def.AliasPricing })...)
+
+	candidate, err := buildModelConfig(&file)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		report.Valid = false
+		return report
+	}
+	for name := range candidate.routes {
+		if _, ok := candidate.pricing[name]; !ok {
+			report.MissingPricing = append(report.MissingPricing, name)
+		}
+	}
+	sort.Strings(report.MissingPricing)
+
+	report.RouteChanges, report.PriceChanges = diffModelConfigs(live, candidate)
+	report.Valid = len(report.Errors) == 0 && len(report.AliasCycles) == 0
+	return report
+}
+
+// findAliasCycles follows the alias edge of every model and returns each
+// cycle once, as "a -> b -> a".
+func findAliasCycles(models map[string]ModelDef, target func(def ModelDef) string) []string {
+	next := map[string]string{}
+	for name, def := range models {
+		if t := target(def); t != "" {
+			next[strings.ToLower(name)] = strings.ToLower(t)
+		}
+	}
+
+	starts := make([]string, 0, len(next))
+	for name := range next {
+		starts = append(starts, name)
+	}
+	sort.Strings(starts)
+
+	cycles := []string{}
+	done := map[string]bool{}
+	for _, start := range starts {
+		position := map[string]int{}
+		path := []string{}
+		for name := start; name != "" && !done[name]; name = next[name] {
+			if i, ok := position[name]; ok {
+				cycles = append(cycles, strings.Join(append(path[i:], name), " -> "))
+				break
+			}
+			position[name] = len(path)
+			path = append(path, name)
+		}
+		for _, name := range path {
+			done[name] = true
+		}
+	}
+	return cycles
+}
+
+func describeModelRoute(route modelRoute) string {
+	description := route.providerName + "/" + route.upstreamModel
+	for _, fb := range route.fallbacks {
+		description += ", fallback " + fb.providerName + "/" + fb.upstreamModel
+	}
+	if route.premium {
+		description += ", premium"
+	}
+	if route.hidden {
+		description += ", hidden"
+	}
+	if route.entitlement != "" {
+		description += ", entitlement " + route.entitlement
+	}
+	if route.shadow != nil {
+		description += fmt.Sprintf(", shadow %g%% to %s/%s", route.shadow.percent, route.shadow.providerName, route.shadow.upstreamModel)
+	}
+	if route.thinkTags != "" {
+		description += ", think tags " + route.thinkTags
+	}
+	return description
+}
+
+func describeModelPrice(price modelPrice) string {
+	return fmt.Sprintf("$%g in / $%g out per million", price.InputPerMillion, price.OutputPerMillion)
+}
+
+// diffModelConfigs returns the routes and prices that replacing live with
+// candidate would add, remove or change, sorted by model.
+func diffModelConfigs(live *ModelConfig, candidate *ModelConfig) ([]modelConfigChange, []modelConfigChange) {
+	live.mu.RLock()
+	defer live.mu.RUnlock()
+
+	liveRoutes := map[string]string{}
+	for name, route := range live.routes {
+		liveRoutes[name] = describeModelRoute(route)
+	}
+	candidateRoutes := map[string]string{}
+	for name, route := range candidate.routes {
+		candidateRoutes[name] = describeModelRoute(route)
+	}
+	livePrices := map[string]string{}
+	for name, price := range live.pricing {
+		livePrices[name] = describeModelPrice(price)
+	}
+	candidatePrices := map[string]string{}
+	for name, price := range candidate.pricing {
+		candidatePrices[name] = describeModelPrice(price)
+	}
+
+	return diffDescriptions(liveRoutes, candidateRoutes), diffDescriptions(livePrices, candidatePrices)
+}
+
+func diffDescriptions(before map[string]string, after map[string]string) []modelConfigChange {
+	changes := []modelConfigChange{}
+	for name, was := range before {
+		now, ok := after[name]
+		switch {
+		case !ok:
+			changes = append(changes, modelConfigChange{Model: name, Change: "removed", Before: was})
+		case now != was:
+			changes = append(changes, modelConfigChange{Model: name, Change: "changed", Before: was, After: now})
+		}
+	}
+	for name, now := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, modelConfigChange{Model: name, Change: "added", After: now})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Model < changes[j].Model
+	})
+	return changes
+}
+
+// getModelConfigPath returns the path of the live config file, or the
+// configured path when the static fallback is serving.
+func getModelConfigPath() string {
+	if cfg := GetModelConfig(); !cfg.static {
+		return cfg.configPath
+	}
+	if path := conf.GetConfigString("modelConfigPath"); path != "" {
+		return path
+	}
+	return "conf/models.yaml"
+}
+
+// ValidateModelConfig
+// @Title ValidateModelConfig
+// @Tag Admin
+// @Description Validate a candidate model config and diff it against the live one, without reloading.
+// @Param   file    query    string  false    "config file name in the live config's directory; defaults to the live config source"
+// @Param   body    body     string  false    "candidate YAML; takes precedence over file"
+// @Success 200 {object} controllers.Response
+// @router /validate-model-config [post]
+func (c *ApiController) ValidateModelConfig() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	data := c.Ctx.Input.RequestBody
+	if len(bytes.TrimSpace(data)) == 0 {
+		source := getModelConfigPath()
+		// Only local files next to the live config can be read, by base name.
+		if file := c.Input().Get("file"); file != "" {
+			if isRemoteModelConfigSource(source) {
+				c.ResponseError("file is only supported for local model config sources")
+				return
+			}
+			source = filepath.Join(filepath.Dir(getModelConfigBase(source)), filepath.Base(file))
+		}
+
+		var err error
+		data, err = fetchModelConfig(source)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	report := validateModelConfig(data, GetModelConfig(), func(name string) bool {
+		provider, err := object.GetModelProviderByName(name, c.getAuditActor())
+		return err == nil && provider != nil
+	})
+	c.ResponseOk(report)
+}
+
+// validateModelTimeouts checks that the timeouts of model name are positive
+// durations and that the first token is not expected after the total.
+func validateModelTimeouts(name string, def *ModelTimeoutsDef) []string {
+	errs := []string{}
+	for _, field := range []struct {
+		key   string
+		value string
+	}{
+		{"connect", def.Connect},
+		{"first_token", def.FirstToken},
+		{"total", def.Total},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("models.%s.timeouts.%s: %s", name, field.key, err.Error()))
+		} else if d <= 0 {
+			errs = append(errs, fmt.Sprintf("models.%s.timeouts.%s: must be positive", name, field.key))
+		}
+	}
+	t := def.toRouteTimeouts()
+	if t.total > 0 && t.firstToken > t.total {
+		errs = append(errs, fmt.Sprintf("models.%s.timeouts.first_token: exceeds total", name))
+	}
+	if t.total > 0 && t.connect > t.total {
+		errs = append(errs, fmt.Sprintf("models.%s.timeouts.connect: exceeds total", name))
+	}
+	return errs
+}
+
+// validateModelShadow checks that the shadow of model name names an
+// upstream and mirrors a share of its requests.
+func validateModelShadow(name string, def *ModelShadowDef) []string {
+	errs := []string{}
+	if def.Provider == "" || def.Upstream == "" {
+		errs = append(errs, fmt.Sprintf("models.%s.shadow: provider and upstream are required", name))
+	}
+	if def.Percent <= 0 || def.Percent > 100 {
+		errs = append(errs, fmt.Sprintf("models.%s.shadow.percent: must be above 0 and at most 100", name))
+	}
+	return errs
+}
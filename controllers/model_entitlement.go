@@ -0,0 +1,156 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// GetModelEntitlements
+// @Title GetModelEntitlements
+// @Tag ModelEntitlement API
+// @Description get the model entitlements of an organization
+// @Param owner query string true "The owner (org) of the entitlements"
+// @Success 200 {array} object.ModelEntitlement The Response object
+// @router /get-model-entitlements [get]
+func (c *ApiController) GetModelEntitlements() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	entitlements, err := object.GetModelEntitlements(c.Input().Get("owner"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(entitlements)
+}
+
+// AddModelEntitlement
+// @Title AddModelEntitlement
+// @Tag ModelEntitlement API
+// @Description grant an organization a model entitlement
+// @Param body body object.ModelEntitlement true "The details of the entitlement"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-model-entitlement [post]
+func (c *ApiController) AddModelEntitlement() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var entitlement object.ModelEntitlement
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if entitlement.Owner == "" || entitlement.Name == "" {
+		c.ResponseError("owner and name are required")
+		return
+	}
+
+	success, err := object.AddModelEntitlement(&entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "model-entitlement", entitlement.Owner, entitlement.GetId(), nil, &entitlement)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateModelEntitlement
+// @Title UpdateModelEntitlement
+// @Tag ModelEntitlement API
+// @Description update a model entitlement
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The entitlement name"
+// @Param body body object.ModelEntitlement true "The details of the entitlement"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-model-entitlement [post]
+func (c *ApiController) UpdateModelEntitlement() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var entitlement object.ModelEntitlement
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetModelEntitlement(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateModelEntitlement(owner, name, &entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "model-entitlement", owner, entitlement.GetId(), before, &entitlement)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteModelEntitlement
+// @Title DeleteModelEntitlement
+// @Tag ModelEntitlement API
+// @Description revoke a model entitlement
+// @Param body body object.ModelEntitlement true "The details of the entitlement"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-model-entitlement [post]
+func (c *ApiController) DeleteModelEntitlement() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var entitlement object.ModelEntitlement
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetModelEntitlement(entitlement.Owner, entitlement.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteModelEntitlement(&entitlement)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "model-entitlement", entitlement.Owner, entitlement.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
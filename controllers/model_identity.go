@@ -0,0 +1,384 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/hanzoai/cloud/conf"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+const (
+	defaultIdentityCompany = "Hanzo AI Inc"
+	defaultIdentityWebsite = "hanzo.ai"
+)
+
+// Zen identity injection modes, chosen per request with
+// {"hanzo": {"identity": "..."}}.
+const (
+	zenIdentityPrepend = "prepend" // default: identity before the caller's system prompt
+	zenIdentityReplace = "replace" // identity replaces the caller's system prompt
+	zenIdentityOff     = "off"     // no identity prompt
+)
+
+// IdentityConfig is the identity prompt template of models.yaml, rendered
+// once per model (and per branded org) at load time.
+type IdentityConfig struct {
+	Company  string                      `yaml:"company"`
+	Website  string                      `yaml:"website"`
+	Template string                      `yaml:"template"`
+	Orgs     map[string]IdentityBranding `yaml:"orgs"`
+}
+
+// IdentityBranding overrides the branding of the identity prompts served to
+// one organization. Empty fields keep the defaults.
+type IdentityBranding struct {
+	Company  string                     `yaml:"company"`
+	Website  string                     `yaml:"website"`
+	Template string                     `yaml:"template"`
+	Models   map[string]BrandedModelDef `yaml:"models"` // org-facing model name → zen model and identity overrides
+}
+
+// BrandedModelDef is a model as one organization sees it: a name of its own
+// for a zen model ("acme-1" → zen4), or, keyed by the zen model's name, new
+// identity variables for it. Requests for the name are routed and billed as
+// the zen model.
+type BrandedModelDef struct {
+	Model            string           `yaml:"model"`           // zen model served; defaults to the key
+	IdentityPrompt   string           `yaml:"identity_prompt"` // literal prompt; takes precedence over the template
+	ModelIdentityDef `yaml:",inline"` // overrides of the zen model's template variables
+}
+
+// target returns the lowercase name of the model served for name.
+func (def BrandedModelDef) target(name string) string {
+	if def.Model != "" {
+		return strings.ToLower(def.Model)
+	}
+	return strings.ToLower(name)
+}
+
+// overrides reports whether def changes any template variable.
+func (def BrandedModelDef) overrides() bool {
+	return def.ModelIdentityDef != ModelIdentityDef{}
+}
+
+// apply layers def's template variables over vars.
+func (def BrandedModelDef) apply(vars identityPromptVars) identityPromptVars {
+	if def.Name != "" {
+		vars.Name = def.Name
+	}
+	if def.Description != "" {
+		vars.Description = def.Description
+	}
+	if def.Tier != "" {
+		vars.Tier = def.Tier
+	}
+	if def.Generation != 0 {
+		vars.Generation = def.Generation
+	}
+	if def.Note != "" {
+		vars.Note = def.Note
+	}
+	return vars
+}
+
+// ModelIdentityDef holds the template variables of one model.
+type ModelIdentityDef struct {
+	Name        string `yaml:"name"`        // display name, e.g. "Zen4 Pro"
+	Description string `yaml:"description"` // e.g. "a high-capability large language model"
+	Tier        string `yaml:"tier"`        // e.g. "professional tier"
+	Generation  int    `yaml:"generation"`  // Zen LM generation
+	Note        string `yaml:"note"`        // extra instruction appended to the prompt
+}
+
+// identityPromptVars is what identity templates can reference.
+type identityPromptVars struct {
+	Model       string
+	Name        string
+	Description string
+	Tier        string
+	Generation  int
+	Note        string
+	Company     string
+	Website     string
+}
+
+// renderIdentityPrompts renders the templated identity prompts of file.
+// It returns the default prompts and, per branded org, that org's prompts,
+// both keyed by lowercase model name. Models with a literal identity_prompt
+// keep it and have no org variants, unless an org's models entry overrides
+// them. An org's models entries are rendered last, so they win.
+func renderIdentityPrompts(file *ModelConfigFile) (map[string]string, map[string]map[string]string, error) {
+	prompts := map[string]string{}
+	orgPrompts := map[string]map[string]string{}
+
+	identity := file.Identity
+	company := identity.Company
+	if company == "" {
+		company = defaultIdentityCompany
+	}
+	website := identity.Website
+	if website == "" {
+		website = defaultIdentityWebsite
+	}
+
+	var base *template.Template
+	if identity.Template != "" {
+		var err error
+		if base, err = template.New("identity").Option("missingkey=error").Parse(identity.Template); err != nil {
+			return nil, nil, fmt.Errorf("identity.template: %w", err)
+		}
+	}
+	orgTemplates := map[string]*template.Template{}
+	for org, branding := range identity.Orgs {
+		orgTemplates[org] = base
+		if branding.Template != "" {
+			t, err := template.New(org).Option("missingkey=error").Parse(branding.Template)
+			if err != nil {
+				return nil, nil, fmt.Errorf("identity.orgs.%s.template: %w", org, err)
+			}
+			orgTemplates[org] = t
+		}
+	}
+
+	// brand returns vars with org's company and website.
+	brand := func(org string, vars identityPromptVars) identityPromptVars {
+		branding := identity.Orgs[org]
+		if branding.Company != "" {
+			vars.Company = branding.Company
+		}
+		if branding.Website != "" {
+			vars.Website = branding.Website
+		}
+		return vars
+	}
+	setOrgPrompt := func(org string, key string, prompt string) {
+		if orgPrompts[org] == nil {
+			orgPrompts[org] = map[string]string{}
+		}
+		orgPrompts[org][key] = prompt
+	}
+
+	defs := map[string]ModelDef{}
+	for name, def := range file.Models {
+		defs[strings.ToLower(name)] = def
+	}
+	modelVars := func(name string, def ModelDef) identityPromptVars {
+		vars := identityPromptVars{Model: name, Name: name, Company: company, Website: website}
+		if def.Identity != nil {
+			vars.Description = def.Identity.Description
+			vars.Tier = def.Identity.Tier
+			vars.Generation = def.Identity.Generation
+			vars.Note = def.Identity.Note
+			if def.Identity.Name != "" {
+				vars.Name = def.Identity.Name
+			}
+		}
+		return vars
+	}
+
+	for name, def := range file.Models {
+		key := strings.ToLower(name)
+		if def.IdentityPrompt != "" {
+			prompts[key] = strings.TrimSpace(def.IdentityPrompt)
+			continue
+		}
+		if def.Identity == nil {
+			continue
+		}
+		if base == nil {
+			return nil, nil, fmt.Errorf("models.%s.identity: identity.template is not set", name)
+		}
+
+		vars := modelVars(name, def)
+		prompt, err := renderIdentityPrompt(base, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("models.%s.identity: %w", name, err)
+		}
+		prompts[key] = prompt
+
+		for org := range identity.Orgs {
+			prompt, err = renderIdentityPrompt(orgTemplates[org], brand(org, vars))
+			if err != nil {
+				return nil, nil, fmt.Errorf("models.%s.identity for org %s: %w", name, org, err)
+			}
+			setOrgPrompt(org, key, prompt)
+		}
+	}
+
+	for org, branding := range identity.Orgs {
+		for name, branded := range branding.Models {
+			key := strings.ToLower(name)
+			target := branded.target(name)
+			def, ok := defs[target]
+			if !ok {
+				return nil, nil, fmt.Errorf("identity.orgs.%s.models.%s: model %s is not defined", org, name, target)
+			}
+
+			switch {
+			case branded.IdentityPrompt != "":
+				setOrgPrompt(org, key, strings.TrimSpace(branded.IdentityPrompt))
+			case !branded.overrides():
+				// A plain rename: the lookup serves the zen model's prompt.
+				continue
+			default:
+				if orgTemplates[org] == nil {
+					return nil, nil, fmt.Errorf("identity.orgs.%s.models.%s: identity.template is not set", org, name)
+				}
+				prompt, err := renderIdentityPrompt(orgTemplates[org], brand(org, branded.apply(modelVars(name, def))))
+				if err != nil {
+					return nil, nil, fmt.Errorf("identity.orgs.%s.models.%s: %w", org, name, err)
+				}
+				setOrgPrompt(org, key, prompt)
+			}
+		}
+	}
+
+	return prompts, orgPrompts, nil
+}
+
+// brandedModelNames returns, per org, its own model names mapped to the zen
+// models they serve, keyed by lowercase name.
+func brandedModelNames(identity IdentityConfig) map[string]map[string]string {
+	names := map[string]map[string]string{}
+	for org, branding := range identity.Orgs {
+		for name, branded := range branding.Models {
+			key := strings.ToLower(name)
+			if target := branded.target(name); target != key {
+				if names[org] == nil {
+					names[org] = map[string]string{}
+				}
+				names[org][key] = target
+			}
+		}
+	}
+	return names
+}
+
+func renderIdentityPrompt(t *template.Template, vars identityPromptVars) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// findIdentityPrompt looks up key in prompts, falling back through version
+// aliases (zen-mini → zen4-mini → zen3-mini).
+func findIdentityPrompt(prompts map[string]string, key string) (string, bool) {
+	if prompt, ok := prompts[key]; ok {
+		return prompt, true
+	}
+	if strings.HasPrefix(key, "zen-") {
+		suffix := key[4:]
+		if prompt, ok := prompts["zen4-"+suffix]; ok {
+			return prompt, true
+		}
+		if prompt, ok := prompts["zen3-"+suffix]; ok {
+			return prompt, true
+		}
+	}
+	return "", false
+}
+
+// hanzoRequestExtension is the "hanzo" object a chat request body may carry
+// next to the OpenAI or Anthropic fields.
+type hanzoRequestExtension struct {
+	Hanzo struct {
+		Identity     string `json:"identity"`
+		Conversation string `json:"conversation"` // chat completions only; see conversation.go
+		Store        string `json:"store"`        // see store_limits.go
+	} `json:"hanzo"`
+}
+
+// zenIdentityOverrideAllowed reports whether org may choose an identity mode
+// other than prepend: it must be listed in the comma-separated
+// zenIdentityOverrideOrgs config, where "*" allows every org. No org ("")
+// never may.
+func zenIdentityOverrideAllowed(org string) bool {
+	for _, allowed := range strings.Split(conf.GetConfigString("zenIdentityOverrideOrgs"), ",") {
+		allowed = strings.TrimSpace(allowed)
+		if org != "" && (allowed == "*" || allowed == org) {
+			return true
+		}
+	}
+	return false
+}
+
+// identityModeOrg returns the org that may choose the identity mode of a
+// request authenticated as user in org: org itself, once verified against
+// the user (see getCallerOrg), or "" for requests without a user, such as
+// widget and provider keys.
+func identityModeOrg(user *iamsdk.User, org string) string {
+	if user == nil {
+		return ""
+	}
+	return org
+}
+
+// getZenIdentityMode returns the identity mode a request body asks for,
+// prepend when it asks for none. Modes other than prepend are an error
+// unless org is trusted with zenIdentityOverrideOrgs.
+func getZenIdentityMode(body []byte, org string) (string, error) {
+	var extension hanzoRequestExtension
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, &extension)
+	}
+	return extension.zenIdentityMode(org)
+}
+
+// zenIdentityMode is getZenIdentityMode for a request body already decoded
+// along with its extension.
+func (extension *hanzoRequestExtension) zenIdentityMode(org string) (string, error) {
+	switch mode := extension.Hanzo.Identity; mode {
+	case "", zenIdentityPrepend:
+		return zenIdentityPrepend, nil
+	case zenIdentityReplace, zenIdentityOff:
+		if !zenIdentityOverrideAllowed(org) {
+			return "", fmt.Errorf("hanzo.identity %q is not enabled for this organization", mode)
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("hanzo.identity must be one of prepend, replace or off, got %q", mode)
+	}
+}
+
+// injectZenIdentity applies the identity prompt of a zen model to messages
+// in the given mode. Non-zen models (empty prompt) are left unchanged.
+func injectZenIdentity(messages []openai.ChatCompletionMessage, prompt string, mode string) []openai.ChatCompletionMessage {
+	if prompt == "" || mode == zenIdentityOff {
+		return messages
+	}
+
+	hasSystem := len(messages) > 0 && messages[0].Role == "system"
+	switch {
+	case hasSystem && mode == zenIdentityReplace:
+		messages[0].Content = prompt
+	case hasSystem:
+		messages[0].Content = prompt + "\n\n" + messages[0].Content
+	default:
+		messages = append([]openai.ChatCompletionMessage{{
+			Role:    "system",
+			Content: prompt,
+		}}, messages...)
+	}
+	return messages
+}
@@ -0,0 +1,83 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestGetZenIdentityMode(t *testing.T) {
+	t.Setenv("zenIdentityOverrideOrgs", "acme, initech")
+
+	tests := []struct {
+		body    string
+		org     string
+		want    string
+		wantErr bool
+	}{
+		{`{"model":"zen4"}`, "other", zenIdentityPrepend, false},
+		{`{"hanzo":{"identity":"prepend"}}`, "other", zenIdentityPrepend, false},
+		{`{"hanzo":{"identity":"off"}}`, "acme", zenIdentityOff, false},
+		{`{"hanzo":{"identity":"replace"}}`, "initech", zenIdentityReplace, false},
+		{`{"hanzo":{"identity":"off"}}`, "other", "", true},
+		{`{"hanzo":{"identity":"loud"}}`, "acme", "", true},
+		{`{"hanzo":{"identity":"off"}}`, identityModeOrg(nil, "acme"), "", true},
+		{`{"hanzo":{"identity":"off"}}`, identityModeOrg(&iamsdk.User{Owner: "acme"}, "acme"), zenIdentityOff, false},
+	}
+	for _, tc := range tests {
+		got, err := getZenIdentityMode([]byte(tc.body), tc.org)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("getZenIdentityMode(%s, %q) = %q, %v; want %q, error %v", tc.body, tc.org, got, err, tc.want, tc.wantErr)
+		}
+	}
+
+	t.Setenv("zenIdentityOverrideOrgs", "*")
+	if _, err := getZenIdentityMode([]byte(`{"hanzo":{"identity":"off"}}`), ""); err == nil {
+		t.Error("getZenIdentityMode without an org allowed identity off")
+	}
+}
+
+func TestInjectZenIdentity(t *testing.T) {
+	withSystem := func() []openai.ChatCompletionMessage {
+		return []openai.ChatCompletionMessage{
+			{Role: "system", Content: "Answer in French."},
+			{Role: "user", Content: "Who are you?"},
+		}
+	}
+
+	messages := injectZenIdentity(withSystem(), "You are Zen4.", zenIdentityPrepend)
+	if len(messages) != 2 || messages[0].Content != "You are Zen4.\n\nAnswer in French." {
+		t.Errorf("prepend = %+v", messages)
+	}
+	messages = injectZenIdentity(withSystem(), "You are Zen4.", zenIdentityReplace)
+	if len(messages) != 2 || messages[0].Content != "You are Zen4." {
+		t.Errorf("replace = %+v", messages)
+	}
+	messages = injectZenIdentity(withSystem(), "You are Zen4.", zenIdentityOff)
+	if messages[0].Content != "Answer in French." {
+		t.Errorf("off = %+v", messages)
+	}
+	messages = injectZenIdentity(withSystem()[1:], "You are Zen4.", zenIdentityReplace)
+	if len(messages) != 2 || messages[0].Role != "system" || messages[0].Content != "You are Zen4." {
+		t.Errorf("replace without a system message = %+v", messages)
+	}
+	messages = injectZenIdentity(withSystem(), "", zenIdentityReplace)
+	if messages[0].Content != "Answer in French." {
+		t.Errorf("non-zen model = %+v", messages)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+// otherModelLabel replaces model names that failed before routing, so that
+// arbitrary client-supplied names cannot blow up metric cardinality.
+const otherModelLabel = "other"
+
+// modelMetricLabels returns the model, provider, tier and status labels for a
+// usage record. Tier is "premium" for premium models and "standard" otherwise.
+func modelMetricLabels(record *usageRecord) []string {
+	model := record.Model
+	if record.Status != "success" && resolveModelRoute(model) == nil {
+		model = otherModelLabel
+	}
+	provider := record.Provider
+	if provider == "" {
+		provider = "unknown"
+	}
+	tier := "standard"
+	if record.Premium {
+		tier = "premium"
+	}
+	status := record.Status
+	if status == "" {
+		status = "unknown"
+	}
+	return []string{model, provider, tier, status}
+}
+
+// usageOrganization returns the organization a usage record belongs to.
+func usageOrganization(record *usageRecord) string {
+	if record.Organization != "" {
+		return record.Organization
+	}
+	if record.Owner != "" {
+		return record.Owner
+	}
+	if owner, _, found := strings.Cut(record.User, "/"); found {
+		return owner
+	}
+	return ""
+}
+
+// trackTenantInflight counts a request as in flight for org until the
+// returned function is called.
+func trackTenantInflight(org string) func() {
+	gauge := object.TenantInflightRequests.WithLabelValues(object.TenantMetricLabel(org))
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// observeTenantMetrics exports a finished gateway request to the
+// per-organization metrics.
+func observeTenantMetrics(record *usageRecord, billedCents float64) {
+	org := object.TenantMetricLabel(usageOrganization(record))
+	status := record.Status
+	if status == "" {
+		status = "unknown"
+	}
+
+	object.TenantRequests.WithLabelValues(org, status).Inc()
+	object.TenantTokens.WithLabelValues(org, "prompt").Add(float64(record.PromptTokens))
+	object.TenantTokens.WithLabelValues(org, "completion").Add(float64(record.CompletionTokens))
+	if billedCents > 0 {
+		object.TenantBilledCents.WithLabelValues(org).Add(billedCents)
+	}
+	if record.LatencyMs > 0 {
+		object.TenantLatency.WithLabelValues(org).Observe((time.Duration(record.LatencyMs) * time.Millisecond).Seconds())
+	}
+}
+
+// observeModelMetrics exports a finished gateway request to the per-model
+// Prometheus metrics. It runs for every usage record, whether or not billing
+// is configured.
+func observeModelMetrics(record *usageRecord) {
+	labels := modelMetricLabels(record)
+
+	object.ModelRequests.WithLabelValues(labels...).Inc()
+	object.ModelPromptTokens.WithLabelValues(labels...).Add(float64(record.PromptTokens))
+	object.ModelCompletionTokens.WithLabelValues(labels...).Add(float64(record.CompletionTokens))
+
+	billedCents := 0.0
+	if record.Status == "success" {
+		microCents := calculateCostMicroCentsWithCache(
+			record.Model, record.PromptTokens, record.CompletionTokens,
+			record.CacheReadTokens, record.CacheWriteTokens,
+		)
+		billedCents = float64(microCents) / util.MicroCentsPerCent
+		object.ModelBilledCents.WithLabelValues(labels...).Add(billedCents)
+	}
+	observeTenantMetrics(record, billedCents)
+	observePromptTokenDrift(record)
+	observeExperiment(record)
+	if record.ErrorClass != "" {
+		object.ModelUpstreamErrors.WithLabelValues(labels[0], labels[1], record.ErrorClass).Inc()
+	}
+
+	if record.LatencyMs > 0 {
+		seconds := (time.Duration(record.LatencyMs) * time.Millisecond).Seconds()
+		if record.Stream {
+			object.ModelStreamDuration.WithLabelValues(labels...).Observe(seconds)
+		} else {
+			object.ModelUpstreamLatency.WithLabelValues(labels...).Observe(seconds)
+		}
+	}
+}
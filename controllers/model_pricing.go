@@ -18,7 +18,9 @@ import (
 	"math"
 	"strings"
 
+	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
 )
 
 // StarterCreditDollars is the amount granted to new users as free credit.
@@ -115,6 +117,38 @@ var modelPricing = map[string]modelPrice{
 	"openai-direct/o3":          {InputPerMillion: 10.00, OutputPerMillion: 40.00},
 	"openai-direct/o3-mini":     {InputPerMillion: 1.10, OutputPerMillion: 4.40},
 
+	// ── xAI and DeepSeek direct premium models ──────────────────────
+
+	"grok-4":            {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"grok-4-fast":       {InputPerMillion: 0.20, OutputPerMillion: 0.50},
+	"grok-code-fast-1":  {InputPerMillion: 0.20, OutputPerMillion: 1.50},
+	"grok-3":            {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"grok-3-mini":       {InputPerMillion: 0.30, OutputPerMillion: 0.50},
+	"deepseek-chat":     {InputPerMillion: 0.28, OutputPerMillion: 0.42},
+	"deepseek-reasoner": {InputPerMillion: 0.28, OutputPerMillion: 0.42},
+
+	// ── Cohere and Mistral direct premium models ────────────────────
+	"command-a":               {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"command-r-plus":          {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"command-r":               {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	"mistral-large":           {InputPerMillion: 2.00, OutputPerMillion: 6.00},
+	"mistral-medium":          {InputPerMillion: 0.40, OutputPerMillion: 2.00},
+	"mistral-small":           {InputPerMillion: 0.10, OutputPerMillion: 0.30},
+	"codestral":               {InputPerMillion: 0.30, OutputPerMillion: 0.90},
+	"embed-v4.0":              {InputPerMillion: 0.12},
+	"embed-english-v3.0":      {InputPerMillion: 0.10},
+	"embed-multilingual-v3.0": {InputPerMillion: 0.10},
+	"mistral-embed":           {InputPerMillion: 0.10},
+	"codestral-embed":         {InputPerMillion: 0.15},
+	// Rerank usage is counted in search units of up to 100 documents: $2.00
+	// per 1K search units.
+	"rerank-v3.5":                        {InputPerMillion: 2000},
+	"rerank-english-v3.0":                {InputPerMillion: 2000},
+	"rerank-multilingual-v3.0":           {InputPerMillion: 2000},
+	"jina-reranker-v2-base-multilingual": {InputPerMillion: 2000},
+	"jina-reranker-m0":                   {InputPerMillion: 2000},
+	"jina-colbert-v2":                    {InputPerMillion: 2000},
+
 	// ── Zen branded models (use Fireworks pricing via upstream) ──────
 
 	// Zen4 models
@@ -150,6 +184,35 @@ var modelPricing = map[string]modelPrice{
 	"zen-embedding":   {InputPerMillion: 0.39, OutputPerMillion: 0.39},
 }
 
+// upstreamRef names an upstream model of a provider.
+type upstreamRef struct {
+	provider string // DB provider name
+	upstream string // upstream model ID
+}
+
+// upstreamCosts is what providers charge us per upstream model, the base the
+// sell prices above are marked up from. The price sync proposes updates to it
+// from the providers' published prices; see pricing_sync.go.
+var upstreamCosts = map[upstreamRef]modelPrice{
+	{"fireworks", "accounts/fireworks/models/glm-5"}:                     {InputPerMillion: 1.00, OutputPerMillion: 3.20},
+	{"fireworks", "accounts/fireworks/models/glm-4p7"}:                   {InputPerMillion: 0.60, OutputPerMillion: 2.20},
+	{"fireworks", "accounts/fireworks/models/deepseek-v3p1"}:             {InputPerMillion: 0.56, OutputPerMillion: 1.68},
+	{"fireworks", "accounts/fireworks/models/deepseek-v3p2"}:             {InputPerMillion: 0.56, OutputPerMillion: 1.68},
+	{"fireworks", "accounts/fireworks/models/kimi-k2-instruct-0905"}:     {InputPerMillion: 0.60, OutputPerMillion: 2.50},
+	{"fireworks", "accounts/fireworks/models/kimi-k2-thinking"}:          {InputPerMillion: 0.60, OutputPerMillion: 2.50},
+	{"fireworks", "accounts/fireworks/models/kimi-k2p5"}:                 {InputPerMillion: 0.60, OutputPerMillion: 3.00},
+	{"fireworks", "accounts/fireworks/models/minimax-m2p1"}:              {InputPerMillion: 0.30, OutputPerMillion: 1.20},
+	{"fireworks", "accounts/fireworks/models/minimax-m2p5"}:              {InputPerMillion: 0.30, OutputPerMillion: 1.20},
+	{"fireworks", "accounts/cogito/models/cogito-671b-v2-p1"}:            {InputPerMillion: 1.20, OutputPerMillion: 1.20},
+	{"fireworks", "accounts/fireworks/models/gpt-oss-120b"}:              {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	{"fireworks", "accounts/fireworks/models/gpt-oss-20b"}:               {InputPerMillion: 0.07, OutputPerMillion: 0.30},
+	{"fireworks", "accounts/fireworks/models/mixtral-8x22b-instruct"}:    {InputPerMillion: 0.90, OutputPerMillion: 0.90},
+	{"fireworks", "accounts/fireworks/models/qwen3-8b"}:                  {InputPerMillion: 0.20, OutputPerMillion: 0.20},
+	{"fireworks", "accounts/fireworks/models/qwen3-vl-30b-a3b-instruct"}: {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	{"fireworks", "accounts/fireworks/models/qwen3-vl-30b-a3b-thinking"}: {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	{"fireworks", "accounts/fireworks/models/llama-v3p3-70b-instruct"}:   {InputPerMillion: 0.90, OutputPerMillion: 0.90},
+}
+
 // DO-AI alias pricing (same as their base model)
 var aliasPricing = map[string]string{
 	"openai/gpt-4o":                        "gpt-4o",
@@ -169,7 +232,12 @@ func getModelPrice(model string) modelPrice {
 }
 
 func getModelPriceForOrg(model string, orgId string) modelPrice {
-	// Check DB route pricing first (org-specific -> global)
+	return GetModelConfig().GetPriceForOrg(model, orgId)
+}
+
+// GetPriceForOrg returns the pricing of a model for an org: its DB route's
+// prices (org-specific, then global) take precedence over the config's.
+func (mc *ModelConfig) GetPriceForOrg(model string, orgId string) modelPrice {
 	dbRoute, err := object.ResolveModelRouteFromDB(strings.ToLower(model), orgId)
 	if err == nil && dbRoute != nil && (dbRoute.InputPrice > 0 || dbRoute.OutputPrice > 0) {
 		return modelPrice{
@@ -177,40 +245,78 @@ func getModelPriceForOrg(model string, orgId string) modelPrice {
 			OutputPerMillion: dbRoute.OutputPrice,
 		}
 	}
+	return mc.GetPrice(model)
+}
 
-	// YAML config pricing
-	if cfg := GetModelConfig(); cfg != nil {
-		return cfg.GetPrice(model)
-	}
+// calculateCostCents computes the cost in cents for a model call.
+func calculateCostCents(model string, promptTokens, completionTokens int) int64 {
+	return calculateCostCentsWithCache(model, promptTokens, completionTokens, 0, 0)
+}
 
-	// Static fallback
-	m := strings.ToLower(model)
+// calculateCostCentsWithCache computes cost in cents including cache token pricing.
+// The exact cost is rounded to the nearest cent; when billingMinIncrementCents
+// is configured, any non-zero usage is billed at least that many cents.
+func calculateCostCentsWithCache(model string, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens int) int64 {
+	microCents := calculateCostMicroCentsWithCache(model, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens)
+	hasUsage := promptTokens > 0 || completionTokens > 0 || cacheReadTokens > 0 || cacheWriteTokens > 0
+	return roundCostCents(microCents, hasUsage)
+}
 
-	// Direct lookup
-	if price, ok := modelPricing[m]; ok {
-		return price
+// roundCostCents rounds a cost in micro-cents to the cents billed for it,
+// at least billingMinIncrementCents when the call had usage.
+func roundCostCents(microCents int64, hasUsage bool) int64 {
+	costCents := (microCents + util.MicroCentsPerCent/2) / util.MicroCentsPerCent
+	if minCents := billingMinIncrementCents(); hasUsage && costCents < minCents {
+		costCents = minCents
 	}
+	return costCents
+}
 
-	// Check aliases
-	if base, ok := aliasPricing[m]; ok {
-		if price, ok := modelPricing[base]; ok {
-			return price
-		}
+// calculateCostMicroCentsWithCache computes the exact cost of a model call in
+// micro-cents (1 cent = 1,000,000 micro-cents), without any rounding to whole
+// cents.
+func calculateCostMicroCentsWithCache(model string, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens int) int64 {
+	return calculateUsageCost(model, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens).total()
+}
+
+// usageCost is the cost of a model call in micro-cents, by kind of token.
+type usageCost struct {
+	input      int64
+	output     int64
+	cacheRead  int64
+	cacheWrite int64
+}
+
+func (c usageCost) total() int64 {
+	return c.input + c.output + c.cacheRead + c.cacheWrite
+}
+
+// percent returns percent of c.
+func (c usageCost) percent(percent int64) usageCost {
+	return usageCost{
+		input:      c.input * percent / 100,
+		output:     c.output * percent / 100,
+		cacheRead:  c.cacheRead * percent / 100,
+		cacheWrite: c.cacheWrite * percent / 100,
 	}
+}
 
-	// Default: conservative pricing for unknown models
-	return modelPrice{InputPerMillion: 1.00, OutputPerMillion: 4.00}
+// inputCents returns the cost of the prompt, cached tokens included, in
+// fractional cents.
+func (c usageCost) inputCents() float64 {
+	return float64(c.input+c.cacheRead+c.cacheWrite) / util.MicroCentsPerCent
 }
 
-// calculateCostCents computes the cost in cents for a model call.
-func calculateCostCents(model string, promptTokens, completionTokens int) int64 {
-	return calculateCostCentsWithCache(model, promptTokens, completionTokens, 0, 0)
+// outputCents returns the cost of the completion in fractional cents.
+func (c usageCost) outputCents() float64 {
+	return float64(c.output) / util.MicroCentsPerCent
 }
 
-// calculateCostCentsWithCache computes cost in cents including cache token pricing.
-// Cache-read tokens are billed at 10% of input price (matching Anthropic).
-// Cache-write tokens are billed at the same rate as input tokens.
-func calculateCostCentsWithCache(model string, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens int) int64 {
+// calculateUsageCost prices each kind of token of a model call at the
+// model's rate for it. Cache-read tokens are billed at 10% of input price
+// (matching Anthropic). Cache-write tokens are billed at the same rate as
+// input tokens.
+func calculateUsageCost(model string, promptTokens, completionTokens, cacheReadTokens, cacheWriteTokens int) usageCost {
 	price := getModelPrice(model)
 
 	// Cache-read price: use explicit CacheReadPerMillion if set, else 10% of input
@@ -225,18 +331,22 @@ func calculateCostCentsWithCache(model string, promptTokens, completionTokens, c
 		cacheWriteRate = price.InputPerMillion
 	}
 
-	inputCost := float64(promptTokens) * price.InputPerMillion / 1_000_000.0
-	outputCost := float64(completionTokens) * price.OutputPerMillion / 1_000_000.0
-	cacheReadCost := float64(cacheReadTokens) * cacheReadRate / 1_000_000.0
-	cacheWriteCost := float64(cacheWriteTokens) * cacheWriteRate / 1_000_000.0
-
-	totalDollars := inputCost + outputCost + cacheReadCost + cacheWriteCost
-	costCents := int64(math.Round(totalDollars * 100))
-
-	// Minimum 1 cent for any non-zero usage
-	if costCents <= 0 && (promptTokens > 0 || completionTokens > 0 || cacheReadTokens > 0 || cacheWriteTokens > 0) {
-		costCents = 1
+	// A dollar per 1M tokens is 1e8 micro-cents per 1M tokens, so 100
+	// micro-cents per token.
+	microCents := func(tokens int, dollarsPerMillion float64) int64 {
+		return int64(math.Round(float64(tokens) * dollarsPerMillion * 100))
+	}
+	return usageCost{
+		input:      microCents(promptTokens, price.InputPerMillion),
+		output:     microCents(completionTokens, price.OutputPerMillion),
+		cacheRead:  microCents(cacheReadTokens, cacheReadRate),
+		cacheWrite: microCents(cacheWriteTokens, cacheWriteRate),
 	}
+}
 
-	return costCents
+// billingMinIncrementCents returns the configured minimum charge per call in
+// cents (app.conf / env "billingMinIncrementCents"). Zero (the default) bills
+// exact micro-cent amounts and carries sub-cent remainders between calls.
+func billingMinIncrementCents() int64 {
+	return int64(conf.GetConfigInt("billingMinIncrementCents"))
 }
@@ -118,6 +118,9 @@ func (c *ApiController) AddModelRoute() {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("add", "model-route", route.Owner, route.Owner+"/"+route.ModelName, nil, &route)
+	}
 
 	c.ResponseOk(success)
 }
@@ -142,11 +145,20 @@ func (c *ApiController) UpdateModelRoute() {
 		return
 	}
 
+	before, err := object.GetModelRoute(owner, modelName)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	success, err := object.UpdateModelRoute(owner, modelName, &route)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("update", "model-route", owner, owner+"/"+modelName, before, &route)
+	}
 
 	c.ResponseOk(success)
 }
@@ -166,11 +178,20 @@ func (c *ApiController) DeleteModelRoute() {
 		return
 	}
 
+	before, err := object.GetModelRoute(route.Owner, route.ModelName)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	success, err := object.DeleteModelRoute(&route)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("delete", "model-route", route.Owner, route.Owner+"/"+route.ModelName, before, nil)
+	}
 
 	c.ResponseOk(success)
 }
@@ -17,7 +17,6 @@ package controllers
 import (
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/hanzoai/cloud/object"
 )
@@ -26,16 +25,26 @@ import (
 type modelRouteFallback struct {
 	providerName  string
 	upstreamModel string
+	weight        int // Share of traffic sent here first; 0 only takes failover
 }
 
 // modelRoute maps a user-facing model name to an upstream provider and model ID.
 type modelRoute struct {
-	providerName  string               // DB provider name: "do-ai", "fireworks", "openai-direct"
-	upstreamModel string               // Model ID sent to upstream API
-	fallbacks     []modelRouteFallback // Alternate providers tried on error
-	premium       bool                 // Requires positive balance
-	hidden        bool                 // If true, excluded from /api/models listing (still callable)
-	ownedBy       string               // Override for owned_by in model listing (default: providerName)
+	providerName    string               // DB provider name: "do-ai", "fireworks", "openai-direct"
+	upstreamModel   string               // Model ID sent to upstream API
+	fallbacks       []modelRouteFallback // Alternate providers tried on error
+	premium         bool                 // Requires positive balance
+	hidden          bool                 // If true, excluded from /api/models listing (still callable)
+	ownedBy         string               // Override for owned_by in model listing (default: providerName)
+	entitlement     string               // If set, listed only for orgs holding this entitlement (still callable)
+	residency       string               // Set when restricted to the upstreams of an org's residency region
+	weight          int                  // Primary's share of traffic when fallbacks are weighted (default 1)
+	timeouts        routeTimeouts        // Bounds on upstream calls (see route_timeouts.go)
+	contextWindow   int                  // Context window in tokens; 0 when not configured
+	maxOutputTokens int                  // Most tokens one response may have; 0 when not configured
+	capabilities    []string             // Sorted, see modelCapabilities
+	shadow          *routeShadow         // Candidate upstream mirrored for comparison (see shadow_traffic.go)
+	thinkTags       string               // How reasoning in <think> tags is sent (see think_tags.go); "" passes it through
 }
 
 // modelRoutes is the static routing table. Keys are user-facing model names
@@ -110,6 +119,24 @@ var modelRoutes = map[string]modelRoute{
 	"openai-direct/o3":          {providerName: "openai-direct", upstreamModel: "o3", premium: true, hidden: true},
 	"openai-direct/o3-mini":     {providerName: "openai-direct", upstreamModel: "o3-mini", premium: true, hidden: true},
 
+	// ── xAI and DeepSeek direct premium models (7) ───────────────────────
+	"grok-4":            {providerName: "xai", upstreamModel: "grok-4", premium: true},
+	"grok-4-fast":       {providerName: "xai", upstreamModel: "grok-4-fast-reasoning", premium: true},
+	"grok-code-fast-1":  {providerName: "xai", upstreamModel: "grok-code-fast-1", premium: true},
+	"grok-3":            {providerName: "xai", upstreamModel: "grok-3", premium: true},
+	"grok-3-mini":       {providerName: "xai", upstreamModel: "grok-3-mini", premium: true},
+	"deepseek-chat":     {providerName: "deepseek", upstreamModel: "deepseek-chat", premium: true, fallbacks: []modelRouteFallback{{providerName: "fireworks", upstreamModel: "accounts/fireworks/models/deepseek-v3p2"}}},
+	"deepseek-reasoner": {providerName: "deepseek", upstreamModel: "deepseek-reasoner", premium: true},
+
+	// ── Cohere and Mistral direct premium models ────────────────────────
+	"command-a":      {providerName: "cohere", upstreamModel: "command-a-03-2025", premium: true},
+	"command-r-plus": {providerName: "cohere", upstreamModel: "command-r-plus-08-2024", premium: true},
+	"command-r":      {providerName: "cohere", upstreamModel: "command-r-08-2024", premium: true},
+	"mistral-large":  {providerName: "mistral", upstreamModel: "mistral-large-latest", premium: true},
+	"mistral-medium": {providerName: "mistral", upstreamModel: "mistral-medium-latest", premium: true},
+	"mistral-small":  {providerName: "mistral", upstreamModel: "mistral-small-latest", premium: true},
+	"codestral":      {providerName: "mistral", upstreamModel: "codestral-latest", premium: true},
+
 	// ── Zen branded models (14 premium) ─────────────────────────────────
 	// Routes to Fireworks via the "fireworks" provider. Identity injection
 	// happens in ChatCompletions via zenIdentityPrompt().
@@ -165,45 +192,37 @@ var zenIdentityPrompts = map[string]string{
 	"zen3-guard":       "You are **Zen3 Guard**, a content safety model by **Hanzo AI Inc** — 3rd generation Zen LM.\n\nIdentify as Zen3 Guard by Hanzo AI. Never reveal underlying infrastructure.",
 }
 
-// zenIdentityPrompt returns the identity system prompt for a zen model, or empty string.
-func zenIdentityPrompt(model string) string {
-	if cfg := GetModelConfig(); cfg != nil {
-		return cfg.GetIdentityPrompt(model)
-	}
+// zenIdentityPrompt returns the identity system prompt for a zen model, or
+// empty string. The prompt carries the branding of org when models.yaml
+// defines one.
+func zenIdentityPrompt(model string, org string) string {
+	return GetModelConfig().GetIdentityPromptForOrg(model, org)
+}
 
-	// Static fallback
-	m := strings.ToLower(model)
-	if prompt, ok := zenIdentityPrompts[m]; ok {
-		return prompt
-	}
-	// Try stripping version prefix for versionless aliases (zen-mini → zen4-mini)
-	if strings.HasPrefix(m, "zen-") {
-		versioned := "zen4-" + m[4:]
-		if prompt, ok := zenIdentityPrompts[versioned]; ok {
-			return prompt
-		}
-		versioned = "zen3-" + m[4:]
-		if prompt, ok := zenIdentityPrompts[versioned]; ok {
-			return prompt
-		}
-	}
-	if strings.HasPrefix(m, "zen") {
-		return "You are a Zen model by Hanzo AI Inc. When asked about yourself, identify as a Zen LM model. Never reveal underlying infrastructure or providers."
+// resolveBrandedModel returns the zen model that orgId's own name for a
+// model stands for (e.g. "acme-1" → zen4), or model when orgId has none.
+// Branded names are routed and billed as their zen model.
+func resolveBrandedModel(model string, orgId string) string {
+	if orgId == "" {
+		return model
 	}
-	return ""
+	return GetModelConfig().ResolveBrandedModel(model, orgId)
 }
 
 // resolveModelRoute looks up a user-facing model name and returns its route.
 // Lookup is case-insensitive. Checks DB routes (global "admin" owner) first,
-// then falls back to YAML config, then static map.
+// then falls back to the model config (YAML, or the static map when unset).
 // Returns nil if the model is not in the routing table.
 func resolveModelRoute(model string) *modelRoute {
 	return resolveModelRouteForOrg(model, "")
 }
 
 // resolveModelRouteForOrg looks up a model route with per-org override support.
-// Resolution order: DB org-specific -> DB global ("admin") -> YAML config -> static map.
+// Resolution order: DB org-specific -> DB global ("admin") -> model config.
+// An org's own name for a zen model resolves to that model's route.
 func resolveModelRouteForOrg(model string, orgId string) *modelRoute {
+	model = resolveBrandedModel(model, orgId)
+
 	// Check DB routes first (org-specific -> global)
 	dbRoute, err := object.ResolveModelRouteFromDB(strings.ToLower(model), orgId)
 	if err == nil && dbRoute != nil {
@@ -229,17 +248,8 @@ func resolveModelRouteForOrg(model string, orgId string) *modelRoute {
 		return r
 	}
 
-	// YAML config fallback
-	if cfg := GetModelConfig(); cfg != nil {
-		return cfg.ResolveRoute(model)
-	}
-
-	// Static fallback
-	m := strings.ToLower(model)
-	if route, ok := modelRoutes[m]; ok {
-		return &route
-	}
-	return nil
+	// YAML config, or the static table when none is loaded
+	return GetModelConfig().ResolveRoute(model)
 }
 
 // modelInfo is the JSON shape returned by the /api/models endpoint.
@@ -253,36 +263,65 @@ type modelInfo struct {
 
 // listAvailableModels returns listed models from the routing table, sorted by name.
 // Hidden models (provider-prefixed aliases, upstream-named routes) are excluded
-// from the listing but remain callable via the completions endpoint.
+// from the listing but remain callable via the completions endpoint. This is
+// the public catalog: models requiring an entitlement are excluded too.
 func listAvailableModels() []modelInfo {
-	if cfg := GetModelConfig(); cfg != nil {
-		return cfg.ListModels()
-	}
+	return GetModelConfig().ListModels()
+}
 
-	// Static fallback
-	now := time.Now().Unix()
-	models := make([]modelInfo, 0, len(modelRoutes))
+// listModelsForOrg returns the catalog of orgId: the public catalog plus the
+// models its entitlements unlock. An empty orgId gets the public catalog.
+func listModelsForOrg(orgId string) []modelInfo {
+	return getModelCatalogScope(orgId).models()
+}
 
-	for name, route := range modelRoutes {
-		if route.hidden {
-			continue
-		}
-		owner := route.ownedBy
-		if owner == "" {
-			owner = route.providerName
+// withBrandedModels adds an org's own names for listed zen models to models,
+// owned by the org.
+func withBrandedModels(models []modelInfo, branded map[string]string, orgId string) []modelInfo {
+	if len(branded) == 0 {
+		return models
+	}
+	listed := make(map[string]modelInfo, len(models))
+	for _, info := range models {
+		listed[info.ID] = info
+	}
+	for name, target := range branded {
+		if info, ok := listed[target]; ok {
+			info.ID = name
+			info.OwnedBy = orgId
+			models = append(models, info)
 		}
-		models = append(models, modelInfo{
-			ID:      name,
-			Object:  "model",
-			Created: now,
-			OwnedBy: owner,
-			Premium: route.premium,
-		})
 	}
-
 	sort.Slice(models, func(i, j int) bool {
 		return models[i].ID < models[j].ID
 	})
-
 	return models
 }
+
+// getCatalogOrgForToken returns the organization of a bearer token: a ZAP
+// session, a gateway token, a hanzo.id JWT or an IAM API key, the last
+// through the access key cache as sent by the client source. Other tokens,
+// and tokens that fail verification, get "", the public catalog.
+func getCatalogOrgForToken(token string, source string) string {
+	if user := getZapSessionUser(token); user != nil {
+		return user.Owner
+	}
+	switch {
+	case isGatewayToken(token):
+		if claims, err := parseGatewayToken(token); err == nil {
+			if claims.Org != "" {
+				return claims.Org
+			}
+			return claims.Owner
+		}
+	case isJwtToken(token):
+		if claims, err := validateJwtToken(token); err == nil {
+			return claims.User.Owner
+		}
+	case isIAMApiKey(token):
+		if user, err := getUserByAccessKey(token, source); err == nil && user != nil {
+			return user.Owner
+		}
+	}
+	return ""
+}
@@ -163,6 +163,10 @@ func TestModelRoutes_ProviderNamesAreKnown(t *testing.T) {
 		"do-ai":         true,
 		"fireworks":     true,
 		"openai-direct": true,
+		"xai":           true,
+		"deepseek":      true,
+		"cohere":        true,
+		"mistral":       true,
 	}
 	for name, route := range modelRoutes {
 		if !known[route.providerName] {
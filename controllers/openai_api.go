@@ -17,8 +17,10 @@ package controllers
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,18 +34,34 @@ import (
 	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/model"
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/proxy"
 	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 	"github.com/sashabaranov/go-openai"
+	"golang.org/x/sync/singleflight"
 )
 
 // getUserBalance returns the current balance for a user by fetching from Commerce.
 // Balance is mutable financial state (not identity) so it is never read from the
 // JWT — always checked against the source of truth. Caching is handled by the
 // router-level BalanceGate (routers/filter_balance.go); this controller-level
-// call is a defense-in-depth backstop and does not maintain its own cache.
+// call is a defense-in-depth backstop and does not maintain its own cache,
+// but concurrent calls for the same user share one Commerce request.
 // The userId should be in "owner/name" format (e.g., "hanzo/alice").
 func getUserBalance(userId string) (float64, error) {
+	balance, err, _ := userBalanceGroup.Do(userId, func() (interface{}, error) {
+		return fetchUserBalance(userId)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return balance.(float64), nil
+}
+
+// userBalanceGroup coalesces concurrent getUserBalance calls per user.
+var userBalanceGroup singleflight.Group
+
+func fetchUserBalance(userId string) (float64, error) {
 	commerceEndpoint := conf.GetConfigString("commerceEndpoint")
 	if commerceEndpoint == "" {
 		return 0, fmt.Errorf("commerceEndpoint is not configured")
@@ -81,6 +99,7 @@ func getUserBalance(userId string) (float64, error) {
 
 	// Convert cents to dollars for backward compatibility with existing balance > 0 check
 	balanceDollars := float64(result.Available) / 100.0
+	notifyBalanceLow(userId, balanceDollars)
 
 	return balanceDollars, nil
 }
@@ -253,7 +272,7 @@ func resolveProviderFromWidgetKey(token string, requestedModel string, lang stri
 		)
 	}
 
-	provider, err := object.GetModelProviderByName(route.providerName)
+	provider, err := object.GetModelProviderByName(route.providerName, "widget")
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to get provider %q: %s", route.providerName, err.Error())
 	}
@@ -277,8 +296,23 @@ func widgetAllowedModelsList() string {
 // resolveProviderFromJwt validates a hanzo.id JWT token and returns the
 // appropriate model provider for the requested model, plus the translated
 // upstream model name.
+// requestSecretCaller names the caller of a model request in the secret
+// audit log: its user, else the widget or provider key it authenticated
+// with.
+func requestSecretCaller(token string, user *iamsdk.User, provider *object.Provider) string {
+	switch {
+	case user != nil:
+		return user.Owner + "/" + user.Name
+	case isWidgetKey(token):
+		return "widget"
+	case provider != nil:
+		return "provider-key:" + provider.Owner + "/" + provider.Name
+	}
+	return ""
+}
+
 func resolveProviderFromJwt(token string, requestedModel string, lang string) (*object.Provider, *iamsdk.User, string, error) {
-	claims, err := iamsdk.ParseJwtToken(token)
+	claims, err := validateJwtToken(token)
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("invalid hanzo.id token: %s", err.Error())
 	}
@@ -287,14 +321,18 @@ func resolveProviderFromJwt(token string, requestedModel string, lang string) (*
 	return resolveProviderForUser(user, requestedModel, lang)
 }
 
-// resolveProviderFromIAMKey validates an IAM API key (hk-{accessKey})
-// and returns the model provider + user, same as JWT path.
-func resolveProviderFromIAMKey(apiKey string, requestedModel string, lang string) (*object.Provider, *iamsdk.User, string, error) {
+// resolveProviderFromIAMKey validates an IAM API key (hk-{accessKey}) sent
+// by the client source and returns the model provider + user, same as JWT
+// path.
+func resolveProviderFromIAMKey(apiKey string, source string, requestedModel string, lang string) (*object.Provider, *iamsdk.User, string, error) {
 	// IAM API key format: hk-{uuid}
 	// Look up user by accessKey via IAM API
 	accessKey := apiKey // the full token including hk- prefix is the accessKey
 
-	user, err := getUserByAccessKey(accessKey)
+	user, err := getUserByAccessKey(accessKey, source)
+	if errors.Is(err, errAccessKeyExpired) {
+		return nil, nil, "", err
+	}
 	if err != nil {
 		// IAM may return "password or code is incorrect" for service-account users
 		// (cloud-agent, etc.) due to a known IAM deployment quirk where the
@@ -313,6 +351,9 @@ func resolveProviderFromIAMKey(apiKey string, requestedModel string, lang string
 	if user == nil {
 		return nil, nil, "", fmt.Errorf("invalid API key")
 	}
+	if err = checkMemberKeys(user); err != nil {
+		return nil, nil, "", err
+	}
 
 	return resolveProviderForUser(user, requestedModel, lang)
 }
@@ -343,8 +384,9 @@ func tryCloudAgentKeyFallback(apiKey string) *iamsdk.User {
 // resolveProviderForUser is the shared logic for JWT and API key auth paths.
 // Given a validated user, resolves the model route and provider.
 func resolveProviderForUser(user *iamsdk.User, requestedModel string, lang string) (*object.Provider, *iamsdk.User, string, error) {
-	// Look up the model in the static routing table.
-	route := resolveModelRoute(requestedModel)
+	// Look up the model in the static routing table, under the zen model
+	// name when the user's organization brands it.
+	route := resolveModelRoute(resolveBrandedModel(requestedModel, user.Owner))
 	if route == nil {
 		return nil, user, "", fmt.Errorf(
 			"model %q is not available. Use GET /api/models to list available models",
@@ -352,9 +394,10 @@ func resolveProviderForUser(user *iamsdk.User, requestedModel string, lang strin
 		)
 	}
 
-	// Fetch the provider entry that holds API keys/URLs for this upstream.
-	// GetModelProviderByName returns a shallow copy, safe to mutate.
-	provider, err := object.GetModelProviderByName(route.providerName)
+	// Fetch the provider entry that holds API keys/URLs for this upstream,
+	// preferring one the user's organization registered under that name.
+	// GetModelProviderForOrg returns a shallow copy, safe to mutate.
+	provider, err := object.GetModelProviderForOrg(user.Owner, route.providerName, user.Owner+"/"+user.Name)
 	if err != nil {
 		return nil, user, "", fmt.Errorf("failed to get provider %q: %s", route.providerName, err.Error())
 	}
@@ -362,63 +405,71 @@ func resolveProviderForUser(user *iamsdk.User, requestedModel string, lang strin
 		return nil, user, "", fmt.Errorf("provider %q not configured in database", route.providerName)
 	}
 
-	// Service accounts configured in BALANCE_EXEMPT_USERS skip balance checks.
-	// This allows internal cloud agent pods to make LLM calls without Commerce setup.
-	exemptUsers := os.Getenv("BALANCE_EXEMPT_USERS")
-	userKey := user.Owner + "/" + user.Name
-	isExempt := false
-	if exemptUsers != "" {
-		for _, u := range strings.Split(exemptUsers, ",") {
-			if strings.TrimSpace(u) == userKey {
-				isExempt = true
-				break
-			}
-		}
+	balance, exempt, err := checkModelBalance(user.Owner+"/"+user.Name, requestedModel, route.premium)
+	if err != nil {
+		return nil, user, "", err
+	}
+	if !exempt {
+		user.Balance = balance
 	}
 
-	if !isExempt {
-		// All models require prepaid balance. New accounts receive a $5 starter
-		// credit that works only for non-premium (DO-AI) models.
-		// Premium models (Fireworks, OpenAI Direct, Zen) require the user to
-		// have added funds beyond the starter credit.
-		balance, err := getUserBalance(userKey)
-		if err != nil {
-			return nil, user, "", fmt.Errorf("failed to verify account balance: %s", err.Error())
-		}
+	return provider, user, route.upstreamModel, nil
+}
 
-		if balance <= 0 {
-			return nil, user, "", fmt.Errorf(
-				"model %q requires a positive balance. Your current balance is $%.2f. "+
-					"Add funds at https://hanzo.ai/billing",
-				requestedModel, balance,
-			)
+// isBalanceExempt reports whether userKey ("owner/name") is one of the
+// service accounts configured in BALANCE_EXEMPT_USERS, which skip balance
+// checks. This allows internal cloud agent pods to make LLM calls without
+// Commerce setup.
+func isBalanceExempt(userKey string) bool {
+	exemptUsers := os.Getenv("BALANCE_EXEMPT_USERS")
+	if exemptUsers == "" {
+		return false
+	}
+	for _, u := range strings.Split(exemptUsers, ",") {
+		if strings.TrimSpace(u) == userKey {
+			return true
 		}
 	}
+	return false
+}
 
-	// Premium models require funds beyond the starter credit.
-	// A balance <= StarterCreditDollars means the user only has free credit.
-	if !isExempt {
-		balance, _ := getUserBalance(userKey)
-		starterCredit := StarterCreditDollars
-		if cfg := GetModelConfig(); cfg != nil {
-			starterCredit = cfg.StarterCreditDollars()
-		}
-		if route.premium && balance <= starterCredit {
-			return nil, user, "", fmt.Errorf(
-				"model %q is a premium model requiring a paid balance. "+
-					"Your current balance ($%.2f) is from the starter credit. "+
-					"Add funds at https://hanzo.ai/billing to access premium models",
-				requestedModel, balance,
-			)
-		}
+// checkModelBalance verifies that userKey ("owner/name") may be billed for
+// a call of requestedModel, returning the user's balance, or exempt when
+// the user skips balance checks.
+func checkModelBalance(userKey string, requestedModel string, premium bool) (balance float64, exempt bool, err error) {
+	if isBalanceExempt(userKey) {
+		return 0, true, nil
 	}
 
-	if !isExempt {
-		bal, _ := getUserBalance(userKey)
-		user.Balance = bal
+	// All models require prepaid balance. New accounts receive a $5 starter
+	// credit that works only for non-premium (DO-AI) models.
+	// Premium models (Fireworks, OpenAI Direct, Zen) require the user to
+	// have added funds beyond the starter credit.
+	balance, err = getUserBalance(userKey)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to verify account balance: %s", err.Error())
 	}
 
-	return provider, user, route.upstreamModel, nil
+	if balance <= 0 {
+		return balance, false, fmt.Errorf(
+			"model %q requires a positive balance. Your current balance is $%.2f. "+
+				"Add funds at https://hanzo.ai/billing",
+			requestedModel, balance,
+		)
+	}
+
+	// Premium models require funds beyond the starter credit.
+	// A balance <= StarterCreditDollars means the user only has free credit.
+	starterCredit := GetModelConfig().StarterCreditDollars()
+	if premium && balance <= starterCredit {
+		return balance, false, fmt.Errorf(
+			"model %q is a premium model requiring a paid balance. "+
+				"Your current balance ($%.2f) is from the starter credit. "+
+				"Add funds at https://hanzo.ai/billing to access premium models",
+			requestedModel, balance,
+		)
+	}
+	return balance, false, nil
 }
 
 // iamAuthQuery returns the clientId/clientSecret query string for IAM API auth.
@@ -447,7 +498,41 @@ func iamAuthQuery() string {
 }
 
 // getUserByAccessKey looks up a user by their IAM API key via Hanzo IAM.
-func getUserByAccessKey(accessKey string) (*iamsdk.User, error) {
+// Results are cached (see access_key_cache.go): valid keys for
+// accessKeyCacheTTL, rejected keys for accessKeyNegativeTTL. source is the
+// IP of the client that sent the key, whose failed lookups are throttled,
+// or "" when it is not known. Expired keys return errAccessKeyExpired (see
+// access_key_rotation.go).
+func getUserByAccessKey(accessKey string, source string) (*iamsdk.User, error) {
+	user, err := userByAccessKeyCache.get(accessKey, source)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkAccessKeyExpiry(user, accessKey, time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// VerifyAccessKey resolves an hk- key sent by the client source the way the
+// API handlers do: rotated-out keys within their overlap window and the
+// cloud-agent key are accepted. When no user is returned, rejected tells a
+// refused or expired key apart from an IAM that could not be asked.
+func VerifyAccessKey(accessKey string, source string) (user *iamsdk.User, rejected bool) {
+	user, err := getUserByAccessKey(accessKey, source)
+	if err == nil && user != nil {
+		return user, false
+	}
+	if !errors.Is(err, errAccessKeyExpired) {
+		if fallbackUser := tryCloudAgentKeyFallback(accessKey); fallbackUser != nil {
+			return fallbackUser, false
+		}
+	}
+	return nil, err == nil || isAccessKeyRejected(err) || errors.Is(err, errAccessKeyExpired)
+}
+
+// fetchUserByAccessKey performs the uncached IAM get-user call.
+func fetchUserByAccessKey(accessKey string) (*iamsdk.User, error) {
 	// Call IAM's get-user endpoint with accessKey query parameter
 	iamEndpoint := conf.GetConfigString("iamEndpoint")
 	if iamEndpoint == "" {
@@ -482,7 +567,7 @@ func getUserByAccessKey(accessKey string) (*iamsdk.User, error) {
 	}
 
 	if result.Status != "ok" {
-		return nil, fmt.Errorf("IAM error: %s", result.Msg)
+		return nil, &accessKeyRejectedError{msg: result.Msg}
 	}
 
 	return result.Data, nil
@@ -508,8 +593,42 @@ type usageRecord struct {
 	Stream           bool    `json:"stream"`
 	Status           string  `json:"status"`
 	ErrorMsg         string  `json:"errorMsg"`
+	ErrorClass       string  `json:"errorClass,omitempty"`
 	ClientIP         string  `json:"clientIp"`
 	RequestID        string  `json:"requestId"`
+	LatencyMs        int64   `json:"latencyMs,omitempty"`
+	TtftMs           int64   `json:"ttftMs,omitempty"`
+	TokensPerSecond  float64 `json:"tokensPerSecond,omitempty"`
+	CacheHit         string  `json:"cacheHit,omitempty"`         // "exact" or "semantic" when answered from the completion cache
+	FinishReason     string  `json:"finishReason,omitempty"`     // "stop", "length", "tool_calls" or "content_filter"
+	ConfigGeneration int     `json:"configGeneration,omitempty"` // model config generation in effect, see model_config_history.go
+
+	// EstimatedPromptTokens is the gateway's own count of the prompt, made
+	// before the request was sent; see token_preflight.go.
+	EstimatedPromptTokens int    `json:"estimatedPromptTokens,omitempty"`
+	Tokenizer             string `json:"-"` // tokenizer model of EstimatedPromptTokens
+
+	// Experiment and ExperimentArm name the experiment arm that served the
+	// request; see experiment.go.
+	Experiment    string `json:"experiment,omitempty"`
+	ExperimentArm string `json:"experimentArm,omitempty"`
+
+	// Guardrails are the guardrail policies that matched the request.
+	Guardrails []guardrailDecision `json:"guardrails,omitempty"`
+
+	// Prompt and Response feed the request log only; they are never sent to
+	// Commerce.
+	Prompt   string `json:"-"`
+	Response string `json:"-"`
+}
+
+// finishReasonOf returns why the generation of result stopped, empty when
+// the provider did not report it.
+func finishReasonOf(result *model.ModelResult) string {
+	if result == nil {
+		return ""
+	}
+	return result.FinishReason
 }
 
 // billingQueue is the singleton usage record delivery queue. Initialized by
@@ -517,6 +636,14 @@ type usageRecord struct {
 // is a no-op.
 var billingQueue *util.BillingQueue
 
+// billingAccumulator carries sub-cent usage costs per user between calls.
+// Nil when billingMinIncrementCents is configured (per-call minimum billing).
+var billingAccumulator *util.BillingAccumulator
+
+// defaultBillingSettleInterval is how long a user's sub-cent remainder may sit
+// idle before it is settled to Commerce.
+const defaultBillingSettleInterval = time.Hour
+
 // InitBillingQueue creates the billing queue from app config. Must be called
 // once during startup. Returns the queue so main.go can call Shutdown().
 func InitBillingQueue() *util.BillingQueue {
@@ -528,13 +655,76 @@ func InitBillingQueue() *util.BillingQueue {
 	token := conf.GetConfigString("commerceToken")
 
 	billingQueue = util.NewBillingQueue(endpoint, token)
+
+	if billingMinIncrementCents() <= 0 {
+		interval := defaultBillingSettleInterval
+		if raw := conf.GetConfigString("billingSettleInterval"); raw != "" {
+			if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+				interval = d
+			}
+		}
+		billingAccumulator = util.NewBillingAccumulator(interval, settleUsageRemainder)
+	}
+
 	return billingQueue
 }
 
+// StopBillingAccumulator settles all pending sub-cent remainders. Must be
+// called before the billing queue is shut down so the settlements are
+// delivered.
+func StopBillingAccumulator() {
+	if billingAccumulator != nil {
+		billingAccumulator.Stop()
+	}
+}
+
+// settleUsageRemainder enqueues a settlement record for a user's accumulated
+// sub-cent remainder, rounded to the nearest cent.
+func settleUsageRemainder(user string, cents int64, microCents int64) {
+	if billingQueue == nil || cents <= 0 {
+		return
+	}
+
+	requestId := util.GenerateUUID()
+	payload := map[string]interface{}{
+		"user":             user,
+		"currency":         "usd",
+		"amount":           cents,
+		"amountMicroCents": microCents,
+		"requestId":        requestId,
+		"status":           "success",
+		"type":             "settlement",
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logs.Error("billing: failed to marshal settlement user=%s: %v", user, err)
+		return
+	}
+
+	billingQueue.Enqueue(&util.BillingRecord{
+		Body:      body,
+		RequestID: requestId,
+		User:      user,
+	})
+}
+
 // recordUsage serializes a usage record and enqueues it for reliable delivery
 // to Commerce. The queue handles retries with exponential backoff.
 // Only successful API calls are recorded (error status is filtered here).
 func recordUsage(record *usageRecord) {
+	if record.ConfigGeneration == 0 {
+		record.ConfigGeneration = GetModelConfig().Generation()
+	}
+	observeModelMetrics(record)
+	recordRequestLog(record)
+	publishRequestTailEnd(record)
+	publishUsageEvent(record)
+	notifyModelDeprecated(record)
+	sendUsageWebhooks(record)
+	recordTenantQuotaUsage(record)
+	recordMemberUsage(record)
+
 	if billingQueue == nil {
 		return
 	}
@@ -544,16 +734,48 @@ func recordUsage(record *usageRecord) {
 		return
 	}
 
-	// Calculate cost from per-model pricing table (cache-aware)
-	costCents := calculateCostCentsWithCache(
+	// Calculate the exact cost from the per-model pricing table (cache-aware).
+	// With accumulation enabled, only whole cents are billed now and the
+	// sub-cent remainder carries over to the user's next call.
+	// Prompt and completion tokens are each priced at the model's rate.
+	cost := calculateUsageCost(
 		record.Model, record.PromptTokens, record.CompletionTokens,
 		record.CacheReadTokens, record.CacheWriteTokens,
 	)
+	selfHosted, isSelfHosted := getSelfHostedCost(record)
+	if isSelfHosted {
+		if selfHosted.total() == 0 {
+			return
+		}
+		cost = selfHosted
+	}
+	// Cache hits are billed at responseCacheHitPricePercent of the price.
+	if record.CacheHit != "" {
+		cost = cost.percent(responseCacheHitPricePercent())
+		if cost.total() == 0 {
+			return
+		}
+	}
+	costMicroCents := cost.total()
+	var costCents int64
+	if billingAccumulator != nil {
+		costCents = billingAccumulator.Add(record.User, costMicroCents)
+	} else if isSelfHosted || record.CacheHit != "" {
+		costCents = roundCostCents(costMicroCents, true)
+	} else {
+		costCents = calculateCostCentsWithCache(
+			record.Model, record.PromptTokens, record.CompletionTokens,
+			record.CacheReadTokens, record.CacheWriteTokens,
+		)
+	}
 
 	payload := map[string]interface{}{
 		"user":             record.User,
 		"currency":         "usd",
 		"amount":           costCents,
+		"amountMicroCents": costMicroCents,
+		"inputMicroCents":  cost.input + cost.cacheRead + cost.cacheWrite,
+		"outputMicroCents": cost.output,
 		"model":            record.Model,
 		"provider":         record.Provider,
 		"promptTokens":     record.PromptTokens,
@@ -566,6 +788,11 @@ func recordUsage(record *usageRecord) {
 		"stream":           record.Stream,
 		"status":           record.Status,
 		"clientIp":         record.ClientIP,
+		"latencyMs":        record.LatencyMs,
+		"ttftMs":           record.TtftMs,
+		"tokensPerSecond":  record.TokensPerSecond,
+		"finishReason":     record.FinishReason,
+		"configGeneration": record.ConfigGeneration,
 	}
 
 	body, err := json.Marshal(payload)
@@ -616,146 +843,159 @@ func resolveConsoleKeys(org string) (publicKey, secretKey string) {
 	return "", ""
 }
 
+// clientDisconnected reports whether err stopped an upstream call because the
+// client went away (the request context was canceled) after the provider had
+// generated part of the answer. The partial result is billed like a completed
+// call: the upstream charged for those tokens.
+func clientDisconnected(ctx context.Context, result *model.ModelResult, err error) bool {
+	return err != nil && ctx.Err() != nil && result != nil
+}
+
 // recordTrace sends a trace+generation event to the console for observability.
 // Traces are routed to per-org console projects using KMS secrets
 // (console-pk-{org} / console-sk-{org}), enabling each org to see their own usage
 // in console.hanzo.ai. This is fire-and-forget — failures are silently ignored.
+// The writes run on the usage recorder (see usage_recorder.go).
 func recordTrace(record *usageRecord, startTime time.Time) {
-	// Write billing record to ClickHouse for invoice reconciliation.
-	go zapWriteUsage(record, startTime)
-	// Write observability trace to ClickHouse via native ZAP.
-	go zapWriteTrace(record, startTime)
+	submitUsageJob(func() {
+		// Write billing record to ClickHouse for invoice reconciliation.
+		zapWriteUsage(record, startTime)
+		// Write observability trace to ClickHouse via native ZAP.
+		zapWriteTrace(record, startTime)
+		postConsoleTrace(record, startTime)
+	})
+}
 
-	go func() {
-		// Resolve console endpoint from KMS, then Beego config, then env var
-		consoleEndpoint, _ := object.GetKMSSecret("console-endpoint")
-		if consoleEndpoint == "" {
-			consoleEndpoint = conf.GetConfigString("consoleEndpoint")
-		}
-		if consoleEndpoint == "" {
-			consoleEndpoint = os.Getenv("CONSOLE_HOST")
-		}
-		if consoleEndpoint == "" {
-			return
-		}
-		consoleEndpoint = strings.TrimRight(consoleEndpoint, "/")
+// postConsoleTrace sends the trace of record to the console ingestion API
+// when a console endpoint and keys are configured.
+func postConsoleTrace(record *usageRecord, startTime time.Time) {
+	// Resolve console endpoint from KMS, then Beego config, then env var
+	consoleEndpoint, _ := object.GetKMSSecret("console-endpoint")
+	if consoleEndpoint == "" {
+		consoleEndpoint = conf.GetConfigString("consoleEndpoint")
+	}
+	if consoleEndpoint == "" {
+		consoleEndpoint = os.Getenv("CONSOLE_HOST")
+	}
+	if consoleEndpoint == "" {
+		return
+	}
+	consoleEndpoint = strings.TrimRight(consoleEndpoint, "/")
 
-		// Resolve per-org or global console API keys
-		org := record.Organization
-		if org == "" {
-			org = record.Owner
-		}
-		consoleApiKey, consoleSecretKeyVal := resolveConsoleKeys(org)
-		if consoleApiKey == "" || consoleSecretKeyVal == "" {
-			return
-		}
+	// Resolve per-org or global console API keys
+	org := record.Organization
+	if org == "" {
+		org = record.Owner
+	}
+	consoleApiKey, consoleSecretKeyVal := resolveConsoleKeys(org)
+	if consoleApiKey == "" || consoleSecretKeyVal == "" {
+		return
+	}
 
-		endTime := time.Now().UTC()
-		traceId := util.GenerateUUID()
-		genId := util.GenerateUUID()
+	endTime := time.Now().UTC()
+	traceId := util.GenerateUUID()
+	genId := util.GenerateUUID()
 
-		// Build tags: org, model, provider, source app
-		tags := []string{record.Model, record.Provider}
-		if org != "" {
-			tags = append(tags, "org:"+org)
-		}
-		if record.User != "" {
-			tags = append(tags, "user:"+record.User)
-		}
+	// Build tags: org, model, provider, source app
+	tags := []string{record.Model, record.Provider}
+	if org != "" {
+		tags = append(tags, "org:"+org)
+	}
+	if record.User != "" {
+		tags = append(tags, "user:"+record.User)
+	}
 
-		// Determine cost for the generation
-		costCents := calculateCostCentsWithCache(
-			record.Model, record.PromptTokens, record.CompletionTokens,
-			record.CacheReadTokens, record.CacheWriteTokens,
-		)
+	// Determine cost for the generation
+	cost := calculateUsageCost(
+		record.Model, record.PromptTokens, record.CompletionTokens,
+		record.CacheReadTokens, record.CacheWriteTokens,
+	)
 
-		// Build console ingestion batch with full org/user/cost context
-		batch := map[string]interface{}{
-			"batch": []map[string]interface{}{
-				{
-					"id":        util.GenerateUUID(),
-					"type":      "trace-create",
+	// Build console ingestion batch with full org/user/cost context
+	batch := map[string]interface{}{
+		"batch": []map[string]interface{}{
+			{
+				"id":        util.GenerateUUID(),
+				"type":      "trace-create",
+				"timestamp": startTime.UTC().Format(time.RFC3339Nano),
+				"body": map[string]interface{}{
+					"id":        traceId,
+					"name":      "chat-completion",
+					"userId":    record.User,
+					"sessionId": record.RequestID,
 					"timestamp": startTime.UTC().Format(time.RFC3339Nano),
-					"body": map[string]interface{}{
-						"id":        traceId,
-						"name":      "chat-completion",
-						"userId":    record.User,
-						"sessionId": record.RequestID,
-						"timestamp": startTime.UTC().Format(time.RFC3339Nano),
-						"metadata": map[string]interface{}{
-							"model":        record.Model,
-							"provider":     record.Provider,
-							"organization": org,
-							"premium":      record.Premium,
-							"stream":       record.Stream,
-							"requestId":    record.RequestID,
-							"clientIp":     record.ClientIP,
-							"source":       "cloud-api",
-						},
-						"tags": tags,
+					"metadata": map[string]interface{}{
+						"model":        record.Model,
+						"provider":     record.Provider,
+						"organization": org,
+						"premium":      record.Premium,
+						"stream":       record.Stream,
+						"requestId":    record.RequestID,
+						"clientIp":     record.ClientIP,
+						"source":       "cloud-api",
 					},
+					"tags": tags,
 				},
-				{
-					"id":        util.GenerateUUID(),
-					"type":      "generation-create",
-					"timestamp": endTime.Format(time.RFC3339Nano),
-					"body": map[string]interface{}{
-						"id":                  genId,
-						"traceId":             traceId,
-						"name":                record.Model,
-						"model":               record.Model,
-						"startTime":           startTime.UTC().Format(time.RFC3339Nano),
-						"endTime":             endTime.Format(time.RFC3339Nano),
-						"completionStartTime": endTime.Format(time.RFC3339Nano),
-						"level":               "DEFAULT",
-						"statusMessage":       record.Status,
-						"usage": map[string]interface{}{
-							"input":  record.PromptTokens,
-							"output": record.CompletionTokens,
-							"total":  record.TotalTokens,
-							"unit":   "TOKENS",
-						},
-						"costDetails": map[string]interface{}{
-							"input":  float64(costCents) * float64(record.PromptTokens) / float64(max(record.TotalTokens, 1)),
-							"output": float64(costCents) * float64(record.CompletionTokens) / float64(max(record.TotalTokens, 1)),
-						},
-						"metadata": map[string]interface{}{
-							"provider":     record.Provider,
-							"organization": org,
-							"requestId":    record.RequestID,
-							"costCents":    costCents,
-						},
+			},
+			{
+				"id":        util.GenerateUUID(),
+				"type":      "generation-create",
+				"timestamp": endTime.Format(time.RFC3339Nano),
+				"body": map[string]interface{}{
+					"id":                  genId,
+					"traceId":             traceId,
+					"name":                record.Model,
+					"model":               record.Model,
+					"startTime":           startTime.UTC().Format(time.RFC3339Nano),
+					"endTime":             endTime.Format(time.RFC3339Nano),
+					"completionStartTime": endTime.Format(time.RFC3339Nano),
+					"level":               "DEFAULT",
+					"statusMessage":       record.Status,
+					"usage": map[string]interface{}{
+						"input":  record.PromptTokens,
+						"output": record.CompletionTokens,
+						"total":  record.TotalTokens,
+						"unit":   "TOKENS",
+					},
+					"costDetails": map[string]interface{}{
+						"input":  cost.inputCents(),
+						"output": cost.outputCents(),
+					},
+					"metadata": map[string]interface{}{
+						"provider":     record.Provider,
+						"organization": org,
+						"requestId":    record.RequestID,
+						"costCents":    cost.inputCents() + cost.outputCents(),
 					},
 				},
 			},
-			"metadata": map[string]interface{}{
-				"sdk_name":    "cloud-api",
-				"sdk_version": "1.0.0",
-				"public_key":  consoleApiKey,
-			},
-		}
+		},
+		"metadata": map[string]interface{}{
+			"sdk_name":    "cloud-api",
+			"sdk_version": "1.0.0",
+			"public_key":  consoleApiKey,
+		},
+	}
 
-		body, err := json.Marshal(batch)
-		if err != nil {
-			return
-		}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
 
-		url := consoleEndpoint + "/api/public/ingestion"
-		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
-		if err != nil {
-			return
-		}
-		req.Header.Set("Content-Type", "application/json")
-		auth := base64.StdEncoding.EncodeToString([]byte(consoleApiKey + ":" + consoleSecretKeyVal))
-		req.Header.Set("Authorization", "Basic "+auth)
+	url := consoleEndpoint + "/api/public/ingestion"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth := base64.StdEncoding.EncodeToString([]byte(consoleApiKey + ":" + consoleSecretKeyVal))
+	req.Header.Set("Authorization", "Basic "+auth)
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return
-		}
-		resp.Body.Close()
-	}()
+	resp, err := usageRecorderClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
 }
 
 // ── API handlers ────────────────────────────────────────────────────────────
@@ -767,15 +1007,23 @@ func recordTrace(record *usageRecord, startTime time.Time) {
 //   - Widget key (hz_...)   — restricted models, no balance check, token-capped
 //   - IAM API key (hk-...)  — full model routing + billing
 //   - hanzo.id JWT token    — full model routing + billing
+//   - Gateway token (gt-...) — client-credentials service token, validated locally
 //   - Provider API key      — direct provider access
+//   - Dashboard session     — signed-in users without an Authorization
+//     header (e.g. the built-in playground); full model routing + billing
 //
 // @Param   body    body    openai.ChatCompletionRequest  true    "The OpenAI chat request"
 // @Success 200 {object} openai.ChatCompletionResponse
 // @router /chat [post]
 func (c *ApiController) ChatCompletions() {
-	// Extract Bearer token
+	// Extract Bearer token, or fall back to the signed-in session user when
+	// no Authorization header is sent at all.
 	authHeader := c.Ctx.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	var sessionUser *iamsdk.User
+	if authHeader == "" {
+		sessionUser = c.GetSessionUser()
+	}
+	if sessionUser == nil && !strings.HasPrefix(authHeader, "Bearer ") {
 		c.ResponseError(c.T("openai:Invalid API key format. Expected 'Bearer API_KEY'"))
 		return
 	}
@@ -794,13 +1042,27 @@ func (c *ApiController) ChatCompletions() {
 	// Track timing for observability
 	requestStartTime := time.Now().UTC()
 
-	// Parse request body
-	var request openai.ChatCompletionRequest
-	err := json.Unmarshal(c.Ctx.Input.RequestBody, &request)
+	// Parse request body, with the hanzo extension alongside the OpenAI fields
+	var body struct {
+		openai.ChatCompletionRequest
+		hanzoRequestExtension
+		promptTemplateRequest
+		// Temperature shadows the request's, to tell an explicit 0 from none.
+		Temperature *float32 `json:"temperature"`
+	}
+	err := c.decodeRequestBody(&body)
+	if message := requestBodyTooLarge(err); message != "" {
+		c.respondOpenAIError(http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large", message)
+		return
+	}
 	if err != nil {
 		c.ResponseError(fmt.Sprintf("Failed to parse request: %s", err.Error()))
 		return
 	}
+	request := body.ChatCompletionRequest
+	if body.Temperature != nil {
+		request.Temperature = *body.Temperature
+	}
 
 	var provider *object.Provider
 	var authUser *iamsdk.User
@@ -810,7 +1072,47 @@ func (c *ApiController) ChatCompletions() {
 	// Resolve org context for per-org model routing and pricing.
 	orgId := c.GetEffectiveOrg()
 
-	if isWidgetKey(token) {
+	if sessionUser != nil {
+		// Authenticate via dashboard session — same routing and billing as JWT
+		provider, authUser, upstreamModel, err = resolveProviderForUser(sessionUser, request.Model, c.GetAcceptLanguage())
+		if err != nil {
+			c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
+			return
+		}
+		c.Ctx.Input.SetParam("recordUserId", authUser.Owner+"/"+authUser.Name)
+		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
+			isPremium = route.premium
+		}
+	} else if isGatewayToken(token) {
+		// Authenticate via gateway token (gt-...) — validated locally, the
+		// embedded org claim selects per-org routing
+		var claims *gatewayClaims
+		claims, err = parseScopedGatewayToken(token, gatewayScopeChat)
+		if err != nil {
+			c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
+			return
+		}
+		if !claims.allowsModel(request.Model) {
+			c.ResponseError(fmt.Sprintf("Authentication failed: token is not valid for model %q", request.Model))
+			return
+		}
+		if claims.Org != "" {
+			orgId = claims.Org
+		}
+		if err = checkMemberKeys(claims.user()); err != nil {
+			c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
+			return
+		}
+		provider, authUser, upstreamModel, err = resolveProviderForUser(claims.user(), request.Model, c.GetAcceptLanguage())
+		if err != nil {
+			c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
+			return
+		}
+		c.Ctx.Input.SetParam("recordUserId", authUser.Owner+"/"+authUser.Name)
+		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
+			isPremium = route.premium
+		}
+	} else if isWidgetKey(token) {
 		// Authenticate via widget key (hz_...) — restricted model access, no balance check
 		var widgetUpstream string
 		provider, widgetUpstream, err = resolveProviderFromWidgetKey(token, request.Model, c.GetAcceptLanguage())
@@ -828,7 +1130,7 @@ func (c *ApiController) ChatCompletions() {
 		logs.Info("Widget key access: model=%s, upstream=%s", request.Model, upstreamModel)
 	} else if isIAMApiKey(token) {
 		// Authenticate via IAM API key (hk-...) — full model routing
-		provider, authUser, upstreamModel, err = resolveProviderFromIAMKey(token, request.Model, c.GetAcceptLanguage())
+		provider, authUser, upstreamModel, err = resolveProviderFromIAMKey(token, util.GetClientIP(c.Ctx.Request), request.Model, c.GetAcceptLanguage())
 		if err != nil {
 			c.ResponseError(fmt.Sprintf("Authentication failed: %s", err.Error()))
 			return
@@ -837,6 +1139,7 @@ func (c *ApiController) ChatCompletions() {
 			userId := authUser.Owner + "/" + authUser.Name
 			c.Ctx.Input.SetParam("recordUserId", userId)
 		}
+		orgId = c.getCallerOrg(authUser)
 		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
 			isPremium = route.premium
 		}
@@ -851,6 +1154,7 @@ func (c *ApiController) ChatCompletions() {
 			userId := authUser.Owner + "/" + authUser.Name
 			c.Ctx.Input.SetParam("recordUserId", userId)
 		}
+		orgId = c.getCallerOrg(authUser)
 		if route := resolveModelRouteForOrg(request.Model, orgId); route != nil {
 			isPremium = route.premium
 		}
@@ -865,6 +1169,10 @@ func (c *ApiController) ChatCompletions() {
 			c.ResponseError("Authentication failed: invalid API key")
 			return
 		}
+		// The key of an organization's provider serves that organization.
+		if provider.Owner != "admin" {
+			orgId = provider.Owner
+		}
 		// Apply model routing for sk- keys too. If the route points to a
 		// different provider than the one that owns the API key, switch to
 		// the route's provider so zen/fireworks models work with any key.
@@ -872,13 +1180,73 @@ func (c *ApiController) ChatCompletions() {
 			upstreamModel = route.upstreamModel
 			isPremium = route.premium
 			if route.providerName != provider.Name {
-				routeProvider, routeErr := object.GetModelProviderByName(route.providerName)
+				routeProvider, routeErr := object.GetModelProviderForOrg(orgId, route.providerName, requestSecretCaller(token, nil, provider))
 				if routeErr == nil && routeProvider != nil {
 					provider = routeProvider
 				}
 			}
 		}
 	}
+	caller := requestSecretCaller(token, authUser, provider)
+	c.Ctx.Request = c.Ctx.Request.WithContext(withSecretCaller(c.Ctx.Request.Context(), caller))
+
+	// Put the history of a stored conversation in front of the new
+	// messages; see conversation.go.
+	if !c.loadConversation(body.Hanzo.Conversation, authUser, &request) {
+		return
+	}
+
+	// Render the organization's prompt template in front of the messages;
+	// see prompt_template.go.
+	templateOrg := orgId
+	if authUser != nil {
+		templateOrg = authUser.Owner
+	}
+	if !c.renderPromptTemplate(body.Template, templateOrg, &request) {
+		return
+	}
+
+	// Hold the request to the limits of the store it is made for; see
+	// store_limits.go.
+	store, err := resolveRequestStore(authUser, body.Hanzo.Store)
+	if err != nil {
+		c.respondOpenAIError(http.StatusForbidden, "permission_error", "store_forbidden", err.Error())
+		return
+	}
+	if retryAfter, err := checkStoreFrequency(store, c.storeLimitUser(authUser)); err != nil {
+		c.Ctx.ResponseWriter.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		c.respondOpenAIError(http.StatusTooManyRequests, "rate_limit_error", "store_limit_exceeded", err.Error())
+		return
+	}
+	request.Messages = trimStoreMemory(store, request.Messages)
+
+	// Keep the request inside the organization's data residency region.
+	route, err := applyTenantResidency(request.Model, resolveModelRouteForOrg(request.Model, orgId), orgId, caller)
+	if err == nil && route == nil {
+		err = checkProviderResidency(request.Model, provider, orgId)
+	}
+	if err != nil {
+		c.respondOpenAIError(http.StatusForbidden, "invalid_request_error", "data_residency", err.Error())
+		return
+	}
+	provider, upstreamModel, err = resolveResidentProvider(route, orgId, caller, provider, upstreamModel)
+	if err != nil {
+		c.ResponseError(fmt.Sprintf("Failed to get provider: %s", err.Error()))
+		return
+	}
+
+	// Record and bill the org's own name for a zen model as that model; the
+	// caller still sees its own name.
+	brandedModel := request.Model
+	request.Model = resolveBrandedModel(request.Model, orgId)
+
+	// Serve the request with its user's arm of a running experiment on the
+	// model; see experiment.go.
+	experimentUser := c.getClientIp()
+	if authUser != nil {
+		experimentUser = authUser.Owner + "/" + authUser.Name
+	}
+	c.applyModelExperiment(orgId, experimentUser, &request, body.Temperature != nil)
 
 	if provider.Category != "Model" {
 		c.ResponseError(fmt.Sprintf("Provider %s is not a model provider", provider.Name))
@@ -894,28 +1262,53 @@ func (c *ApiController) ChatCompletions() {
 		provider.SubType = request.Model
 	}
 
+	// Scrub PII before any of the messages leave the gateway; see pii.go.
+	piiReport, err := scrubPromptPii(orgId, request.Messages)
+	if err != nil {
+		c.respondOpenAIError(http.StatusServiceUnavailable, "api_error", "pii_unavailable", err.Error())
+		return
+	}
+	c.setPiiRedactionsHeader(piiReport)
+
+	// Run the organization's input guardrails on the user's message, before
+	// any path sends it upstream; see guardrail.go.
+	userText := lastUserMessageText(request.Messages)
+	input := applyGuardrails(c.Ctx.Request.Context(), orgId, object.GuardrailStageInput, userText, c.GetAcceptLanguage())
+	if input.Blocked != nil {
+		c.recordGuardrailBlock(authUser, request.Model, isPremium, request.Stream, input, requestStartTime)
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "content_policy_violation", input.blockedMessage())
+		return
+	}
+	if input.Text != userText {
+		redactUserMessage(request.Messages, input.Text)
+	}
+
 	// ── Tool-calling pass-through ──────────────────────────────────────
 	// When the request includes tools/functions, the QueryText pipeline
 	// cannot handle structured tool calls. Proxy the raw request directly
 	// to the upstream provider's OpenAI-compatible endpoint so the LLM
 	// receives tool definitions and can return tool_calls in the response.
 	if len(request.Tools) > 0 || request.ToolChoice != nil {
-		c.proxyToolRequest(provider, &request, requestStartTime, authUser, isPremium, orgId)
+		c.preflightChatTokens(&request, provider.SubType, route)
+		c.proxyToolRequest(provider, &request, requestStartTime, authUser, isPremium, orgId, route)
 		return
 	}
 
-	// Inject Zen identity prompt for zen-branded models
-	if zenPrompt := zenIdentityPrompt(request.Model); zenPrompt != "" {
-		hasSystem := len(request.Messages) > 0 && request.Messages[0].Role == "system"
-		if hasSystem {
-			request.Messages[0].Content = zenPrompt + "\n\n" + request.Messages[0].Content
-		} else {
-			request.Messages = append([]openai.ChatCompletionMessage{{
-				Role:    "system",
-				Content: zenPrompt,
-			}}, request.Messages...)
-		}
+	// Inject Zen identity prompt for zen-branded models. The mode is the
+	// choice of the caller's org; widget and provider keys belong to no
+	// org, so they always get the default mode.
+	identityMode, err := body.zenIdentityMode(identityModeOrg(authUser, orgId))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
 	}
+	identityPrompt := c.experimentIdentityPrompt(zenIdentityPrompt(brandedModel, orgId))
+	request.Messages = injectZenIdentity(request.Messages, identityPrompt, identityMode)
+	scanIdentity := identityPrompt != "" && identityMode != zenIdentityOff
+
+	// Count the prompt with the upstream model's tokenizer and fit
+	// max_tokens to the model; see token_preflight.go.
+	c.preflightChatTokens(&request, provider.SubType, route)
 
 	// Extract messages content
 	var question string
@@ -947,18 +1340,42 @@ func (c *ApiController) ChatCompletions() {
 		}
 	}
 
-	if question == "" {
+	// Images, JSON mode and stop sequences do not survive the QueryText
+	// pipeline; Fireworks serves them natively, images without text too.
+	native := provider.Type == "Fireworks" && needsNativeChat(&request)
+	if question == "" && !native {
 		c.ResponseError(c.T("openai:No user message found in the request"))
 		return
 	}
 
+	holdOutput := holdsGuardrailOutput(orgId)
+
 	// Combine system prompt with user question if available
 	if systemPrompt != "" {
 		question = fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, question)
 	}
 
-	// Setup for streaming if enabled
+	// Enforce the organization's daily and monthly quotas and the member's
+	// spend limit.
+	if !c.admitChatRequest(authUser) {
+		return
+	}
+
 	requestId := util.GenerateUUID()
+
+	if native {
+		// The native path streams the answer as it comes, so it cannot be
+		// held back for the output guardrails.
+		if holdOutput {
+			c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "content_policy_violation",
+				"Images, JSON mode and stop sequences are not available with this organization's output guardrails")
+			return
+		}
+		c.fireworksChatCompletion(provider, &request, request.Model, brandedModel, requestStartTime, authUser, isPremium, requestId, orgId, route, scanIdentity)
+		return
+	}
+
+	// Setup for streaming if enabled
 	if request.Stream {
 		c.Ctx.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
 		c.Ctx.ResponseWriter.Header().Set("Cache-Control", "no-cache")
@@ -972,7 +1389,17 @@ func (c *ApiController) ChatCompletions() {
 		RequestID: requestId,
 		Stream:    request.Stream,
 		Cleaner:   *NewCleaner(6),
-		Model:     request.Model,
+		ThinkTags: newThinkTagSplitter(getRouteThinkTags(route)),
+		Model:     brandedModel,
+		Timing:    newStreamTiming(requestStartTime),
+	}
+	writer.Live = startLiveRequest(requestId, request.Model, provider.Name, tailUserId(authUser), request.Stream, requestStartTime)
+	if request.Stream {
+		writer.Keepalive = startSSEKeepalive(writer.ResponseWriter, getSSEKeepaliveInterval())
+		defer writer.Keepalive.Stop()
+	}
+	if authUser != nil {
+		defer trackTenantInflight(authUser.Owner)()
 	}
 
 	// Optional RAG: unified retrieval path shared with the old /chat-docs route.
@@ -987,16 +1414,51 @@ func (c *ApiController) ChatCompletions() {
 		c.GetAcceptLanguage(),
 	)
 
-	// Resolve the route for failover (may have fallback providers)
-	route := resolveModelRouteForOrg(request.Model, orgId)
+	// Answers grounded in retrieved knowledge are not cached: the knowledge
+	// changes independently of the request.
+	var cacheLookup *completionCacheLookup
+	var cached *completionCacheEntry
+	var cacheType string
+	if len(knowledge) == 0 {
+		cacheLookup, cached, cacheType = lookupCompletionCache(c.Ctx.Request.Context(), orgId, provider.Name, &request, c.Ctx.Request.Header)
+		c.setCacheHeaders(cacheLookup, cacheType)
+		if authUser != nil && cacheLookup != nil && cacheLookup.embeddingTokens > 0 {
+			c.recordEmbeddingUsage(authUser, cacheLookup.embeddingModel, cacheLookup.embeddingProvider, cacheLookup.embeddingTokens, nil, requestId, requestStartTime)
+		}
+	}
 
-	// Call the model provider with failover support
+	// Call the model provider with failover support (the route may have
+	// fallback providers)
 	var modelResult *model.ModelResult
 	var actualProvider string
 
-	if route != nil && len(route.fallbacks) > 0 {
+	// With output guardrails the answer is collected and released once it
+	// has been checked, and so is a non-streamed zen answer that may be
+	// retried for an identity leak.
+	target := io.Writer(writer)
+	var held *CarrierWriter
+	if holdOutput || (scanIdentity && !request.Stream && retriesIdentityLeaks()) {
+		held = &CarrierWriter{}
+		target = held
+	}
+
+	// The request context is canceled when the client disconnects, which
+	// stops the upstream generation; the route's timeouts bound it as well.
+	deadline := startUpstreamDeadline(c.Ctx.Request.Context(), getRouteTimeouts(route))
+	defer deadline.Stop()
+	ctx, limits := proxy.WithRateLimitRecorder(deadline.Context())
+	if temperature, ok := c.experimentTemperature(); ok {
+		provider.Temperature = temperature
+		ctx = withProviderTemperature(ctx, temperature)
+	}
+	upstreamWriter := deadline.Writer(target)
+	upstreamStart := time.Now()
+	if cached != nil {
+		modelResult, err = (&cachedModelProvider{entry: cached}).QueryText(ctx, question, target, history, "", knowledge, nil, c.GetAcceptLanguage())
+		actualProvider = provider.Name
+	} else if route != nil && len(route.fallbacks) > 0 {
 		modelResult, actualProvider, err = failoverQueryText(
-			route, question, writer, history, knowledge,
+			ctx, orgId, route, question, upstreamWriter, history, knowledge,
 			c.GetAcceptLanguage(),
 			func() bool { return writer.StreamSent },
 		)
@@ -1008,33 +1470,85 @@ func (c *ApiController) ChatCompletions() {
 			c.ResponseError(fmt.Sprintf("Failed to get model provider: %s", err.Error()))
 			return
 		}
-		modelResult, err = modelProvider.QueryText(question, writer, history, "", knowledge, nil, c.GetAcceptLanguage())
+		modelResult, err = modelProvider.QueryText(ctx, question, upstreamWriter, history, "", knowledge, nil, c.GetAcceptLanguage())
+		err = upstreamCallError(ctx, err)
 		actualProvider = provider.Name
+		if !clientGone(ctx) {
+			providerHealth.record(actualProvider, err)
+		}
 	}
 
-	if err != nil {
+	upstreamLatency := time.Since(upstreamStart)
+	writer.Keepalive.Stop()
+
+	disconnected := clientDisconnected(c.Ctx.Request.Context(), modelResult, err)
+	if err != nil && !disconnected {
+		errorClass := classifyUpstreamError(err)
 		// Record failed usage
 		if authUser != nil {
 			errRecord := &usageRecord{
-				Owner:     authUser.Owner,
-				User:      authUser.Owner + "/" + authUser.Name,
-				Model:     request.Model,
-				Provider:  actualProvider,
-				Premium:   isPremium,
-				Stream:    request.Stream,
-				Status:    "error",
-				ErrorMsg:  err.Error(),
-				ClientIP:  c.Ctx.Request.RemoteAddr,
-				RequestID: requestId,
+				Owner:      authUser.Owner,
+				User:       authUser.Owner + "/" + authUser.Name,
+				Model:      request.Model,
+				Provider:   actualProvider,
+				Premium:    isPremium,
+				Stream:     request.Stream,
+				Status:     "error",
+				ErrorMsg:   err.Error(),
+				ErrorClass: errorClass,
+				ClientIP:   c.getClientIp(),
+				RequestID:  requestId,
+				LatencyMs:  time.Since(requestStartTime).Milliseconds(),
 			}
+			errRecord.Prompt = question
+			errRecord.Guardrails = input.Decisions
+			c.applyExperiment(errRecord)
 			recordUsage(errRecord)
 			recordTrace(errRecord, requestStartTime)
 		}
-		c.ResponseError(err.Error())
+		c.setUpstreamRetryAfter(errorClass, actualProvider, limits)
+		if writer.Started() {
+			// The stream is committed to a 200; end it with an error event.
+			resp := getUpstreamErrorResponse(errorClass)
+			_ = writer.WriteError(resp.openAIType, resp.openAICode, err.Error())
+			c.EnableRender = false
+			return
+		}
+		c.respondOpenAIUpstreamError(errorClass, err.Error())
 		return
 	}
 
-	// Record successful usage (actualProvider reflects which provider served the request)
+	// Scan a zen answer for an identity leak, retrying a held one; see
+	// identity_leak.go.
+	if scanIdentity && !disconnected {
+		if held == nil {
+			checkIdentityLeak(request.Model, writer.MessageString(), nil)
+		} else {
+			retry := newIdentityLeakRetry(ctx, orgId, route, provider, actualProvider, question, history, knowledge, c.GetAcceptLanguage())
+			answer, retried := checkIdentityLeak(request.Model, held.MessageString(), retry)
+			if retried != nil {
+				addRetryUsage(modelResult, retried)
+				held = &CarrierWriter{}
+				_ = replayGuardedAnswer(held, answer)
+			}
+		}
+	}
+
+	// Check the held answer against the output guardrails and release it.
+	guardrails := input.Decisions
+	var output *guardrailOutcome
+	if held != nil && !disconnected {
+		output = applyGuardrails(c.Ctx.Request.Context(), orgId, object.GuardrailStageOutput, held.MessageString(), c.GetAcceptLanguage())
+		guardrails = append(guardrails, output.Decisions...)
+		if output.Blocked == nil {
+			_ = replayGuardedAnswer(writer, output.Text)
+		}
+	}
+	_ = writer.FlushThinkTags()
+
+	// Record successful usage (actualProvider reflects which provider served the
+	// request), including the part generated before the client disconnected.
+	// An answer the output guardrails blocked was still generated and billed.
 	if authUser != nil {
 		successRecord := &usageRecord{
 			Owner:            authUser.Owner,
@@ -1049,12 +1563,64 @@ func (c *ApiController) ChatCompletions() {
 			Premium:          isPremium,
 			Stream:           request.Stream,
 			Status:           "success",
-			ClientIP:         c.Ctx.Request.RemoteAddr,
+			ClientIP:         c.getClientIp(),
 			RequestID:        requestId,
+			LatencyMs:        time.Since(requestStartTime).Milliseconds(),
+		}
+		writer.Timing.apply(successRecord)
+		c.applyPromptEstimate(successRecord)
+		c.applyExperiment(successRecord)
+		successRecord.Prompt = question
+		successRecord.Response = writer.MessageString()
+		successRecord.CacheHit = cacheType
+		successRecord.Guardrails = guardrails
+		if !disconnected {
+			successRecord.FinishReason = finishReasonOf(modelResult)
+		}
+		if disconnected {
+			successRecord.ErrorMsg = "client disconnected: " + err.Error()
+		}
+		if output != nil && output.Blocked != nil {
+			successRecord.ErrorMsg = output.blockedMessage()
 		}
 		recordUsage(successRecord)
 		recordTrace(successRecord, requestStartTime)
 	}
+	if disconnected {
+		return
+	}
+	if output != nil && output.Blocked != nil {
+		if writer.Started() {
+			_ = writer.WriteError("invalid_request_error", "content_policy_violation", output.blockedMessage())
+			c.EnableRender = false
+			return
+		}
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "content_policy_violation", output.blockedMessage())
+		return
+	}
+	c.saveConversationTurn(writer.MessageString())
+	if cached == nil && route != nil && route.shadow != nil {
+		mirrorToShadow(route, shadowSample{
+			model:           request.Model,
+			org:             orgId,
+			caller:          caller,
+			question:        question,
+			history:         history,
+			knowledge:       knowledge,
+			lang:            c.GetAcceptLanguage(),
+			primaryUpstream: routeUpstreamLabel(route, actualProvider),
+			primaryLatency:  upstreamLatency,
+			primaryTokens:   modelResult.ResponseTokenCount,
+			primaryAnswer:   writer.MessageString(),
+		})
+	}
+	if cacheLookup != nil && cached == nil {
+		storeCompletion(cacheLookup, completionCacheEntry{
+			Answer:           writer.MessageString(),
+			PromptTokens:     modelResult.PromptTokenCount,
+			CompletionTokens: modelResult.ResponseTokenCount,
+		})
+	}
 
 	// Handle response based on streaming mode
 	if !request.Stream {
@@ -1064,13 +1630,14 @@ func (c *ApiController) ChatCompletions() {
 			ID:      "chatcmpl-" + requestId,
 			Object:  "chat.completion",
 			Created: util.GetCurrentUnixTime(),
-			Model:   request.Model,
+			Model:   brandedModel,
 			Choices: []openai.ChatCompletionChoice{
 				{
 					Index: 0,
 					Message: openai.ChatCompletionMessage{
-						Role:    "assistant",
-						Content: answer,
+						Role:             "assistant",
+						Content:          answer,
+						ReasoningContent: writer.ReasoningString(),
 					},
 					FinishReason: openai.FinishReasonStop,
 				},
@@ -1104,17 +1671,91 @@ func (c *ApiController) ChatCompletions() {
 	c.EnableRender = false
 }
 
-// ListModels returns the list of available models from the routing table.
+// ListModels returns the list of available models from the routing table,
+// filtered by the caller's org entitlements; with verbose=true each model
+// has its details, as GetModel returns them.
 // Requires a valid Bearer token (JWT, hk-, pk-, sk-, or hz_ key).
 // @Title ListModels
 // @Tag OpenAI Compatible API
-// @Description Returns a list of all available models. Requires authentication.
+// @Description Returns the models available to the caller's organization. Requires authentication.
 // @Param Authorization header string true "Bearer token"
+// @Param verbose query bool false "Include each model's pricing, context window, max output tokens, capabilities and modalities"
 // @Success 200 {object} object
 // @Failure 401 {object} object "Unauthorized"
 // @router /models [get]
 func (c *ApiController) ListModels() {
-	// R-04 fix: require authentication for model listing.
+	orgId, ok := c.authorizeCatalogCaller()
+	if !ok {
+		return
+	}
+
+	verbose, _ := c.GetBool("verbose")
+	etag, jsonResponse, err := getModelCatalog(orgId, verbose)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	// The listing depends on the caller's org, so only private caches may
+	// keep it.
+	c.Ctx.Output.Header("ETag", etag)
+	c.Ctx.Output.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(modelCatalogMaxAge.Seconds())))
+	c.Ctx.ResponseWriter.Header().Add("Vary", "Authorization, Cookie")
+	c.EnableRender = false
+	if etagMatches(c.Ctx.Input.Header("If-None-Match"), etag) {
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	c.Ctx.Output.Header("Content-Type", "application/json")
+	c.Ctx.Output.Body(jsonResponse)
+}
+
+// GetModel returns the details of one model of the caller's catalog.
+// @Title GetModel
+// @Tag OpenAI Compatible API
+// @Description Returns a model available to the caller's organization, in the OpenAI retrieve-model shape, with its pricing, context window, capabilities and deprecation status. Requires authentication.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "The model ID, e.g. zen4"
+// @Success 200 {object} controllers.modelDetail
+// @Failure 401 {object} object "Unauthorized"
+// @Failure 404 {object} object "The model does not exist"
+// @router /models/:id [get]
+func (c *ApiController) GetModel() {
+	orgId, ok := c.authorizeCatalogCaller()
+	if !ok {
+		return
+	}
+
+	id := c.Ctx.Input.Param(":id")
+	detail, found := getModelCatalogScope(orgId).model(id)
+	if !found {
+		c.Ctx.Output.Header("Content-Type", "application/json")
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		body, _ := json.Marshal(map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": fmt.Sprintf("The model '%s' does not exist", id),
+				"type":    "invalid_request_error",
+				"param":   "model",
+				"code":    "model_not_found",
+			},
+		})
+		c.Ctx.Output.Body(body)
+		c.EnableRender = false
+		return
+	}
+
+	c.Ctx.Output.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(modelCatalogMaxAge.Seconds())))
+	c.Ctx.ResponseWriter.Header().Add("Vary", "Authorization, Cookie")
+	c.Data["json"] = detail
+	c.ServeJSON()
+}
+
+// authorizeCatalogCaller checks that a model catalog request is
+// authenticated and returns the org whose catalog the caller sees ("" for
+// the public catalog). On failure it has written a 401.
+func (c *ApiController) authorizeCatalogCaller() (string, bool) {
+	// R-04 fix: require authentication for the model catalog.
 	// Accept any valid token type (JWT, IAM key, publishable key, widget key).
 	authHeader := c.Ctx.Request.Header.Get("Authorization")
 	token := ""
@@ -1127,7 +1768,7 @@ func (c *ApiController) ListModels() {
 		c.Ctx.ResponseWriter.WriteHeader(401)
 		c.Ctx.Output.Body([]byte(`{"error":{"message":"Authentication required. Provide a Bearer token.","type":"authentication_error","code":"unauthorized"}}`))
 		c.EnableRender = false
-		return
+		return "", false
 	}
 
 	// R-RED-03: Validate token format — reject obviously invalid bearer values.
@@ -1135,6 +1776,7 @@ func (c *ApiController) ListModels() {
 	// hz_ (Hanzo token). JWTs must have 3 base64url-encoded parts.
 	if token != "" {
 		isKnownPrefix := strings.HasPrefix(token, "hk-") ||
+			isGatewayToken(token) ||
 			strings.HasPrefix(token, "sk-") ||
 			strings.HasPrefix(token, "pk-") ||
 			strings.HasPrefix(token, "hz_")
@@ -1161,32 +1803,44 @@ func (c *ApiController) ListModels() {
 			c.Ctx.ResponseWriter.WriteHeader(401)
 			c.Ctx.Output.Body([]byte(`{"error":{"message":"Invalid token format.","type":"authentication_error","code":"unauthorized"}}`))
 			c.EnableRender = false
-			return
+			return "", false
 		}
 	}
 
-	models := listAvailableModels()
-
-	response := map[string]interface{}{
-		"object": "list",
-		"data":   models,
+	// The catalog follows the caller's org entitlements; callers whose org
+	// cannot be established get the public catalog.
+	orgId := c.GetRequestTenantOrgID()
+	if user := c.GetSessionUser(); orgId == "" && user != nil {
+		orgId = user.Owner
 	}
-
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		c.ResponseError(err.Error())
-		return
+	if orgId == "" && token != "" {
+		orgId = getCatalogOrgForToken(token, util.GetClientIP(c.Ctx.Request))
 	}
+	return orgId, true
+}
 
-	c.Ctx.Output.Header("Content-Type", "application/json")
-	c.Ctx.Output.Body(jsonResponse)
-	c.EnableRender = false
+// admitChatRequest holds a chat completion of authUser to its organization's
+// quotas and its own spend limit, and answers 429 when one is used up.
+func (c *ApiController) admitChatRequest(authUser *iamsdk.User) bool {
+	if authUser == nil {
+		return true
+	}
+	if quota := checkTenantQuota(authUser.Owner); !c.setQuotaHeaders(quota) {
+		c.respondOpenAIError(http.StatusTooManyRequests, "insufficient_quota", "quota_exceeded", quotaExceededMessage(quota))
+		return false
+	}
+	if err := checkMemberSpend(authUser); err != nil {
+		c.respondOpenAIError(http.StatusTooManyRequests, "insufficient_quota", "member_spend_limit", err.Error())
+		return false
+	}
+	return true
 }
 
 // proxyToolRequest forwards an OpenAI chat completion request that contains
 // tool definitions directly to the upstream provider, bypassing the QueryText
 // pipeline which cannot handle structured tool calls. The raw upstream response
-// (including tool_calls) is streamed back to the client.
+// (including tool_calls) is streamed back to the client. The upstream call
+// ends with the client's request and is bounded by the route's timeouts.
 func (c *ApiController) proxyToolRequest(
 	provider *object.Provider,
 	request *openai.ChatCompletionRequest,
@@ -1194,15 +1848,30 @@ func (c *ApiController) proxyToolRequest(
 	authUser *iamsdk.User,
 	isPremium bool,
 	orgId string,
+	route *modelRoute,
 ) {
+	if !c.admitChatRequest(authUser) {
+		return
+	}
+
 	requestId := util.GenerateUUID()
+	timeouts := getRouteTimeouts(route)
+
+	// Fireworks takes tool calls natively and reports exact usage. No
+	// identity prompt is put ahead of tool requests, but a zen answer is
+	// still scanned for a leak.
+	if provider.Type == "Fireworks" {
+		scanIdentity := zenIdentityPrompt(request.Model, orgId) != ""
+		c.fireworksChatCompletion(provider, request, request.Model, request.Model, requestStartTime, authUser, isPremium, requestId, orgId, route, scanIdentity)
+		return
+	}
 
 	// Rewrite model to upstream model name
 	request.Model = provider.SubType
 
 	// For Claude/Anthropic providers, convert to Anthropic Messages API format
 	if provider.Type == "Claude" {
-		c.proxyToolRequestAnthropic(provider, request, requestStartTime, authUser, isPremium, orgId, requestId)
+		c.proxyToolRequestAnthropic(provider, request, requestStartTime, authUser, isPremium, orgId, requestId, timeouts)
 		return
 	}
 
@@ -1220,39 +1889,57 @@ func (c *ApiController) proxyToolRequest(
 		return
 	}
 
+	if !request.Stream {
+		// A non-streamed completion produces no output before it is done.
+		timeouts.firstToken = 0
+	}
+	deadline := startUpstreamDeadline(c.Ctx.Request.Context(), timeouts)
+	defer deadline.Stop()
+
 	// Build upstream HTTP request
-	req, err := http.NewRequest(http.MethodPost, upstreamURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(deadline.Context(), http.MethodPost, upstreamURL, bytes.NewReader(body))
 	if err != nil {
 		c.ResponseError(fmt.Sprintf("Failed to create upstream request: %s", err.Error()))
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if authHeader != "" {
+	if provider.Type == "Azure" {
+		req.Header.Set("api-key", apiKey)
+	} else if authHeader != "" {
 		req.Header.Set("Authorization", authHeader)
 	} else if apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
-	client := &http.Client{Timeout: 120 * time.Second}
+	client, err := provider.GetHttpClient()
+	if err != nil {
+		c.ResponseError(fmt.Sprintf("Failed to create upstream client: %s", err.Error()))
+		return
+	}
 	resp, err := client.Do(req)
 	if err != nil {
+		err = upstreamCallError(deadline.Context(), err)
+		errorClass := classifyUpstreamError(err)
 		if authUser != nil {
 			errRecord := &usageRecord{
-				Owner:     authUser.Owner,
-				User:      authUser.Owner + "/" + authUser.Name,
-				Model:     request.Model,
-				Provider:  provider.Name,
-				Premium:   isPremium,
-				Stream:    request.Stream,
-				Status:    "error",
-				ErrorMsg:  err.Error(),
-				ClientIP:  c.Ctx.Request.RemoteAddr,
-				RequestID: requestId,
+				Owner:      authUser.Owner,
+				User:       authUser.Owner + "/" + authUser.Name,
+				Model:      request.Model,
+				Provider:   provider.Name,
+				Premium:    isPremium,
+				Stream:     request.Stream,
+				Status:     "error",
+				ErrorMsg:   err.Error(),
+				ErrorClass: errorClass,
+				ClientIP:   c.getClientIp(),
+				RequestID:  requestId,
+				LatencyMs:  time.Since(requestStartTime).Milliseconds(),
 			}
+			c.applyExperiment(errRecord)
 			recordUsage(errRecord)
 			recordTrace(errRecord, requestStartTime)
 		}
-		c.ResponseError(fmt.Sprintf("Upstream request failed: %s", err.Error()))
+		c.respondOpenAIUpstreamError(errorClass, fmt.Sprintf("Upstream request failed: %s", err.Error()))
 		return
 	}
 	defer resp.Body.Close()
@@ -1263,6 +1950,10 @@ func (c *ApiController) proxyToolRequest(
 			c.Ctx.ResponseWriter.Header().Add(k, v)
 		}
 	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		object.UpstreamRateLimits.WithLabelValues(provider.Name).Inc()
+		c.Ctx.ResponseWriter.Header().Set("Retry-After", upstreamRetryAfterSeconds(proxy.ParseRetryAfter(resp.Header, time.Now())))
+	}
 
 	if request.Stream {
 		// Stream: copy SSE events directly
@@ -1276,8 +1967,11 @@ func (c *ApiController) proxyToolRequest(
 
 		// Track the last seen chunk ID/model so we can fix bare usage chunks.
 		var lastChunkID, lastChunkModel string
+		var finishReason string
+		var ttftMs int64
 
 		for scanner.Scan() {
+			deadline.markToken()
 			line := scanner.Text()
 
 			// Fix bare usage-only SSE chunks (missing id/object/choices) so
@@ -1306,8 +2000,11 @@ func (c *ApiController) proxyToolRequest(
 			} else if strings.HasPrefix(line, "data: {") && strings.Contains(line, "\"id\"") {
 				// Extract chunk ID/model for reuse in usage chunk
 				var peek struct {
-					ID    string `json:"id"`
-					Model string `json:"model"`
+					ID      string `json:"id"`
+					Model   string `json:"model"`
+					Choices []struct {
+						FinishReason string `json:"finish_reason"`
+					} `json:"choices"`
 				}
 				if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &peek) == nil {
 					if peek.ID != "" {
@@ -1316,12 +2013,25 @@ func (c *ApiController) proxyToolRequest(
 					if peek.Model != "" {
 						lastChunkModel = peek.Model
 					}
+					if len(peek.Choices) > 0 && peek.Choices[0].FinishReason != "" {
+						finishReason = peek.Choices[0].FinishReason
+					}
+				}
+				if ttftMs == 0 {
+					ttftMs = time.Since(requestStartTime).Milliseconds()
 				}
 			}
 
 			_, _ = fmt.Fprintf(c.Ctx.ResponseWriter, "%s\n", line)
 			c.Ctx.ResponseWriter.Flush()
 		}
+		streamErr := upstreamCallError(deadline.Context(), scanner.Err())
+		if streamErr != nil {
+			// The response is committed; end the stream with the error.
+			data, _ := json.Marshal(map[string]interface{}{"error": map[string]string{"message": streamErr.Error(), "type": "upstream_error"}})
+			_, _ = fmt.Fprintf(c.Ctx.ResponseWriter, "data: %s\n\n", data)
+			c.Ctx.ResponseWriter.Flush()
+		}
 
 		// Record usage (approximate — we don't parse SSE for token counts in streaming)
 		if authUser != nil {
@@ -1335,9 +2045,19 @@ func (c *ApiController) proxyToolRequest(
 				Premium:      isPremium,
 				Stream:       true,
 				Status:       "success",
-				ClientIP:     c.Ctx.Request.RemoteAddr,
+				ClientIP:     c.getClientIp(),
 				RequestID:    requestId,
+				LatencyMs:    time.Since(requestStartTime).Milliseconds(),
+				TtftMs:       ttftMs,
+				FinishReason: finishReason,
+			}
+			if streamErr != nil {
+				successRecord.Status = "error"
+				successRecord.ErrorMsg = streamErr.Error()
+				successRecord.ErrorClass = classifyUpstreamError(streamErr)
 			}
+			c.applyPromptEstimate(successRecord)
+			c.applyExperiment(successRecord)
 			recordUsage(successRecord)
 			recordTrace(successRecord, requestStartTime)
 		}
@@ -1345,7 +2065,8 @@ func (c *ApiController) proxyToolRequest(
 		// Non-streaming: read full response, extract token counts, forward
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			c.ResponseError(fmt.Sprintf("Failed to read upstream response: %s", err.Error()))
+			err = upstreamCallError(deadline.Context(), err)
+			c.respondOpenAIUpstreamError(classifyUpstreamError(err), fmt.Sprintf("Failed to read upstream response: %s", err.Error()))
 			return
 		}
 
@@ -1356,6 +2077,9 @@ func (c *ApiController) proxyToolRequest(
 				CompletionTokens int `json:"completion_tokens"`
 				TotalTokens      int `json:"total_tokens"`
 			} `json:"usage"`
+			Choices []struct {
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
 		}
 		_ = json.Unmarshal(respBody, &upstreamResp)
 
@@ -1373,9 +2097,15 @@ func (c *ApiController) proxyToolRequest(
 				Premium:          isPremium,
 				Stream:           false,
 				Status:           "success",
-				ClientIP:         c.Ctx.Request.RemoteAddr,
+				ClientIP:         c.getClientIp(),
 				RequestID:        requestId,
+				LatencyMs:        time.Since(requestStartTime).Milliseconds(),
+			}
+			if len(upstreamResp.Choices) > 0 {
+				successRecord.FinishReason = upstreamResp.Choices[0].FinishReason
 			}
+			c.applyPromptEstimate(successRecord)
+			c.applyExperiment(successRecord)
 			recordUsage(successRecord)
 			recordTrace(successRecord, requestStartTime)
 		}
@@ -1388,7 +2118,7 @@ func (c *ApiController) proxyToolRequest(
 
 // resolveUpstreamEndpoint returns the chat completions URL, API key, and
 // optional full Authorization header for the given provider.
-func resolveUpstreamEndpoint(provider *object.Provider) (url string, apiKey string, authHeader string) {
+func resolveUpstreamEndpoint(provider *object.Provider) (endpoint string, apiKey string, authHeader string) {
 	apiKey = provider.ClientSecret
 
 	switch provider.Type {
@@ -1409,6 +2139,18 @@ func resolveUpstreamEndpoint(provider *object.Provider) (url string, apiKey stri
 	case "Grok":
 		return "https://api.x.ai/v1/chat/completions", apiKey, ""
 
+	case "Groq":
+		return "https://api.groq.com/openai/v1/chat/completions", apiKey, ""
+
+	case "DeepSeek":
+		return "https://api.deepseek.com/v1/chat/completions", apiKey, ""
+
+	case "Mistral":
+		return "https://api.mistral.ai/v1/chat/completions", apiKey, ""
+
+	case "Cohere":
+		return "https://api.cohere.ai/compatibility/v1/chat/completions", apiKey, ""
+
 	case "OpenRouter":
 		return "https://openrouter.ai/api/v1/chat/completions", apiKey, ""
 
@@ -1420,13 +2162,21 @@ func resolveUpstreamEndpoint(provider *object.Provider) (url string, apiKey stri
 		return fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/openai/chat/completions"), apiKey, ""
 
 	case "Azure":
+		// Azure authenticates with an api-key header, set by the caller
 		baseURL := strings.TrimRight(provider.ProviderUrl, "/")
-		apiVersion := provider.ApiVersion
-		if apiVersion == "" {
-			apiVersion = "2024-02-01"
+		if baseURL == "" {
+			return "", "", ""
 		}
 		return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
-			baseURL, provider.SubType, apiVersion), "", "api-key " + apiKey
+			baseURL, url.PathEscape(object.GetAzureDeployment(provider, provider.SubType)),
+			url.QueryEscape(object.GetAzureApiVersion(provider))), apiKey, ""
+
+	case object.SelfHostedProviderType:
+		baseURL := object.GetSelfHostedBaseUrl(provider)
+		if baseURL == "" {
+			return "", "", ""
+		}
+		return baseURL + "/chat/completions", apiKey, ""
 
 	case "Local", "Ollama", "DigitalOcean":
 		// Local/compatible providers with custom URLs
@@ -1461,6 +2211,7 @@ func (c *ApiController) proxyToolRequestAnthropic(
 	isPremium bool,
 	orgId string,
 	requestId string,
+	timeouts routeTimeouts,
 ) {
 	apiKey := provider.ClientSecret
 	baseURL := provider.ProviderUrl
@@ -1583,7 +2334,13 @@ func (c *ApiController) proxyToolRequestAnthropic(
 		return
 	}
 
-	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/messages", bytes.NewReader(body))
+	// The whole response is read before anything is sent to the client.
+	timeouts.firstToken = 0
+	deadline := startUpstreamDeadline(c.Ctx.Request.Context(), timeouts)
+	defer deadline.Stop()
+	ctx, limits := proxy.WithRateLimitRecorder(deadline.Context())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/v1/messages", bytes.NewReader(body))
 	if err != nil {
 		c.ResponseError(fmt.Sprintf("Failed to create Anthropic request: %s", err.Error()))
 		return
@@ -1592,10 +2349,15 @@ func (c *ApiController) proxyToolRequestAnthropic(
 	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{Timeout: 120 * time.Second}
+	client, err := provider.GetHttpClient()
+	if err != nil {
+		c.ResponseError(fmt.Sprintf("Failed to create upstream client: %s", err.Error()))
+		return
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		c.ResponseError(fmt.Sprintf("Anthropic request failed: %s", err.Error()))
+		err = upstreamCallError(deadline.Context(), err)
+		c.respondOpenAIUpstreamError(classifyUpstreamError(err), fmt.Sprintf("Anthropic request failed: %s", err.Error()))
 		return
 	}
 	defer resp.Body.Close()
@@ -1609,12 +2371,16 @@ func (c *ApiController) proxyToolRequestAnthropic(
 	// Read full Anthropic response
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.ResponseError(fmt.Sprintf("Failed to read Anthropic response: %s", err.Error()))
+		err = upstreamCallError(deadline.Context(), err)
+		c.respondOpenAIUpstreamError(classifyUpstreamError(err), fmt.Sprintf("Failed to read Anthropic response: %s", err.Error()))
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		logs.Error("[proxyToolRequest] Anthropic error %d: %s", resp.StatusCode, string(respBody))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.setUpstreamRetryAfter(errorClassRateLimited, provider.Name, limits)
+		}
 		c.Ctx.ResponseWriter.WriteHeader(resp.StatusCode)
 		c.Ctx.Output.Body(respBody)
 		c.EnableRender = false
@@ -1709,9 +2475,13 @@ func (c *ApiController) proxyToolRequestAnthropic(
 			Premium:          isPremium,
 			Stream:           false,
 			Status:           "success",
-			ClientIP:         c.Ctx.Request.RemoteAddr,
+			ClientIP:         c.getClientIp(),
 			RequestID:        requestId,
+			LatencyMs:        time.Since(requestStartTime).Milliseconds(),
+			FinishReason:     string(finishReason),
 		}
+		c.applyPromptEstimate(successRecord)
+		c.applyExperiment(successRecord)
 		recordUsage(successRecord)
 		recordTrace(successRecord, requestStartTime)
 	}
@@ -15,8 +15,20 @@
 package controllers
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/beego/beego/context"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
 )
 
 func TestIsWidgetKey(t *testing.T) {
@@ -121,3 +133,82 @@ func TestValidateWidgetKeyNoConfig(t *testing.T) {
 		t.Error("validateWidgetKey should reject all keys when WIDGET_KEYS is not configured")
 	}
 }
+
+func TestFinishReasonOf(t *testing.T) {
+	if got := finishReasonOf(nil); got != "" {
+		t.Errorf("finishReasonOf(nil) = %q, want empty", got)
+	}
+	if got := finishReasonOf(&model.ModelResult{}); got != "" {
+		t.Errorf("finishReasonOf() = %q for an unreported reason, want empty", got)
+	}
+	if got := finishReasonOf(&model.ModelResult{FinishReason: "length"}); got != "length" {
+		t.Errorf("finishReasonOf() = %q, want length", got)
+	}
+}
+
+// proxyToolRequestFor runs a tool-calling request of user against an
+// upstream that counts its calls, and returns the response and that count.
+func proxyToolRequestFor(t *testing.T, user *iamsdk.User) (*httptest.ResponseRecorder, int32) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	resp := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(resp, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	c := &ApiController{}
+	c.Ctx = ctx
+
+	provider := &object.Provider{Name: "tools-upstream", Type: "OpenAI", Category: "Model", SubType: "gpt-4o", ProviderUrl: upstream.URL}
+	request := &openai.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "What is the weather?"}},
+		Tools:    []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_weather"}}},
+	}
+	c.proxyToolRequest(provider, request, time.Now(), user, false, user.Owner, nil)
+	return resp, atomic.LoadInt32(&calls)
+}
+
+func TestProxyToolRequestTenantQuota(t *testing.T) {
+	quotas := []*object.TenantQuota{{Owner: "tools-quota", Period: object.QuotaPeriodDaily, MaxRequests: 1, Enabled: true}}
+	previous := loadTenantQuotas
+	loadTenantQuotas = func(string) ([]*object.TenantQuota, error) { return quotas, nil }
+	t.Cleanup(func() { loadTenantQuotas = previous })
+
+	// The org's only request of the day is used up.
+	tenantUsage.admit("tools-quota", quotas, time.Now())
+
+	resp, calls := proxyToolRequestFor(t, &iamsdk.User{Owner: "tools-quota", Name: "alice"})
+	if resp.Code != http.StatusTooManyRequests || !strings.Contains(resp.Body.String(), "quota_exceeded") {
+		t.Errorf("response = %d %s, want 429 quota_exceeded", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After is not set")
+	}
+	if calls != 0 {
+		t.Errorf("upstream was called %d times over the quota", calls)
+	}
+}
+
+func TestProxyToolRequestMemberSpend(t *testing.T) {
+	previousQuotas, previousLimit := loadTenantQuotas, loadMemberLimit
+	loadTenantQuotas = func(string) ([]*object.TenantQuota, error) { return nil, nil }
+	loadMemberLimit = func(owner string, user string) (*object.OrgMemberLimit, error) {
+		return &object.OrgMemberLimit{Owner: owner, User: user, MaxSpendCents: 100}, nil
+	}
+	t.Cleanup(func() { loadTenantQuotas, loadMemberLimit = previousQuotas, previousLimit })
+
+	// The member has spent its $1.00 cap.
+	memberUsage.add("tools-spend/bob", quotaUsage{Requests: 1, SpendMicroCents: 100 * util.MicroCentsPerCent}, time.Now())
+
+	resp, calls := proxyToolRequestFor(t, &iamsdk.User{Owner: "tools-spend", Name: "bob"})
+	if resp.Code != http.StatusTooManyRequests || !strings.Contains(resp.Body.String(), "member_spend_limit") {
+		t.Errorf("response = %d %s, want 429 member_spend_limit", resp.Code, resp.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("upstream was called %d times over the spend limit", calls)
+	}
+}
@@ -27,51 +27,99 @@ import (
 // OpenAIWriter implements a writer that formats responses in OpenAI format
 type OpenAIWriter struct {
 	context.Response
-	Cleaner    Cleaner
-	Buffer     []byte
-	MessageBuf []byte
-	RequestID  string
-	Stream     bool
-	StreamSent bool
-	Model      string
+	Cleaner      Cleaner
+	ThinkTags    thinkTagSplitter
+	Buffer       []byte
+	MessageBuf   []byte
+	ReasoningBuf []byte
+	RequestID    string
+	Stream       bool
+	StreamSent   bool
+	Model        string
+	Timing       streamTiming
+	Live         *liveRequest
+	Keepalive    *sseKeepalive
 }
 
 // Write processes incoming data chunks and formats them for OpenAI compatibility
 func (w *OpenAIWriter) Write(p []byte) (n int, err error) {
 	// Parse the incoming SSE message format
-	var content string
+	var parts []thinkTagPart
 
 	if bytes.HasPrefix(p, []byte("event: message\ndata: ")) {
 		prefix := []byte("event: message\ndata: ")
 		suffix := []byte("\n\n")
-		content = string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
-
-		// Add content to message buffer
-		w.MessageBuf = append(w.MessageBuf, []byte(content)...)
+		parts = w.ThinkTags.split(string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix)))
 	} else if bytes.HasPrefix(p, []byte("event: reason\ndata: ")) {
-		// We don't expose reason data in OpenAI format, but we'll store it
+		// Reasoning streamed apart is sent as ThinkTags says; see think_tags.go
 		prefix := []byte("event: reason\ndata: ")
 		suffix := []byte("\n\n")
-		content = string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
+		content := string(bytes.TrimSuffix(bytes.TrimPrefix(p, prefix), suffix))
+		for _, part := range w.ThinkTags.reason(content) {
+			if part.reasoning {
+				w.ReasoningBuf = append(w.ReasoningBuf, []byte(part.text)...)
+			}
+			if err = w.send(part); err != nil {
+				return 0, err
+			}
+		}
+		w.Buffer = append(w.Buffer, p...)
+		return len(p), nil
 	} else {
 		// If we can't parse, just store the raw bytes and attempt to clean
-		content = w.Cleaner.CleanString(string(p))
-		if content != "" {
-			w.MessageBuf = append(w.MessageBuf, []byte(content)...)
-		}
+		parts = w.ThinkTags.raw(string(p), &w.Cleaner)
 	}
 
 	// Always store the original bytes
 	w.Buffer = append(w.Buffer, p...)
+	for _, part := range parts {
+		w.buffer(part)
+		if err = w.send(part); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// FlushThinkTags sends the end of the answer ThinkTags held back, once the
+// upstream has answered.
+func (w *OpenAIWriter) FlushThinkTags() error {
+	for _, part := range w.ThinkTags.flush() {
+		w.buffer(part)
+		if err := w.send(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *OpenAIWriter) buffer(part thinkTagPart) {
+	if part.reasoning {
+		w.ReasoningBuf = append(w.ReasoningBuf, []byte(part.text)...)
+	} else {
+		w.MessageBuf = append(w.MessageBuf, []byte(part.text)...)
+	}
+}
+
+// send streams a part of the answer as a chat completion chunk.
+func (w *OpenAIWriter) send(part thinkTagPart) error {
+	if part.text == "" {
+		return nil
+	}
+	w.Timing.markToken()
+	w.Live.progress()
 
 	// For non-streaming, just collect the data
 	if !w.Stream {
-		return len(p), nil
+		return nil
 	}
 
-	// Skip empty content
-	if content == "" {
-		return len(p), nil
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
+
+	delta := openai.ChatCompletionStreamChoiceDelta{Content: part.text}
+	if part.reasoning {
+		delta = openai.ChatCompletionStreamChoiceDelta{ReasoningContent: part.text}
 	}
 
 	// Create SSE chunk using go-openai library structure
@@ -82,10 +130,8 @@ func (w *OpenAIWriter) Write(p []byte) (n int, err error) {
 		Model:   w.Model,
 		Choices: []openai.ChatCompletionStreamChoice{
 			{
-				Index: 0,
-				Delta: openai.ChatCompletionStreamChoiceDelta{
-					Content: content,
-				},
+				Index:        0,
+				Delta:        delta,
 				FinishReason: openai.FinishReasonNull,
 			},
 		},
@@ -93,19 +139,18 @@ func (w *OpenAIWriter) Write(p []byte) (n int, err error) {
 
 	jsonData, err := json.Marshal(chunk)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	// Send as SSE data chunk - use ResponseWriter to avoid recursion
 	_, err = w.ResponseWriter.Write([]byte(fmt.Sprintf("data: %s\n\n", jsonData)))
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	w.StreamSent = true
 	w.Flush()
-
-	return len(p), nil
+	return nil
 }
 
 // MessageString returns the complete buffered message
@@ -113,11 +158,46 @@ func (w *OpenAIWriter) MessageString() string {
 	return string(w.MessageBuf)
 }
 
+// ReasoningString returns the reasoning sent apart from the message
+func (w *OpenAIWriter) ReasoningString() string {
+	return string(w.ReasoningBuf)
+}
+
+// Started reports whether anything reached the client, after which the
+// response status can no longer change.
+func (w *OpenAIWriter) Started() bool {
+	return w.StreamSent || w.Keepalive.Sent()
+}
+
+// WriteError ends a started stream with an OpenAI-style error event.
+func (w *OpenAIWriter) WriteError(errType string, code string, message string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"code":    code,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
+	if _, err = w.ResponseWriter.Write([]byte(fmt.Sprintf("data: %s\n\n", jsonData))); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
 // Close finalizes the stream by sending completion message and DONE marker
 func (w *OpenAIWriter) Close(promptTokens, completionTokens, totalTokens int) error {
 	if !w.Stream {
 		return nil
 	}
+	w.Keepalive.lock()
+	defer w.Keepalive.unlock()
 
 	if w.StreamSent {
 		// Send final message with finish_reason
@@ -0,0 +1,31 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"github.com/hanzoai/cloud/openapi"
+)
+
+// GetOpenAPISpec
+// @Title GetOpenAPISpec
+// @Tag System API
+// @Description get the OpenAPI 3 document of the gateway, generated from the routes and these annotations
+// @Success 200 {object} object The OpenAPI document
+// @router /openapi.json [get]
+func (c *ApiController) GetOpenAPISpec() {
+	c.Ctx.Output.Header("Content-Type", "application/json")
+	c.Ctx.Output.Body(openapi.Spec)
+	c.EnableRender = false
+}
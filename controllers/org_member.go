@@ -0,0 +1,340 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Org member management. Admins of an organization can see their members'
+// gateway usage, cap each member's spend over a sliding 30-day window and
+// disable a member's API keys. Limits live in the org_member_limit table and
+// are mirrored onto the member's IAM user properties. Member spend is
+// tracked like tenant quota usage: in memory, per instance, for members with
+// a spend limit.
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+// memberSpendWindow is the sliding window of member spend limits.
+const memberSpendWindow = 30 * 24 * time.Hour
+
+// IAM user properties mirroring a member's limit.
+const (
+	memberSpendLimitProperty   = "gatewaySpendLimitCents"
+	memberKeysDisabledProperty = "gatewayKeysDisabled"
+)
+
+// errMemberKeysDisabled is returned for API keys of a member whose keys an
+// org admin disabled.
+var errMemberKeysDisabled = errors.New("API keys of this user are disabled by the organization")
+
+// memberUsage is the sliding window store of member spend, keyed by
+// "owner/name".
+var memberUsage = &tenantUsageStore{orgs: map[string]map[int64]*quotaUsage{}}
+
+// loadMemberLimit is swapped out by tests.
+var loadMemberLimit = object.GetCachedOrgMemberLimit
+
+// checkMemberKeys returns errMemberKeysDisabled when user's keys are
+// disabled. Failed lookups allow the key.
+func checkMemberKeys(user *iamsdk.User) error {
+	if user == nil {
+		return nil
+	}
+	limit, err := loadMemberLimit(user.Owner, user.Name)
+	if err != nil {
+		logs.Warn("org member: limit lookup for %s/%s failed: %v (allowing)", user.Owner, user.Name, err)
+		return nil
+	}
+	if limit != nil && limit.KeysDisabled {
+		return errMemberKeysDisabled
+	}
+	return nil
+}
+
+// checkMemberSpend returns an error when user has spent its limit over the
+// last 30 days. Members without a limit, and failed lookups, pass.
+func checkMemberSpend(user *iamsdk.User) error {
+	if user == nil {
+		return nil
+	}
+	limit, err := loadMemberLimit(user.Owner, user.Name)
+	if err != nil {
+		logs.Warn("org member: limit lookup for %s/%s failed: %v (allowing)", user.Owner, user.Name, err)
+		return nil
+	}
+	if limit == nil || limit.MaxSpendCents <= 0 {
+		return nil
+	}
+
+	memberUsage.mu.Lock()
+	usage, _ := memberUsage.sumLocked(limit.GetId(), memberSpendWindow, time.Now())
+	memberUsage.mu.Unlock()
+	if usage.SpendMicroCents/util.MicroCentsPerCent >= limit.MaxSpendCents {
+		return fmt.Errorf("User %s reached the spend limit of $%.2f set by its organization for the last 30 days.",
+			limit.GetId(), float64(limit.MaxSpendCents)/100)
+	}
+	return nil
+}
+
+// recordMemberUsage counts a finished request's spend against its user's
+// spend limit.
+func recordMemberUsage(record *usageRecord) {
+	if record.Status != "success" || record.User == "" {
+		return
+	}
+	owner, name := util.GetOwnerAndNameFromIdNoCheck(record.User)
+	limit, err := loadMemberLimit(owner, name)
+	if err != nil || limit == nil || limit.MaxSpendCents <= 0 {
+		return
+	}
+	spend := calculateCostMicroCentsWithCache(
+		record.Model, record.PromptTokens, record.CompletionTokens,
+		record.CacheReadTokens, record.CacheWriteTokens,
+	)
+	memberUsage.add(limit.GetId(), quotaUsage{Requests: 1, SpendMicroCents: spend}, time.Now())
+}
+
+// requireOrgAdmin ensures the caller is signed in as an admin of their
+// organization and returns that organization.
+func (c *ApiController) requireOrgAdmin() (string, bool) {
+	owner, ok := c.RequireSessionOwner()
+	if !ok {
+		return "", false
+	}
+	if !util.IsAdmin(c.GetSessionUser()) {
+		c.ResponseError(c.T("auth:this operation requires admin privilege"))
+		return "", false
+	}
+	return owner, true
+}
+
+// fetchIAMUser performs an uncached IAM get-user call by user id.
+func fetchIAMUser(owner string, name string) (*iamsdk.User, error) {
+	iamEndpoint := strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/")
+	if iamEndpoint == "" {
+		return nil, fmt.Errorf("iamEndpoint is not configured")
+	}
+
+	reqURL := fmt.Sprintf("%s/api/get-user?id=%s%s", iamEndpoint, url.QueryEscape(owner+"/"+name), iamAuthQuery())
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(reqURL)
+	if err != nil {
+		return nil, fmt.Errorf("IAM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string       `json:"status"`
+		Msg    string       `json:"msg"`
+		Data   *iamsdk.User `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse IAM response: %w", err)
+	}
+	if result.Status != "ok" {
+		return nil, fmt.Errorf("IAM error: %s", result.Msg)
+	}
+	return result.Data, nil
+}
+
+// mirrorOrgMemberLimit records limit on the member's IAM user properties, so
+// IAM and the services reading it see the same limits as the gateway.
+func mirrorOrgMemberLimit(limit *object.OrgMemberLimit) error {
+	user, err := fetchIAMUser(limit.Owner, limit.User)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s does not exist in IAM", limit.GetId())
+	}
+
+	if user.Properties == nil {
+		user.Properties = map[string]string{}
+	}
+	if limit.MaxSpendCents > 0 {
+		user.Properties[memberSpendLimitProperty] = strconv.FormatInt(limit.MaxSpendCents, 10)
+	} else {
+		delete(user.Properties, memberSpendLimitProperty)
+	}
+	if limit.KeysDisabled {
+		user.Properties[memberKeysDisabledProperty] = "true"
+	} else {
+		delete(user.Properties, memberKeysDisabledProperty)
+	}
+
+	return updateIAMUser(user, "properties")
+}
+
+// orgMemberUsage is one member's gateway usage with its limit.
+type orgMemberUsage struct {
+	User             string                        `json:"user"`
+	Requests         int64                         `json:"requests"`
+	Errors           int64                         `json:"errors"`
+	PromptTokens     int64                         `json:"promptTokens"`
+	CompletionTokens int64                         `json:"completionTokens"`
+	TotalTokens      int64                         `json:"totalTokens"`
+	SpendCents       int64                         `json:"spendCents"` // estimated from the model pricing table
+	Models           []*object.RequestLogUserUsage `json:"models"`
+	MaxSpendCents    int64                         `json:"maxSpendCents"`
+	KeysDisabled     bool                          `json:"keysDisabled"`
+}
+
+// GetOrgMemberUsages
+// @Title GetOrgMemberUsages
+// @Tag Org Member API
+// @Description get the gateway usage of each member of the caller's organization, with their limits
+// @Param   from    query    string  false    "RFC3339 start time (inclusive)"
+// @Param   to      query    string  false    "RFC3339 end time (exclusive)"
+// @Success 200 {array} controllers.orgMemberUsage The Response object
+// @router /get-org-member-usages [get]
+func (c *ApiController) GetOrgMemberUsages() {
+	owner, ok := c.requireOrgAdmin()
+	if !ok {
+		return
+	}
+
+	usages, err := object.GetRequestLogUserUsages(&object.RequestLogFilter{
+		Owner: owner,
+		From:  c.Input().Get("from"),
+		To:    c.Input().Get("to"),
+	})
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	limits, err := object.GetOrgMemberLimits(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	members := map[string]*orgMemberUsage{}
+	member := func(name string) *orgMemberUsage {
+		if members[name] == nil {
+			members[name] = &orgMemberUsage{User: name, Models: []*object.RequestLogUserUsage{}}
+		}
+		return members[name]
+	}
+	for _, usage := range usages {
+		_, name := util.GetOwnerAndNameFromIdNoCheck(usage.User)
+		m := member(name)
+		m.Requests += usage.Requests
+		m.Errors += usage.Errors
+		m.PromptTokens += usage.PromptTokens
+		m.CompletionTokens += usage.CompletionTokens
+		m.TotalTokens += usage.TotalTokens
+		m.SpendCents += calculateCostMicroCentsWithCache(usage.Model, int(usage.PromptTokens), int(usage.CompletionTokens), 0, 0) / util.MicroCentsPerCent
+		m.Models = append(m.Models, usage)
+	}
+	for _, limit := range limits {
+		m := member(limit.User)
+		m.MaxSpendCents = limit.MaxSpendCents
+		m.KeysDisabled = limit.KeysDisabled
+	}
+
+	result := make([]*orgMemberUsage, 0, len(members))
+	for _, m := range members {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].User < result[j].User
+	})
+
+	c.ResponseOk(result)
+}
+
+// updateOrgMemberLimit applies change, given the request body, to a member's
+// limit in the caller's organization, mirrors it to IAM and answers with the
+// stored limit.
+func (c *ApiController) updateOrgMemberLimit(change func(limit *object.OrgMemberLimit, request *object.OrgMemberLimit)) {
+	owner, ok := c.requireOrgAdmin()
+	if !ok {
+		return
+	}
+
+	var request object.OrgMemberLimit
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &request)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	_, name := util.GetOwnerAndNameFromIdNoCheck(request.User)
+
+	before, err := object.GetOrgMemberLimit(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	limit := &object.OrgMemberLimit{Owner: owner, User: name}
+	if before != nil {
+		copied := *before
+		limit = &copied
+	}
+	change(limit, &request)
+
+	success, err := object.SetOrgMemberLimit(limit)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "org-member-limit", owner, limit.GetId(), before, limit)
+		if err = mirrorOrgMemberLimit(limit); err != nil {
+			logs.Warn("org member: failed to mirror limit of %s to IAM: %v", limit.GetId(), err)
+		}
+	}
+
+	c.ResponseOk(limit)
+}
+
+// UpdateOrgMemberLimit
+// @Title UpdateOrgMemberLimit
+// @Tag Org Member API
+// @Description set the 30-day spend limit of a member of the caller's organization (0 = unlimited)
+// @Param body body object.OrgMemberLimit true "user and maxSpendCents"
+// @Success 200 {object} object.OrgMemberLimit The Response object
+// @router /update-org-member-limit [post]
+func (c *ApiController) UpdateOrgMemberLimit() {
+	c.updateOrgMemberLimit(func(limit *object.OrgMemberLimit, request *object.OrgMemberLimit) {
+		limit.MaxSpendCents = request.MaxSpendCents
+	})
+}
+
+// UpdateOrgMemberKeys
+// @Title UpdateOrgMemberKeys
+// @Tag Org Member API
+// @Description disable or re-enable the API keys of a member of the caller's organization
+// @Param body body object.OrgMemberLimit true "user and keysDisabled"
+// @Success 200 {object} object.OrgMemberLimit The Response object
+// @router /update-org-member-keys [post]
+func (c *ApiController) UpdateOrgMemberKeys() {
+	c.updateOrgMemberLimit(func(limit *object.OrgMemberLimit, request *object.OrgMemberLimit) {
+		limit.KeysDisabled = request.KeysDisabled
+	})
+}
@@ -15,19 +15,23 @@
 package controllers
 
 import (
-	"strings"
-
 	"github.com/hanzoai/cloud/conf"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 )
 
+// TenantOrgKey holds the org of the request in the request context data,
+// once the tenant context filter verified it against the request's
+// credentials; see routers/tenant_context_filter.go.
+const TenantOrgKey = "tenant.orgId"
+
 // GetEffectiveOrg resolves the organization for data-scoping purposes.
 // Resolution order:
-//  1. X-IAM-Org-Id header (injected by gateway auth middleware from JWT)
+//  1. X-IAM-Org-Id header, once verified against the request's credentials
 //  2. Authenticated session user's Owner field
 //  3. Config default (iamOrganization env/config value)
 func (c *ApiController) GetEffectiveOrg() string {
-	// 1. Gateway-injected header (trusted, set after JWT validation)
-	if orgID := strings.TrimSpace(c.Ctx.Input.Header("X-IAM-Org-Id")); orgID != "" {
+	// 1. Verified tenant org
+	if orgID := c.GetRequestTenantOrgID(); orgID != "" {
 		return orgID
 	}
 
@@ -40,3 +44,13 @@ func (c *ApiController) GetEffectiveOrg() string {
 	// 3. Config fallback (default org for this instance)
 	return conf.GetConfigString("iamOrganization")
 }
+
+// getCallerOrg returns the organization of a request authenticated as user
+// with an API key or a JWT: the verified tenant org when the request named
+// one, else user's own, never the config default.
+func (c *ApiController) getCallerOrg(user *iamsdk.User) string {
+	if user == nil || c.GetRequestTenantOrgID() != "" {
+		return c.GetEffectiveOrg()
+	}
+	return user.Owner
+}
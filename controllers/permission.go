@@ -75,11 +75,20 @@ func (c *ApiController) UpdatePermission() {
 		panic(err)
 	}
 
+	before, err := iamsdk.GetPermission(permission.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	success, err := iamsdk.UpdatePermission(&permission)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("update", "permission", permission.Owner, permission.Owner+"/"+permission.Name, before, &permission)
+	}
 
 	c.ResponseOk(success)
 }
@@ -104,6 +113,9 @@ func (c *ApiController) AddPermission() {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("add", "permission", permission.Owner, permission.Owner+"/"+permission.Name, nil, &permission)
+	}
 
 	c.ResponseOk(success)
 }
@@ -123,11 +135,20 @@ func (c *ApiController) DeletePermission() {
 		return
 	}
 
+	before, err := iamsdk.GetPermission(permission.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	success, err := iamsdk.DeletePermission(&permission)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		c.recordAdminAudit("delete", "permission", permission.Owner, permission.Owner+"/"+permission.Name, before, nil)
+	}
 
 	c.ResponseOk(success)
 }
@@ -0,0 +1,141 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// PII scrubbing. Organizations opt in through their PII setting (the
+// pii_setting table): with scrubPrompts, emails, phone numbers, card numbers
+// and the organization's own patterns are replaced in every message before
+// the request leaves the gateway, guardrails and tool pass-through included;
+// with scrubLogs, the same happens to captured prompts and responses in the
+// request log and to the messages of stored conversations. Responses to
+// scrubbed requests report the redactions in the X-PII-Redactions header,
+// e.g. "email=2, phone=1".
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// piiRedactionsHeader reports the redactions made in a scrubbed prompt.
+const piiRedactionsHeader = "X-PII-Redactions"
+
+// loadPiiSetting is swapped out by tests.
+var loadPiiSetting = object.GetActivePiiSetting
+
+// scrubPromptPii replaces the PII in the text of messages, in place, when
+// the organization scrubs prompts. It returns the redactions made, or nil.
+// Tool call arguments are left alone, since redacting them could break their
+// JSON. A failed setting lookup is an error, so that the caller rejects the
+// request rather than send a prompt that may be unscrubbed.
+func scrubPromptPii(org string, messages []openai.ChatCompletionMessage) (object.PiiReport, error) {
+	setting, err := loadPiiSetting(org)
+	if err != nil {
+		logs.Warn("pii: failed to load the setting of org=%s: %v", org, err)
+		return nil, fmt.Errorf("failed to load the PII setting: %v", err)
+	}
+	if setting == nil || !setting.ScrubPrompts {
+		return nil, nil
+	}
+
+	report := object.PiiReport{}
+	for i := range messages {
+		messages[i].Content = setting.Scrub(messages[i].Content, report)
+		for j := range messages[i].MultiContent {
+			if messages[i].MultiContent[j].Type == openai.ChatMessagePartTypeText {
+				messages[i].MultiContent[j].Text = setting.Scrub(messages[i].MultiContent[j].Text, report)
+			}
+		}
+	}
+	for typ, count := range report {
+		object.PiiRedactions.WithLabelValues(typ).Add(float64(count))
+	}
+	return report, nil
+}
+
+// setPiiRedactionsHeader reports the redactions of a scrubbed prompt. Nothing
+// is sent when nothing was redacted.
+func (c *ApiController) setPiiRedactionsHeader(report object.PiiReport) {
+	if value := report.String(); value != "" {
+		c.Ctx.ResponseWriter.Header().Set(piiRedactionsHeader, value)
+	}
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetPiiSetting
+// @Title GetPiiSetting
+// @Tag PII API
+// @Description get an organization's PII scrubbing setting
+// @Param   owner    query    string    true    "organization"
+// @Success 200 {object} object.PiiSetting The Response object
+// @router /get-pii-setting [get]
+func (c *ApiController) GetPiiSetting() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	setting, err := object.GetPiiSetting(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if setting == nil {
+		setting = &object.PiiSetting{Owner: owner}
+	}
+
+	c.ResponseOk(setting)
+}
+
+// UpdatePiiSetting
+// @Title UpdatePiiSetting
+// @Tag PII API
+// @Description opt an organization in or out of PII scrubbing of prompts and request logs
+// @Param   body    body    object.PiiSetting    true    "The setting"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-pii-setting [post]
+func (c *ApiController) UpdatePiiSetting() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var setting object.PiiSetting
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &setting)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetPiiSetting(setting.Owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdatePiiSetting(&setting)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "pii-setting", setting.Owner, setting.Owner, before, &setting)
+	}
+
+	c.ResponseOk(success)
+}
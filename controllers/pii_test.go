@@ -0,0 +1,82 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// usePiiSetting serves setting to the prompt scrubber for the test.
+func usePiiSetting(t *testing.T, setting *object.PiiSetting) {
+	if setting != nil {
+		if err := setting.Compile(); err != nil {
+			t.Fatalf("Compile() = %v", err)
+		}
+	}
+	previous := loadPiiSetting
+	loadPiiSetting = func(string) (*object.PiiSetting, error) { return setting, nil }
+	t.Cleanup(func() { loadPiiSetting = previous })
+}
+
+func TestScrubPromptPii(t *testing.T) {
+	messages := func() []openai.ChatCompletionMessage {
+		return []openai.ChatCompletionMessage{
+			{Role: "system", Content: "Support for bob@example.com"},
+			{Role: "user", MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: "Call me at (415) 555-0132"},
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "https://example.com/a.png"}},
+			}},
+		}
+	}
+
+	usePiiSetting(t, &object.PiiSetting{ScrubLogs: true})
+	unscrubbed := messages()
+	if report, err := scrubPromptPii("hanzo", unscrubbed); report != nil || err != nil {
+		t.Errorf("scrubPromptPii() = %v, %v, want nil when prompts are not scrubbed", report, err)
+	}
+	if unscrubbed[0].Content != "Support for bob@example.com" {
+		t.Errorf("Content = %q, want it untouched", unscrubbed[0].Content)
+	}
+
+	usePiiSetting(t, &object.PiiSetting{ScrubPrompts: true})
+	scrubbed := messages()
+	report, err := scrubPromptPii("hanzo", scrubbed)
+	if err != nil {
+		t.Fatalf("scrubPromptPii() = %v", err)
+	}
+	if got, want := report.String(), "email=1, phone=1"; got != want {
+		t.Errorf("report = %q, want %q", got, want)
+	}
+	if scrubbed[0].Content != "Support for [EMAIL]" {
+		t.Errorf("system Content = %q", scrubbed[0].Content)
+	}
+	if scrubbed[1].MultiContent[0].Text != "Call me at [PHONE]" {
+		t.Errorf("user text part = %q", scrubbed[1].MultiContent[0].Text)
+	}
+	if scrubbed[1].MultiContent[1].ImageURL.URL != "https://example.com/a.png" {
+		t.Errorf("image part = %v, want it kept", scrubbed[1].MultiContent[1].ImageURL)
+	}
+
+	previous := loadPiiSetting
+	loadPiiSetting = func(string) (*object.PiiSetting, error) { return nil, errors.New("db down") }
+	t.Cleanup(func() { loadPiiSetting = previous })
+	if _, err := scrubPromptPii("hanzo", messages()); err == nil {
+		t.Error("scrubPromptPii() = nil error, want the failed lookup to reject the prompt")
+	}
+}
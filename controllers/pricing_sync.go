@@ -0,0 +1,394 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/robfig/cron/v3"
+)
+
+// The price sync keeps upstream costs (upstream_costs in models.yaml) in
+// step with what providers publish. Providers with a price source (see
+// model.GetPriceSource) are polled periodically; a published price
+// differing from the known cost of an upstream model the config uses becomes
+// a pending change, stored in the upstream_cost table. A price off by a
+// factor of priceUnitMismatchRatio or more is taken for one in other units
+// and only logged. Nothing is billed differently until an admin approves
+// it: approval sets the cost and scales the sell price of every model served
+// from that upstream by the same ratio, keeping the margin. Approved costs
+// are stored too and applied over each config loaded, every replica's
+// included, until the config's cost of the upstream changes; copy them into
+// models.yaml.
+
+const defaultPriceSyncIntervalHours = 24
+
+// priceUnitMismatchRatio is how far a published price may be from the
+// known cost before it is taken for a price in other units, like per token
+// or per thousand tokens instead of per million.
+const priceUnitMismatchRatio = 100
+
+// upstreamCostCache names the invalidations telling the other replicas to
+// apply newly approved upstream costs.
+const upstreamCostCache = "upstream-cost"
+
+func init() {
+	// Providers without their own price table (Fireworks) bill from the
+	// upstream costs of the central config.
+	model.SetPriceLookup(func(upstreamModel string) (model.ModelPrice, bool) {
+		return GetModelConfig().findUpstreamCost(upstreamModel)
+	})
+	cache.OnInvalidate(upstreamCostCache, func(key string, prefix bool) {
+		GetModelConfig().loadApprovedUpstreamCosts()
+	})
+}
+
+// InitProviderPriceSync polls the providers' published prices every
+// priceSyncIntervalHours (app.conf, default 24).
+func InitProviderPriceSync() {
+	hours := conf.GetConfigInt("priceSyncIntervalHours")
+	if hours <= 0 {
+		hours = defaultPriceSyncIntervalHours
+	}
+	cronJob := cron.New()
+	schedule := fmt.Sprintf("@every %dh", hours)
+	_, err := cronJob.AddFunc(schedule, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		syncProviderPrices(ctx)
+	})
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
+
+// syncProviderPrices fetches the prices of every price-publishing provider
+// and records those differing from the known costs as pending. It returns
+// the number of pending changes found.
+func syncProviderPrices(ctx context.Context) int {
+	providers, err := object.GetProviders("admin")
+	if err != nil {
+		logs.Warn("price sync: failed to list providers: %v", err)
+		return 0
+	}
+
+	found := 0
+	for _, provider := range providers {
+		if provider.Category != "Model" {
+			continue
+		}
+		modelProvider, err := provider.GetModelProvider("en")
+		if err != nil || modelProvider == nil {
+			continue
+		}
+		source, ok := model.GetPriceSource(provider.Type, modelProvider)
+		if !ok {
+			continue
+		}
+		prices, err := source.FetchPrices(ctx)
+		if err != nil {
+			logs.Warn("price sync: failed to fetch prices of provider %s: %v", provider.Name, err)
+			continue
+		}
+		changes := GetModelConfig().diffUpstreamCosts(provider.Name, prices)
+		if err = object.AddPendingUpstreamCosts(changes); err != nil {
+			logs.Warn("price sync: failed to store the price changes of provider %s: %v", provider.Name, err)
+			continue
+		}
+		found += len(changes)
+	}
+	if found > 0 {
+		logs.Info("price sync: %d upstream price changes pending approval", found)
+	}
+	return found
+}
+
+// findUpstreamCost returns the cost of an upstream model ID. Should several
+// providers serve the same ID, the first provider by name wins.
+func (mc *ModelConfig) findUpstreamCost(upstream string) (model.ModelPrice, bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	var found *upstreamRef
+	for ref := range mc.costs {
+		if ref.upstream == upstream && (found == nil || ref.provider < found.provider) {
+			r := ref
+			found = &r
+		}
+	}
+	if found == nil {
+		return model.ModelPrice{}, false
+	}
+	cost := mc.costs[*found]
+	return model.ModelPrice{Model: upstream, InputPerMillion: cost.InputPerMillion, OutputPerMillion: cost.OutputPerMillion}, true
+}
+
+// diffUpstreamCosts returns the published prices of provider that differ
+// from the known costs. Only upstream models the config has a cost for or
+// serves a priced model from are considered, and prices in other units
+// than the known costs are logged and left out.
+func (mc *ModelConfig) diffUpstreamCosts(provider string, prices []model.ModelPrice) []*object.UpstreamCost {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	known := map[upstreamRef]bool{}
+	for ref := range mc.costs {
+		if ref.provider == provider {
+			known[ref] = true
+		}
+	}
+	for _, ref := range mc.servedBy {
+		if ref.provider == provider {
+			known[ref] = true
+		}
+	}
+
+	changes := []*object.UpstreamCost{}
+	for _, price := range prices {
+		ref := upstreamRef{provider: provider, upstream: price.Model}
+		if !known[ref] {
+			continue
+		}
+		current := mc.costs[ref]
+		if current.InputPerMillion == price.InputPerMillion && current.OutputPerMillion == price.OutputPerMillion {
+			continue
+		}
+		if isPriceUnitMismatch(current.InputPerMillion, price.InputPerMillion) || isPriceUnitMismatch(current.OutputPerMillion, price.OutputPerMillion) {
+			logs.Warn("price sync: ignoring the price of %s/%s, $%g/$%g per million against a cost of $%g/$%g, as one in other units",
+				provider, price.Model, price.InputPerMillion, price.OutputPerMillion, current.InputPerMillion, current.OutputPerMillion)
+			continue
+		}
+		changes = append(changes, &object.UpstreamCost{
+			Provider:                 provider,
+			Upstream:                 price.Model,
+			InputPerMillion:          price.InputPerMillion,
+			OutputPerMillion:         price.OutputPerMillion,
+			PreviousInputPerMillion:  current.InputPerMillion,
+			PreviousOutputPerMillion: current.OutputPerMillion,
+		})
+	}
+	return changes
+}
+
+// isPriceUnitMismatch reports whether a published price is so far from the
+// known cost that it must be in other units. Without a known cost there is
+// nothing to compare, and a free model's price may change either way.
+func isPriceUnitMismatch(known float64, published float64) bool {
+	if known <= 0 || published <= 0 {
+		return false
+	}
+	return published >= known*priceUnitMismatchRatio || published*priceUnitMismatchRatio <= known
+}
+
+// loadApprovedUpstreamCosts applies the approved costs to the config.
+func (mc *ModelConfig) loadApprovedUpstreamCosts() {
+	costs, err := object.GetUpstreamCosts(object.UpstreamCostApproved)
+	if err != nil {
+		logs.Warn("price sync: failed to load the approved upstream costs: %v", err)
+		return
+	}
+	mc.applyApprovedUpstreamCosts(costs)
+}
+
+// applyApprovedUpstreamCosts approves each of costs whose previous cost is
+// still the config's, skipping those the config has caught up with or
+// changed since.
+func (mc *ModelConfig) applyApprovedUpstreamCosts(costs []*object.UpstreamCost) {
+	for _, cost := range costs {
+		ref := upstreamRef{provider: cost.Provider, upstream: cost.Upstream}
+		mc.mu.RLock()
+		current := mc.costs[ref]
+		mc.mu.RUnlock()
+		if current.InputPerMillion != cost.PreviousInputPerMillion || current.OutputPerMillion != cost.PreviousOutputPerMillion {
+			continue
+		}
+		mc.approveUpstreamCost(ref, model.ModelPrice{Model: cost.Upstream, InputPerMillion: cost.InputPerMillion, OutputPerMillion: cost.OutputPerMillion})
+	}
+}
+
+// approveUpstreamCost sets the cost of ref and scales the sell price of the
+// models served from it by the change, returning the repriced models. Models
+// of an upstream without a previous cost keep their sell prices.
+func (mc *ModelConfig) approveUpstreamCost(ref upstreamRef, cost model.ModelPrice) []string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	previous, hadCost := mc.costs[ref]
+	mc.costs[ref] = modelPrice{InputPerMillion: cost.InputPerMillion, OutputPerMillion: cost.OutputPerMillion}
+	if !hadCost {
+		return nil
+	}
+
+	repriced := []string{}
+	for key, served := range mc.servedBy {
+		if served != ref {
+			continue
+		}
+		price, ok := mc.pricing[key]
+		if !ok {
+			continue
+		}
+		if previous.InputPerMillion > 0 {
+			price.InputPerMillion *= cost.InputPerMillion / previous.InputPerMillion
+		}
+		if previous.OutputPerMillion > 0 {
+			price.OutputPerMillion *= cost.OutputPerMillion / previous.OutputPerMillion
+		}
+		logs.Info("price sync: repriced %s from $%g/$%g to $%g/$%g per million, following upstream %s/%s",
+			key, mc.pricing[key].InputPerMillion, mc.pricing[key].OutputPerMillion, price.InputPerMillion, price.OutputPerMillion, ref.provider, ref.upstream)
+		mc.pricing[key] = price
+		repriced = append(repriced, key)
+	}
+	sort.Strings(repriced)
+	return repriced
+}
+
+// upstreamCostRequest names a pending change in approve and reject requests.
+type upstreamCostRequest struct {
+	Provider string `json:"provider"`
+	Upstream string `json:"upstream"`
+}
+
+func (c *ApiController) getUpstreamCostRequests() ([]upstreamCostRequest, bool) {
+	var requests []upstreamCostRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &requests); err != nil {
+		c.ResponseError(err.Error())
+		return nil, false
+	}
+	if len(requests) == 0 {
+		c.ResponseError("no upstream costs given")
+		return nil, false
+	}
+	return requests, true
+}
+
+// GetPendingUpstreamCosts
+// @Title GetPendingUpstreamCosts
+// @Tag Admin
+// @Description list upstream price changes awaiting approval
+// @Success 200 {array} object.UpstreamCost
+// @router /get-pending-upstream-costs [get]
+func (c *ApiController) GetPendingUpstreamCosts() {
+	if !c.RequireAdmin() {
+		return
+	}
+	costs, err := object.GetUpstreamCosts(object.UpstreamCostPending)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	c.ResponseOk(costs)
+}
+
+// ApproveUpstreamCosts
+// @Title ApproveUpstreamCosts
+// @Tag Admin
+// @Description apply pending upstream price changes and reprice the models served from them
+// @Param   body    body   []controllers.upstreamCostRequest  true        "The changes to approve"
+// @Success 200 {object} controllers.Response
+// @router /approve-upstream-costs [post]
+func (c *ApiController) ApproveUpstreamCosts() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	cfg := GetModelConfig()
+	if cfg.static {
+		c.ResponseError("model config not initialized")
+		return
+	}
+	requests, ok := c.getUpstreamCostRequests()
+	if !ok {
+		return
+	}
+
+	repriced := map[string][]string{}
+	for _, request := range requests {
+		cost, err := object.ApproveUpstreamCost(request.Provider, request.Upstream)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		if cost == nil {
+			continue
+		}
+		ref := upstreamRef{provider: request.Provider, upstream: request.Upstream}
+		proposed := model.ModelPrice{Model: cost.Upstream, InputPerMillion: cost.InputPerMillion, OutputPerMillion: cost.OutputPerMillion}
+		previous := model.ModelPrice{Model: cost.Upstream, InputPerMillion: cost.PreviousInputPerMillion, OutputPerMillion: cost.PreviousOutputPerMillion}
+		repriced[request.Provider+"/"+request.Upstream] = cfg.approveUpstreamCost(ref, proposed)
+		cache.Invalidate(upstreamCostCache, request.Provider+"/"+request.Upstream)
+		c.recordAdminAudit("approve-upstream-cost", "model-config", "admin", request.Provider+"/"+request.Upstream, previous, proposed)
+	}
+	c.ResponseOk(repriced)
+}
+
+// RejectUpstreamCosts
+// @Title RejectUpstreamCosts
+// @Tag Admin
+// @Description discard pending upstream price changes
+// @Param   body    body   []controllers.upstreamCostRequest  true        "The changes to reject"
+// @Success 200 {object} controllers.Response
+// @router /reject-upstream-costs [post]
+func (c *ApiController) RejectUpstreamCosts() {
+	if !c.RequireAdmin() {
+		return
+	}
+	requests, ok := c.getUpstreamCostRequests()
+	if !ok {
+		return
+	}
+
+	rejected := 0
+	for _, request := range requests {
+		ok, err := object.RejectUpstreamCost(request.Provider, request.Upstream)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		if ok {
+			rejected++
+		}
+	}
+	c.ResponseOk(rejected)
+}
+
+// SyncUpstreamCosts
+// @Title SyncUpstreamCosts
+// @Tag Admin
+// @Description fetch the providers' published prices now
+// @Success 200 {array} object.UpstreamCost
+// @router /sync-upstream-costs [post]
+func (c *ApiController) SyncUpstreamCosts() {
+	if !c.RequireAdmin() {
+		return
+	}
+	syncProviderPrices(c.Ctx.Request.Context())
+	costs, err := object.GetUpstreamCosts(object.UpstreamCostPending)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	c.ResponseOk(costs)
+}
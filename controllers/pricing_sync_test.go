@@ -0,0 +1,135 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+)
+
+func newPriceSyncTestConfig() *ModelConfig {
+	glm5 := upstreamRef{provider: "fireworks", upstream: "accounts/fireworks/models/glm-5"}
+	return &ModelConfig{
+		pricing: map[string]modelPrice{
+			"zen4":            {InputPerMillion: 3.00, OutputPerMillion: 9.60},
+			"fireworks/glm-5": {InputPerMillion: 3.00, OutputPerMillion: 9.60},
+			"gpt-4o":          {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+		},
+		costs: map[upstreamRef]modelPrice{
+			glm5: {InputPerMillion: 1.00, OutputPerMillion: 3.20},
+		},
+		servedBy: map[string]upstreamRef{
+			"zen4":            glm5,
+			"fireworks/glm-5": glm5,
+			"gpt-4o":          {provider: "do-ai", upstream: "openai-gpt-4o"},
+			"fireworks/qwen3": {provider: "fireworks", upstream: "accounts/fireworks/models/qwen3-8b"},
+		},
+	}
+}
+
+func TestDiffUpstreamCosts(t *testing.T) {
+	mc := newPriceSyncTestConfig()
+
+	changes := mc.diffUpstreamCosts("fireworks", []model.ModelPrice{
+		{Model: "accounts/fireworks/models/glm-5", InputPerMillion: 1.00, OutputPerMillion: 3.20},    // unchanged
+		{Model: "accounts/fireworks/models/qwen3-8b", InputPerMillion: 0.20, OutputPerMillion: 0.20}, // served, no cost yet
+		{Model: "accounts/fireworks/models/unused", InputPerMillion: 5.00, OutputPerMillion: 5.00},   // not used by the config
+	})
+	if len(changes) != 1 || changes[0].Upstream != "accounts/fireworks/models/qwen3-8b" {
+		t.Fatalf("diffUpstreamCosts() = %+v, want only qwen3-8b", changes)
+	}
+	if changes[0].PreviousInputPerMillion != 0 || changes[0].InputPerMillion != 0.20 {
+		t.Errorf("qwen3-8b change = %+v, want 0 -> 0.20", changes[0])
+	}
+
+	changes = mc.diffUpstreamCosts("do-ai", []model.ModelPrice{
+		{Model: "accounts/fireworks/models/glm-5", InputPerMillion: 2.00, OutputPerMillion: 6.40},
+	})
+	if len(changes) != 0 {
+		t.Errorf("diffUpstreamCosts() matched another provider's upstream: %+v", changes)
+	}
+
+	// A price per thousand tokens is not taken for a 1000x increase.
+	changes = mc.diffUpstreamCosts("fireworks", []model.ModelPrice{
+		{Model: "accounts/fireworks/models/glm-5", InputPerMillion: 1000.00, OutputPerMillion: 3200.00},
+	})
+	if len(changes) != 0 {
+		t.Errorf("diffUpstreamCosts() proposed a price in other units: %+v", changes)
+	}
+}
+
+func TestApplyApprovedUpstreamCosts(t *testing.T) {
+	mc := newPriceSyncTestConfig()
+	approved := []*object.UpstreamCost{
+		// Approved over the config's cost, so applied.
+		{Provider: "fireworks", Upstream: "accounts/fireworks/models/glm-5", InputPerMillion: 2.00, OutputPerMillion: 6.40, PreviousInputPerMillion: 1.00, PreviousOutputPerMillion: 3.20},
+		// Approved over a cost the config has changed since, so skipped.
+		{Provider: "do-ai", Upstream: "openai-gpt-4o", InputPerMillion: 3.00, OutputPerMillion: 12.00, PreviousInputPerMillion: 2.00, PreviousOutputPerMillion: 8.00},
+	}
+	mc.applyApprovedUpstreamCosts(approved)
+	if price := mc.GetPrice("zen4"); price.InputPerMillion != 6.00 || price.OutputPerMillion != 19.20 {
+		t.Errorf("zen4 = %+v, want $6.00/$19.20", price)
+	}
+	if _, ok := mc.findUpstreamCost("openai-gpt-4o"); ok {
+		t.Error("applied an approval the config changed since")
+	}
+
+	// Applying them again, as when another replica approves, changes nothing.
+	mc.applyApprovedUpstreamCosts(approved)
+	if price := mc.GetPrice("zen4"); price.InputPerMillion != 6.00 {
+		t.Errorf("zen4 input = %v after reapplying, want 6.00", price.InputPerMillion)
+	}
+}
+
+func TestApproveUpstreamCost(t *testing.T) {
+	mc := newPriceSyncTestConfig()
+	glm5 := upstreamRef{provider: "fireworks", upstream: "accounts/fireworks/models/glm-5"}
+
+	repriced := mc.approveUpstreamCost(glm5, model.ModelPrice{Model: glm5.upstream, InputPerMillion: 2.00, OutputPerMillion: 1.60})
+	if len(repriced) != 2 || repriced[0] != "fireworks/glm-5" || repriced[1] != "zen4" {
+		t.Fatalf("approveUpstreamCost() repriced %v, want [fireworks/glm-5 zen4]", repriced)
+	}
+	if price := mc.GetPrice("zen4"); price.InputPerMillion != 6.00 || price.OutputPerMillion != 4.80 {
+		t.Errorf("zen4 = %+v, want $6.00/$4.80 keeping the margin", price)
+	}
+	if price := mc.GetPrice("gpt-4o"); price.InputPerMillion != 2.50 {
+		t.Errorf("gpt-4o input = %v, want 2.50 untouched", price.InputPerMillion)
+	}
+	if cost, ok := mc.findUpstreamCost(glm5.upstream); !ok || cost.InputPerMillion != 2.00 {
+		t.Errorf("findUpstreamCost() = %+v, %v, want the approved cost", cost, ok)
+	}
+
+	qwen := upstreamRef{provider: "fireworks", upstream: "accounts/fireworks/models/qwen3-8b"}
+	if repriced = mc.approveUpstreamCost(qwen, model.ModelPrice{InputPerMillion: 0.20, OutputPerMillion: 0.20}); len(repriced) != 0 {
+		t.Errorf("approveUpstreamCost() repriced %v for an upstream without a previous cost", repriced)
+	}
+	if _, ok := mc.findUpstreamCost(qwen.upstream); !ok {
+		t.Error("findUpstreamCost() should find the newly approved cost")
+	}
+}
+
+func TestStaticUpstreamCostsAreServed(t *testing.T) {
+	served := map[upstreamRef]bool{}
+	for _, route := range modelRoutes {
+		served[upstreamRef{provider: route.providerName, upstream: route.upstreamModel}] = true
+	}
+	for ref := range upstreamCosts {
+		if !served[ref] {
+			t.Errorf("upstream cost of %s/%s has no route serving it", ref.provider, ref.upstream)
+		}
+	}
+}
@@ -15,11 +15,18 @@
 package controllers
 
 import (
-	metric "github.com/luxfi/metric"
-
 	"github.com/hanzoai/cloud/object"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// metricsHandler serves the default registry, which holds every metric in
+// object/prometheus.go. OpenMetrics is negotiated so that scrapers asking
+// for it receive the trace exemplars on cloud_api_latency.
+var metricsHandler = promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+	EnableOpenMetrics: true,
+})
+
 // GetPrometheusInfo
 // @Title GetPrometheusInfo
 // @Tag System API
@@ -43,7 +50,7 @@ func (c *ApiController) GetPrometheusInfo() {
 // GetMetrics
 // @Title GetMetrics
 // @Tag System API
-// @Description get Prometheus metrics
+// @Description get Prometheus metrics, in OpenMetrics format (with exemplars) when the scraper accepts it
 // @Success 200 {string} string The Response metrics in Prometheus format
 // @router /metrics [get]
 func (c *ApiController) GetMetrics() {
@@ -51,5 +58,5 @@ func (c *ApiController) GetMetrics() {
 		return
 	}
 
-	metric.Handler().ServeHTTP(c.Ctx.ResponseWriter, c.Ctx.Request)
+	metricsHandler.ServeHTTP(c.Ctx.ResponseWriter, c.Ctx.Request)
 }
@@ -0,0 +1,254 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Prompt templates. An organization keeps its system prompts as named,
+// versioned templates in the prompt_template table, and chat requests name
+// one instead of sending the prompt:
+//
+//	{"model": "zen4", "template": {"name": "support", "variables": {"product": "Vault"}}, "messages": [...]}
+//
+// The gateway renders the template, the latest version unless the request
+// pins one with "version", and puts its messages in front of the request's
+// before the request is routed. The version used is reported in the
+// X-Prompt-Template header as "name@version".
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// promptTemplateHeader reports the prompt template a chat request was
+// rendered from.
+const promptTemplateHeader = "X-Prompt-Template"
+
+// PromptTemplateCall names the prompt template of a chat request and the
+// values of its variables.
+type PromptTemplateCall struct {
+	Name      string            `json:"name"`
+	Version   int               `json:"version"` // 0 for the latest
+	Variables map[string]string `json:"variables"`
+}
+
+// promptTemplateRequest is the "template" field a chat request body may
+// carry next to the OpenAI fields.
+type promptTemplateRequest struct {
+	Template *PromptTemplateCall `json:"template"`
+}
+
+// renderPromptTemplate puts the rendered messages of the prompt template
+// of org that call names in front of the request's messages. It writes the
+// error response and returns false when the template cannot be rendered.
+func (c *ApiController) renderPromptTemplate(call *PromptTemplateCall, org string, request *openai.ChatCompletionRequest) bool {
+	if call == nil {
+		return true
+	}
+	if call.Name == "" {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", "template.name is required")
+		return false
+	}
+
+	template, err := object.GetCachedPromptTemplate(org, call.Name, call.Version)
+	if err != nil {
+		c.respondOpenAIError(http.StatusInternalServerError, "api_error", "internal_error", err.Error())
+		return false
+	}
+	if template == nil {
+		message := fmt.Sprintf("The prompt template '%s' does not exist", call.Name)
+		if call.Version != 0 {
+			message = fmt.Sprintf("Version %d of the prompt template '%s' does not exist", call.Version, call.Name)
+		}
+		c.respondOpenAIError(http.StatusNotFound, "invalid_request_error", "prompt_template_not_found", message)
+		return false
+	}
+
+	rendered, err := template.Render(call.Variables)
+	if err != nil {
+		c.respondOpenAIError(http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return false
+	}
+	messages := make([]openai.ChatCompletionMessage, 0, len(rendered)+len(request.Messages))
+	for _, message := range rendered {
+		messages = append(messages, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+	request.Messages = append(messages, request.Messages...)
+
+	c.Ctx.ResponseWriter.Header().Set(promptTemplateHeader, fmt.Sprintf("%s@%d", template.Name, template.Version))
+	return true
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetPromptTemplates
+// @Title GetPromptTemplates
+// @Tag Prompt Template API
+// @Description get the latest version of each prompt template of an organization
+// @Param owner query string true "The owner (org) of the templates"
+// @Success 200 {array} object.PromptTemplate The Response object
+// @router /get-prompt-templates [get]
+func (c *ApiController) GetPromptTemplates() {
+	templates, err := object.GetPromptTemplates(c.Input().Get("owner"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(templates)
+}
+
+// GetPromptTemplate
+// @Title GetPromptTemplate
+// @Tag Prompt Template API
+// @Description get a version of an organization's prompt template
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the template"
+// @Param version query int false "The version, the latest when omitted"
+// @Success 200 {object} object.PromptTemplate The Response object
+// @router /get-prompt-template [get]
+func (c *ApiController) GetPromptTemplate() {
+	version, err := c.GetInt("version", 0)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	template, err := object.GetPromptTemplate(c.Input().Get("owner"), c.Input().Get("name"), version)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(template)
+}
+
+// GetPromptTemplateVersions
+// @Title GetPromptTemplateVersions
+// @Tag Prompt Template API
+// @Description get every version of an organization's prompt template, newest first
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the template"
+// @Success 200 {array} object.PromptTemplate The Response object
+// @router /get-prompt-template-versions [get]
+func (c *ApiController) GetPromptTemplateVersions() {
+	versions, err := object.GetPromptTemplateVersions(c.Input().Get("owner"), c.Input().Get("name"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(versions)
+}
+
+// AddPromptTemplate
+// @Title AddPromptTemplate
+// @Tag Prompt Template API
+// @Description add a prompt template for an organization, as its version 1
+// @Param body body object.PromptTemplate true "The details of the template"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-prompt-template [post]
+func (c *ApiController) AddPromptTemplate() {
+	var template object.PromptTemplate
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddPromptTemplate(&template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "prompt-template", template.Owner, template.GetId(), nil, &template)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdatePromptTemplate
+// @Title UpdatePromptTemplate
+// @Tag Prompt Template API
+// @Description add the next version of an organization's prompt template
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the template"
+// @Param body body object.PromptTemplate true "The details of the template"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-prompt-template [post]
+func (c *ApiController) UpdatePromptTemplate() {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var template object.PromptTemplate
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetPromptTemplate(owner, name, 0)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdatePromptTemplate(owner, name, &template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "prompt-template", owner, template.GetId(), before, &template)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeletePromptTemplate
+// @Title DeletePromptTemplate
+// @Tag Prompt Template API
+// @Description delete an organization's prompt template with all its versions
+// @Param body body object.PromptTemplate true "The details of the template"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-prompt-template [post]
+func (c *ApiController) DeletePromptTemplate() {
+	var template object.PromptTemplate
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetPromptTemplate(template.Owner, template.Name, 0)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeletePromptTemplate(&template)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "prompt-template", template.Owner, template.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
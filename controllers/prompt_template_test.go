@@ -0,0 +1,50 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestChatRequestTemplateField(t *testing.T) {
+	raw := `{
+		"model": "zen4",
+		"template": {"name": "support", "version": 2, "variables": {"product": "Vault"}},
+		"hanzo": {"conversation": "conv_1"},
+		"messages": [{"role": "user", "content": "Hi"}]
+	}`
+	var body struct {
+		openai.ChatCompletionRequest
+		hanzoRequestExtension
+		promptTemplateRequest
+	}
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if body.Model != "zen4" || len(body.Messages) != 1 {
+		t.Errorf("chat fields = %q, %d messages", body.Model, len(body.Messages))
+	}
+	if body.Hanzo.Conversation != "conv_1" {
+		t.Errorf("hanzo.conversation = %q", body.Hanzo.Conversation)
+	}
+	template := body.Template
+	if template == nil || template.Name != "support" || template.Version != 2 || template.Variables["product"] != "Vault" {
+		t.Errorf("template = %+v", template)
+	}
+}
@@ -120,8 +120,9 @@ func (c *ApiController) GetProvider() {
 // UpdateProvider
 // @Title UpdateProvider
 // @Tag Provider API
-// @Description update provider
+// @Description update provider. A model provider's secret references must resolve, masked secrets ("***") standing for the stored ones; with test=true it must also answer a tiny completion
 // @Param id query string true "The id (owner/name) of the provider"
+// @Param test query bool false "run a connectivity test before saving a model provider"
 // @Param body body object.Provider true "The details of the provider"
 // @Success 200 {object} controllers.Response The Response object
 // @router /update-provider [post]
@@ -135,11 +136,34 @@ func (c *ApiController) UpdateProvider() {
 		return
 	}
 
+	before, err := object.GetProvider(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	if provider.Category == "Model" {
+		err = object.CheckModelProvider(&provider, before, c.Input().Get("test") == "true", c.GetAcceptLanguage(), c.getAuditActor())
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
 	success, err := object.UpdateProvider(id, &provider)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		if before != nil && before.Category == "Model" {
+			forgetModelProvider(before.Owner, before.Name)
+		}
+		if provider.Category == "Model" {
+			forgetModelProvider(provider.Owner, provider.Name)
+		}
+		c.recordAdminAudit("update", "provider", provider.Owner, provider.GetId(), before, &provider)
+	}
 
 	c.ResponseOk(success)
 }
@@ -147,7 +171,8 @@ func (c *ApiController) UpdateProvider() {
 // AddProvider
 // @Title AddProvider
 // @Tag Provider API
-// @Description add provider
+// @Description add provider. A model provider's secret references must resolve; with test=true it must also answer a tiny completion
+// @Param test query bool false "run a connectivity test before adding a model provider"
 // @Param body body object.Provider true "The details of the provider"
 // @Success 200 {object} controllers.Response The Response object
 // @router /add-provider [post]
@@ -164,11 +189,25 @@ func (c *ApiController) AddProvider() {
 		return
 	}
 	provider.Owner = owner
+	if provider.Category == "Model" {
+		err = object.CheckModelProvider(&provider, nil, c.Input().Get("test") == "true", c.GetAcceptLanguage(), c.getAuditActor())
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
 	success, err := object.AddProvider(&provider)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		if provider.Category == "Model" {
+			forgetModelProvider(provider.Owner, provider.Name)
+		}
+		c.recordAdminAudit("add", "provider", provider.Owner, provider.GetId(), nil, &provider)
+	}
 
 	c.ResponseOk(success)
 }
@@ -188,11 +227,23 @@ func (c *ApiController) DeleteProvider() {
 		return
 	}
 
+	before, err := object.GetProvider(provider.GetId())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	success, err := object.DeleteProvider(&provider)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
+	if success {
+		if before != nil && before.Category == "Model" {
+			forgetModelProvider(before.Owner, before.Name)
+		}
+		c.recordAdminAudit("delete", "provider", provider.Owner, provider.GetId(), before, nil)
+	}
 
 	c.ResponseOk(success)
 }
@@ -0,0 +1,65 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Checks of model providers. add-provider and update-provider refuse a model
+// provider whose secret references (kms://, vault://, ...) do not resolve
+// and, with test=true, one that cannot answer a completion. Every change
+// drops the provider's cached record and KMS secrets.
+
+package controllers
+
+import (
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/object"
+)
+
+// forgetModelProvider drops the cached record and secrets of a changed
+// provider, so the change applies to the next request.
+func forgetModelProvider(owner string, name string) {
+	if err := object.InvalidateModelProviderForOrg(owner, name); err != nil {
+		logs.Warn("provider: failed to invalidate provider %s/%s: %v", owner, name, err)
+	}
+}
+
+// TestModelProvider
+// @Title TestModelProvider
+// @Tag Provider API
+// @Description check that a stored model provider's secret references resolve and that it answers a tiny completion
+// @Param id query string true "The id (owner/name) of the provider"
+// @Success 200 {object} controllers.Response The Response object
+// @router /test-model-provider [post]
+func (c *ApiController) TestModelProvider() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	id := c.Input().Get("id")
+	provider, err := object.GetProvider(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if provider == nil {
+		c.ResponseError("provider not found: " + id)
+		return
+	}
+
+	err = object.CheckModelProvider(provider, nil, true, c.GetAcceptLanguage(), c.getAuditActor())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk()
+}
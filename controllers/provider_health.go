@@ -0,0 +1,134 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+const (
+	// providerUnhealthyFailures is the number of consecutive upstream
+	// failures after which a provider is reported unhealthy.
+	providerUnhealthyFailures = 5
+
+	// providerUnhealthyWindow is how long a failing provider stays
+	// unhealthy without a new failure, so readiness recovers once the
+	// provider is no longer being called.
+	providerUnhealthyWindow = 5 * time.Minute
+)
+
+type providerHealthState struct {
+	consecutiveFailures int
+	lastFailure         time.Time
+}
+
+// providerHealthTracker records the outcome of upstream calls per provider.
+type providerHealthTracker struct {
+	mu     sync.Mutex
+	states map[string]*providerHealthState
+}
+
+var providerHealth = &providerHealthTracker{states: map[string]*providerHealthState{}}
+
+// record notes the outcome of a call to the named provider.
+func (t *providerHealthTracker) record(name string, err error) {
+	if name == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err == nil {
+		delete(t.states, name)
+		return
+	}
+	state, ok := t.states[name]
+	if !ok {
+		state = &providerHealthState{}
+		t.states[name] = state
+	}
+	state.consecutiveFailures++
+	state.lastFailure = time.Now()
+}
+
+// isHealthy reports whether the provider has not failed
+// providerUnhealthyFailures times in a row within providerUnhealthyWindow.
+func (t *providerHealthTracker) isHealthy(name string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.states[name]
+	if !ok {
+		return true
+	}
+	return state.consecutiveFailures < providerUnhealthyFailures || now.Sub(state.lastFailure) > providerUnhealthyWindow
+}
+
+// routeProviderNames returns the distinct, sorted providers referenced by
+// routes, including fallbacks.
+func routeProviderNames(routes map[string]modelRoute) []string {
+	seen := map[string]bool{}
+	for _, route := range routes {
+		seen[route.providerName] = true
+		for _, fb := range route.fallbacks {
+			seen[fb.providerName] = true
+		}
+	}
+	delete(seen, "")
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkModelProviderHealth reports whether at least one routed model
+// provider is configured and not failing. The provider lookups are cached
+// like the other dependency checks, so probes do not reach the database.
+func checkModelProviderHealth() *object.DependencyHealth {
+	return object.CachedDependencyHealth("providers", func() *object.DependencyHealth {
+		now := time.Now()
+		names := GetModelConfig().ProviderNames()
+
+		healthy := 0
+		for _, name := range names {
+			provider, err := object.GetModelProviderByName(name, "job:provider-health")
+			if err != nil || provider == nil {
+				continue
+			}
+			if providerHealth.isHealthy(name, now) {
+				healthy++
+			}
+		}
+
+		health := &object.DependencyHealth{
+			Status: "ok",
+			Detail: fmt.Sprintf("%d/%d providers healthy", healthy, len(names)),
+		}
+		if healthy == 0 {
+			health.Status = "error"
+			health.Error = "no healthy model provider"
+		}
+		return health
+	})
+}
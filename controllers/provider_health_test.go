@@ -0,0 +1,63 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderHealthTracker(t *testing.T) {
+	tracker := &providerHealthTracker{states: map[string]*providerHealthState{}}
+	upstreamErr := errors.New("upstream returned 502")
+
+	for i := 0; i < providerUnhealthyFailures-1; i++ {
+		tracker.record("fireworks", upstreamErr)
+	}
+	if !tracker.isHealthy("fireworks", time.Now()) {
+		t.Fatal("provider should stay healthy below the failure threshold")
+	}
+
+	tracker.record("fireworks", upstreamErr)
+	if tracker.isHealthy("fireworks", time.Now()) {
+		t.Fatal("provider should be unhealthy at the failure threshold")
+	}
+	if !tracker.isHealthy("fireworks", time.Now().Add(providerUnhealthyWindow+time.Second)) {
+		t.Error("provider should recover after the unhealthy window")
+	}
+
+	tracker.record("fireworks", nil)
+	if !tracker.isHealthy("fireworks", time.Now()) {
+		t.Error("a success should reset the failure count")
+	}
+	if !tracker.isHealthy("openai-direct", time.Now()) {
+		t.Error("a provider with no calls should be healthy")
+	}
+}
+
+func TestRouteProviderNames(t *testing.T) {
+	routes := map[string]modelRoute{
+		"a": {providerName: "fireworks", fallbacks: []modelRouteFallback{{providerName: "do-ai"}}},
+		"b": {providerName: "do-ai"},
+		"c": {providerName: ""},
+	}
+	got := routeProviderNames(routes)
+	want := []string{"do-ai", "fireworks"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routeProviderNames() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/beego/beego/context"
+)
+
+// deferredRequestBodyKey is the context data key of a request body left
+// for the handler to decode.
+const deferredRequestBodyKey = "deferredRequestBody"
+
+// DeferRequestBody keeps beego from buffering the body of ctx into
+// Input.RequestBody, so that the handler decodes it straight from the
+// connection with decodeRequestBody. Large multimodal payloads are then held
+// in memory once, decoded, rather than also as raw bytes for the lifetime of
+// the request. Must run before the body is copied, in a BeforeStatic filter.
+func DeferRequestBody(ctx *context.Context) {
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+		return
+	}
+	ctx.Input.SetData(deferredRequestBodyKey, ctx.Request.Body)
+	ctx.Request.Body = http.NoBody
+}
+
+// decodeRequestBody decodes the JSON request body into v, streaming it when
+// it was deferred.
+func (c *ApiController) decodeRequestBody(v interface{}) error {
+	body, ok := c.Ctx.Input.GetData(deferredRequestBodyKey).(io.Reader)
+	if !ok {
+		return json.Unmarshal(c.Ctx.Input.RequestBody, v)
+	}
+	return json.NewDecoder(body).Decode(v)
+}
+
+// requestBodyReader returns the raw request body, streaming it when it was
+// deferred.
+func (c *ApiController) requestBodyReader() io.Reader {
+	body, ok := c.Ctx.Input.GetData(deferredRequestBodyKey).(io.Reader)
+	if !ok {
+		return bytes.NewReader(c.Ctx.Input.RequestBody)
+	}
+	return body
+}
+
+// requestBodyTooLarge returns the message of a 413 response when err is a
+// request body over its limit, or "".
+func requestBodyTooLarge(err error) string {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return ""
+	}
+	return fmt.Sprintf("Request body exceeds the limit of %d bytes", tooLarge.Limit)
+}
@@ -0,0 +1,340 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/beego/beego/logs"
+	"github.com/beego/beego/utils/pagination"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	// requestLogExportPageSize is the rows an export reads from the
+	// database at a time.
+	requestLogExportPageSize = 1000
+
+	// requestLogQueueSize bounds the request logs waiting to be stored.
+	requestLogQueueSize = 4096
+
+	// requestLogWriters is the number of goroutines storing request logs.
+	requestLogWriters = 4
+)
+
+var (
+	requestLogOnce  sync.Once
+	requestLogQueue chan *object.RequestLog
+)
+
+// startRequestLogWriters starts the goroutines that store queued request
+// logs, so a burst of traffic never runs more than requestLogWriters
+// inserts at once.
+func startRequestLogWriters() {
+	requestLogOnce.Do(func() {
+		requestLogQueue = make(chan *object.RequestLog, requestLogQueueSize)
+		for i := 0; i < requestLogWriters; i++ {
+			go writeRequestLogs(requestLogQueue)
+		}
+	})
+}
+
+func writeRequestLogs(queue <-chan *object.RequestLog) {
+	for requestLog := range queue {
+		if err := object.AddRequestLog(requestLog); err != nil {
+			logs.Warn("request log: failed to store request_id=%s: %v", requestLog.RequestId, err)
+		}
+	}
+}
+
+// recordRequestLog stores a finished gateway request in the request log.
+// Prompt capture is decided per organization inside object.AddRequestLog.
+func recordRequestLog(record *usageRecord) {
+	requestLog := &object.RequestLog{
+		Owner:            usageOrganization(record),
+		RequestId:        record.RequestID,
+		User:             record.User,
+		Model:            record.Model,
+		Provider:         record.Provider,
+		Status:           record.Status,
+		ErrorMsg:         record.ErrorMsg,
+		ErrorClass:       record.ErrorClass,
+		Stream:           record.Stream,
+		LatencyMs:        record.LatencyMs,
+		TtftMs:           record.TtftMs,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		TotalTokens:      record.TotalTokens,
+		TokensPerSecond:  record.TokensPerSecond,
+		ClientIp:         record.ClientIP,
+		Prompt:           record.Prompt,
+		Response:         record.Response,
+		Experiment:       record.Experiment,
+		ExperimentArm:    record.ExperimentArm,
+	}
+	for _, decision := range record.Guardrails {
+		requestLog.Guardrails = append(requestLog.Guardrails, decision.String())
+	}
+
+	// Queue without blocking the request; when the writers fall behind
+	// and the queue is full, the log is dropped.
+	startRequestLogWriters()
+	select {
+	case requestLogQueue <- requestLog:
+	default:
+		logs.Warn("request log: queue full, dropped request_id=%s", requestLog.RequestId)
+	}
+}
+
+func (c *ApiController) getRequestLogFilter() *object.RequestLogFilter {
+	return &object.RequestLogFilter{
+		Owner:      c.Input().Get("owner"),
+		User:       c.Input().Get("user"),
+		Model:      c.Input().Get("model"),
+		Provider:   c.Input().Get("provider"),
+		Status:     c.Input().Get("status"),
+		ErrorClass: c.Input().Get("errorClass"),
+		From:       c.Input().Get("from"),
+		To:         c.Input().Get("to"),
+	}
+}
+
+// GetRequestLogs
+// @Title GetRequestLogs
+// @Tag Request Log API
+// @Description get gateway request logs, newest first
+// @Param   owner       query    string  false    "organization"
+// @Param   user        query    string  false    "user, as owner/name"
+// @Param   model       query    string  false    "model"
+// @Param   provider    query    string  false    "provider"
+// @Param   status      query    string  false    "success or error"
+// @Param   errorClass  query    string  false    "upstream error class, e.g. rate_limited"
+// @Param   from        query    string  false    "RFC3339 start time (inclusive)"
+// @Param   to          query    string  false    "RFC3339 end time (exclusive)"
+// @Param   pageSize    query    int     false    "page size (default 50)"
+// @Param   p           query    int     false    "page number"
+// @Success 200 {array} object.RequestLog The Response object
+// @router /get-request-logs [get]
+func (c *ApiController) GetRequestLogs() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	filter := c.getRequestLogFilter()
+	limit := util.ParseInt(c.Input().Get("pageSize"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	count, err := object.GetRequestLogCount(filter)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	paginator := pagination.SetPaginator(c.Ctx, limit, count)
+	requestLogs, err := object.GetPaginationRequestLogs(filter, paginator.Offset(), limit)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(requestLogs, paginator.Nums())
+}
+
+// GetRequestLog
+// @Title GetRequestLog
+// @Tag Request Log API
+// @Description get a gateway request log
+// @Param   id    query    int    true    "The id of the request log"
+// @Success 200 {object} object.RequestLog The Response object
+// @router /get-request-log [get]
+func (c *ApiController) GetRequestLog() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Input().Get("id"))
+	if err != nil {
+		c.ResponseError("id must be an integer")
+		return
+	}
+
+	requestLog, err := object.GetRequestLog(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(requestLog)
+}
+
+// ExportRequestLogs
+// @Title ExportRequestLogs
+// @Tag Request Log API
+// @Description export gateway request logs matching the filters of get-request-logs, as JSON lines or CSV
+// @Param   format    query    string  false    "jsonl (default) or csv"
+// @Success 200 {file} file The exported logs
+// @router /export-request-logs [get]
+func (c *ApiController) ExportRequestLogs() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	format := c.Input().Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "jsonl" && format != "csv" {
+		c.ResponseError("format must be jsonl or csv")
+		return
+	}
+
+	// Write each row as its page is read, paging by id so rows logged
+	// during the export neither shift nor repeat the pages.
+	var writeRow func(l *object.RequestLog) error
+	var flush func() error
+	w := c.Ctx.ResponseWriter
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=request-logs.%s", format))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"id", "createdTime", "owner", "requestId", "user", "model", "provider", "status", "errorMsg", "errorClass",
+			"stream", "latencyMs", "ttftMs", "promptTokens", "completionTokens", "totalTokens",
+			"tokensPerSecond", "clientIp", "prompt", "response", "guardrails",
+		})
+		writeRow = func(l *object.RequestLog) error {
+			return cw.Write([]string{
+				strconv.Itoa(l.Id), l.CreatedTime, l.Owner, l.RequestId, l.User, l.Model, l.Provider, l.Status, l.ErrorMsg, l.ErrorClass,
+				strconv.FormatBool(l.Stream), strconv.FormatInt(l.LatencyMs, 10), strconv.FormatInt(l.TtftMs, 10),
+				strconv.Itoa(l.PromptTokens), strconv.Itoa(l.CompletionTokens), strconv.Itoa(l.TotalTokens),
+				strconv.FormatFloat(l.TokensPerSecond, 'f', -1, 64), l.ClientIp, l.Prompt, l.Response,
+				strings.Join(l.Guardrails, " "),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		writeRow = func(l *object.RequestLog) error { return encoder.Encode(l) }
+		flush = func() error { return nil }
+	}
+
+	filter := c.getRequestLogFilter()
+	for {
+		requestLogs, err := object.GetPaginationRequestLogs(filter, 0, requestLogExportPageSize)
+		if err != nil {
+			if filter.BeforeId == 0 {
+				w.Header().Del("Content-Disposition")
+				c.ResponseError(err.Error())
+				return
+			}
+			// The status line is already sent; a truncated file is all
+			// that can be signalled.
+			logs.Warn("request log: export aborted: %v", err)
+			return
+		}
+		for _, l := range requestLogs {
+			if err = writeRow(l); err != nil {
+				logs.Warn("request log: export aborted: %v", err)
+				return
+			}
+		}
+		if err = flush(); err != nil {
+			logs.Warn("request log: export aborted: %v", err)
+			return
+		}
+		if len(requestLogs) < requestLogExportPageSize {
+			return
+		}
+		filter.BeforeId = requestLogs[len(requestLogs)-1].Id
+	}
+}
+
+// GetRequestLogSetting
+// @Title GetRequestLogSetting
+// @Tag Request Log API
+// @Description get an organization's request log settings
+// @Param   owner    query    string    true    "organization"
+// @Success 200 {object} object.RequestLogSetting The Response object
+// @router /get-request-log-setting [get]
+func (c *ApiController) GetRequestLogSetting() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	setting, err := object.GetRequestLogSetting(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if setting == nil {
+		setting = &object.RequestLogSetting{Owner: owner}
+	}
+
+	c.ResponseOk(setting)
+}
+
+// UpdateRequestLogSetting
+// @Title UpdateRequestLogSetting
+// @Tag Request Log API
+// @Description opt an organization in or out of prompt/response capture in the request log
+// @Param   body    body    object.RequestLogSetting    true    "The settings"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-request-log-setting [post]
+func (c *ApiController) UpdateRequestLogSetting() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var setting object.RequestLogSetting
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &setting)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if setting.Owner == "" {
+		c.ResponseError("owner is required")
+		return
+	}
+
+	before, err := object.GetRequestLogSetting(setting.Owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateRequestLogSetting(&setting)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "request-log-setting", setting.Owner, setting.Owner, before, &setting)
+	}
+
+	c.ResponseOk(success)
+}
@@ -0,0 +1,289 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+const (
+	// defaultRequestTailSampleRate applies when neither the subscriber nor
+	// requestTailSampleRate sets a rate.
+	defaultRequestTailSampleRate = 0.1
+
+	// maxRequestTailSubscribers bounds concurrent tail connections.
+	maxRequestTailSubscribers = 16
+
+	// requestTailProgressInterval throttles progress events per request.
+	requestTailProgressInterval = 500 * time.Millisecond
+
+	// requestTailHeartbeat keeps idle tail connections open through proxies.
+	requestTailHeartbeat = 15 * time.Second
+)
+
+// requestTailEvent is one entry of the live request feed. It carries no
+// prompt or response content, and the user is reduced to a short hash.
+type requestTailEvent struct {
+	Type       string  `json:"type"` // "start", "progress" or "end"
+	RequestId  string  `json:"requestId"`
+	Time       string  `json:"time"`
+	Model      string  `json:"model"`
+	Provider   string  `json:"provider,omitempty"`
+	UserHash   string  `json:"userHash"`
+	Stream     bool    `json:"stream"`
+	Tokens     int     `json:"tokens"` // chunks so far, or completion tokens at end
+	LatencyMs  int64   `json:"latencyMs"`
+	Status     string  `json:"status,omitempty"`
+	ErrorClass string  `json:"errorClass,omitempty"`
+	Sample     float64 `json:"-"`
+}
+
+type requestTailSubscriber struct {
+	events     chan *requestTailEvent
+	sampleRate float64
+}
+
+// requestTailHub fans gateway request events out to admin tail connections.
+type requestTailHub struct {
+	mu          sync.RWMutex
+	subscribers map[*requestTailSubscriber]bool
+}
+
+var requestTail = &requestTailHub{subscribers: map[*requestTailSubscriber]bool{}}
+
+func (h *requestTailHub) subscribe(sampleRate float64) (*requestTailSubscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subscribers) >= maxRequestTailSubscribers {
+		return nil, fmt.Errorf("too many request tail connections (max %d)", maxRequestTailSubscribers)
+	}
+	sub := &requestTailSubscriber{events: make(chan *requestTailEvent, 256), sampleRate: sampleRate}
+	h.subscribers[sub] = true
+	return sub, nil
+}
+
+func (h *requestTailHub) unsubscribe(sub *requestTailSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, sub)
+}
+
+func (h *requestTailHub) active() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscribers) > 0
+}
+
+// publish delivers ev to every subscriber whose sample rate covers the
+// request. Slow subscribers miss events rather than block the gateway.
+func (h *requestTailHub) publish(ev *requestTailEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if ev.Sample >= sub.sampleRate {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+		}
+	}
+}
+
+// requestSample maps a request ID to [0, 1) so that all events of a request
+// are either in or out of a subscriber's sample.
+func requestSample(requestId string) float64 {
+	sum := sha256.Sum256([]byte(requestId))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// hashTailUser redacts a user ID to a stable short hash.
+func hashTailUser(user string) string {
+	if user == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:6])
+}
+
+// tailUserId returns the owner/name of an authenticated user, or "".
+func tailUserId(user *iamsdk.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.Owner + "/" + user.Name
+}
+
+// liveRequest tracks an in-flight gateway request for the request tail.
+// A nil *liveRequest is valid and publishes nothing.
+type liveRequest struct {
+	mu          sync.Mutex
+	event       requestTailEvent
+	startTime   time.Time
+	lastPublish time.Time
+}
+
+// startLiveRequest publishes the start of a request. It returns nil when no
+// admin is tailing, so the hot path does no work.
+func startLiveRequest(requestId, model, provider, user string, stream bool, startTime time.Time) *liveRequest {
+	if !requestTail.active() {
+		return nil
+	}
+	r := &liveRequest{
+		event: requestTailEvent{
+			RequestId: requestId,
+			Model:     model,
+			Provider:  provider,
+			UserHash:  hashTailUser(user),
+			Stream:    stream,
+			Sample:    requestSample(requestId),
+		},
+		startTime: startTime,
+	}
+	r.publish("start", time.Now())
+	return r
+}
+
+// progress notes that a chunk of content arrived, publishing at most once
+// per requestTailProgressInterval.
+func (r *liveRequest) progress() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.event.Tokens++
+	now := time.Now()
+	if now.Sub(r.lastPublish) < requestTailProgressInterval {
+		return
+	}
+	r.publish("progress", now)
+}
+
+func (r *liveRequest) publish(eventType string, now time.Time) {
+	ev := r.event
+	ev.Type = eventType
+	ev.Time = now.UTC().Format(time.RFC3339Nano)
+	ev.LatencyMs = now.Sub(r.startTime).Milliseconds()
+	r.lastPublish = now
+	requestTail.publish(&ev)
+}
+
+// publishRequestTailEnd publishes the outcome of a finished request.
+func publishRequestTailEnd(record *usageRecord) {
+	if !requestTail.active() {
+		return
+	}
+	requestTail.publish(&requestTailEvent{
+		Type:       "end",
+		RequestId:  record.RequestID,
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		Model:      record.Model,
+		Provider:   record.Provider,
+		UserHash:   hashTailUser(record.User),
+		Stream:     record.Stream,
+		Tokens:     record.CompletionTokens,
+		LatencyMs:  record.LatencyMs,
+		Status:     record.Status,
+		ErrorClass: record.ErrorClass,
+		Sample:     requestSample(record.RequestID),
+	})
+}
+
+func getRequestTailSampleRate(value string) (float64, error) {
+	if value == "" {
+		value = conf.GetConfigString("requestTailSampleRate")
+	}
+	if value == "" {
+		return defaultRequestTailSampleRate, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		return 0, fmt.Errorf("sample must be a number in (0, 1]")
+	}
+	return rate, nil
+}
+
+// TailRequests
+// @Title TailRequests
+// @Tag Request Log API
+// @Description stream a sampled, redacted feed of in-flight gateway requests as server-sent events. Events carry model, provider, hashed user, tokens so far and latency, never prompt or response content
+// @Param   sample    query    number  false    "fraction of requests to include, in (0, 1] (default requestTailSampleRate or 0.1)"
+// @Success 200 {object} controllers.requestTailEvent The event stream
+// @router /tail-requests [get]
+func (c *ApiController) TailRequests() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	sampleRate, err := getRequestTailSampleRate(c.Input().Get("sample"))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	sub, err := requestTail.subscribe(sampleRate)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	defer requestTail.unsubscribe(sub)
+
+	c.EnableRender = false
+	w := c.Ctx.ResponseWriter
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+	w.Flush()
+
+	heartbeat := time.NewTicker(requestTailHeartbeat)
+	defer heartbeat.Stop()
+	done := c.Ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case <-heartbeat.C:
+			if _, err = w.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+		case ev := <-sub.events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
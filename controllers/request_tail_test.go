@@ -0,0 +1,62 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRequestTailSampling(t *testing.T) {
+	all, err := requestTail.subscribe(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer requestTail.unsubscribe(all)
+	none, err := requestTail.subscribe(1e-12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer requestTail.unsubscribe(none)
+
+	r := startLiveRequest("req-1", "zen4", "fireworks", "hanzo/alice", true, time.Now())
+	r.progress()
+
+	ev := <-all.events
+	if ev.Type != "start" || ev.RequestId != "req-1" || ev.UserHash == "hanzo/alice" {
+		t.Errorf("start event = %+v, want redacted start of req-1", ev)
+	}
+	if len(none.events) != 0 {
+		t.Error("a request outside the sample should not be delivered")
+	}
+}
+
+func TestRequestSampleIsStable(t *testing.T) {
+	in := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		s := requestSample(id)
+		if s < 0 || s >= 1 || s != requestSample(id) {
+			t.Fatalf("requestSample(%q) = %v, want a stable value in [0, 1)", id, s)
+		}
+		if s < 0.1 {
+			in++
+		}
+	}
+	if in < 50 || in > 150 {
+		t.Errorf("%d of 1000 requests fell in a 10%% sample", in)
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// The completion cache answers repeated chat completions without calling the
+// upstream. It is off unless enabled in app.conf / env:
+//
+//	responseCacheEnabled           = true
+//	responseCacheTtlSeconds        = 3600  ; default 1h
+//	responseCacheHitPricePercent   = 10    ; share of the normal price billed for hits
+//	semanticCacheEmbeddingProvider = text-embedding-3-small ; enables semantic lookups
+//	semanticCacheThreshold         = 0.95  ; minimum cosine similarity of a semantic hit
+//
+// Exact lookups match the organization, model, messages (whitespace
+// trimmed) and sampling parameters. Requests sending `X-Semantic-Cache: true`
+// also match earlier requests whose last message embeds within the threshold
+// of theirs, the rest of the conversation being identical; the embedding is
+// billed to the caller like an embeddings request. The cached answers and
+// the semantic index are shared by the replicas through the cache backend.
+// Requests sending `Cache-Control: no-cache` or `no-store` bypass the cache.
+//
+// Responses carry `X-Cache: HIT` or `MISS`, and hits `X-Cache-Type: exact` or
+// `semantic`.
+const (
+	defaultResponseCacheTtl             = time.Hour
+	defaultResponseCacheHitPricePercent = 10
+	defaultSemanticCacheThreshold       = 0.95
+	responseCacheMaxEntries             = 10000
+	semanticCacheMaxScopes              = 1000
+	semanticCacheMaxEntriesPerScope     = 256
+
+	cacheTypeExact    = "exact"
+	cacheTypeSemantic = "semantic"
+)
+
+// completionCacheEntry is a cached answer with the usage it was billed for.
+type completionCacheEntry struct {
+	Answer           string `json:"answer"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
+}
+
+// completionCacheLookup is where a cacheable request's answer is stored.
+type completionCacheLookup struct {
+	key    string    // exact key
+	scope  string    // everything but the last message, for semantic lookups
+	vector []float32 // normalized embedding of the last message; nil without semantic lookups
+
+	// The embedding of a semantic lookup, billed to the caller.
+	embeddingProvider string
+	embeddingModel    string
+	embeddingTokens   int
+}
+
+var (
+	completionCache     *cache.Loading[completionCacheEntry]
+	completionCacheOnce sync.Once
+	semanticIndex       *semanticCacheIndex
+	semanticIndexOnce   sync.Once
+)
+
+func responseCacheEnabled() bool {
+	return conf.GetConfigBool("responseCacheEnabled")
+}
+
+func responseCacheTtl() time.Duration {
+	if seconds := conf.GetConfigInt("responseCacheTtlSeconds"); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultResponseCacheTtl
+}
+
+// responseCacheHitPricePercent is the share of the normal price, 0 to 100,
+// billed for a cache hit, defaultResponseCacheHitPricePercent when unset.
+func responseCacheHitPricePercent() int64 {
+	if conf.GetConfigString("responseCacheHitPricePercent") == "" {
+		return defaultResponseCacheHitPricePercent
+	}
+	percent := conf.GetConfigInt("responseCacheHitPricePercent")
+	return int64(max(0, min(100, percent)))
+}
+
+func semanticCacheThreshold() float64 {
+	if threshold, err := strconv.ParseFloat(conf.GetConfigString("semanticCacheThreshold"), 64); err == nil && threshold > 0 && threshold <= 1 {
+		return threshold
+	}
+	return defaultSemanticCacheThreshold
+}
+
+func getCompletionCache() *cache.Loading[completionCacheEntry] {
+	completionCacheOnce.Do(func() {
+		completionCache = cache.NewLoading[completionCacheEntry]("completion", cache.Options{
+			MaxEntries: responseCacheMaxEntries,
+			TTL:        responseCacheTtl(),
+			Shared:     true,
+		})
+	})
+	return completionCache
+}
+
+func getSemanticIndex() *semanticCacheIndex {
+	semanticIndexOnce.Do(func() {
+		semanticIndex = newSemanticCacheIndex("semantic-index", semanticCacheMaxEntriesPerScope, responseCacheTtl())
+	})
+	return semanticIndex
+}
+
+// completionCacheKeys returns the exact key of request for org and the scope
+// of its semantic lookups.
+func completionCacheKeys(org string, upstream string, request *openai.ChatCompletionRequest) (string, string) {
+	type message struct {
+		Role  string          `json:"role"`
+		Text  string          `json:"text"`
+		Parts json.RawMessage `json:"parts,omitempty"`
+	}
+	messages := make([]message, 0, len(request.Messages))
+	for _, msg := range request.Messages {
+		m := message{Role: msg.Role, Text: strings.TrimSpace(msg.Content)}
+		if len(msg.MultiContent) > 0 {
+			m.Parts, _ = json.Marshal(msg.MultiContent)
+		}
+		messages = append(messages, m)
+	}
+	material := struct {
+		Org              string                               `json:"org"`
+		Model            string                               `json:"model"`
+		Upstream         string                               `json:"upstream"`
+		Messages         []message                            `json:"messages"`
+		Temperature      float32                              `json:"temperature"`
+		TopP             float32                              `json:"topP"`
+		MaxTokens        int                                  `json:"maxTokens"`
+		MaxCompletion    int                                  `json:"maxCompletionTokens"`
+		PresencePenalty  float32                              `json:"presencePenalty"`
+		FrequencyPenalty float32                              `json:"frequencyPenalty"`
+		Seed             *int                                 `json:"seed"`
+		Stop             []string                             `json:"stop"`
+		ResponseFormat   *openai.ChatCompletionResponseFormat `json:"responseFormat"`
+	}{
+		Org:              org,
+		Model:            strings.ToLower(request.Model),
+		Upstream:         upstream,
+		Messages:         messages,
+		Temperature:      request.Temperature,
+		TopP:             request.TopP,
+		MaxTokens:        request.MaxTokens,
+		MaxCompletion:    request.MaxCompletionTokens,
+		PresencePenalty:  request.PresencePenalty,
+		FrequencyPenalty: request.FrequencyPenalty,
+		Seed:             request.Seed,
+		Stop:             request.Stop,
+		ResponseFormat:   request.ResponseFormat,
+	}
+
+	hash := func() string {
+		data, _ := json.Marshal(material)
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	key := hash()
+	if len(messages) > 0 {
+		material.Messages = messages[:len(messages)-1]
+	}
+	return key, hash()
+}
+
+// isCacheable reports whether a completion request may be answered from the
+// cache: single-choice, without bypass headers.
+func isCacheable(request *openai.ChatCompletionRequest, header http.Header) bool {
+	if !responseCacheEnabled() || request.N > 1 || len(request.Messages) == 0 {
+		return false
+	}
+	cacheControl := strings.ToLower(header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-cache") && !strings.Contains(cacheControl, "no-store")
+}
+
+// lookupCompletionCache returns where request's answer is cached, nil when it
+// is not cacheable, and the cached answer with its cache type on a hit.
+func lookupCompletionCache(ctx context.Context, org string, upstream string, request *openai.ChatCompletionRequest, header http.Header) (*completionCacheLookup, *completionCacheEntry, string) {
+	if !isCacheable(request, header) {
+		return nil, nil, ""
+	}
+	lookup := &completionCacheLookup{}
+	lookup.key, lookup.scope = completionCacheKeys(org, upstream, request)
+	if entry, ok := getCompletionCache().Peek(lookup.key); ok {
+		return lookup, &entry, cacheTypeExact
+	}
+
+	if header.Get("X-Semantic-Cache") != "true" {
+		return lookup, nil, ""
+	}
+	err := embedForSemanticCache(ctx, lookup, request.Messages[len(request.Messages)-1])
+	if err != nil {
+		logs.Warn("response cache: semantic lookup skipped: %v", err)
+		return lookup, nil, ""
+	}
+	if key, ok := getSemanticIndex().nearest(lookup.scope, lookup.vector, semanticCacheThreshold()); ok {
+		if entry, ok := getCompletionCache().Peek(key); ok {
+			return lookup, &entry, cacheTypeSemantic
+		}
+	}
+	return lookup, nil, ""
+}
+
+// storeCompletion caches answer under lookup.
+func storeCompletion(lookup *completionCacheLookup, entry completionCacheEntry) {
+	if lookup == nil || entry.Answer == "" {
+		return
+	}
+	getCompletionCache().Set(lookup.key, entry)
+	if lookup.vector != nil {
+		getSemanticIndex().add(lookup.scope, lookup.vector, lookup.key)
+	}
+}
+
+// embedForSemanticCache embeds the text of msg with the configured embedding
+// provider into lookup, normalized to unit length, with the usage to bill.
+func embedForSemanticCache(ctx context.Context, lookup *completionCacheLookup, msg openai.ChatCompletionMessage) error {
+	name := conf.GetConfigString("semanticCacheEmbeddingProvider")
+	if name == "" {
+		return fmt.Errorf("semanticCacheEmbeddingProvider is not configured")
+	}
+	provider, embeddingProvider, err := object.GetEmbeddingProviderFromContext("admin", name, "en")
+	if err != nil {
+		return err
+	}
+	if embeddingProvider == nil {
+		return fmt.Errorf("embedding provider %s not found", name)
+	}
+
+	text := strings.TrimSpace(msg.Content)
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			text += "\n" + part.Text
+		}
+	}
+	vector, result, err := embeddingProvider.QueryVector(text, ctx, "en")
+	if err != nil {
+		return err
+	}
+	lookup.vector = normalizeVector(vector)
+	lookup.embeddingProvider = provider.Name
+	lookup.embeddingModel = provider.SubType
+	if result != nil {
+		lookup.embeddingTokens = result.TokenCount
+	}
+	return nil
+}
+
+func normalizeVector(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+// semanticCacheIndex keeps, per scope, the normalized prompt embeddings of
+// recently cached answers with their exact keys, newest last. Scopes are
+// shared through the cache backend; a replica adding to one drops the
+// others' copies.
+type semanticCacheIndex struct {
+	name     string
+	mu       sync.Mutex
+	perScope int
+	ttl      time.Duration
+	scopes   *cache.Loading[[]semanticCacheItem]
+}
+
+type semanticCacheItem struct {
+	Vector   []float32 `json:"vector"`
+	Key      string    `json:"key"`
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// newSemanticCacheIndex creates the index called name, keeping perScope
+// vectors per scope for ttl.
+func newSemanticCacheIndex(name string, perScope int, ttl time.Duration) *semanticCacheIndex {
+	return &semanticCacheIndex{
+		name:     name,
+		perScope: perScope,
+		ttl:      ttl,
+		scopes: cache.NewLoading[[]semanticCacheItem](name, cache.Options{
+			MaxEntries: semanticCacheMaxScopes,
+			TTL:        ttl,
+			Shared:     true,
+		}),
+	}
+}
+
+// live returns the unexpired items of scope.
+func (i *semanticCacheIndex) live(scope string) []semanticCacheItem {
+	items, _ := i.scopes.Peek(scope)
+	live := make([]semanticCacheItem, 0, len(items)+1)
+	for _, item := range items {
+		if time.Since(item.StoredAt) < i.ttl {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+func (i *semanticCacheIndex) add(scope string, vector []float32, key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	items := append(i.live(scope), semanticCacheItem{Vector: vector, Key: key, StoredAt: time.Now()})
+	if len(items) > i.perScope {
+		items = items[len(items)-i.perScope:]
+	}
+	i.scopes.Set(scope, items)
+	cache.Invalidate(i.name, scope)
+}
+
+// nearest returns the key of the most similar unexpired vector of scope at
+// or above threshold.
+func (i *semanticCacheIndex) nearest(scope string, vector []float32, threshold float64) (string, bool) {
+	bestKey, best := "", threshold
+	for _, item := range i.live(scope) {
+		if len(item.Vector) != len(vector) {
+			continue
+		}
+		var similarity float64
+		for j := range vector {
+			similarity += float64(vector[j]) * float64(item.Vector[j])
+		}
+		if similarity >= best {
+			bestKey, best = item.Key, similarity
+		}
+	}
+	return bestKey, bestKey != ""
+}
+
+// cachedModelProvider replays a cached answer through the QueryText
+// pipeline, so hits are streamed, recorded and returned like upstream
+// answers.
+type cachedModelProvider struct {
+	entry *completionCacheEntry
+}
+
+func (p *cachedModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*model.RawMessage, prompt string, knowledgeMessages []*model.RawMessage, agentInfo *model.AgentInfo, lang string) (*model.ModelResult, error) {
+	if _, err := fmt.Fprintf(writer, "event: message\ndata: %s\n\n", p.entry.Answer); err != nil {
+		return nil, err
+	}
+	if flusher, ok := writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return &model.ModelResult{
+		PromptTokenCount:   p.entry.PromptTokens,
+		ResponseTokenCount: p.entry.CompletionTokens,
+		TotalTokenCount:    p.entry.PromptTokens + p.entry.CompletionTokens,
+	}, nil
+}
+
+// setCacheHeaders marks the response of a cacheable request as a hit or a
+// miss. Must be called before the response is written.
+func (c *ApiController) setCacheHeaders(lookup *completionCacheLookup, cacheType string) {
+	if lookup == nil {
+		return
+	}
+	header := c.Ctx.ResponseWriter.Header()
+	if cacheType == "" {
+		header.Set("X-Cache", "MISS")
+		return
+	}
+	header.Set("X-Cache", "HIT")
+	header.Set("X-Cache-Type", cacheType)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/context"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestCompletionCacheKeys(t *testing.T) {
+	request := func(question string, temperature float32) *openai.ChatCompletionRequest {
+		return &openai.ChatCompletionRequest{
+			Model:       "zen4",
+			Temperature: temperature,
+			Messages: []openai.ChatCompletionMessage{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: question},
+			},
+		}
+	}
+
+	key, scope := completionCacheKeys("hanzo", "fireworks", request("What is Go?", 0))
+	if other, _ := completionCacheKeys("hanzo", "fireworks", request("  What is Go?\n", 0)); other != key {
+		t.Error("surrounding whitespace changed the key")
+	}
+	if other, _ := completionCacheKeys("acme", "fireworks", request("What is Go?", 0)); other == key {
+		t.Error("requests of different organizations share a key")
+	}
+	if other, _ := completionCacheKeys("hanzo", "fireworks", request("What is Go?", 0.7)); other == key {
+		t.Error("requests with different temperatures share a key")
+	}
+	other, otherScope := completionCacheKeys("hanzo", "fireworks", request("What's Go?", 0))
+	if other == key || otherScope != scope {
+		t.Error("rewording the last message should change the key but not the semantic scope")
+	}
+}
+
+func TestSemanticCacheIndexNearest(t *testing.T) {
+	index := newSemanticCacheIndex("semantic-index-test", 2, time.Hour)
+	index.add("scope", normalizeVector([]float32{1, 0, 0}), "a")
+	index.add("scope", normalizeVector([]float32{0, 1, 0}), "b")
+
+	if key, ok := index.nearest("scope", normalizeVector([]float32{0.1, 1, 0}), 0.95); !ok || key != "b" {
+		t.Errorf("nearest() = %q, %v, want b", key, ok)
+	}
+	if _, ok := index.nearest("scope", normalizeVector([]float32{1, 1, 0}), 0.95); ok {
+		t.Error("nearest() matched below the threshold")
+	}
+	if _, ok := index.nearest("other", normalizeVector([]float32{1, 0, 0}), 0.95); ok {
+		t.Error("nearest() matched across scopes")
+	}
+
+	index.add("scope", normalizeVector([]float32{0, 0, 1}), "c")
+	if _, ok := index.nearest("scope", normalizeVector([]float32{1, 0, 0}), 0.95); ok {
+		t.Error("the oldest vector was kept past the per-scope limit")
+	}
+	index.ttl = 0
+	if _, ok := index.nearest("scope", normalizeVector([]float32{0, 0, 1}), 0.95); ok {
+		t.Error("nearest() matched an expired vector")
+	}
+}
+
+func TestCachedModelProviderReplay(t *testing.T) {
+	writer := &OpenAIWriter{Response: context.Response{ResponseWriter: httptest.NewRecorder()}, Cleaner: *NewCleaner(6)}
+	provider := &cachedModelProvider{entry: &completionCacheEntry{Answer: "Go is a language.", PromptTokens: 12, CompletionTokens: 5}}
+
+	result, err := provider.QueryText(t.Context(), "What is Go?", writer, nil, "", nil, nil, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := writer.MessageString(); got != "Go is a language." {
+		t.Errorf("answer = %q", got)
+	}
+	if result.PromptTokenCount != 12 || result.ResponseTokenCount != 5 || result.TotalTokenCount != 17 {
+		t.Errorf("result = %+v", result)
+	}
+}
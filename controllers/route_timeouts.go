@@ -0,0 +1,177 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// routeTimeouts bound the upstream calls of a model route. Zero leaves a
+// phase unbounded.
+//
+//	models:
+//	  zen4:
+//	    timeouts:
+//	      connect: 5s       # until the upstream connection is established
+//	      first_token: 30s  # until the first output arrives
+//	      total: 5m         # the whole call, streaming included
+type routeTimeouts struct {
+	connect    time.Duration
+	firstToken time.Duration
+	total      time.Duration
+}
+
+// upstreamTimeoutError is the cause of an upstream call canceled by one of
+// its route timeouts. Its message classifies as errorClassTimeout, which is
+// reported to clients as a 504.
+type upstreamTimeoutError struct {
+	phase string
+	limit time.Duration
+}
+
+func (e *upstreamTimeoutError) Error() string {
+	return fmt.Sprintf("upstream timeout: %s exceeded %s", e.phase, e.limit)
+}
+
+// upstreamDeadline enforces routeTimeouts on the context of an upstream
+// call. The caller marks output with markToken or by writing through Writer.
+type upstreamDeadline struct {
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	timers    []*time.Timer
+	connected atomic.Bool
+	started   atomic.Bool
+}
+
+// startUpstreamDeadline derives the context of an upstream call from parent,
+// the request context, and starts its timers. Stop must be called when the
+// call returns.
+func startUpstreamDeadline(parent context.Context, timeouts routeTimeouts) *upstreamDeadline {
+	d := &upstreamDeadline{}
+	d.ctx, d.cancel = context.WithCancelCause(parent)
+
+	if timeouts.connect > 0 {
+		// Clients built on net/http report the connection through the trace
+		// of the request context.
+		d.ctx = httptrace.WithClientTrace(d.ctx, &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) { d.connected.Store(true) },
+		})
+		d.after(timeouts.connect, "connect", func() bool { return !d.connected.Load() })
+	}
+	if timeouts.firstToken > 0 {
+		d.after(timeouts.firstToken, "first token", func() bool { return !d.started.Load() })
+	}
+	if timeouts.total > 0 {
+		d.after(timeouts.total, "total", func() bool { return true })
+	}
+	return d
+}
+
+// after cancels the call when limit passes and expired still holds.
+func (d *upstreamDeadline) after(limit time.Duration, phase string, expired func() bool) {
+	d.timers = append(d.timers, time.AfterFunc(limit, func() {
+		if expired() {
+			d.cancel(&upstreamTimeoutError{phase: phase, limit: limit})
+		}
+	}))
+}
+
+// Context returns the context to make the upstream call with.
+func (d *upstreamDeadline) Context() context.Context {
+	return d.ctx
+}
+
+// markToken notes that the upstream produced output.
+func (d *upstreamDeadline) markToken() {
+	d.started.Store(true)
+}
+
+// Writer wraps w so that writes mark output.
+func (d *upstreamDeadline) Writer(w io.Writer) io.Writer {
+	return &deadlineWriter{Writer: w, deadline: d}
+}
+
+// Err returns the upstreamTimeoutError that canceled the call, or nil.
+func (d *upstreamDeadline) Err() error {
+	return upstreamTimeout(d.ctx)
+}
+
+// Stop releases the timers and the context.
+func (d *upstreamDeadline) Stop() {
+	for _, timer := range d.timers {
+		timer.Stop()
+	}
+	d.cancel(nil)
+}
+
+// deadlineWriter passes writes and flushes through, marking output. The
+// model providers require an http.Flusher.
+type deadlineWriter struct {
+	io.Writer
+	deadline *upstreamDeadline
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	w.deadline.markToken()
+	return w.Writer.Write(p)
+}
+
+func (w *deadlineWriter) Flush() {
+	if flusher, ok := w.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// upstreamTimeout returns the upstreamTimeoutError that canceled ctx, or nil.
+func upstreamTimeout(ctx context.Context) error {
+	var timeoutErr *upstreamTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return nil
+}
+
+// upstreamCallError returns err of a call made with ctx, with the timeout
+// that stopped the call in place of the context error it caused.
+func upstreamCallError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if timeoutErr := upstreamTimeout(ctx); timeoutErr != nil {
+		return timeoutErr
+	}
+	return err
+}
+
+// clientGone reports whether ctx was canceled by the client going away
+// rather than by a route timeout.
+func clientGone(ctx context.Context) bool {
+	return ctx.Err() != nil && upstreamTimeout(ctx) == nil
+}
+
+// getRouteTimeouts returns the timeouts of route, none for a nil route.
+func getRouteTimeouts(route *modelRoute) routeTimeouts {
+	if route == nil {
+		return routeTimeouts{}
+	}
+	return route.timeouts
+}
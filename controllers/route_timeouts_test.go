@@ -0,0 +1,100 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUpstreamDeadlineFirstToken(t *testing.T) {
+	d := startUpstreamDeadline(context.Background(), routeTimeouts{firstToken: 20 * time.Millisecond})
+	defer d.Stop()
+
+	<-d.Context().Done()
+	err := upstreamCallError(d.Context(), context.Canceled)
+	if err == nil || err.Error() != "upstream timeout: first token exceeded 20ms" {
+		t.Fatalf("upstreamCallError() = %v", err)
+	}
+	if status := getUpstreamErrorResponse(classifyUpstreamError(err)).status; status != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", status)
+	}
+	if clientGone(d.Context()) {
+		t.Error("a timeout was taken for the client going away")
+	}
+}
+
+func TestUpstreamDeadlineOutputStopsFirstTokenTimer(t *testing.T) {
+	d := startUpstreamDeadline(context.Background(), routeTimeouts{firstToken: 20 * time.Millisecond, total: time.Minute})
+	defer d.Stop()
+
+	if _, err := io.WriteString(d.Writer(io.Discard), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if d.Context().Err() != nil {
+		t.Errorf("a call with output was canceled: %v", d.Err())
+	}
+}
+
+func TestUpstreamDeadlineTotal(t *testing.T) {
+	d := startUpstreamDeadline(context.Background(), routeTimeouts{total: 20 * time.Millisecond})
+	defer d.Stop()
+
+	d.markToken()
+	<-d.Context().Done()
+	if err := d.Err(); err == nil || err.Error() != "upstream timeout: total exceeded 20ms" {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestUpstreamDeadlineClientGone(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	d := startUpstreamDeadline(parent, routeTimeouts{total: time.Minute})
+	defer d.Stop()
+
+	cancel()
+	if !clientGone(d.Context()) {
+		t.Error("canceling the request context was not taken for the client going away")
+	}
+	if err := upstreamCallError(d.Context(), context.Canceled); err != context.Canceled {
+		t.Errorf("upstreamCallError() = %v, want context.Canceled", err)
+	}
+}
+
+func TestValidateModelTimeouts(t *testing.T) {
+	def := &ModelTimeoutsDef{Connect: "5s", FirstToken: "30s", Total: "5m"}
+	if errs := validateModelTimeouts("zen4", def); len(errs) != 0 {
+		t.Errorf("errors = %q, want none", errs)
+	}
+	if got := def.toRouteTimeouts(); got != (routeTimeouts{connect: 5 * time.Second, firstToken: 30 * time.Second, total: 5 * time.Minute}) {
+		t.Errorf("toRouteTimeouts() = %+v", got)
+	}
+
+	errs := validateModelTimeouts("zen4", &ModelTimeoutsDef{Connect: "soon", FirstToken: "2m", Total: "1m"})
+	want := []string{
+		`models.zen4.timeouts.connect: time: invalid duration "soon"`,
+		"models.zen4.timeouts.first_token: exceeds total",
+	}
+	if len(errs) != len(want) || errs[0] != want[0] || errs[1] != want[1] {
+		t.Errorf("errors = %q, want %q", errs, want)
+	}
+	if errs = validateModelTimeouts("zen4", &ModelTimeoutsDef{Total: "-1s"}); len(errs) != 1 {
+		t.Errorf("errors = %q, want the negative total rejected", errs)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Weighted routing. A route whose fallbacks carry a weight spreads its
+// traffic: each request starts at the primary or at a weighted fallback,
+// picked at random in proportion to the weights (the primary weighs the
+// model's weight, 1 when unset), then fails over to the other upstreams in
+// their configured order. Fallbacks without a weight are only tried on
+// failure. This is how a low-latency provider such as Groq takes a share of
+// a model's traffic without becoming its only upstream.
+
+package controllers
+
+// upstreams returns the primary upstream of r followed by its fallbacks.
+func (r *modelRoute) upstreams() []modelRouteFallback {
+	upstreams := make([]modelRouteFallback, 0, len(r.fallbacks)+1)
+	upstreams = append(upstreams, modelRouteFallback{providerName: r.providerName, upstreamModel: r.upstreamModel, weight: r.weight})
+	return append(upstreams, r.fallbacks...)
+}
+
+// withUpstreams returns a copy of r serving upstreams, the first as primary.
+func (r *modelRoute) withUpstreams(upstreams []modelRouteFallback) *modelRoute {
+	route := *r
+	route.providerName = upstreams[0].providerName
+	route.upstreamModel = upstreams[0].upstreamModel
+	route.weight = upstreams[0].weight
+	route.fallbacks = upstreams[1:]
+	return &route
+}
+
+// isWeighted reports whether any fallback of r takes a share of its traffic.
+func (r *modelRoute) isWeighted() bool {
+	for _, fb := range r.fallbacks {
+		if fb.weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// weightedRoute returns route reordered to start at an upstream picked by
+// weight. intn returns a random number in [0, n). Upstreams available rejects
+// are not picked unless none of the weighted upstreams is available.
+func weightedRoute(route *modelRoute, intn func(n int) int, available func(providerName string) bool) *modelRoute {
+	if route == nil || !route.isWeighted() {
+		return route
+	}
+
+	upstreams := route.upstreams()
+	weightOf := func(i int) int {
+		if i == 0 && upstreams[i].weight <= 0 {
+			return 1
+		}
+		return upstreams[i].weight
+	}
+
+	candidates := []int{}
+	for i := range upstreams {
+		if weightOf(i) > 0 && available(upstreams[i].providerName) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range upstreams {
+			if weightOf(i) > 0 {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+
+	total := 0
+	for _, i := range candidates {
+		total += weightOf(i)
+	}
+	n := intn(total)
+	picked := candidates[len(candidates)-1]
+	for _, i := range candidates {
+		if n < weightOf(i) {
+			picked = i
+			break
+		}
+		n -= weightOf(i)
+	}
+	if picked == 0 {
+		return route
+	}
+
+	ordered := make([]modelRouteFallback, 0, len(upstreams))
+	ordered = append(ordered, upstreams[picked])
+	ordered = append(ordered, upstreams[:picked]...)
+	ordered = append(ordered, upstreams[picked+1:]...)
+	return route.withUpstreams(ordered)
+}
@@ -0,0 +1,61 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import "testing"
+
+func TestWeightedRoute(t *testing.T) {
+	route := &modelRoute{
+		providerName:  "do-ai",
+		upstreamModel: "llama3.3-70b-instruct",
+		fallbacks: []modelRouteFallback{
+			{providerName: "fireworks", upstreamModel: "llama-v3p3-70b-instruct"},
+			{providerName: "groq", upstreamModel: "llama-3.3-70b-versatile", weight: 3},
+		},
+	}
+	all := func(string) bool { return true }
+
+	// Primary weighs 1 and groq 3: draws 0 keep the primary, 1-3 pick groq.
+	if got := weightedRoute(route, func(int) int { return 0 }, all); got.providerName != "do-ai" {
+		t.Errorf("draw 0 picked %s, want do-ai", got.providerName)
+	}
+	got := weightedRoute(route, func(n int) int {
+		if n != 4 {
+			t.Errorf("total weight = %d, want 4", n)
+		}
+		return 1
+	}, all)
+	if got.providerName != "groq" || got.upstreamModel != "llama-3.3-70b-versatile" {
+		t.Fatalf("draw 1 picked %s/%s, want groq", got.providerName, got.upstreamModel)
+	}
+	if len(got.fallbacks) != 2 || got.fallbacks[0].providerName != "do-ai" || got.fallbacks[1].providerName != "fireworks" {
+		t.Errorf("fallbacks = %+v, want do-ai then fireworks", got.fallbacks)
+	}
+	if route.providerName != "do-ai" || len(route.fallbacks) != 2 {
+		t.Error("weightedRoute modified the route it was given")
+	}
+
+	// An unavailable weighted upstream is not picked.
+	noGroq := func(name string) bool { return name != "groq" }
+	if got := weightedRoute(route, func(int) int { return 0 }, noGroq); got.providerName != "do-ai" {
+		t.Errorf("picked %s with groq unavailable, want do-ai", got.providerName)
+	}
+
+	// Routes without weighted fallbacks are returned as is.
+	plain := &modelRoute{providerName: "do-ai", fallbacks: []modelRouteFallback{{providerName: "fireworks"}}}
+	if got := weightedRoute(plain, func(int) int { return 0 }, all); got != plain {
+		t.Error("unweighted route was reordered")
+	}
+}
@@ -56,12 +56,12 @@ func (c *ApiController) ScrapeDocs() {
 
 	stats, err := object.ScrapeAndIndex(auth.Owner, &req, c.GetAcceptLanguage())
 	if err != nil {
-		recordSearchUsage(auth, "scrape", "crawl", "error", 0, c.Ctx.Request.RemoteAddr)
+		recordSearchUsage(auth, "scrape", "crawl", "error", 0, c.getClientIp())
 		c.ResponseError(err.Error())
 		return
 	}
 
-	recordSearchUsage(auth, "scrape", stats.Engine, "success", stats.PagesScraped, c.Ctx.Request.RemoteAddr)
+	recordSearchUsage(auth, "scrape", stats.Engine, "success", stats.PagesScraped, c.getClientIp())
 
 	c.ResponseOk(stats)
 }
@@ -126,7 +126,7 @@ func (c *ApiController) ScrapePreview() {
 			object.ArchiveCrawlPreviewAsync(auth.Owner, req.URL, sr, results[0])
 		}
 
-		recordSearchUsage(auth, "scrape", "crawl4ai", "success", 1, c.Ctx.Request.RemoteAddr)
+		recordSearchUsage(auth, "scrape", "crawl4ai", "success", 1, c.getClientIp())
 		c.ResponseOk(sr)
 		return
 	}
@@ -137,7 +137,7 @@ func (c *ApiController) ScrapePreview() {
 		return
 	}
 
-	recordSearchUsage(auth, "scrape", "fast", "success", 1, c.Ctx.Request.RemoteAddr)
+	recordSearchUsage(auth, "scrape", "fast", "success", 1, c.getClientIp())
 
 	c.ResponseOk(result)
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 )
 
@@ -66,7 +67,7 @@ func (c *ApiController) resolveSearchAuth() *searchAuth {
 
 	// 3. IAM API key (hk-*) -- validate via IAM and resolve owner
 	if isIAMApiKey(token) {
-		iamUser, err := getUserByAccessKey(token)
+		iamUser, err := getUserByAccessKey(token, util.GetClientIP(c.Ctx.Request))
 		if err != nil {
 			logs.Warning("search auth: hk-* key validation failed: %s", err.Error())
 			c.ResponseError("API key validation failed")
@@ -85,7 +86,7 @@ func (c *ApiController) resolveSearchAuth() *searchAuth {
 
 	// 4. Publishable key (pk-*) -- validate via IAM (read-only access)
 	if isPublishableKey(token) {
-		iamUser, err := getUserByAccessKey(token)
+		iamUser, err := getUserByAccessKey(token, util.GetClientIP(c.Ctx.Request))
 		if err != nil {
 			logs.Warning("search auth: pk-* key validation failed: %s", err.Error())
 			c.ResponseError("publishable key validation failed")
@@ -125,7 +126,7 @@ func (c *ApiController) resolveSearchAuth() *searchAuth {
 
 	// 5. JWT token -- validate via IAM OIDC
 	if isJwtToken(token) {
-		claims, err := iamsdk.ParseJwtToken(token)
+		claims, err := validateJwtToken(token)
 		if err != nil {
 			c.ResponseError("invalid token: " + err.Error())
 			return nil
@@ -173,7 +174,7 @@ func (c *ApiController) requireIndexAuth() *searchAuth {
 
 		// hk-* API keys: validate via IAM
 		if isIAMApiKey(token) {
-			iamUser, err := getUserByAccessKey(token)
+			iamUser, err := getUserByAccessKey(token, util.GetClientIP(c.Ctx.Request))
 			if err != nil {
 				logs.Warning("index auth: hk-* key validation failed: %s", err.Error())
 				c.ResponseError("API key validation failed")
@@ -198,7 +199,7 @@ func (c *ApiController) requireIndexAuth() *searchAuth {
 
 		// JWT token: validate via IAM OIDC
 		if isJwtToken(token) {
-			claims, err := iamsdk.ParseJwtToken(token)
+			claims, err := validateJwtToken(token)
 			if err != nil {
 				c.ResponseError("invalid token: " + err.Error())
 				return nil
@@ -321,12 +322,12 @@ func (c *ApiController) SearchDocs() {
 
 	results, err := object.SearchDocuments(auth.Owner, store, &req, c.GetAcceptLanguage())
 	if err != nil {
-		recordSearchUsage(auth, "search-query", req.Mode, "error", 0, c.Ctx.Request.RemoteAddr)
+		recordSearchUsage(auth, "search-query", req.Mode, "error", 0, c.getClientIp())
 		c.ResponseError(err.Error())
 		return
 	}
 
-	recordSearchUsage(auth, "search-query", req.Mode, "success", len(results), c.Ctx.Request.RemoteAddr)
+	recordSearchUsage(auth, "search-query", req.Mode, "success", len(results), c.getClientIp())
 
 	// Cloudflare edge cache headers: 5 min browser cache, 24h edge cache.
 	indexName := object.GetSearchIndexName(auth.Owner, store)
@@ -368,12 +369,12 @@ func (c *ApiController) IndexDocs() {
 
 	count, err := object.IndexDocuments(auth.Owner, store, &req, c.GetAcceptLanguage())
 	if err != nil {
-		recordSearchUsage(auth, "index-docs", "meilisearch", "error", 0, c.Ctx.Request.RemoteAddr)
+		recordSearchUsage(auth, "index-docs", "meilisearch", "error", 0, c.getClientIp())
 		c.ResponseError(err.Error())
 		return
 	}
 
-	recordSearchUsage(auth, "index-docs", "meilisearch", "success", count, c.Ctx.Request.RemoteAddr)
+	recordSearchUsage(auth, "index-docs", "meilisearch", "success", count, c.getClientIp())
 
 	// Purge Cloudflare edge cache for this search index so stale results
 	// are not served after re-indexing. Runs async to avoid blocking.
@@ -0,0 +1,129 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/object"
+	"github.com/robfig/cron/v3"
+)
+
+// selfHostedHealthInterval is how often self-hosted runtimes are probed.
+const selfHostedHealthInterval = 30
+
+// selfHostedPrice is what billing needs of a model provider: whether it is
+// self-hosted, and then its per-token prices.
+type selfHostedPrice struct {
+	selfHosted bool
+	input      float64
+	output     float64
+}
+
+// selfHostedPrices holds the selfHostedPrice of every model provider by
+// owner/name, as of the last health check, so usage records are priced
+// without a lookup of their own.
+var selfHostedPrices atomic.Pointer[map[string]selfHostedPrice]
+
+// InitSelfHostedHealthChecks probes the runtimes of self-hosted providers
+// (see object/provider_self_hosted.go), the admin's and the organizations',
+// periodically and feeds the outcome into the provider health that readiness
+// and weighted routing consult, so a stopped on-prem runtime shows up
+// without waiting for failed requests. The prices of providers are loaded
+// first, and refreshed by every check.
+func InitSelfHostedHealthChecks() {
+	if _, err := loadSelfHostedProviders(); err != nil {
+		logs.Warn("self-hosted: failed to list providers: %v", err)
+	}
+
+	cronJob := cron.New()
+	schedule := fmt.Sprintf("@every %ds", selfHostedHealthInterval)
+	_, err := cronJob.AddFunc(schedule, checkSelfHostedProviders)
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
+
+// loadSelfHostedProviders lists the model providers of every owner, stores
+// their prices in selfHostedPrices and returns the self-hosted ones.
+func loadSelfHostedProviders() ([]*object.Provider, error) {
+	providers, err := object.GetModelProviders()
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]selfHostedPrice, len(providers))
+	selfHosted := []*object.Provider{}
+	for _, provider := range providers {
+		price := selfHostedPrice{selfHosted: provider.Type == object.SelfHostedProviderType}
+		if price.selfHosted {
+			price.input = provider.InputPricePerThousandTokens
+			price.output = provider.OutputPricePerThousandTokens
+			selfHosted = append(selfHosted, provider)
+		}
+		prices[provider.Owner+"/"+provider.Name] = price
+	}
+	selfHostedPrices.Store(&prices)
+	return selfHosted, nil
+}
+
+// checkSelfHostedProviders probes every self-hosted runtime. A provider
+// whose secret cannot be resolved is recorded unhealthy, and the others
+// are still probed.
+func checkSelfHostedProviders() {
+	providers, err := loadSelfHostedProviders()
+	if err != nil {
+		logs.Warn("self-hosted: failed to list providers: %v", err)
+		return
+	}
+	for _, provider := range providers {
+		err = object.ResolveProviderSecretAs(provider, "job:self-hosted-check")
+		if err == nil {
+			err = object.CheckSelfHostedProvider(provider)
+		}
+		if err != nil {
+			logs.Warn("self-hosted: provider %s/%s is unreachable: %v", provider.Owner, provider.Name, err)
+		}
+		providerHealth.record(provider.Name, err)
+	}
+}
+
+// getSelfHostedCost returns the cost of a call served by a
+// self-hosted provider at the provider's own per-token prices, and whether
+// the call was served by one. Self-hosted providers without prices are free.
+// The provider is found like GetModelProviderForOrg finds it, the org's own
+// before the admin's.
+func getSelfHostedCost(record *usageRecord) (usageCost, bool) {
+	prices := selfHostedPrices.Load()
+	if prices == nil {
+		return usageCost{}, false
+	}
+	price, ok := (*prices)[record.Owner+"/"+record.Provider]
+	if !ok || record.Owner == "" {
+		price = (*prices)["admin/"+record.Provider]
+	}
+	if !price.selfHosted {
+		return usageCost{}, false
+	}
+
+	// $ per 1K tokens * tokens / 1000 is dollars; a dollar is 1e8 micro-cents.
+	return usageCost{
+		input:  int64(math.Round(float64(record.PromptTokens) * price.input * 1e5)),
+		output: int64(math.Round(float64(record.CompletionTokens) * price.output * 1e5)),
+	}, true
+}
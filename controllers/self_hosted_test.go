@@ -0,0 +1,49 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import "testing"
+
+func TestGetSelfHostedCost(t *testing.T) {
+	previous := selfHostedPrices.Load()
+	t.Cleanup(func() { selfHostedPrices.Store(previous) })
+	selfHostedPrices.Store(&map[string]selfHostedPrice{
+		"admin/vllm":  {selfHosted: true, input: 0.001, output: 0.002},
+		"admin/local": {selfHosted: true},
+		"acme/vllm":   {},
+		"beta/tgi":    {selfHosted: true, input: 0.01},
+	})
+
+	tests := []struct {
+		owner      string
+		provider   string
+		want       int64
+		selfHosted bool
+	}{
+		{"", "vllm", 500000, true},      // 1000*0.001 + 2000*0.002 = $0.005
+		{"other", "vllm", 500000, true}, // the admin's provider
+		{"acme", "vllm", 0, false},      // acme's own provider of that name
+		{"admin", "local", 0, true},     // self-hosted without prices is free
+		{"beta", "tgi", 1000000, true},  // an org's self-hosted provider
+		{"other", "openai", 0, false},   // unknown provider
+	}
+	for _, tt := range tests {
+		record := &usageRecord{Owner: tt.owner, Provider: tt.provider, PromptTokens: 1000, CompletionTokens: 2000}
+		cost, selfHosted := getSelfHostedCost(record)
+		if got := cost.total(); got != tt.want || selfHosted != tt.selfHosted {
+			t.Errorf("%s/%s: got %d, %v, want %d, %v", tt.owner, tt.provider, cost.total(), selfHosted, tt.want, tt.selfHosted)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Shadow traffic. A model may mirror a sample of its chat requests to a
+// candidate upstream, to compare the candidate with the upstream serving
+// the model before the model is switched over:
+//
+//	shadow: { provider: fireworks, upstream: accounts/fireworks/models/glm-5, percent: 5 }
+//
+// A sampled request is sent to the shadow once the primary has answered.
+// The shadow's answer is discarded and its usage is neither recorded nor
+// billed; its outcome does not count toward the provider's health. What is
+// kept are the cloud_shadow_* metrics: the latency and completion tokens of
+// both upstreams, and how much the two answers have in common.
+//
+// Cached answers are not mirrored, and neither are the requests of orgs
+// whose residency restricts the model's upstreams, since the shadow may be
+// outside their region.
+
+package controllers
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+)
+
+const (
+	// maxShadowInflight bounds the mirrored requests running at once;
+	// samples beyond it are dropped.
+	maxShadowInflight = 16
+	// defaultShadowTimeout bounds a mirrored request of a model without a
+	// total timeout.
+	defaultShadowTimeout = 2 * time.Minute
+)
+
+var shadowInflight = make(chan struct{}, maxShadowInflight)
+
+// routeShadow is the candidate upstream a route mirrors requests to.
+type routeShadow struct {
+	providerName  string
+	upstreamModel string
+	percent       float64 // Share of requests mirrored, in (0, 100]
+}
+
+// shadowSample is a request picked for mirroring, with what the primary
+// upstream made of it.
+type shadowSample struct {
+	model           string
+	org             string
+	caller          string
+	question        string
+	history         []*model.RawMessage
+	knowledge       []*model.RawMessage
+	lang            string
+	primaryUpstream string
+	primaryLatency  time.Duration
+	primaryTokens   int
+	primaryAnswer   string
+}
+
+// sampleShadow reports whether a request goes to the shadow, given roll in
+// [0, 1).
+func sampleShadow(shadow *routeShadow, roll func() float64) bool {
+	return shadow != nil && roll()*100 < shadow.percent
+}
+
+// routeUpstreamLabel names the upstream of route that providerName served,
+// as "provider/upstream".
+func routeUpstreamLabel(route *modelRoute, providerName string) string {
+	for _, upstream := range route.upstreams() {
+		if upstream.providerName == providerName {
+			return upstream.providerName + "/" + upstream.upstreamModel
+		}
+	}
+	return providerName
+}
+
+// mirrorToShadow sends a sample of route's requests to its shadow upstream
+// in the background.
+func mirrorToShadow(route *modelRoute, sample shadowSample) {
+	if route == nil || route.residency != "" || !sampleShadow(route.shadow, rand.Float64) {
+		return
+	}
+	shadow := *route.shadow
+	select {
+	case shadowInflight <- struct{}{}:
+	default:
+		object.ShadowRequests.WithLabelValues(sample.model, shadow.providerName+"/"+shadow.upstreamModel, "dropped").Inc()
+		return
+	}
+
+	timeout := route.timeouts.total
+	if timeout <= 0 {
+		timeout = defaultShadowTimeout
+	}
+	go func() {
+		defer func() { <-shadowInflight }()
+		ctx, cancel := context.WithTimeout(withSecretCaller(context.Background(), sample.caller), timeout)
+		defer cancel()
+		runShadow(ctx, shadow, sample)
+	}()
+}
+
+func runShadow(ctx context.Context, shadow routeShadow, sample shadowSample) {
+	upstream := shadow.providerName + "/" + shadow.upstreamModel
+	start := time.Now()
+	answer := &CarrierWriter{}
+	modelProvider, err := getUpstreamModelProvider(ctx, sample.org, shadow.providerName, shadow.upstreamModel, sample.lang)
+	var result *model.ModelResult
+	if err == nil {
+		result, err = modelProvider.QueryText(ctx, sample.question, answer, sample.history, "", sample.knowledge, nil, sample.lang)
+		err = upstreamCallError(ctx, err)
+	}
+	latency := time.Since(start)
+	if err != nil {
+		logs.Warn("shadow: %s for %s failed: %v", upstream, sample.model, err)
+		object.ShadowRequests.WithLabelValues(sample.model, upstream, "error").Inc()
+		return
+	}
+
+	object.ShadowRequests.WithLabelValues(sample.model, upstream, "success").Inc()
+	object.ShadowLatency.WithLabelValues(sample.model, sample.primaryUpstream, "primary").Observe(sample.primaryLatency.Seconds())
+	object.ShadowLatency.WithLabelValues(sample.model, upstream, "shadow").Observe(latency.Seconds())
+	object.ShadowCompletionTokens.WithLabelValues(sample.model, sample.primaryUpstream, "primary").Add(float64(sample.primaryTokens))
+	if result != nil {
+		object.ShadowCompletionTokens.WithLabelValues(sample.model, upstream, "shadow").Add(float64(result.ResponseTokenCount))
+	}
+	object.ShadowAnswerSimilarity.WithLabelValues(sample.model, upstream).Observe(answerSimilarity(sample.primaryAnswer, answer.MessageString()))
+}
+
+// answerSimilarity returns the Jaccard index of the words of a and b: 1 when
+// they use the same words, 0 when they share none.
+func answerSimilarity(a string, b string) float64 {
+	wordsA := answerWords(a)
+	wordsB := answerWords(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+func answerWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,;:!?\"'()[]{}*`")
+		if word != "" {
+			words[word] = true
+		}
+	}
+	return words
+}
@@ -0,0 +1,123 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestShadowRouteFromConfig(t *testing.T) {
+	file := &ModelConfigFile{
+		Version: 1,
+		Models: map[string]ModelDef{
+			"zen4": {
+				Provider: "do-ai",
+				Upstream: "glm-5",
+				Shadow:   &ModelShadowDef{Provider: "fireworks", Upstream: "glm-5-fp8", Percent: 5},
+			},
+			"zen4-mini": {Provider: "do-ai", Upstream: "glm-4"},
+		},
+	}
+	cfg, err := buildModelConfig(file)
+	if err != nil {
+		t.Fatalf("buildModelConfig() error = %v", err)
+	}
+
+	shadow := cfg.routes["zen4"].shadow
+	if shadow == nil || *shadow != (routeShadow{providerName: "fireworks", upstreamModel: "glm-5-fp8", percent: 5}) {
+		t.Errorf("shadow = %+v", shadow)
+	}
+	if cfg.routes["zen4-mini"].shadow != nil {
+		t.Error("a model without a shadow got one")
+	}
+	if got := describeModelRoute(cfg.routes["zen4"]); !strings.HasSuffix(got, ", shadow 5% to fireworks/glm-5-fp8") {
+		t.Errorf("describeModelRoute() = %q", got)
+	}
+}
+
+func TestValidateModelShadow(t *testing.T) {
+	if errs := validateModelShadow("zen4", &ModelShadowDef{Provider: "fireworks", Upstream: "glm-5", Percent: 100}); len(errs) != 0 {
+		t.Errorf("errors = %q, want none", errs)
+	}
+
+	errs := validateModelShadow("zen4", &ModelShadowDef{Provider: "fireworks", Percent: 0})
+	want := []string{
+		"models.zen4.shadow: provider and upstream are required",
+		"models.zen4.shadow.percent: must be above 0 and at most 100",
+	}
+	if len(errs) != len(want) || errs[0] != want[0] || errs[1] != want[1] {
+		t.Errorf("errors = %q, want %q", errs, want)
+	}
+	if errs = validateModelShadow("zen4", &ModelShadowDef{Provider: "fireworks", Upstream: "glm-5", Percent: 150}); len(errs) != 1 {
+		t.Errorf("errors = %q, want the percent rejected", errs)
+	}
+}
+
+func TestSampleShadow(t *testing.T) {
+	shadow := &routeShadow{providerName: "fireworks", upstreamModel: "glm-5", percent: 5}
+	roll := func(value float64) func() float64 {
+		return func() float64 { return value }
+	}
+
+	if !sampleShadow(shadow, roll(0.049)) {
+		t.Error("a roll under the percent was not sampled")
+	}
+	if sampleShadow(shadow, roll(0.05)) {
+		t.Error("a roll at the percent was sampled")
+	}
+	if sampleShadow(nil, roll(0)) {
+		t.Error("a route without a shadow was sampled")
+	}
+	if !sampleShadow(&routeShadow{percent: 100}, roll(0.999)) {
+		t.Error("a 100% shadow skipped a request")
+	}
+}
+
+func TestRouteUpstreamLabel(t *testing.T) {
+	route := &modelRoute{
+		providerName:  "do-ai",
+		upstreamModel: "glm-5",
+		fallbacks:     []modelRouteFallback{{providerName: "fireworks", upstreamModel: "glm-5-fp8"}},
+	}
+	for provider, want := range map[string]string{
+		"do-ai":     "do-ai/glm-5",
+		"fireworks": "fireworks/glm-5-fp8",
+		"openai":    "openai",
+	} {
+		if got := routeUpstreamLabel(route, provider); got != want {
+			t.Errorf("routeUpstreamLabel(%q) = %q, want %q", provider, got, want)
+		}
+	}
+}
+
+func TestAnswerSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Paris is the capital.", "paris is the capital", 1},
+		{"", "", 1},
+		{"Paris", "", 0},
+		{"The capital is Paris.", "The capital is Lyon.", 0.6},
+		{"yes", "no", 0},
+	}
+	for _, tt := range tests {
+		if got := answerSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("answerSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
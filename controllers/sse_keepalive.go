@@ -0,0 +1,130 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+)
+
+// defaultSSEKeepaliveInterval is how long a stream may stay silent before a
+// keepalive comment is sent, when sseKeepaliveSeconds is unset. Common proxy
+// and load balancer idle timeouts are 30 to 60 seconds.
+const defaultSSEKeepaliveInterval = 15 * time.Second
+
+// sseKeepaliveComment is an SSE comment line, which clients ignore.
+var sseKeepaliveComment = []byte(": keepalive\n\n")
+
+// sseKeepalive writes a keepalive comment to a stream whenever nothing was
+// written to it for an interval, so that proxies do not sever the stream
+// while a slow model is still reasoning. Writers hold lock while writing to
+// the stream. A nil *sseKeepalive does nothing.
+type sseKeepalive struct {
+	mu       sync.Mutex
+	w        http.ResponseWriter
+	interval time.Duration
+	last     time.Time
+	sent     bool
+	stopped  bool
+	stop     chan struct{}
+}
+
+// getSSEKeepaliveInterval returns the sseKeepaliveSeconds setting; a negative
+// value disables keepalives.
+func getSSEKeepaliveInterval() time.Duration {
+	seconds := conf.GetConfigInt("sseKeepaliveSeconds")
+	if seconds == 0 {
+		return defaultSSEKeepaliveInterval
+	}
+	return time.Duration(max(seconds, 0)) * time.Second
+}
+
+// startSSEKeepalive starts sending keepalives to w every interval of
+// silence. Returns nil when interval is not positive. Stop must be called
+// before the handler returns.
+func startSSEKeepalive(w http.ResponseWriter, interval time.Duration) *sseKeepalive {
+	if interval <= 0 {
+		return nil
+	}
+	k := &sseKeepalive{w: w, interval: interval, last: time.Now(), stop: make(chan struct{})}
+	go k.run()
+	return k
+}
+
+func (k *sseKeepalive) run() {
+	ticker := time.NewTicker(k.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			k.mu.Lock()
+			if !k.stopped && time.Since(k.last) >= k.interval {
+				if _, err := k.w.Write(sseKeepaliveComment); err == nil {
+					if flusher, ok := k.w.(http.Flusher); ok {
+						flusher.Flush()
+					}
+					k.sent = true
+				}
+				k.last = time.Now()
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+// lock excludes keepalives while the caller writes to the stream.
+func (k *sseKeepalive) lock() {
+	if k != nil {
+		k.mu.Lock()
+	}
+}
+
+// unlock ends a write started with lock, restarting the silence interval.
+func (k *sseKeepalive) unlock() {
+	if k != nil {
+		k.last = time.Now()
+		k.mu.Unlock()
+	}
+}
+
+// Sent reports whether a keepalive was written, which commits the response
+// status and headers.
+func (k *sseKeepalive) Sent() bool {
+	if k == nil {
+		return false
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.sent
+}
+
+// Stop stops the keepalives; no keepalive is written once it returns. It may
+// be called more than once.
+func (k *sseKeepalive) Stop() {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.stopped {
+		k.stopped = true
+		close(k.stop)
+	}
+}
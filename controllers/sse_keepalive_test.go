@@ -0,0 +1,91 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/context"
+)
+
+func TestSSEKeepaliveDuringSilence(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	k := startSSEKeepalive(recorder, 20*time.Millisecond)
+	time.Sleep(70 * time.Millisecond)
+	k.Stop()
+
+	if !k.Sent() {
+		t.Fatal("no keepalive was sent during the silence")
+	}
+	if body := recorder.Body.String(); !strings.HasPrefix(body, ": keepalive\n\n") || !recorder.Flushed {
+		t.Errorf("body = %q, flushed = %v", body, recorder.Flushed)
+	}
+}
+
+func TestSSEKeepaliveQuietWhileStreaming(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := &OpenAIWriter{Response: context.Response{ResponseWriter: recorder}, Cleaner: *NewCleaner(6), Stream: true}
+	writer.Keepalive = startSSEKeepalive(recorder, 40*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		if _, err := writer.Write([]byte("event: message\ndata: token\n\n")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	writer.Keepalive.Stop()
+
+	if strings.Contains(recorder.Body.String(), ": keepalive") {
+		t.Error("a keepalive was sent while tokens were streaming")
+	}
+}
+
+func TestOpenAIWriterErrorAfterKeepalive(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := &OpenAIWriter{Response: context.Response{ResponseWriter: recorder}, Stream: true}
+	if writer.Started() {
+		t.Fatal("Started() before anything was written")
+	}
+
+	writer.Keepalive = startSSEKeepalive(recorder, 10*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	writer.Keepalive.Stop()
+	if !writer.Started() {
+		t.Fatal("Started() = false after a keepalive")
+	}
+
+	if err := writer.WriteError("api_error", "timeout", "upstream timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if body := recorder.Body.String(); !strings.HasSuffix(body, `data: {"error":{"code":"timeout","message":"upstream timeout","type":"api_error"}}`+"\n\n") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSSEKeepaliveDisabled(t *testing.T) {
+	k := startSSEKeepalive(httptest.NewRecorder(), 0)
+	if k != nil {
+		t.Fatal("startSSEKeepalive() started with no interval")
+	}
+	k.lock()
+	k.unlock()
+	k.Stop()
+	if k.Sent() {
+		t.Error("a nil keepalive reported a keepalive sent")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// GetStorageRetentions
+// @Title GetStorageRetentions
+// @Tag StorageRetention API
+// @Description get the storage retention rules of an organization
+// @Param owner query string true "The owner (org) of the rules"
+// @Success 200 {array} object.StorageRetention The Response object
+// @router /get-storage-retentions [get]
+func (c *ApiController) GetStorageRetentions() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	rules, err := object.GetStorageRetentions(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(rules)
+}
+
+// AddStorageRetention
+// @Title AddStorageRetention
+// @Tag StorageRetention API
+// @Description add a storage retention rule for a store, or for a whole organization
+// @Param body body object.StorageRetention true "The details of the rule"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-storage-retention [post]
+func (c *ApiController) AddStorageRetention() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var rule object.StorageRetention
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddStorageRetention(&rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "storage-retention", rule.Owner, rule.GetId(), nil, &rule)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateStorageRetention
+// @Title UpdateStorageRetention
+// @Tag StorageRetention API
+// @Description update a storage retention rule, e.g. to put it on hold
+// @Param owner query string true "The owner (org)"
+// @Param store query string false "The store, empty for the org-wide rule"
+// @Param body body object.StorageRetention true "The details of the rule"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-storage-retention [post]
+func (c *ApiController) UpdateStorageRetention() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	store := c.Input().Get("store")
+
+	var rule object.StorageRetention
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetStorageRetention(owner, store)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateStorageRetention(owner, store, &rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "storage-retention", owner, rule.GetId(), before, &rule)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteStorageRetention
+// @Title DeleteStorageRetention
+// @Tag StorageRetention API
+// @Description delete a storage retention rule
+// @Param body body object.StorageRetention true "The details of the rule"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-storage-retention [post]
+func (c *ApiController) DeleteStorageRetention() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var rule object.StorageRetention
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetStorageRetention(rule.Owner, rule.Store)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteStorageRetention(&rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "storage-retention", rule.Owner, rule.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
+
+// SweepStorageRetention
+// @Title SweepStorageRetention
+// @Tag StorageRetention API
+// @Description enforce the storage retention rules of an organization now, instead of at the next scheduled sweep
+// @Param owner query string true "The owner (org)"
+// @Param dryRun query string false "1 to only report the files that would be deleted"
+// @Success 200 {object} object.RetentionSweepReport The Response object
+// @router /sweep-storage-retention [post]
+func (c *ApiController) SweepStorageRetention() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	if owner == "" {
+		c.ResponseError("owner is required")
+		return
+	}
+	dryRun := c.Input().Get("dryRun") == "1"
+
+	report, err := object.SweepStorageRetention(owner, dryRun)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if !dryRun {
+		c.recordAdminAudit("sweep", "storage-retention", owner, owner, nil, report)
+	}
+
+	c.ResponseOk(report)
+}
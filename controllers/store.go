@@ -127,8 +127,7 @@ func (c *ApiController) GetStore() {
 	}
 
 	if store != nil {
-		host := c.Ctx.Request.Host
-		origin := getOriginFromHost(host)
+		origin := c.getCloudUrl()
 		err = store.Populate(origin, c.GetAcceptLanguage())
 		if err != nil {
 			c.ResponseOk(store, err.Error())
@@ -0,0 +1,298 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Store limits. A chat completion or message request made for a store is
+// held to the store's limits, like the store's own chats: a user may send
+// at most Frequency requests every LimitMinutes, and only the last
+// MemoryLimit exchanges of the conversation are sent to the model (all of
+// them when MemoryLimit is 0). A request is made for a store when its
+// key's user is bound to one through Homepage, or when it names one:
+//
+//	{"model": "zen4", "messages": [...], "hanzo": {"store": "support"}}
+//
+// A user bound to a store cannot name another. Like tenant quotas, request
+// counts are shared through the cache backend when one is configured, and
+// kept per instance otherwise.
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
+)
+
+// limitedStoreCache caches the stores requests are made for by id. The
+// id may come from the request, so the cache is bounded and keeps misses
+// as briefly as stores.
+var limitedStoreCache = cache.NewLoading[*object.Store]("limited-store", cache.Options{
+	MaxEntries: 4096,
+	TTL:        60 * time.Second,
+})
+
+// getLimitedStore returns the store id names, cached.
+func getLimitedStore(id string) (*object.Store, error) {
+	return limitedStoreCache.Get(id, func() (*object.Store, error) {
+		return object.GetStore(id)
+	})
+}
+
+// resolveRequestStore returns the store a request is made for, or nil:
+// the store user is bound to, else the one the request names. A store
+// named without an owner belongs to "admin".
+func resolveRequestStore(user *iamsdk.User, requested string) (*object.Store, error) {
+	name := requested
+	if user != nil && user.Homepage != "" {
+		if requested != "" && requested != user.Homepage && requested != util.GetId("admin", user.Homepage) {
+			return nil, fmt.Errorf("You can only access data from your assigned store")
+		}
+		name = user.Homepage
+	}
+	if name == "" {
+		return nil, nil
+	}
+	if !strings.Contains(name, "/") {
+		name = util.GetId("admin", name)
+	}
+
+	store, err := getLimitedStore(name)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil && requested != "" {
+		return nil, fmt.Errorf("The store: %s is not found", name)
+	}
+	return store, nil
+}
+
+// storeRequestWindow counts the requests of each store user, per minute.
+type storeRequestWindow struct {
+	mu        sync.Mutex
+	counts    map[string]*storeRequestCounts // store/user → counts
+	lastSweep time.Time
+}
+
+type storeRequestCounts struct {
+	buckets map[int64]int // minute → requests
+	expires time.Time     // when the last request leaves its window
+}
+
+var storeRequests = &storeRequestWindow{counts: map[string]*storeRequestCounts{}}
+
+// admit counts a request of key if fewer than limit were made in the
+// window ending at now. Otherwise it returns how long until one of them
+// leaves the window.
+func (w *storeRequestWindow) admit(key string, limit int, window time.Duration, now time.Time) (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sweep(now)
+	minute := now.Unix() / 60
+	first := (now.Add(-window).Unix() + 59) / 60
+	counts := w.counts[key]
+	if counts == nil {
+		counts = &storeRequestCounts{buckets: map[int64]int{}}
+		w.counts[key] = counts
+	}
+	count := 0
+	oldest := minute
+	for bucket, n := range counts.buckets {
+		if bucket < first {
+			delete(counts.buckets, bucket)
+			continue
+		}
+		count += n
+		oldest = min(oldest, bucket)
+	}
+	if count >= limit {
+		return false, time.Unix(oldest*60, 0).Add(window).Sub(now)
+	}
+	counts.buckets[minute]++
+	counts.expires = now.Add(window)
+	return true, 0
+}
+
+// sweep drops, at most once a minute, the users whose requests all left
+// their window.
+func (w *storeRequestWindow) sweep(now time.Time) {
+	if now.Sub(w.lastSweep) < time.Minute {
+		return
+	}
+	w.lastSweep = now
+	for key, counts := range w.counts {
+		if !now.Before(counts.expires) {
+			delete(w.counts, key)
+		}
+	}
+}
+
+// sharedStoreRequestCache names the request buckets of store users the
+// replicas share through the cache backend, keyed "store/user|bucket".
+const sharedStoreRequestCache = "store-requests"
+
+// storeRequestBuckets is how many buckets a window is counted in across
+// replicas, so a long window costs a bounded read.
+const storeRequestBuckets = 60
+
+// admitShared is admit for the requests of key on every replica. A
+// request over the limit is uncounted again.
+func admitShared(key string, limit int, window time.Duration, now time.Time) (bool, time.Duration, error) {
+	size := max(int64(window/time.Minute+storeRequestBuckets-1)/storeRequestBuckets, 1) * 60
+	current := now.Unix() / size
+	first := (now.Add(-window).Unix() + size - 1) / size
+	total, err := cache.AddShared(sharedStoreRequestCache, fmt.Sprintf("%s|%d", key, current), 1, window+time.Duration(size)*time.Second)
+	if err != nil {
+		return false, 0, err
+	}
+	keys := []string{}
+	for bucket := first; bucket < current; bucket++ {
+		keys = append(keys, fmt.Sprintf("%s|%d", key, bucket))
+	}
+	values, err := cache.GetSharedInts(sharedStoreRequestCache, keys)
+	if err != nil {
+		return false, 0, err
+	}
+	oldest := current
+	for i, value := range values {
+		total += value
+		if value > 0 {
+			oldest = min(oldest, first+int64(i))
+		}
+	}
+	if total <= int64(limit) {
+		return true, 0, nil
+	}
+	if _, err := cache.AddShared(sharedStoreRequestCache, fmt.Sprintf("%s|%d", key, current), -1, window+time.Duration(size)*time.Second); err != nil {
+		logs.Warn("store limits: uncounting a rejected request of %s: %v", key, err)
+	}
+	return false, time.Unix(oldest*size, 0).Add(window).Sub(now), nil
+}
+
+// checkStoreFrequency admits a request of user against store's Frequency
+// limit, and returns the error of one over it with how long to wait.
+func checkStoreFrequency(store *object.Store, user string) (time.Duration, error) {
+	if store == nil || store.Frequency <= 0 || store.LimitMinutes <= 0 {
+		return 0, nil
+	}
+	window := time.Duration(store.LimitMinutes) * time.Minute
+	key, now := store.GetId()+"/"+user, time.Now()
+	var ok bool
+	var retryAfter time.Duration
+	var err error
+	if cache.Distributed() {
+		ok, retryAfter, err = admitShared(key, store.Frequency, window, now)
+		if err != nil {
+			logs.Warn("store limits: counting only this replica's requests of %s: %v", key, err)
+		}
+	}
+	if !cache.Distributed() || err != nil {
+		ok, retryAfter = storeRequests.admit(key, store.Frequency, window, now)
+	}
+	if ok {
+		return 0, nil
+	}
+	object.StoreLimitExceeded.WithLabelValues(store.GetId(), "frequency").Inc()
+	return retryAfter, fmt.Errorf("Store %s allows %d requests every %d minutes. Retry after %d seconds.",
+		store.Name, store.Frequency, store.LimitMinutes, int(retryAfter.Seconds())+1)
+}
+
+// keepStoreMemory returns which of the messages with roles to send within
+// store's MemoryLimit: the system messages, the last message and the
+// MemoryLimit exchanges before it, or all of them when MemoryLimit is 0.
+// The kept conversation starts with a user message.
+func keepStoreMemory(store *object.Store, roles []string) []bool {
+	keep := make([]bool, len(roles))
+	budget := len(roles)
+	if store != nil && store.MemoryLimit > 0 {
+		budget = 2*store.MemoryLimit + 1
+	}
+	first := len(roles)
+	for i := len(roles) - 1; i >= 0 && budget > 0; i-- {
+		if isSystemRole(roles[i]) {
+			continue
+		}
+		first = i
+		budget--
+	}
+	trimmed := false
+	for i := 0; i < first; i++ {
+		trimmed = trimmed || !isSystemRole(roles[i])
+	}
+	for trimmed && first < len(roles)-1 && roles[first] != openai.ChatMessageRoleUser {
+		first++
+	}
+
+	for i, role := range roles {
+		keep[i] = isSystemRole(role) || i >= first
+	}
+	if trimmed {
+		object.StoreLimitExceeded.WithLabelValues(store.GetId(), "memory").Inc()
+	}
+	return keep
+}
+
+func isSystemRole(role string) bool {
+	return role == openai.ChatMessageRoleSystem || role == openai.ChatMessageRoleDeveloper
+}
+
+// trimStoreMemory drops the messages beyond store's MemoryLimit.
+func trimStoreMemory(store *object.Store, messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if store == nil {
+		return messages
+	}
+	roles := make([]string, len(messages))
+	for i, message := range messages {
+		roles[i] = message.Role
+	}
+	kept := messages[:0:0]
+	for i, keep := range keepStoreMemory(store, roles) {
+		if keep {
+			kept = append(kept, messages[i])
+		}
+	}
+	return kept
+}
+
+// trimAnthropicStoreMemory is trimStoreMemory for Anthropic messages.
+func trimAnthropicStoreMemory(store *object.Store, messages []AnthropicMessage) []AnthropicMessage {
+	if store == nil {
+		return messages
+	}
+	roles := make([]string, len(messages))
+	for i, message := range messages {
+		roles[i] = message.Role
+	}
+	kept := messages[:0:0]
+	for i, keep := range keepStoreMemory(store, roles) {
+		if keep {
+			kept = append(kept, messages[i])
+		}
+	}
+	return kept
+}
+
+// storeLimitUser names the caller a store counts requests for.
+func (c *ApiController) storeLimitUser(user *iamsdk.User) string {
+	if user != nil {
+		return user.Owner + "/" + user.Name
+	}
+	return c.getClientIp()
+}
@@ -0,0 +1,139 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestStoreRequestWindow(t *testing.T) {
+	window := &storeRequestWindow{counts: map[string]*storeRequestCounts{}}
+	now := time.Date(2026, 3, 2, 12, 0, 30, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if ok, _ := window.admit("admin/support/acme/alice", 3, 15*time.Minute, now); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	ok, retryAfter := window.admit("admin/support/acme/alice", 3, 15*time.Minute, now)
+	if ok || retryAfter <= 0 || retryAfter > 15*time.Minute {
+		t.Errorf("fourth request: admitted %v, retry after %v", ok, retryAfter)
+	}
+	if ok, _ := window.admit("admin/support/acme/bob", 3, 15*time.Minute, now); !ok {
+		t.Error("another user was rejected")
+	}
+	if ok, _ := window.admit("admin/support/acme/alice", 3, 15*time.Minute, now.Add(16*time.Minute)); !ok {
+		t.Error("a request after the window was rejected")
+	}
+
+	// Users whose requests all left their window are dropped.
+	window.admit("admin/support/acme/carol", 3, 15*time.Minute, now.Add(40*time.Minute))
+	if len(window.counts) != 1 || window.counts["admin/support/acme/carol"] == nil {
+		t.Errorf("kept the requests of %d users, want only carol's", len(window.counts))
+	}
+}
+
+func TestAdmitShared(t *testing.T) {
+	cache.SetBackend(&counterBackend{counters: map[string]int64{}})
+	t.Cleanup(func() { cache.SetBackend(nil) })
+
+	// Two replicas admit requests of the same user against one limit.
+	now := time.Date(2026, 3, 2, 12, 0, 30, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if ok, _, err := admitShared("admin/support/acme/alice", 3, 15*time.Minute, now.Add(time.Duration(i)*time.Minute)); !ok || err != nil {
+			t.Fatalf("request %d: admitted %v, %v", i+1, ok, err)
+		}
+	}
+	ok, retryAfter, err := admitShared("admin/support/acme/alice", 3, 15*time.Minute, now.Add(3*time.Minute))
+	if ok || err != nil || retryAfter != 12*time.Minute-30*time.Second {
+		t.Errorf("fourth request: admitted %v, retry after %v, %v", ok, retryAfter, err)
+	}
+	if ok, _, _ := admitShared("admin/support/acme/alice", 3, 15*time.Minute, now.Add(15*time.Minute)); !ok {
+		t.Error("a request after the first left the window was rejected")
+	}
+
+	// A day long window is read in at most storeRequestBuckets buckets.
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := admitShared("admin/support/acme/bob", 2, 24*time.Hour, now.Add(time.Duration(i)*time.Hour)); !ok {
+			t.Fatalf("request %d of a day long window rejected", i+1)
+		}
+	}
+	if ok, _, _ := admitShared("admin/support/acme/bob", 2, 24*time.Hour, now.Add(23*time.Hour)); ok {
+		t.Error("a third request in a day long window was admitted")
+	}
+}
+
+func TestTrimStoreMemory(t *testing.T) {
+	message := func(role string, content string) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: role, Content: content}
+	}
+	messages := []openai.ChatCompletionMessage{
+		message(openai.ChatMessageRoleSystem, "You are helpful."),
+		message(openai.ChatMessageRoleUser, "q1"),
+		message(openai.ChatMessageRoleAssistant, "a1"),
+		message(openai.ChatMessageRoleUser, "q2"),
+		message(openai.ChatMessageRoleAssistant, "a2"),
+		message(openai.ChatMessageRoleUser, "q3"),
+	}
+	contents := func(messages []openai.ChatCompletionMessage) []string {
+		res := []string{}
+		for _, message := range messages {
+			res = append(res, message.Content)
+		}
+		return res
+	}
+
+	tests := []struct {
+		memoryLimit int
+		want        []string
+	}{
+		{0, []string{"You are helpful.", "q1", "a1", "q2", "a2", "q3"}},
+		{1, []string{"You are helpful.", "q2", "a2", "q3"}},
+		{5, []string{"You are helpful.", "q1", "a1", "q2", "a2", "q3"}},
+	}
+	for _, tt := range tests {
+		store := &object.Store{Owner: "admin", Name: "support", MemoryLimit: tt.memoryLimit}
+		if got := contents(trimStoreMemory(store, messages)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("memory limit %d: kept %v, want %v", tt.memoryLimit, got, tt.want)
+		}
+	}
+	if got := trimStoreMemory(nil, messages); len(got) != len(messages) {
+		t.Errorf("without a store: kept %d of %d messages", len(got), len(messages))
+	}
+
+	// A cut never leaves the conversation starting with an assistant turn.
+	odd := []AnthropicMessage{{Role: "user"}, {Role: "assistant"}, {Role: "assistant"}, {Role: "user"}}
+	store := &object.Store{Owner: "admin", Name: "support", MemoryLimit: 1}
+	if got := trimAnthropicStoreMemory(store, odd); len(got) != 1 || got[0].Role != "user" {
+		t.Errorf("kept %+v, want the last user message", got)
+	}
+}
+
+func TestResolveRequestStoreBinding(t *testing.T) {
+	user := &iamsdk.User{Owner: "acme", Name: "alice", Homepage: "support"}
+	if _, err := resolveRequestStore(user, "sales"); err == nil {
+		t.Error("a user bound to a store named another one")
+	}
+	store, err := resolveRequestStore(&iamsdk.User{Owner: "acme", Name: "bob"}, "")
+	if store != nil || err != nil {
+		t.Errorf("a request without a store resolved to %v, %v", store, err)
+	}
+}
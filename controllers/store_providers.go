@@ -0,0 +1,128 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Store model providers. A store answers its chats with its ModelProvider
+// and its ChildModelProviders, typically several keys of the same upstream.
+// New chats take them in turn, skipping the unhealthy ones, and a chat then
+// stays on the provider that first answered it. When a provider fails with
+// a retryable error before any of its answer is written, the next provider
+// answers instead.
+
+package controllers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+)
+
+// storeProviderRotation keeps the turn of each store's model providers.
+type storeProviderRotation struct {
+	mu   sync.Mutex
+	next map[string]int // store ID → index of the provider whose turn it is
+}
+
+var storeProviderTurns = &storeProviderRotation{next: map[string]int{}}
+
+// take returns the index of the provider whose turn it is among n, and
+// passes the turn on.
+func (r *storeProviderRotation) take(storeId string, n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.next[storeId] % n
+	r.next[storeId] = (i + 1) % n
+	return i
+}
+
+// storeModelProviders returns the distinct model providers of store: its
+// ModelProvider, then its ChildModelProviders.
+func storeModelProviders(store *object.Store) []string {
+	seen := map[string]bool{"": true}
+	names := []string{}
+	for _, name := range append([]string{store.ModelProvider}, store.ChildModelProviders...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// orderStoreModelProviders returns the model providers to try for a chat
+// of store, in order. The chat's own provider comes first; a chat without
+// one starts with the provider whose turn it is. Unhealthy providers are
+// tried last. A store without model providers leaves the choice to
+// GetModelProviderFromContext.
+func orderStoreModelProviders(store *object.Store, chatProvider string, healthy func(string) bool) []string {
+	names := storeModelProviders(store)
+	if len(names) == 0 {
+		return []string{chatProvider}
+	}
+
+	ordered := make([]string, 0, len(names)+1)
+	if chatProvider != "" {
+		ordered = append(ordered, chatProvider)
+		for _, name := range names {
+			if name != chatProvider {
+				ordered = append(ordered, name)
+			}
+		}
+	} else {
+		first := storeProviderTurns.take(store.GetId(), len(names))
+		ordered = append(ordered, names[first:]...)
+		ordered = append(ordered, names[:first]...)
+	}
+
+	res := make([]string, 0, len(ordered))
+	unhealthy := []string{}
+	for _, name := range ordered {
+		if healthy(name) {
+			res = append(res, name)
+		} else {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	return append(res, unhealthy...)
+}
+
+func isStoreProviderHealthy(name string) bool {
+	return providerHealth.isHealthy(name, time.Now())
+}
+
+// recordStoreProviderHealth notes the outcome of a store chat's call to the
+// named provider, unless the client went away.
+func recordStoreProviderHealth(name string, err error) {
+	if err != nil && strings.Contains(err.Error(), "write tcp") {
+		return
+	}
+	providerHealth.record(name, err)
+}
+
+// getStoreModelProvider returns the first of names that resolves to a model
+// provider, with the names left to fail over to.
+func getStoreModelProvider(names []string, lang string) (*object.Provider, model.ModelProvider, []string, error) {
+	var err error
+	for i, name := range names {
+		var provider *object.Provider
+		var providerObj model.ModelProvider
+		provider, providerObj, err = object.GetModelProviderFromContext("admin", name, lang)
+		if err == nil {
+			return provider, providerObj, names[i+1:], nil
+		}
+	}
+	return nil, nil, nil, err
+}
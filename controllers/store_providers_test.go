@@ -0,0 +1,58 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestOrderStoreModelProviders(t *testing.T) {
+	store := &object.Store{
+		Owner:               "admin",
+		Name:                "rotation-test",
+		ModelProvider:       "key-a",
+		ChildModelProviders: []string{"key-b", "key-a", "", "key-c"},
+	}
+	healthy := func(string) bool { return true }
+
+	// New chats take the providers in turn.
+	firsts := []string{}
+	for i := 0; i < 4; i++ {
+		firsts = append(firsts, orderStoreModelProviders(store, "", healthy)[0])
+	}
+	if want := []string{"key-a", "key-b", "key-c", "key-a"}; !reflect.DeepEqual(firsts, want) {
+		t.Errorf("first providers %v, want %v", firsts, want)
+	}
+
+	// A chat stays on its provider, and fails over to the others.
+	if got, want := orderStoreModelProviders(store, "key-c", healthy), []string{"key-c", "key-a", "key-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chat on key-c: %v, want %v", got, want)
+	}
+
+	// Unhealthy providers are tried last.
+	unhealthyC := func(name string) bool { return name != "key-c" }
+	if got, want := orderStoreModelProviders(store, "key-c", unhealthyC), []string{"key-a", "key-b", "key-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unhealthy key-c: %v, want %v", got, want)
+	}
+
+	// A store without model providers leaves the choice to the default.
+	bare := &object.Store{Owner: "admin", Name: "bare"}
+	if got := orderStoreModelProviders(bare, "", healthy); !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("store without providers: %v", got)
+	}
+}
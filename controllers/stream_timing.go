@@ -0,0 +1,85 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"math"
+	"time"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// minGenerationWindow is the shortest first-to-last token window used for a
+// throughput figure. Shorter windows mean the provider delivered the answer
+// in one burst, which says nothing about generation speed.
+const minGenerationWindow = 50 * time.Millisecond
+
+// streamTiming records when a response writer was created and when content
+// first and last arrived from the provider.
+type streamTiming struct {
+	StartTime      time.Time
+	FirstTokenTime time.Time
+	LastTokenTime  time.Time
+}
+
+func newStreamTiming(startTime time.Time) streamTiming {
+	return streamTiming{StartTime: startTime}
+}
+
+// markToken notes that a chunk of content arrived.
+func (t *streamTiming) markToken() {
+	now := time.Now()
+	if t.FirstTokenTime.IsZero() {
+		t.FirstTokenTime = now
+	}
+	t.LastTokenTime = now
+}
+
+// timeToFirstToken returns the TTFT, or false if no content arrived.
+func (t *streamTiming) timeToFirstToken() (time.Duration, bool) {
+	if t.StartTime.IsZero() || t.FirstTokenTime.IsZero() {
+		return 0, false
+	}
+	return t.FirstTokenTime.Sub(t.StartTime), true
+}
+
+// tokensPerSecond returns the generation throughput between the first and
+// last content chunks, or false if the window is too short to be meaningful.
+func (t *streamTiming) tokensPerSecond(completionTokens int) (float64, bool) {
+	window := t.LastTokenTime.Sub(t.FirstTokenTime)
+	if completionTokens <= 0 || window < minGenerationWindow {
+		return 0, false
+	}
+	return float64(completionTokens) / window.Seconds(), true
+}
+
+// apply copies TTFT and throughput of a streamed response onto its usage
+// record and exports them to Prometheus. Non-streaming responses are skipped,
+// since their first token is only observed once the whole answer is ready.
+func (t *streamTiming) apply(record *usageRecord) {
+	if !record.Stream {
+		return
+	}
+	labels := modelMetricLabels(record)[:2]
+
+	if ttft, ok := t.timeToFirstToken(); ok {
+		record.TtftMs = ttft.Milliseconds()
+		object.ModelTimeToFirstToken.WithLabelValues(labels...).Observe(ttft.Seconds())
+	}
+	if tps, ok := t.tokensPerSecond(record.CompletionTokens); ok {
+		record.TokensPerSecond = math.Round(tps*100) / 100
+		object.ModelTokensPerSecond.WithLabelValues(labels...).Observe(tps)
+	}
+}
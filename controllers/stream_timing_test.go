@@ -0,0 +1,56 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamTimingApply(t *testing.T) {
+	start := time.Now()
+	timing := streamTiming{
+		StartTime:      start,
+		FirstTokenTime: start.Add(400 * time.Millisecond),
+		LastTokenTime:  start.Add(2400 * time.Millisecond),
+	}
+
+	record := &usageRecord{Model: "gpt-4o", Provider: "do-ai", Stream: true, Status: "success", CompletionTokens: 100}
+	timing.apply(record)
+	if record.TtftMs != 400 {
+		t.Errorf("TtftMs = %d, want 400", record.TtftMs)
+	}
+	if record.TokensPerSecond != 50 {
+		t.Errorf("TokensPerSecond = %v, want 50", record.TokensPerSecond)
+	}
+
+	nonStream := &usageRecord{Model: "gpt-4o", Provider: "do-ai", Status: "success", CompletionTokens: 100}
+	timing.apply(nonStream)
+	if nonStream.TtftMs != 0 || nonStream.TokensPerSecond != 0 {
+		t.Errorf("non-streaming record should not get stream timings, got %d ms, %v tok/s", nonStream.TtftMs, nonStream.TokensPerSecond)
+	}
+}
+
+func TestStreamTimingSingleBurst(t *testing.T) {
+	timing := newStreamTiming(time.Now())
+	timing.markToken()
+
+	if _, ok := timing.timeToFirstToken(); !ok {
+		t.Error("timeToFirstToken should be set after the first token")
+	}
+	if _, ok := timing.tokensPerSecond(100); ok {
+		t.Error("tokensPerSecond should be skipped when all content arrives in one burst")
+	}
+}
@@ -15,6 +15,11 @@
 package controllers
 
 import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hanzoai/cloud/object"
 	"github.com/hanzoai/cloud/util"
 )
 
@@ -65,9 +70,40 @@ func (c *ApiController) GetVersionInfo() {
 // Health
 // @Title Health
 // @Tag System API
-// @Description check if the system is live
+// @Description check if the process is live. Also served at /healthz
 // @Success 200 {object} controllers.Response The Response object
 // @router /health [get]
 func (c *ApiController) Health() {
 	c.ResponseOk()
 }
+
+// Ready
+// @Title Ready
+// @Tag System API
+// @Description check if the system can serve traffic: database, Commerce, IAM, KMS and at least one healthy model provider. Returns 503 with per-dependency status when a dependency is down. Also served at /readyz
+// @Success 200 {object} controllers.Response The Response object
+// @router /ready [get]
+func (c *ApiController) Ready() {
+	checks := map[string]*object.DependencyHealth{
+		"database":  object.CheckDatabaseHealth(),
+		"commerce":  object.CheckCommerceHealth(),
+		"iam":       object.CheckIAMHealth(),
+		"kms":       object.CheckKMSHealth(),
+		"providers": checkModelProviderHealth(),
+	}
+
+	failed := []string{}
+	for name, health := range checks {
+		if health.Status == "error" {
+			failed = append(failed, name+": "+health.Error)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		c.Ctx.Output.SetStatus(http.StatusServiceUnavailable)
+		c.ResponseError(strings.Join(failed, "; "), checks)
+		return
+	}
+
+	c.ResponseOk(checks)
+}
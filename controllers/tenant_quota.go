@@ -0,0 +1,631 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Tenant quotas. Each organization can have a daily (24h) and a monthly
+// (30d) quota on requests, tokens and spend, stored in the tenant_quota
+// table. Usage is kept in a sliding window store with hourly buckets:
+// requests are counted when admitted, tokens and spend when the usage record
+// is written. With a distributed cache backend the buckets are shared, so
+// every replica enforces the organization's total; without one, or when it
+// fails, each replica enforces its own in-memory view.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	// quotaBucketSize is the granularity of the sliding windows.
+	quotaBucketSize = time.Hour
+
+	// quotaRetention is how long buckets are kept: the longest window.
+	quotaRetention = 30 * 24 * time.Hour
+)
+
+// quotaUsage is the usage of one organization in one bucket or window.
+type quotaUsage struct {
+	Requests        int64
+	Tokens          int64
+	SpendMicroCents int64
+}
+
+// tenantUsageStore is the sliding window store: per organization, usage
+// summed per hourly bucket.
+type tenantUsageStore struct {
+	mu   sync.Mutex
+	orgs map[string]map[int64]*quotaUsage // org → bucket start (unix) → usage
+}
+
+var tenantUsage = &tenantUsageStore{orgs: map[string]map[int64]*quotaUsage{}}
+
+func quotaBucket(t time.Time) int64 {
+	return t.Truncate(quotaBucketSize).Unix()
+}
+
+// addLocked adds delta to org's current bucket and prunes expired buckets.
+func (s *tenantUsageStore) addLocked(org string, delta quotaUsage, now time.Time) {
+	buckets := s.orgs[org]
+	if buckets == nil {
+		buckets = map[int64]*quotaUsage{}
+		s.orgs[org] = buckets
+	}
+	key := quotaBucket(now)
+	bucket := buckets[key]
+	if bucket == nil {
+		bucket = &quotaUsage{}
+		buckets[key] = bucket
+		oldest := quotaBucket(now.Add(-quotaRetention))
+		for start := range buckets {
+			if start < oldest {
+				delete(buckets, start)
+			}
+		}
+	}
+	bucket.Requests += delta.Requests
+	bucket.Tokens += delta.Tokens
+	bucket.SpendMicroCents += delta.SpendMicroCents
+}
+
+func (s *tenantUsageStore) add(org string, delta quotaUsage, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(org, delta, now)
+}
+
+// sumLocked returns org's usage over the window ending at now, and when the
+// oldest bucket holding usage leaves the window.
+func (s *tenantUsageStore) sumLocked(org string, window time.Duration, now time.Time) (quotaUsage, time.Time) {
+	var total quotaUsage
+	var slides time.Time
+	oldest := quotaBucket(now.Add(-window))
+	for start, bucket := range s.orgs[org] {
+		if start <= oldest {
+			continue
+		}
+		total.Requests += bucket.Requests
+		total.Tokens += bucket.Tokens
+		total.SpendMicroCents += bucket.SpendMicroCents
+		if leaves := time.Unix(start, 0).Add(window); slides.IsZero() || leaves.Before(slides) {
+			slides = leaves
+		}
+	}
+	return total, slides
+}
+
+// spendCrossing is a spend threshold of a quota that a request crossed.
+type spendCrossing struct {
+	Period     string
+	Percent    int64
+	SpendCents int64
+	LimitCents int64
+}
+
+// The hourly buckets the replicas share through the cache backend, keyed
+// "org|bucket start".
+const (
+	sharedRequestsCache = "quota-requests"
+	sharedTokensCache   = "quota-tokens"
+	sharedSpendCache    = "quota-spend"
+)
+
+// sharedQuotaKey is the key of org's shared bucket starting at start.
+func sharedQuotaKey(org string, start int64) string {
+	return fmt.Sprintf("%s|%d", org, start)
+}
+
+// addSharedUsage adds delta's requests and tokens to org's shared buckets at
+// now. Spend is added by addSharedSpend.
+func addSharedUsage(org string, delta quotaUsage, now time.Time) error {
+	key := sharedQuotaKey(org, quotaBucket(now))
+	for name, value := range map[string]int64{sharedRequestsCache: delta.Requests, sharedTokensCache: delta.Tokens} {
+		if value <= 0 {
+			continue
+		}
+		if _, err := cache.AddShared(name, key, value, quotaRetention+quotaBucketSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedWindowUsage is org's usage over a window from the shared buckets,
+// and when the oldest bucket holding usage leaves the window.
+type sharedWindowUsage struct {
+	usage  quotaUsage
+	slides time.Time
+}
+
+// getSharedUsage sums org's shared buckets over each of windows ending at
+// now.
+func getSharedUsage(org string, windows []time.Duration, now time.Time) (map[time.Duration]*sharedWindowUsage, error) {
+	var longest time.Duration
+	for _, window := range windows {
+		longest = max(longest, window)
+	}
+	step := int64(quotaBucketSize / time.Second)
+	starts := []int64{}
+	keys := []string{}
+	for start := quotaBucket(now.Add(-longest)) + step; start <= quotaBucket(now); start += step {
+		starts = append(starts, start)
+		keys = append(keys, sharedQuotaKey(org, start))
+	}
+
+	values := map[string][]int64{}
+	for _, name := range []string{sharedRequestsCache, sharedTokensCache, sharedSpendCache} {
+		ints, err := cache.GetSharedInts(name, keys)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = ints
+	}
+
+	sums := map[time.Duration]*sharedWindowUsage{}
+	for _, window := range windows {
+		sum := &sharedWindowUsage{}
+		oldest := quotaBucket(now.Add(-window))
+		for i, start := range starts {
+			if start <= oldest {
+				continue
+			}
+			bucket := quotaUsage{
+				Requests:        values[sharedRequestsCache][i],
+				Tokens:          values[sharedTokensCache][i],
+				SpendMicroCents: values[sharedSpendCache][i],
+			}
+			if bucket == (quotaUsage{}) {
+				continue
+			}
+			sum.usage.Requests += bucket.Requests
+			sum.usage.Tokens += bucket.Tokens
+			sum.usage.SpendMicroCents += bucket.SpendMicroCents
+			if sum.slides.IsZero() {
+				sum.slides = time.Unix(start, 0).Add(window)
+			}
+		}
+		sums[window] = sum
+	}
+	return sums, nil
+}
+
+// addSpend adds a finished request's delta to org's usage and returns the
+// spend thresholds, in percent of each quota's spend limit, that it
+// crossed. A threshold is crossed again only after the window slid usage
+// back below it. With a distributed cache backend the spend of every
+// replica counts; without one, or when it fails, this replica's.
+func (s *tenantUsageStore) addSpend(org string, delta quotaUsage, quotas []*object.TenantQuota, percents []int64, now time.Time) []spendCrossing {
+	windows := []time.Duration{}
+	for _, quota := range quotas {
+		if quota.MaxSpendCents > 0 {
+			windows = append(windows, quota.Window())
+		}
+	}
+	spend := s.addLocalSpend(org, delta, windows, now)
+	if cache.Distributed() {
+		if err := addSharedUsage(org, quotaUsage{Tokens: delta.Tokens}, now); err != nil {
+			logs.Warn("tenant quota: counting %s's tokens on this replica only: %v", org, err)
+		}
+	}
+	if delta.SpendMicroCents <= 0 {
+		return nil
+	}
+	if cache.Distributed() {
+		shared, err := addSharedSpend(org, delta.SpendMicroCents, windows, now)
+		if err != nil {
+			logs.Warn("tenant quota: counting only this replica's spend of %s: %v", org, err)
+		} else {
+			spend = shared
+		}
+	}
+
+	var crossings []spendCrossing
+	for _, quota := range quotas {
+		if quota.MaxSpendCents <= 0 {
+			continue
+		}
+		after := spend[quota.Window()]
+		before := after - delta.SpendMicroCents
+		for _, percent := range percents {
+			threshold := quota.MaxSpendCents * util.MicroCentsPerCent * percent / 100
+			if before < threshold && after >= threshold {
+				crossings = append(crossings, spendCrossing{
+					Period:     quota.Period,
+					Percent:    percent,
+					SpendCents: after / util.MicroCentsPerCent,
+					LimitCents: quota.MaxSpendCents,
+				})
+			}
+		}
+	}
+	return crossings
+}
+
+// addLocalSpend adds delta to org's usage and returns org's spend over each
+// of windows, delta included.
+func (s *tenantUsageStore) addLocalSpend(org string, delta quotaUsage, windows []time.Duration, now time.Time) map[time.Duration]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(org, delta, now)
+	spend := map[time.Duration]int64{}
+	for _, window := range windows {
+		usage, _ := s.sumLocked(org, window, now)
+		spend[window] = usage.SpendMicroCents
+	}
+	return spend
+}
+
+// addSharedSpend adds spend to org's shared bucket at now and returns org's
+// shared spend over each of windows. The current bucket's total is the one
+// the increment returned, so requests finishing together on different
+// replicas see distinct running totals and only one of them crosses a
+// threshold.
+func addSharedSpend(org string, spend int64, windows []time.Duration, now time.Time) (map[time.Duration]int64, error) {
+	current := quotaBucket(now)
+	total, err := cache.AddShared(sharedSpendCache, sharedQuotaKey(org, current), spend, quotaRetention+quotaBucketSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var longest time.Duration
+	for _, window := range windows {
+		longest = max(longest, window)
+	}
+	step := int64(quotaBucketSize / time.Second)
+	starts := []int64{}
+	keys := []string{}
+	for start := quotaBucket(now.Add(-longest)) + step; start < current; start += step {
+		starts = append(starts, start)
+		keys = append(keys, sharedQuotaKey(org, start))
+	}
+	values, err := cache.GetSharedInts(sharedSpendCache, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := map[time.Duration]int64{}
+	for _, window := range windows {
+		sum := total
+		oldest := quotaBucket(now.Add(-window))
+		for i, start := range starts {
+			if start > oldest {
+				sum += values[i]
+			}
+		}
+		sums[window] = sum
+	}
+	return sums, nil
+}
+
+// quotaDecision is the outcome of admitting a request against its
+// organization's quotas.
+type quotaDecision struct {
+	Headers    map[string]string
+	Exceeded   string        // e.g. "daily token quota"; empty when admitted
+	RetryAfter time.Duration // until the window frees usage, when exceeded
+}
+
+// quotaDimension is one limit of a quota.
+type quotaDimension struct {
+	name  string
+	limit int64
+	used  int64
+}
+
+// admit checks org's usage against quotas at now. An admitted request is
+// counted against the request quotas at once, so concurrent requests cannot
+// overshoot them. With a distributed cache backend the usage of every
+// replica counts; without one, or when it fails, this replica's.
+func (s *tenantUsageStore) admit(org string, quotas []*object.TenantQuota, now time.Time) *quotaDecision {
+	var shared map[time.Duration]*sharedWindowUsage
+	if cache.Distributed() && len(quotas) > 0 {
+		windows := make([]time.Duration, 0, len(quotas))
+		for _, quota := range quotas {
+			windows = append(windows, quota.Window())
+		}
+		var err error
+		if shared, err = getSharedUsage(org, windows, now); err != nil {
+			logs.Warn("tenant quota: admitting %s on this replica's usage only: %v", org, err)
+		}
+	}
+
+	decision := s.admitLocal(org, quotas, shared, now)
+	if decision.Exceeded == "" && shared != nil {
+		if err := addSharedUsage(org, quotaUsage{Requests: 1}, now); err != nil {
+			logs.Warn("tenant quota: counting %s's request on this replica only: %v", org, err)
+		}
+	}
+	return decision
+}
+
+// admitLocal checks org's usage against quotas, taking each window's usage
+// from shared when present, and counts an admitted request locally.
+func (s *tenantUsageStore) admitLocal(org string, quotas []*object.TenantQuota, shared map[time.Duration]*sharedWindowUsage, now time.Time) *quotaDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decision := &quotaDecision{Headers: map[string]string{}}
+	for _, quota := range quotas {
+		usage, slides := s.sumLocked(org, quota.Window(), now)
+		if window, ok := shared[quota.Window()]; ok {
+			usage, slides = window.usage, window.slides
+		}
+		period := quotaHeaderPeriod(quota.Period)
+		dimensions := []quotaDimension{
+			{"Requests", quota.MaxRequests, usage.Requests},
+			{"Tokens", quota.MaxTokens, usage.Tokens},
+			{"Spend-Cents", quota.MaxSpendCents, usage.SpendMicroCents / util.MicroCentsPerCent},
+		}
+		for _, d := range dimensions {
+			if d.limit <= 0 {
+				continue
+			}
+			remaining := d.limit - d.used
+			if remaining < 0 {
+				remaining = 0
+			}
+			decision.Headers[fmt.Sprintf("X-Quota-%s-%s-Limit", period, d.name)] = fmt.Sprintf("%d", d.limit)
+			decision.Headers[fmt.Sprintf("X-Quota-%s-%s-Remaining", period, d.name)] = fmt.Sprintf("%d", remaining)
+			if remaining == 0 && decision.Exceeded == "" {
+				decision.Exceeded = fmt.Sprintf("%s %s quota", quota.Period, strings.ToLower(strings.TrimSuffix(d.name, "-Cents")))
+				decision.RetryAfter = slides.Sub(now)
+			}
+		}
+	}
+
+	if decision.Exceeded == "" && len(quotas) > 0 {
+		s.addLocked(org, quotaUsage{Requests: 1}, now)
+	}
+	return decision
+}
+
+// quotaHeaderPeriod capitalizes a quota period for the X-Quota-* headers,
+// e.g. "daily" → "Daily".
+func quotaHeaderPeriod(period string) string {
+	if period == "" {
+		return ""
+	}
+	return strings.ToUpper(period[:1]) + period[1:]
+}
+
+// loadTenantQuotas is swapped out by tests.
+var loadTenantQuotas = object.GetActiveTenantQuotas
+
+// checkTenantQuota admits a request of org against its quotas. Orgs
+// without quotas, and lookups that fail, are always admitted.
+func checkTenantQuota(org string) *quotaDecision {
+	if org == "" {
+		return &quotaDecision{}
+	}
+	quotas, err := loadTenantQuotas(org)
+	if err != nil {
+		logs.Warn("tenant quota: lookup for %s failed: %v (admitting)", org, err)
+		return &quotaDecision{}
+	}
+	return tenantUsage.admit(org, quotas, time.Now())
+}
+
+// recordTenantQuotaUsage counts a finished request's tokens and spend
+// against its organization's quotas.
+func recordTenantQuotaUsage(record *usageRecord) {
+	org := usageOrganization(record)
+	if org == "" {
+		return
+	}
+	quotas, err := loadTenantQuotas(org)
+	if err != nil || len(quotas) == 0 {
+		return
+	}
+
+	delta := quotaUsage{Tokens: int64(record.PromptTokens + record.CompletionTokens)}
+	if record.Status == "success" {
+		delta.SpendMicroCents = calculateCostMicroCentsWithCache(
+			record.Model, record.PromptTokens, record.CompletionTokens,
+			record.CacheReadTokens, record.CacheWriteTokens,
+		)
+	}
+	for _, crossing := range tenantUsage.addSpend(org, delta, quotas, getSpendThresholdPercents(), time.Now()) {
+		sendSpendThresholdWebhook(org, crossing)
+	}
+}
+
+// quotaRetryAfterSeconds rounds a quota's retry delay up to whole seconds.
+func quotaRetryAfterSeconds(decision *quotaDecision) int {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// setQuotaHeaders writes the X-Quota-* headers of decision and, when a
+// quota is exhausted, Retry-After. It returns false when the request must be
+// rejected with 429.
+func (c *ApiController) setQuotaHeaders(decision *quotaDecision) bool {
+	header := c.Ctx.ResponseWriter.Header()
+	for name, value := range decision.Headers {
+		header.Set(name, value)
+	}
+	if decision.Exceeded == "" {
+		return true
+	}
+	header.Set("Retry-After", fmt.Sprintf("%d", quotaRetryAfterSeconds(decision)))
+	return false
+}
+
+// quotaExceededMessage is the error message of a rejected request.
+func quotaExceededMessage(decision *quotaDecision) string {
+	return fmt.Sprintf("Organization %s exceeded. Retry after %d seconds.", decision.Exceeded, quotaRetryAfterSeconds(decision))
+}
+
+// GetTenantQuotas
+// @Title GetTenantQuotas
+// @Tag TenantQuota API
+// @Description get the quotas of an organization with their current usage
+// @Param owner query string true "The owner (org) of the quotas"
+// @Success 200 {array} object.TenantQuota The Response object
+// @router /get-tenant-quotas [get]
+func (c *ApiController) GetTenantQuotas() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	quotas, err := object.GetTenantQuotas(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	type tenantQuotaStatus struct {
+		*object.TenantQuota
+		UsedRequests   int64 `json:"usedRequests"`
+		UsedTokens     int64 `json:"usedTokens"`
+		UsedSpendCents int64 `json:"usedSpendCents"`
+	}
+	statuses := []tenantQuotaStatus{}
+	now := time.Now()
+	tenantUsage.mu.Lock()
+	for _, quota := range quotas {
+		usage, _ := tenantUsage.sumLocked(owner, quota.Window(), now)
+		statuses = append(statuses, tenantQuotaStatus{
+			TenantQuota:    quota,
+			UsedRequests:   usage.Requests,
+			UsedTokens:     usage.Tokens,
+			UsedSpendCents: usage.SpendMicroCents / util.MicroCentsPerCent,
+		})
+	}
+	tenantUsage.mu.Unlock()
+
+	c.ResponseOk(statuses)
+}
+
+// AddTenantQuota
+// @Title AddTenantQuota
+// @Tag TenantQuota API
+// @Description add a daily or monthly quota for an organization
+// @Param body body object.TenantQuota true "The details of the quota"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-tenant-quota [post]
+func (c *ApiController) AddTenantQuota() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var quota object.TenantQuota
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddTenantQuota(&quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "tenant-quota", quota.Owner, quota.GetId(), nil, &quota)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateTenantQuota
+// @Title UpdateTenantQuota
+// @Tag TenantQuota API
+// @Description update an organization's quota
+// @Param owner query string true "The owner (org)"
+// @Param period query string true "daily or monthly"
+// @Param body body object.TenantQuota true "The details of the quota"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-tenant-quota [post]
+func (c *ApiController) UpdateTenantQuota() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	period := c.Input().Get("period")
+
+	var quota object.TenantQuota
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetTenantQuota(owner, period)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateTenantQuota(owner, period, &quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "tenant-quota", owner, quota.GetId(), before, &quota)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteTenantQuota
+// @Title DeleteTenantQuota
+// @Tag TenantQuota API
+// @Description delete an organization's quota
+// @Param body body object.TenantQuota true "The details of the quota"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-tenant-quota [post]
+func (c *ApiController) DeleteTenantQuota() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var quota object.TenantQuota
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetTenantQuota(quota.Owner, quota.Period)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteTenantQuota(&quota)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "tenant-quota", quota.Owner, quota.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+func TestTenantUsageStoreAdmit(t *testing.T) {
+	store := &tenantUsageStore{orgs: map[string]map[int64]*quotaUsage{}}
+	quotas := []*object.TenantQuota{
+		{Owner: "acme", Period: object.QuotaPeriodDaily, MaxRequests: 2, Enabled: true},
+		{Owner: "acme", Period: object.QuotaPeriodMonthly, MaxSpendCents: 100, Enabled: true},
+	}
+	start := time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if decision := store.admit("acme", quotas, start); decision.Exceeded != "" {
+			t.Fatalf("request %d: rejected with %s", i, decision.Exceeded)
+		}
+	}
+	decision := store.admit("acme", quotas, start)
+	if decision.Exceeded != "daily requests quota" {
+		t.Fatalf("third request: Exceeded = %q, want daily requests quota", decision.Exceeded)
+	}
+	if got := decision.Headers["X-Quota-Daily-Requests-Remaining"]; got != "0" {
+		t.Errorf("remaining = %q, want 0", got)
+	}
+	if want := 24*time.Hour - 30*time.Minute; decision.RetryAfter != want {
+		t.Errorf("RetryAfter = %v, want %v", decision.RetryAfter, want)
+	}
+
+	// The window slides: a day later the requests have expired, but the
+	// monthly spend still counts.
+	store.add("acme", quotaUsage{SpendMicroCents: 100 * util.MicroCentsPerCent}, start)
+	decision = store.admit("acme", quotas, start.Add(25*time.Hour))
+	if decision.Exceeded != "monthly spend quota" {
+		t.Fatalf("next day: Exceeded = %q, want monthly spend quota", decision.Exceeded)
+	}
+
+	if decision = store.admit("other", nil, start); decision.Exceeded != "" || len(decision.Headers) != 0 {
+		t.Errorf("org without quotas: got %+v", decision)
+	}
+}
+
+func TestTenantUsageStoreAdmitShared(t *testing.T) {
+	cache.SetBackend(&counterBackend{counters: map[string]int64{}})
+	t.Cleanup(func() { cache.SetBackend(nil) })
+
+	// Two replicas, each with its own store, admit requests for the same org.
+	replicas := []*tenantUsageStore{
+		{orgs: map[string]map[int64]*quotaUsage{}},
+		{orgs: map[string]map[int64]*quotaUsage{}},
+	}
+	quotas := []*object.TenantQuota{
+		{Owner: "shared-quota", Period: object.QuotaPeriodDaily, MaxRequests: 2, Enabled: true},
+		{Owner: "shared-quota", Period: object.QuotaPeriodMonthly, MaxTokens: 100, Enabled: true},
+	}
+	start := time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	for i, replica := range replicas {
+		if decision := replica.admit("shared-quota", quotas, start); decision.Exceeded != "" {
+			t.Fatalf("replica %d: rejected with %s", i, decision.Exceeded)
+		}
+	}
+	decision := replicas[0].admit("shared-quota", quotas, start)
+	if decision.Exceeded != "daily requests quota" {
+		t.Fatalf("third request: Exceeded = %q, want the daily requests quota both replicas used", decision.Exceeded)
+	}
+	if want := 24*time.Hour - 30*time.Minute; decision.RetryAfter != want {
+		t.Errorf("RetryAfter = %v, want %v", decision.RetryAfter, want)
+	}
+
+	// Tokens used on one replica count on the other the next day.
+	replicas[1].addSpend("shared-quota", quotaUsage{Tokens: 100}, quotas, nil, start)
+	decision = replicas[0].admit("shared-quota", quotas, start.Add(25*time.Hour))
+	if decision.Exceeded != "monthly tokens quota" {
+		t.Errorf("next day: Exceeded = %q, want monthly tokens quota", decision.Exceeded)
+	}
+}
+
+func TestQuotaHeaderPeriod(t *testing.T) {
+	for period, want := range map[string]string{"daily": "Daily", "monthly": "Monthly", "": ""} {
+		if got := quotaHeaderPeriod(period); got != want {
+			t.Errorf("quotaHeaderPeriod(%q) = %q, want %q", period, got, want)
+		}
+	}
+}
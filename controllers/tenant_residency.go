@@ -0,0 +1,244 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Data residency. An organization with a tenant_residency row is served
+// only by upstream providers whose Region lies in its region (see
+// object.GetProviderResidency). Routes keep their in-region upstreams, in
+// order. Residency fails closed: a route with none, a provider whose region
+// is not recognized and a failed lookup of the org's region are all
+// refused with a policy error. The org is the caller's own (see
+// getCallerOrg), so the policy holds without any client header.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/object"
+)
+
+// residencyPolicyError is returned for a premium route that cannot be
+// served inside the organization's residency region.
+type residencyPolicyError struct {
+	model  string
+	region string
+}
+
+func (e *residencyPolicyError) Error() string {
+	return fmt.Sprintf("data residency policy: model %q has no upstream in the %s region required for this organization", e.model, e.region)
+}
+
+// applyTenantResidency restricts route to the upstreams in org's residency
+// region. The returned route has residency set when it was restricted.
+// caller is the user the providers are looked up for.
+func applyTenantResidency(model string, route *modelRoute, org string, caller string) (*modelRoute, error) {
+	if route == nil {
+		return nil, nil
+	}
+	region, err := getTenantResidencyRegion(org)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		return route, nil
+	}
+
+	resident := []modelRouteFallback{}
+	for _, upstream := range route.upstreams() {
+		provider, err := object.GetModelProviderForOrg(org, upstream.providerName, caller)
+		if err == nil && provider != nil && object.GetProviderResidency(provider) == region {
+			resident = append(resident, upstream)
+		}
+	}
+
+	if len(resident) == 0 {
+		return nil, &residencyPolicyError{model: model, region: region}
+	}
+
+	restricted := route.withUpstreams(resident)
+	restricted.residency = region
+	return restricted, nil
+}
+
+// checkProviderResidency refuses provider, serving a request for model
+// without a route (e.g. direct provider key access), when it lies outside
+// org's residency region.
+func checkProviderResidency(model string, provider *object.Provider, org string) error {
+	region, err := getTenantResidencyRegion(org)
+	if err != nil {
+		return err
+	}
+	if region != "" && (provider == nil || object.GetProviderResidency(provider) != region) {
+		return &residencyPolicyError{model: model, region: region}
+	}
+	return nil
+}
+
+// getTenantResidencyRegion returns org's residency region; a failed lookup
+// is an error rather than no region.
+func getTenantResidencyRegion(org string) (string, error) {
+	region, err := object.GetTenantResidencyRegion(org)
+	if err != nil {
+		logs.Error("residency: lookup for %s failed: %v", org, err)
+		return "", fmt.Errorf("data residency policy: the residency region of %s could not be looked up", org)
+	}
+	return region, nil
+}
+
+// resolveResidentProvider returns the provider and upstream model a request
+// should use under org's residency: provider and upstreamModel unchanged
+// unless the route was restricted to other upstreams.
+func resolveResidentProvider(route *modelRoute, org string, caller string, provider *object.Provider, upstreamModel string) (*object.Provider, string, error) {
+	if route == nil || route.residency == "" || route.providerName == provider.Name {
+		return provider, upstreamModel, nil
+	}
+	resident, err := object.GetModelProviderForOrg(org, route.providerName, caller)
+	if err != nil {
+		return nil, "", err
+	}
+	if resident == nil {
+		return nil, "", fmt.Errorf("provider %q not configured in database", route.providerName)
+	}
+	return resident, route.upstreamModel, nil
+}
+
+// GetTenantResidencies
+// @Title GetTenantResidencies
+// @Tag TenantResidency API
+// @Description get the data residency regions of all organizations
+// @Success 200 {array} object.TenantResidency The Response object
+// @router /get-tenant-residencies [get]
+func (c *ApiController) GetTenantResidencies() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	residencies, err := object.GetTenantResidencies()
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(residencies)
+}
+
+// AddTenantResidency
+// @Title AddTenantResidency
+// @Tag TenantResidency API
+// @Description pin an organization to a data residency region
+// @Param body body object.TenantResidency true "The details of the residency"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-tenant-residency [post]
+func (c *ApiController) AddTenantResidency() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var residency object.TenantResidency
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddTenantResidency(&residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "tenant-residency", residency.Owner, residency.Owner, nil, &residency)
+	}
+
+	c.ResponseOk(success)
+}
+
+// UpdateTenantResidency
+// @Title UpdateTenantResidency
+// @Tag TenantResidency API
+// @Description change an organization's data residency region
+// @Param owner query string true "The owner (org)"
+// @Param body body object.TenantResidency true "The details of the residency"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-tenant-residency [post]
+func (c *ApiController) UpdateTenantResidency() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+
+	var residency object.TenantResidency
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetTenantResidency(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateTenantResidency(owner, &residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "tenant-residency", owner, owner, before, &residency)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteTenantResidency
+// @Title DeleteTenantResidency
+// @Tag TenantResidency API
+// @Description remove an organization's data residency region
+// @Param body body object.TenantResidency true "The details of the residency"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-tenant-residency [post]
+func (c *ApiController) DeleteTenantResidency() {
+	if !c.RequireAdmin() {
+		return
+	}
+
+	var residency object.TenantResidency
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetTenantResidency(residency.Owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteTenantResidency(&residency)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "tenant-residency", residency.Owner, residency.Owner, before, nil)
+	}
+
+	c.ResponseOk(success)
+}
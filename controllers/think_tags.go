@@ -0,0 +1,165 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Think tags. Some upstreams put a model's reasoning in the content of its
+// answer, between <think> and </think>; others stream it apart, as reason
+// events. How the chat completion and messages APIs pass the reasoning on
+// is set per model with think_tags:
+//
+//	passthrough  the content is sent as is (the default)
+//	strip        the reasoning is dropped
+//	reasoning    the reasoning is sent apart from the answer: as
+//	             reasoning_content by the chat completion API, and as
+//	             thinking blocks by the messages API
+//
+// A tag may be split across the chunks of a stream, so the end of a chunk
+// that may begin one is held back until the next chunk tells. Any other
+// angle bracket is sent unchanged. Chunks that are not events go through
+// the Cleaner only for models without a think_tags setting.
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	thinkTagsPassthrough = "passthrough"
+	thinkTagsStrip       = "strip"
+	thinkTagsReasoning   = "reasoning"
+
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// validateThinkTags checks a think_tags setting; empty means passthrough.
+func validateThinkTags(mode string) error {
+	switch mode {
+	case "", thinkTagsPassthrough, thinkTagsStrip, thinkTagsReasoning:
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s or %s", thinkTagsPassthrough, thinkTagsStrip, thinkTagsReasoning)
+}
+
+// getRouteThinkTags returns how route's think tags are handled, empty when
+// the route does not say.
+func getRouteThinkTags(route *modelRoute) string {
+	if route == nil {
+		return ""
+	}
+	return route.thinkTags
+}
+
+// thinkTagPart is a run of a streamed answer, either answer text or the
+// model's reasoning.
+type thinkTagPart struct {
+	text      string
+	reasoning bool
+}
+
+// thinkTagSplitter separates the reasoning from the answer of a stream.
+type thinkTagSplitter struct {
+	mode       string
+	configured bool   // The mode was set rather than defaulted
+	reasoning  bool   // Inside <think>
+	pending    string // End of the last chunk that may begin a tag
+}
+
+// newThinkTagSplitter returns the splitter of mode, passthrough when empty.
+func newThinkTagSplitter(mode string) thinkTagSplitter {
+	if mode == "" {
+		return thinkTagSplitter{mode: thinkTagsPassthrough}
+	}
+	return thinkTagSplitter{mode: mode, configured: true}
+}
+
+// split returns the parts of chunk that can be sent, in order. In strip
+// mode it leaves out the reasoning.
+func (s *thinkTagSplitter) split(chunk string) []thinkTagPart {
+	if s.mode != thinkTagsStrip && s.mode != thinkTagsReasoning {
+		return s.parts(chunk)
+	}
+
+	parts := []thinkTagPart{}
+	text := s.pending + chunk
+	s.pending = ""
+	for text != "" {
+		tag := thinkOpenTag
+		if s.reasoning {
+			tag = thinkCloseTag
+		}
+		if i := strings.Index(text, tag); i >= 0 {
+			parts = append(parts, s.parts(text[:i])...)
+			text = text[i+len(tag):]
+			s.reasoning = !s.reasoning
+			continue
+		}
+		held := partialTagLength(text, tag)
+		parts = append(parts, s.parts(text[:len(text)-held])...)
+		s.pending = text[len(text)-held:]
+		break
+	}
+	return parts
+}
+
+// raw returns the parts of a chunk that is not an event. Without a
+// configured mode it is cleaned by cleaner, as it always was; with one it
+// is split like an event, untouched.
+func (s *thinkTagSplitter) raw(chunk string, cleaner *Cleaner) []thinkTagPart {
+	if !s.configured {
+		return s.parts(cleaner.CleanString(chunk))
+	}
+	return s.split(chunk)
+}
+
+// flush returns what is held back once the stream has ended.
+func (s *thinkTagSplitter) flush() []thinkTagPart {
+	text := s.pending
+	s.pending = ""
+	return s.parts(text)
+}
+
+// reason returns the parts of a reason event, which upstreams stream apart
+// from the answer. Passthrough sends them as answer text, as before.
+func (s *thinkTagSplitter) reason(text string) []thinkTagPart {
+	switch s.mode {
+	case thinkTagsStrip:
+		return nil
+	case thinkTagsReasoning:
+		if text == "" {
+			return nil
+		}
+		return []thinkTagPart{{text: text, reasoning: true}}
+	}
+	return []thinkTagPart{{text: text}}
+}
+
+func (s *thinkTagSplitter) parts(text string) []thinkTagPart {
+	if text == "" || (s.reasoning && s.mode == thinkTagsStrip) {
+		return nil
+	}
+	return []thinkTagPart{{text: text, reasoning: s.reasoning}}
+}
+
+// partialTagLength returns the length of the longest end of text that
+// begins tag.
+func partialTagLength(text string, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beego/beego/context"
+)
+
+// splitThinkTags feeds chunks to a splitter of mode and returns the answer
+// and the reasoning it lets through.
+func splitThinkTags(mode string, chunks ...string) (string, string) {
+	splitter := newThinkTagSplitter(mode)
+	var answer, reasoning strings.Builder
+	collect := func(parts []thinkTagPart) {
+		for _, part := range parts {
+			if part.reasoning {
+				reasoning.WriteString(part.text)
+			} else {
+				answer.WriteString(part.text)
+			}
+		}
+	}
+	for _, chunk := range chunks {
+		collect(splitter.split(chunk))
+	}
+	collect(splitter.flush())
+	return answer.String(), reasoning.String()
+}
+
+func TestThinkTagSplitter(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		chunks        []string
+		wantAnswer    string
+		wantReasoning string
+	}{
+		{"passthrough", thinkTagsPassthrough, []string{"<think>hm</think>", "Hi"}, "<think>hm</think>Hi", ""},
+		{"strip", thinkTagsStrip, []string{"<think>hm</think>Hi"}, "Hi", ""},
+		{"reasoning", thinkTagsReasoning, []string{"<think>hm</think>Hi"}, "Hi", "hm"},
+		{"tags split across chunks", thinkTagsReasoning, []string{"<th", "ink>h", "m</", "thi", "nk", ">Hi"}, "Hi", "hm"},
+		{"one byte per chunk", thinkTagsReasoning, strings.Split("<think>hm</think>Hi", ""), "Hi", "hm"},
+		{"angle brackets kept", thinkTagsStrip, []string{"if a <", "b and <div> or <thin", "g>"}, "if a <b and <div> or <thing>", ""},
+		{"pending tag start flushed", thinkTagsStrip, []string{"x <th"}, "x <th", ""},
+		{"unclosed reasoning", thinkTagsReasoning, []string{"<think>still thinking</th"}, "", "still thinking</th"},
+		{"strip drops unclosed reasoning", thinkTagsStrip, []string{"A<think>b"}, "A", ""},
+		{"several think blocks", thinkTagsReasoning, []string{"<think>a</think>B<think>c</think>D"}, "BD", "ac"},
+	}
+	for _, tt := range tests {
+		answer, reasoning := splitThinkTags(tt.mode, tt.chunks...)
+		if answer != tt.wantAnswer || reasoning != tt.wantReasoning {
+			t.Errorf("%s: answer %q, reasoning %q; want %q, %q", tt.name, answer, reasoning, tt.wantAnswer, tt.wantReasoning)
+		}
+	}
+}
+
+func TestValidateThinkTags(t *testing.T) {
+	for _, mode := range []string{"", thinkTagsPassthrough, thinkTagsStrip, thinkTagsReasoning} {
+		if err := validateThinkTags(mode); err != nil {
+			t.Errorf("%q: %v", mode, err)
+		}
+	}
+	if validateThinkTags("hide") == nil {
+		t.Error("an unknown mode was accepted")
+	}
+}
+
+func TestOpenAIWriterThinkTags(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := &OpenAIWriter{
+		Response:  context.Response{ResponseWriter: recorder},
+		Cleaner:   *NewCleaner(6),
+		ThinkTags: newThinkTagSplitter(thinkTagsReasoning),
+		Stream:    true,
+	}
+	for _, chunk := range []string{"<think>2+2</thi", "nk>4 <", "= 5"} {
+		_, _ = fmt.Fprintf(writer, "event: message\ndata: %s\n\n", chunk)
+	}
+	_, _ = fmt.Fprintf(writer, "event: reason\ndata: %s\n\n", "checked")
+	if err := writer.FlushThinkTags(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := writer.MessageString(); got != "4 <= 5" {
+		t.Errorf("message %q", got)
+	}
+	if got := writer.ReasoningString(); got != "2+2checked" {
+		t.Errorf("reasoning %q", got)
+	}
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"reasoning_content":"2+2"`) || strings.Contains(body, "think>") {
+		t.Errorf("stream %s", body)
+	}
+}
+
+func TestAnthropicWriterThinkingBlocks(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer := &AnthropicWriter{
+		Response:  context.Response{ResponseWriter: recorder},
+		Cleaner:   *NewCleaner(6),
+		ThinkTags: newThinkTagSplitter(thinkTagsReasoning),
+		Stream:    true,
+	}
+	_, _ = fmt.Fprintf(writer, "event: message\ndata: %s\n\n", "<think>hm</think>Hi")
+	_ = writer.FlushThinkTags()
+	if err := writer.Close(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`"content_block":{"signature":"","thinking":"","type":"thinking"},"index":0`,
+		`"delta":{"thinking":"hm","type":"thinking_delta"},"index":0`,
+		`"delta":{"signature":"","type":"signature_delta"},"index":0`,
+		`{"index":0,"type":"content_block_stop"}`,
+		`"content_block":{"text":"","type":"text"},"index":1`,
+		`"delta":{"text":"Hi","type":"text_delta"},"index":1`,
+		`{"index":1,"type":"content_block_stop"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream lacks %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestThinkTagSplitterRaw(t *testing.T) {
+	chunk := "<think>why?</think>a -> b?"
+	cleaner := NewCleaner(6)
+
+	unset := newThinkTagSplitter(getRouteThinkTags(nil))
+	if got := joinThinkTagParts(unset.raw(chunk, cleaner)); got != cleaner.CleanString(chunk) {
+		t.Errorf("raw() without a mode = %q, want the cleaned chunk", got)
+	}
+
+	passthrough := newThinkTagSplitter(thinkTagsPassthrough)
+	if got := joinThinkTagParts(passthrough.raw(chunk, cleaner)); got != chunk {
+		t.Errorf("raw() in passthrough mode = %q, want %q", got, chunk)
+	}
+
+	strip := newThinkTagSplitter(thinkTagsStrip)
+	if got := joinThinkTagParts(strip.raw(chunk, cleaner)); got != "a -> b?" {
+		t.Errorf("raw() in strip mode = %q, want %q", got, "a -> b?")
+	}
+}
+
+func joinThinkTagParts(parts []thinkTagPart) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.text)
+	}
+	return b.String()
+}
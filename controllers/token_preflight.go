@@ -0,0 +1,131 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/beego/beego/logs"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// promptEstimateKey is the context data key of a chat request's pre-flight
+// prompt token count.
+const promptEstimateKey = "promptEstimate"
+
+// maxTokensClampedHeader reports the max_tokens a chat request was sent
+// with after the gateway lowered it to fit the model.
+const maxTokensClampedHeader = "X-Max-Tokens-Clamped"
+
+// promptEstimate is the gateway's own count of a chat request's prompt
+// tokens, made before the request is sent. Only models whose tokenizer is
+// known (see model.GetTokenizerModel) are counted; Known is false for the
+// rest.
+type promptEstimate struct {
+	Tokens    int
+	Known     bool
+	Tokenizer string
+}
+
+// estimatePromptTokens counts the prompt tokens of request, tool
+// definitions included, with the tokenizer of upstreamModel.
+func estimatePromptTokens(request *openai.ChatCompletionRequest, upstreamModel string) promptEstimate {
+	tokenizerModel, ok := model.GetTokenizerModel(upstreamModel)
+	if !ok {
+		return promptEstimate{}
+	}
+
+	tokens, err := model.OpenaiNumTokensFromMessages(request.Messages, tokenizerModel)
+	if err != nil {
+		logs.Warn("token preflight: count prompt of %s: %v", upstreamModel, err)
+		return promptEstimate{}
+	}
+	if len(request.Tools) > 0 {
+		if definitions, err := json.Marshal(request.Tools); err == nil {
+			if n, err := model.GetTokenSize(tokenizerModel, string(definitions)); err == nil {
+				tokens += n
+			}
+		}
+	}
+	return promptEstimate{Tokens: tokens, Known: true, Tokenizer: tokenizerModel}
+}
+
+// fitMaxTokens lowers max_tokens and max_completion_tokens to the output
+// limit of route and, when the prompt was counted with the model's own
+// tokenizer, to what the prompt leaves of the context window. A prompt
+// that fills the window is left for the upstream to refuse. It returns the
+// limit applied, or 0 when request was left alone.
+func fitMaxTokens(request *openai.ChatCompletionRequest, route *modelRoute, estimate promptEstimate) int {
+	if route == nil {
+		return 0
+	}
+
+	limit := route.maxOutputTokens
+	if route.contextWindow > 0 && estimate.Known {
+		if left := route.contextWindow - estimate.Tokens; left > 0 && (limit == 0 || left < limit) {
+			limit = left
+		}
+	}
+	if limit == 0 || (request.MaxTokens <= limit && request.MaxCompletionTokens <= limit) {
+		return 0
+	}
+
+	if request.MaxTokens > limit {
+		request.MaxTokens = limit
+	}
+	if request.MaxCompletionTokens > limit {
+		request.MaxCompletionTokens = limit
+	}
+	return limit
+}
+
+// preflightChatTokens counts the prompt of a chat request, keeps the count
+// for its usage record and fits max_tokens to the model, telling the
+// caller when it was lowered.
+func (c *ApiController) preflightChatTokens(request *openai.ChatCompletionRequest, upstreamModel string, route *modelRoute) {
+	estimate := estimatePromptTokens(request, upstreamModel)
+	c.Ctx.Input.SetData(promptEstimateKey, estimate)
+
+	if limit := fitMaxTokens(request, route, estimate); limit > 0 {
+		logs.Warn("token preflight: max_tokens of %s request lowered to %d (prompt %d tokens)", upstreamModel, limit, estimate.Tokens)
+		c.Ctx.ResponseWriter.Header().Set(maxTokensClampedHeader, strconv.Itoa(limit))
+	}
+}
+
+// applyPromptEstimate copies the request's pre-flight prompt count onto its
+// usage record, when there is one.
+func (c *ApiController) applyPromptEstimate(record *usageRecord) {
+	estimate, ok := c.Ctx.Input.GetData(promptEstimateKey).(promptEstimate)
+	if !ok || !estimate.Known {
+		return
+	}
+	record.EstimatedPromptTokens = estimate.Tokens
+	record.Tokenizer = estimate.Tokenizer
+}
+
+// observePromptTokenDrift exports how far the prompt tokens the upstream
+// reported are from the gateway's own count.
+func observePromptTokenDrift(record *usageRecord) {
+	if record.Status != "success" || record.EstimatedPromptTokens <= 0 || record.PromptTokens <= 0 {
+		return
+	}
+	labels := modelMetricLabels(record)[:2]
+	ratio := float64(record.PromptTokens) / float64(record.EstimatedPromptTokens)
+	object.ModelPromptTokenDrift.WithLabelValues(labels[0], labels[1], record.Tokenizer).Observe(ratio)
+}
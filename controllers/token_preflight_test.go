@@ -0,0 +1,99 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestFitMaxTokens(t *testing.T) {
+	route := &modelRoute{contextWindow: 1000, maxOutputTokens: 300}
+	known := func(tokens int) promptEstimate { return promptEstimate{Tokens: tokens, Known: true} }
+
+	tests := []struct {
+		name          string
+		route         *modelRoute
+		estimate      promptEstimate
+		maxTokens     int
+		wantMaxTokens int
+		wantLimit     int
+	}{
+		{"fits", route, known(100), 200, 200, 0},
+		{"output limit", route, known(100), 500, 300, 300},
+		{"rest of window", route, known(800), 500, 200, 200},
+		{"unset stays unset", route, known(800), 0, 0, 0},
+		{"window exceeded is left to the upstream", route, known(1000), 500, 300, 300},
+		{"unknown tokenizer not held against window", route, promptEstimate{Tokens: 900}, 500, 300, 300},
+		{"no route", nil, known(5000), 500, 500, 0},
+		{"no limits", &modelRoute{}, known(5000), 500, 500, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &openai.ChatCompletionRequest{MaxTokens: tt.maxTokens, MaxCompletionTokens: tt.maxTokens}
+			if limit := fitMaxTokens(request, tt.route, tt.estimate); limit != tt.wantLimit {
+				t.Errorf("fitMaxTokens() = %d, want %d", limit, tt.wantLimit)
+			}
+			if request.MaxTokens != tt.wantMaxTokens || request.MaxCompletionTokens != tt.wantMaxTokens {
+				t.Errorf("max tokens = %d/%d, want %d", request.MaxTokens, request.MaxCompletionTokens, tt.wantMaxTokens)
+			}
+		})
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	request := &openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "What is the weather in Paris?"}},
+	}
+	if unknown := estimatePromptTokens(request, "accounts/fireworks/models/glm-5"); unknown.Known {
+		t.Fatalf("estimate = %+v, want no count for a model without a known tokenizer", unknown)
+	}
+
+	plain := estimatePromptTokens(request, "accounts/fireworks/models/gpt-oss-120b")
+	if !plain.Known || plain.Tokenizer != openai.GPT4o || plain.Tokens <= 0 {
+		t.Fatalf("estimate = %+v, want a positive %s count", plain, openai.GPT4o)
+	}
+
+	request.Tools = []openai.Tool{{
+		Type:     openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{Name: "get_weather", Description: "Current weather of a city"},
+	}}
+	if withTools := estimatePromptTokens(request, "accounts/fireworks/models/gpt-oss-120b"); withTools.Tokens <= plain.Tokens {
+		t.Errorf("tool definitions were not counted: %d <= %d", withTools.Tokens, plain.Tokens)
+	}
+}
+
+func TestObservePromptTokenDrift(t *testing.T) {
+	series := func() int { return testutil.CollectAndCount(object.ModelPromptTokenDrift) }
+	before := series()
+
+	observePromptTokenDrift(&usageRecord{Model: "drift-test", Provider: "p", Status: "success", PromptTokens: 110})
+	observePromptTokenDrift(&usageRecord{Model: "drift-test", Provider: "p", Status: "error", PromptTokens: 110, EstimatedPromptTokens: 100})
+	if got := series(); got != before {
+		t.Fatalf("drift observed without a count to compare: %d series, want %d", got, before)
+	}
+
+	observePromptTokenDrift(&usageRecord{
+		Model: "drift-test", Provider: "p", Status: "success",
+		PromptTokens: 110, EstimatedPromptTokens: 100, Tokenizer: "gpt-4o",
+	})
+	if got := series(); got != before+1 {
+		t.Errorf("drift series = %d, want %d", got, before+1)
+	}
+}
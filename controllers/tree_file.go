@@ -17,8 +17,17 @@ package controllers
 import (
 	"encoding/json"
 	"mime/multipart"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	defaultPresignedUrlExpiry = 15 * time.Minute
+	maxPresignedUrlExpiry     = 7 * 24 * time.Hour
 )
 
 // UpdateTreeFile
@@ -79,28 +88,33 @@ func (c *ApiController) AddTreeFile() {
 	isLeaf := c.Input().Get("isLeaf") == "1"
 	filename := c.Input().Get("filename")
 	var file multipart.File
+	var size int64
 
 	if isLeaf {
+		var header *multipart.FileHeader
 		var err error
-		file, _, err = c.GetFile("file")
+		file, header, err = c.GetFile("file")
 		if err != nil {
 			c.ResponseError(err.Error())
 			return
 		}
 		defer file.Close()
+		size = header.Size
 	}
 
-	res, bs, err := object.AddTreeFile(storeId, userName, key, isLeaf, filename, file, c.GetAcceptLanguage())
+	res, err := object.AddTreeFile(storeId, userName, key, isLeaf, filename, file, size, c.GetAcceptLanguage())
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
 	}
 
 	if res {
-		err = addFileToCache(key, filename, bs)
-		if err != nil {
-			c.ResponseError(err.Error())
-			return
+		if isLeaf && getCachePrefix(filename) != "" {
+			err = addUploadedFileToCache(key, filename, file)
+			if err != nil {
+				c.ResponseError(err.Error())
+				return
+			}
 		}
 
 		err = addRecordForFile(c, userName, "Add", storeId, key, filename, isLeaf, c.GetAcceptLanguage())
@@ -148,3 +162,92 @@ func (c *ApiController) DeleteTreeFile() {
 
 	c.ResponseOk(res)
 }
+
+// GetPresignedUrl
+// @Title GetPresignedUrl
+// @Tag Tree File API
+// @Description get a presigned URL to download (GET) or upload (PUT) a tree file directly from the store's storage. Uploads are added to the store by complete-presigned-upload
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file"
+// @Param method query string false "GET or PUT, defaults to GET"
+// @Param size query string false "The size in bytes of the file to upload, required for PUT"
+// @Param expires query string false "The lifetime of the URL in seconds, defaults to 900"
+// @Success 200 {object} controllers.Response The Response object
+// @router /get-presigned-url [get]
+func (c *ApiController) GetPresignedUrl() {
+	_, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	method := strings.ToUpper(c.Input().Get("method"))
+	if method == "" {
+		method = "GET"
+	}
+	expires := defaultPresignedUrlExpiry
+	if value := c.Input().Get("expires"); value != "" {
+		seconds, err := util.ParseIntWithError(value)
+		if err != nil || seconds <= 0 {
+			c.ResponseError("expires should be a positive number of seconds")
+			return
+		}
+		expires = time.Duration(seconds) * time.Second
+	}
+	if expires > maxPresignedUrlExpiry {
+		expires = maxPresignedUrlExpiry
+	}
+	var size int64
+	if value := c.Input().Get("size"); value != "" {
+		parsed, err := util.ParseIntWithError(value)
+		if err != nil {
+			c.ResponseError("size should be a number of bytes")
+			return
+		}
+		size = int64(parsed)
+	}
+
+	url, err := object.GetPresignedTreeFileUrl(storeId, key, method, size, expires, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(url)
+}
+
+// CompletePresignedUpload
+// @Title CompletePresignedUpload
+// @Tag Tree File API
+// @Description add a file uploaded through a presigned PUT URL to the store
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file"
+// @Success 200 {object} controllers.Response The Response object
+// @router /complete-presigned-upload [post]
+func (c *ApiController) CompletePresignedUpload() {
+	userName, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+
+	res, err := object.CompletePresignedTreeFileUpload(storeId, key, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	if res {
+		folder, filename := path.Split(strings.TrimLeft(key, "/"))
+		err = addRecordForFile(c, userName, "Add", storeId, path.Clean("/" + folder)[1:], filename, true, c.GetAcceptLanguage())
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	c.ResponseOk(res)
+}
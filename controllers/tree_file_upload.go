@@ -0,0 +1,184 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"path"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+// maxUploadParts is the most parts an upload may have, as in S3.
+const maxUploadParts = 10000
+
+// CreateTreeFileUpload
+// @Title CreateTreeFileUpload
+// @Tag Tree File API
+// @Description start a resumable upload of a large tree file, sent in parts
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the folder of the file"
+// @Param filename query string true "The name of the file"
+// @Success 200 {object} controllers.Response The Response object
+// @router /create-tree-file-upload [post]
+func (c *ApiController) CreateTreeFileUpload() {
+	_, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	filename := c.Input().Get("filename")
+
+	objectKey, uploadId, err := object.CreateTreeFileUpload(storeId, key, filename, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(map[string]string{"key": objectKey, "uploadId": uploadId})
+}
+
+// UploadTreeFilePart
+// @Title UploadTreeFilePart
+// @Tag Tree File API
+// @Description upload a part of a resumable upload as the raw request body. All parts but the last must be at least 5 MB
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file, as returned when the upload was created"
+// @Param uploadId query string true "The id of the upload"
+// @Param partNumber query string true "The number of the part, from 1 to 10000"
+// @Success 200 {object} controllers.Response The Response object
+// @router /upload-tree-file-part [post]
+func (c *ApiController) UploadTreeFilePart() {
+	_, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	uploadId := c.Input().Get("uploadId")
+	partNumber, err := util.ParseIntWithError(c.Input().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxUploadParts {
+		c.ResponseError("partNumber should be a number from 1 to 10000")
+		return
+	}
+
+	etag, err := object.UploadTreeFilePart(storeId, key, uploadId, int32(partNumber), c.requestBodyReader(), c.Ctx.Request.ContentLength, c.GetAcceptLanguage())
+	if err != nil {
+		if message := requestBodyTooLarge(err); message != "" {
+			c.ResponseError(message)
+			return
+		}
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(etag)
+}
+
+// GetTreeFileUploadParts
+// @Title GetTreeFileUploadParts
+// @Tag Tree File API
+// @Description get the parts of a resumable upload stored so far, to resume it
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file"
+// @Param uploadId query string true "The id of the upload"
+// @Success 200 {array} storage.Part The Response object
+// @router /get-tree-file-upload-parts [get]
+func (c *ApiController) GetTreeFileUploadParts() {
+	_, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	uploadId := c.Input().Get("uploadId")
+
+	parts, err := object.GetTreeFileUploadParts(storeId, key, uploadId, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(parts)
+}
+
+// CompleteTreeFileUpload
+// @Title CompleteTreeFileUpload
+// @Tag Tree File API
+// @Description assemble the uploaded parts into the tree file
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file"
+// @Param uploadId query string true "The id of the upload"
+// @Success 200 {object} controllers.Response The Response object
+// @router /complete-tree-file-upload [post]
+func (c *ApiController) CompleteTreeFileUpload() {
+	userName, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	uploadId := c.Input().Get("uploadId")
+
+	res, err := object.CompleteTreeFileUpload(storeId, key, uploadId, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	if res {
+		folder, filename := path.Split(key)
+		err = addRecordForFile(c, userName, "Add", storeId, path.Clean("/" + folder)[1:], filename, true, c.GetAcceptLanguage())
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	c.ResponseOk(res)
+}
+
+// AbortTreeFileUpload
+// @Title AbortTreeFileUpload
+// @Tag Tree File API
+// @Description discard a resumable upload and its parts
+// @Param store query string true "The store of the file"
+// @Param key query string true "The key of the file"
+// @Param uploadId query string true "The id of the upload"
+// @Success 200 {object} controllers.Response The Response object
+// @router /abort-tree-file-upload [post]
+func (c *ApiController) AbortTreeFileUpload() {
+	_, ok := c.RequireSignedIn()
+	if !ok {
+		return
+	}
+
+	storeId := c.Input().Get("store")
+	key := c.Input().Get("key")
+	uploadId := c.Input().Get("uploadId")
+
+	err := object.AbortTreeFileUpload(storeId, key, uploadId, c.GetAcceptLanguage())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(true)
+}
@@ -0,0 +1,227 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/proxy"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// Upstream error classes, recorded in usage records and metrics.
+const (
+	errorClassRateLimited   = "rate_limited"
+	errorClassContextLength = "context_length"
+	errorClassContentFilter = "content_filter"
+	errorClassAuth          = "auth"
+	errorClassTimeout       = "timeout"
+	errorClassServerError   = "server_error"
+	errorClassUnknown       = "unknown"
+)
+
+// upstreamErrorPhrases are the phrases providers report a context length
+// or content filter failure with, in a 400 or an error without a status.
+// They are matched in order against the lowercased error message.
+var upstreamErrorPhrases = []struct {
+	class   string
+	phrases []string
+}{
+	{errorClassContextLength, []string{
+		"context_length_exceeded", "context length", "maximum context", "context window",
+		"prompt is too long", "reduce the length",
+	}},
+	{errorClassContentFilter, []string{
+		"content_filter", "content filter", "content policy", "content management policy",
+	}},
+}
+
+// upstreamErrorStatus returns the HTTP status an upstream answered err with,
+// and its error code when it gave one, from the error types of the provider
+// SDKs; 0 when err carries no status.
+func upstreamErrorStatus(err error) (int, string) {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		code, _ := apiErr.Code.(string)
+		return apiErr.HTTPStatusCode, code
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode, ""
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode, ""
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code, ""
+	}
+	// The AWS SDK's response errors, among others.
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode(), ""
+	}
+	return 0, ""
+}
+
+// classifyUpstreamError maps an upstream failure to an error class, by the
+// status and code of the provider's error, or by the kind of a transport
+// error.
+func classifyUpstreamError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var timeoutErr *upstreamTimeoutError
+	var netErr net.Error
+	if errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errorClassTimeout
+	}
+
+	status, code := upstreamErrorStatus(err)
+	switch code {
+	case "context_length_exceeded":
+		return errorClassContextLength
+	case "content_filter", "content_policy_violation":
+		return errorClassContentFilter
+	}
+	switch {
+	case status == http.StatusTooManyRequests:
+		return errorClassRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return errorClassAuth
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return errorClassTimeout
+	case status == http.StatusRequestEntityTooLarge:
+		return errorClassContextLength
+	case status >= http.StatusInternalServerError:
+		return errorClassServerError
+	case status == http.StatusBadRequest || status == 0:
+		msg := strings.ToLower(err.Error())
+		for _, p := range upstreamErrorPhrases {
+			for _, phrase := range p.phrases {
+				if strings.Contains(msg, phrase) {
+					return p.class
+				}
+			}
+		}
+	}
+	if status == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)) {
+		return errorClassServerError
+	}
+	return errorClassUnknown
+}
+
+// upstreamErrorResponse is how an error class is reported to clients.
+type upstreamErrorResponse struct {
+	status        int
+	openAIType    string
+	openAICode    string
+	anthropicType string
+}
+
+// upstreamErrorResponses maps error classes to the status codes and error
+// types of the OpenAI and Anthropic APIs. Upstream auth failures are the
+// gateway's credentials, not the caller's, so they are reported as 502.
+var upstreamErrorResponses = map[string]upstreamErrorResponse{
+	errorClassRateLimited:   {http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded", "rate_limit_error"},
+	errorClassContextLength: {http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", "invalid_request_error"},
+	errorClassContentFilter: {http.StatusBadRequest, "invalid_request_error", "content_filter", "invalid_request_error"},
+	errorClassAuth:          {http.StatusBadGateway, "api_error", "upstream_auth_failed", "api_error"},
+	errorClassTimeout:       {http.StatusGatewayTimeout, "api_error", "timeout", "timeout_error"},
+	errorClassServerError:   {http.StatusBadGateway, "server_error", "upstream_error", "api_error"},
+	errorClassUnknown:       {http.StatusInternalServerError, "api_error", "internal_error", "api_error"},
+}
+
+func getUpstreamErrorResponse(class string) upstreamErrorResponse {
+	if resp, ok := upstreamErrorResponses[class]; ok {
+		return resp
+	}
+	return upstreamErrorResponses[errorClassUnknown]
+}
+
+// defaultUpstreamRetryAfter is the Retry-After sent for an upstream rate
+// limit when the provider did not say how long to wait.
+const defaultUpstreamRetryAfter = 5 * time.Second
+
+// upstreamRetryAfterSeconds returns the Retry-After value, in whole seconds,
+// for a provider that asked to wait retryAfter (zero when it did not say).
+func upstreamRetryAfterSeconds(retryAfter time.Duration) string {
+	if retryAfter <= 0 {
+		retryAfter = defaultUpstreamRetryAfter
+	}
+	return strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+}
+
+// setUpstreamRetryAfter counts an upstream rate limit against provider and
+// passes its Retry-After on to the client. It must be called before the
+// error response is written; other classes are left alone.
+func (c *ApiController) setUpstreamRetryAfter(class string, provider string, limits *proxy.RateLimitRecorder) {
+	if class != errorClassRateLimited {
+		return
+	}
+	object.UpstreamRateLimits.WithLabelValues(provider).Inc()
+	retryAfter, _ := limits.RetryAfter()
+	c.Ctx.Output.Header("Retry-After", upstreamRetryAfterSeconds(retryAfter))
+}
+
+// respondOpenAIUpstreamError writes an OpenAI-style error for an upstream
+// failure of the given class.
+func (c *ApiController) respondOpenAIUpstreamError(class string, message string) {
+	resp := getUpstreamErrorResponse(class)
+	c.respondOpenAIError(resp.status, resp.openAIType, resp.openAICode, message)
+}
+
+// respondOpenAIError writes an OpenAI-style error body with the given status.
+func (c *ApiController) respondOpenAIError(status int, errType string, code string, message string) {
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"code":    code,
+		},
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	c.Ctx.Output.Header("Content-Type", "application/json")
+	c.Ctx.ResponseWriter.WriteHeader(status)
+	c.Ctx.Output.Body(jsonData)
+	c.EnableRender = false
+}
+
+// respondAnthropicUpstreamError writes an Anthropic-style error for an
+// upstream failure of the given class.
+func (c *ApiController) respondAnthropicUpstreamError(class string, message string) {
+	resp := getUpstreamErrorResponse(class)
+	c.respondAnthropicError(resp.anthropicType, message, resp.status)
+}
@@ -0,0 +1,81 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyUpstreamError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{&openai.APIError{HTTPStatusCode: 429, Message: "Rate limit reached for requests"}, errorClassRateLimited},
+		{&openai.APIError{HTTPStatusCode: 400, Code: "context_length_exceeded"}, errorClassContextLength},
+		{&openai.APIError{HTTPStatusCode: 400, Message: "This model's maximum context length is 8192 tokens"}, errorClassContextLength},
+		{errors.New("prompt is too long: 210000 tokens > 200000 maximum"), errorClassContextLength},
+		{&openai.APIError{HTTPStatusCode: 400, Code: "content_filter"}, errorClassContentFilter},
+		{&openai.APIError{HTTPStatusCode: 401, Message: "Incorrect API key provided"}, errorClassAuth},
+		{&anthropic.Error{StatusCode: 529}, errorClassServerError},
+		{fmt.Errorf("Post \"https://api.example.com\": %w", context.DeadlineExceeded), errorClassTimeout},
+		{&upstreamTimeoutError{phase: "first token", limit: time.Second}, errorClassTimeout},
+		{fmt.Errorf("stream: %w", &openai.RequestError{HTTPStatusCode: 503}), errorClassServerError},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), errorClassServerError},
+		{&openai.APIError{HTTPStatusCode: 422, Message: "the quota of 500 is flagged"}, errorClassUnknown},
+		{errors.New("error, status code: 429"), errorClassUnknown}, // no status to go by
+		{errors.New("something unexpected"), errorClassUnknown},
+	}
+	for _, tc := range cases {
+		if got := classifyUpstreamError(tc.err); got != tc.want {
+			t.Errorf("classifyUpstreamError(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestUpstreamErrorResponses(t *testing.T) {
+	if got := getUpstreamErrorResponse(errorClassRateLimited); got.status != 429 || got.openAIType != "rate_limit_error" {
+		t.Errorf("rate_limited response = %+v", got)
+	}
+	if got := getUpstreamErrorResponse("bogus"); got.status != 500 {
+		t.Errorf("unknown class should map to 500, got %+v", got)
+	}
+}
+
+func TestUpstreamRetryAfterSeconds(t *testing.T) {
+	cases := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{0, "5"},
+		{7 * time.Second, "7"},
+		{1500 * time.Millisecond, "2"},
+		{200 * time.Millisecond, "1"},
+	}
+	for _, tc := range cases {
+		if got := upstreamRetryAfterSeconds(tc.retryAfter); got != tc.want {
+			t.Errorf("upstreamRetryAfterSeconds(%v) = %q, want %q", tc.retryAfter, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+)
+
+// defaultEventBusTopic applies when eventBusTopic is unset.
+const defaultEventBusTopic = "cloud.usage"
+
+// eventBus publishes usage and error events for downstream analytics. Nil
+// when eventBusType is not configured.
+var eventBus *util.EventBus
+
+// usageEvent is the envelope published for every usage record. Type is
+// "usage" for successful requests and "error" for failed ones.
+type usageEvent struct {
+	Type           string       `json:"type"`
+	Id             string       `json:"id"`
+	Time           string       `json:"time"`
+	CostMicroCents int64        `json:"costMicroCents,omitempty"`
+	Data           *usageRecord `json:"data"`
+}
+
+// InitEventBus starts the event bus configured by eventBusType ("kafka" or
+// "nats"), eventBusBrokers and eventBusTopic. A misconfigured bus is logged
+// and disabled rather than failing startup.
+func InitEventBus() *util.EventBus {
+	kind := conf.GetConfigString("eventBusType")
+	if kind == "" {
+		return nil
+	}
+	topic := conf.GetConfigString("eventBusTopic")
+	if topic == "" {
+		topic = defaultEventBusTopic
+	}
+
+	bus, err := util.NewEventBus(kind, conf.GetConfigString("eventBusBrokers"), topic)
+	if err != nil {
+		logs.Error("InitEventBus() error: %v", err)
+		return nil
+	}
+	eventBus = bus
+	return eventBus
+}
+
+// publishUsageEvent enqueues a usage record on the event bus.
+func publishUsageEvent(record *usageRecord) {
+	if eventBus == nil {
+		return
+	}
+
+	event := &usageEvent{
+		Type: "usage",
+		Id:   util.GenerateUUID(),
+		Time: time.Now().UTC().Format(time.RFC3339Nano),
+		Data: record,
+	}
+	if record.Status == "success" {
+		event.CostMicroCents = calculateCostMicroCentsWithCache(
+			record.Model, record.PromptTokens, record.CompletionTokens,
+			record.CacheReadTokens, record.CacheWriteTokens,
+		)
+	} else {
+		event.Type = "error"
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		logs.Error("event_bus: failed to marshal usage event request_id=%s: %v", record.RequestID, err)
+		return
+	}
+	eventBus.Enqueue(&util.Event{Key: record.User, Body: body})
+}
@@ -0,0 +1,194 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+)
+
+const (
+	// defaultUsageRecorderWorkers is the number of workers recording usage
+	// when usageRecorderWorkers is unset.
+	defaultUsageRecorderWorkers = 8
+
+	// defaultUsageRecorderQueueSize is the number of jobs buffered for the
+	// workers when usageRecorderQueueSize is unset.
+	defaultUsageRecorderQueueSize = 4096
+
+	// usageRecorderEnqueueWait is how long a submit waits for room in a full
+	// queue before dropping the job. It bounds the latency a saturated
+	// recorder adds to the request that finished.
+	usageRecorderEnqueueWait = 50 * time.Millisecond
+
+	// usageRecorderShutdownTimeout is the maximum time to wait for the queue
+	// to drain on shutdown.
+	usageRecorderShutdownTimeout = 10 * time.Second
+
+	// usageRecorderHTTPTimeout is the per-request timeout for trace ingestion.
+	usageRecorderHTTPTimeout = 5 * time.Second
+)
+
+// usageRecorder runs the asynchronous work recorded after each request (the
+// datastore usage and trace rows, console ingestion) on a fixed pool of
+// workers fed by a buffered queue, so a burst of traffic cannot pile up
+// goroutines and upstream connections without bound.
+type usageRecorder struct {
+	ch   chan func()
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// recorder is nil until InitUsageRecorder; until then jobs run on their own
+// goroutine.
+var recorder *usageRecorder
+
+// usageRecorderClient is shared by every job so trace ingestion reuses
+// pooled connections instead of dialing per request.
+var usageRecorderClient = &http.Client{Timeout: usageRecorderHTTPTimeout}
+
+// InitUsageRecorder starts the usage recorder workers. Must be called once
+// during startup; main.go calls ShutdownUsageRecorder() to drain it.
+func InitUsageRecorder() {
+	workers := conf.GetConfigInt("usageRecorderWorkers")
+	if workers <= 0 {
+		workers = defaultUsageRecorderWorkers
+	}
+	queueSize := conf.GetConfigInt("usageRecorderQueueSize")
+	if queueSize <= 0 {
+		queueSize = defaultUsageRecorderQueueSize
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = workers
+	usageRecorderClient = &http.Client{Timeout: usageRecorderHTTPTimeout, Transport: transport}
+
+	recorder = newUsageRecorder(workers, queueSize)
+}
+
+func newUsageRecorder(workers int, queueSize int) *usageRecorder {
+	r := &usageRecorder{
+		ch:   make(chan func(), queueSize),
+		stop: make(chan struct{}),
+	}
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+// submitUsageJob runs job on the usage recorder.
+func submitUsageJob(job func()) {
+	if recorder == nil {
+		go job()
+		return
+	}
+	recorder.submit(job)
+}
+
+// submit queues job, waiting up to usageRecorderEnqueueWait when the queue
+// is full. Jobs still not queued then are dropped and counted.
+func (r *usageRecorder) submit(job func()) bool {
+	select {
+	case r.ch <- job:
+		object.UsageRecorderJobs.WithLabelValues("queued").Inc()
+		object.UsageRecorderQueueDepth.Set(float64(len(r.ch)))
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(usageRecorderEnqueueWait)
+	defer timer.Stop()
+	select {
+	case r.ch <- job:
+		object.UsageRecorderJobs.WithLabelValues("delayed").Inc()
+		object.UsageRecorderQueueDepth.Set(float64(len(r.ch)))
+		return true
+	case <-timer.C:
+		object.UsageRecorderJobs.WithLabelValues("dropped").Inc()
+		logs.Error("usage_recorder: dropped job (queue full, %d pending)", len(r.ch))
+		return false
+	}
+}
+
+// ShutdownUsageRecorder drains the usage recorder. Jobs only write traces
+// and reconciliation rows; usage is billed before a job is submitted, so a
+// dropped job never loses billing.
+func ShutdownUsageRecorder() {
+	if recorder == nil {
+		return
+	}
+	if remaining := recorder.shutdown(usageRecorderShutdownTimeout); remaining > 0 {
+		logs.Error("Usage recorder shutdown: %d jobs were not run", remaining)
+	} else {
+		logs.Info("Usage recorder drained successfully")
+	}
+}
+
+// shutdown signals the workers to finish the queued jobs and waits up to
+// timeout for them. Returns the number of jobs still queued then.
+func (r *usageRecorder) shutdown(timeout time.Duration) int {
+	close(r.stop)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return 0
+	case <-time.After(timeout):
+		return len(r.ch)
+	}
+}
+
+func (r *usageRecorder) worker() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case job := <-r.ch:
+			r.run(job)
+		case <-r.stop:
+			// Drain remaining jobs before exiting.
+			for {
+				select {
+				case job := <-r.ch:
+					r.run(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *usageRecorder) run(job func()) {
+	object.UsageRecorderQueueDepth.Set(float64(len(r.ch)))
+	defer func() {
+		if err := recover(); err != nil {
+			logs.Error("usage_recorder: job panicked: %v", err)
+		}
+	}()
+	job()
+}
@@ -0,0 +1,82 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUsageRecorderDrainsOnShutdown(t *testing.T) {
+	r := newUsageRecorder(2, 100)
+
+	var ran atomic.Int64
+	for i := 0; i < 50; i++ {
+		if !r.submit(func() {
+			time.Sleep(time.Millisecond)
+			ran.Add(1)
+		}) {
+			t.Fatalf("submit() dropped job %d with room in the queue", i)
+		}
+	}
+
+	if remaining := r.shutdown(5 * time.Second); remaining != 0 {
+		t.Errorf("shutdown() left %d jobs", remaining)
+	}
+	if ran.Load() != 50 {
+		t.Errorf("ran %d jobs, want 50", ran.Load())
+	}
+}
+
+func TestUsageRecorderDropsWhenFull(t *testing.T) {
+	r := newUsageRecorder(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	// Occupy the only worker, then fill the queue.
+	r.submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	if !r.submit(func() {}) {
+		t.Fatal("submit() dropped the job filling the queue")
+	}
+
+	begin := time.Now()
+	if r.submit(func() {}) {
+		t.Error("submit() queued a job past the queue size")
+	}
+	if waited := time.Since(begin); waited < usageRecorderEnqueueWait {
+		t.Errorf("submit() gave up after %v, want at least %v", waited, usageRecorderEnqueueWait)
+	}
+
+	close(release)
+	r.shutdown(5 * time.Second)
+}
+
+func TestUsageRecorderSurvivesPanics(t *testing.T) {
+	r := newUsageRecorder(1, 10)
+
+	var ran atomic.Bool
+	r.submit(func() { panic("boom") })
+	r.submit(func() { ran.Store(true) })
+	r.shutdown(5 * time.Second)
+
+	if !ran.Load() {
+		t.Error("the worker stopped after a panicking job")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/beego/beego/context"
 	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/i18n"
+	"github.com/hanzoai/cloud/object"
 	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 )
@@ -117,12 +118,10 @@ func (c *ApiController) CheckSignedIn() (string, bool) {
 	return userId, true
 }
 
+// RequireAdmin ensures the caller is signed in as an admin. Unlike the
+// read-only pages of preview mode, it holds whether or not preview mode is
+// enabled.
 func (c *ApiController) RequireAdmin() bool {
-	disablePreviewMode, _ := beego.AppConfig.Bool("disablePreviewMode")
-	if !disablePreviewMode {
-		return true
-	}
-
 	if !c.IsAdmin() {
 		c.ResponseError(c.T("auth:this operation requires admin privilege"))
 		return false
@@ -202,6 +201,13 @@ func getOriginFromHost(host string) string {
 	return fmt.Sprintf("%s%s", protocol, host)
 }
 
+// getCloudUrl returns the external URL to build absolute URLs on; see
+// object.GetCloudUrl. Without one configured, it is the origin of the
+// request's host.
+func (c *ApiController) getCloudUrl() string {
+	return object.GetCloudUrl(getOriginFromHost(c.Ctx.Request.Host))
+}
+
 func removeHtmlTags(s string) string {
 	re := regexp.MustCompile(`<[^>]+>`)
 	return re.ReplaceAllString(s, "")
@@ -0,0 +1,338 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Webhooks. Organizations register endpoints in the webhook table and
+// receive signed events (see object/webhook_delivery.go):
+//
+//   - request.completed and request.failed after every gateway request, with
+//     its usage but never its prompt or response;
+//   - spend.threshold when an organization's spend crosses a share of a
+//     tenant quota's spend limit (spendThresholdPercents, default 80,100);
+//   - model.deprecated when a deprecated model is called, at most once a day
+//     per organization and model.
+//
+// Events are stored as deliveries before the request's usage recording
+// returns, so none are lost to a full queue. Spend thresholds are computed
+// from spend shared between replicas when a distributed cache backend is
+// configured (see tenantUsageStore.addSpend).
+
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/beego/beego/utils/pagination"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+)
+
+// defaultSpendThresholdPercents are the shares of a quota's spend limit
+// that send spend.threshold.
+var defaultSpendThresholdPercents = []int64{80, 100}
+
+// modelDeprecatedWebhookCooldown suppresses repeats of model.deprecated for
+// an organization and model.
+const modelDeprecatedWebhookCooldown = 24 * time.Hour
+
+// sendWebhookEvent is swapped out by tests.
+var sendWebhookEvent = object.SendWebhookEvent
+
+var modelDeprecatedWebhooks = struct {
+	sync.Mutex
+	lastSent map[string]time.Time // org|model → last sent
+}{lastSent: map[string]time.Time{}}
+
+// getSpendThresholdPercents reads spendThresholdPercents, a comma-separated
+// list of percentages such as "50,80,100".
+func getSpendThresholdPercents() []int64 {
+	value := conf.GetConfigString("spendThresholdPercents")
+	if value == "" {
+		return defaultSpendThresholdPercents
+	}
+	percents := []int64{}
+	for _, field := range strings.Split(value, ",") {
+		percent, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || percent <= 0 {
+			logs.Warn("webhook: ignoring spend threshold %q", field)
+			continue
+		}
+		percents = append(percents, percent)
+	}
+	return percents
+}
+
+func sendWebhookEventNoError(org string, eventType string, data interface{}) {
+	if err := sendWebhookEvent(org, eventType, data); err != nil {
+		logs.Warn("webhook: failed to send %s to org=%s: %v", eventType, org, err)
+	}
+}
+
+// sendUsageWebhooks sends request.completed or request.failed for a
+// finished request, and model.deprecated when its model is deprecated.
+func sendUsageWebhooks(record *usageRecord) {
+	org := usageOrganization(record)
+	if org == "" {
+		return
+	}
+
+	eventType := object.WebhookEventRequestCompleted
+	data := map[string]interface{}{
+		"requestId":        record.RequestID,
+		"user":             record.User,
+		"model":            record.Model,
+		"provider":         record.Provider,
+		"status":           record.Status,
+		"stream":           record.Stream,
+		"promptTokens":     record.PromptTokens,
+		"completionTokens": record.CompletionTokens,
+		"totalTokens":      record.TotalTokens,
+		"latencyMs":        record.LatencyMs,
+		"ttftMs":           record.TtftMs,
+		"finishReason":     record.FinishReason,
+	}
+	if record.Status != "success" {
+		eventType = object.WebhookEventRequestFailed
+		data["errorMsg"] = record.ErrorMsg
+		data["errorClass"] = record.ErrorClass
+	}
+	sendWebhookEventNoError(org, eventType, data)
+
+	notice := GetModelConfig().GetDeprecation(record.Model)
+	if notice == "" || !claimModelDeprecatedWebhook(org, record.Model, time.Now()) {
+		return
+	}
+	deprecated := map[string]interface{}{
+		"model":  record.Model,
+		"notice": notice,
+		"user":   record.User,
+	}
+	sendWebhookEventNoError(org, object.WebhookEventModelDeprecated, deprecated)
+}
+
+// claimModelDeprecatedWebhook reports whether model.deprecated is due for
+// org and model at now, and marks it sent.
+func claimModelDeprecatedWebhook(org string, model string, now time.Time) bool {
+	key := org + "|" + strings.ToLower(model)
+	modelDeprecatedWebhooks.Lock()
+	defer modelDeprecatedWebhooks.Unlock()
+	if last, ok := modelDeprecatedWebhooks.lastSent[key]; ok && now.Sub(last) < modelDeprecatedWebhookCooldown {
+		return false
+	}
+	modelDeprecatedWebhooks.lastSent[key] = now
+	for k, last := range modelDeprecatedWebhooks.lastSent {
+		if now.Sub(last) >= modelDeprecatedWebhookCooldown {
+			delete(modelDeprecatedWebhooks.lastSent, k)
+		}
+	}
+	return true
+}
+
+// sendSpendThresholdWebhook sends spend.threshold for a crossed threshold.
+func sendSpendThresholdWebhook(org string, crossing spendCrossing) {
+	data := map[string]interface{}{
+		"period":     crossing.Period,
+		"percent":    crossing.Percent,
+		"spendCents": crossing.SpendCents,
+		"limitCents": crossing.LimitCents,
+	}
+	sendWebhookEventNoError(org, object.WebhookEventSpendThreshold, data)
+}
+
+// ── Admin endpoints ─────────────────────────────────────────────────────
+
+// GetWebhooks
+// @Title GetWebhooks
+// @Tag Webhook API
+// @Description get the webhooks of an organization, with their secrets masked
+// @Param owner query string true "The owner (org) of the webhooks"
+// @Success 200 {array} object.Webhook The Response object
+// @router /get-webhooks [get]
+func (c *ApiController) GetWebhooks() {
+	webhooks, err := object.GetMaskedWebhooks(object.GetWebhooks(c.Input().Get("owner")))
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(webhooks)
+}
+
+// AddWebhook
+// @Title AddWebhook
+// @Tag Webhook API
+// @Description add a webhook for an organization. The response's data2 holds the signing secret, which is not shown again
+// @Param body body object.Webhook true "The details of the webhook"
+// @Success 200 {object} controllers.Response The Response object
+// @router /add-webhook [post]
+func (c *ApiController) AddWebhook() {
+	var webhook object.Webhook
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.AddWebhook(&webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("add", "webhook", webhook.Owner, webhook.GetId(), nil, &webhook)
+	}
+
+	c.ResponseOk(success, webhook.Secret)
+}
+
+// UpdateWebhook
+// @Title UpdateWebhook
+// @Tag Webhook API
+// @Description update an organization's webhook. A masked or empty secret keeps the current one
+// @Param owner query string true "The owner (org)"
+// @Param name query string true "The name of the webhook"
+// @Param body body object.Webhook true "The details of the webhook"
+// @Success 200 {object} controllers.Response The Response object
+// @router /update-webhook [post]
+func (c *ApiController) UpdateWebhook() {
+	owner := c.Input().Get("owner")
+	name := c.Input().Get("name")
+
+	var webhook object.Webhook
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetWebhook(owner, name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.UpdateWebhook(owner, name, &webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("update", "webhook", owner, webhook.GetId(), before, &webhook)
+	}
+
+	c.ResponseOk(success)
+}
+
+// DeleteWebhook
+// @Title DeleteWebhook
+// @Tag Webhook API
+// @Description delete an organization's webhook
+// @Param body body object.Webhook true "The details of the webhook"
+// @Success 200 {object} controllers.Response The Response object
+// @router /delete-webhook [post]
+func (c *ApiController) DeleteWebhook() {
+	var webhook object.Webhook
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	before, err := object.GetWebhook(webhook.Owner, webhook.Name)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	success, err := object.DeleteWebhook(&webhook)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if success {
+		c.recordAdminAudit("delete", "webhook", webhook.Owner, webhook.GetId(), before, nil)
+	}
+
+	c.ResponseOk(success)
+}
+
+// GetWebhookDeliveries
+// @Title GetWebhookDeliveries
+// @Tag Webhook API
+// @Description get webhook deliveries, newest first
+// @Param   owner       query    string  false    "organization"
+// @Param   webhook     query    string  false    "webhook name"
+// @Param   event       query    string  false    "event type, e.g. request.failed"
+// @Param   status      query    string  false    "pending, succeeded or failed"
+// @Param   pageSize    query    int     false    "page size (default 50)"
+// @Param   p           query    int     false    "page number"
+// @Success 200 {array} object.WebhookDelivery The Response object
+// @router /get-webhook-deliveries [get]
+func (c *ApiController) GetWebhookDeliveries() {
+	filter := &object.WebhookDeliveryFilter{
+		Owner:   c.Input().Get("owner"),
+		Webhook: c.Input().Get("webhook"),
+		Event:   c.Input().Get("event"),
+		Status:  c.Input().Get("status"),
+	}
+	limit, _ := strconv.Atoi(c.Input().Get("pageSize"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	count, err := object.GetWebhookDeliveryCount(filter)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	paginator := pagination.SetPaginator(c.Ctx, limit, count)
+	deliveries, err := object.GetPaginationWebhookDeliveries(filter, paginator.Offset(), limit)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(deliveries, paginator.Nums())
+}
+
+// ReplayWebhookDelivery
+// @Title ReplayWebhookDelivery
+// @Tag Webhook API
+// @Description send a delivery's event again, as a new delivery with the same event ID
+// @Param   owner query    string true    "The owner (org) of the delivery"
+// @Param   id    query    int    true    "The id of the delivery"
+// @Success 200 {object} object.WebhookDelivery The Response object
+// @router /replay-webhook-delivery [post]
+func (c *ApiController) ReplayWebhookDelivery() {
+	id, err := strconv.Atoi(c.Input().Get("id"))
+	if err != nil {
+		c.ResponseError("id must be an integer")
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	replay, err := object.ReplayWebhookDelivery(owner, id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	c.recordAdminAudit("replay", "webhook-delivery", owner, strconv.Itoa(id), nil, replay)
+
+	c.ResponseOk(replay)
+}
@@ -0,0 +1,182 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+func TestTenantUsageStoreAddSpend(t *testing.T) {
+	store := &tenantUsageStore{orgs: map[string]map[int64]*quotaUsage{}}
+	quotas := []*object.TenantQuota{
+		{Owner: "acme", Period: object.QuotaPeriodDaily, MaxSpendCents: 100, Enabled: true},
+		{Owner: "acme", Period: object.QuotaPeriodMonthly, MaxTokens: 1000, Enabled: true},
+	}
+	percents := []int64{80, 100}
+	start := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	spend := func(cents int64, at time.Time) []spendCrossing {
+		return store.addSpend("acme", quotaUsage{SpendMicroCents: cents * util.MicroCentsPerCent}, quotas, percents, at)
+	}
+
+	if got := spend(50, start); len(got) != 0 {
+		t.Errorf("50%%: crossed %+v, want none", got)
+	}
+	want := []spendCrossing{{Period: object.QuotaPeriodDaily, Percent: 80, SpendCents: 85, LimitCents: 100}}
+	if got := spend(35, start); !reflect.DeepEqual(got, want) {
+		t.Errorf("85%%: crossed %+v, want %+v", got, want)
+	}
+	if got := spend(5, start); len(got) != 0 {
+		t.Errorf("90%%: crossed %+v, want none", got)
+	}
+	want = []spendCrossing{{Period: object.QuotaPeriodDaily, Percent: 100, SpendCents: 120, LimitCents: 100}}
+	if got := spend(30, start); !reflect.DeepEqual(got, want) {
+		t.Errorf("120%%: crossed %+v, want %+v", got, want)
+	}
+
+	// A day later the window has slid past the earlier spend, so the
+	// thresholds can be crossed again.
+	next := start.Add(25 * time.Hour)
+	want = []spendCrossing{
+		{Period: object.QuotaPeriodDaily, Percent: 80, SpendCents: 100, LimitCents: 100},
+		{Period: object.QuotaPeriodDaily, Percent: 100, SpendCents: 100, LimitCents: 100},
+	}
+	if got := spend(100, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("next day: crossed %+v, want %+v", got, want)
+	}
+}
+
+// counterBackend is a cache.Backend keeping counters and plain values,
+// standing in for the Redis the replicas share.
+type counterBackend struct {
+	mu        sync.Mutex
+	counters  map[string]int64
+	values    map[string][]byte
+	published []string
+}
+
+func (b *counterBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.values[key]
+	return value, ok, nil
+}
+
+func (b *counterBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.values == nil {
+		b.values = map[string][]byte{}
+	}
+	b.values[key] = value
+	return nil
+}
+
+func (b *counterBackend) Delete(ctx context.Context, keys ...string) error { return nil }
+
+func (b *counterBackend) DeletePrefix(ctx context.Context, prefix string) error { return nil }
+
+func (b *counterBackend) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counters[key] += delta
+	return b.counters[key], nil
+}
+
+func (b *counterBackend) GetInts(ctx context.Context, keys ...string) ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	values := make([]int64, len(keys))
+	for i, key := range keys {
+		values[i] = b.counters[key]
+	}
+	return values, nil
+}
+
+func (b *counterBackend) Publish(ctx context.Context, channel string, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, message)
+	return nil
+}
+
+func (b *counterBackend) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	return nil
+}
+
+func (b *counterBackend) Close() error { return nil }
+
+func TestTenantUsageStoreAddSharedSpend(t *testing.T) {
+	cache.SetBackend(&counterBackend{counters: map[string]int64{}})
+	t.Cleanup(func() { cache.SetBackend(nil) })
+
+	// Two replicas, each with its own store, spend for the same org.
+	replicas := []*tenantUsageStore{
+		{orgs: map[string]map[int64]*quotaUsage{}},
+		{orgs: map[string]map[int64]*quotaUsage{}},
+	}
+	quotas := []*object.TenantQuota{{Owner: "shared-acme", Period: object.QuotaPeriodMonthly, MaxSpendCents: 100, Enabled: true}}
+	start := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	spend := func(replica int, cents int64, at time.Time) []spendCrossing {
+		delta := quotaUsage{SpendMicroCents: cents * util.MicroCentsPerCent}
+		return replicas[replica].addSpend("shared-acme", delta, quotas, []int64{80}, at)
+	}
+
+	if got := spend(0, 50, start); len(got) != 0 {
+		t.Errorf("50%%: crossed %+v, want none", got)
+	}
+	// The second replica's spend is counted on top of the first's, a day
+	// later and so in another bucket.
+	want := []spendCrossing{{Period: object.QuotaPeriodMonthly, Percent: 80, SpendCents: 90, LimitCents: 100}}
+	if got := spend(1, 40, start.Add(24*time.Hour)); !reflect.DeepEqual(got, want) {
+		t.Errorf("90%%: crossed %+v, want %+v", got, want)
+	}
+	if got := spend(0, 5, start.Add(25*time.Hour)); len(got) != 0 {
+		t.Errorf("95%%: crossed %+v, want none", got)
+	}
+}
+
+func TestClaimModelDeprecatedWebhook(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	if !claimModelDeprecatedWebhook("acme-test", "Old-Model", now) {
+		t.Fatal("first call: not claimed")
+	}
+	if claimModelDeprecatedWebhook("acme-test", "old-model", now.Add(time.Hour)) {
+		t.Error("within the cooldown: claimed again")
+	}
+	if !claimModelDeprecatedWebhook("other-test", "old-model", now.Add(time.Hour)) {
+		t.Error("another organization: not claimed")
+	}
+	if !claimModelDeprecatedWebhook("acme-test", "old-model", now.Add(modelDeprecatedWebhookCooldown)) {
+		t.Error("after the cooldown: not claimed")
+	}
+}
+
+func TestGetSpendThresholdPercents(t *testing.T) {
+	if got := getSpendThresholdPercents(); !reflect.DeepEqual(got, defaultSpendThresholdPercents) {
+		t.Errorf("default = %v, want %v", got, defaultSpendThresholdPercents)
+	}
+	t.Setenv("spendThresholdPercents", "50, 90,x,100")
+	if got, want := getSpendThresholdPercents(), []int64{50, 90, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("configured = %v, want %v", got, want)
+	}
+}
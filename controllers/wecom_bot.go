@@ -156,7 +156,8 @@ func (c *ApiController) handleTextMessage(message *object.WecomBotMessage, wxcpt
 }
 
 func sendMessage(store *object.Store, question string, lang string) (string, error) {
-	modelProvider, _, err := object.GetModelProviderFromContext("admin", store.ModelProvider, lang)
+	modelProviderNames := orderStoreModelProviders(store, "", isStoreProviderHealthy)
+	modelProvider, _, _, err := getStoreModelProvider(modelProviderNames, lang)
 	if err != nil {
 		return "", err
 	}
@@ -171,7 +172,7 @@ func sendMessage(store *object.Store, question string, lang string) (string, err
 		return "", err
 	}
 	var history []*model.RawMessage
-	answer, _, err := object.GetAnswerWithContext(store.ModelProvider, question, history, knowledge, store.Prompt, lang)
+	answer, _, err := object.GetAnswerWithContext(modelProvider.Name, question, history, knowledge, store.Prompt, lang)
 	if err != nil {
 		return "", err
 	}
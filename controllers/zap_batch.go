@@ -0,0 +1,115 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/hanzoai/cloud/util"
+)
+
+// zapBatchMaxSize is the most envelopes accepted in one batch.
+const zapBatchMaxSize = 100
+
+// runZapBatch executes envelopes with at most zapMaxInflight running at once
+// and returns their result frames keyed by envelope id. Streaming is
+// disabled: chat requests return only their final result.
+func runZapBatch(ctx context.Context, envelopes []*zapEnvelope, handle func(ctx context.Context, envelope *zapEnvelope) *zapFrame) map[string]*zapFrame {
+	results := make(map[string]*zapFrame, len(envelopes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inflight := make(chan struct{}, zapMaxInflight)
+
+	for _, envelope := range envelopes {
+		inflight <- struct{}{}
+		wg.Add(1)
+		go func(envelope *zapEnvelope) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			frame := handle(ctx, envelope)
+			mu.Lock()
+			results[envelope.Id] = frame
+			mu.Unlock()
+		}(envelope)
+	}
+	wg.Wait()
+	return results
+}
+
+// validateZapBatch checks batch size and that every envelope has a unique id,
+// since results are keyed by it.
+func validateZapBatch(envelopes []*zapEnvelope) error {
+	if len(envelopes) == 0 {
+		return fmt.Errorf("batch is empty")
+	}
+	if len(envelopes) > zapBatchMaxSize {
+		return fmt.Errorf("batch has %d requests, max %d", len(envelopes), zapBatchMaxSize)
+	}
+
+	seen := map[string]bool{}
+	for i, envelope := range envelopes {
+		if envelope == nil || envelope.Id == "" {
+			return fmt.Errorf("request %d has no id", i)
+		}
+		if seen[envelope.Id] {
+			return fmt.Errorf("duplicate request id: %s", envelope.Id)
+		}
+		seen[envelope.Id] = true
+	}
+	return nil
+}
+
+// ZapBatch
+// @Title ZapBatch
+// @Tag ZAP API
+// @Description execute an array of ZAP envelopes, returning results keyed by id
+// @Param   body    body   []zapEnvelope  true        "The ZAP requests"
+// @Success 200 {object} map[string]zapFrame The results keyed by request id
+// @router /zap [post]
+func (c *ApiController) ZapBatch() {
+	var envelopes []*zapEnvelope
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &envelopes)
+	if err == nil {
+		err = validateZapBatch(envelopes)
+	}
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": "invalid batch: " + err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	defaultAuth := c.Ctx.Request.Header.Get("Authorization")
+	for _, envelope := range envelopes {
+		if envelope.Auth == "" {
+			envelope.Auth = defaultAuth
+		}
+	}
+
+	// Each envelope counts against the rate limits like the HTTP request it
+	// stands in for; one over the limit gets a 429 result of its own.
+	clientIP := util.GetClientIP(c.Ctx.Request)
+	c.Data["json"] = runZapBatch(c.Ctx.Request.Context(), envelopes, func(ctx context.Context, envelope *zapEnvelope) *zapFrame {
+		if frame := zapRateLimitFrame(envelope, clientIP); frame != nil {
+			return frame
+		}
+		return handleZapEnvelope(ctx, envelope, nil)
+	})
+	c.ServeJSON()
+}
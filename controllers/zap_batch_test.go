@@ -0,0 +1,71 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunZapBatchBoundedAndKeyed(t *testing.T) {
+	envelopes := []*zapEnvelope{}
+	for i := 0; i < 3*zapMaxInflight; i++ {
+		envelopes = append(envelopes, &zapEnvelope{Id: fmt.Sprintf("req-%d", i), Method: "models.list"})
+	}
+	if err := validateZapBatch(envelopes); err != nil {
+		t.Fatalf("validateZapBatch() error: %v", err)
+	}
+
+	var running, peak int32
+	results := runZapBatch(context.Background(), envelopes, func(ctx context.Context, envelope *zapEnvelope) *zapFrame {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return &zapFrame{Id: envelope.Id, Status: 200}
+	})
+
+	if peak > zapMaxInflight {
+		t.Errorf("peak concurrency = %d, want <= %d", peak, zapMaxInflight)
+	}
+	if len(results) != len(envelopes) {
+		t.Fatalf("got %d results, want %d", len(results), len(envelopes))
+	}
+	for _, envelope := range envelopes {
+		if results[envelope.Id] == nil || results[envelope.Id].Id != envelope.Id {
+			t.Errorf("missing result for %s", envelope.Id)
+		}
+	}
+}
+
+func TestValidateZapBatchRejectsBadIds(t *testing.T) {
+	if err := validateZapBatch(nil); err == nil {
+		t.Error("expected error for empty batch")
+	}
+	if err := validateZapBatch([]*zapEnvelope{{Id: "a"}, {Id: ""}}); err == nil {
+		t.Error("expected error for missing id")
+	}
+	if err := validateZapBatch([]*zapEnvelope{{Id: "a"}, {Id: "a"}}); err == nil {
+		t.Error("expected error for duplicate id")
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+)
+
+// Server-initiated ZAP event types, delivered to events.subscribe.
+const (
+	zapEventBalanceLow       = "balance.low"
+	zapEventModelDeprecated  = "model.deprecated"
+	zapEventRateLimitWarning = "ratelimit.warning"
+)
+
+var zapEventTypes = []string{zapEventBalanceLow, zapEventModelDeprecated, zapEventRateLimitWarning}
+
+const (
+	// zapEventCooldown suppresses repeats of the same event for a subject.
+	zapEventCooldown = 10 * time.Minute
+
+	// defaultBalanceLowThreshold is the balance in dollars below which
+	// balance.low fires when balanceLowThreshold is unset.
+	defaultBalanceLowThreshold = 1.0
+
+	// rateLimitWarningFraction fires ratelimit.warning once the remaining
+	// requests fall to this fraction of the per-minute limit.
+	rateLimitWarningFraction = 0.1
+)
+
+// zapEvent is a server-initiated event.
+type zapEvent struct {
+	Type string      `json:"type"`
+	Time string      `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// zapSubscription receives the events of its subjects: the subscriber's user
+// id and the key subject of the token it authenticated with.
+type zapSubscription struct {
+	subjects []string
+	types    map[string]bool
+	send     func(event *zapEvent)
+}
+
+// zapEventHub routes events to subscriptions by subject.
+type zapEventHub struct {
+	mu       sync.RWMutex
+	subjects map[string]map[*zapSubscription]bool
+	lastSent map[string]time.Time // subject|type|key → last publish
+}
+
+var zapEvents = &zapEventHub{
+	subjects: map[string]map[*zapSubscription]bool{},
+	lastSent: map[string]time.Time{},
+}
+
+func (h *zapEventHub) subscribe(sub *zapSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subject := range sub.subjects {
+		if h.subjects[subject] == nil {
+			h.subjects[subject] = map[*zapSubscription]bool{}
+		}
+		h.subjects[subject][sub] = true
+	}
+}
+
+func (h *zapEventHub) unsubscribe(sub *zapSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subject := range sub.subjects {
+		delete(h.subjects[subject], sub)
+		if len(h.subjects[subject]) == 0 {
+			delete(h.subjects, subject)
+		}
+	}
+}
+
+// publish delivers an event to the subscriptions of subject that want its
+// type. The same subject, type and key publish at most once per
+// zapEventCooldown, so hot paths can call this on every request.
+func (h *zapEventHub) publish(subject string, eventType string, key string, data interface{}) {
+	if subject == "" {
+		return
+	}
+
+	h.mu.RLock()
+	subs := make([]*zapSubscription, 0, len(h.subjects[subject]))
+	for sub := range h.subjects[subject] {
+		if sub.types[eventType] {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	dedupKey := subject + "|" + eventType + "|" + key
+	h.mu.Lock()
+	if last, ok := h.lastSent[dedupKey]; ok && now.Sub(last) < zapEventCooldown {
+		h.mu.Unlock()
+		return
+	}
+	h.lastSent[dedupKey] = now
+	for k, last := range h.lastSent {
+		if now.Sub(last) >= zapEventCooldown {
+			delete(h.lastSent, k)
+		}
+	}
+	h.mu.Unlock()
+
+	event := &zapEvent{Type: eventType, Time: now.UTC().Format(time.RFC3339), Data: data}
+	for _, sub := range subs {
+		sub.send(event)
+	}
+}
+
+// zapKeySubject identifies a token without keeping it in the hub.
+func zapKeySubject(token string) string {
+	token = strings.TrimPrefix(token, "Bearer ")
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// parseZapEventTypes returns the requested event types, defaulting to all.
+func parseZapEventTypes(body []byte) (map[string]bool, error) {
+	var request struct {
+		Events []string `json:"events"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			return nil, err
+		}
+	}
+	if len(request.Events) == 0 {
+		request.Events = zapEventTypes
+	}
+
+	types := map[string]bool{}
+	for _, eventType := range request.Events {
+		if !util.InSlice(zapEventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type: %s", eventType)
+		}
+		types[eventType] = true
+	}
+	return types, nil
+}
+
+// subscribeZapEvents registers an events.subscribe request on a WebSocket
+// connection. Events are written asynchronously so a slow client never
+// blocks the request that triggered them. Returns the subscription, nil on
+// failure, and the frame answering the request.
+func subscribeZapEvents(conn *zapWsConn, request *zapEnvelope) (*zapSubscription, *zapFrame) {
+	userId, err := zapResolveUser(request.Auth)
+	if err != nil {
+		return nil, newZapErrorFrame(request.Id, 401, err.Error())
+	}
+	types, err := parseZapEventTypes(request.Body)
+	if err != nil {
+		return nil, newZapErrorFrame(request.Id, 400, "invalid request: "+err.Error())
+	}
+
+	id := request.Id
+	sub := &zapSubscription{
+		subjects: []string{userId},
+		types:    types,
+		send: func(event *zapEvent) {
+			go func() {
+				_ = conn.writeFrame(&zapFrame{Id: id, Event: event})
+			}()
+		},
+	}
+	if keySubject := zapKeySubject(request.Auth); keySubject != "" {
+		sub.subjects = append(sub.subjects, keySubject)
+	}
+	zapEvents.subscribe(sub)
+
+	subscribed := []string{}
+	for _, eventType := range zapEventTypes {
+		if types[eventType] {
+			subscribed = append(subscribed, eventType)
+		}
+	}
+	result, _ := json.Marshal(map[string]interface{}{"subscribed": subscribed})
+	return sub, &zapFrame{Id: id, Status: 200, Result: result}
+}
+
+// ── Event sources ───────────────────────────────────────────────────────
+
+func getBalanceLowThreshold() float64 {
+	threshold, err := strconv.ParseFloat(conf.GetConfigString("balanceLowThreshold"), 64)
+	if err == nil && threshold > 0 {
+		return threshold
+	}
+	return defaultBalanceLowThreshold
+}
+
+// notifyBalanceLow publishes balance.low when a balance lookup finds the
+// user below balanceLowThreshold dollars.
+func notifyBalanceLow(userId string, balance float64) {
+	threshold := getBalanceLowThreshold()
+	if balance >= threshold {
+		return
+	}
+	zapEvents.publish(userId, zapEventBalanceLow, "", map[string]interface{}{
+		"user":      userId,
+		"balance":   balance,
+		"threshold": threshold,
+		"currency":  "usd",
+	})
+}
+
+// notifyModelDeprecated publishes model.deprecated when a user calls a
+// model the model config marks as deprecated.
+func notifyModelDeprecated(record *usageRecord) {
+	if record.User == "" {
+		return
+	}
+	notice := GetModelConfig().GetDeprecation(record.Model)
+	if notice == "" {
+		return
+	}
+	zapEvents.publish(record.User, zapEventModelDeprecated, strings.ToLower(record.Model), map[string]interface{}{
+		"model":  record.Model,
+		"notice": notice,
+	})
+}
+
+// NotifyRateLimitWarning publishes ratelimit.warning to subscribers that
+// authenticated with apiKey once its remaining requests run low. Called by
+// the rate limit filter after every allowed request.
+func NotifyRateLimitWarning(apiKey string, limit int, remaining int, reset time.Duration) {
+	if limit <= 0 || float64(remaining) > float64(limit)*rateLimitWarningFraction {
+		return
+	}
+	zapEvents.publish(zapKeySubject(apiKey), zapEventRateLimitWarning, "", map[string]interface{}{
+		"limit":     limit,
+		"remaining": remaining,
+		"resetSecs": int(reset.Seconds()),
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestZapEventHubRoutesAndDedups(t *testing.T) {
+	hub := &zapEventHub{subjects: map[string]map[*zapSubscription]bool{}, lastSent: map[string]time.Time{}}
+
+	var got []*zapEvent
+	sub := &zapSubscription{
+		subjects: []string{"hanzo/alice", zapKeySubject("Bearer hk-alice")},
+		types:    map[string]bool{zapEventBalanceLow: true, zapEventRateLimitWarning: true},
+		send:     func(event *zapEvent) { got = append(got, event) },
+	}
+	hub.subscribe(sub)
+
+	hub.publish("hanzo/alice", zapEventBalanceLow, "", 0.5)
+	hub.publish("hanzo/alice", zapEventBalanceLow, "", 0.4)         // within cooldown
+	hub.publish("hanzo/alice", zapEventModelDeprecated, "gpt-4", 1) // not subscribed
+	hub.publish("hanzo/bob", zapEventBalanceLow, "", 0.1)           // other user
+	hub.publish(zapKeySubject("hk-alice"), zapEventRateLimitWarning, "", 3)
+
+	if len(got) != 2 || got[0].Type != zapEventBalanceLow || got[1].Type != zapEventRateLimitWarning {
+		t.Fatalf("unexpected events delivered: %+v", got)
+	}
+
+	hub.unsubscribe(sub)
+	if len(hub.subjects) != 0 {
+		t.Errorf("expected no subjects after unsubscribe, got %d", len(hub.subjects))
+	}
+}
+
+func TestParseZapEventTypes(t *testing.T) {
+	types, err := parseZapEventTypes(nil)
+	if err != nil || len(types) != len(zapEventTypes) {
+		t.Errorf("default types = %v, %v; want all", types, err)
+	}
+	types, err = parseZapEventTypes([]byte(`{"events":["balance.low"]}`))
+	if err != nil || len(types) != 1 || !types[zapEventBalanceLow] {
+		t.Errorf("types = %v, %v; want balance.low", types, err)
+	}
+	if _, err = parseZapEventTypes([]byte(`{"events":["model.created"]}`)); err == nil {
+		t.Error("expected error for unknown event type")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luxfi/zap"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	// zapEmbeddingsMaxInputs caps the inputs of one embeddings.create call.
+	zapEmbeddingsMaxInputs = 256
+
+	// zapUsageDefaultDays is the usage.query window when from is unset.
+	zapUsageDefaultDays = 30
+)
+
+// ── embeddings.create ───────────────────────────────────────────────────
+
+// parseEmbeddingInput accepts the OpenAI input forms a single string or an
+// array of strings.
+func parseEmbeddingInput(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, fmt.Errorf("input is empty")
+		}
+		return []string{single}, nil
+	}
+
+	var inputs []string
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("input is empty")
+	}
+	if len(inputs) > zapEmbeddingsMaxInputs {
+		return nil, fmt.Errorf("input has %d items, max %d", len(inputs), zapEmbeddingsMaxInputs)
+	}
+	return inputs, nil
+}
+
+// zapEmbeddingsHandler embeds the inputs with the default store's embedding
+// provider and bills the tokens like a chat prompt. The response follows the
+// OpenAI embeddings shape; its model is the provider's upstream model.
+func zapEmbeddingsHandler(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+	userId, err := zapResolveUser(auth)
+	if err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+
+	var request struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err = json.Unmarshal(body, &request); err != nil {
+		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+	}
+	inputs, err := parseEmbeddingInput(request.Input)
+	if err != nil {
+		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+	}
+
+	balance, err := getUserBalance(userId)
+	if err != nil || balance <= 0 {
+		return object.BuildCloudResponse(402, nil, "insufficient balance")
+	}
+
+	provider, embeddingProvider, err := object.GetEmbeddingProviderFromContext("admin", "", "en")
+	if err != nil {
+		return object.BuildCloudResponse(502, nil, "embedding provider init failed: "+err.Error())
+	}
+	if embeddingProvider == nil {
+		return object.BuildCloudResponse(503, nil, "no embedding provider configured")
+	}
+
+	requestStartTime := time.Now().UTC()
+	requestId := util.GenerateUUID()
+	response := openai.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]openai.Embedding, len(inputs)),
+		Model:  openai.EmbeddingModel(provider.SubType),
+	}
+	for i, input := range inputs {
+		vector, result, err := embeddingProvider.QueryVector(input, ctx, "en")
+		if err != nil {
+			return object.BuildCloudResponse(uint32(getUpstreamErrorResponse(classifyUpstreamError(err)).status), nil, "provider error: "+err.Error())
+		}
+		response.Data[i] = openai.Embedding{Object: "embedding", Index: i, Embedding: vector}
+		if result != nil {
+			response.Usage.PromptTokens += result.TokenCount
+		}
+	}
+	response.Usage.TotalTokens = response.Usage.PromptTokens
+
+	owner, _, _ := strings.Cut(userId, "/")
+	record := &usageRecord{
+		Owner:        owner,
+		User:         userId,
+		Organization: owner,
+		Model:        provider.SubType,
+		Provider:     provider.Name,
+		PromptTokens: response.Usage.PromptTokens,
+		TotalTokens:  response.Usage.TotalTokens,
+		Currency:     "USD",
+		Status:       "success",
+		RequestID:    requestId,
+		LatencyMs:    time.Since(requestStartTime).Milliseconds(),
+	}
+	recordUsage(record)
+
+	data, _ := json.Marshal(response)
+	return object.BuildCloudResponse(200, data, "")
+}
+
+// ── moderations.create ──────────────────────────────────────────────────
+
+// zapModerationResult is the verdict on one input. Categories holds the
+// categories the guard model reported as violated.
+type zapModerationResult struct {
+	Flagged    bool            `json:"flagged"`
+	Categories map[string]bool `json:"categories"`
+}
+
+// zapModerationResponse follows the OpenAI moderations shape.
+type zapModerationResponse struct {
+	Id      string                 `json:"id"`
+	Model   string                 `json:"model"`
+	Results []*zapModerationResult `json:"results"`
+}
+
+// moderateInputs classifies each input with the guard model the moderation
+// guardrails use, on behalf of org.
+func moderateInputs(ctx context.Context, org string, inputs []string) (*zapModerationResponse, error) {
+	response := &zapModerationResponse{
+		Id:      "modr-" + util.GenerateUUID(),
+		Model:   getGuardrailModel(),
+		Results: make([]*zapModerationResult, len(inputs)),
+	}
+	for i, input := range inputs {
+		unsafe, categories, err := guardrailModerator(ctx, org, input, "en")
+		if err != nil {
+			return nil, err
+		}
+		result := &zapModerationResult{Flagged: unsafe, Categories: map[string]bool{}}
+		for _, category := range strings.Split(categories, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				result.Categories[category] = true
+			}
+		}
+		response.Results[i] = result
+	}
+	return response, nil
+}
+
+// zapModerationsHandler classifies the inputs with the guard model. Like
+// embeddings.create it takes a string or an array of strings.
+func zapModerationsHandler(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+	userId, err := zapResolveUser(auth)
+	if err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+
+	var request struct {
+		Input json.RawMessage `json:"input"`
+	}
+	if err = json.Unmarshal(body, &request); err != nil {
+		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+	}
+	inputs, err := parseEmbeddingInput(request.Input)
+	if err != nil {
+		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+	}
+
+	balance, err := getUserBalance(userId)
+	if err != nil || balance <= 0 {
+		return object.BuildCloudResponse(402, nil, "insufficient balance")
+	}
+
+	owner, _, _ := strings.Cut(userId, "/")
+	response, err := moderateInputs(ctx, owner, inputs)
+	if err != nil {
+		return object.BuildCloudResponse(uint32(getUpstreamErrorResponse(classifyUpstreamError(err)).status), nil, "moderation failed: "+err.Error())
+	}
+
+	data, _ := json.Marshal(response)
+	return object.BuildCloudResponse(200, data, "")
+}
+
+// ── text.tokenize ───────────────────────────────────────────────────────
+
+// zapTokenizeHandler counts the tokens of text for model with the tiktoken
+// encoding closest to it.
+func zapTokenizeHandler(auth string, body []byte) (*zap.Message, error) {
+	if _, err := zapResolveUser(auth); err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+
+	var request struct {
+		Model string `json:"model"`
+		Text  string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+	}
+
+	tokens, err := model.GetTokenSize(request.Model, request.Text)
+	if err != nil {
+		return object.BuildCloudResponse(500, nil, "tokenize failed: "+err.Error())
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"model":  request.Model,
+		"tokens": tokens,
+	})
+	return object.BuildCloudResponse(200, data, "")
+}
+
+// ── usage.query ─────────────────────────────────────────────────────────
+
+// zapUsageQueryHandler returns the caller's per-model request and token
+// totals from the request log. from and to are RFC3339 times; from defaults
+// to zapUsageDefaultDays ago.
+func zapUsageQueryHandler(auth string, body []byte) (*zap.Message, error) {
+	userId, err := zapResolveUser(auth)
+	if err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+
+	var request struct {
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Model string `json:"model"`
+	}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &request); err != nil {
+			return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+		}
+	}
+	if request.From == "" {
+		request.From = time.Now().UTC().AddDate(0, 0, -zapUsageDefaultDays).Format(time.RFC3339)
+	}
+
+	usages, err := object.GetRequestLogUsages(&object.RequestLogFilter{
+		User:  userId,
+		Model: request.Model,
+		From:  request.From,
+		To:    request.To,
+	})
+	if err != nil {
+		return object.BuildCloudResponse(500, nil, "usage query failed: "+err.Error())
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"user": userId,
+		"from": request.From,
+		"to":   request.To,
+		"data": usages,
+	})
+	return object.BuildCloudResponse(200, data, "")
+}
@@ -0,0 +1,56 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestModerateInputs(t *testing.T) {
+	previous := guardrailModerator
+	defer func() { guardrailModerator = previous }()
+	guardrailModerator = func(ctx context.Context, org string, text string, lang string) (bool, string, error) {
+		if org != "acme" {
+			t.Errorf("org = %q, want acme", org)
+		}
+		if text == "bad" {
+			return true, "violence, hate", nil
+		}
+		return false, "", nil
+	}
+
+	response, err := moderateInputs(context.Background(), "acme", []string{"fine", "bad"})
+	if err != nil {
+		t.Fatalf("moderateInputs() error: %v", err)
+	}
+	if response.Model != getGuardrailModel() || len(response.Results) != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	if response.Results[0].Flagged || len(response.Results[0].Categories) != 0 {
+		t.Errorf("safe input result = %+v", response.Results[0])
+	}
+	if got := response.Results[1]; !got.Flagged || !got.Categories["violence"] || !got.Categories["hate"] {
+		t.Errorf("unsafe input result = %+v, want flagged for violence and hate", got)
+	}
+
+	guardrailModerator = func(ctx context.Context, org string, text string, lang string) (bool, string, error) {
+		return false, "", fmt.Errorf("guard model down")
+	}
+	if _, err = moderateInputs(context.Background(), "acme", []string{"fine"}); err == nil {
+		t.Error("moderateInputs() error = nil when the guard model fails")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luxfi/zap"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// unknownZapMethodLabel replaces unregistered method names so that callers
+// cannot grow the metric cardinality.
+const unknownZapMethodLabel = "unknown"
+
+// zapResponseStatus returns the status of a dispatched ZAP response; a
+// handler error counts as 500.
+func zapResponseStatus(msg *zap.Message, err error) uint32 {
+	if err != nil || msg == nil {
+		return 500
+	}
+	return msg.Root().Uint32(object.CloudRespStatus)
+}
+
+// zapTransport names the transport a request arrived on from its connection:
+// "ws", "node", or "http" for the connectionless batch and MCP endpoints.
+func zapTransport(ctx context.Context) string {
+	transport, _, found := strings.Cut(getZapConn(ctx), ":")
+	if !found {
+		return "http"
+	}
+	return transport
+}
+
+// zapStatusClass buckets a status code as 2xx, 4xx or 5xx.
+func zapStatusClass(status uint32) string {
+	return strconv.Itoa(int(status/100)) + "xx"
+}
+
+// observeZapMetrics exports one dispatched ZAP call to the cloud_zap_*
+// metrics.
+func observeZapMetrics(ctx context.Context, method string, status uint32, duration time.Duration) {
+	if zapMethods[method] == nil {
+		method = unknownZapMethodLabel
+	}
+	transport := zapTransport(ctx)
+
+	object.ZapRequests.WithLabelValues(method, transport, zapStatusClass(status)).Inc()
+	if status >= 400 {
+		object.ZapErrors.WithLabelValues(method, strconv.Itoa(int(status))).Inc()
+	}
+	object.ZapLatency.WithLabelValues(method, transport).Observe(duration.Seconds())
+}
@@ -0,0 +1,63 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestZapDispatchMetrics(t *testing.T) {
+	discover := object.ZapRequests.WithLabelValues("rpc.discover", "ws", "2xx")
+	unknown := object.ZapErrors.WithLabelValues(unknownZapMethodLabel, "404")
+	beforeDiscover := testutil.ToFloat64(discover)
+	beforeUnknown := testutil.ToFloat64(unknown)
+
+	ctx := withZapConn(context.Background(), "ws:test")
+	if _, err := zapDispatch(ctx, "rpc.discover", "", nil); err != nil {
+		t.Fatalf("rpc.discover error: %v", err)
+	}
+	if _, err := zapDispatch(context.Background(), "no.such.method", "", nil); err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+
+	if got := testutil.ToFloat64(discover) - beforeDiscover; got != 1 {
+		t.Errorf("rpc.discover ws 2xx count grew by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(unknown) - beforeUnknown; got != 1 {
+		t.Errorf("unknown method 404 count grew by %v, want 1", got)
+	}
+}
+
+func TestZapStreamingMetrics(t *testing.T) {
+	unauthorized := object.ZapErrors.WithLabelValues("chat.completions", "401")
+	before := testutil.ToFloat64(unauthorized)
+
+	ctx := withZapConn(context.Background(), "ws:test")
+	request := &zapEnvelope{Id: "1", Method: "chat.completions"}
+	frame := handleZapEnvelope(ctx, request, func(*openai.ChatCompletionStreamChoiceDelta) {})
+	if frame.Status != 401 {
+		t.Fatalf("status = %d, want 401", frame.Status)
+	}
+
+	if got := testutil.ToFloat64(unauthorized) - before; got != 1 {
+		t.Errorf("streaming chat 401 count grew by %v, want 1", got)
+	}
+}
@@ -28,7 +28,6 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -62,21 +61,33 @@ func handleCloudService(ctx context.Context, from string, msg *zap.Message) (*za
 	method := root.Text(object.CloudReqMethod)
 	auth := root.Text(object.CloudReqAuth)
 	body := root.Bytes(object.CloudReqBody)
+	return zapDispatch(withZapConn(ctx, "node:"+from), method, auth, body)
+}
 
-	switch method {
-	case "models.list":
-		// R-04: require auth for model listing
-		if auth == "" {
-			return object.BuildCloudResponse(401, nil, "authentication required")
-		}
-		return zapListModelsHandler()
-	case "balance":
-		return zapBalanceHandler(auth, body)
-	case "chat.completions", "chat.messages":
-		return zapChatHandler(ctx, auth, body)
-	default:
+// zapDispatch routes a native cloud method to its registered handler.
+// Shared by the ZAP node and the WebSocket, batch and MCP transports. Every
+// call is exported to the cloud_zap_* metrics, since all of these arrive on
+// a single path as far as cloud_api_latency is concerned.
+func zapDispatch(ctx context.Context, method string, auth string, body []byte) (*zap.Message, error) {
+	start := time.Now()
+	msg, err := dispatchZapMethod(ctx, method, auth, body)
+	observeZapMetrics(ctx, method, zapResponseStatus(msg, err), time.Since(start))
+	return msg, err
+}
+
+func dispatchZapMethod(ctx context.Context, method string, auth string, body []byte) (*zap.Message, error) {
+	m := zapMethods[method]
+	if m == nil {
 		return object.BuildCloudResponse(404, nil, "unknown method: "+method)
 	}
+	// R-04: require auth for model listing and every other metered method
+	if m.Auth && auth == "" {
+		return object.BuildCloudResponse(401, nil, "authentication required")
+	}
+	if err := checkZapSession(ctx, auth); err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+	return m.handler(ctx, auth, body)
 }
 
 // ── Gateway HTTP-over-ZAP (MsgType 200) ─────────────────────────────────
@@ -92,6 +103,10 @@ func handleGatewayHTTPRequest(ctx context.Context, from string, msg *zap.Message
 
 	// Extract auth from headers JSON: {"Authorization":"Bearer xxx", ...}
 	auth := extractAuthFromHeaders(root.Bytes(16))
+	if isZapSessionToken(auth) {
+		errBody, _ := json.Marshal(map[string]string{"error": "session tokens are not accepted over HTTP"})
+		return object.BuildGatewayResponse(401, errBody, nil)
+	}
 
 	switch {
 	case path == "/v1/chat" || path == "/v1/chat/completions" || path == "/v1/completions":
@@ -108,7 +123,7 @@ func handleGatewayHTTPRequest(ctx context.Context, from string, msg *zap.Message
 			})
 			return object.BuildGatewayResponse(401, errBody, nil)
 		}
-		return zapListModelsHandler()
+		return zapListModelsHandler(auth)
 	case strings.HasPrefix(path, "/v1/balance"):
 		return zapBalanceHandler(auth, body)
 	default:
@@ -155,7 +170,7 @@ func zapWriteTrace(record *usageRecord, startTime time.Time) {
 		org = record.Owner
 	}
 
-	costCents := calculateCostCentsWithCache(
+	cost := calculateUsageCost(
 		record.Model, record.PromptTokens, record.CompletionTokens,
 		record.CacheReadTokens, record.CacheWriteTokens,
 	)
@@ -170,9 +185,9 @@ func zapWriteTrace(record *usageRecord, startTime time.Time) {
 		startTime.UTC(), endTime,
 		"GENERATION", record.Model,
 		record.PromptTokens, record.CompletionTokens, record.TotalTokens,
-		float64(costCents)*float64(record.PromptTokens)/float64(max(record.TotalTokens, 1)),
-		float64(costCents)*float64(record.CompletionTokens)/float64(max(record.TotalTokens, 1)),
-		float64(costCents),
+		cost.inputCents(),
+		cost.outputCents(),
+		cost.inputCents()+cost.outputCents(),
 		fmt.Sprintf(`{"provider":"%s","organization":"%s","requestId":"%s","premium":%v,"stream":%v}`,
 			record.Provider, org, record.RequestID, record.Premium, record.Stream),
 		fmt.Sprintf(`["%s","%s","org:%s"]`, record.Model, record.Provider, org),
@@ -278,8 +293,8 @@ func zapWriteUsage(record *usageRecord, startTime time.Time) {
 
 // ── models.list ─────────────────────────────────────────────────────────
 
-func zapListModelsHandler() (*zap.Message, error) {
-	models := listAvailableModels()
+func zapListModelsHandler(auth string) (*zap.Message, error) {
+	models := listModelsForOrg(getCatalogOrgForToken(strings.TrimPrefix(auth, "Bearer "), ""))
 	data, _ := json.Marshal(map[string]interface{}{
 		"object": "list",
 		"data":   models,
@@ -321,19 +336,35 @@ func zapBalanceHandler(auth string, body []byte) (*zap.Message, error) {
 // ── chat.completions / chat.messages ────────────────────────────────────
 
 func zapChatHandler(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+	status, data, errMsg := zapChat(ctx, auth, body, nil)
+	return object.BuildCloudResponse(status, data, errMsg)
+}
+
+// zapChat runs a chat completion for any ZAP transport and returns the
+// response status, body and error message. When onDelta is non-nil and the
+// request asks for streaming, each content delta is passed to onDelta as the
+// provider produces it; the returned body is still the complete response.
+func zapChat(ctx context.Context, auth string, body []byte, onDelta func(delta *openai.ChatCompletionStreamChoiceDelta)) (uint32, []byte, string) {
 	if auth == "" {
-		return object.BuildCloudResponse(401, nil, "auth token required")
+		return 401, nil, "auth token required"
 	}
 
 	var request openai.ChatCompletionRequest
 	if err := json.Unmarshal(body, &request); err != nil {
-		return object.BuildCloudResponse(400, nil, "invalid request: "+err.Error())
+		return 400, nil, "invalid request: " + err.Error()
 	}
 
 	// Auth → provider + user + upstream model.
 	provider, authUser, upstreamModel, err := zapResolveAuth(auth, request.Model)
 	if err != nil {
-		return object.BuildCloudResponse(401, nil, err.Error())
+		return 401, nil, err.Error()
+	}
+
+	// Record and bill the org's own name for a zen model as that model; the
+	// caller still sees its own name.
+	brandedModel := request.Model
+	if authUser != nil {
+		request.Model = resolveBrandedModel(request.Model, authUser.Owner)
 	}
 
 	// Balance gate for premium models.
@@ -344,13 +375,42 @@ func zapChatHandler(ctx context.Context, auth string, body []byte) (*zap.Message
 			userId := authUser.Owner + "/" + authUser.Name
 			balance, balErr := getUserBalance(userId)
 			if balErr != nil || balance <= 0 {
-				return object.BuildCloudResponse(402, nil, "insufficient balance for premium model")
+				return 402, nil, "insufficient balance for premium model"
 			}
 		}
 	}
 
+	// Tenant quotas and member spend limits.
+	if authUser != nil {
+		if quota := checkTenantQuota(authUser.Owner); quota.Exceeded != "" {
+			return 429, nil, quotaExceededMessage(quota)
+		}
+		if err := checkMemberSpend(authUser); err != nil {
+			return 429, nil, err.Error()
+		}
+	}
+
+	// Data residency, of the caller's org or of the org whose provider key
+	// authenticated the request.
+	residencyOrg, caller := provider.Owner, ""
+	if authUser != nil {
+		residencyOrg, caller = authUser.Owner, authUser.Owner+"/"+authUser.Name
+	}
+	if residencyOrg != "admin" {
+		route, err := applyTenantResidency(request.Model, resolveModelRouteForOrg(request.Model, residencyOrg), residencyOrg, caller)
+		if err == nil && route == nil {
+			err = checkProviderResidency(request.Model, provider, residencyOrg)
+		}
+		if err != nil {
+			return 403, nil, err.Error()
+		}
+		if provider, upstreamModel, err = resolveResidentProvider(route, residencyOrg, caller, provider, upstreamModel); err != nil {
+			return 502, nil, "provider init failed: " + err.Error()
+		}
+	}
+
 	// KMS secrets.
-	if err := object.ResolveProviderSecret(provider); err != nil {
+	if err := object.ResolveProviderSecretAs(provider, caller); err != nil {
 		logs.Error("ZAP: KMS resolve %s: %v", provider.Name, err)
 	}
 
@@ -363,22 +423,20 @@ func zapChatHandler(ctx context.Context, auth string, body []byte) (*zap.Message
 
 	modelProvider, err := provider.GetModelProvider("en")
 	if err != nil {
-		return object.BuildCloudResponse(502, nil, "provider init failed: "+err.Error())
-	}
-
-	// Inject Zen identity for zen-branded models.
-	if zenPrompt := zenIdentityPrompt(request.Model); zenPrompt != "" {
-		hasSystem := len(request.Messages) > 0 && request.Messages[0].Role == "system"
-		if hasSystem {
-			request.Messages[0].Content = zenPrompt + "\n\n" + request.Messages[0].Content
-		} else {
-			request.Messages = append([]openai.ChatCompletionMessage{{
-				Role:    "system",
-				Content: zenPrompt,
-			}}, request.Messages...)
-		}
+		return 502, nil, "provider init failed: " + err.Error()
 	}
 
+	// Inject Zen identity for zen-branded models, with the caller org's branding.
+	org := ""
+	if authUser != nil {
+		org = authUser.Owner
+	}
+	identityMode, err := getZenIdentityMode(body, org)
+	if err != nil {
+		return 400, nil, err.Error()
+	}
+	request.Messages = injectZenIdentity(request.Messages, zenIdentityPrompt(brandedModel, org), identityMode)
+
 	// Extract question + history from messages.
 	var question string
 	var systemPrompt string
@@ -399,42 +457,53 @@ func zapChatHandler(ctx context.Context, auth string, body []byte) (*zap.Message
 	}
 
 	if question == "" {
-		return object.BuildCloudResponse(400, nil, "no user message found")
+		return 400, nil, "no user message found"
 	}
 
 	if systemPrompt != "" {
 		question = fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, question)
 	}
 
-	// Call the model provider. Use a buffer — no HTTP writer.
+	// Call the model provider. No HTTP writer: the stream writer collects the
+	// answer and forwards deltas when the transport can stream them.
+	stream := request.Stream && onDelta != nil
 	requestStartTime := time.Now().UTC()
 	requestId := util.GenerateUUID()
-	var buf bytes.Buffer
+	writer := &zapStreamWriter{}
+	if stream {
+		writer.onDelta = onDelta
+	}
 
-	modelResult, err := modelProvider.QueryText(question, &buf, history, "", nil, nil, "en")
-	if err != nil {
+	modelResult, err := modelProvider.QueryText(ctx, question, writer, history, "", nil, nil, "en")
+	disconnected := clientDisconnected(ctx, modelResult, err)
+	if err != nil && !disconnected {
+		errorClass := classifyUpstreamError(err)
 		if authUser != nil {
-			go recordUsage(&usageRecord{
-				User:      authUser.Owner + "/" + authUser.Name,
-				Model:     request.Model,
-				Provider:  provider.Name,
-				Premium:   isPremium,
-				Stream:    false,
-				Status:    "error",
-				ErrorMsg:  err.Error(),
-				RequestID: requestId,
-			})
+			record := &usageRecord{
+				User:       authUser.Owner + "/" + authUser.Name,
+				Model:      request.Model,
+				Provider:   provider.Name,
+				Premium:    isPremium,
+				Stream:     stream,
+				Status:     "error",
+				ErrorMsg:   err.Error(),
+				ErrorClass: errorClass,
+				RequestID:  requestId,
+				LatencyMs:  time.Since(requestStartTime).Milliseconds(),
+				Prompt:     question,
+			}
+			recordUsage(record)
 		}
-		return object.BuildCloudResponse(502, nil, "provider error: "+err.Error())
+		return uint32(getUpstreamErrorResponse(errorClass).status), nil, "provider error: " + err.Error()
 	}
 
 	// Build response.
-	answer := buf.String()
+	answer := writer.MessageString()
 	response := openai.ChatCompletionResponse{
 		ID:      "chatcmpl-" + requestId,
 		Object:  "chat.completion",
 		Created: util.GetCurrentUnixTime(),
-		Model:   request.Model,
+		Model:   brandedModel,
 		Choices: []openai.ChatCompletionChoice{
 			{
 				Index: 0,
@@ -453,65 +522,57 @@ func zapChatHandler(ctx context.Context, auth string, body []byte) (*zap.Message
 	}
 	data, _ := json.Marshal(response)
 
-	// Record billing.
+	// Record billing, including the part generated before the caller went away.
 	if authUser != nil {
-		go func() {
-			record := &usageRecord{
-				Owner:            authUser.Owner,
-				User:             authUser.Owner + "/" + authUser.Name,
-				Organization:     authUser.Owner,
-				Model:            request.Model,
-				Provider:         provider.Name,
-				PromptTokens:     modelResult.PromptTokenCount,
-				CompletionTokens: modelResult.ResponseTokenCount,
-				TotalTokens:      modelResult.TotalTokenCount,
-				Currency:         "USD",
-				Premium:          isPremium,
-				Stream:           false,
-				Status:           "success",
-				RequestID:        requestId,
-			}
-			recordUsage(record)
-			recordTrace(record, requestStartTime)
-		}()
+		record := &usageRecord{
+			Owner:            authUser.Owner,
+			User:             authUser.Owner + "/" + authUser.Name,
+			Organization:     authUser.Owner,
+			Model:            request.Model,
+			Provider:         provider.Name,
+			PromptTokens:     modelResult.PromptTokenCount,
+			CompletionTokens: modelResult.ResponseTokenCount,
+			TotalTokens:      modelResult.TotalTokenCount,
+			Currency:         "USD",
+			Premium:          isPremium,
+			Stream:           stream,
+			Status:           "success",
+			RequestID:        requestId,
+			LatencyMs:        time.Since(requestStartTime).Milliseconds(),
+			Prompt:           question,
+			Response:         answer,
+		}
+		if disconnected {
+			record.ErrorMsg = "client disconnected: " + err.Error()
+		}
+		recordUsage(record)
+		recordTrace(record, requestStartTime)
 	}
 
-	return object.BuildCloudResponse(200, data, "")
+	return 200, data, ""
 }
 
 // ── Auth helpers ────────────────────────────────────────────────────────
 
 func zapResolveUser(auth string) (string, error) {
-	if auth == "" {
-		return "", fmt.Errorf("auth token required")
+	if user := getZapSessionUser(auth); user != nil {
+		return user.Owner + "/" + user.Name, nil
 	}
-	token := strings.TrimPrefix(auth, "Bearer ")
-
-	if isIAMApiKey(token) {
-		user, err := getUserByAccessKey(token)
-		if err != nil {
-			return "", fmt.Errorf("invalid API key: %w", err)
-		}
-		if user != nil {
-			return user.Owner + "/" + user.Name, nil
-		}
-	}
-
-	if isJwtToken(token) {
-		claims, err := iamsdk.ParseJwtToken(token)
-		if err == nil && claims != nil {
-			return claims.Owner + "/" + claims.Name, nil
-		}
+	user, err := zapAuthenticate(auth)
+	if err != nil {
+		return "", err
 	}
-
-	return "", fmt.Errorf("unsupported auth type")
+	return user.Owner + "/" + user.Name, nil
 }
 
 func zapResolveAuth(auth string, requestModel string) (*object.Provider, *iamsdk.User, string, error) {
 	token := strings.TrimPrefix(auth, "Bearer ")
 
+	if user := getZapSessionUser(token); user != nil {
+		return resolveProviderForUser(user, requestModel, "en")
+	}
 	if isIAMApiKey(token) {
-		return resolveProviderFromIAMKey(token, requestModel, "en")
+		return resolveProviderFromIAMKey(token, "", requestModel, "en")
 	}
 	if isJwtToken(token) {
 		return resolveProviderFromJwt(token, requestModel, "en")
@@ -0,0 +1,217 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/luxfi/zap"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+// zapProtocolVersion is reported by rpc.discover.
+const zapProtocolVersion = "1"
+
+// zapMethod describes a native ZAP method. Every method is registered here,
+// so dispatch and rpc.discover cannot drift apart.
+type zapMethod struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Auth         bool                   `json:"auth"`
+	Streaming    bool                   `json:"streaming"`
+	Subscription bool                   `json:"subscription"`     // delivers server-initiated events; WebSocket transport only
+	Params       map[string]interface{} `json:"params,omitempty"` // JSON Schema of the request body
+	handler      func(ctx context.Context, auth string, body []byte) (*zap.Message, error)
+}
+
+var zapMethods = map[string]*zapMethod{}
+
+func registerZapMethod(method *zapMethod) {
+	zapMethods[method.Name] = method
+}
+
+// zapSchema builds a JSON Schema object with the given properties.
+func zapSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func zapStringSchema(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func init() {
+	chatParams := zapSchema(map[string]interface{}{
+		"model": zapStringSchema("model id from models.list"),
+		"messages": map[string]interface{}{
+			"type": "array",
+			"items": zapSchema(map[string]interface{}{
+				"role":    map[string]interface{}{"type": "string", "enum": []string{"system", "user", "assistant"}},
+				"content": zapStringSchema("message text"),
+			}, "role", "content"),
+		},
+		"stream": map[string]interface{}{"type": "boolean", "description": "stream delta frames (WebSocket transport only)"},
+	}, "model", "messages")
+
+	registerZapMethod(&zapMethod{
+		Name:        "rpc.discover",
+		Description: "Describe the available methods",
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapDiscoverHandler()
+		},
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "auth.login",
+		Description: "Exchange an API key or JWT for a session token bound to this connection",
+		Auth:        true,
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapLoginHandler(ctx, auth)
+		},
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "models.list",
+		Description: "List the available models",
+		Auth:        true,
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapListModelsHandler(auth)
+		},
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "balance",
+		Description: "Get the balance of the caller or of user",
+		Auth:        true,
+		Params: zapSchema(map[string]interface{}{
+			"user": zapStringSchema("owner/name; defaults to the caller"),
+		}),
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapBalanceHandler(auth, body)
+		},
+	})
+	for _, name := range []string{"chat.completions", "chat.messages"} {
+		registerZapMethod(&zapMethod{
+			Name:        name,
+			Description: "Create a chat completion",
+			Auth:        true,
+			Streaming:   true,
+			Params:      chatParams,
+			handler:     zapChatHandler,
+		})
+	}
+	registerZapMethod(&zapMethod{
+		Name:        "embeddings.create",
+		Description: "Embed one or more inputs with the default embedding provider",
+		Auth:        true,
+		Params: zapSchema(map[string]interface{}{
+			"model": zapStringSchema("ignored; the response reports the provider model"),
+			"input": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": zapEmbeddingsMaxInputs},
+				},
+			},
+		}, "input"),
+		handler: zapEmbeddingsHandler,
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "moderations.create",
+		Description: "Classify input for policy violations with the guard model",
+		Auth:        true,
+		Params: zapSchema(map[string]interface{}{
+			"input": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": zapEmbeddingsMaxInputs},
+				},
+			},
+		}, "input"),
+		handler: zapModerationsHandler,
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "text.tokenize",
+		Description: "Count the tokens of text for a model",
+		Auth:        true,
+		Params: zapSchema(map[string]interface{}{
+			"model": zapStringSchema("model id"),
+			"text":  zapStringSchema("text to tokenize"),
+		}, "text"),
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapTokenizeHandler(auth, body)
+		},
+	})
+	registerZapMethod(&zapMethod{
+		Name:         "events.subscribe",
+		Description:  "Receive platform events (WebSocket transport only)",
+		Auth:         true,
+		Subscription: true,
+		Params: zapSchema(map[string]interface{}{
+			"events": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string", "enum": zapEventTypes},
+				"description": "event types to receive; defaults to all",
+			},
+		}),
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return object.BuildCloudResponse(400, nil, "events.subscribe requires the WebSocket transport")
+		},
+	})
+	registerZapMethod(&zapMethod{
+		Name:        "usage.query",
+		Description: "Get the caller's per-model request and token totals",
+		Auth:        true,
+		Params: zapSchema(map[string]interface{}{
+			"from":  map[string]interface{}{"type": "string", "format": "date-time"},
+			"to":    map[string]interface{}{"type": "string", "format": "date-time"},
+			"model": zapStringSchema("only this model"),
+		}),
+		handler: func(ctx context.Context, auth string, body []byte) (*zap.Message, error) {
+			return zapUsageQueryHandler(auth, body)
+		},
+	})
+}
+
+// getZapMethods returns the registered methods sorted by name.
+func getZapMethods() []*zapMethod {
+	methods := make([]*zapMethod, 0, len(zapMethods))
+	for _, method := range zapMethods {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+	return methods
+}
+
+// ── rpc.discover ────────────────────────────────────────────────────────
+
+func zapDiscoverHandler() (*zap.Message, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"protocol": "zap",
+		"version":  zapProtocolVersion,
+		"methods":  getZapMethods(),
+	})
+	if err != nil {
+		return object.BuildCloudResponse(500, nil, err.Error())
+	}
+	return object.BuildCloudResponse(200, data, "")
+}
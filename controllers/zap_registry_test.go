@@ -0,0 +1,66 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hanzoai/cloud/object"
+)
+
+func TestZapDiscoverListsRegisteredMethods(t *testing.T) {
+	msg, err := zapDispatch(context.Background(), "rpc.discover", "", nil)
+	if err != nil {
+		t.Fatalf("rpc.discover error: %v", err)
+	}
+	root := msg.Root()
+	if status := root.Uint32(object.CloudRespStatus); status != 200 {
+		t.Fatalf("rpc.discover status = %d, error = %s", status, root.Text(object.CloudRespError))
+	}
+
+	var discovery struct {
+		Methods []*zapMethod `json:"methods"`
+	}
+	if err = json.Unmarshal(root.Bytes(object.CloudRespBody), &discovery); err != nil {
+		t.Fatalf("invalid rpc.discover body: %v", err)
+	}
+	if len(discovery.Methods) != len(zapMethods) {
+		t.Fatalf("rpc.discover listed %d methods, %d registered", len(discovery.Methods), len(zapMethods))
+	}
+	for i, method := range discovery.Methods {
+		if i > 0 && discovery.Methods[i-1].Name >= method.Name {
+			t.Errorf("methods not sorted: %s before %s", discovery.Methods[i-1].Name, method.Name)
+		}
+		if zapMethods[method.Name] == nil || zapMethods[method.Name].handler == nil {
+			t.Errorf("discovered method %s has no handler", method.Name)
+		}
+	}
+	if !zapMethods["chat.completions"].Streaming || zapMethods["models.list"].Streaming {
+		t.Error("streaming flags do not match the chat methods")
+	}
+}
+
+func TestZapDispatchRequiresAuth(t *testing.T) {
+	msg, _ := zapDispatch(context.Background(), "usage.query", "", nil)
+	if status := msg.Root().Uint32(object.CloudRespStatus); status != 401 {
+		t.Errorf("usage.query without auth status = %d, want 401", status)
+	}
+	msg, _ = zapDispatch(context.Background(), "no.such.method", "", nil)
+	if status := msg.Root().Uint32(object.CloudRespStatus); status != 404 {
+		t.Errorf("unknown method status = %d, want 404", status)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"github.com/luxfi/zap"
+)
+
+const (
+	// zapSessionPrefix marks session tokens issued by auth.login.
+	zapSessionPrefix = "zs-"
+
+	// defaultZapSessionTTL applies when zapSessionTtlMinutes is unset.
+	defaultZapSessionTTL = 15 * time.Minute
+)
+
+// zapConnKey is the context key of the connection a ZAP request arrived on:
+// "ws:<uuid>" for a WebSocket or "node:<peer>" for a native ZAP peer.
+// Transports without a connection (batch POST, HTTP-over-ZAP) leave it unset.
+type zapConnKey struct{}
+
+func withZapConn(ctx context.Context, conn string) context.Context {
+	return context.WithValue(ctx, zapConnKey{}, conn)
+}
+
+func getZapConn(ctx context.Context) string {
+	conn, _ := ctx.Value(zapConnKey{}).(string)
+	return conn
+}
+
+// zapSession is the identity auth.login resolved, valid only on the
+// connection that logged in.
+type zapSession struct {
+	user      *iamsdk.User
+	userId    string
+	conn      string
+	expiresAt time.Time
+}
+
+type zapSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*zapSession
+}
+
+var zapSessions = &zapSessionStore{sessions: map[string]*zapSession{}}
+
+func getZapSessionTTL() time.Duration {
+	if minutes := conf.GetConfigInt("zapSessionTtlMinutes"); minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultZapSessionTTL
+}
+
+func isZapSessionToken(auth string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(auth, "Bearer "), zapSessionPrefix)
+}
+
+// create issues a session token for user bound to conn.
+func (s *zapSessionStore) create(user *iamsdk.User, conn string, now time.Time) (string, *zapSession) {
+	token := zapSessionPrefix + util.GenerateUUID()
+	session := &zapSession{
+		user:      user,
+		userId:    user.Owner + "/" + user.Name,
+		conn:      conn,
+		expiresAt: now.Add(getZapSessionTTL()),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, existing := range s.sessions {
+		if now.After(existing.expiresAt) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session
+	return token, session
+}
+
+// get returns the live session of auth, or nil when the token is unknown,
+// expired, or was issued on a different connection.
+func (s *zapSessionStore) get(auth string, conn string, now time.Time) *zapSession {
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.sessions[token]
+	if session == nil {
+		return nil
+	}
+	if now.After(session.expiresAt) {
+		delete(s.sessions, token)
+		return nil
+	}
+	if conn == "" || session.conn != conn {
+		return nil
+	}
+	return session
+}
+
+// closeConn drops the sessions of a closed connection.
+func (s *zapSessionStore) closeConn(conn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, session := range s.sessions {
+		if session.conn == conn {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// checkZapSession rejects a session token that is not valid on the
+// request's connection. Other credentials pass through unchanged.
+func checkZapSession(ctx context.Context, auth string) error {
+	if !isZapSessionToken(auth) {
+		return nil
+	}
+	if zapSessions.get(auth, getZapConn(ctx), time.Now()) == nil {
+		return fmt.Errorf("session token is invalid, expired, or bound to another connection")
+	}
+	return nil
+}
+
+// getZapSessionUser returns the user of a session token. Callers must have
+// passed the token through checkZapSession on the request's connection.
+func getZapSessionUser(auth string) *iamsdk.User {
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	zapSessions.mu.Lock()
+	defer zapSessions.mu.Unlock()
+	if session := zapSessions.sessions[token]; session != nil && time.Now().Before(session.expiresAt) {
+		return session.user
+	}
+	return nil
+}
+
+// zapAuthenticate validates an IAM API key or JWT and returns its user.
+func zapAuthenticate(auth string) (*iamsdk.User, error) {
+	if auth == "" {
+		return nil, fmt.Errorf("auth token required")
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	if isIAMApiKey(token) {
+		user, err := getUserByAccessKey(token, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid API key: %w", err)
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+
+	if isJwtToken(token) {
+		claims, err := validateJwtToken(token)
+		if err == nil && claims != nil {
+			return &claims.User, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported auth type")
+}
+
+// ── auth.login ──────────────────────────────────────────────────────────
+
+// zapLoginHandler validates the credential once and returns a session token
+// that later requests on the same connection can send instead, skipping the
+// per-message IAM lookup.
+func zapLoginHandler(ctx context.Context, auth string) (*zap.Message, error) {
+	conn := getZapConn(ctx)
+	if conn == "" {
+		return object.BuildCloudResponse(400, nil, "auth.login requires a WebSocket or native ZAP connection")
+	}
+	if isZapSessionToken(auth) {
+		return object.BuildCloudResponse(400, nil, "auth.login requires an API key or JWT")
+	}
+
+	user, err := zapAuthenticate(auth)
+	if err != nil {
+		return object.BuildCloudResponse(401, nil, err.Error())
+	}
+
+	token, session := zapSessions.create(user, conn, time.Now())
+	data, _ := json.Marshal(map[string]interface{}{
+		"token":     token,
+		"user":      session.userId,
+		"expiresAt": session.expiresAt.UTC().Format(time.RFC3339),
+	})
+	return object.BuildCloudResponse(200, data, "")
+}
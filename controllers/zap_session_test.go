@@ -0,0 +1,63 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func TestZapSessionBoundToConnection(t *testing.T) {
+	store := &zapSessionStore{sessions: map[string]*zapSession{}}
+	now := time.Now()
+	token, session := store.create(&iamsdk.User{Owner: "hanzo", Name: "alice"}, "ws:1", now)
+
+	if !isZapSessionToken("Bearer " + token) {
+		t.Fatalf("token %q not recognized as a session token", token)
+	}
+	if session.userId != "hanzo/alice" {
+		t.Errorf("userId = %q, want hanzo/alice", session.userId)
+	}
+	if store.get("Bearer "+token, "ws:1", now) == nil {
+		t.Error("expected session valid on its own connection")
+	}
+	if store.get(token, "ws:2", now) != nil {
+		t.Error("expected session rejected on another connection")
+	}
+	if store.get(token, "", now) != nil {
+		t.Error("expected session rejected without a connection")
+	}
+	if store.get(token, "ws:1", session.expiresAt.Add(time.Second)) != nil {
+		t.Error("expected expired session rejected")
+	}
+
+	token, _ = store.create(&iamsdk.User{Owner: "hanzo", Name: "alice"}, "ws:1", now)
+	store.closeConn("ws:1")
+	if store.get(token, "ws:1", now) != nil {
+		t.Error("expected session dropped when its connection closed")
+	}
+}
+
+func TestCheckZapSessionPassesOtherCredentials(t *testing.T) {
+	if err := checkZapSession(context.Background(), "Bearer hk-123"); err != nil {
+		t.Errorf("unexpected error for API key: %v", err)
+	}
+	if err := checkZapSession(withZapConn(context.Background(), "ws:1"), "zs-unknown"); err == nil {
+		t.Error("expected error for unknown session token")
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ZAP over WebSocket. Same methods as the native ZAP node (see zapDispatch),
+// carried as JSON text frames for clients that cannot open a raw ZAP
+// connection.
+//
+// Request frame:  {"id":"1","method":"chat.completions","auth":"Bearer ...","body":{...}}
+// Delta frame:    {"id":"1","stream":true,"delta":{"content":"Hel"}}
+// Result frame:   {"id":"1","status":200,"result":{...}}
+// Error frame:    {"id":"1","status":502,"error":{"code":502,"message":"..."}}
+// Event frame:    {"id":"2","event":{"type":"balance.low","time":"...","data":{...}}}
+//
+// Requests on one connection run concurrently and are matched by id. A
+// streaming chat request (body.stream = true) receives delta frames as the
+// provider produces them, then exactly one result frame carrying the full
+// chat.completion response. Frame auth falls back to the Authorization
+// header of the upgrade request, and each request frame counts against the
+// same rate limits as an HTTP API request. An events.subscribe request is
+// answered with a result frame, then receives event frames under its id
+// until the connection closes.
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/gorilla/websocket"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/util"
+)
+
+const (
+	// zapWsMaxFrameSize bounds a single request frame.
+	zapWsMaxFrameSize = 4 << 20
+
+	// zapMaxInflight is the most concurrent requests per connection or batch.
+	zapMaxInflight = 8
+
+	// zapWsWriteTimeout bounds a single frame write.
+	zapWsWriteTimeout = 10 * time.Second
+)
+
+// zapWsUpgrader accepts upgrades without an Origin header (non-browser
+// clients), from the server's own origin, and from the origins the CORS
+// allowlist trusts.
+var zapWsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     checkZapOrigin,
+}
+
+var (
+	// zapOriginAllowed reports whether a cross-site origin may open a
+	// connection. Set by the routers package from its CORS allowlist; nil
+	// allows only same-origin connections.
+	zapOriginAllowed func(origin string) bool
+
+	// zapRateLimit counts one request frame of the given credentials and
+	// client IP against the API rate limits, returning whether it is allowed
+	// and, when not, the seconds until it would be. Set by the routers
+	// package; nil disables per-frame limits.
+	zapRateLimit func(auth string, clientIP string) (bool, int)
+)
+
+// SetZapOriginCheck installs the origin allowlist of ZAP WebSocket upgrades.
+func SetZapOriginCheck(allowed func(origin string) bool) {
+	zapOriginAllowed = allowed
+}
+
+// SetZapRateLimit installs the rate limit applied to each ZAP WebSocket
+// request frame, so a single upgraded connection is held to the same limits
+// as the HTTP API.
+func SetZapRateLimit(limit func(auth string, clientIP string) (bool, int)) {
+	zapRateLimit = limit
+}
+
+// checkZapOrigin is the CheckOrigin of zapWsUpgrader.
+func checkZapOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return zapOriginAllowed != nil && zapOriginAllowed(origin)
+}
+
+// zapEnvelope is one ZAP request, sent as a WebSocket frame or a batch
+// element.
+type zapEnvelope struct {
+	Id     string          `json:"id"`
+	Method string          `json:"method"`
+	Auth   string          `json:"auth,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// zapFrame is a ZAP response envelope: a delta when Stream is set,
+// otherwise the final outcome for Id, carrying either Result or Error.
+type zapFrame struct {
+	Id     string                                  `json:"id"`
+	Stream bool                                    `json:"stream,omitempty"`
+	Delta  *openai.ChatCompletionStreamChoiceDelta `json:"delta,omitempty"`
+	Event  *zapEvent                               `json:"event,omitempty"`
+	Status uint32                                  `json:"status,omitempty"`
+	Result json.RawMessage                         `json:"result,omitempty"`
+	Error  *zapError                               `json:"error,omitempty"`
+}
+
+// zapError is the error of a failed ZAP request. Code repeats the status.
+type zapError struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
+}
+
+// newZapErrorFrame returns the error envelope for id.
+func newZapErrorFrame(id string, status uint32, message string) *zapFrame {
+	return &zapFrame{Id: id, Status: status, Error: &zapError{Code: status, Message: message}}
+}
+
+// newZapResultFrame wraps a handler's status, body and error message in the
+// envelope for id. Error statuses become Error, using the body when the
+// handler gave no message; a body that is not JSON is wrapped as a string.
+func newZapResultFrame(id string, status uint32, body []byte, errMsg string) *zapFrame {
+	if status >= 400 {
+		if errMsg == "" {
+			errMsg = string(body)
+		}
+		return newZapErrorFrame(id, status, errMsg)
+	}
+
+	frame := &zapFrame{Id: id, Status: status, Result: body}
+	if len(body) > 0 && !json.Valid(body) {
+		frame.Result, _ = json.Marshal(string(body))
+	}
+	return frame
+}
+
+// zapWsConn serializes frame writes from concurrent requests.
+type zapWsConn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *zapWsConn) writeFrame(frame *zapFrame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(zapWsWriteTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// ZapWebSocket
+// @Title ZapWebSocket
+// @Tag ZAP API
+// @Description ZAP methods over WebSocket with native streaming frames
+// @Success 101 switching protocols
+// @router /zap [get]
+func (c *ApiController) ZapWebSocket() {
+	c.EnableRender = false
+
+	ws, err := zapWsUpgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
+	if err != nil {
+		logs.Error("ZAP ws: upgrade failed: %v", err)
+		return
+	}
+	defer ws.Close()
+	ws.SetReadLimit(zapWsMaxFrameSize)
+
+	conn := &zapWsConn{ws: ws}
+	defaultAuth := c.Ctx.Request.Header.Get("Authorization")
+	clientIP := util.GetClientIP(c.Ctx.Request)
+
+	connId := "ws:" + util.GenerateUUID()
+	defer zapSessions.closeConn(connId)
+	ctx, cancel := context.WithCancel(withZapConn(context.Background(), connId))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	inflight := make(chan struct{}, zapMaxInflight)
+
+	var subs []*zapSubscription
+	defer func() {
+		for _, sub := range subs {
+			zapEvents.unsubscribe(sub)
+		}
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logs.Warning("ZAP ws: read failed: %v", err)
+			}
+			return
+		}
+
+		var request zapEnvelope
+		if err = json.Unmarshal(data, &request); err != nil {
+			_ = conn.writeFrame(newZapErrorFrame("", 400, "invalid frame: "+err.Error()))
+			continue
+		}
+		if request.Auth == "" {
+			request.Auth = defaultAuth
+		}
+		if err = checkZapSession(ctx, request.Auth); err != nil {
+			_ = conn.writeFrame(newZapErrorFrame(request.Id, 401, err.Error()))
+			continue
+		}
+		if frame := zapRateLimitFrame(&request, clientIP); frame != nil {
+			_ = conn.writeFrame(frame)
+			continue
+		}
+
+		if method := zapMethods[request.Method]; method != nil && method.Subscription {
+			if len(subs) >= zapMaxInflight {
+				_ = conn.writeFrame(newZapErrorFrame(request.Id, 429, fmt.Sprintf("too many subscriptions (max %d)", zapMaxInflight)))
+				continue
+			}
+			sub, frame := subscribeZapEvents(conn, &request)
+			if sub != nil {
+				subs = append(subs, sub)
+			}
+			_ = conn.writeFrame(frame)
+			continue
+		}
+
+		select {
+		case inflight <- struct{}{}:
+		default:
+			_ = conn.writeFrame(newZapErrorFrame(request.Id, 429, fmt.Sprintf("too many concurrent requests (max %d)", zapMaxInflight)))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			onDelta := func(delta *openai.ChatCompletionStreamChoiceDelta) {
+				if err := conn.writeFrame(&zapFrame{Id: request.Id, Stream: true, Delta: delta}); err != nil {
+					logs.Warning("ZAP ws: failed to write delta id=%s: %v", request.Id, err)
+				}
+			}
+			if err := conn.writeFrame(handleZapEnvelope(ctx, &request, onDelta)); err != nil {
+				logs.Warning("ZAP ws: failed to write result id=%s: %v", request.Id, err)
+			}
+		}()
+	}
+}
+
+// zapRateLimitFrame counts request against the API rate limits of its
+// credentials and returns the 429 frame answering it when they are
+// exhausted, or nil when it may run.
+func zapRateLimitFrame(request *zapEnvelope, clientIP string) *zapFrame {
+	if zapRateLimit == nil {
+		return nil
+	}
+	if allowed, retryAfter := zapRateLimit(request.Auth, clientIP); !allowed {
+		return newZapErrorFrame(request.Id, 429, fmt.Sprintf("Rate limit exceeded. Retry after %d seconds.", retryAfter))
+	}
+	return nil
+}
+
+// handleZapEnvelope runs one request and returns its result frame. Streaming
+// chat requests pass their deltas to onDelta before returning; a nil onDelta
+// disables streaming.
+func handleZapEnvelope(ctx context.Context, request *zapEnvelope, onDelta func(delta *openai.ChatCompletionStreamChoiceDelta)) *zapFrame {
+	if method := zapMethods[request.Method]; method != nil && method.Streaming && onDelta != nil {
+		// Streaming bypasses zapDispatch, so it records its own metrics.
+		start := time.Now()
+		if request.Auth == "" {
+			observeZapMetrics(ctx, request.Method, 401, time.Since(start))
+			return newZapErrorFrame(request.Id, 401, "authentication required")
+		}
+		status, data, errMsg := zapChat(ctx, request.Auth, request.Body, onDelta)
+		observeZapMetrics(ctx, request.Method, status, time.Since(start))
+		return newZapResultFrame(request.Id, status, data, errMsg)
+	}
+
+	msg, err := zapDispatch(ctx, request.Method, request.Auth, request.Body)
+	if err != nil {
+		return newZapErrorFrame(request.Id, 500, err.Error())
+	}
+	root := msg.Root()
+	return newZapResultFrame(request.Id, root.Uint32(object.CloudRespStatus), root.Bytes(object.CloudRespBody), root.Text(object.CloudRespError))
+}
+
+// zapStreamWriter is the provider writer for ZAP transports. It decodes the
+// provider's "event: <type>\ndata: <text>\n\n" frames, collects the message
+// text, and forwards message and reasoning deltas to onDelta when set.
+type zapStreamWriter struct {
+	onDelta    func(delta *openai.ChatCompletionStreamChoiceDelta)
+	messageBuf strings.Builder
+}
+
+func (w *zapStreamWriter) Write(p []byte) (int, error) {
+	event, data := "message", string(p)
+	if bytes.HasPrefix(p, []byte("event: ")) {
+		header, rest, found := strings.Cut(string(p), "\n")
+		if !found || !strings.HasPrefix(rest, "data: ") {
+			return len(p), nil
+		}
+		event = strings.TrimPrefix(header, "event: ")
+		data = strings.TrimSuffix(strings.TrimPrefix(rest, "data: "), "\n\n")
+	}
+	if data == "" {
+		return len(p), nil
+	}
+
+	var delta *openai.ChatCompletionStreamChoiceDelta
+	switch event {
+	case "message":
+		w.messageBuf.WriteString(data)
+		delta = &openai.ChatCompletionStreamChoiceDelta{Content: data}
+	case "reason":
+		delta = &openai.ChatCompletionStreamChoiceDelta{ReasoningContent: data}
+	default:
+		// Tool and search events have no ZAP delta representation.
+		return len(p), nil
+	}
+
+	if w.onDelta != nil {
+		w.onDelta(delta)
+	}
+	return len(p), nil
+}
+
+// Flush satisfies http.Flusher, which providers require of their writer.
+// Deltas are forwarded as they are written, so there is nothing to flush.
+func (w *zapStreamWriter) Flush() {}
+
+// MessageString returns the collected message text.
+func (w *zapStreamWriter) MessageString() string {
+	return w.messageBuf.String()
+}
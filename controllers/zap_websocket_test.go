@@ -0,0 +1,126 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestZapStreamWriterDeltas(t *testing.T) {
+	var deltas []*openai.ChatCompletionStreamChoiceDelta
+	w := &zapStreamWriter{onDelta: func(delta *openai.ChatCompletionStreamChoiceDelta) {
+		deltas = append(deltas, delta)
+	}}
+
+	for _, chunk := range []string{
+		"event: reason\ndata: thinking\n\n",
+		"event: message\ndata: Hel\n\n",
+		"event: search\ndata: {\"results\":[]}\n\n",
+		"event: message\ndata: lo\n\n",
+		"!",
+	} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+
+	if got := w.MessageString(); got != "Hello!" {
+		t.Errorf("MessageString() = %q, want %q", got, "Hello!")
+	}
+	data, _ := json.Marshal(deltas)
+	want := `[{"reasoning_content":"thinking"},{"content":"Hel"},{"content":"lo"},{"content":"!"}]`
+	if string(data) != want {
+		t.Errorf("deltas = %s, want %s", data, want)
+	}
+}
+
+func TestNewZapResultFrame(t *testing.T) {
+	cases := []struct {
+		status uint32
+		body   string
+		errMsg string
+		want   string
+	}{
+		{200, `{"object":"list"}`, "", `{"id":"7","status":200,"result":{"object":"list"}}`},
+		{200, `plain text`, "", `{"id":"7","status":200,"result":"plain text"}`},
+		{502, "", "provider error: boom", `{"id":"7","status":502,"error":{"code":502,"message":"provider error: boom"}}`},
+		{404, `{"error":"not found"}`, "", `{"id":"7","status":404,"error":{"code":404,"message":"{\"error\":\"not found\"}"}}`},
+	}
+	for _, tc := range cases {
+		data, err := json.Marshal(newZapResultFrame("7", tc.status, []byte(tc.body), tc.errMsg))
+		if err != nil {
+			t.Fatalf("marshal frame: %v", err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("newZapResultFrame(%d, %q, %q) = %s, want %s", tc.status, tc.body, tc.errMsg, data, tc.want)
+		}
+	}
+}
+
+func TestCheckZapOrigin(t *testing.T) {
+	previous := zapOriginAllowed
+	defer func() { zapOriginAllowed = previous }()
+	zapOriginAllowed = func(origin string) bool { return origin == "https://app.hanzo.ai" }
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://api.hanzo.ai", true},
+		{"https://app.hanzo.ai", true},
+		{"https://evil.example", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "https://api.hanzo.ai/v1/zap", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := checkZapOrigin(req); got != tt.want {
+			t.Errorf("checkZapOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestZapRateLimitFrame(t *testing.T) {
+	previous := zapRateLimit
+	defer func() { zapRateLimit = previous }()
+
+	zapRateLimit = nil
+	if frame := zapRateLimitFrame(&zapEnvelope{Id: "1"}, "10.0.0.1"); frame != nil {
+		t.Fatalf("frame = %+v without a limiter, want nil", frame)
+	}
+
+	var gotAuth, gotIP string
+	zapRateLimit = func(auth string, clientIP string) (bool, int) {
+		gotAuth, gotIP = auth, clientIP
+		return auth != "Bearer hk-over", 7
+	}
+	if frame := zapRateLimitFrame(&zapEnvelope{Id: "1", Auth: "Bearer hk-ok"}, "10.0.0.1"); frame != nil {
+		t.Errorf("allowed request got frame %+v", frame)
+	}
+	if gotAuth != "Bearer hk-ok" || gotIP != "10.0.0.1" {
+		t.Errorf("limiter called with (%q, %q)", gotAuth, gotIP)
+	}
+	frame := zapRateLimitFrame(&zapEnvelope{Id: "2", Auth: "Bearer hk-over"}, "10.0.0.1")
+	if frame == nil || frame.Id != "2" || frame.Status != 429 {
+		t.Fatalf("denied request frame = %+v, want a 429 for id 2", frame)
+	}
+}
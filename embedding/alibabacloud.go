@@ -17,6 +17,7 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +27,7 @@ type AlibabacloudEmbeddingProvider struct {
 	subType     string
 	secretKey   string
 	providerUrl string
+	httpClient  *http.Client
 }
 
 func NewAlibabacloudEmbeddingProvider(typ string, subType string, secretKey string, providerUrl string) (*AlibabacloudEmbeddingProvider, error) {
@@ -37,6 +39,10 @@ func NewAlibabacloudEmbeddingProvider(typ string, subType string, secretKey stri
 	}, nil
 }
 
+func (p *AlibabacloudEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *AlibabacloudEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://help.aliyun.com/zh/model-studio/user-guide/embedding?spm=a2c4g.11186623.help-menu-2400256.d_1_0_7.5a06b0a85SQYXz
@@ -72,6 +78,7 @@ func (p *AlibabacloudEmbeddingProvider) QueryVector(text string, ctx context.Con
 	if err != nil {
 		return nil, nil, err
 	}
+	localEmbeddingProvider.SetHttpClient(p.httpClient)
 	vector, embeddingResult, err := localEmbeddingProvider.QueryVector(text, ctx, lang)
 	if err != nil {
 		return nil, nil, err
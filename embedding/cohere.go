@@ -16,15 +16,17 @@ package embedding
 
 import (
 	"context"
+	"net/http"
 
 	cohere "github.com/cohere-ai/cohere-go/v2"
 	cohereclient "github.com/cohere-ai/cohere-go/v2/client"
 )
 
 type CohereEmbeddingProvider struct {
-	subType   string
-	secretKey string
-	inputType string
+	subType    string
+	secretKey  string
+	inputType  string
+	httpClient *http.Client
 }
 
 func (p *CohereEmbeddingProvider) GetPricing() string {
@@ -47,6 +49,10 @@ func (p *CohereEmbeddingProvider) calculatePrice(res *EmbeddingResult) error {
 }
 
 func NewCohereEmbeddingProvider(subType string, inputType string, secretKey string) (*CohereEmbeddingProvider, error) {
+	// v3 embed models require an input type
+	if inputType == "" {
+		inputType = "search_document"
+	}
 	return &CohereEmbeddingProvider{
 		subType:   subType,
 		secretKey: secretKey,
@@ -54,9 +60,14 @@ func NewCohereEmbeddingProvider(subType string, inputType string, secretKey stri
 	}, nil
 }
 
+func (p *CohereEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *CohereEmbeddingProvider) QueryVector(text string, ctx context.Context, lang string) ([]float32, *EmbeddingResult, error) {
 	client := cohereclient.NewClient(
 		cohereclient.WithToken(p.secretKey),
+		cohereclient.WithHTTPClient(getHttpClient(p.httpClient)),
 	)
 
 	embeddingResult, embed, err := cohereEmbed(ctx, client, &p.subType, &p.inputType, []string{text})
@@ -93,3 +104,41 @@ func cohereEmbed(ctx context.Context, client *cohereclient.Client, model *string
 
 	return embeddingResult, embeddings, nil
 }
+
+// Rerank orders documents by relevance to query with a Cohere rerank model,
+// returning the topN most relevant (all when topN is 0).
+func (p *CohereEmbeddingProvider) Rerank(ctx context.Context, model string, query string, documents []string, topN int) ([]RerankResult, *EmbeddingResult, error) {
+	client := cohereclient.NewClient(
+		cohereclient.WithToken(p.secretKey),
+		cohereclient.WithHTTPClient(getHttpClient(p.httpClient)),
+	)
+
+	request := &cohere.RerankRequest{
+		Model: &model,
+		Query: query,
+	}
+	for _, document := range documents {
+		request.Documents = append(request.Documents, cohere.NewRerankRequestDocumentsItemFromString(document))
+	}
+	if topN > 0 {
+		request.TopN = &topN
+	}
+
+	resp, err := client.Rerank(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]RerankResult, 0, len(resp.Results))
+	for _, item := range resp.Results {
+		results = append(results, RerankResult{Index: item.Index, RelevanceScore: item.RelevanceScore})
+	}
+
+	embeddingResult := &EmbeddingResult{TokenCount: RerankSearchUnits(len(documents)), Currency: "USD"}
+	if resp.Meta != nil && resp.Meta.BilledUnits != nil && resp.Meta.BilledUnits.SearchUnits != nil {
+		embeddingResult.TokenCount = int(*resp.Meta.BilledUnits.SearchUnits)
+	}
+	// $2.00 per 1,000 searches
+	embeddingResult.Price = getPrice(embeddingResult.TokenCount, 2.0)
+	return results, embeddingResult, nil
+}
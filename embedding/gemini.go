@@ -16,14 +16,15 @@ package embedding
 
 import (
 	"context"
+	"net/http"
 
-	"github.com/hanzoai/cloud/proxy"
 	"google.golang.org/genai"
 )
 
 type GeminiEmbeddingProvider struct {
-	subType   string
-	secretKey string
+	subType    string
+	secretKey  string
+	httpClient *http.Client
 }
 
 func NewGeminiEmbeddingProvider(subType string, secretKey string) (*GeminiEmbeddingProvider, error) {
@@ -34,6 +35,10 @@ func NewGeminiEmbeddingProvider(subType string, secretKey string) (*GeminiEmbedd
 	return p, nil
 }
 
+func (p *GeminiEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *GeminiEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://cloud.google.com/vertex-ai/generative-ai/pricing
@@ -58,7 +63,7 @@ func (p *GeminiEmbeddingProvider) QueryVector(text string, ctx context.Context,
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:     p.secretKey,
 		Backend:    genai.BackendGeminiAPI,
-		HTTPClient: proxy.ProxyHttpClient,
+		HTTPClient: getHttpClient(p.httpClient),
 	})
 	if err != nil {
 		return nil, nil, err
@@ -16,20 +16,25 @@ package embedding
 
 import (
 	"context"
+	"net/http"
 
-	"github.com/hanzoai/cloud/proxy"
 	huggingfaceembedder "github.com/henomis/lingoose/embedder/huggingface"
 )
 
 type HuggingFaceEmbeddingProvider struct {
-	subType   string
-	secretKey string
+	subType    string
+	secretKey  string
+	httpClient *http.Client
 }
 
 func NewHuggingFaceEmbeddingProvider(subType string, secretKey string) (*HuggingFaceEmbeddingProvider, error) {
 	return &HuggingFaceEmbeddingProvider{subType: subType, secretKey: secretKey}, nil
 }
 
+func (p *HuggingFaceEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *HuggingFaceEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://huggingface.co/pricing
@@ -43,7 +48,7 @@ func (p *HuggingFaceEmbeddingProvider) calculatePrice(res *EmbeddingResult) erro
 }
 
 func (p *HuggingFaceEmbeddingProvider) QueryVector(text string, ctx context.Context, lang string) ([]float32, *EmbeddingResult, error) {
-	client := huggingfaceembedder.New().WithToken(p.secretKey).WithModel(p.subType).WithHTTPClient(proxy.ProxyHttpClient)
+	client := huggingfaceembedder.New().WithToken(p.secretKey).WithModel(p.subType).WithHTTPClient(getHttpClient(p.httpClient))
 	embed, err := client.Embed(ctx, []string{text})
 	if err != nil {
 		return nil, nil, err
@@ -26,8 +26,9 @@ import (
 )
 
 type JinaEmbeddingProvider struct {
-	subType string
-	apiKey  string
+	subType    string
+	apiKey     string
+	httpClient *http.Client
 }
 
 func NewJinaEmbeddingProvider(subType string, apiKey string) (*JinaEmbeddingProvider, error) {
@@ -38,6 +39,10 @@ func NewJinaEmbeddingProvider(subType string, apiKey string) (*JinaEmbeddingProv
 	return p, nil
 }
 
+func (p *JinaEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *JinaEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://jina.ai/zh-CN/embeddings/
@@ -90,8 +95,7 @@ func (p *JinaEmbeddingProvider) QueryVector(text string, ctx context.Context, la
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := getHttpClient(p.httpClient).Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -137,3 +141,63 @@ func (p *JinaEmbeddingProvider) QueryVector(text string, ctx context.Context, la
 
 	return embedding, embeddingResult, nil
 }
+
+// Rerank orders documents by relevance to query with a Jina reranker model,
+// returning the topN most relevant (all when topN is 0).
+func (p *JinaEmbeddingProvider) Rerank(ctx context.Context, model string, query string, documents []string, topN int) ([]RerankResult, *EmbeddingResult, error) {
+	payload := map[string]interface{}{
+		"model":            model,
+		"query":            query,
+		"documents":        documents,
+		"return_documents": false,
+	}
+	if topN > 0 {
+		payload["top_n"] = topN
+	}
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.jina.ai/v1/rerank", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := getHttpClient(p.httpClient).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("jina rerank failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var apiResponse struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err = json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]RerankResult, 0, len(apiResponse.Results))
+	for _, item := range apiResponse.Results {
+		results = append(results, RerankResult{Index: item.Index, RelevanceScore: item.RelevanceScore})
+	}
+
+	// Jina bills tokens; reranks are sold in search units like Cohere's.
+	embeddingResult := &EmbeddingResult{TokenCount: RerankSearchUnits(len(documents)), Currency: "USD"}
+	// $2.00 per 1,000 searches
+	embeddingResult.Price = getPrice(embeddingResult.TokenCount, 2.0)
+	return results, embeddingResult, nil
+}
@@ -35,6 +35,7 @@ type LocalEmbeddingProvider struct {
 	apiVersion             string
 	pricePerThousandTokens float64
 	currency               string
+	httpClient             *http.Client
 }
 
 func NewLocalEmbeddingProvider(typ string, subType string, secretKey string, providerUrl string, compatibleProvider string, pricePerThousandTokens float64, currency string) (*LocalEmbeddingProvider, error) {
@@ -50,13 +51,19 @@ func NewLocalEmbeddingProvider(typ string, subType string, secretKey string, pro
 	return p, nil
 }
 
-func getLocalClientFromUrl(authToken string, url string) *openai.Client {
+func (p *LocalEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
+func getLocalClientFromUrl(authToken string, url string, httpClient *http.Client) *openai.Client {
 	config := openai.DefaultConfig(authToken)
 	config.BaseURL = url
 
-	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	httpClient := http.Client{Transport: transport}
-	config.HTTPClient = &httpClient
+	if httpClient == nil {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		httpClient = &http.Client{Transport: transport}
+	}
+	config.HTTPClient = httpClient
 
 	c := openai.NewClientWithConfig(config)
 	return c
@@ -101,13 +108,13 @@ func (p *LocalEmbeddingProvider) calculatePrice(res *EmbeddingResult, lang strin
 func (p *LocalEmbeddingProvider) QueryVector(text string, ctx context.Context, lang string) ([]float32, *EmbeddingResult, error) {
 	var client *openai.Client
 	if p.typ == "Local" {
-		client = getLocalClientFromUrl(p.secretKey, p.providerUrl)
+		client = getLocalClientFromUrl(p.secretKey, p.providerUrl, p.httpClient)
 	} else if p.typ == "Azure" {
-		client = getAzureClientFromToken(p.deploymentName, p.secretKey, p.providerUrl, p.apiVersion)
+		client = getAzureClientFromToken(p.deploymentName, p.secretKey, p.providerUrl, p.apiVersion, p.httpClient)
 	} else if p.typ == "OpenAI" {
-		client = getProxyClientFromToken(p.secretKey)
+		client = getProxyClientFromToken(p.secretKey, p.httpClient)
 	} else if p.typ == "Custom" {
-		client = getLocalClientFromUrl(p.secretKey, p.providerUrl)
+		client = getLocalClientFromUrl(p.secretKey, p.providerUrl, p.httpClient)
 	}
 	model := p.subType
 	if model == "custom-embedding" && p.compatibleProvider != "" {
@@ -29,6 +29,7 @@ type MiniMaxEmbeddingProvider struct {
 	subType     string
 	apiKey      string
 	providerUrl string // providerUrl contains groupId
+	httpClient  *http.Client
 }
 
 func NewMiniMaxEmbeddingProvider(typ string, subType string, apiKey string, providerUrl string) (*MiniMaxEmbeddingProvider, error) {
@@ -40,6 +41,10 @@ func NewMiniMaxEmbeddingProvider(typ string, subType string, apiKey string, prov
 	return p, nil
 }
 
+func (p *MiniMaxEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *MiniMaxEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://platform.minimaxi.com/document/Price
@@ -97,8 +102,7 @@ func (p *MiniMaxEmbeddingProvider) QueryVector(text string, ctx context.Context,
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := getHttpClient(p.httpClient).Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,80 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedding
+
+import (
+	"context"
+	"net/http"
+)
+
+// MistralEmbeddingProvider embeds through api.mistral.ai's OpenAI-compatible
+// embeddings API.
+type MistralEmbeddingProvider struct {
+	subType    string
+	secretKey  string
+	httpClient *http.Client
+}
+
+func NewMistralEmbeddingProvider(subType string, secretKey string) (*MistralEmbeddingProvider, error) {
+	return &MistralEmbeddingProvider{
+		subType:   subType,
+		secretKey: secretKey,
+	}, nil
+}
+
+func (p *MistralEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
+func (p *MistralEmbeddingProvider) GetPricing() string {
+	return `URL:
+https://mistral.ai/pricing
+
+Embedding models:
+
+| Models          | Per 1,000,000 tokens |
+|-----------------|----------------------|
+| mistral-embed   | $0.1                 |
+| codestral-embed | $0.15                |
+`
+}
+
+func (p *MistralEmbeddingProvider) calculatePrice(res *EmbeddingResult) error {
+	pricePerThousandTokens := 0.0001
+	if p.subType == "codestral-embed" {
+		pricePerThousandTokens = 0.00015
+	}
+	res.Price = getPrice(res.TokenCount, pricePerThousandTokens)
+	res.Currency = "USD"
+	return nil
+}
+
+func (p *MistralEmbeddingProvider) QueryVector(text string, ctx context.Context, lang string) ([]float32, *EmbeddingResult, error) {
+	const BaseUrl = "https://api.mistral.ai/v1"
+	localEmbeddingProvider, err := NewLocalEmbeddingProvider("Custom", "custom-embedding", p.secretKey, BaseUrl, p.subType, 0, "USD")
+	if err != nil {
+		return nil, nil, err
+	}
+	localEmbeddingProvider.SetHttpClient(p.httpClient)
+	vector, embeddingResult, err := localEmbeddingProvider.QueryVector(text, ctx, lang)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = p.calculatePrice(embeddingResult)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vector, embeddingResult, nil
+}
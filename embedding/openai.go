@@ -15,7 +15,8 @@
 package embedding
 
 import (
-	"github.com/hanzoai/cloud/proxy"
+	"net/http"
+
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -27,9 +28,9 @@ func NewOpenAiEmbeddingProvider(typ string, subType string, secretKey string) (*
 	}, nil
 }
 
-func getProxyClientFromToken(authToken string) *openai.Client {
+func getProxyClientFromToken(authToken string, httpClient *http.Client) *openai.Client {
 	config := openai.DefaultConfig(authToken)
-	config.HTTPClient = proxy.ProxyHttpClient
+	config.HTTPClient = getHttpClient(httpClient)
 
 	c := openai.NewClientWithConfig(config)
 	return c
@@ -16,8 +16,10 @@ package embedding
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/proxy"
 )
 
 type EmbeddingResult struct {
@@ -26,6 +28,14 @@ type EmbeddingResult struct {
 	Currency   string
 }
 
+// getHttpClient returns client, or the shared proxy client when none was set.
+func getHttpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return proxy.ProxyHttpClient
+	}
+	return client
+}
+
 type EmbeddingProvider interface {
 	GetPricing() string
 	QueryVector(text string, ctx context.Context, lang string) ([]float32, *EmbeddingResult, error)
@@ -42,6 +52,8 @@ func GetEmbeddingProvider(typ string, subType string, clientId string, clientSec
 		p, err = NewHuggingFaceEmbeddingProvider(subType, clientSecret)
 	} else if typ == "Cohere" {
 		p, err = NewCohereEmbeddingProvider(subType, clientId, clientSecret)
+	} else if typ == "Mistral" {
+		p, err = NewMistralEmbeddingProvider(subType, clientSecret)
 	} else if typ == "Baidu Cloud" {
 		p, err = NewBaiduCloudEmbeddingProvider(subType, clientId, clientSecret)
 	} else if typ == "Ollama" {
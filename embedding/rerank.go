@@ -0,0 +1,39 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedding
+
+import "context"
+
+type RerankResult struct {
+	Index          int
+	RelevanceScore float64
+}
+
+// rerankSearchUnitDocuments is the documents of one search unit, Cohere's
+// unit of rerank billing: one query over up to 100 documents.
+const rerankSearchUnitDocuments = 100
+
+// RerankSearchUnits returns the search units of a rerank of documents.
+func RerankSearchUnits(documents int) int {
+	return max(1, (documents+rerankSearchUnitDocuments-1)/rerankSearchUnitDocuments)
+}
+
+// Reranker is implemented by embedding providers that can also order
+// documents by their relevance to a query. The TokenCount of the
+// EmbeddingResult of a rerank is its search units: those the upstream bills
+// when it reports them, else RerankSearchUnits.
+type Reranker interface {
+	Rerank(ctx context.Context, model string, query string, documents []string, topN int) ([]RerankResult, *EmbeddingResult, error)
+}
@@ -17,6 +17,7 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
@@ -41,6 +42,12 @@ func NewTencentCloudEmbeddingProvider(clientId, clientSecret string, lang string
 	}, nil
 }
 
+// SetHttpClient sends the SDK's requests through the transport of client;
+// the SDK applies its own timeout.
+func (p *TencentCloudEmbeddingProvider) SetHttpClient(client *http.Client) {
+	p.client.WithHttpTransport(client.Transport)
+}
+
 func (p *TencentCloudEmbeddingProvider) GetPricing() string {
 	return `URL:
 https://cloud.tencent.com/document/product/1729/97731
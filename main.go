@@ -25,6 +25,7 @@ import (
 	"github.com/beego/beego"
 	"github.com/beego/beego/logs"
 	_ "github.com/beego/beego/session/redis"
+	"github.com/hanzoai/cloud/cache"
 	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/controllers"
 	"github.com/hanzoai/cloud/object"
@@ -35,12 +36,14 @@ import (
 
 func main() {
 	object.InitFlag()
+	cache.InitCache()
 	object.InitAdapter()
 	object.CreateTables()
 
 	object.InitDb()
 
-	// Load model routing/pricing config from YAML. Non-fatal: falls back to static maps.
+	// Load model routing/pricing config from YAML (a file, directory or URL, see
+	// controllers/model_config_source.go). Non-fatal: falls back to static maps.
 	configPath := conf.GetConfigString("modelConfigPath")
 	if configPath == "" {
 		configPath = "conf/models.yaml"
@@ -48,12 +51,17 @@ func main() {
 	if err := controllers.InitModelConfig(configPath); err != nil {
 		logs.Warn("Model config: %v (using static fallback)", err)
 	}
+	controllers.InitSelfHostedHealthChecks()
+	controllers.InitProviderPriceSync()
+	controllers.InitEvalScheduler()
 
 	proxy.InitHttpClient()
 	util.InitMaxmindFiles()
 	util.InitIpDb()
 	util.InitParser()
 	object.InitCleanupChats()
+	object.InitRequestLogRetention()
+	object.InitWebhookDeliveries()
 	object.InitStoreCount()
 	object.InitCommitRecordsTask()
 	object.InitScanJobProcessor()
@@ -72,11 +80,18 @@ func main() {
 	rlInstance := routers.InitRateLimiter(routers.DefaultTierFunc)
 	logs.Info("Per-key rate limiter initialized (tiers: free=10/min, starter=60/min, pro=300/min, enterprise=1000/min)")
 
+	routers.InitResponseCompression()
+	routers.InitCors()
+
 	beego.SetStaticPath("/swagger", "swagger")
+	beego.InsertFilter("*", beego.BeforeStatic, routers.LegacyApiRewriteFilter)
+	beego.InsertFilter("*", beego.BeforeStatic, routers.RequestBodyLimitFilter)
 	beego.InsertFilter("/v1/cloud/*", beego.BeforeRouter, routers.V1CloudRewriteFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.CorsFilter)
+	beego.InsertFilter("*", beego.BeforeRouter, routers.RequestBodyTooLargeFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.HstsFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.CacheControlFilter)
+	beego.InsertFilter("*", beego.BeforeRouter, routers.CompressionFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.RateLimitFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.AutoSigninFilter)
 	beego.InsertFilter("*", beego.BeforeRouter, routers.BalanceGateFilter)
@@ -137,7 +152,16 @@ func main() {
 		logs.Info("Billing queue started (Commerce endpoint configured)")
 	}
 
-	// Graceful shutdown: drain billing queue and stop rate limiter.
+	// Start the worker pool that records usage and traces after each request.
+	controllers.InitUsageRecorder()
+
+	// Initialize the optional Kafka/NATS publisher for usage and error events.
+	eb := controllers.InitEventBus()
+	if eb != nil {
+		logs.Info("Event bus started (%s)", conf.GetConfigString("eventBusType"))
+	}
+
+	// Graceful shutdown: drain billing queue, stop rate limiter and model config refresh.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
@@ -150,7 +174,13 @@ func main() {
 			logs.Info("Rate limiter stopped (total_allowed=%d total_denied=%d)", allowed, denied)
 		}
 
+		controllers.StopEvalRuns()
+
+		// Drain the trace writes first; usage was billed before they were queued.
+		controllers.ShutdownUsageRecorder()
+
 		if bq != nil {
+			controllers.StopBillingAccumulator()
 			remaining := bq.Shutdown()
 			if remaining > 0 {
 				logs.Error("Billing queue shutdown: %d records could not be delivered", remaining)
@@ -159,6 +189,13 @@ func main() {
 			}
 		}
 
+		if eb != nil {
+			if remaining := eb.Shutdown(); remaining > 0 {
+				logs.Error("Event bus shutdown: %d events could not be published", remaining)
+			}
+		}
+
+		controllers.ShutdownModelConfig()
 		controllers.StopInterserviceZap()
 		object.StopZap()
 
@@ -175,6 +212,7 @@ func main() {
 	controllers.InitInterserviceZap()
 
 	go object.ClearThroughputPerSecond()
+	object.InitStorageRetentionSweeper()
 
 	beego.Run(fmt.Sprintf(":%v", port))
 }
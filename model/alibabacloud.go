@@ -32,6 +32,7 @@ type AlibabacloudModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewAlibabacloudModelProvider(subType string, apiKey string, temperature float32, topP float32) (*AlibabacloudModelProvider, error) {
@@ -43,30 +44,8 @@ func NewAlibabacloudModelProvider(subType string, apiKey string, temperature flo
 	}, nil
 }
 
-func (p *AlibabacloudModelProvider) GetPricing() string {
-	return `URL:
-https://help.aliyun.com/zh/model-studio/billing-for-model-studio
-
-| Model               | sub-type                        | Input Price per 1K characters    | Output Price per 1K characters |
-|---------------------|---------------------------------|----------------------------------|--------------------------------|
-| Qwen-Long           | qwen-long                       | 0.0005yuan/1,000 tokens          | 0.002yuan/1,000 tokens         |
-| Qwen-Turbo          | qwen-turbo                      | 0.002yuan/1,000 tokens           | 0.006yuan/1,000 tokens         |
-| Qwen-Plus           | qwen-plus                       | 0.004yuan/1,000 tokens           | 0.012yuan/1,000 tokens         |
-| Qwen-Max            | qwen-max                        | 0.04yuan/1,000 tokens            | 0.12yuan/1,000 tokens          |
-| Qwen-Max            | qwen-max-longcontext            | 0.04yuan/1,000 tokens            | 0.12yuan/1,000 tokens          |
-| Qwen3-235B-a22B     | qwen3-235b-a22b                 | 0.004yuan/1,000 tokens            | 0.04yuan/1,000 tokens         |
-| Qwen3-32B           | qwen3-32b            			| 0.002yuan/1,000 tokens            | 0.02yuan/1,000 tokens         |
-| DeepSeek-R1         | deepseek-r1                     | 0.004yuan/1,000 tokens           | 0.016yuan/1,000 tokens         |
-| DeepSeek-V3         | deepseek-v3                     | 0.002yuan/1,000 tokens           | 0.008yuan/1,000 tokens         |
-| DeepSeek-V3.1       | deepseek-v3.1                   | 0.004yuan/1,000 tokens           | 0.012yuan/1,000 tokens         |
-| DeepSeek-V3.2       | deepseek-v3.2                   | 0.002yuan/1,000 tokens           | 0.003yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-qwen-1.5b   | 0.000yuan/1,000 tokens           | 0.000yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-qwen-7b     | 0.0005yuan/1,000 tokens          | 0.001yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-qwen-14b    | 0.001yuan/1,000 tokens           | 0.003yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-qwen-32b    | 0.002yuan/1,000 tokens           | 0.006yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-llama-8b    | 0.000yuan/1,000 tokens           | 0.000yuan/1,000 tokens         |
-| DeepSeek-R1-Distill | deepseek-r1-distill-llama-70b   | 0.000yuan/1,000 tokens           | 0.000yuan/1,000 tokens         |
-`
+func (p *AlibabacloudModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *AlibabacloudModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -103,8 +82,14 @@ func (p *AlibabacloudModelProvider) calculatePrice(modelResult *ModelResult, lan
 	return nil
 }
 
-func (p *AlibabacloudModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	ctx := context.Background()
+// QueryText sends web searches through DashScope's native API, which
+// returns the search results but takes no HTTP client; other queries go
+// through its OpenAI-compatible mode.
+func (p *AlibabacloudModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	if agentInfo == nil || agentInfo.AgentClients == nil || !agentInfo.AgentClients.WebSearchEnabled {
+		return p.queryTextCompatible(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	}
+
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
@@ -129,21 +114,21 @@ func (p *AlibabacloudModelProvider) QueryText(question string, writer io.Writer,
 		SetTopP(float64(p.topP)).
 		SetIncrementalOutput(true)
 
-	if agentInfo != nil && agentInfo.AgentClients != nil && agentInfo.AgentClients.WebSearchEnabled {
-		params.SetEnableSearch(true)
-		params.SetSearchOptions(&qwen.SearchOptions{
-			ForcedSearch:        true,
-			EnableSource:        true,
-			EnableCitation:      true,
-			PrependSearchResult: true,
-		})
-	}
+	params.SetEnableSearch(true)
+	params.SetSearchOptions(&qwen.SearchOptions{
+		ForcedSearch:        true,
+		EnableSource:        true,
+		EnableCitation:      true,
+		PrependSearchResult: true,
+	})
 
+	var answer strings.Builder
 	streamCallbackFn := func(ctx context.Context, typ string, chunk []byte) error {
 		data := string(chunk)
 		if data == "" {
 			return nil
 		}
+		answer.WriteString(data)
 		return flushDataThink(data, typ, writer, lang)
 	}
 
@@ -158,6 +143,11 @@ func (p *AlibabacloudModelProvider) QueryText(question string, writer io.Writer,
 
 	resp, err := cli.CreateCompletion(ctx, req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return canceledModelResult(ctx, &ModelResult{}, p.subType, question, answer.String(), func(modelResult *ModelResult) error {
+				return p.calculatePrice(modelResult, lang)
+			})
+		}
 		return nil, err
 	}
 
@@ -181,6 +171,27 @@ func (p *AlibabacloudModelProvider) QueryText(question string, writer io.Writer,
 	return modelResult, nil
 }
 
+func (p *AlibabacloudModelProvider) queryTextCompatible(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	const BaseUrl = "https://dashscope.aliyuncs.com/compatible-mode/v1"
+	// Create a new LocalModelProvider to handle the request
+	localProvider, err := NewLocalModelProvider("Custom-think", "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
+	if err != nil {
+		return nil, err
+	}
+	localProvider.SetHttpClient(p.httpClient)
+
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
+		return nil, err
+	}
+
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
+	}
+	return modelResult, err
+}
+
 func buildMessages(question string, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage) []qwen.Message[*qwen.TextContent] {
 	systemMessages := getSystemMessages(prompt, knowledgeMessages)
 	var messages []qwen.Message[*qwen.TextContent]
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -31,6 +32,7 @@ type AmazonBedrockModelProvider struct {
 	temperature float64
 	subType     string
 	secretKey   string
+	httpClient  *http.Client
 }
 
 func NewAmazonBedrockModelProvider(subType string, secretKey string, temperature float64) (*AmazonBedrockModelProvider, error) {
@@ -42,39 +44,8 @@ func NewAmazonBedrockModelProvider(subType string, secretKey string, temperature
 	return client, nil
 }
 
-func (a AmazonBedrockModelProvider) GetPricing() string {
-	return `URL: https://aws.amazon.com/cn/bedrock/pricing/
-
-Model 
-| Model                       | Unit Of Charge                | Price    |
-|-----------------------------|-------------------------------|----------|
-| Claude                      | 1K input tokens               | $0.008   |
-| Claude                      | 1K output tokens              | $0.024   |
-| Claude Instant              | 1K input tokens               | $0.0008  |
-| Claude Instant              | 1K output tokens              | $0.0024  |
-| Command                     | 1K input tokens               | $0.0015  |
-| Command                     | 1K output tokens              | $0.002   |
-| Command Light               | 1K input tokens               | $0.0003  |
-| Command Light               | 1K output tokens              | $0.0006  |
-| Embed - English             | 1K input tokens               | $0.0001  |
-| Embed - Multilingual        | 1K input tokens               | $0.0001  |
-| Jurassic-2 Mid              | 1K input tokens               | $0.0125  |
-| Jurassic-2 Mid              | 1K output tokens              | $0.0125  |
-| Jurassic-2 Ultra            | 1K input tokens               | $0.0188  |
-| Jurassic-2 Ultra            | 1K output tokens              | $0.0188  |
-| Llama 2 Chat (13B)          | 1K input tokens               | $0.00075 |
-| Llama 2 Chat (13B)          | 1K output tokens              | $0.001   |
-| Llama 2 Chat (70B)          | 1K input tokens               | $0.00195 |
-| Llama 2 Chat (70B)          | 1K output tokens              | $0.00256 |
-| Titan Text Lite             | 1K input tokens               | $0.0003  |
-| Titan Text Lite             | 1K output tokens              | $0.0004  |
-| Titan Text Express          | 1K input tokens               | $0.0008  |
-| Titan Text Express          | 1K output tokens              | $0.0016  |
-| Titan Embeddings            | 1K input tokens               | $0.0001  |
-| Titan Multimodal Embeddings | 1K input tokens               | $0.0008  |
-| Titan Multimodal Embeddings | 1 input image                 | $0.00006 |
-
-`
+func (p *AmazonBedrockModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *AmazonBedrockModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -108,8 +79,8 @@ func (p *AmazonBedrockModelProvider) calculatePrice(modelResult *ModelResult, la
 	return nil
 }
 
-func (p *AmazonBedrockModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-west-2"))
+func (p *AmazonBedrockModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-west-2"), config.WithHTTPClient(getHttpClient(p.httpClient)))
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +109,7 @@ func (p *AmazonBedrockModelProvider) QueryText(question string, writer io.Writer
 		}
 	}
 
-	resp, err := client.InvokeModel(context.TODO(), &bedrockruntime.InvokeModelInput{
+	resp, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
 		ModelId:     aws.String(p.subType),
 		Body:        requestBody,
 		ContentType: aws.String("application/json"),
@@ -15,7 +15,8 @@
 package model
 
 import (
-	"github.com/hanzoai/cloud/proxy"
+	"net/http"
+
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -35,9 +36,9 @@ func NewAzureModelProvider(typ string, subType string, deploymentName string, se
 	return p, nil
 }
 
-func getAzureClientFromToken(deploymentName string, authToken string, url string, apiVersion string) *openai.Client {
+func getAzureClientFromToken(deploymentName string, authToken string, url string, apiVersion string, httpClient *http.Client) *openai.Client {
 	config := openai.DefaultAzureConfig(authToken, url)
-	config.HTTPClient = proxy.ProxyHttpClient
+	config.HTTPClient = getHttpClient(httpClient)
 	if apiVersion != "" {
 		config.APIVersion = apiVersion
 	}
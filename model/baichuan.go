@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type BaichuanModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewBaichuanModelProvider(subType string, apiKey string, temperature float32, topP float32) (*BaichuanModelProvider, error) {
@@ -37,20 +40,8 @@ func NewBaichuanModelProvider(subType string, apiKey string, temperature float32
 	}, nil
 }
 
-func (p *BaichuanModelProvider) GetPricing() string {
-	return `URL:
-https://platform.baichuan-ai.com/price
-
-| Model      | sub-type             | Input Price per 1K characters    | Output Price per 1K characters |
-|------------|----------------------|----------------------------------|--------------------------------|
-| Baichuan   | Baichuan2-Turbo      | 0.008yuan/1,000 tokens           | 0.008yuan/1,000 tokens         |
-| Baichuan   | Baichuan2-53B        | 0.010yuan/1,000 tokens           | 0.010yuan/1,000 tokens         |
-| Baichuan   | Baichuan3-Turbo      | 0.012yuan/1,000 tokens           | 0.012yuan/1,000 tokens         |
-| Baichuan   | Baichuan3-Turbo-128k | 0.024yuan/1,000 tokens           | 0.024yuan/1,000 tokens         |
-| Baichuan   | Baichuan4            | 0.100yuan/1,000 tokens           | 0.100yuan/1,000 tokens         |
-| Baichuan   | Baichuan4-Air        | 0.00098yuan/1,000 tokens         | 0.00098yuan/1,000 tokens       |
-| Baichuan   | Baichuan4-Turbo      | 0.015yuan/1,000 tokens           | 0.015yuan/1,000 tokens         |
-`
+func (p *BaichuanModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *BaichuanModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -78,22 +69,23 @@ func (p *BaichuanModelProvider) calculatePrice(modelResult *ModelResult, lang st
 	return nil
 }
 
-func (p *BaichuanModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *BaichuanModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.baichuan-ai.com/v1"
 	// Create a new LocalModelProvider to handle the request
 	localProvider, err := NewLocalModelProvider("Custom", "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
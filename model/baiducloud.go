@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type BaiduCloudModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewBaiduCloudModelProvider(subType string, apiKey string, temperature float32, topP float32) (*BaiduCloudModelProvider, error) {
@@ -37,42 +40,8 @@ func NewBaiduCloudModelProvider(subType string, apiKey string, temperature float
 	}, nil
 }
 
-func (p *BaiduCloudModelProvider) GetPricing() string {
-	return `URL:
-https://cloud.baidu.com/doc/WENXINWORKSHOP/s/Blfmc9dlf
-
-| Model                                 | Input Price per 1K tokens (CNY)     | Output Price per 1K tokens (CNY)     |
-|-------------------------------------  |-------------------------------------|--------------------------------------|
-| ernie-4.0-8k                          | 0.03                                | 0.09                                 |
-| ernie-4.0-8k-latest                   | 0.03                                | 0.09                                 |
-| ernie-4.0-8k-preview                  | 0.03                                | 0.09                                 |
-| ernie-4.0-turbo-8k                    | 0.02                                | 0.06                                 |
-| ernie-4.0-turbo-128k                  | 0.02                                | 0.06                                 |
-| ernie-4.0-turbo-8k-preview            | 0.02                                | 0.06                                 |
-| ernie-4.0-turbo-8k-latest             | 0.02                                | 0.02                                 |
-| ernie-3.5-8k                          | 0.0008                              | 0.002                                |
-| ernie-3.5-128k                        | 0.0008                              | 0.002                                |
-| ernie-3.5-8k-preview                  | 0.0008                              | 0.002                                |
-| ernie-speed-8k                        | free                                | free                                 |
-| ernie-speed-128k                      | free                                | free                                 |
-| ernie-speed-pro-128k                  | 0.0003                              | 0.0006                               |
-| ernie-lite-8k                         | free                                | free                                 |
-| ernie-lite-pro-128k                   | 0.0002                              | 0.0004                               |
-| ernie-tiny-8k                         | free                                | free                                 |
-| ernie-character-8k                    | 0.0003                              | 0.008                                |
-| ernie-character-fiction-8k            | 0.004                               | 0.008                                |
-| ernie-novel-8k                        | 0.04                                | 0.12                                 |
-| deepseek-v3                           | 0.0008                              | 0.0016                               |
-| deepseek-r1                           | 0.002                               | 0.008                                |
-| deepseek-r1-distill-qwen-1.5b         | Free until March 12th,2025          | Free until March 12th,2025           |
-| deepseek-r1-distill-qwen-7b           | Free until March 12th,2025          | Free until March 12th,2025           |
-| deepseek-r1-distill-qwen-14b          | 0.0006                              | 0.0024                               |
-| deepseek-r1-distill-qwen-32b          | 0.0015                              | 0.006                                |
-| deepseek-r1-distill-llama-8b          | Free until March 12th,2025          | Free until March 12th,2025           |
-| deepseek-r1-distill-llama-70b         | 0.002                               | 0.008                                |
-| deepseek-r1-distill-qianfan-llama-8b  | Free until March 12th,2025          | Free until March 12th,2025           |
-| deepseek-r1-distill-qianfan-llama-70b | 0.002                               | 0.008                                |
-`
+func (p *BaiduCloudModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *BaiduCloudModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -122,22 +91,23 @@ func (p *BaiduCloudModelProvider) calculatePrice(modelResult *ModelResult, lang
 	return nil
 }
 
-func (p *BaiduCloudModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *BaiduCloudModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://qianfan.baidubce.com/v2"
 	// Create a new LocalModelProvider to handle the request
 	localProvider, err := NewLocalModelProvider("Custom-think", "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
@@ -15,37 +15,28 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/hanzoai/cloud/i18n"
-	"github.com/leverly/ChatGLM/client"
 )
 
+// ChatGLMModelProvider queries Zhipu's open.bigmodel.cn through its
+// OpenAI-compatible chat completions API.
 type ChatGLMModelProvider struct {
 	subType      string
 	clientSecret string
+	httpClient   *http.Client
 }
 
 func NewChatGLMModelProvider(subType string, clientSecret string) (*ChatGLMModelProvider, error) {
 	return &ChatGLMModelProvider{subType: subType, clientSecret: clientSecret}, nil
 }
 
-func (c *ChatGLMModelProvider) GetPricing() string {
-	return `URL:
-https://open.bigmodel.cn/pricing
-
-Generate Model:
-
-| Model        | Context Length | Unit Price (Per 1,000 tokens) |
-|--------------|----------------|-------------------------------|
-| GLM-3-Turbo  | 128K           | 0.005 yuan / K tokens         |
-| GLM-4        | 128K           | 0.1 yuan / K tokens           |
-| GLM-4V       | 2K             | 0.1 yuan / K tokens           |
-`
+func (p *ChatGLMModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *ChatGLMModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -64,60 +55,23 @@ func (p *ChatGLMModelProvider) calculatePrice(modelResult *ModelResult, lang str
 	return nil
 }
 
-func (p *ChatGLMModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	proxy := client.NewChatGLMClient(p.clientSecret, 30*time.Second)
-	messages := []client.Message{{Role: "user", Content: question}}
-	taskId, err := proxy.AsyncInvoke(p.subType, 0.2, messages)
-	if err != nil {
-		return nil, err
-	}
-
-	flusher, ok := writer.(http.Flusher)
-	if !ok {
-		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
-	}
-
-	flushData := func(data string) error {
-		if _, err = fmt.Fprintf(writer, "event: message\ndata: %s\n\n", data); err != nil {
-			return err
-		}
-		flusher.Flush()
-		return nil
-	}
-
-	if strings.HasPrefix(question, "$CloudDryRun$") {
-		modelResult, err := getDefaultModelResult(p.subType, question, "")
-		if err != nil {
-			return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:cannot calculate tokens"))
-		}
-		if getContextLength(p.subType) > modelResult.TotalTokenCount {
-			return modelResult, nil
-		} else {
-			return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:exceed max tokens"))
-		}
-	}
-
-	response, err := proxy.AsyncInvokeTask(p.subType, taskId)
+func (p *ChatGLMModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	const BaseUrl = "https://open.bigmodel.cn/api/paas/v4"
+	// Create a new LocalModelProvider to handle the request
+	localProvider, err := NewLocalModelProvider("Custom", "custom-model", p.clientSecret, 0.2, 0, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	content := (*response.Choices)[0].Content
-
-	err = flushData(content)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	modelResult := &ModelResult{}
-	modelResult.PromptTokenCount = response.Usage.PromptTokens
-	modelResult.ResponseTokenCount = response.Usage.CompletionTokens
-	modelResult.TotalTokenCount = response.Usage.TotalTokens
-
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-
-	return modelResult, nil
+	return modelResult, err
 }
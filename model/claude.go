@@ -24,7 +24,6 @@ import (
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/hanzoai/cloud/i18n"
-	"github.com/hanzoai/cloud/proxy"
 )
 
 type ClaudeModelProvider struct {
@@ -32,28 +31,15 @@ type ClaudeModelProvider struct {
 	secretKey      string
 	budgetTokens   int
 	enableThinking bool
+	httpClient     *http.Client
 }
 
 func NewClaudeModelProvider(subType string, secretKey string, enableThinking bool, budgetTokens int) (*ClaudeModelProvider, error) {
 	return &ClaudeModelProvider{subType: subType, secretKey: secretKey, enableThinking: enableThinking, budgetTokens: budgetTokens}, nil
 }
 
-func (p *ClaudeModelProvider) GetPricing() string {
-	return `URL:
-https://docs.anthropic.com/en/docs/about-claude/pricing
-
-| Model family        | Context window | Input Pricing         | Output Pricing        |
-|---------------------|----------------|-----------------------|-----------------------|
-| Claude Opus 4.5     | 200,000 tokens | $5.00/million tokens  | $25.00/million tokens |
-| Claude Opus 4.1     | 200,000 tokens | $15.00/million tokens | $75.00/million tokens |
-| Claude Opus 4       | 200,000 tokens | $15.00/million tokens | $75.00/million tokens |
-| Claude Sonnet 4     | 200,000 tokens | $3.00/million tokens  | $15.00/million tokens |
-| Claude Sonnet 3.7   | 200,000 tokens | $3.00/million tokens  | $15.00/million tokens |
-| Claude Sonnet 3.5   | 200,000 tokens | $3.00/million tokens  | $15.00/million tokens |
-| Claude Haiku 3.5    | 200,000 tokens | $0.80/million tokens  | $4.00/million tokens  |
-| Claude Opus 3       | 200,000 tokens | $15.00/million tokens | $75.00/million tokens |
-| Claude Haiku 3      | 200,000 tokens | $0.25/million tokens  | $1.25/million tokens  |
-`
+func (p *ClaudeModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *ClaudeModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -90,10 +76,10 @@ func (p *ClaudeModelProvider) calculatePrice(modelResult *ModelResult, lang stri
 	return nil
 }
 
-func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *ClaudeModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	client := anthropic.NewClient(
 		option.WithAPIKey(p.secretKey),
-		option.WithHTTPClient(proxy.ProxyHttpClient),
+		option.WithHTTPClient(getHttpClient(p.httpClient)),
 	)
 
 	if strings.HasPrefix(question, "$CloudDryRun$") {
@@ -140,7 +126,7 @@ func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, histo
 			},
 		}
 	}
-	stream := client.Messages.NewStreaming(context.TODO(), messageParams)
+	stream := client.Messages.NewStreaming(ctx, messageParams)
 
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
@@ -156,6 +142,7 @@ func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, histo
 	}
 
 	modelResult := &ModelResult{}
+	var answer strings.Builder
 	for stream.Next() {
 		event := stream.Current()
 
@@ -171,6 +158,7 @@ func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, histo
 					return nil, err
 				}
 			case anthropic.TextDelta:
+				answer.WriteString(deltaVariant.Text)
 				err := flushData("message", deltaVariant.Text)
 				if err != nil {
 					return nil, err
@@ -179,10 +167,16 @@ func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, histo
 		case anthropic.MessageDeltaEvent:
 			outputTokens := int(eventVariant.Usage.OutputTokens)
 			modelResult.ResponseTokenCount = outputTokens
+			modelResult.FinishReason = claudeFinishReason(eventVariant.Delta.StopReason)
 		}
 	}
 
 	if stream.Err() != nil {
+		if ctx.Err() != nil {
+			return canceledModelResult(ctx, modelResult, p.subType, question, answer.String(), func(modelResult *ModelResult) error {
+				return p.calculatePrice(modelResult, lang)
+			})
+		}
 		return nil, stream.Err()
 	}
 	modelResult.TotalTokenCount = modelResult.PromptTokenCount + modelResult.ResponseTokenCount
@@ -194,3 +188,19 @@ func (p *ClaudeModelProvider) QueryText(question string, writer io.Writer, histo
 
 	return modelResult, nil
 }
+
+// claudeFinishReason maps a Claude stop reason to the OpenAI finish reason.
+func claudeFinishReason(stopReason anthropic.StopReason) string {
+	switch stopReason {
+	case "":
+		return ""
+	case anthropic.StopReasonMaxTokens:
+		return "length"
+	case anthropic.StopReasonToolUse:
+		return "tool_calls"
+	case anthropic.StopReasonRefusal:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
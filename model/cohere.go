@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	cohere "github.com/cohere-ai/cohere-go/v2"
@@ -35,6 +36,7 @@ type CohereModelProvider struct {
 	maxTokens   int
 	verbose     bool
 	stop        []string
+	httpClient  *http.Client
 }
 
 type ChatMessage struct {
@@ -50,25 +52,8 @@ func NewCohereModelProvider(subType string, secretKey string) (*CohereModelProvi
 	}, nil
 }
 
-// GetPricing returns the pricing of the model
-// https://cohere.com/pricing
-func (c *CohereModelProvider) GetPricing() string {
-	return `URL:
-https://cohere.com/pricing
-
-Generate Model:
-
-| Model         | Input Price (Per 1,000,000 tokens) | Output Price (Per 1,000,000 tokens) |
-|---------------|------------------------------------|-------------------------------------|
-| Command Light | $0.30                              | $0.60                               |
-| Command       | $1.00                              | $2.00                               |
-
-Embed Model:
-
-| Model      | Cost (Per 1,000,000 tokens) |
-|------------|-----------------------------|
-| Default    | $0.10                       |
-`
+func (p *CohereModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *CohereModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -91,11 +76,11 @@ func (p *CohereModelProvider) calculatePrice(modelResult *ModelResult, lang stri
 	return nil
 }
 
-func (p *CohereModelProvider) QueryText(message string, writer io.Writer, chat_history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *CohereModelProvider) QueryText(ctx context.Context, message string, writer io.Writer, chat_history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	client := cohereclient.NewClient(
 		cohereclient.WithToken(p.secretKey),
+		cohereclient.WithHTTPClient(getHttpClient(p.httpClient)),
 	)
-	ctx := context.Background()
 
 	// if p.maxTokens > 0, use p.maxTokens, otherwise use model's default Maxtokens
 	maxTokens := getContextLength(p.subType)
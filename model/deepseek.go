@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type DeepSeekProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewDeepSeekProvider(subType string, apiKey string, temperature float32, topP float32) (*DeepSeekProvider, error) {
@@ -37,59 +40,50 @@ func NewDeepSeekProvider(subType string, apiKey string, temperature float32, top
 	}, nil
 }
 
-func (p *DeepSeekProvider) GetPricing() string {
-	return `URL:
-https://api-docs.deepseek.com/zh-cn/quick_start/pricing
-
-| Model            | sub-type           | Input Price per 1K characters    | Output Price per 1K characters |
-|------------------|--------------------|----------------------------------|--------------------------------|
-| deepseek-V3.2    | deepseek-chat      | 0.002 yuan/1,000 tokens          | 0.003 yuan/1,000 tokens        |
-| deepseek-V3.2    | deepseek-reasoner  | 0.002 yuan/1,000 tokens          | 0.003 yuan/1,000 tokens        |
-`
+func (p *DeepSeekProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *DeepSeekProvider) calculatePrice(modelResult *ModelResult, lang string) error {
-	price := 0.0
+	// USD per 1K tokens, cache miss (api.deepseek.com, DeepSeek-V3.2)
 	priceTable := map[string][2]float64{
-		"deepseek-chat":     {0.002, 0.003},
-		"deepseek-reasoner": {0.002, 0.003},
+		"deepseek-chat":     {0.00028, 0.00042},
+		"deepseek-reasoner": {0.00028, 0.00042},
 	}
 
-	if priceItem, ok := priceTable[p.subType]; ok {
-		inputPrice := getPrice(modelResult.TotalTokenCount, priceItem[0])
-		outputPrice := getPrice(modelResult.TotalTokenCount, priceItem[1])
-		price = inputPrice + outputPrice
-	} else {
+	priceItem, ok := priceTable[p.subType]
+	if !ok {
 		return fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "embedding:calculatePrice() error: unknown model type: %s"), p.subType))
 	}
 
-	modelResult.TotalPrice = price
-	modelResult.Currency = "CNY"
+	inputPrice := getPrice(modelResult.PromptTokenCount, priceItem[0])
+	outputPrice := getPrice(modelResult.ResponseTokenCount, priceItem[1])
+	modelResult.TotalPrice = AddPrices(inputPrice, outputPrice)
+	modelResult.Currency = "USD"
 	return nil
 }
 
-func (p *DeepSeekProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *DeepSeekProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.deepseek.com/v1"
 
-	var localType string
+	localType := "Custom"
 	if p.subType == "deepseek-reasoner" {
 		localType = "Custom-think"
-	} else if p.subType == "deepseek-chat" {
-		localType = "Custom"
 	}
-	localProvider, err := NewLocalModelProvider(localType, "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
+	localProvider, err := NewLocalModelProvider(localType, "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "USD")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
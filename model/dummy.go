@@ -15,6 +15,7 @@
 package model
 
 import (
+	"context"
 	"io"
 	"strings"
 )
@@ -29,17 +30,7 @@ func NewDummyModelProvider(subType string) (*DummyModelProvider, error) {
 	}, nil
 }
 
-func (c *DummyModelProvider) GetPricing() string {
-	return `URL:
-This is a dummy module provider.
-
-Generate Model:
-
-This is a dummy module provider.
-`
-}
-
-func (p *DummyModelProvider) QueryText(message string, writer io.Writer, chat_history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *DummyModelProvider) QueryText(ctx context.Context, message string, writer io.Writer, chat_history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	answer := "this is the answer for \"" + message + "\""
 	if strings.HasPrefix(message, "$CloudDryRun$") {
 		return &ModelResult{}, nil
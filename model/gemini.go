@@ -22,7 +22,6 @@ import (
 	"strings"
 
 	"github.com/hanzoai/cloud/i18n"
-	"github.com/hanzoai/cloud/proxy"
 	"google.golang.org/genai"
 )
 
@@ -32,6 +31,7 @@ type GeminiModelProvider struct {
 	temperature float32
 	topP        float32
 	topK        int
+	httpClient  *http.Client
 }
 
 func NewGeminiModelProvider(subType string, secretKey string, temperature float32, topP float32, topK int) (*GeminiModelProvider, error) {
@@ -45,25 +45,8 @@ func NewGeminiModelProvider(subType string, secretKey string, temperature float3
 	return p, nil
 }
 
-func (p *GeminiModelProvider) GetPricing() string {
-	return `URL: https://ai.google.dev/gemini-api/docs/pricing
-| Model                                    | Input Price (per 1M tokens)              | Output Price (per 1M tokens)            |
-|------------------------------------------|------------------------------------------|-----------------------------------------|
-| Gemini 2.5 Flash Preview                 | $0.15 (text/image/video), $1.00 (audio) | Non-thinking: $0.60, Thinking: $3.50    |
-| Gemini 2.5 Pro Preview                   | $1.25 (≤200k), $2.50 (>200k)           | $10.00 (≤200k), $15.00 (>200k)         |
-| Gemini 2.5 Flash Native Audio            | $0.50 (text), $3.00 (audio/video)      | $2.00 (text), $12.00 (audio)           |
-| Gemini 2.5 Flash Preview TTS             | $0.50 (text)                            | $10.00 (audio)                          |
-| Gemini 2.5 Pro Preview TTS               | $1.00 (text)                            | $20.00 (audio)                          |
-| Gemini 2.0 Flash                         | $0.10 (text/image/video), $0.70 (audio) | $0.40                                   |
-| Gemini 2.0 Flash-Lite                    | $0.075                                  | $0.30                                   |
-| Gemini 1.5 Flash                         | $0.075 (≤128k), $0.15 (>128k)          | $0.30 (≤128k), $0.60 (>128k)           |
-| Gemini 1.5 Flash-8B                      | $0.0375 (≤128k), $0.075 (>128k)        | $0.15 (≤128k), $0.30 (>128k)           |
-| Gemini 1.5 Pro                           | $1.25 (≤128k), $2.50 (>128k)           | $5.00 (≤128k), $10.00 (>128k)          |
-| Gemini 1.0 Pro Vision                    | $0.125                                  | $0.375                                  |
-| Imagen 3                                 | $0.03 per image                         | -                                       |
-| Veo 2                                    | $0.35 per second                        | -                                       |
-| Gemma 3 / Gemma 3n                       | Free                                    | Free                                    |
-`
+func (p *GeminiModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *GeminiModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -203,14 +186,13 @@ func (p *GeminiModelProvider) calculatePrice(modelResult *ModelResult, lang stri
 	return nil
 }
 
-func (p *GeminiModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	ctx := context.Background()
+func (p *GeminiModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	// Access your API key as an environment variable (see "Set up your API key" above)
 	client, err := genai.NewClient(ctx,
 		&genai.ClientConfig{
 			APIKey:     p.secretKey,
 			Backend:    genai.BackendGeminiAPI,
-			HTTPClient: proxy.ProxyHttpClient,
+			HTTPClient: getHttpClient(p.httpClient),
 		})
 	if err != nil {
 		return nil, err
@@ -15,7 +15,8 @@
 package model
 
 import (
-	"github.com/hanzoai/cloud/proxy"
+	"net/http"
+
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -40,22 +41,15 @@ func NewGitHubModelProvider(typ string, subType string, secretKey string, temper
 	return p, nil
 }
 
-func getGitHubClientFromToken(authToken string, providerUrl string) *openai.Client {
+func getGitHubClientFromToken(authToken string, providerUrl string, httpClient *http.Client) *openai.Client {
 	config := openai.DefaultConfig(authToken)
 	config.BaseURL = providerUrl
-	config.HTTPClient = proxy.ProxyHttpClient
+	config.HTTPClient = getHttpClient(httpClient)
 
 	c := openai.NewClientWithConfig(config)
 	return c
 }
 
-func (p *GitHubModelProvider) GetPricing() string {
-	return `GitHub model API usage are free but rate limited by requests per minute, requests per day, tokens per request, and concurrent requests.
-URL:
-https://docs.github.com/en/github-models/prototyping-with-ai-models#rate-limits
-`
-}
-
 func (p *GitHubModelProvider) calculatePrice(modelResult *ModelResult) error {
 	price := 0.0
 	modelResult.TotalPrice = price
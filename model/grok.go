@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/hanzoai/cloud/i18n"
@@ -27,6 +29,7 @@ type GrokModelProvider struct {
 	secretKey   string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewGrokModelProvider(subType string, secretKey string, temperature float32, topP float32) (*GrokModelProvider, error) {
@@ -38,31 +41,25 @@ func NewGrokModelProvider(subType string, secretKey string, temperature float32,
 	}, nil
 }
 
-func (p *GrokModelProvider) GetPricing() string {
-	return `URL:
-https://x.ai/pricing
-
-| Models              | Context | Input (Per 1,000 tokens) | Output (Per 1,000 tokens)|
-|---------------------|---------|--------------------------|--------------------------|
-| grok-3              | 131K    | $0.003                   | $0.015                   |
-| grok-3-fast         | 131K    | $0.005                   | $0.025                   |
-| grok-3-mini         | 131K    | $0.0003                  | $0.0005                  |
-| grok-3-mini-fast    | 131K    | $0.0006                  | $0.004                  |
-| grok-2-vision       | 32K     | $0.002                   | $0.01                    |
-| grok-2              | 131K    | $0.002                   | $0.01                    |
-
-Image models:
-
-| Models               | Price (per image) |
-|----------------------|-------------------|
-| grok-2-image         | $0.07             |
-`
+func (p *GrokModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *GrokModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
 	var inputPricePerThousandTokens, outputPricePerThousandTokens float64
 
-	if strings.Contains(p.subType, "grok-3") {
+	if strings.Contains(p.subType, "grok-code-fast") {
+		inputPricePerThousandTokens = 0.0002  // $0.0002 per 1,000 tokens
+		outputPricePerThousandTokens = 0.0015 // $0.0015 per 1,000 tokens
+	} else if strings.Contains(p.subType, "grok-4") {
+		if strings.Contains(p.subType, "fast") {
+			inputPricePerThousandTokens = 0.0002  // $0.0002 per 1,000 tokens
+			outputPricePerThousandTokens = 0.0005 // $0.0005 per 1,000 tokens
+		} else {
+			inputPricePerThousandTokens = 0.003  // $0.003 per 1,000 tokens
+			outputPricePerThousandTokens = 0.015 // $0.015 per 1,000 tokens
+		}
+	} else if strings.Contains(p.subType, "grok-3") {
 		if !strings.Contains(p.subType, "fast") && !strings.Contains(p.subType, "mini") {
 			inputPricePerThousandTokens = 0.003  // $0.003 per 1,000 tokens
 			outputPricePerThousandTokens = 0.015 // $0.015 per 1,000 tokens
@@ -98,23 +95,23 @@ func (p *GrokModelProvider) calculatePrice(modelResult *ModelResult, lang string
 	return nil
 }
 
-func (p *GrokModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *GrokModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	// Create a LocalModelProvider to handle the request
 	const BaseUrl = "https://api.x.ai/v1"
 	localProvider, err := NewLocalModelProvider("Custom", "custom-model", p.secretKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "USD")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-
-	return modelResult, nil
+	return modelResult, err
 }
@@ -0,0 +1,92 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hanzoai/cloud/i18n"
+)
+
+// groqPrices are Groq's prices in USD per 1,000 tokens: input, output.
+var groqPrices = map[string][2]float64{
+	"llama-3.3-70b-versatile":                       {0.00059, 0.00079},
+	"llama-3.1-8b-instant":                          {0.00005, 0.00008},
+	"meta-llama/llama-4-maverick-17b-128e-instruct": {0.0002, 0.0006},
+	"meta-llama/llama-4-scout-17b-16e-instruct":     {0.00011, 0.00034},
+	"openai/gpt-oss-120b":                           {0.00015, 0.00075},
+	"openai/gpt-oss-20b":                            {0.0001, 0.0005},
+	"qwen/qwen3-32b":                                {0.00029, 0.00059},
+	"moonshotai/kimi-k2-instruct":                   {0.001, 0.003},
+}
+
+type GroqModelProvider struct {
+	subType     string
+	secretKey   string
+	temperature float32
+	topP        float32
+	httpClient  *http.Client
+}
+
+func NewGroqModelProvider(subType string, secretKey string, temperature float32, topP float32) (*GroqModelProvider, error) {
+	return &GroqModelProvider{
+		subType:     subType,
+		secretKey:   secretKey,
+		temperature: temperature,
+		topP:        topP,
+	}, nil
+}
+
+func (p *GroqModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
+func (p *GroqModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
+	prices, ok := groqPrices[strings.ToLower(p.subType)]
+	if !ok {
+		return fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "embedding:calculatePrice() error: unknown model type: %s"), p.subType))
+	}
+
+	inputPrice := getPrice(modelResult.PromptTokenCount, prices[0])
+	outputPrice := getPrice(modelResult.ResponseTokenCount, prices[1])
+	modelResult.TotalPrice = AddPrices(inputPrice, outputPrice)
+	modelResult.Currency = "USD"
+	return nil
+}
+
+func (p *GroqModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	// Groq serves an OpenAI-compatible API
+	const BaseUrl = "https://api.groq.com/openai/v1"
+	localProvider, err := NewLocalModelProvider("Custom", "custom-model", p.secretKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "USD")
+	if err != nil {
+		return nil, err
+	}
+	localProvider.SetHttpClient(p.httpClient)
+
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
+		return nil, err
+	}
+
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
+	}
+	return modelResult, err
+}
@@ -18,10 +18,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/hanzoai/cloud/i18n"
-	"github.com/hanzoai/cloud/proxy"
 	"github.com/hupe1980/go-huggingface"
 )
 
@@ -29,18 +29,15 @@ type HuggingFaceModelProvider struct {
 	subType     string
 	secretKey   string
 	temperature float32
+	httpClient  *http.Client
 }
 
 func NewHuggingFaceModelProvider(subType string, secretKey string, temperature float32) (*HuggingFaceModelProvider, error) {
 	return &HuggingFaceModelProvider{subType: subType, secretKey: secretKey, temperature: temperature}, nil
 }
 
-func (p *HuggingFaceModelProvider) GetPricing() string {
-	return `URL:
-https://huggingface.co/pricing
-
-Not charged
-`
+func (p *HuggingFaceModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *HuggingFaceModelProvider) calculatePrice(modelResult *ModelResult) error {
@@ -48,10 +45,9 @@ func (p *HuggingFaceModelProvider) calculatePrice(modelResult *ModelResult) erro
 	return nil
 }
 
-func (p *HuggingFaceModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	ctx := context.Background()
+func (p *HuggingFaceModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	client := huggingface.NewInferenceClient(p.secretKey, func(o *huggingface.InferenceClientOptions) {
-		o.HTTPClient = proxy.ProxyHttpClient
+		o.HTTPClient = getHttpClient(p.httpClient)
 	})
 
 	if strings.HasPrefix(question, "$CloudDryRun$") {
@@ -26,6 +26,7 @@ import (
 	"unicode"
 
 	"github.com/hanzoai/cloud/i18n"
+	"github.com/hanzoai/cloud/proxy"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -44,6 +45,7 @@ type LocalModelProvider struct {
 	inputPricePerThousandTokens  float64
 	outputPricePerThousandTokens float64
 	currency                     string
+	httpClient                   *http.Client
 }
 
 func NewLocalModelProvider(typ string, subType string, secretKey string, temperature float32, topP float32, frequencyPenalty float32, presencePenalty float32, providerUrl string, compatibleProvider string, inputPricePerThousandTokens float64, outputPricePerThousandTokens float64, Currency string) (*LocalModelProvider, error) {
@@ -64,22 +66,24 @@ func NewLocalModelProvider(typ string, subType string, secretKey string, tempera
 	return p, nil
 }
 
-func getLocalClientFromUrl(authToken string, url string) *openai.Client {
+func (p *LocalModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
+func getLocalClientFromUrl(authToken string, url string, httpClient *http.Client) *openai.Client {
 	config := openai.DefaultConfig(authToken)
 	config.BaseURL = url
 
-	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	httpClient := http.Client{Transport: transport}
-	config.HTTPClient = &httpClient
+	if httpClient == nil {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		httpClient = &http.Client{Transport: &proxy.RateLimitTransport{Base: transport}}
+	}
+	config.HTTPClient = httpClient
 
 	c := openai.NewClientWithConfig(config)
 	return c
 }
 
-func (p *LocalModelProvider) GetPricing() string {
-	return getOpenAIModelPrice()
-}
-
 func (p *LocalModelProvider) CalculatePrice(modelResult *ModelResult, lang string) error {
 	// Use provider-configured pricing for custom models and DigitalOcean
 	if p.subType == "custom-model" || p.typ == "DigitalOcean" || p.inputPricePerThousandTokens > 0 {
@@ -160,28 +164,42 @@ func flushDataThink(data string, eventType string, writer io.Writer, lang string
 	return nil
 }
 
-func (p *LocalModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+// canceledResult completes modelResult with the tokens of the answer streamed
+// before ctx was canceled and returns it with ctx's error.
+func (p *LocalModelProvider) canceledResult(ctx context.Context, modelResult *ModelResult, model string, answer string, lang string) (*ModelResult, error) {
+	responseTokenCount, err := GetTokenSize(model, answer)
+	if err != nil {
+		return nil, ctx.Err()
+	}
+	modelResult.ResponseTokenCount += responseTokenCount
+	modelResult.TotalTokenCount = modelResult.PromptTokenCount + modelResult.ResponseTokenCount
+	if err = p.CalculatePrice(modelResult, lang); err != nil {
+		return nil, ctx.Err()
+	}
+	return modelResult, ctx.Err()
+}
+
+func (p *LocalModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	var client *openai.Client
 	var flushData interface{} // Can be either flushData or flushDataThink
 
 	if p.typ == "Local" || p.typ == "DigitalOcean" {
-		client = getLocalClientFromUrl(p.secretKey, p.providerUrl)
+		client = getLocalClientFromUrl(p.secretKey, p.providerUrl, p.httpClient)
 		flushData = flushDataThink
 	} else if p.typ == "Azure" {
-		client = getAzureClientFromToken(p.deploymentName, p.secretKey, p.providerUrl, p.apiVersion)
+		client = getAzureClientFromToken(p.deploymentName, p.secretKey, p.providerUrl, p.apiVersion, p.httpClient)
 		flushData = flushDataAzure
 	} else if p.typ == "GitHub" {
-		client = getGitHubClientFromToken(p.secretKey, p.providerUrl)
+		client = getGitHubClientFromToken(p.secretKey, p.providerUrl, p.httpClient)
 		flushData = flushDataOpenai
 	} else if p.typ == "Custom" {
-		client = getLocalClientFromUrl(p.secretKey, p.providerUrl)
+		client = getLocalClientFromUrl(p.secretKey, p.providerUrl, p.httpClient)
 		flushData = flushDataOpenai
 	} else if p.typ == "Custom-think" {
-		client = getLocalClientFromUrl(p.secretKey, p.providerUrl)
+		client = getLocalClientFromUrl(p.secretKey, p.providerUrl, p.httpClient)
 		flushData = flushDataThink
 	}
 
-	ctx := context.Background()
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
@@ -276,12 +294,18 @@ func (p *LocalModelProvider) QueryText(question string, writer io.Writer, histor
 				if streamErr == io.EOF {
 					break
 				}
+				if ctx.Err() != nil {
+					return p.canceledResult(ctx, modelResult, model, answerData.String(), lang)
+				}
 				return nil, streamErr
 			}
 
 			if len(completion.Choices) == 0 {
 				continue
 			}
+			if reason := completion.Choices[0].FinishReason; reason != "" {
+				modelResult.FinishReason = string(reason)
+			}
 			if completion.Choices[0].Delta.ToolCalls != nil {
 				for _, toolCall := range completion.Choices[0].Delta.ToolCalls {
 					toolCalls, toolCallsMap = handleToolCallsParameters(toolCall, toolCalls, toolCallsMap)
@@ -298,6 +322,9 @@ func (p *LocalModelProvider) QueryText(question string, writer io.Writer, histor
 					reasoningData := completion.Choices[0].Delta.ReasoningContent
 					err = flushThink(reasoningData, "reason", writer, lang)
 					if err != nil {
+						if ctx.Err() != nil {
+							return p.canceledResult(ctx, modelResult, model, answerData.String(), lang)
+						}
 						return nil, err
 					}
 				}
@@ -315,6 +342,9 @@ func (p *LocalModelProvider) QueryText(question string, writer io.Writer, histor
 
 					err = flushThink(data, "message", writer, lang)
 					if err != nil {
+						if ctx.Err() != nil {
+							return p.canceledResult(ctx, modelResult, model, answerData.String()+data, lang)
+						}
 						return nil, err
 					}
 
@@ -335,6 +365,9 @@ func (p *LocalModelProvider) QueryText(question string, writer io.Writer, histor
 
 				err = flushStandard(data, writer, lang)
 				if err != nil {
+					if ctx.Err() != nil {
+						return p.canceledResult(ctx, modelResult, model, answerData.String()+data, lang)
+					}
 					return nil, err
 				}
 
@@ -96,9 +96,9 @@ func handleToolCallsParameters(toolCall openai.ToolCall, toolCalls []openai.Tool
 	return toolCalls, toolCallsMap
 }
 
-func QueryTextWithTools(p ModelProvider, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func QueryTextWithTools(ctx context.Context, p ModelProvider, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	var messages []*RawMessage
-	modelResult, err := p.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	modelResult, err := p.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +135,7 @@ func QueryTextWithTools(p ModelProvider, question string, writer io.Writer, hist
 			}
 		}
 		agentInfo.AgentMessages.Messages = messages
-		modelResult, err = p.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+		modelResult, err = p.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
 		if err != nil {
 			return nil, err
 		}
@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -25,6 +27,7 @@ type MiniMaxModelProvider struct {
 	subType     string
 	apiKey      string
 	temperature float32
+	httpClient  *http.Client
 }
 
 func NewMiniMaxModelProvider(subType string, groupID string, apiKey string, temperature float32) (*MiniMaxModelProvider, error) {
@@ -35,17 +38,8 @@ func NewMiniMaxModelProvider(subType string, groupID string, apiKey string, temp
 	}, nil
 }
 
-func (p *MiniMaxModelProvider) GetPricing() string {
-	return `URL:
-https://www.minimax.io/price
-
-| Model              | Input Price         | Output Price         |
-|--------------------|---------------------|----------------------|
-| MiniMax-Text-01    | 1 CNY/1M tokens     | 7 CNY/1M tokens      |
-| abab6.5s-chat      | 0.1 CNY/1M tokens   | 0.1 CNY/1M tokens    |
-| abab6.5g-chat      | 0.5 CNY/1M tokens   | 0.5 CNY/1M tokens    |
-| abab6.5t-chat      | 0.5 CNY/1M tokens   | 0.5 CNY/1M tokens    |
-`
+func (p *MiniMaxModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *MiniMaxModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -70,22 +64,23 @@ func (p *MiniMaxModelProvider) calculatePrice(modelResult *ModelResult, lang str
 	return nil
 }
 
-func (p *MiniMaxModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *MiniMaxModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.minimax.chat/v1"
 
 	localProvider, err := NewLocalModelProvider("Custom", "", p.apiKey, p.temperature, 0, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
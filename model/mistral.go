@@ -15,45 +15,31 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"strings"
+	"net/http"
 
-	"github.com/gage-technologies/mistral-go"
 	"github.com/hanzoai/cloud/i18n"
 )
 
+// MistralModelProvider queries api.mistral.ai through its OpenAI-compatible
+// chat completions API.
 type MistralModelProvider struct {
-	client    *mistral.MistralClient
-	modelName string
+	apiKey     string
+	modelName  string
+	httpClient *http.Client
 }
 
 func NewMistralProvider(apiKey, modelName string) (*MistralModelProvider, error) {
-	client := mistral.NewMistralClientDefault(apiKey)
-
 	return &MistralModelProvider{
-		client:    client,
+		apiKey:    apiKey,
 		modelName: modelName,
 	}, nil
 }
 
-func (c *MistralModelProvider) GetPricing() string {
-	return `URL: https://mistral.ai/technology/#pricing
-
-	| Model                               | Input Price($) per 1K tokens  | Output Price($) per 1K tokens  |
-	|-------------------------------------|-------------------------------|--------------------------------|
-	| mistral-large-latest                | 0.002                         | 0.006                          |
-	| pixtral-large-latest                | 0.002                         | 0.006                          |
-	| mistral-small-latest                | 0.0002                        | 0.0006                         |
-	| codestral-latest                    | 0.0003                        | 0.0009                         |
-	| ministral-8b-latest                 | 0.0001                        | 0.0001                         |
-	| ministral-3b-latest                 | 0.00004                       | 0.00004                        |
-	| pixtral-12b                         | 0.00015                       | 0.00015                        |
-	| mistral-nemo                        | 0.00015                       | 0.00015                        |
-	| open-mistral-7b                     | 0.00025                       | 0.00025                        |
-	| open-mixtral-8x7b                   | 0.0007                        | 0.0007                         |
-	| open-mixtral-8x22b                  | 0.002                         | 0.006                          |
-	`
+func (c *MistralModelProvider) SetHttpClient(client *http.Client) {
+	c.httpClient = client
 }
 
 func (c *MistralModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -73,8 +59,8 @@ func (c *MistralModelProvider) calculatePrice(modelResult *ModelResult, lang str
 	}
 
 	if priceItem, ok := priceTable[c.modelName]; ok {
-		inputPrice := getPrice(modelResult.TotalTokenCount, priceItem[0])
-		outputPrice := getPrice(modelResult.TotalTokenCount, priceItem[1])
+		inputPrice := getPrice(modelResult.PromptTokenCount, priceItem[0])
+		outputPrice := getPrice(modelResult.ResponseTokenCount, priceItem[1])
 		price = inputPrice + outputPrice
 	} else {
 		return fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "embedding:calculatePrice() error: unknown model type: %s"), c.modelName))
@@ -85,30 +71,23 @@ func (c *MistralModelProvider) calculatePrice(modelResult *ModelResult, lang str
 	return nil
 }
 
-func (c *MistralModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	chatRes, err := c.client.Chat(c.modelName, []mistral.ChatMessage{{Content: question, Role: mistral.RoleUser}}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "model:error getting chat completion: %v"), err))
-	}
-
-	respText := chatRes.Choices[0].Message.Content
-	respText = strings.TrimSpace(respText)
-
-	_, err = fmt.Fprint(writer, respText)
+func (c *MistralModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+	const BaseUrl = "https://api.mistral.ai/v1"
+	// Create a new LocalModelProvider to handle the request
+	localProvider, err := NewLocalModelProvider("Custom", "custom-model", c.apiKey, 0, 0, 0, 0, BaseUrl, c.modelName, 0, 0, "USD")
 	if err != nil {
-		return nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "model:failed to write response: %v"), err))
+		return nil, err
 	}
+	localProvider.SetHttpClient(c.httpClient)
 
-	modelResult, err := getDefaultModelResult(c.modelName, question, respText)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = c.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "embedding:failed to calculate price: %v"), err))
+	// A canceled call still returns the tokens it generated.
+	if priceErr := c.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	modelResult.PromptTokenCount += len(question)
-
-	return modelResult, nil
+	return modelResult, err
 }
@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type MoonshotModelProvider struct {
 	subType     string
 	secretKey   string
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewMoonshotModelProvider(subType string, secretKey string, temperature float32, topP float32) (*MoonshotModelProvider, error) {
@@ -38,24 +41,8 @@ func NewMoonshotModelProvider(subType string, secretKey string, temperature floa
 	return client, nil
 }
 
-func (p *MoonshotModelProvider) GetPricing() string {
-	return `URL: 
-https://platform.moonshot.cn/docs/pricing/chat
-
-Model
-
-| Model                  | Unit Of Charge | Input Price | Output Price |
-|------------------------|----------------|-------------|--------------|
-| moonshot-v1-8k         | 1M tokens      | 2 yuan      | 10 yuan      |
-| moonshot-v1-32k        | 1M tokens      | 5 yuan      | 20 yuan      |
-| moonshot-v1-128k       | 1M tokens      | 10 yuan     | 30 yuan      |
-| kimi-k2-0905-preview   | 1M tokens      | 4 yuan      | 16 yuan      |
-| kimi-k2-0711-preview   | 1M tokens      | 4 yuan      | 16 yuan      |
-| kimi-k2-turbo-preview  | 1M tokens      | 8 yuan      | 58 yuan      |
-| kimi-k2-thinking       | 1M tokens      | 4 yuan      | 16 yuan      |
-| kimi-k2-thinking-turbo | 1M tokens      | 8 yuan      | 58 yuan      |
-| kimi-latest            | 1M tokens      | Auto (Tier) | Auto (Tier)  |
-`
+func (p *MoonshotModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *MoonshotModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -102,22 +89,23 @@ func (p *MoonshotModelProvider) calculatePrice(modelResult *ModelResult, lang st
 	return nil
 }
 
-func (p *MoonshotModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *MoonshotModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.moonshot.cn/v1"
 
 	localProvider, err := NewLocalModelProvider("Custom-think", "custom-model", p.secretKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
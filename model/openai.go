@@ -41,6 +41,7 @@ type OpenAiModelProvider struct {
 	topP             float32
 	frequencyPenalty float32
 	presencePenalty  float32
+	httpClient       *http.Client
 }
 
 func NewOpenAiModelProvider(subType string, secretKey string, providerUrl string, temperature float32, topP float32, frequencyPenalty float32, presencePenalty float32) (*OpenAiModelProvider, error) {
@@ -214,62 +215,15 @@ func CalculateOpenAIModelPrice(model string, modelResult *ModelResult, lang stri
 	return nil
 }
 
-func getOpenAIModelPrice() string {
-	return `URL:
-https://azure.microsoft.com/en-us/pricing/details/cognitive-services/openai-service/
-
-Language models:
-
-| Models                | Context | Input (Per 1,000 tokens) | Output (Per 1,000 tokens) |
-|-----------------------|---------|--------------------------|--------------------------|
-| GPT-3.5-Turbo         | 16K     | $0.0005                  | $0.0015                  |
-| GPT-4                 | 8K      | $0.03                    | $0.06                    |
-| GPT-4                 | 32K     | $0.06                    | $0.12                    |
-| GPT-4-Turbo           | 128K    | $0.01                    | $0.03                    |
-| GPT-4o                | 128K    | $0.0025                  | $0.0075                  |
-| GPT-4o-mini           | 128K    | $0.000075                | $0.0003                  |
-| GPT-4.1               | 100K    | $0.002                   | $0.008                   |
-| GPT-4.1-mini          | 100K    | $0.0004	                 | $0.0016                  |
-| GPT-4.1-nano          | 100K    | $0.0001                  | $0.0004                  |
-| GPT-4.5               | 100K    | $0.002                   | $0.008                   |
-| GPT-4.5-mini          | 100K    | $0.0004                  | $0.0016                  |
-| GPT-4.5-nano          | 100K    | $0.0001                  | $0.0004                  |
-| o1                    | 200K    | $0.015                   | $0.060                   |
-| o1-pro                | 200K    | $0.15                    | $0.6                     |
-| o3                    | 200K    | $0.002                   | $0.008                   |
-| o3-mini               | 200K    | $0.0011                  | $0.0044                  |
-| o4-mini               | 200K    | $0.0011                  | $0.0044                  |
-| GPT-5                 | 400K    | $0.00125                 | $0.01                    |
-| GPT-5-mini            | 400K    | $0.00025                 | $0.002                   |
-| GPT-5-nano            | 400K    | $0.00005                 | $0.0004                  |
-| GPT-5.1               | 400K    | $0.00125                 | $0.01                    |
-| GPT-5.1-mini          | 400K    | $0.00025                 | $0.002                   |
-| GPT-5.1-nano          | 400K    | $0.00005                 | $0.0004                  |
-| GPT-5.2               | 400K    | $0.00125                 | $0.01                    |
-| GPT-5.2-mini          | 400K    | $0.00025                 | $0.002                   |
-| GPT-5.2-nano          | 400K    | $0.00005                 | $0.0004                  |
-| GPT-5.2-chat          | 400K    | $0.00125                 | $0.01                    |
-| GPT-5-chat-latest     | 400K    | $0.00125                 | $0.01                    |
-| Deep-Research         | 200K    | $0.002                   | $0.008                   |
-Image models:
-
-| Models       | Quality | Resolution               | Price (per image) |
-|--------------|---------|--------------------------|------------------|
-| Dall-E-3     | Standard| 1024 * 1024              | N/A              |
-|              | Standard| 1024 * 1792, 1792 * 1024 | $0.08            |
-| Dall-E-3     | HD      | 1024 * 1024              | N/A              |
-|              | HD      | 1024 * 1792, 1792 * 1024 | N/A              |
-| Dall-E-2     | Standard| 1024 * 1024              | N/A              |
-| GPT-Image-1  | Standard| 1024 * 1024              | $0.08            |
-`
+func (p *OpenAiModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
-func (p *OpenAiModelProvider) GetPricing() string {
-	return getOpenAIModelPrice()
+func GetOpenAiClientFromToken(authToken string, providerUrl string) openai.Client {
+	return getOpenAiClient(authToken, providerUrl, proxy.ProxyHttpClient)
 }
 
-func GetOpenAiClientFromToken(authToken string, providerUrl string) openai.Client {
-	httpClient := proxy.ProxyHttpClient
+func getOpenAiClient(authToken string, providerUrl string, httpClient *http.Client) openai.Client {
 	opts := []option.RequestOption{option.WithHTTPClient(httpClient), option.WithAPIKey(authToken)}
 	if providerUrl != "" {
 		opts = append(opts, option.WithBaseURL(providerUrl))
@@ -278,14 +232,17 @@ func GetOpenAiClientFromToken(authToken string, providerUrl string) openai.Clien
 	return c
 }
 
-func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *OpenAiModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	var client openai.Client
 	var flushData interface{}
 
-	client = GetOpenAiClientFromToken(p.secretKey, p.providerUrl)
+	if p.httpClient != nil {
+		client = getOpenAiClient(p.secretKey, p.providerUrl, p.httpClient)
+	} else {
+		client = GetOpenAiClientFromToken(p.secretKey, p.providerUrl)
+	}
 	flushData = flushDataThink
 
-	ctx := context.Background()
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
@@ -367,6 +324,7 @@ func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, histo
 		respStream := client.Responses.NewStreaming(ctx, req)
 		defer respStream.Close()
 
+		var answer strings.Builder
 		isLeadingReturn := true
 		for respStream.Next() {
 			flushThink := flushData.(func(string, string, io.Writer, string) error)
@@ -388,6 +346,7 @@ func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, histo
 					}
 				}
 
+				answer.WriteString(data)
 				err = flushThink(data, "message", writer, lang)
 				if err != nil {
 					return nil, err
@@ -419,10 +378,27 @@ func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, histo
 				modelResult.ResponseTokenCount = int(variant.Response.Usage.OutputTokens)
 				modelResult.PromptTokenCount = int(variant.Response.Usage.InputTokens)
 				modelResult.TotalTokenCount = int(variant.Response.Usage.TotalTokens)
+				modelResult.FinishReason = "stop"
+				if len(toolCalls) > 0 {
+					modelResult.FinishReason = "tool_calls"
+				}
 				break
+			case responses.ResponseIncompleteEvent:
+				modelResult.ResponseTokenCount = int(variant.Response.Usage.OutputTokens)
+				modelResult.PromptTokenCount = int(variant.Response.Usage.InputTokens)
+				modelResult.TotalTokenCount = int(variant.Response.Usage.TotalTokens)
+				modelResult.FinishReason = "length"
+				if variant.Response.IncompleteDetails.Reason == "content_filter" {
+					modelResult.FinishReason = "content_filter"
+				}
 			}
 		}
 		if respStream.Err() != nil {
+			if ctx.Err() != nil {
+				return canceledModelResult(ctx, modelResult, model, question, answer.String(), func(modelResult *ModelResult) error {
+					return CalculateOpenAIModelPrice(model, modelResult, lang)
+				})
+			}
 			return nil, respStream.Err()
 		}
 
@@ -506,6 +482,7 @@ func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, histo
 			}
 
 			if completion.Choices[0].FinishReason != "" {
+				finishReason := string(completion.Choices[0].FinishReason)
 				if completion.Choices[0].FinishReason == openai.CompletionChoiceFinishReasonStop {
 					modelResult.PromptTokenCount = int(completion.Usage.PromptTokens)
 					modelResult.ResponseTokenCount = int(completion.Usage.CompletionTokens)
@@ -517,11 +494,17 @@ func (p *OpenAiModelProvider) QueryText(question string, writer io.Writer, histo
 						return nil, err
 					}
 				}
+				modelResult.FinishReason = finishReason
 				break
 			}
 		}
 
 		if respStream.Err() != nil {
+			if ctx.Err() != nil {
+				return canceledModelResult(ctx, modelResult, model, question, response.String(), func(modelResult *ModelResult) error {
+					return CalculateOpenAIModelPrice(model, modelResult, lang)
+				})
+			}
 			return nil, respStream.Err()
 		}
 
@@ -16,9 +16,11 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/hanzoai/cloud/i18n"
@@ -33,6 +35,7 @@ type OpenRouterModelProvider struct {
 	siteUrl     string
 	temperature *float32
 	topP        *float32
+	httpClient  *http.Client
 }
 
 func NewOpenRouterModelProvider(subType string, secretKey string, temperature float32, topP float32) (*OpenRouterModelProvider, error) {
@@ -47,33 +50,8 @@ func NewOpenRouterModelProvider(subType string, secretKey string, temperature fl
 	return p, nil
 }
 
-func (p *OpenRouterModelProvider) GetPricing() string {
-	return `URL:
-https://openrouter.ai/docs#models
-
-| Model Name                   | Prompt cost ($ per 1k tokens) | Completion cost ($ per 1k tokens) | Context (tokens) | Moderation |
-|------------------------------|-------------------------------|-----------------------------------|------------------|------------|
-| google/palm-2-codechat-bison | $0.00025                      | $0.0005                           | 28,672           | None       |
-| google/palm-2-chat-bison     | $0.00025                      | $0.0005                           | 36,864           | None       |
-| openai/gpt-3.5-turbo         | $0.001                        | $0.002                            | 4,095            | Moderated  |
-| openai/gpt-3.5-turbo-16k     | $0.0005                       | $0.0015                           | 16,385           | Moderated  |
-| openai/gpt-4                 | $0.03                         | $0.06                             | 8,191            | Moderated  |
-| openai/gpt-4-32k             | $0.06                         | $0.12                             | 32,767           | Moderated  |
-| anthropic/claude-2           | $0.008                        | $0.024                            | 200,000          | Moderated  |
-| anthropic/claude-instant-v1  | $0.0008                       | $0.0024                           | 100,000          | Moderated  |
-| meta-llama/llama-2-13b-chat  | $0.0007                       | $0.0009                           | 4,096            | None       |
-| meta-llama/llama-2-70b-chat  | $0.0007                       | $0.0009                           | 4,096            | None       |
-| palm-2-codechat-bison        | $0.00025                      | $0.0005                           | 28,672           | None       |
-| palm-2-chat-bison            | $0.00025                      | $0.0005                           | 36,864           | None       |
-| gpt-3.5-turbo                | $0.001                        | $0.002                            | 4,095            | Moderated  |
-| gpt-3.5-turbo-16k            | $0.0005                       | $0.0015                           | 16,385           | Moderated  |
-| gpt-4                        | $0.03                         | $0.06                             | 8,191            | Moderated  |
-| gpt-4-32k                    | $0.06                         | $0.12                             | 32,767           | Moderated  |
-| claude-2                     | $0.008                        | $0.024                            | 200,000          | Moderated  |
-| claude-instant-v1            | $0.0008                       | $0.0024                           | 100,000          | Moderated  |
-| llama-2-13b-chat             | $0.0007                       | $0.0009                           | 4,096            | None       |
-| llama-2-70b-chat             | $0.0007                       | $0.0009                           | 4,096            | None       |
-`
+func (p *OpenRouterModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *OpenRouterModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -121,16 +99,15 @@ func (p *OpenRouterModelProvider) getProxyClientFromToken() *openrouter.Client {
 		panic(err)
 	}
 
-	config.HTTPClient = proxy.ProxyHttpClient
+	config.HTTPClient = getHttpClient(p.httpClient)
 
 	c := openrouter.NewClientWithConfig(config)
 	return c
 }
 
-func (p *OpenRouterModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *OpenRouterModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	client := p.getProxyClientFromToken()
 
-	ctx := context.Background()
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
@@ -202,6 +179,11 @@ func (p *OpenRouterModelProvider) QueryText(question string, writer io.Writer, h
 			if streamErr == io.EOF {
 				break
 			}
+			if ctx.Err() != nil {
+				return canceledModelResult(ctx, &ModelResult{}, p.subType, question, responseStringBuilder.String(), func(modelResult *ModelResult) error {
+					return p.calculatePrice(modelResult, lang)
+				})
+			}
 			return nil, streamErr
 		}
 
@@ -236,3 +218,54 @@ func (p *OpenRouterModelProvider) QueryText(question string, writer io.Writer, h
 
 	return modelResult, nil
 }
+
+const openRouterModelsUrl = "https://openrouter.ai/api/v1/models"
+
+// FetchPrices reads the prices of all models listed by OpenRouter.
+func (p *OpenRouterModelProvider) FetchPrices(ctx context.Context) ([]ModelPrice, error) {
+	return fetchOpenRouterPrices(ctx)
+}
+
+// fetchOpenRouterPrices reads the prices of all models listed by
+// OpenRouter, which publishes them in USD per token.
+func fetchOpenRouterPrices(ctx context.Context) ([]ModelPrice, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openRouterModelsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := proxy.ProxyHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openrouter models: status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data []struct {
+			Id      string `json:"id"`
+			Pricing struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	prices := []ModelPrice{}
+	for _, m := range response.Data {
+		input, err := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		if err != nil || input < 0 {
+			continue
+		}
+		output, err := strconv.ParseFloat(m.Pricing.Completion, 64)
+		if err != nil || output < 0 {
+			continue
+		}
+		prices = append(prices, ModelPrice{Model: m.Id, InputPerMillion: input * 1e6, OutputPerMillion: output * 1e6})
+	}
+	return prices, nil
+}
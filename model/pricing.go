@@ -0,0 +1,125 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is the upstream price of one model, in USD per million tokens.
+type ModelPrice struct {
+	Model            string  `json:"model"`
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+}
+
+// PriceSource is implemented by model providers whose upstream publishes
+// its prices in machine-readable form. FetchPrices returns the current
+// price of every model the upstream lists; the gateway's price sync compares
+// them with the central pricing table.
+type PriceSource interface {
+	FetchPrices(ctx context.Context) ([]ModelPrice, error)
+}
+
+// openRouterPriceVendors maps the types of providers whose upstream has no
+// machine-readable price list to the vendor OpenRouter lists its models
+// under, at the vendor's own prices.
+var openRouterPriceVendors = map[string]string{
+	"Claude":   "anthropic",
+	"Cohere":   "cohere",
+	"DeepSeek": "deepseek",
+	"Gemini":   "google",
+	"Grok":     "x-ai",
+	"Mistral":  "mistralai",
+	"Moonshot": "moonshotai",
+	"OpenAI":   "openai",
+}
+
+// GetPriceSource returns where the published prices of a provider of typ
+// come from: the provider itself when it is a PriceSource, else OpenRouter's
+// list of its vendor's models.
+func GetPriceSource(typ string, provider ModelProvider) (PriceSource, bool) {
+	if source, ok := provider.(PriceSource); ok {
+		return source, true
+	}
+	if vendor, ok := openRouterPriceVendors[typ]; ok {
+		return vendorPriceSource{vendor: vendor}, true
+	}
+	return nil, false
+}
+
+// vendorPriceSource lists the prices of one vendor's models on OpenRouter
+// under the vendor's own model IDs. Only upstream IDs matching those
+// exactly are priced.
+type vendorPriceSource struct {
+	vendor string
+}
+
+func (s vendorPriceSource) FetchPrices(ctx context.Context) ([]ModelPrice, error) {
+	prices, err := fetchOpenRouterPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterVendorPrices(prices, s.vendor), nil
+}
+
+func filterVendorPrices(prices []ModelPrice, vendor string) []ModelPrice {
+	res := []ModelPrice{}
+	for _, price := range prices {
+		if id, ok := strings.CutPrefix(price.Model, vendor+"/"); ok {
+			price.Model = id
+			res = append(res, price)
+		}
+	}
+	return res
+}
+
+// PriceLookup returns the central price of an upstream model ID.
+type PriceLookup func(upstreamModel string) (ModelPrice, bool)
+
+var (
+	priceLookup   PriceLookup
+	priceLookupMu sync.RWMutex
+)
+
+// SetPriceLookup installs the lookup into the central pricing table, which
+// providers without their own price table bill from.
+func SetPriceLookup(lookup PriceLookup) {
+	priceLookupMu.Lock()
+	defer priceLookupMu.Unlock()
+	priceLookup = lookup
+}
+
+// lookupCentralPrice prices modelResult for upstreamModel from the central
+// pricing table, reporting whether the table has the model.
+func lookupCentralPrice(upstreamModel string, modelResult *ModelResult) bool {
+	priceLookupMu.RLock()
+	lookup := priceLookup
+	priceLookupMu.RUnlock()
+	if lookup == nil {
+		return false
+	}
+	price, ok := lookup(upstreamModel)
+	if !ok {
+		return false
+	}
+	inputPrice := getPrice(modelResult.PromptTokenCount, price.InputPerMillion/1000)
+	outputPrice := getPrice(modelResult.ResponseTokenCount, price.OutputPerMillion/1000)
+	modelResult.TotalPrice = AddPrices(inputPrice, outputPrice)
+	modelResult.Currency = "USD"
+	return true
+}
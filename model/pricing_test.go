@@ -0,0 +1,48 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterVendorPrices(t *testing.T) {
+	prices := []ModelPrice{
+		{Model: "openai/gpt-4o", InputPerMillion: 2.50, OutputPerMillion: 10},
+		{Model: "openai/gpt-4o-mini", InputPerMillion: 0.15, OutputPerMillion: 0.60},
+		{Model: "anthropic/claude-sonnet-4", InputPerMillion: 3, OutputPerMillion: 15},
+		{Model: "openai-compatible/gpt-4o", InputPerMillion: 1, OutputPerMillion: 1},
+	}
+	want := []ModelPrice{
+		{Model: "gpt-4o", InputPerMillion: 2.50, OutputPerMillion: 10},
+		{Model: "gpt-4o-mini", InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	}
+	if got := filterVendorPrices(prices, "openai"); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterVendorPrices() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetPriceSource(t *testing.T) {
+	if _, ok := GetPriceSource("OpenAI", nil); !ok {
+		t.Error("OpenAI providers have no price source")
+	}
+	if _, ok := GetPriceSource("Ollama", nil); ok {
+		t.Error("Ollama providers have a price source")
+	}
+	if source, ok := GetPriceSource("OpenRouter", &OpenRouterModelProvider{}); !ok || source == nil {
+		t.Error("OpenRouter providers have no price source")
+	}
+}
@@ -15,7 +15,11 @@
 package model
 
 import (
+	"context"
 	"io"
+	"net/http"
+
+	"github.com/hanzoai/cloud/proxy"
 )
 
 // DryRunPrefix is a special prefix that triggers model providers to estimate
@@ -29,6 +33,10 @@ type ModelResult struct {
 	ImageCount         int
 	TotalPrice         float64
 	Currency           string
+	// FinishReason is why generation stopped, in OpenAI's terms ("stop",
+	// "length", "tool_calls" or "content_filter"); empty when the provider
+	// did not say.
+	FinishReason string
 }
 
 func newModelResult(promptTokenCount int, responseTokenCount int, totalTokenCount int) *ModelResult {
@@ -39,9 +47,45 @@ func newModelResult(promptTokenCount int, responseTokenCount int, totalTokenCoun
 	}
 }
 
+// ModelProvider answers questions with a model. Its upstream call stops when
+// ctx is canceled, e.g. because the client disconnected; the result of a
+// canceled call counts the tokens generated before it stopped and is
+// returned along with ctx's error, so they can still be billed.
 type ModelProvider interface {
-	GetPricing() string
-	QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error)
+	QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error)
+}
+
+// canceledModelResult completes modelResult with the tokens of the answer
+// streamed before ctx was canceled, and of question when the upstream has
+// not reported the prompt's, prices it and returns it with ctx's error.
+func canceledModelResult(ctx context.Context, modelResult *ModelResult, model string, question string, answer string, calculatePrice func(*ModelResult) error) (*ModelResult, error) {
+	counted, err := getDefaultModelResult(model, question, answer)
+	if err != nil {
+		return nil, ctx.Err()
+	}
+	if modelResult.PromptTokenCount == 0 {
+		modelResult.PromptTokenCount = counted.PromptTokenCount
+	}
+	modelResult.ResponseTokenCount = counted.ResponseTokenCount
+	modelResult.TotalTokenCount = modelResult.PromptTokenCount + modelResult.ResponseTokenCount
+	if err = calculatePrice(modelResult); err != nil {
+		return nil, ctx.Err()
+	}
+	return modelResult, ctx.Err()
+}
+
+// HttpClientSetter is implemented by model providers that can send their
+// upstream calls through a client tuned for the provider record.
+type HttpClientSetter interface {
+	SetHttpClient(client *http.Client)
+}
+
+// getHttpClient returns client, or the shared proxy client when none was set.
+func getHttpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return proxy.ProxyHttpClient
+	}
+	return client
 }
 
 func GetModelProvider(typ string, subType string, clientId string, clientSecret string, userKey string, temperature float32, topP float32, topK int, frequencyPenalty float32, presencePenalty float32, providerUrl string, apiVersion string, compatibleProvider string, inputPricePerThousandTokens float64, outputPricePerThousandTokens float64, Currency string, enableThinking bool) (ModelProvider, error) {
@@ -49,6 +93,9 @@ func GetModelProvider(typ string, subType string, clientId string, clientSecret
 	var err error
 	if typ == "Ollama" {
 		p, err = NewLocalModelProvider("Custom-think", "custom-model", "randomString", temperature, topP, 0, 0, providerUrl, subType, inputPricePerThousandTokens, outputPricePerThousandTokens, Currency)
+	} else if typ == "Self-hosted" {
+		// providerUrl is the runtime's /v1 base URL, see object.GetSelfHostedBaseUrl
+		p, err = NewLocalModelProvider("Custom-think", "custom-model", clientSecret, temperature, topP, frequencyPenalty, presencePenalty, providerUrl, subType, inputPricePerThousandTokens, outputPricePerThousandTokens, Currency)
 	} else if typ == "Local" {
 		p, err = NewLocalModelProvider(typ, subType, clientSecret, temperature, topP, frequencyPenalty, presencePenalty, providerUrl, compatibleProvider, inputPricePerThousandTokens, outputPricePerThousandTokens, Currency)
 	} else if typ == "OpenAI" {
@@ -67,6 +114,8 @@ func GetModelProvider(typ string, subType string, clientId string, clientSecret
 		p, err = NewClaudeModelProvider(subType, clientSecret, enableThinking, topK)
 	} else if typ == "Grok" {
 		p, err = NewGrokModelProvider(subType, clientSecret, temperature, topP)
+	} else if typ == "Groq" {
+		p, err = NewGroqModelProvider(subType, clientSecret, temperature, topP)
 	} else if typ == "OpenRouter" {
 		p, err = NewOpenRouterModelProvider(subType, clientSecret, temperature, topP)
 	} else if typ == "Baidu Cloud" {
@@ -0,0 +1,74 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestProvidersTakeHttpClient(t *testing.T) {
+	providers := []ModelProvider{
+		&AlibabacloudModelProvider{}, &AmazonBedrockModelProvider{}, &BaichuanModelProvider{},
+		&BaiduCloudModelProvider{}, &ChatGLMModelProvider{}, &ClaudeModelProvider{},
+		&CohereModelProvider{}, &DeepSeekProvider{}, &FireworksModelProvider{},
+		&GeminiModelProvider{}, &GitHubModelProvider{}, &GrokModelProvider{},
+		&GroqModelProvider{}, &HuggingFaceModelProvider{}, &iFlytekModelProvider{},
+		&LocalModelProvider{}, &MiniMaxModelProvider{}, &MistralModelProvider{},
+		&MoonshotModelProvider{}, &OpenAiModelProvider{}, &OpenRouterModelProvider{},
+		&SiliconFlowProvider{}, &StepFunModelProvider{}, &TencentCloudClient{},
+		&VolcengineModelProvider{}, &WriterModelProvider{}, &YiProvider{},
+	}
+	for _, provider := range providers {
+		if _, ok := provider.(HttpClientSetter); !ok {
+			t.Errorf("%T ignores the HTTP client settings of its provider", provider)
+		}
+	}
+}
+
+func TestCanceledModelResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	priced := false
+	result, err := canceledModelResult(ctx, &ModelResult{PromptTokenCount: 42}, "gpt-4o", "hello", "hello world", func(modelResult *ModelResult) error {
+		priced = true
+		return nil
+	})
+	if err != context.Canceled || result == nil || !priced {
+		t.Fatalf("canceledModelResult() = %+v, %v, priced %v, want a priced result and context.Canceled", result, err, priced)
+	}
+	if result.PromptTokenCount != 42 || result.ResponseTokenCount == 0 || result.TotalTokenCount != 42+result.ResponseTokenCount {
+		t.Errorf("canceledModelResult() = %+v, want the reported prompt and the counted answer", result)
+	}
+}
+
+func TestClaudeFinishReason(t *testing.T) {
+	cases := map[anthropic.StopReason]string{
+		"":                               "",
+		anthropic.StopReasonEndTurn:      "stop",
+		anthropic.StopReasonStopSequence: "stop",
+		anthropic.StopReasonMaxTokens:    "length",
+		anthropic.StopReasonToolUse:      "tool_calls",
+		anthropic.StopReasonRefusal:      "content_filter",
+	}
+	for stopReason, want := range cases {
+		if got := claudeFinishReason(stopReason); got != want {
+			t.Errorf("claudeFinishReason(%q) = %q, want %q", stopReason, got, want)
+		}
+	}
+}
@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type SiliconFlowProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewSiliconFlowProvider(subType string, apiKey string, temperature float32, topP float32) (*SiliconFlowProvider, error) {
@@ -37,34 +40,8 @@ func NewSiliconFlowProvider(subType string, apiKey string, temperature float32,
 	}, nil
 }
 
-func (p *SiliconFlowProvider) GetPricing() string {
-	return `URL:
-https://cloud.siliconflow.cn/models
-
-| Model      | sub-type             					   | Input Price per 1K characters     | Output Price per 1K characters   |
-|------------|---------------------------------------------|-----------------------------------|----------------------------------|
-| DeepSeek   | deepseek-ai/DeepSeek-R1      			   | 0.00400yuan/1,000 tokens          | 0.01600yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-V3					   | 0.00200yuan/1,000 tokens          | 0.00800yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-R1-Distill-Llama-70B   | 0.00413yuan/1,000 tokens          | 0.00413yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-R1-Distill-Qwen-32B	   | 0.00126yuan/1,000 tokens          | 0.00126yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-R1-Distill-Qwen-14B	   | 0.00070yuan/1,000 tokens          | 0.00070yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-R1-Distill-Llama-8B    | 0.00000yuan/1,000 tokens		   | 0.00000yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-R1-Distill-Qwen-7B     | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-| DeepSeek   | deepseek-ai/DeepSeek-V2.5      			   | 0.00133yuan/1,000 tokens          | 0.00133yuan/1,000 tokens         |
-| Meta-Llama | meta-llama/Llama-3.3-70B-Instruct		   | 0.00413yuan/1,000 tokens          | 0.00413yuan/1,000 tokens         |
-| Meta-Llama | meta-llama/Meta-Llama-3.1-405B-Instruct     | 0.02100yuan/1,000 tokens          | 0.02100yuan/1,000 tokens         |
-| Meta-Llama | meta-llama/Meta-Llama-3.1-70B-Instruct      | 0.00413yuan/1,000 tokens          | 0.00413yuan/1,000 tokens         |
-| Meta-Llama | meta-llama/Meta-Llama-3.1-8B-Instruct       | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-| Qwen	 	 | Qwen/Qwen2.5-72B-Instruct				   | 0.00413yuan/1,000 tokens          | 0.00413yuan/1,000 tokens         |
-| Qwen   	 | Qwen/Qwen2.5-32B-Instruct      			   | 0.00126yuan/1,000 tokens          | 0.00126yuan/1,000 tokens         |
-| Qwen   	 | Qwen/Qwen2.5-14B-Instruct      			   | 0.00070yuan/1,000 tokens          | 0.00070yuan/1,000 tokens         |
-| Qwen   	 | Qwen/Qwen2.5-7B-Instruct      			   | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-| GLM   	 | THUDM/glm-4-9b-chat      				   | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-| Yi   	 	 | 01-ai/Yi-1.5-34B-Chat-16K     			   | 0.00126yuan/1,000 tokens          | 0.00126yuan/1,000 tokens         |
-| Yi   	 	 | 01-ai/Yi-1.5-9B-Chat-16K      			   | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-| Gemma   	 | google/gemma-2-27b-it      				   | 0.00126yuan/1,000 tokens          | 0.00126yuan/1,000 tokens         |
-| Gemma   	 | google/gemma-2-9b-it      				   | 0.00000yuan/1,000 tokens          | 0.00000yuan/1,000 tokens         |
-`
+func (p *SiliconFlowProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *SiliconFlowProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -106,22 +83,23 @@ func (p *SiliconFlowProvider) calculatePrice(modelResult *ModelResult, lang stri
 	return nil
 }
 
-func (p *SiliconFlowProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *SiliconFlowProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.siliconflow.cn/v1"
 	// Create a new LocalModelProvider to handle the request
 	localProvider, err := NewLocalModelProvider("Custom-think", "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "USD")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
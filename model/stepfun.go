@@ -14,8 +14,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -25,6 +27,7 @@ type StepFunModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewStepFunModelProvider(subType string, apiKey string, temperature float32, topP float32) (*StepFunModelProvider, error) {
@@ -36,19 +39,8 @@ func NewStepFunModelProvider(subType string, apiKey string, temperature float32,
 	}, nil
 }
 
-func (p *StepFunModelProvider) GetPricing() string {
-	return `URL:
-https://platform.stepfun.com/docs/pricing/details
-
-| Model        | Input Price per 1K characters    | Output Price per 1K characters |
-|--------------|----------------------------------|--------------------------------|
-| step-1-8k    | 0.005yuan/1,000 tokens           | 0.02yuan/1,000 tokens          |
-| step-1-32k   | 0.015yuan/1,000 tokens           | 0.07yuan/1,000 tokens          |
-| step-1-256k  | 0.095yuan/1,000 tokens           | 0.3yuan/1,000 tokens           |
-| step-2-mini  | 0.001yuan/1,000 tokens           | 0.002yuan/1,000 tokens         |
-| step-2-16k   | 0.038yuan/1,000 tokens           | 0.12yuan/1,000 tokens          |
-| step-2-16k-exp   | 0.038yuan/1,000 tokens       | 0.12yuan/1,000 tokens          |
-`
+func (p *StepFunModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *StepFunModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -75,22 +67,23 @@ func (p *StepFunModelProvider) calculatePrice(modelResult *ModelResult, lang str
 	return nil
 }
 
-func (p *StepFunModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *StepFunModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.stepfun.com/v1"
 	// Create a new LocalModelProvider to handle the request
 	localProvider, err := NewLocalModelProvider("Custom", "custom-model", p.apiKey, p.temperature, p.topP, 0, 0, BaseUrl, p.subType, 0, 0, "CNY")
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
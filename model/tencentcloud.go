@@ -15,7 +15,9 @@
 package model
 
 import (
+	"context"
 	"io"
+	"net/http"
 	"strings"
 )
 
@@ -25,6 +27,7 @@ type TencentCloudClient struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewTencentCloudProvider(secretKey, endpoint, subType string, temperature, topP float32) (*TencentCloudClient, error) {
@@ -40,11 +43,11 @@ func NewTencentCloudProvider(secretKey, endpoint, subType string, temperature, t
 	}, nil
 }
 
-func (c *TencentCloudClient) GetPricing() string {
-	return `Pricing information for Tencent Cloud models is not yet available.`
+func (c *TencentCloudClient) SetHttpClient(client *http.Client) {
+	c.httpClient = client
 }
 
-func (c *TencentCloudClient) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (c *TencentCloudClient) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	baseUrl := c.endpoint
 	// Get model name
 	model := ""
@@ -59,8 +62,9 @@ func (c *TencentCloudClient) QueryText(question string, writer io.Writer, histor
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(c.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
 	if err != nil {
 		return nil, err
 	}
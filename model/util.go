@@ -23,9 +23,16 @@ import (
 
 	"github.com/hanzoai/cloud/i18n"
 	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
 	"github.com/sashabaranov/go-openai"
 )
 
+func init() {
+	// Use the BPE files built into the binary rather than downloading
+	// them on first use.
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
 type RawMessage struct {
 	Text           string
 	Author         string
@@ -101,7 +108,12 @@ func getCompatibleModel(model string) string {
 	// Handle generic model families
 	if strings.Contains(model, "gpt-3.5-turbo") {
 		return openai.GPT3Dot5Turbo
-	} else if strings.Contains(model, "gpt-5") || strings.Contains(model, "gpt-4") || strings.Contains(model, "deep-research") || strings.Contains(model, "o1") || strings.Contains(model, "o3") || strings.Contains(model, "o4") {
+	} else if strings.Contains(model, "gpt-4o") || strings.Contains(model, "gpt-4.1") || strings.Contains(model, "gpt-4.5") ||
+		strings.Contains(model, "gpt-5") || strings.Contains(model, "gpt-oss") || strings.Contains(model, "o1") ||
+		strings.Contains(model, "o3") || strings.Contains(model, "o4") {
+		// o200k_base models
+		return openai.GPT4o
+	} else if strings.Contains(model, "gpt-4") || strings.Contains(model, "deep-research") {
 		return openai.GPT4
 	}
 
@@ -109,6 +121,22 @@ func getCompatibleModel(model string) string {
 	return openai.GPT4
 }
 
+// GetTokenizerModel returns the OpenAI model whose tokenizer counts model,
+// an upstream model ID such as "gpt-4o", "openai/gpt-4.1" or
+// "accounts/fireworks/models/gpt-oss-120b". ok is false for every other
+// model: GetTokenSize only approximates those with an OpenAI encoding.
+func GetTokenizerModel(model string) (tokenizerModel string, ok bool) {
+	name := strings.ToLower(model)
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "openai-")
+	for _, prefix := range []string{"gpt-", "chatgpt-", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return getCompatibleModel(name), true
+		}
+	}
+	return "", false
+}
+
 func GetTokenSize(model string, prompt string) (int, error) {
 	modelToUse := getCompatibleModel(model)
 	tkm, err := tiktoken.EncodingForModel(modelToUse)
@@ -0,0 +1,54 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestGetTokenizerModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+		ok    bool
+	}{
+		{"gpt-4o-mini", openai.GPT4oMini, true},
+		{"openai/gpt-4.1", openai.GPT4o, true},
+		{"openai-gpt-5", openai.GPT4o, true},
+		{"o3-mini", openai.GPT4o, true},
+		{"accounts/fireworks/models/gpt-oss-120b", openai.GPT4o, true},
+		{"gpt-4-turbo", openai.GPT4Turbo, true},
+		{"gpt-3.5-turbo-16k", openai.GPT3Dot5Turbo16K, true},
+		{"claude-sonnet-4", "", false},
+		{"accounts/fireworks/models/glm-5", "", false},
+		{"qwen3-235b-a22b", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := GetTokenizerModel(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("GetTokenizerModel(%q) = %q, %v, want %q, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGetTokenSizeOffline(t *testing.T) {
+	for _, model := range []string{"gpt-4o", "gpt-4"} {
+		if n, err := GetTokenSize(model, "What is the weather in Paris?"); err != nil || n <= 0 {
+			t.Errorf("GetTokenSize(%q) = %d, %v", model, n, err)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/i18n"
@@ -34,6 +35,7 @@ type VolcengineModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewVolcengineModelProvider(subType string, endpointID string, apiKey string, temperature float32, topP float32) (*VolcengineModelProvider, error) {
@@ -46,59 +48,16 @@ func NewVolcengineModelProvider(subType string, endpointID string, apiKey string
 	}, nil
 }
 
+func (p *VolcengineModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func trimModelDate(subType string) string {
 	// change "doubao-seed-1-6-250615" to "doubao-seed-1-6"
 	re := regexp.MustCompile(`-\d{6}$`)
 	return re.ReplaceAllString(subType, "")
 }
 
-func (p *VolcengineModelProvider) GetPricing() string {
-	return `URL:
-https://www.volcengine.com/docs/82379/1099320
-
-| Model                          | Input Price per 1K tokens (yuan) | Output Price per 1K tokens (yuan) |
-|--------------------------------|----------------------------------|-----------------------------------|
-| doubao-seed-1.6-vision         | 0.0008                          | 0.0080                           |
-| doubao-seed-1-6                | 0.0008                          | 0.0020                           |
-| doubao-seed-1-6-thinking       | 0.0008                          | 0.0080                           |
-| doubao-seed-1-6-flash          | 0.0002                          | 0.0015                           |
-| doubao-1-5-thinking-pro        | 0.0040                          | 0.0160                           |
-| doubao-1-5-thinking-vision-pro | 0.0030                          | 0.0090                           |
-| deepseek-v3.1                  | 0.0040                          | 0.0120                           |
-| deepseek-v3.2                  | 0.0040                          | 0.0120                           |
-| deepseek-r1                    | 0.0040                          | 0.0160                           |
-| deepseek-r1-distill-qwen-32b   | 0.0015                          | 0.0060                           |
-| deepseek-r1-distill-qwen-7b    | 0.0006                          | 0.0024                           |
-| doubao-1-5-pro-32k             | 0.0008                          | 0.0020                           |
-| doubao-1-5-pro-256k            | 0.0050                          | 0.0090                           |
-| doubao-1-5-lite-32k            | 0.0003                          | 0.0006                           |
-| doubao-pro-32k                 | 0.0008                          | 0.0020                           |
-| doubao-pro-256k                | 0.0050                          | 0.0090                           |
-| doubao-lite-4k                 | 0.0003                          | 0.0006                           |
-| doubao-lite-32k                | 0.0003                          | 0.0006                           |
-| doubao-lite-128k               | 0.0008                          | 0.0010                           |
-| kimi-k2                        | 0.0040                          | 0.0120                           |
-| deepseek-v3                    | 0.0020                          | 0.0080                           |
-| doubao-1-5-vision-pro          | 0.0030                          | 0.0090                           |
-| doubao-1-5-vision-lite         | 0.0015                          | 0.0045                           |
-| doubao-1-5-ui-tars             | 0.0035                          | 0.0120                           |
-| doubao-1-5-vision-pro-32k      | 0.0030                          | 0.0090                           |
-| doubao-vision-pro-32k          | 0.0030                          | 0.0090                           |
-| doubao-vision-lite-32k         | 0.0015                          | 0.0045                           |
-| doubao-embedding               | 0.0005                          | 0.0000                           |
-| doubao-embedding-large         | 0.0007                          | 0.0000                           |
-| doubao-embedding-vision        | 0.0007                          | 0.0000                           |
-| doubao-seedance-1-0-pro        | 0.0150                          | 0.0000                           |
-| doubao-seedance-1-0-lite-t2v   | 0.0100                          | 0.0000                           |
-| doubao-seaweed                 | 0.0300                          | 0.0000                           |
-| wan2-1-14b                     | 0.0500                          | 0.0000                           |
-| doubao-seedream-4.0            | 0.2000                          | 0.0000                           |
-| doubao-seedream-3-0-t2i        | 0.2590                          | 0.0000                           |
-| doubao-seededit-3.0-i2i        | 0.3000                          | 0.0000                           |
-| doubao-realtime                | 0.0300                          | 0.0300                           |
-`
-}
-
 func (p *VolcengineModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
 	price := 0.0
 	priceTable := map[string][2]float64{
@@ -177,13 +136,14 @@ func (p *VolcengineModelProvider) calculatePrice(modelResult *ModelResult, lang
 	return nil
 }
 
-func (p *VolcengineModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
-	ctx := context.Background()
+func (p *VolcengineModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return nil, fmt.Errorf("%s", i18n.Translate(lang, "model:writer does not implement http.Flusher"))
 	}
-	client := arkruntime.NewClientWithApiKey(p.apiKey)
+	client := arkruntime.NewClientWithApiKey(p.apiKey, func(config *arkruntime.ClientConfig) {
+		config.HTTPClient = getHttpClient(p.httpClient)
+	})
 
 	// set request params
 	messages := []*model.ChatCompletionMessage{
@@ -218,6 +178,7 @@ func (p *VolcengineModelProvider) QueryText(question string, writer io.Writer, h
 	}
 	defer stream.Close()
 	modelResult := newModelResult(0, 0, 0)
+	var answer strings.Builder
 
 	for {
 		response, err := stream.Recv()
@@ -232,6 +193,11 @@ func (p *VolcengineModelProvider) QueryText(question string, writer io.Writer, h
 			if err == io.EOF {
 				break
 			}
+			if ctx.Err() != nil {
+				return canceledModelResult(ctx, modelResult, p.subType, question, answer.String(), func(modelResult *ModelResult) error {
+					return p.calculatePrice(modelResult, lang)
+				})
+			}
 			return nil, err
 		}
 
@@ -240,6 +206,7 @@ func (p *VolcengineModelProvider) QueryText(question string, writer io.Writer, h
 		}
 
 		data := response.Choices[0].Delta.Content
+		answer.WriteString(data)
 		err = flushData(data)
 		if err != nil {
 			return nil, err
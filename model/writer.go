@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type WriterModelProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewWriterModelProvider(subType string, apiKey string, temperature float32, topP float32) (*WriterModelProvider, error) {
@@ -37,18 +40,8 @@ func NewWriterModelProvider(subType string, apiKey string, temperature float32,
 	}, nil
 }
 
-func (p *WriterModelProvider) GetPricing() string {
-	return `URL:
-https://writer.com/pricing/
-
-| Model                    | Input Price per 1M tokens | Output Price per 1M tokens |
-|--------------------------|---------------------------|----------------------------|
-| Palmyra X5               | $0.60                     | $6.00                      |
-| Palmyra X4               | $2.50                     | $10.00                     |
-| Palmyra Med              | $2.50                     | $10.00                     |
-| Palmyra Fin              | $2.50                     | $10.00                     |
-| Palmyra Creative         | $2.50                     | $10.00                     |
-`
+func (p *WriterModelProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *WriterModelProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -76,7 +69,7 @@ func (p *WriterModelProvider) calculatePrice(modelResult *ModelResult, lang stri
 	return nil
 }
 
-func (p *WriterModelProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *WriterModelProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	const BaseUrl = "https://api.writer.com/v1"
 
 	// Create a LocalModelProvider to handle the OpenAI-compatible API
@@ -84,17 +77,16 @@ func (p *WriterModelProvider) QueryText(question string, writer io.Writer, histo
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	// Override price calculation with Writer-specific pricing
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-
-	return modelResult, nil
+	return modelResult, err
 }
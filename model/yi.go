@@ -15,8 +15,10 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/hanzoai/cloud/i18n"
 )
@@ -26,6 +28,7 @@ type YiProvider struct {
 	apiKey      string
 	temperature float32
 	topP        float32
+	httpClient  *http.Client
 }
 
 func NewYiProvider(subType string, apiKey string, temperature float32, topP float32) (*YiProvider, error) {
@@ -37,14 +40,8 @@ func NewYiProvider(subType string, apiKey string, temperature float32, topP floa
 	}, nil
 }
 
-func (p *YiProvider) GetPricing() string {
-	return `URL:
-https://platform.lingyiwanwu.com
-    
-| Model          | Context Window | Input Price (per 1M tokens) | Output Price (per 1M tokens) |
-|----------------|---------------|---------------------------|----------------------------|
-| yi-lightning   | 16K          | ¥0.99                    | ¥0.99                     |
-| yi-vision-v2   | 16K          | ¥6.00                    | ¥6.00                     |`
+func (p *YiProvider) SetHttpClient(client *http.Client) {
+	p.httpClient = client
 }
 
 func (p *YiProvider) calculatePrice(modelResult *ModelResult, lang string) error {
@@ -65,7 +62,7 @@ func (p *YiProvider) calculatePrice(modelResult *ModelResult, lang string) error
 	}
 }
 
-func (p *YiProvider) QueryText(question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
+func (p *YiProvider) QueryText(ctx context.Context, question string, writer io.Writer, history []*RawMessage, prompt string, knowledgeMessages []*RawMessage, agentInfo *AgentInfo, lang string) (*ModelResult, error) {
 	// Configure Yi API client
 	const BaseUrl = "https://api.lingyiwanwu.com/v1"
 
@@ -74,15 +71,16 @@ func (p *YiProvider) QueryText(question string, writer io.Writer, history []*Raw
 	if err != nil {
 		return nil, err
 	}
+	localProvider.SetHttpClient(p.httpClient)
 
-	modelResult, err := localProvider.QueryText(question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
-	if err != nil {
+	modelResult, err := localProvider.QueryText(ctx, question, writer, history, prompt, knowledgeMessages, agentInfo, lang)
+	if modelResult == nil {
 		return nil, err
 	}
 
-	err = p.calculatePrice(modelResult, lang)
-	if err != nil {
-		return nil, err
+	// A canceled call still returns the tokens it generated.
+	if priceErr := p.calculatePrice(modelResult, lang); priceErr != nil {
+		return nil, priceErr
 	}
-	return modelResult, nil
+	return modelResult, err
 }
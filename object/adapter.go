@@ -20,6 +20,7 @@ import (
 	"runtime"
 
 	"github.com/beego/beego"
+	"github.com/beego/beego/logs"
 	_ "github.com/denisenkom/go-mssqldb" // mssql
 	_ "github.com/go-sql-driver/mysql"   // mysql
 	"github.com/hanzoai/cloud/conf"
@@ -76,6 +77,10 @@ func CreateTables() {
 		}
 	}
 	adapter.createTable()
+	adapter.addColumns()
+	if providerAdapter != nil {
+		providerAdapter.addColumns()
+	}
 }
 
 // Adapter represents the database adapter for storage.
@@ -176,7 +181,11 @@ func (a *Adapter) createTable() {
 		"template", "application", "node", "machine", "image", "container",
 		"pod", "task", "scale", "form", "workflow", "article", "session",
 		"connection", "record", "graph", "hospital", "doctor", "patient",
-		"caase", "consultation", "asset", "scan", "model_route",
+		"caase", "consultation", "asset", "scan", "model_route", "secret_audit",
+		"request_log", "request_log_setting", "pii_setting", "admin_audit", "model_entitlement",
+		"tenant_quota", "tenant_residency", "kms_project", "org_member_limit",
+		"storage_retention", "guardrail_policy", "webhook", "webhook_delivery", "prompt_template",
+		"eval_suite", "eval_run", "experiment", "upstream_cost",
 	}
 	for _, table := range tables {
 		var count int
@@ -189,6 +198,59 @@ func (a *Adapter) createTable() {
 	}
 }
 
+// addedColumns are the columns added to existing tables, with their kind:
+// "int", "varchar" or "text". A database whose tables predate them gets them
+// from addColumns.
+var addedColumns = []struct {
+	table  string
+	column string
+	kind   string
+}{
+	{"provider", "http_timeout", "int"},
+	{"provider", "http_max_idle_conns", "int"},
+	{"provider", "http_keep_alive", "int"},
+	{"provider", "http_proxy_url", "varchar"},
+	{"provider", "http_ca_cert", "text"},
+}
+
+// columnDefinition returns the SQL definition of a column of kind, which
+// leaves existing rows with the zero value.
+func (a *Adapter) columnDefinition(kind string) string {
+	switch kind {
+	case "int":
+		return "INT NOT NULL DEFAULT 0"
+	case "varchar":
+		return "VARCHAR(255) NOT NULL DEFAULT ''"
+	default:
+		if a.driverName == "mysql" {
+			// MySQL takes no literal default for TEXT; existing rows get ''.
+			return "TEXT NOT NULL"
+		}
+		return "TEXT NOT NULL DEFAULT ''"
+	}
+}
+
+// addColumns adds the addedColumns missing from the existing tables of the
+// database. Tables it does not hold are skipped.
+func (a *Adapter) addColumns() {
+	for _, added := range addedColumns {
+		table := a.db.QuoteTableName(added.table)
+		if _, err := a.db.NewQuery(fmt.Sprintf("SELECT 1 FROM %s LIMIT 1", table)).Execute(); err != nil {
+			continue
+		}
+		column := a.db.QuoteColumnName(added.column)
+		if _, err := a.db.NewQuery(fmt.Sprintf("SELECT %s FROM %s LIMIT 1", column, table)).Execute(); err == nil {
+			continue
+		}
+		_, err := a.db.NewQuery(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, a.columnDefinition(added.kind))).Execute()
+		if err != nil {
+			logs.Warn("failed to add column %s.%s: %v", added.table, added.column, err)
+			continue
+		}
+		logs.Info("added column %s.%s", added.table, added.column)
+	}
+}
+
 // RawDB returns the underlying *sql.DB for direct access when needed.
 func (a *Adapter) RawDB() *sql.DB {
 	return a.db.DB()
@@ -0,0 +1,167 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// AdminAudit records one admin mutation of gateway configuration. The table
+// is append-only: nothing in the application updates or deletes its rows.
+type AdminAudit struct {
+	Id           int    `db:"pk" json:"id"`
+	Owner        string `json:"owner"` // owner of the changed resource
+	CreatedTime  string `json:"createdTime"`
+	RequestId    string `json:"requestId"`
+	Actor        string `json:"actor"`
+	Action       string `json:"action"`       // "add", "update", "delete" or "reload"
+	ResourceType string `json:"resourceType"` // e.g. "provider", "model-route", "permission"
+	ResourceId   string `json:"resourceId"`
+	ClientIp     string `json:"clientIp"`
+	Changes      string `json:"changes"` // JSON array of AdminAuditChange
+}
+
+// AdminAuditChange is one field that differs between the before and after
+// states of a mutation.
+type AdminAuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// adminAuditSecretFields are JSON fields whose values are masked in diffs,
+// whatever the value looks like: a DSN or proxy URL carries its password
+// inline, and a certificate field may hold its private key. Changes to them
+// are still recorded.
+var adminAuditSecretFields = map[string]bool{
+	"clientSecret":   true,
+	"providerKey":    true,
+	"userKey":        true,
+	"userCert":       true,
+	"signKey":        true,
+	"signCert":       true,
+	"configText":     true,
+	"httpProxyUrl":   true,
+	"apiKey":         true,
+	"token":          true,
+	"accessToken":    true,
+	"secret":         true,
+	"secretValue":    true,
+	"password":       true,
+	"remotePassword": true,
+	"credentials":    true,
+}
+
+// maskAdminAuditValue hides the values of secret fields. An empty value is
+// kept, so clearing or first setting a secret still shows.
+func maskAdminAuditValue(field string, value interface{}) interface{} {
+	if !adminAuditSecretFields[field] || value == nil || value == "" {
+		return value
+	}
+	return "***"
+}
+
+func toAdminAuditFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// DiffAdminAudit returns the top-level JSON fields that differ between
+// before and after, sorted by field name. Either side may be nil, for adds
+// and deletes.
+func DiffAdminAudit(before, after interface{}) ([]*AdminAuditChange, error) {
+	beforeFields, err := toAdminAuditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toAdminAuditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	changes := []*AdminAuditChange{}
+	for name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes = append(changes, &AdminAuditChange{
+			Field:  name,
+			Before: maskAdminAuditValue(name, b),
+			After:  maskAdminAuditValue(name, a),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// AddAdminAudit stores an audit row with the diff between before and after.
+func AddAdminAudit(audit *AdminAudit, before, after interface{}) error {
+	if adapter == nil || adapter.db == nil {
+		return nil
+	}
+
+	changes, err := DiffAdminAudit(before, after)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	audit.Id = 0
+	audit.Changes = string(data)
+	audit.CreatedTime = time.Now().UTC().Format(time.RFC3339)
+	return insertRow(adapter.db, audit)
+}
+
+func GetAdminAuditCount(owner, field, value string) (int64, error) {
+	session := GetDbQuery(owner, -1, -1, field, value, "", "")
+	return queryCount(session, "admin_audit")
+}
+
+func GetPaginationAdminAudits(owner string, offset, limit int, field, value, sortField, sortOrder string) ([]*AdminAudit, error) {
+	audits := []*AdminAudit{}
+	session := GetDbQuery(owner, offset, limit, field, value, sortField, sortOrder)
+	err := queryFind(session, "admin_audit", &audits)
+	if err != nil {
+		return audits, err
+	}
+	return audits, nil
+}
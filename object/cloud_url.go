@@ -0,0 +1,32 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"strings"
+
+	"github.com/hanzoai/cloud/conf"
+)
+
+// GetCloudUrl returns the external URL of the deployment, without a
+// trailing slash, for the absolute URLs it hands out: cloudUrl from
+// app.conf / env, else fallback (the origin of the request's own Host).
+// Request origins are never used: they are chosen by the caller.
+func GetCloudUrl(fallback string) string {
+	if cloudUrl := strings.TrimRight(conf.GetConfigString("cloudUrl"), "/"); cloudUrl != "" {
+		return cloudUrl
+	}
+	return fallback
+}
@@ -0,0 +1,29 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestGetCloudUrl(t *testing.T) {
+	t.Setenv("cloudUrl", "")
+	if got := GetCloudUrl("https://api.example.com"); got != "https://api.example.com" {
+		t.Errorf("without cloudUrl: %q", got)
+	}
+
+	t.Setenv("cloudUrl", "https://console.example.com/")
+	if got := GetCloudUrl("https://api.example.com"); got != "https://console.example.com" {
+		t.Errorf("with cloudUrl: %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+
+	"github.com/hanzoai/cloud/util"
+	"github.com/hanzoai/dbx"
+)
+
+// ConversationCategory is the category of the chats kept for API callers by
+// the conversation API, which are owned by the caller's organization rather
+// than by "admin" like the chats of the web UI.
+const ConversationCategory = "Conversation"
+
+// GetConversation returns the conversation owner/name, or nil if there is
+// none.
+func GetConversation(owner string, name string) (*Chat, error) {
+	chat, err := getChat(owner, name)
+	if err != nil || chat == nil || chat.Category != ConversationCategory {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// GetConversationMessages returns the messages of a conversation, oldest
+// first.
+func GetConversationMessages(chat *Chat) ([]*Message, error) {
+	messages := []*Message{}
+	err := findAll(adapter.db, "message", &messages, dbx.HashExp{"owner": chat.Owner, "chat": chat.Name}, "created_time ASC")
+	if err != nil {
+		return messages, err
+	}
+	return messages, nil
+}
+
+// AppendConversationMessages adds messages to the end of a conversation, in
+// order, and counts them in chat, in one transaction. Their owner, chat,
+// user and creation times are set from chat, and when the organization
+// scrubs logs, their text is scrubbed of PII like the request log.
+func AppendConversationMessages(chat *Chat, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	pii, err := GetActivePiiSetting(chat.Owner)
+	if err != nil {
+		return err
+	}
+
+	last := chat.UpdatedTime
+	if last == "" {
+		last = chat.CreatedTime
+	}
+	for _, message := range messages {
+		last = util.GetCurrentTimeBasedOnLastMilli(last)
+		message.Owner = chat.Owner
+		message.Name = fmt.Sprintf("message_%s", util.GenerateUUID())
+		message.CreatedTime = last
+		message.Organization = chat.Organization
+		message.Store = chat.Store
+		message.User = chat.User
+		message.Chat = chat.Name
+		if pii != nil && pii.ScrubLogs {
+			message.Text = pii.Scrub(message.Text, PiiReport{})
+		}
+		message.TextTokenCount, err = getMessageTextTokenCount(message.ModelProvider, message.Text)
+		if err != nil {
+			return err
+		}
+	}
+
+	updatedTime := util.GetCurrentTime()
+	err = adapter.db.Transactional(func(tx *dbx.Tx) error {
+		for _, message := range messages {
+			if err := tx.Model(message).Insert(); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Update("chat", dbx.Params{
+			"updated_time":  updatedTime,
+			"message_count": dbx.NewExp("message_count + {:count}", dbx.Params{"count": len(messages)}),
+		}, pk2(chat.Owner, chat.Name)).Execute()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	chat.UpdatedTime = updatedTime
+	chat.MessageCount += len(messages)
+	return nil
+}
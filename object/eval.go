@@ -0,0 +1,363 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hanzoai/dbx"
+	"github.com/robfig/cron/v3"
+)
+
+// Eval scorers. Exact cases pass when the answer, trimmed, is the expected
+// text; regex cases when the expected pattern matches the answer; judge
+// cases when the judge model finds that the answer meets the expected
+// criteria.
+const (
+	EvalScorerExact = "exact"
+	EvalScorerRegex = "regex"
+	EvalScorerJudge = "judge"
+)
+
+// Eval run states. A run fails when it is canceled, times out or is left
+// running by an instance that stopped.
+const (
+	EvalRunRunning  = "running"
+	EvalRunFinished = "finished"
+	EvalRunFailed   = "failed"
+)
+
+// Eval run triggers.
+const (
+	EvalTriggerManual   = "manual"
+	EvalTriggerSchedule = "schedule"
+)
+
+// MaxEvalCases bounds the cases of a suite, each of which is sent to every
+// model of a run.
+const MaxEvalCases = 200
+
+// EvalCase is a golden prompt of an eval suite and how its answer is scored.
+type EvalCase struct {
+	Name     string `json:"name"`
+	System   string `json:"system"`
+	Prompt   string `json:"prompt"`
+	Scorer   string `json:"scorer"`   // "exact", "regex" or "judge"
+	Expected string `json:"expected"` // the answer, the pattern or the judge's criteria
+}
+
+// EvalSuite is an organization's suite of golden prompts, run against its
+// models on demand or on a schedule.
+type EvalSuite struct {
+	Owner         string      `db:"pk" json:"owner"` // org ID, whose routing the runs use
+	Name          string      `db:"pk" json:"name"`
+	CreatedTime   string      `json:"createdTime"`
+	UpdatedTime   string      `json:"updatedTime"`
+	Description   string      `json:"description"`
+	Models        StringSlice `json:"models"`
+	Cases         []EvalCase  `db:"json" json:"cases"`
+	JudgeModel    string      `json:"judgeModel"`    // scores judge cases; evalJudgeModel (app.conf) when empty
+	Schedule      string      `json:"schedule"`      // cron spec such as "@daily"; empty to run on demand only
+	ScheduledTime string      `json:"scheduledTime"` // when the schedule last started a run
+	User          string      `json:"user"`          // "owner/name" billed for scheduled runs: the last to save the suite
+}
+
+func (s *EvalSuite) GetId() string {
+	return fmt.Sprintf("%s/%s", s.Owner, s.Name)
+}
+
+// EvalResult is the outcome of one case of a run against one model.
+type EvalResult struct {
+	Model     string `json:"model"`
+	Case      string `json:"case"`
+	Answer    string `json:"answer"`
+	Passed    bool   `json:"passed"`
+	Reason    string `json:"reason,omitempty"` // why the case failed
+	Error     string `json:"error,omitempty"`  // the model or the judge could not answer
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// EvalModelSummary counts the results of a run for one model.
+type EvalModelSummary struct {
+	Model  string `json:"model"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+	Errors int    `json:"errors"`
+}
+
+// EvalRun is a run of an eval suite against its models.
+type EvalRun struct {
+	Owner        string             `db:"pk" json:"owner"`
+	Name         string             `db:"pk" json:"name"`
+	Suite        string             `json:"suite"`
+	CreatedTime  string             `json:"createdTime"`
+	FinishedTime string             `json:"finishedTime"`
+	Trigger      string             `json:"trigger"` // "manual" or "schedule"
+	State        string             `json:"state"`   // "running", "finished" or "failed"
+	Error        string             `json:"error,omitempty"`
+	User         string             `json:"user"` // "owner/name" billed for the run's calls
+	Models       StringSlice        `json:"models"`
+	Summary      []EvalModelSummary `db:"json" json:"summary"`
+	Results      []EvalResult       `db:"json" json:"results"`
+}
+
+func (r *EvalRun) GetId() string {
+	return fmt.Sprintf("%s/%s", r.Owner, r.Name)
+}
+
+// Summarize counts the run's results per model, in the run's model order.
+func (r *EvalRun) Summarize() {
+	summaries := map[string]*EvalModelSummary{}
+	for _, result := range r.Results {
+		summary, ok := summaries[result.Model]
+		if !ok {
+			summary = &EvalModelSummary{Model: result.Model}
+			summaries[result.Model] = summary
+		}
+		switch {
+		case result.Error != "":
+			summary.Errors++
+		case result.Passed:
+			summary.Passed++
+		default:
+			summary.Failed++
+		}
+	}
+	r.Summary = []EvalModelSummary{}
+	for _, model := range r.Models {
+		if summary, ok := summaries[model]; ok {
+			r.Summary = append(r.Summary, *summary)
+		}
+	}
+}
+
+// ParseEvalSchedule parses the cron spec of a suite's schedule.
+func ParseEvalSchedule(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+func validateEvalSuite(s *EvalSuite) error {
+	if s.Owner == "" || s.Name == "" {
+		return fmt.Errorf("owner and name are required")
+	}
+	if len(s.Models) == 0 {
+		return fmt.Errorf("a suite needs at least one model")
+	}
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("a suite needs at least one case")
+	}
+	if len(s.Cases) > MaxEvalCases {
+		return fmt.Errorf("a suite has at most %d cases", MaxEvalCases)
+	}
+	if s.Schedule != "" {
+		if _, err := ParseEvalSchedule(s.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule %q: %v", s.Schedule, err)
+		}
+	}
+
+	names := map[string]bool{}
+	for i, c := range s.Cases {
+		if c.Name == "" || c.Prompt == "" {
+			return fmt.Errorf("cases[%d]: name and prompt are required", i)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("cases[%d]: case %s is defined twice", i, c.Name)
+		}
+		names[c.Name] = true
+		switch c.Scorer {
+		case EvalScorerExact, EvalScorerJudge:
+		case EvalScorerRegex:
+			if _, err := regexp.Compile(c.Expected); err != nil {
+				return fmt.Errorf("cases[%d]: invalid pattern %q: %v", i, c.Expected, err)
+			}
+		default:
+			return fmt.Errorf("cases[%d]: scorer must be %q, %q or %q", i, EvalScorerExact, EvalScorerRegex, EvalScorerJudge)
+		}
+		if c.Scorer == EvalScorerJudge && c.Expected == "" {
+			return fmt.Errorf("cases[%d]: judge cases need the criteria in expected", i)
+		}
+	}
+	return nil
+}
+
+func GetEvalSuites(owner string) ([]*EvalSuite, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	suites := []*EvalSuite{}
+	err := findAll(adapter.db, "eval_suite", &suites, dbx.HashExp{"owner": owner}, "name")
+	if err != nil {
+		return suites, err
+	}
+	return suites, nil
+}
+
+// GetScheduledEvalSuites returns the suites of every organization that
+// have a schedule.
+func GetScheduledEvalSuites() ([]*EvalSuite, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	suites := []*EvalSuite{}
+	err := findAll(adapter.db, "eval_suite", &suites, dbx.Not(dbx.HashExp{"schedule": ""}), "owner", "name")
+	if err != nil {
+		return suites, err
+	}
+	return suites, nil
+}
+
+func GetEvalSuite(owner string, name string) (*EvalSuite, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	suite := EvalSuite{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "eval_suite", &suite, dbx.HashExp{"owner": owner, "name": name})
+	if err != nil {
+		return &suite, err
+	}
+	if existed {
+		return &suite, nil
+	}
+	return nil, nil
+}
+
+func AddEvalSuite(suite *EvalSuite) (bool, error) {
+	if err := validateEvalSuite(suite); err != nil {
+		return false, err
+	}
+	suite.CreatedTime = time.Now().Format(time.RFC3339)
+	suite.UpdatedTime = suite.CreatedTime
+	suite.ScheduledTime = suite.CreatedTime
+	err := insertRow(adapter.db, suite)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func UpdateEvalSuite(owner string, name string, suite *EvalSuite) (bool, error) {
+	existing, err := GetEvalSuite(owner, name)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, nil
+	}
+	suite.Owner = owner
+	suite.Name = name
+	if err := validateEvalSuite(suite); err != nil {
+		return false, err
+	}
+	suite.CreatedTime = existing.CreatedTime
+	suite.UpdatedTime = time.Now().Format(time.RFC3339)
+	suite.ScheduledTime = existing.ScheduledTime
+	err = adapter.db.Model(suite).Update()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteEvalSuite deletes a suite with its runs.
+func DeleteEvalSuite(suite *EvalSuite) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "eval_suite", dbx.HashExp{"owner": suite.Owner, "name": suite.Name})
+	if err != nil {
+		return false, err
+	}
+	_, err = deleteWhere(adapter.db, "eval_run", dbx.HashExp{"owner": suite.Owner, "suite": suite.Name})
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+// ClaimEvalSchedule moves the suite's scheduled time to scheduledTime,
+// unless another instance has moved it first. Only the instance that
+// claims a scheduled run starts it.
+func ClaimEvalSchedule(suite *EvalSuite, scheduledTime string) (bool, error) {
+	affected, err := updateCols(adapter.db, "eval_suite",
+		dbx.HashExp{"owner": suite.Owner, "name": suite.Name, "scheduled_time": suite.ScheduledTime},
+		dbx.Params{"scheduled_time": scheduledTime},
+	)
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// GetEvalRuns returns the runs of a suite, newest first.
+func GetEvalRuns(owner string, suite string) ([]*EvalRun, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	runs := []*EvalRun{}
+	err := findAll(adapter.db, "eval_run", &runs, dbx.HashExp{"owner": owner, "suite": suite}, "created_time DESC")
+	if err != nil {
+		return runs, err
+	}
+	return runs, nil
+}
+
+func GetEvalRun(owner string, name string) (*EvalRun, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	run := EvalRun{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "eval_run", &run, dbx.HashExp{"owner": owner, "name": name})
+	if err != nil {
+		return &run, err
+	}
+	if existed {
+		return &run, nil
+	}
+	return nil, nil
+}
+
+func AddEvalRun(run *EvalRun) error {
+	return insertRow(adapter.db, run)
+}
+
+// FinishEvalRun stores the results of a run and marks it finished, or
+// failed when run.Error is set.
+func FinishEvalRun(run *EvalRun) error {
+	run.Summarize()
+	run.State = EvalRunFinished
+	if run.Error != "" {
+		run.State = EvalRunFailed
+	}
+	run.FinishedTime = time.Now().Format(time.RFC3339)
+	return adapter.db.Model(run).Update()
+}
+
+// FailStaleEvalRuns marks failed the runs still running that were created
+// before createdBefore: no instance is running them any more.
+func FailStaleEvalRuns(createdBefore time.Time) (int64, error) {
+	if adapter == nil || adapter.db == nil {
+		return 0, nil
+	}
+	return updateCols(adapter.db, "eval_run",
+		dbx.And(
+			dbx.HashExp{"state": EvalRunRunning},
+			dbx.NewExp("created_time < {:before}", dbx.Params{"before": createdBefore.Format(time.RFC3339)}),
+		),
+		dbx.Params{
+			"state":         EvalRunFailed,
+			"error":         "the run was interrupted",
+			"finished_time": time.Now().Format(time.RFC3339),
+		},
+	)
+}
@@ -0,0 +1,82 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testEvalSuite() EvalSuite {
+	return EvalSuite{
+		Owner:  "acme",
+		Name:   "identity",
+		Models: StringSlice{"zen4"},
+		Cases: []EvalCase{
+			{Name: "name", Prompt: "Who are you?", Scorer: EvalScorerRegex, Expected: `(?i)\bzen4\b`},
+			{Name: "sum", Prompt: "2+2? Reply with the number only.", Scorer: EvalScorerExact, Expected: "4"},
+			{Name: "tone", Prompt: "Say hello.", Scorer: EvalScorerJudge, Expected: "The answer is a friendly greeting."},
+		},
+		Schedule: "@daily",
+	}
+}
+
+func TestValidateEvalSuite(t *testing.T) {
+	valid := testEvalSuite()
+	if err := validateEvalSuite(&valid); err != nil {
+		t.Fatalf("valid suite rejected: %v", err)
+	}
+
+	tests := map[string]func(s *EvalSuite){
+		"no name":         func(s *EvalSuite) { s.Name = "" },
+		"no models":       func(s *EvalSuite) { s.Models = nil },
+		"no cases":        func(s *EvalSuite) { s.Cases = nil },
+		"bad schedule":    func(s *EvalSuite) { s.Schedule = "every day" },
+		"no prompt":       func(s *EvalSuite) { s.Cases[0].Prompt = "" },
+		"duplicate case":  func(s *EvalSuite) { s.Cases[1].Name = "name" },
+		"bad scorer":      func(s *EvalSuite) { s.Cases[0].Scorer = "fuzzy" },
+		"bad pattern":     func(s *EvalSuite) { s.Cases[0].Expected = "(zen4" },
+		"judge, no rules": func(s *EvalSuite) { s.Cases[2].Expected = "" },
+		"too many cases":  func(s *EvalSuite) { s.Cases = make([]EvalCase, MaxEvalCases+1) },
+	}
+	for name, mutate := range tests {
+		suite := testEvalSuite()
+		mutate(&suite)
+		if err := validateEvalSuite(&suite); err == nil {
+			t.Errorf("%s: suite accepted", name)
+		}
+	}
+}
+
+func TestEvalRunSummarize(t *testing.T) {
+	run := &EvalRun{
+		Models: StringSlice{"zen4", "zen4-mini", "zen3"},
+		Results: []EvalResult{
+			{Model: "zen4-mini", Case: "name", Passed: true},
+			{Model: "zen4", Case: "name", Passed: true},
+			{Model: "zen4", Case: "sum", Passed: false},
+			{Model: "zen4-mini", Case: "sum", Error: "upstream timeout"},
+		},
+	}
+	run.Summarize()
+
+	want := []EvalModelSummary{
+		{Model: "zen4", Passed: 1, Failed: 1},
+		{Model: "zen4-mini", Passed: 1, Errors: 1},
+	}
+	if !reflect.DeepEqual(run.Summary, want) {
+		t.Errorf("Summary = %+v, want %+v", run.Summary, want)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/dbx"
+)
+
+// Experiment states. Only running experiments assign requests to arms, and
+// an experiment's arms can only be changed before it starts.
+const (
+	ExperimentDraft   = "draft"
+	ExperimentRunning = "running"
+	ExperimentStopped = "stopped"
+)
+
+// ExperimentArm is a variant of a model's parameters tried by an
+// experiment. Fields left empty keep the model's defaults.
+type ExperimentArm struct {
+	Name           string   `json:"name"`
+	Weight         int      `json:"weight"`         // share of users assigned to the arm
+	Temperature    *float32 `json:"temperature"`    // used when the request sets no temperature
+	IdentityPrompt string   `json:"identityPrompt"` // replaces the zen identity prompt
+}
+
+// Experiment is an A/B test of a model's parameters. Each user is assigned
+// to one arm for the whole experiment. An experiment owned by "admin"
+// applies to every organization without a running experiment of its own
+// on the model.
+type Experiment struct {
+	Owner       string          `db:"pk" json:"owner"` // org ID, or "admin"
+	Name        string          `db:"pk" json:"name"`
+	CreatedTime string          `json:"createdTime"`
+	UpdatedTime string          `json:"updatedTime"`
+	Description string          `json:"description"`
+	Model       string          `json:"model"`
+	Arms        []ExperimentArm `db:"json" json:"arms"`
+	State       string          `json:"state"` // "draft", "running" or "stopped"
+	StartedTime string          `json:"startedTime"`
+	StoppedTime string          `json:"stoppedTime"`
+}
+
+func (e *Experiment) GetId() string {
+	return fmt.Sprintf("%s/%s", e.Owner, e.Name)
+}
+
+// Assign returns the arm of user. The same user always gets the same arm
+// of an experiment, and users are spread over the arms by weight.
+func (e *Experiment) Assign(user string) *ExperimentArm {
+	total := 0
+	for _, arm := range e.Arms {
+		total += arm.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.Name + "/" + user))
+	bucket := int(h.Sum32() % uint32(total))
+	for i := range e.Arms {
+		if bucket < e.Arms[i].Weight {
+			return &e.Arms[i]
+		}
+		bucket -= e.Arms[i].Weight
+	}
+	return nil
+}
+
+func validateExperiment(e *Experiment) error {
+	if e.Owner == "" || e.Name == "" {
+		return fmt.Errorf("owner and name are required")
+	}
+	if e.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if len(e.Arms) < 2 {
+		return fmt.Errorf("an experiment needs at least two arms")
+	}
+	names := map[string]bool{}
+	for i, arm := range e.Arms {
+		if arm.Name == "" {
+			return fmt.Errorf("arms[%d]: name is required", i)
+		}
+		if names[arm.Name] {
+			return fmt.Errorf("arms[%d]: arm %s is defined twice", i, arm.Name)
+		}
+		names[arm.Name] = true
+		if arm.Weight <= 0 {
+			return fmt.Errorf("arms[%d]: weight must be positive", i)
+		}
+		if arm.Temperature != nil && (*arm.Temperature < 0 || *arm.Temperature > 2) {
+			return fmt.Errorf("arms[%d]: temperature must be between 0 and 2", i)
+		}
+	}
+	return nil
+}
+
+func GetExperiments(owner string) ([]*Experiment, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	experiments := []*Experiment{}
+	err := findAll(adapter.db, "experiment", &experiments, dbx.HashExp{"owner": owner}, "name")
+	if err != nil {
+		return experiments, err
+	}
+	return experiments, nil
+}
+
+func GetExperiment(owner string, name string) (*Experiment, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	experiment := Experiment{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "experiment", &experiment, dbx.HashExp{"owner": owner, "name": name})
+	if err != nil {
+		return &experiment, err
+	}
+	if existed {
+		return &experiment, nil
+	}
+	return nil, nil
+}
+
+func AddExperiment(experiment *Experiment) (bool, error) {
+	if err := validateExperiment(experiment); err != nil {
+		return false, err
+	}
+	experiment.CreatedTime = time.Now().Format(time.RFC3339)
+	experiment.UpdatedTime = experiment.CreatedTime
+	experiment.State = ExperimentDraft
+	experiment.StartedTime = ""
+	experiment.StoppedTime = ""
+	err := insertRow(adapter.db, experiment)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateExperiment changes a draft experiment.
+func UpdateExperiment(owner string, name string, experiment *Experiment) (bool, error) {
+	existing, err := GetExperiment(owner, name)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, nil
+	}
+	if existing.State != ExperimentDraft {
+		return false, fmt.Errorf("experiment %s has started and can no longer be changed", existing.GetId())
+	}
+	experiment.Owner = owner
+	experiment.Name = name
+	if err := validateExperiment(experiment); err != nil {
+		return false, err
+	}
+	experiment.CreatedTime = existing.CreatedTime
+	experiment.UpdatedTime = time.Now().Format(time.RFC3339)
+	experiment.State = ExperimentDraft
+	err = adapter.db.Model(experiment).Update()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func DeleteExperiment(experiment *Experiment) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "experiment", dbx.HashExp{"owner": experiment.Owner, "name": experiment.Name})
+	if err != nil {
+		return false, err
+	}
+	invalidateExperimentCache()
+	return affected != 0, nil
+}
+
+// StartExperiment starts a draft experiment. An owner runs at most one
+// experiment on a model at a time.
+func StartExperiment(owner string, name string) (bool, error) {
+	experiment, err := GetExperiment(owner, name)
+	if err != nil {
+		return false, err
+	}
+	if experiment == nil {
+		return false, nil
+	}
+	if experiment.State != ExperimentDraft {
+		return false, fmt.Errorf("experiment %s is %s, only draft experiments can start", experiment.GetId(), experiment.State)
+	}
+	running, err := countWhere(adapter.db, "experiment", dbx.HashExp{"owner": owner, "model": experiment.Model, "state": ExperimentRunning})
+	if err != nil {
+		return false, err
+	}
+	if running > 0 {
+		return false, fmt.Errorf("%s already runs an experiment on %s", owner, experiment.Model)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	affected, err := updateByPK(adapter.db, "experiment", dbx.HashExp{"owner": owner, "name": name},
+		dbx.Params{"state": ExperimentRunning, "started_time": now, "updated_time": now})
+	if err != nil {
+		return false, err
+	}
+	invalidateExperimentCache()
+	return affected != 0, nil
+}
+
+// StopExperiment stops a running experiment for good; its results are kept.
+func StopExperiment(owner string, name string) (bool, error) {
+	now := time.Now().Format(time.RFC3339)
+	affected, err := updateCols(adapter.db, "experiment",
+		dbx.HashExp{"owner": owner, "name": name, "state": ExperimentRunning},
+		dbx.Params{"state": ExperimentStopped, "stopped_time": now, "updated_time": now})
+	if err != nil {
+		return false, err
+	}
+	invalidateExperimentCache()
+	return affected != 0, nil
+}
+
+// ExperimentArmResult totals the requests an arm of an experiment served.
+// Ratings come from the feedback callers gave on the arm's answers.
+type ExperimentArmResult struct {
+	Arm              string  `json:"arm"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+	AvgTtftMs        float64 `json:"avgTtftMs"`
+	PositiveRatings  int64   `json:"positiveRatings"`
+	NegativeRatings  int64   `json:"negativeRatings"`
+}
+
+// GetExperimentResults returns the per-arm totals of an experiment from
+// the request log, in arm name order.
+func GetExperimentResults(experiment *Experiment) ([]*ExperimentArmResult, error) {
+	results := []*ExperimentArmResult{}
+	where := dbx.HashExp{"experiment": experiment.GetId()}
+	err := adapter.db.Select(
+		"experiment_arm AS arm",
+		"COUNT(*) AS requests",
+		"SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) AS errors",
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+		"COALESCE(AVG(latency_ms), 0) AS avg_latency_ms",
+		"COALESCE(AVG(ttft_ms), 0) AS avg_ttft_ms",
+		"SUM(CASE WHEN rating > 0 THEN 1 ELSE 0 END) AS positive_ratings",
+		"SUM(CASE WHEN rating < 0 THEN 1 ELSE 0 END) AS negative_ratings",
+	).From("request_log").Where(where).GroupBy("experiment_arm").OrderBy("experiment_arm").All(&results)
+	return results, err
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+
+// experimentCache caches the running experiments, all under one key.
+var experimentCache = cache.NewLoading[[]*Experiment]("experiment", cache.Options{
+	MaxEntries: 1,
+	TTL:        experimentCacheTTL,
+	Shared:     true,
+})
+
+const (
+	experimentCacheTTL = 60 * time.Second
+	experimentCacheKey = "running"
+)
+
+func invalidateExperimentCache() {
+	experimentCache.Invalidate(experimentCacheKey)
+}
+
+// GetRunningExperiment returns the running experiment on model that
+// applies to org: its own, else the admin one, or nil. Running experiments
+// are cached for 60s.
+func GetRunningExperiment(org string, model string) (*Experiment, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	experiments, err := experimentCache.Get(experimentCacheKey, func() ([]*Experiment, error) {
+		experiments := []*Experiment{}
+		err := findAll(adapter.db, "experiment", &experiments, dbx.HashExp{"state": ExperimentRunning})
+		return experiments, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var global *Experiment
+	for _, experiment := range experiments {
+		if !strings.EqualFold(experiment.Model, model) {
+			continue
+		}
+		if experiment.Owner == org {
+			return experiment, nil
+		}
+		if experiment.Owner == "admin" {
+			global = experiment
+		}
+	}
+	return global, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testExperiment() Experiment {
+	temperature := float32(0.3)
+	return Experiment{
+		Owner: "admin",
+		Name:  "zen4-temperature",
+		Model: "zen4",
+		Arms: []ExperimentArm{
+			{Name: "control", Weight: 3},
+			{Name: "cool", Weight: 1, Temperature: &temperature},
+		},
+	}
+}
+
+func TestValidateExperiment(t *testing.T) {
+	valid := testExperiment()
+	if err := validateExperiment(&valid); err != nil {
+		t.Fatalf("valid experiment rejected: %v", err)
+	}
+
+	hot := float32(2.5)
+	tests := map[string]func(e *Experiment){
+		"no name":         func(e *Experiment) { e.Name = "" },
+		"no model":        func(e *Experiment) { e.Model = "" },
+		"one arm":         func(e *Experiment) { e.Arms = e.Arms[:1] },
+		"unnamed arm":     func(e *Experiment) { e.Arms[1].Name = "" },
+		"duplicate arm":   func(e *Experiment) { e.Arms[1].Name = "control" },
+		"zero weight":     func(e *Experiment) { e.Arms[0].Weight = 0 },
+		"bad temperature": func(e *Experiment) { e.Arms[1].Temperature = &hot },
+	}
+	for name, mutate := range tests {
+		experiment := testExperiment()
+		mutate(&experiment)
+		if err := validateExperiment(&experiment); err == nil {
+			t.Errorf("%s: experiment accepted", name)
+		}
+	}
+}
+
+func TestExperimentAssign(t *testing.T) {
+	experiment := testExperiment()
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		user := fmt.Sprintf("acme/user-%d", i)
+		arm := experiment.Assign(user)
+		if arm == nil {
+			t.Fatalf("%s got no arm", user)
+		}
+		if again := experiment.Assign(user); again.Name != arm.Name {
+			t.Fatalf("%s moved from %s to %s", user, arm.Name, again.Name)
+		}
+		counts[arm.Name]++
+	}
+	// Arms are weighted 3:1, so control should get about 3000 users.
+	if counts["control"] < 2800 || counts["control"] > 3200 {
+		t.Errorf("arm counts = %v, want about 3000 control and 1000 cool", counts)
+	}
+
+	experiment.Arms = nil
+	if arm := experiment.Assign("acme/user-0"); arm != nil {
+		t.Errorf("experiment without arms assigned %s", arm.Name)
+	}
+}
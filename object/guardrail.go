@@ -0,0 +1,260 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// Guardrail stages: the prompt before generation, the answer after it, or
+// both.
+const (
+	GuardrailStageInput  = "input"
+	GuardrailStageOutput = "output"
+	GuardrailStageBoth   = "both"
+)
+
+// Guardrail policy types. Keyword policies match their patterns literally
+// and case-insensitively, regex policies as Go regular expressions, and
+// moderation policies ask the guard model to classify the text.
+const (
+	GuardrailTypeKeyword    = "keyword"
+	GuardrailTypeRegex      = "regex"
+	GuardrailTypeModeration = "moderation"
+)
+
+// Guardrail actions. Block rejects the request, flag only records the
+// match, and redact replaces the matched text.
+const (
+	GuardrailActionBlock  = "block"
+	GuardrailActionFlag   = "flag"
+	GuardrailActionRedact = "redact"
+)
+
+// GuardrailRedaction replaces the text a redact policy matched.
+const GuardrailRedaction = "[REDACTED]"
+
+// GuardrailPolicy is one policy of an organization's guardrail chain. The
+// enabled policies run in name order at their stage; the first block stops
+// the chain.
+type GuardrailPolicy struct {
+	Owner       string      `db:"pk" json:"owner"` // org ID
+	Name        string      `db:"pk" json:"name"`
+	CreatedTime string      `json:"createdTime"`
+	UpdatedTime string      `json:"updatedTime"`
+	Stage       string      `json:"stage"` // "input", "output" or "both"
+	Type        string      `json:"type"`  // "keyword", "regex" or "moderation"
+	Patterns    StringSlice `json:"patterns"`
+	Action      string      `json:"action"`  // "block", "flag" or "redact"
+	Message     string      `json:"message"` // returned to the caller on block
+	Enabled     bool        `json:"enabled"`
+
+	matcher *regexp.Regexp // compiled patterns, set by Compile
+}
+
+func (p *GuardrailPolicy) GetId() string {
+	return fmt.Sprintf("%s/%s", p.Owner, p.Name)
+}
+
+// AppliesTo reports whether the policy runs at stage.
+func (p *GuardrailPolicy) AppliesTo(stage string) bool {
+	return p.Stage == stage || p.Stage == GuardrailStageBoth
+}
+
+// Compile builds the single expression matching any of the policy's
+// patterns. Moderation policies have none.
+func (p *GuardrailPolicy) Compile() error {
+	if p.Type == GuardrailTypeModeration {
+		p.matcher = nil
+		return nil
+	}
+	alternatives := []string{}
+	for _, pattern := range p.Patterns {
+		if pattern == "" {
+			continue
+		}
+		if p.Type == GuardrailTypeKeyword {
+			pattern = "(?i)" + regexp.QuoteMeta(pattern)
+		} else if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	if len(alternatives) == 0 {
+		return fmt.Errorf("%s policies need at least one pattern", p.Type)
+	}
+	matcher, err := regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		return err
+	}
+	p.matcher = matcher
+	return nil
+}
+
+// Match returns the first text the policy's patterns match, or "".
+func (p *GuardrailPolicy) Match(text string) string {
+	if p.matcher == nil {
+		return ""
+	}
+	return p.matcher.FindString(text)
+}
+
+// Redact replaces everything the policy's patterns match.
+func (p *GuardrailPolicy) Redact(text string) string {
+	if p.matcher == nil {
+		return text
+	}
+	return p.matcher.ReplaceAllLiteralString(text, GuardrailRedaction)
+}
+
+func GetGuardrailPolicies(owner string) ([]*GuardrailPolicy, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	policies := []*GuardrailPolicy{}
+	err := findAll(adapter.db, "guardrail_policy", &policies, dbx.HashExp{"owner": owner}, "name")
+	if err != nil {
+		return policies, err
+	}
+	return policies, nil
+}
+
+func GetGuardrailPolicy(owner string, name string) (*GuardrailPolicy, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	policy := GuardrailPolicy{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "guardrail_policy", &policy, dbx.HashExp{"owner": owner, "name": name})
+	if err != nil {
+		return &policy, err
+	}
+	if existed {
+		return &policy, nil
+	}
+	return nil, nil
+}
+
+func validateGuardrailPolicy(policy *GuardrailPolicy) error {
+	if policy.Owner == "" || policy.Name == "" {
+		return fmt.Errorf("owner and name are required")
+	}
+	switch policy.Stage {
+	case GuardrailStageInput, GuardrailStageOutput, GuardrailStageBoth:
+	default:
+		return fmt.Errorf("stage must be %q, %q or %q", GuardrailStageInput, GuardrailStageOutput, GuardrailStageBoth)
+	}
+	switch policy.Type {
+	case GuardrailTypeKeyword, GuardrailTypeRegex, GuardrailTypeModeration:
+	default:
+		return fmt.Errorf("type must be %q, %q or %q", GuardrailTypeKeyword, GuardrailTypeRegex, GuardrailTypeModeration)
+	}
+	switch policy.Action {
+	case GuardrailActionBlock, GuardrailActionFlag, GuardrailActionRedact:
+	default:
+		return fmt.Errorf("action must be %q, %q or %q", GuardrailActionBlock, GuardrailActionFlag, GuardrailActionRedact)
+	}
+	if policy.Type == GuardrailTypeModeration && policy.Action == GuardrailActionRedact {
+		return fmt.Errorf("moderation policies can only block or flag")
+	}
+	return policy.Compile()
+}
+
+func AddGuardrailPolicy(policy *GuardrailPolicy) (bool, error) {
+	if err := validateGuardrailPolicy(policy); err != nil {
+		return false, err
+	}
+	policy.CreatedTime = time.Now().Format(time.RFC3339)
+	policy.UpdatedTime = policy.CreatedTime
+	err := insertRow(adapter.db, policy)
+	if err != nil {
+		return false, err
+	}
+	invalidateGuardrailPolicyCache()
+	return true, nil
+}
+
+func UpdateGuardrailPolicy(owner string, name string, policy *GuardrailPolicy) (bool, error) {
+	policy.Owner = owner
+	policy.Name = name
+	if err := validateGuardrailPolicy(policy); err != nil {
+		return false, err
+	}
+	policy.UpdatedTime = time.Now().Format(time.RFC3339)
+	err := adapter.db.Model(policy).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateGuardrailPolicyCache()
+	return true, nil
+}
+
+func DeleteGuardrailPolicy(policy *GuardrailPolicy) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "guardrail_policy", dbx.HashExp{"owner": policy.Owner, "name": policy.Name})
+	if err != nil {
+		return false, err
+	}
+	invalidateGuardrailPolicyCache()
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type guardrailPolicyCacheEntry struct {
+	policies  []*GuardrailPolicy
+	fetchedAt time.Time
+}
+
+var (
+	guardrailPolicyCache    = make(map[string]*guardrailPolicyCacheEntry)
+	guardrailPolicyCacheMu  sync.RWMutex
+	guardrailPolicyCacheTTL = 60 * time.Second
+)
+
+func invalidateGuardrailPolicyCache() {
+	guardrailPolicyCacheMu.Lock()
+	guardrailPolicyCache = make(map[string]*guardrailPolicyCacheEntry)
+	guardrailPolicyCacheMu.Unlock()
+}
+
+// GetActiveGuardrailPolicies returns an organization's enabled policies,
+// compiled and in name order, with 60s TTL caching. Policies whose patterns
+// no longer compile are skipped.
+func GetActiveGuardrailPolicies(owner string) ([]*GuardrailPolicy, error) {
+	guardrailPolicyCacheMu.RLock()
+	entry, ok := guardrailPolicyCache[owner]
+	guardrailPolicyCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < guardrailPolicyCacheTTL {
+		return entry.policies, nil
+	}
+	policies, err := GetGuardrailPolicies(owner)
+	if err != nil {
+		return nil, err
+	}
+	active := []*GuardrailPolicy{}
+	for _, policy := range policies {
+		if policy.Enabled && policy.Compile() == nil {
+			active = append(active, policy)
+		}
+	}
+	guardrailPolicyCacheMu.Lock()
+	guardrailPolicyCache[owner] = &guardrailPolicyCacheEntry{policies: active, fetchedAt: time.Now()}
+	guardrailPolicyCacheMu.Unlock()
+	return active, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skipCi
+
+package object
+
+import "testing"
+
+func TestGuardrailPolicyMatchAndRedact(t *testing.T) {
+	keyword := &GuardrailPolicy{Type: GuardrailTypeKeyword, Patterns: StringSlice{"Project X", "a.b"}}
+	if err := keyword.Compile(); err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	if got := keyword.Match("tell me about project x"); got != "project x" {
+		t.Errorf("keyword Match() = %q, want a case-insensitive match", got)
+	}
+	if got := keyword.Match("axb"); got != "" {
+		t.Errorf("keyword Match(axb) = %q, keywords must match literally", got)
+	}
+
+	regex := &GuardrailPolicy{Type: GuardrailTypeRegex, Patterns: StringSlice{`[\w.]+@[\w.]+`, `\d{3}-\d{2}-\d{4}`}}
+	if err := regex.Compile(); err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	got := regex.Redact("mail bob@example.com, ssn 123-45-6789")
+	if want := "mail [REDACTED], ssn [REDACTED]"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateGuardrailPolicy(t *testing.T) {
+	valid := GuardrailPolicy{Owner: "acme", Name: "pii", Stage: GuardrailStageBoth, Type: GuardrailTypeRegex, Patterns: StringSlice{`\d+`}, Action: GuardrailActionRedact}
+	if err := validateGuardrailPolicy(&valid); err != nil {
+		t.Fatalf("valid policy rejected: %v", err)
+	}
+
+	tests := map[string]func(p *GuardrailPolicy){
+		"no name":              func(p *GuardrailPolicy) { p.Name = "" },
+		"bad stage":            func(p *GuardrailPolicy) { p.Stage = "during" },
+		"bad action":           func(p *GuardrailPolicy) { p.Action = "warn" },
+		"bad regex":            func(p *GuardrailPolicy) { p.Patterns = StringSlice{"("} },
+		"no patterns":          func(p *GuardrailPolicy) { p.Patterns = nil },
+		"moderation redaction": func(p *GuardrailPolicy) { p.Type = GuardrailTypeModeration },
+	}
+	for name, mutate := range tests {
+		policy := valid
+		mutate(&policy)
+		if err := validateGuardrailPolicy(&policy); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/conf"
+)
+
+// DependencyHealth reports whether a dependency can currently serve requests.
+type DependencyHealth struct {
+	Status    string `json:"status"` // "ok", "error" or "disabled"
+	Endpoint  string `json:"endpoint,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	CheckedAt string `json:"checkedAt"`
+}
+
+const (
+	// dependencyHealthTTL bounds how often readiness probes reach a dependency.
+	dependencyHealthTTL = 10 * time.Second
+
+	// dependencyHealthTimeout bounds a single dependency probe.
+	dependencyHealthTimeout = 3 * time.Second
+)
+
+type dependencyHealthEntry struct {
+	health    *DependencyHealth
+	checkedAt time.Time
+}
+
+var (
+	dependencyHealthMu    sync.Mutex
+	dependencyHealthCache = map[string]*dependencyHealthEntry{}
+	dependencyHealthHTTP  = &http.Client{Timeout: dependencyHealthTimeout}
+)
+
+// CachedDependencyHealth runs check at most once per dependencyHealthTTL for
+// the named dependency, so readiness probes never hit it more often.
+func CachedDependencyHealth(name string, check func() *DependencyHealth) *DependencyHealth {
+	dependencyHealthMu.Lock()
+	entry, ok := dependencyHealthCache[name]
+	dependencyHealthMu.Unlock()
+	if ok && time.Since(entry.checkedAt) < dependencyHealthTTL {
+		return entry.health
+	}
+
+	start := time.Now()
+	health := check()
+	health.CheckedAt = start.UTC().Format(time.RFC3339)
+	if health.Status != "disabled" {
+		health.LatencyMs = time.Since(start).Milliseconds()
+	}
+
+	dependencyHealthMu.Lock()
+	dependencyHealthCache[name] = &dependencyHealthEntry{health: health, checkedAt: start}
+	dependencyHealthMu.Unlock()
+	return health
+}
+
+// CheckDatabaseHealth pings the database.
+func CheckDatabaseHealth() *DependencyHealth {
+	return CachedDependencyHealth("database", func() *DependencyHealth {
+		if adapter == nil || adapter.db == nil {
+			return &DependencyHealth{Status: "error", Error: "database is not initialized"}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dependencyHealthTimeout)
+		defer cancel()
+		if err := adapter.RawDB().PingContext(ctx); err != nil {
+			return &DependencyHealth{Status: "error", Error: err.Error()}
+		}
+		return &DependencyHealth{Status: "ok"}
+	})
+}
+
+// CheckCommerceHealth probes the Commerce billing service configured by
+// commerceEndpoint.
+func CheckCommerceHealth() *DependencyHealth {
+	return CachedDependencyHealth("commerce", func() *DependencyHealth {
+		return checkEndpointHealth(conf.GetConfigString("commerceEndpoint"))
+	})
+}
+
+// CheckIAMHealth probes the IAM service configured by iamEndpoint.
+func CheckIAMHealth() *DependencyHealth {
+	return CachedDependencyHealth("iam", func() *DependencyHealth {
+		return checkEndpointHealth(conf.GetConfigString("iamEndpoint"))
+	})
+}
+
+// checkEndpointHealth reports whether endpoint answers HTTP. Any response
+// below 500 counts as reachable: the probe is unauthenticated, so a 401 or
+// 404 still shows the service is up. An empty endpoint is "disabled".
+func checkEndpointHealth(endpoint string) *DependencyHealth {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if endpoint == "" {
+		return &DependencyHealth{Status: "disabled"}
+	}
+
+	health := &DependencyHealth{Status: "ok", Endpoint: endpoint}
+	resp, err := dependencyHealthHTTP.Get(endpoint)
+	if err != nil {
+		health.Status = "error"
+		health.Error = fmt.Sprintf("unreachable: %v", err)
+		return health
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		health.Status = "error"
+		health.Error = fmt.Sprintf("returned status %d", resp.StatusCode)
+	}
+	return health
+}
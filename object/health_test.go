@@ -0,0 +1,34 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestCachedDependencyHealth(t *testing.T) {
+	calls := 0
+	check := func() *DependencyHealth {
+		calls++
+		return &DependencyHealth{Status: "ok"}
+	}
+
+	first := CachedDependencyHealth("test-cached", check)
+	second := CachedDependencyHealth("test-cached", check)
+	if calls != 1 {
+		t.Errorf("check ran %d times within the TTL, want 1", calls)
+	}
+	if first != second || first.CheckedAt == "" {
+		t.Errorf("second probe = %+v, want the cached %+v", second, first)
+	}
+}
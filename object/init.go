@@ -193,7 +193,7 @@ func initBuiltInProviders() (string, string, string, string) {
 // maps to an upstream service with its own API key and base URL.
 //
 // Provider secrets can use KMS references ("kms://SECRET_NAME") which
-// are resolved at runtime via ResolveProviderSecret().
+// are resolved at runtime via ResolveProviderSecretAs().
 func initLLMProviders() {
 	providers := []Provider{
 		{
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
 )
 
 // kmsClient fetches secrets from Hanzo KMS.
@@ -47,8 +48,10 @@ import (
 //
 // Multi-tenant model:
 //   - Admin-owned providers use KMS_PROJECT_ID (system secrets)
-//   - Org-owned providers store "kms-project:{projectId}" in ConfigText
-//   - Convention: store "kms://SECRET_NAME" in provider.ClientSecret
+//   - Org-owned providers use the org's project from the kms_project table
+//   - A single field can name its own project: "kms://{projectId}/SECRET_NAME"
+//   - Convention: store "kms://SECRET_NAME" in provider.ClientSecret, or
+//     "kms://SECRET_NAME@v3" to pin a specific version for rollback safety
 type kmsClient struct {
 	endpoint    string
 	environment string
@@ -142,35 +145,46 @@ func (c *kmsClient) getAuthToken() (string, error) {
 	if c.accessToken != "" && time.Now().Add(30*time.Second).Before(c.tokenExpiresAt) {
 		return c.accessToken, nil
 	}
-	// Login via Universal Auth
+	authResp, err := c.login()
+	if err != nil {
+		KmsTokenRefreshes.WithLabelValues("error").Inc()
+		KmsFailures.WithLabelValues("auth").Inc()
+		return "", err
+	}
+	KmsTokenRefreshes.WithLabelValues("ok").Inc()
+	c.accessToken = authResp.AccessToken
+	c.tokenExpiresAt = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
+	logs.Info("KMS: universal auth token acquired, expires in %ds", authResp.ExpiresIn)
+	return c.accessToken, nil
+}
+
+// login exchanges the Universal Auth client credentials for an access token.
+func (c *kmsClient) login() (*universalAuthResponse, error) {
 	body, err := json.Marshal(map[string]string{
 		"clientId":     c.clientID,
 		"clientSecret": c.clientSecret,
 	})
 	if err != nil {
-		return "", fmt.Errorf("kms: failed to marshal login request: %w", err)
+		return nil, fmt.Errorf("kms: failed to marshal login request: %w", err)
 	}
 	url := c.endpoint + "/api/v1/auth/universal-auth/login"
 	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("kms: universal auth login failed: %w", err)
+		return nil, fmt.Errorf("kms: universal auth login failed: %w", err)
 	}
 	defer resp.Body.Close()
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("kms: failed to read login response: %w", err)
+		return nil, fmt.Errorf("kms: failed to read login response: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("kms: universal auth login returned %d: %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("kms: universal auth login returned %d: %s", resp.StatusCode, string(respBody))
 	}
 	var authResp universalAuthResponse
 	if err := json.Unmarshal(respBody, &authResp); err != nil {
-		return "", fmt.Errorf("kms: failed to parse login response: %w", err)
+		return nil, fmt.Errorf("kms: failed to parse login response: %w", err)
 	}
-	c.accessToken = authResp.AccessToken
-	c.tokenExpiresAt = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
-	logs.Info("KMS: universal auth token acquired, expires in %ds", authResp.ExpiresIn)
-	return c.accessToken, nil
+	return &authResp, nil
 }
 
 // ── Secret fetching ─────────────────────────────────────────────────────────
@@ -179,133 +193,484 @@ type kmsSecretResponse struct {
 	Secret struct {
 		SecretKey   string `json:"secretKey"`
 		SecretValue string `json:"secretValue"`
+		Version     int    `json:"version"`
 	} `json:"secret"`
 }
 
-// getSecret fetches a secret value by name from KMS, scoped to a project.
-// Cache hierarchy: ZAP→KV (distributed, survives restarts) → in-memory (5 min TTL).
+// getSecret fetches a secret value by reference ("NAME" or "NAME@v3") from
+// KMS, scoped to a project.
+// Cache hierarchy: in-memory (5 min TTL) → shared (distributed, survives
+// restarts; see getSharedSecret).
 // On cache miss, fetches from KMS API and populates both caches.
-func (c *kmsClient) getSecret(name string, projectID string) (string, error) {
-	cacheKey := projectID + "/" + name
+func (c *kmsClient) getSecret(ref string, projectID string) (string, error) {
+	name, version, err := parseVersionedSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+	cacheKey := projectID + "/" + ref
 	// L1: in-memory cache
 	kmsSecMu.RLock()
 	entry, ok := kmsSecrets[cacheKey]
 	kmsSecMu.RUnlock()
 	if ok && time.Since(entry.fetchedAt) < kmsSecTTL {
+		KmsCacheLookups.WithLabelValues("memory_hit").Inc()
 		return entry.value, nil
 	}
-	// L2: distributed KV cache via ZAP (survives pod restarts)
+	// L2: distributed cache (survives pod restarts)
+	if val, ok := getSharedSecret(cacheKey); ok {
+		// Populate L1 from L2 hit
+		kmsSecMu.Lock()
+		kmsSecrets[cacheKey] = &kmsSecretEntry{value: val, fetchedAt: time.Now()}
+		kmsSecMu.Unlock()
+		KmsCacheLookups.WithLabelValues("kv_hit").Inc()
+		return val, nil
+	}
+	KmsCacheLookups.WithLabelValues("miss").Inc()
+	start := time.Now()
+	kmsResp, err := c.fetchSecret(name, version, projectID)
+	if err != nil {
+		KmsFetchLatency.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		KmsFailures.WithLabelValues("fetch").Inc()
+		return "", err
+	}
+	KmsFetchLatency.WithLabelValues("ok").Observe(time.Since(start).Seconds())
+	value := kmsResp.Secret.SecretValue
+	// Audit trail: record exactly which version is now in use.
+	logs.Info("kms audit: resolved secret=%s requested_version=%d resolved_version=%d project=%s",
+		name, version, kmsResp.Secret.Version, projectID)
+	// Populate L1 in-memory cache.
+	kmsSecMu.Lock()
+	kmsSecrets[cacheKey] = &kmsSecretEntry{value: value, fetchedAt: time.Now()}
+	kmsSecMu.Unlock()
+	// Populate L2 distributed cache (5 min TTL).
+	setSharedSecret(cacheKey, value)
+	return value, nil
+}
+
+// getSharedSecret reads the distributed tier of the secret cache: the
+// gateway cache backend when one is configured, ZAP KV otherwise.
+func getSharedSecret(cacheKey string) (string, bool) {
+	if cache.Distributed() {
+		value, ok := cache.GetShared(kmsCacheName, cacheKey)
+		return string(value), ok && len(value) > 0
+	}
 	if ZapEnabled() {
-		kvKey := "kms:" + cacheKey
-		val, err := ZapKVGet(context.Background(), kvKey)
-		if err == nil && val != "" {
-			// Populate L1 from L2 hit
-			kmsSecMu.Lock()
-			kmsSecrets[cacheKey] = &kmsSecretEntry{value: val, fetchedAt: time.Now()}
-			kmsSecMu.Unlock()
-			return val, nil
-		}
+		val, err := ZapKVGet(context.Background(), "kms:"+cacheKey)
+		return val, err == nil && val != ""
 	}
+	return "", false
+}
+
+func setSharedSecret(cacheKey string, value string) {
+	if cache.Distributed() {
+		cache.SetShared(kmsCacheName, cacheKey, []byte(value), kmsSecTTL)
+	} else if ZapEnabled() {
+		_ = ZapKVSetEx(context.Background(), "kms:"+cacheKey, value, int(kmsSecTTL.Seconds()))
+	}
+}
+
+func deleteSharedSecret(cacheKey string) {
+	if cache.Distributed() {
+		cache.DeleteShared(kmsCacheName, cacheKey, false)
+	} else if ZapEnabled() {
+		_ = ZapKVDel(context.Background(), "kms:"+cacheKey)
+	}
+}
+
+// fetchSecret reads a secret from the KMS API, bypassing the caches.
+func (c *kmsClient) fetchSecret(name string, version int, projectID string) (*kmsSecretResponse, error) {
 	token, err := c.getAuthToken()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	url := fmt.Sprintf("%s/api/v4/secrets/%s?projectId=%s&environment=%s",
 		c.endpoint, name, projectID, c.environment)
+	if version > 0 {
+		url += fmt.Sprintf("&version=%d", version)
+	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("kms: failed to create request: %w", err)
+		return nil, fmt.Errorf("kms: failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("kms: request failed for secret %q: %w", name, err)
+		return nil, fmt.Errorf("kms: request failed for secret %q: %w", name, err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("kms: failed to read response for secret %q: %w", name, err)
+		return nil, fmt.Errorf("kms: failed to read response for secret %q: %w", name, err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("kms: secret %q (project=%s) returned status %d: %s",
+		return nil, fmt.Errorf("kms: secret %q (project=%s) returned status %d: %s",
 			name, projectID, resp.StatusCode, string(body))
 	}
 	var kmsResp kmsSecretResponse
 	if err := json.Unmarshal(body, &kmsResp); err != nil {
-		return "", fmt.Errorf("kms: failed to parse response for secret %q: %w", name, err)
+		return nil, fmt.Errorf("kms: failed to parse response for secret %q: %w", name, err)
 	}
-	value := kmsResp.Secret.SecretValue
-	// Populate L1 in-memory cache.
+	return &kmsResp, nil
+}
+
+// ── Secret writing ──────────────────────────────────────────────────────────
+// errKMSSecretNotFound is returned by updateSecret when the secret does not
+// exist yet, so setSecret can fall back to creating it.
+var errKMSSecretNotFound = fmt.Errorf("kms: secret not found")
+
+// writeSecret sends a create (POST) or update (PATCH) for a secret via the
+// KMS V4 API.
+func (c *kmsClient) writeSecret(method string, name string, projectID string, value string) error {
+	token, err := c.getAuthToken()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{
+		"projectId":   projectID,
+		"environment": c.environment,
+		"secretPath":  "/",
+		"secretValue": value,
+		"type":        "shared",
+	})
+	if err != nil {
+		return fmt.Errorf("kms: failed to marshal secret %q: %w", name, err)
+	}
+	url := fmt.Sprintf("%s/api/v4/secrets/%s", c.endpoint, name)
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("kms: failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms: write failed for secret %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodPatch {
+		return errKMSSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms: write of secret %q (project=%s) returned status %d: %s",
+			name, projectID, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// setSecret updates a secret, creating it when it does not exist yet, and
+// refreshes the caches so readers see the new value immediately.
+func (c *kmsClient) setSecret(name string, projectID string, value string) (created bool, err error) {
+	err = c.writeSecret(http.MethodPatch, name, projectID, value)
+	if err == errKMSSecretNotFound {
+		created = true
+		err = c.writeSecret(http.MethodPost, name, projectID, value)
+	}
+	if err != nil {
+		KmsFailures.WithLabelValues("write").Inc()
+		return false, err
+	}
+
+	cacheKey := projectID + "/" + name
 	kmsSecMu.Lock()
 	kmsSecrets[cacheKey] = &kmsSecretEntry{value: value, fetchedAt: time.Now()}
 	kmsSecMu.Unlock()
-	// Populate L2 distributed KV cache via ZAP (5 min TTL).
-	if ZapEnabled() {
-		kvKey := "kms:" + cacheKey
-		_ = ZapKVSetEx(context.Background(), kvKey, value, int(kmsSecTTL.Seconds()))
+	deleteSharedSecret(cacheKey)
+	cache.Invalidate(kmsCacheName, cacheKey)
+	return created, nil
+}
+
+// ── Cache invalidation ──────────────────────────────────────────────────────
+// kmsCacheName addresses the secret cache's shared values and invalidations;
+// invalidation keys are "projectID/name", either part empty for any.
+const kmsCacheName = "kms"
+
+func init() {
+	cache.OnInvalidate(kmsCacheName, func(key string, prefix bool) {
+		projectID, name, _ := strings.Cut(key, "/")
+		dropLocalSecrets(name, projectID)
+	})
+}
+
+// invalidateSecrets drops cached values (L1 and L2) for secrets matching
+// name and projectID on every replica; an empty value matches anything.
+// Returns the number of L1 entries removed here.
+func invalidateSecrets(name string, projectID string) int {
+	keys := dropLocalSecrets(name, projectID)
+	removed := len(keys)
+	if name != "" && projectID != "" && len(keys) == 0 {
+		keys = append(keys, projectID+"/"+name)
 	}
-	return value, nil
+	for _, cacheKey := range keys {
+		deleteSharedSecret(cacheKey)
+	}
+	cache.Invalidate(kmsCacheName, projectID+"/"+name)
+	return removed
+}
+
+// dropLocalSecrets drops the L1 entries of secrets matching name and
+// projectID and returns their cache keys.
+func dropLocalSecrets(name string, projectID string) []string {
+	var keys []string
+	kmsSecMu.Lock()
+	for cacheKey := range kmsSecrets {
+		keyProject, keyRef, _ := strings.Cut(cacheKey, "/")
+		keyName, _, _ := strings.Cut(keyRef, "@")
+		if (name == "" || keyName == name || keyRef == name) && (projectID == "" || keyProject == projectID) {
+			keys = append(keys, cacheKey)
+			delete(kmsSecrets, cacheKey)
+		}
+	}
+	kmsSecMu.Unlock()
+	return keys
+}
+
+// kmsProjectForProvider returns the KMS project of a provider's secrets:
+// org-owned providers resolve in the project registered for their org (see
+// KmsProject) and get an error when none is registered. Admin-owned
+// providers get "", which selects the system default (KMS_PROJECT_ID).
+func kmsProjectForProvider(provider *Provider) (string, error) {
+	if !isOrgProvider(provider) {
+		return "", nil
+	}
+	projectID, err := getOrgKmsProject(provider.Owner)
+	if err != nil {
+		return "", fmt.Errorf("kms: project lookup for org %s failed: %w", provider.Owner, err)
+	}
+	if projectID == "" {
+		return "", fmt.Errorf("kms: no KMS project registered for org %s", provider.Owner)
+	}
+	return projectID, nil
+}
+
+// invalidateProviderSecrets drops cached values for every secret reference
+// of an unresolved provider record.
+func invalidateProviderSecrets(provider *Provider) {
+	if provider == nil {
+		return
+	}
+	initKMS()
+	projectID, err := kmsProjectForProvider(provider)
+	if err != nil && isOrgProvider(provider) {
+		logs.Warn("%v", err)
+		return
+	}
+	if kms != nil && projectID == "" {
+		projectID = kms.projectID
+	}
+	for _, field := range []string{provider.ClientSecret, provider.UserKey, provider.SignKey} {
+		if ref := strings.TrimPrefix(field, "kms://"); ref != field {
+			fieldProjectID, name, err := splitKMSProjectRef(ref)
+			if kms == nil || err != nil || name == "" {
+				continue
+			}
+			if fieldProjectID == "" {
+				fieldProjectID = projectID
+			}
+			invalidateSecrets(name, fieldProjectID)
+			continue
+		}
+		invalidateSecretRef(field)
+	}
+}
+
+// ── SecretResolver ──────────────────────────────────────────────────────────
+// kmsSecretResolver resolves kms:// references within one KMS project; an
+// empty projectID selects the system default. A reference of the form
+// "{projectId}/NAME" overrides the project for that reference only.
+//
+// An org-scoped resolver serves an org-owned provider: it resolves in
+// projectID only, never in the system project or the environment, and
+// rejects references naming another project.
+type kmsSecretResolver struct {
+	projectID string
+	orgScoped bool
+}
+
+// splitKMSProjectRef splits a "{projectId}/NAME[@vN]" reference into its
+// project override and secret reference. projectID is empty when the
+// reference carries no override.
+func splitKMSProjectRef(ref string) (projectID string, secretRef string, err error) {
+	projectID, secretRef, ok := strings.Cut(ref, "/")
+	if !ok {
+		return "", ref, nil
+	}
+	if projectID == "" || secretRef == "" || strings.Contains(secretRef, "/") {
+		return "", "", fmt.Errorf("kms: invalid reference %q (expected kms://{projectId}/SECRET_NAME)", ref)
+	}
+	return projectID, secretRef, nil
+}
+
+// project returns the KMS project a reference resolves in, or "" when it
+// cannot be determined.
+func (r *kmsSecretResolver) project(ref string) string {
+	projectID, _, err := splitKMSProjectRef(ref)
+	if err != nil {
+		return ""
+	}
+	if r.orgScoped {
+		if projectID != "" && projectID != r.projectID {
+			return ""
+		}
+		return r.projectID
+	}
+	if projectID == "" {
+		projectID = r.projectID
+	}
+	if projectID == "" && kms != nil {
+		projectID = kms.projectID
+	}
+	return projectID
+}
+
+func (r *kmsSecretResolver) Resolve(ref string) (string, error) {
+	fieldProjectID, ref, err := splitKMSProjectRef(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.orgScoped {
+		if r.projectID == "" {
+			return "", fmt.Errorf("kms: no KMS project registered for the provider's org")
+		}
+		if fieldProjectID != "" && fieldProjectID != r.projectID {
+			return "", fmt.Errorf("kms: project %q is outside the provider's org project", fieldProjectID)
+		}
+		initKMS()
+		if kms == nil {
+			return "", fmt.Errorf("kms: not configured")
+		}
+		return kms.getSecret(ref, r.projectID)
+	}
+	// Try env var first (e.g. FIREWORKS_API_KEY from cloud-search-config K8s Secret).
+	// Pinned references ("NAME@v3") and references to an explicit project
+	// always go to KMS, since env vars are unversioned and unscoped.
+	if fieldProjectID == "" && !strings.Contains(ref, "@") {
+		if envValue := os.Getenv(ref); envValue != "" {
+			return envValue, nil
+		}
+	}
+	initKMS()
+	if kms == nil {
+		return "", fmt.Errorf("kms: not configured")
+	}
+	projectID := fieldProjectID
+	if projectID == "" {
+		projectID = r.projectID
+	}
+	if projectID == "" {
+		projectID = kms.projectID
+	}
+	if projectID == "" {
+		return "", fmt.Errorf("kms: no project ID (set KMS_PROJECT_ID, register the org's KMS project or use kms://{projectId}/NAME)")
+	}
+	return kms.getSecret(ref, projectID)
+}
+
+// isOrgProvider reports whether a provider belongs to a tenant org rather
+// than the platform.
+func isOrgProvider(provider *Provider) bool {
+	return provider.Owner != "" && provider.Owner != "admin"
+}
+
+// getProviderSecretResolver is getSecretResolver for a provider's secret
+// field. Org-owned providers may only reference kms:// secrets, resolved in
+// their org's own project; other schemes reach platform-wide backends and
+// are rejected.
+func getProviderSecretResolver(provider *Provider, value string, projectID string) (SecretResolver, string, error) {
+	resolver, ref := getSecretResolver(value, projectID)
+	if resolver == nil || !isOrgProvider(provider) {
+		return resolver, ref, nil
+	}
+	kmsResolver, ok := resolver.(*kmsSecretResolver)
+	if !ok {
+		scheme, _, _ := splitSecretURI(value)
+		return nil, "", fmt.Errorf("secret: provider %q of org %s may not reference %s:// secrets", provider.Name, provider.Owner, scheme)
+	}
+	kmsResolver.orgScoped = true
+	return kmsResolver, ref, nil
 }
 
 // ── Public API ──────────────────────────────────────────────────────────────
-// ResolveProviderSecret resolves KMS-backed secret fields for a provider.
-// If KMS is configured and provider fields start with "kms://", each secret
-// is fetched from KMS. Otherwise, DB values are used as-is.
+// ResolveProviderSecretAs resolves secret references in a provider's secret
+// fields. Each field holding a URI of a known scheme (see SecretResolver) is
+// replaced with the secret value; other values are used as-is.
 //
 // Supported provider fields:
 //   - ClientSecret
 //   - UserKey
 //   - SignKey
 //
-// Convention: store "kms://SECRET_NAME" in these fields in the database.
-// At runtime, they are resolved to actual secret values.
+// Convention: store "kms://SECRET_NAME" (latest) or "kms://SECRET_NAME@v3"
+// (pinned version) in these fields in the database, optionally prefixed with
+// a project ("kms://{projectId}/SECRET_NAME"), or "vault://...",
+// "awssm://..." or "env://..." for self-hosted deployments.
+// At runtime, they are resolved to actual secret values. kms:// references
+// are left untouched when KMS is not configured.
 //
 // Multi-tenant scoping:
 //   - Admin-owned providers use the default KMS_PROJECT_ID
-//   - Org-owned providers can set "kms-project:{projectId}" in ConfigText
-//     to scope secrets to the org's own KMS project
-func ResolveProviderSecret(provider *Provider) error {
-	initKMS()
-	if kms == nil || provider == nil {
-		return nil // KMS disabled, use DB value as-is
-	}
-	hasKmsRef := strings.HasPrefix(provider.ClientSecret, "kms://") ||
-		strings.HasPrefix(provider.UserKey, "kms://") ||
-		strings.HasPrefix(provider.SignKey, "kms://")
-	if !hasKmsRef {
-		return nil // Not a KMS reference
-	}
-	// Determine project ID: org-specific or system default.
-	// Org-owned providers can store "kms-project:{id}" in ConfigText
-	// to scope secrets to the org's KMS project.
-	projectID := kms.projectID
-	if provider.ConfigText != "" {
-		for _, line := range strings.Split(provider.ConfigText, "\n") {
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "kms-project:") {
-				projectID = strings.TrimPrefix(line, "kms-project:")
-				break
-			}
-		}
-	}
-	if projectID == "" {
-		return fmt.Errorf("kms: no project ID for provider %q (set KMS_PROJECT_ID or provider ConfigText 'kms-project:{id}')", provider.Name)
+//   - Org-owned providers resolve in the KMS project registered for their
+//     org (add-kms-project), scoping secrets to the org's own project
+//   - A field of the form "kms://{projectId}/SECRET_NAME" overrides the
+//     project of an admin-owned provider for that field only, so one provider
+//     can mix secrets from several projects
+//   - Org-owned providers may only reference kms:// secrets in their org's
+//     project; env://, vault://, awssm:// and other projects fail closed
+//
+// Every resolution is recorded in the secret audit log with caller: the
+// user ("owner/name") whose request needed the provider, or "job:<name>"
+// for a background job.
+func ResolveProviderSecretAs(provider *Provider, caller string) error {
+	if provider == nil {
+		return nil
 	}
+	initKMS()
+	projectID, projectErr := kmsProjectForProvider(provider)
 	resolveField := func(fieldName string, currentValue string) (string, error) {
-		if !strings.HasPrefix(currentValue, "kms://") {
-			return currentValue, nil
+		resolver, ref, err := getProviderSecretResolver(provider, currentValue, projectID)
+		if err == nil && projectErr != nil && resolver != nil && kms != nil {
+			err = projectErr
 		}
-		secretName := strings.TrimPrefix(currentValue, "kms://")
-		if secretName == "" {
-			return "", fmt.Errorf("kms: empty secret reference for provider %q field %s", provider.Name, fieldName)
+		if err != nil {
+			recordSecretAudit(&SecretAudit{
+				Owner:    provider.Owner,
+				Provider: provider.Name,
+				Field:    fieldName,
+				Secret:   currentValue,
+				Caller:   caller,
+				Result:   "failure",
+				Error:    err.Error(),
+			})
+			return "", err
 		}
-		// Try env var first (e.g. FIREWORKS_API_KEY from cloud-search-config K8s Secret).
-		if envValue := os.Getenv(secretName); envValue != "" {
-			return envValue, nil
+		if resolver == nil {
+			return currentValue, nil // Not a secret reference
+		}
+		if _, ok := resolver.(*kmsSecretResolver); ok && kms == nil {
+			return currentValue, nil // KMS disabled, use DB value as-is
+		}
+		if ref == "" {
+			return "", fmt.Errorf("secret: empty reference for provider %q field %s", provider.Name, fieldName)
+		}
+		audit := &SecretAudit{
+			Owner:    provider.Owner,
+			Provider: provider.Name,
+			Field:    fieldName,
+			Secret:   currentValue,
+			Caller:   caller,
+			Result:   "success",
+		}
+		if kmsResolver, ok := resolver.(*kmsSecretResolver); ok {
+			audit.Project = kmsResolver.project(ref)
+		}
+		value, err := resolver.Resolve(ref)
+		if err != nil {
+			audit.Result = "failure"
+			audit.Error = err.Error()
 		}
-		value, err := kms.getSecret(secretName, projectID)
+		recordSecretAudit(audit)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve KMS secret for provider %q field %s: %w", provider.Name, fieldName, err)
+			return "", fmt.Errorf("failed to resolve secret for provider %q field %s: %w", provider.Name, fieldName, err)
 		}
 		return value, nil
 	}
@@ -351,3 +716,88 @@ func GetOrgKMSSecret(name string, orgProjectID string) (string, error) {
 	}
 	return kms.getSecret(name, orgProjectID)
 }
+
+// SetKMSSecret creates or updates a secret in KMS. An empty projectID selects
+// the default system project (KMS_PROJECT_ID). Returns whether the secret was
+// newly created.
+func SetKMSSecret(name string, value string, projectID string) (bool, error) {
+	initKMS()
+	if kms == nil {
+		return false, fmt.Errorf("kms: not configured")
+	}
+	if projectID == "" {
+		projectID = kms.projectID
+	}
+	if projectID == "" {
+		return false, fmt.Errorf("kms: KMS_PROJECT_ID not set")
+	}
+	return kms.setSecret(name, projectID, value)
+}
+
+// InvalidateKMSSecret drops cached values for a secret so the next read goes
+// to KMS. An empty name invalidates every cached secret; an empty projectID
+// matches all projects. Returns the number of in-memory entries removed.
+func InvalidateKMSSecret(name string, projectID string) int {
+	return invalidateSecrets(name, projectID)
+}
+
+// ── Health ──────────────────────────────────────────────────────────────────
+// kmsHealthTTL bounds how often readiness probes reach KMS.
+const kmsHealthTTL = 10 * time.Second
+
+var (
+	kmsHealthMu   sync.Mutex
+	kmsHealthLast *DependencyHealth
+	kmsHealthAt   time.Time
+)
+
+// checkHealth verifies that KMS is reachable and that the configured
+// credentials are accepted.
+func (c *kmsClient) checkHealth() error {
+	if _, err := c.getAuthToken(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint+"/api/status", nil)
+	if err != nil {
+		return fmt.Errorf("kms: failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms: unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms: status endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckKMSHealth probes KMS reachability. Results are cached for
+// kmsHealthTTL so frequent readiness probes do not load KMS.
+func CheckKMSHealth() *DependencyHealth {
+	initKMS()
+	if kms == nil {
+		return &DependencyHealth{Status: "disabled", CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+	}
+
+	kmsHealthMu.Lock()
+	defer kmsHealthMu.Unlock()
+	if kmsHealthLast != nil && time.Since(kmsHealthAt) < kmsHealthTTL {
+		return kmsHealthLast
+	}
+
+	start := time.Now()
+	health := &DependencyHealth{Status: "ok", Endpoint: kms.endpoint}
+	if err := kms.checkHealth(); err != nil {
+		KmsFailures.WithLabelValues("health").Inc()
+		health.Status = "error"
+		health.Error = err.Error()
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+	health.CheckedAt = start.UTC().Format(time.RFC3339)
+	kmsHealthLast, kmsHealthAt = health, start
+	return health
+}
@@ -0,0 +1,153 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// KmsProject maps an organization to its KMS project. Secret references of
+// the organization's providers resolve in that project unless a reference
+// names its own ("kms://{projectId}/NAME").
+type KmsProject struct {
+	Owner       string `db:"pk" json:"owner"` // org ID
+	CreatedTime string `json:"createdTime"`
+	UpdatedTime string `json:"updatedTime"`
+	ProjectId   string `json:"projectId"`
+}
+
+func GetKmsProjects() ([]*KmsProject, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	projects := []*KmsProject{}
+	err := findAll(adapter.db, "kms_project", &projects, nil, "owner")
+	if err != nil {
+		return projects, err
+	}
+	return projects, nil
+}
+
+func GetKmsProject(owner string) (*KmsProject, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	project := KmsProject{Owner: owner}
+	existed, err := getOne(adapter.db, "kms_project", &project, dbx.HashExp{"owner": owner})
+	if err != nil {
+		return &project, err
+	}
+	if existed {
+		return &project, nil
+	}
+	return nil, nil
+}
+
+func validateKmsProject(project *KmsProject) error {
+	if project.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if project.Owner == "admin" {
+		return fmt.Errorf("admin providers use KMS_PROJECT_ID")
+	}
+	if project.ProjectId == "" {
+		return fmt.Errorf("projectId is required")
+	}
+	return nil
+}
+
+func AddKmsProject(project *KmsProject) (bool, error) {
+	if err := validateKmsProject(project); err != nil {
+		return false, err
+	}
+	project.CreatedTime = time.Now().Format(time.RFC3339)
+	project.UpdatedTime = project.CreatedTime
+	err := insertRow(adapter.db, project)
+	if err != nil {
+		return false, err
+	}
+	invalidateKmsProjectCache(project.Owner)
+	return true, nil
+}
+
+func UpdateKmsProject(owner string, project *KmsProject) (bool, error) {
+	project.Owner = owner
+	if err := validateKmsProject(project); err != nil {
+		return false, err
+	}
+	project.UpdatedTime = time.Now().Format(time.RFC3339)
+	err := adapter.db.Model(project).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateKmsProjectCache(owner)
+	return true, nil
+}
+
+func DeleteKmsProject(project *KmsProject) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "kms_project", dbx.HashExp{"owner": project.Owner})
+	if err != nil {
+		return false, err
+	}
+	invalidateKmsProjectCache(project.Owner)
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type kmsProjectCacheEntry struct {
+	projectID string
+	fetchedAt time.Time
+}
+
+var (
+	kmsProjectCache    = make(map[string]*kmsProjectCacheEntry)
+	kmsProjectCacheMu  sync.RWMutex
+	kmsProjectCacheTTL = 60 * time.Second
+)
+
+// invalidateKmsProjectCache drops the cached mapping of owner, and the
+// owner's cached providers, whose secrets were resolved in the old project.
+func invalidateKmsProjectCache(owner string) {
+	kmsProjectCacheMu.Lock()
+	delete(kmsProjectCache, owner)
+	kmsProjectCacheMu.Unlock()
+	forgetCachedModelProvidersOf(owner)
+}
+
+// getOrgKmsProject returns the KMS project registered for an organization,
+// "" when it has none, with 60s TTL caching.
+func getOrgKmsProject(owner string) (string, error) {
+	kmsProjectCacheMu.RLock()
+	entry, ok := kmsProjectCache[owner]
+	kmsProjectCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < kmsProjectCacheTTL {
+		return entry.projectID, nil
+	}
+	project, err := GetKmsProject(owner)
+	if err != nil {
+		return "", err
+	}
+	projectID := ""
+	if project != nil {
+		projectID = project.ProjectId
+	}
+	kmsProjectCacheMu.Lock()
+	kmsProjectCache[owner] = &kmsProjectCacheEntry{projectID: projectID, fetchedAt: time.Now()}
+	kmsProjectCacheMu.Unlock()
+	return projectID, nil
+}
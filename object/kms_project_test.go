@@ -0,0 +1,52 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKmsProjectForProvider(t *testing.T) {
+	kmsProjectCacheMu.Lock()
+	kmsProjectCache["acme"] = &kmsProjectCacheEntry{projectID: "acme-project", fetchedAt: time.Now()}
+	kmsProjectCache["initech"] = &kmsProjectCacheEntry{fetchedAt: time.Now()}
+	kmsProjectCacheMu.Unlock()
+	defer func() {
+		kmsProjectCacheMu.Lock()
+		delete(kmsProjectCache, "acme")
+		delete(kmsProjectCache, "initech")
+		kmsProjectCacheMu.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		provider *Provider
+		want     string
+		wantErr  bool
+	}{
+		{"registered org", &Provider{Owner: "acme"}, "acme-project", false},
+		{"ConfigText is ignored", &Provider{Owner: "acme", ConfigText: "kms-project:old"}, "acme-project", false},
+		{"unregistered org with ConfigText", &Provider{Owner: "initech", ConfigText: "region: eu\nkms-project:legacy"}, "", true},
+		{"unregistered org", &Provider{Owner: "initech"}, "", true},
+		{"admin uses the default", &Provider{Owner: "admin"}, "", false},
+	}
+	for _, tt := range tests {
+		got, err := kmsProjectForProvider(tt.provider)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: kmsProjectForProvider() = %q, %v; want %q, err=%v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestParseVersionedSecretRef(t *testing.T) {
+	cases := []struct {
+		ref     string
+		name    string
+		version int
+		wantErr bool
+	}{
+		{"OPENAI_API_KEY", "OPENAI_API_KEY", 0, false},
+		{"OPENAI_API_KEY@v3", "OPENAI_API_KEY", 3, false},
+		{"OPENAI_API_KEY@3", "", 0, true},
+		{"OPENAI_API_KEY@v0", "", 0, true},
+		{"OPENAI_API_KEY@vx", "", 0, true},
+		{"@v2", "", 0, true},
+	}
+	for _, tc := range cases {
+		name, version, err := parseVersionedSecretRef(tc.ref)
+		if (err != nil) != tc.wantErr || name != tc.name || version != tc.version {
+			t.Errorf("parseVersionedSecretRef(%q) = %q, %d, %v; want %q, %d, err=%v",
+				tc.ref, name, version, err, tc.name, tc.version, tc.wantErr)
+		}
+	}
+}
+
+func TestOrgProviderSecretScope(t *testing.T) {
+	t.Setenv("ORG_SCOPE_TEST_KEY", "platform-secret")
+	org := &Provider{Owner: "acme", Name: "openai"}
+	admin := &Provider{Owner: "admin", Name: "openai"}
+
+	for _, value := range []string{"env://ORG_SCOPE_TEST_KEY", "vault://secret/data/openai#key", "awssm://openai"} {
+		if _, _, err := getProviderSecretResolver(org, value, "acme-project"); err == nil {
+			t.Errorf("org provider: %s resolved, want an error", value)
+		}
+		if resolver, _, err := getProviderSecretResolver(admin, value, ""); err != nil || resolver == nil {
+			t.Errorf("admin provider: %s = %v, %v; want a resolver", value, resolver, err)
+		}
+	}
+
+	cases := []struct {
+		value     string
+		projectID string
+	}{
+		{"kms://ORG_SCOPE_TEST_KEY", "acme-project"},
+		{"kms://other-project/OPENAI_API_KEY", "acme-project"},
+		{"kms://OPENAI_API_KEY", ""},
+	}
+	for _, tc := range cases {
+		resolver, ref, err := getProviderSecretResolver(org, tc.value, tc.projectID)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.value, err)
+		}
+		if value, err := resolver.Resolve(ref); err == nil {
+			t.Errorf("org provider: %s (project %q) = %q, want an error", tc.value, tc.projectID, value)
+		}
+	}
+
+	resolver, ref, _ := getProviderSecretResolver(org, "kms://other-project/OPENAI_API_KEY", "acme-project")
+	if project := resolver.(*kmsSecretResolver).project(ref); project != "" {
+		t.Errorf("project() of a foreign reference = %q, want empty", project)
+	}
+}
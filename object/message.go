@@ -15,6 +15,7 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -361,7 +362,7 @@ func GetAnswerWithContext(provider string, question string, history []*model.Raw
 		prompt = "You are an expert in your field and you specialize in using your knowledge to answer or solve people's problems."
 	}
 	var writer MyWriter
-	modelResult, err := modelProviderObj.QueryText(question, &writer, history, prompt, knowledge, nil, lang)
+	modelResult, err := modelProviderObj.QueryText(context.Background(), question, &writer, history, prompt, knowledge, nil, lang)
 	if err != nil {
 		return "", nil, err
 	}
@@ -0,0 +1,150 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// ModelEntitlement grants an organization an entitlement (e.g. "enterprise")
+// that lists the models requiring it in the organization's catalog.
+type ModelEntitlement struct {
+	Owner       string `db:"pk" json:"owner"` // org ID
+	Name        string `db:"pk" json:"name"`  // matched against a model's entitlement in models.yaml
+	CreatedTime string `json:"createdTime"`
+	ExpireTime  string `json:"expireTime"` // RFC3339; empty never expires
+	Enabled     bool   `json:"enabled"`
+}
+
+func (e *ModelEntitlement) GetId() string {
+	return fmt.Sprintf("%s/%s", e.Owner, e.Name)
+}
+
+// IsActive reports whether the entitlement is enabled and not expired at now.
+func (e *ModelEntitlement) IsActive(now time.Time) bool {
+	if !e.Enabled {
+		return false
+	}
+	if e.ExpireTime == "" {
+		return true
+	}
+	expireTime, err := time.Parse(time.RFC3339, e.ExpireTime)
+	return err == nil && now.Before(expireTime)
+}
+
+func GetModelEntitlements(owner string) ([]*ModelEntitlement, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	entitlements := []*ModelEntitlement{}
+	err := findAll(adapter.db, "model_entitlement", &entitlements, dbx.HashExp{"owner": owner}, "created_time DESC")
+	if err != nil {
+		return entitlements, err
+	}
+	return entitlements, nil
+}
+
+func GetModelEntitlement(owner string, name string) (*ModelEntitlement, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	entitlement := ModelEntitlement{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "model_entitlement", &entitlement, dbx.HashExp{"owner": owner, "name": name})
+	if err != nil {
+		return &entitlement, err
+	}
+	if existed {
+		return &entitlement, nil
+	}
+	return nil, nil
+}
+
+func AddModelEntitlement(entitlement *ModelEntitlement) (bool, error) {
+	entitlement.CreatedTime = time.Now().Format(time.RFC3339)
+	err := insertRow(adapter.db, entitlement)
+	if err != nil {
+		return false, err
+	}
+	invalidateModelEntitlementCache()
+	return true, nil
+}
+
+func UpdateModelEntitlement(owner string, name string, entitlement *ModelEntitlement) (bool, error) {
+	entitlement.Owner = owner
+	entitlement.Name = name
+	err := adapter.db.Model(entitlement).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateModelEntitlementCache()
+	return true, nil
+}
+
+func DeleteModelEntitlement(entitlement *ModelEntitlement) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "model_entitlement", pk2(entitlement.Owner, entitlement.Name))
+	if err != nil {
+		return false, err
+	}
+	invalidateModelEntitlementCache()
+	return affected != 0, nil
+}
+
+// ── Cached resolution for the model listing ─────────────────────────────
+type modelEntitlementCacheEntry struct {
+	entitlements []*ModelEntitlement
+	fetchedAt    time.Time
+}
+
+var (
+	modelEntitlementCache    = make(map[string]*modelEntitlementCacheEntry)
+	modelEntitlementCacheMu  sync.RWMutex
+	modelEntitlementCacheTTL = 60 * time.Second
+)
+
+func invalidateModelEntitlementCache() {
+	modelEntitlementCacheMu.Lock()
+	modelEntitlementCache = make(map[string]*modelEntitlementCacheEntry)
+	modelEntitlementCacheMu.Unlock()
+}
+
+// GetActiveEntitlements returns the names of an organization's enabled,
+// unexpired entitlements, cached for 60 seconds.
+func GetActiveEntitlements(owner string) (map[string]bool, error) {
+	modelEntitlementCacheMu.RLock()
+	entry, ok := modelEntitlementCache[owner]
+	modelEntitlementCacheMu.RUnlock()
+	if !ok || time.Since(entry.fetchedAt) >= modelEntitlementCacheTTL {
+		entitlements, err := GetModelEntitlements(owner)
+		if err != nil {
+			return nil, err
+		}
+		entry = &modelEntitlementCacheEntry{entitlements: entitlements, fetchedAt: time.Now()}
+		modelEntitlementCacheMu.Lock()
+		modelEntitlementCache[owner] = entry
+		modelEntitlementCacheMu.Unlock()
+	}
+
+	now := time.Now()
+	active := map[string]bool{}
+	for _, entitlement := range entry.entitlements {
+		if entitlement.IsActive(now) {
+			active[entitlement.Name] = true
+		}
+	}
+	return active, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// OrgMemberLimit is what an organization's admins allow one member on the
+// gateway: a spend limit over a sliding 30-day window, and whether the
+// member's API keys work at all.
+type OrgMemberLimit struct {
+	Owner         string `db:"pk" json:"owner"` // org ID
+	User          string `db:"pk" json:"user"`  // member name within the org
+	CreatedTime   string `json:"createdTime"`
+	UpdatedTime   string `json:"updatedTime"`
+	MaxSpendCents int64  `json:"maxSpendCents"` // 0 is unlimited
+	KeysDisabled  bool   `json:"keysDisabled"`
+}
+
+func (l *OrgMemberLimit) GetId() string {
+	return fmt.Sprintf("%s/%s", l.Owner, l.User)
+}
+
+func GetOrgMemberLimits(owner string) ([]*OrgMemberLimit, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	limits := []*OrgMemberLimit{}
+	err := findAll(adapter.db, "org_member_limit", &limits, dbx.HashExp{"owner": owner}, "user")
+	if err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+func GetOrgMemberLimit(owner string, user string) (*OrgMemberLimit, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	limit := OrgMemberLimit{Owner: owner, User: user}
+	existed, err := getOne(adapter.db, "org_member_limit", &limit, dbx.HashExp{"owner": owner, "user": user})
+	if err != nil {
+		return &limit, err
+	}
+	if existed {
+		return &limit, nil
+	}
+	return nil, nil
+}
+
+func validateOrgMemberLimit(limit *OrgMemberLimit) error {
+	if limit.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if limit.User == "" {
+		return fmt.Errorf("user is required")
+	}
+	if limit.MaxSpendCents < 0 {
+		return fmt.Errorf("maxSpendCents must not be negative")
+	}
+	return nil
+}
+
+// SetOrgMemberLimit stores limit, adding it when the member has none yet.
+func SetOrgMemberLimit(limit *OrgMemberLimit) (bool, error) {
+	if err := validateOrgMemberLimit(limit); err != nil {
+		return false, err
+	}
+	existing, err := GetOrgMemberLimit(limit.Owner, limit.User)
+	if err != nil {
+		return false, err
+	}
+
+	limit.UpdatedTime = time.Now().Format(time.RFC3339)
+	if existing == nil {
+		limit.CreatedTime = limit.UpdatedTime
+		err = insertRow(adapter.db, limit)
+	} else {
+		limit.CreatedTime = existing.CreatedTime
+		err = adapter.db.Model(limit).Update()
+	}
+	if err != nil {
+		return false, err
+	}
+	invalidateOrgMemberLimitCache()
+	return true, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type orgMemberLimitCacheEntry struct {
+	limit     *OrgMemberLimit
+	fetchedAt time.Time
+}
+
+var (
+	orgMemberLimitCache    = make(map[string]*orgMemberLimitCacheEntry)
+	orgMemberLimitCacheMu  sync.RWMutex
+	orgMemberLimitCacheTTL = 60 * time.Second
+)
+
+func invalidateOrgMemberLimitCache() {
+	orgMemberLimitCacheMu.Lock()
+	orgMemberLimitCache = make(map[string]*orgMemberLimitCacheEntry)
+	orgMemberLimitCacheMu.Unlock()
+}
+
+// GetCachedOrgMemberLimit returns a member's limit, or nil when it has none,
+// with 60s TTL caching.
+func GetCachedOrgMemberLimit(owner string, user string) (*OrgMemberLimit, error) {
+	key := owner + "/" + user
+	orgMemberLimitCacheMu.RLock()
+	entry, ok := orgMemberLimitCache[key]
+	orgMemberLimitCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < orgMemberLimitCacheTTL {
+		return entry.limit, nil
+	}
+	limit, err := GetOrgMemberLimit(owner, user)
+	if err != nil {
+		return nil, err
+	}
+	orgMemberLimitCacheMu.Lock()
+	orgMemberLimitCache[key] = &orgMemberLimitCacheEntry{limit: limit, fetchedAt: time.Now()}
+	orgMemberLimitCacheMu.Unlock()
+	return limit, nil
+}
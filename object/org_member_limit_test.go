@@ -0,0 +1,35 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestValidateOrgMemberLimit(t *testing.T) {
+	tests := []struct {
+		limit   OrgMemberLimit
+		wantErr bool
+	}{
+		{OrgMemberLimit{Owner: "acme", User: "alice", MaxSpendCents: 5000}, false},
+		{OrgMemberLimit{Owner: "acme", User: "alice", KeysDisabled: true}, false},
+		{OrgMemberLimit{Owner: "acme", MaxSpendCents: 5000}, true},
+		{OrgMemberLimit{User: "alice"}, true},
+		{OrgMemberLimit{Owner: "acme", User: "alice", MaxSpendCents: -1}, true},
+	}
+	for _, tc := range tests {
+		if err := validateOrgMemberLimit(&tc.limit); (err != nil) != tc.wantErr {
+			t.Errorf("validateOrgMemberLimit(%+v) = %v, want error %v", tc.limit, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/util"
+	"github.com/hanzoai/dbx"
+)
+
+// PII types the scrubber reports. Custom counts the matches of an
+// organization's own patterns.
+const (
+	PiiTypeEmail      = "email"
+	PiiTypePhone      = "phone"
+	PiiTypeCreditCard = "credit_card"
+	PiiTypeCustom     = "custom"
+)
+
+// piiDetector finds one built-in PII type. valid, when set, rejects matches
+// that only look like the type, such as card numbers failing the Luhn check.
+type piiDetector struct {
+	typ         string
+	pattern     *regexp.Regexp
+	replacement string
+	valid       func(match string) bool
+}
+
+// piiDetectors run in order, so card numbers are replaced before the phone
+// pattern can claim part of them. A phone number is an international one
+// written with its "+", or a North American one with its digits grouped the
+// usual way, so that bare runs of digits, such as IDs, order numbers and
+// timestamps, are left alone.
+var piiDetectors = []piiDetector{
+	{
+		typ:         PiiTypeEmail,
+		pattern:     regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		replacement: "[EMAIL]",
+	},
+	{
+		typ:         PiiTypeCreditCard,
+		pattern:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		replacement: "[CREDIT_CARD]",
+		valid:       luhnValid,
+	},
+	{
+		typ:         PiiTypePhone,
+		pattern:     regexp.MustCompile(`(?:\+\d{1,3}(?:[ .-]?\(?\d{1,4}\)?){2,5}|\([2-9]\d{2}\) ?[2-9]\d{2}[ .-]\d{4}|\b[2-9]\d{2}(?:-[2-9]\d{2}-|\.[2-9]\d{2}\.| [2-9]\d{2} )\d{4})\b`),
+		replacement: "[PHONE]",
+		valid:       phoneValid,
+	},
+}
+
+// PiiCustomRedaction replaces the matches of an organization's own patterns.
+const PiiCustomRedaction = "[REDACTED]"
+
+func piiDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// phoneValid reports whether s has as many digits as an E.164 number can.
+func phoneValid(s string) bool {
+	n := len(piiDigits(s))
+	return n >= 7 && n <= 15
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum card
+// numbers carry.
+func luhnValid(s string) bool {
+	digits := piiDigits(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// PiiReport counts the redactions by PII type.
+type PiiReport map[string]int
+
+// String formats the report as "credit_card=1, email=2", in type order.
+func (r PiiReport) String() string {
+	types := make([]string, 0, len(r))
+	for typ, count := range r {
+		if count > 0 {
+			types = append(types, typ)
+		}
+	}
+	sort.Strings(types)
+	parts := make([]string, 0, len(types))
+	for _, typ := range types {
+		parts = append(parts, fmt.Sprintf("%s=%d", typ, r[typ]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PiiSetting is an organization's opt-in to PII scrubbing. Prompts are
+// scrubbed before they leave the gateway; logs when captured prompts and
+// responses or conversation messages are stored.
+type PiiSetting struct {
+	Owner        string      `db:"pk" json:"owner"` // org ID
+	UpdatedTime  string      `json:"updatedTime"`
+	ScrubPrompts bool        `json:"scrubPrompts"`
+	ScrubLogs    bool        `json:"scrubLogs"`
+	Types        StringSlice `json:"types"`    // built-in types to detect; empty means all
+	Patterns     StringSlice `json:"patterns"` // extra regular expressions, reported as "custom"
+
+	detectors []piiDetector // set by Compile
+	custom    *regexp.Regexp
+}
+
+// Compile selects the setting's built-in detectors and builds the single
+// expression matching any of its patterns.
+func (s *PiiSetting) Compile() error {
+	for _, typ := range s.Types {
+		if typ != PiiTypeEmail && typ != PiiTypePhone && typ != PiiTypeCreditCard {
+			return fmt.Errorf("type must be %q, %q or %q", PiiTypeEmail, PiiTypePhone, PiiTypeCreditCard)
+		}
+	}
+	s.detectors = nil
+	for _, detector := range piiDetectors {
+		if len(s.Types) == 0 || util.InSlice(s.Types, detector.typ) {
+			s.detectors = append(s.detectors, detector)
+		}
+	}
+
+	s.custom = nil
+	alternatives := []string{}
+	for _, pattern := range s.Patterns {
+		if pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	if len(alternatives) > 0 {
+		custom, err := regexp.Compile(strings.Join(alternatives, "|"))
+		if err != nil {
+			return err
+		}
+		s.custom = custom
+	}
+	return nil
+}
+
+// Scrub replaces the PII in text and counts the replacements in report.
+func (s *PiiSetting) Scrub(text string, report PiiReport) string {
+	for _, detector := range s.detectors {
+		text = detector.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if detector.valid != nil && !detector.valid(match) {
+				return match
+			}
+			report[detector.typ]++
+			return detector.replacement
+		})
+	}
+	if s.custom != nil {
+		text = s.custom.ReplaceAllStringFunc(text, func(string) string {
+			report[PiiTypeCustom]++
+			return PiiCustomRedaction
+		})
+	}
+	return text
+}
+
+func GetPiiSetting(owner string) (*PiiSetting, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	setting := PiiSetting{Owner: owner}
+	existed, err := getOne(adapter.db, "pii_setting", &setting, dbx.HashExp{"owner": owner})
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		return nil, nil
+	}
+	return &setting, nil
+}
+
+// UpdatePiiSetting creates or replaces an organization's PII setting.
+func UpdatePiiSetting(setting *PiiSetting) (bool, error) {
+	if setting.Owner == "" {
+		return false, fmt.Errorf("owner is required")
+	}
+	if err := setting.Compile(); err != nil {
+		return false, err
+	}
+	setting.UpdatedTime = time.Now().UTC().Format(time.RFC3339)
+
+	existing, err := GetPiiSetting(setting.Owner)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		err = insertRow(adapter.db, setting)
+	} else {
+		err = adapter.db.Model(setting).Update()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	piiSettingCacheMu.Lock()
+	delete(piiSettingCache, setting.Owner)
+	piiSettingCacheMu.Unlock()
+	return true, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type piiSettingCacheEntry struct {
+	setting   *PiiSetting
+	fetchedAt time.Time
+}
+
+var (
+	piiSettingCache    = make(map[string]*piiSettingCacheEntry)
+	piiSettingCacheMu  sync.RWMutex
+	piiSettingCacheTTL = 60 * time.Second
+)
+
+// GetActivePiiSetting returns an organization's compiled PII setting, or nil
+// when it has none, with 60s TTL caching. A setting whose patterns no longer
+// compile is an error, so that nothing goes unscrubbed.
+func GetActivePiiSetting(owner string) (*PiiSetting, error) {
+	piiSettingCacheMu.RLock()
+	entry, ok := piiSettingCache[owner]
+	piiSettingCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < piiSettingCacheTTL {
+		return entry.setting, nil
+	}
+
+	setting, err := GetPiiSetting(owner)
+	if err != nil {
+		return nil, err
+	}
+	if setting != nil {
+		if err = setting.Compile(); err != nil {
+			return nil, fmt.Errorf("the PII setting of %s: %v", owner, err)
+		}
+	}
+	piiSettingCacheMu.Lock()
+	piiSettingCache[owner] = &piiSettingCacheEntry{setting: setting, fetchedAt: time.Now()}
+	piiSettingCacheMu.Unlock()
+	return setting, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestPiiSettingScrub(t *testing.T) {
+	setting := &PiiSetting{Patterns: StringSlice{`EMP-\d{6}`}}
+	if err := setting.Compile(); err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"mail jane.doe+ai@mail.example.co.uk now", "mail [EMAIL] now"},
+		{"card 4111 1111 1111 1111 exp 12/29", "card [CREDIT_CARD] exp 12/29"},
+		{"card 4111 1111 1111 1112 fails Luhn", "card 4111 1111 1111 1112 fails Luhn"},
+		{"call (415) 555-0132 or +44 20 7946 0958", "call [PHONE] or [PHONE]"},
+		{"text 415.555.0132 or +14155550132", "text [PHONE] or [PHONE]"},
+		{"order 12345 shipped in 2025", "order 12345 shipped in 2025"},
+		{"ticket 4155550132 at 1729152000", "ticket 4155550132 at 1729152000"},
+		{"id 123-456-7890, build 415-555.0132", "id 123-456-7890, build 415-555.0132"},
+		{"badge EMP-004211", "badge [REDACTED]"},
+	}
+	for _, tt := range tests {
+		if got := setting.Scrub(tt.text, PiiReport{}); got != tt.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	report := PiiReport{}
+	setting.Scrub("a@b.io, c@d.io, 4111-1111-1111-1111, EMP-000001", report)
+	if got, want := report.String(), "credit_card=1, custom=1, email=2"; got != want {
+		t.Errorf("report = %q, want %q", got, want)
+	}
+}
+
+func TestPiiSettingTypes(t *testing.T) {
+	setting := &PiiSetting{Types: StringSlice{PiiTypeEmail}}
+	if err := setting.Compile(); err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	if got, want := setting.Scrub("a@b.io, (415) 555-0132", PiiReport{}), "[EMAIL], (415) 555-0132"; got != want {
+		t.Errorf("Scrub() = %q, want %q", got, want)
+	}
+
+	for _, invalid := range []*PiiSetting{
+		{Types: StringSlice{"ssn"}},
+		{Patterns: StringSlice{"(unclosed"}},
+	} {
+		if err := invalid.Compile(); err == nil {
+			t.Errorf("Compile(%v) = nil, want an error", invalid)
+		}
+	}
+}
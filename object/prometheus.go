@@ -15,8 +15,13 @@ package object
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	io_prometheus_client "github.com/prometheus/client_model/go"
@@ -46,8 +51,9 @@ var (
 		Help: "The throughput of each api access",
 	}, []string{"path", "method"})
 	ApiLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
-		Name: "cloud_api_latency",
-		Help: "API processing latency in milliseconds",
+		Name:    "cloud_api_latency",
+		Help:    "API processing latency in milliseconds",
+		Buckets: getApiLatencyBuckets(),
 	}, []string{"path", "method"})
 	CpuUsage = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "cloud_cpu_usage",
@@ -61,8 +67,296 @@ var (
 		Name: "cloud_total_throughput",
 		Help: "The total throughput of Hanzo Cloud",
 	})
+	JwtRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_jwt_rejected_total",
+		Help: "JWTs rejected during validation, by reason",
+	}, []string{"reason"})
+	ModelRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_model_requests_total",
+		Help: "Gateway model requests, by model, provider, tier and status",
+	}, []string{"model", "provider", "tier", "status"})
+	ModelPromptTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_model_prompt_tokens_total",
+		Help: "Prompt tokens consumed through the gateway",
+	}, []string{"model", "provider", "tier", "status"})
+	ModelCompletionTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_model_completion_tokens_total",
+		Help: "Completion tokens generated through the gateway",
+	}, []string{"model", "provider", "tier", "status"})
+	ModelBilledCents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_model_billed_cents_total",
+		Help: "Cost of gateway requests in cents, from the model pricing table",
+	}, []string{"model", "provider", "tier", "status"})
+	ModelUpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_model_upstream_errors_total",
+		Help: "Failed gateway requests, by model, provider and error class",
+	}, []string{"model", "provider", "class"})
+	UpstreamRateLimits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_upstream_rate_limits_total",
+		Help: "Gateway requests rate limited by the upstream provider, by provider",
+	}, []string{"provider"})
+	ModelUpstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_model_upstream_latency_seconds",
+		Help:    "Latency of non-streaming gateway requests",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "provider", "tier", "status"})
+	ModelStreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_model_stream_duration_seconds",
+		Help:    "Total duration of streaming gateway requests",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model", "provider", "tier", "status"})
+	ModelTimeToFirstToken = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_model_time_to_first_token_seconds",
+		Help:    "Time from request start to the first streamed token",
+		Buckets: []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 20},
+	}, []string{"model", "provider"})
+	ModelTokensPerSecond = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_model_tokens_per_second",
+		Help:    "Completion tokens per second between the first and last streamed token",
+		Buckets: []float64{5, 10, 20, 30, 50, 75, 100, 150, 200, 300, 500},
+	}, []string{"model", "provider"})
+	ModelPromptTokenDrift = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_model_prompt_token_drift_ratio",
+		Help:    "Prompt tokens reported by the upstream over the gateway's own pre-flight count, by model, provider and tokenizer model",
+		Buckets: []float64{0.5, 0.75, 0.9, 0.95, 0.98, 1.02, 1.05, 1.1, 1.25, 1.5, 2},
+	}, []string{"model", "provider", "tokenizer"})
+	ShadowRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_shadow_requests_total",
+		Help: "Chat requests mirrored to a model's shadow upstream, by model, shadow upstream and status (success, error, dropped)",
+	}, []string{"model", "upstream", "status"})
+	ShadowLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_shadow_latency_seconds",
+		Help:    "Upstream latency of mirrored chat requests, by model, upstream and role (primary, shadow)",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "upstream", "role"})
+	ShadowCompletionTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_shadow_completion_tokens_total",
+		Help: "Completion tokens of mirrored chat requests, by model, upstream and role (primary, shadow)",
+	}, []string{"model", "upstream", "role"})
+	ShadowAnswerSimilarity = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_shadow_answer_similarity_ratio",
+		Help:    "Word overlap of the shadow upstream's answer with the primary's, from 0 (none) to 1 (same words), by model and shadow upstream",
+		Buckets: []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	}, []string{"model", "upstream"})
+	StoreLimitExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_store_limit_exceeded_total",
+		Help: "Model API requests that hit a limit of their store, by store and limit (frequency: rejected, memory: history trimmed)",
+	}, []string{"store", "limit"})
+	ExperimentRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_experiment_requests_total",
+		Help: "Chat requests served by an experiment arm, by experiment, arm and status",
+	}, []string{"experiment", "arm", "status"})
+	ExperimentTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_experiment_tokens_total",
+		Help: "Tokens of the chat requests served by an experiment arm, by experiment, arm and type (prompt, completion)",
+	}, []string{"experiment", "arm", "type"})
+	ExperimentLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_experiment_latency_seconds",
+		Help:    "Latency of the chat requests served by an experiment arm",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"experiment", "arm"})
+	TenantRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_tenant_requests_total",
+		Help: "Gateway model requests, by organization and status. Organizations outside metricsTenantAllowlist are reported as \"other\"",
+	}, []string{"org", "status"})
+	TenantTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_tenant_tokens_total",
+		Help: "Tokens consumed through the gateway, by organization and type (prompt, completion)",
+	}, []string{"org", "type"})
+	TenantBilledCents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_tenant_billed_cents_total",
+		Help: "Cost of gateway requests in cents, by organization",
+	}, []string{"org"})
+	TenantLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_tenant_request_duration_seconds",
+		Help:    "Duration of gateway requests, by organization",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"org"})
+	TenantInflightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloud_tenant_inflight_requests",
+		Help: "Gateway requests currently being served, by organization",
+	}, []string{"org"})
+	ZapRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_zap_requests_total",
+		Help: "Native ZAP method calls, by method, transport (ws, node, http) and status class",
+	}, []string{"method", "transport", "status"})
+	ZapErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_zap_errors_total",
+		Help: "Failed native ZAP method calls, by method and status code",
+	}, []string{"method", "code"})
+	ZapLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_zap_request_duration_seconds",
+		Help:    "Duration of native ZAP method calls, including streamed responses",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"method", "transport"})
+	LivePricingFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_live_pricing_fetches_total",
+		Help: "Live pricing refreshes, by result (ok, error, empty, frozen)",
+	}, []string{"result"})
+	LivePricingRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_live_pricing_rejected_total",
+		Help: "Live prices rejected for moving more than max_price_change in one refresh, by model",
+	}, []string{"model"})
+	LivePricingLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_live_pricing_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful live pricing refresh",
+	})
+	LivePricingStale = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_live_pricing_stale",
+		Help: "1 when live pricing has not refreshed successfully for two pricing TTLs, 0 otherwise",
+	})
+	KmsFetchLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloud_kms_fetch_duration_seconds",
+		Help:    "Latency of secret fetches from the KMS API, by result",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"})
+	KmsCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_kms_cache_lookups_total",
+		Help: "KMS secret cache lookups, by result (memory_hit, kv_hit, miss)",
+	}, []string{"result"})
+	KmsTokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_kms_token_refreshes_total",
+		Help: "KMS universal auth token refreshes, by result",
+	}, []string{"result"})
+	KmsFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_kms_failures_total",
+		Help: "Failed KMS operations, by operation (auth, fetch, write, health)",
+	}, []string{"operation"})
+	SecretAuditsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_secret_audits_dropped_total",
+		Help: "Secret audit rows dropped because the audit queue was full",
+	})
+	UsageRecorderQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_usage_recorder_queue_depth",
+		Help: "Usage recording jobs waiting for a worker",
+	})
+	UsageRecorderJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_usage_recorder_jobs_total",
+		Help: "Usage recording jobs submitted, by result (queued, delayed when the queue was full, dropped)",
+	}, []string{"result"})
+	StorageRetentionDeletedFiles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_storage_retention_deleted_files_total",
+		Help: "Files deleted by the storage retention sweep, by reason (expired)",
+	}, []string{"reason"})
+	StorageRetentionDeletedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_storage_retention_deleted_bytes_total",
+		Help: "Bytes freed by the storage retention sweep, by reason (expired)",
+	}, []string{"reason"})
+	StorageRetentionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_storage_retention_errors_total",
+		Help: "Files the storage retention sweep failed to delete",
+	})
+	StorageRetentionLastSweep = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_storage_retention_last_sweep_timestamp_seconds",
+		Help: "Unix time of the last storage retention sweep",
+	})
+	GuardrailDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_guardrail_decisions_total",
+		Help: "Guardrail policy matches, by stage (input, output) and action (block, flag, redact)",
+	}, []string{"stage", "action"})
+	GuardrailErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_guardrail_errors_total",
+		Help: "Guard model calls that failed; blocking moderation policies reject the text, the others let it through",
+	})
+	IdentityLeaks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_identity_leaks_total",
+		Help: "Zen answers that named the model or provider underneath, by model and outcome (served, retried)",
+	}, []string{"model", "outcome"})
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_webhook_deliveries_total",
+		Help: "Webhook delivery attempts, by event type and outcome (succeeded, retrying, failed)",
+	}, []string{"event", "status"})
+	PiiRedactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_pii_redactions_total",
+		Help: "PII redacted from prompts before they left the gateway, by type (email, phone, credit_card, custom)",
+	}, []string{"type"})
+)
+
+// defaultApiLatencyBuckets are the cloud_api_latency bucket boundaries in
+// milliseconds when apiLatencyBuckets is unset.
+var defaultApiLatencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// getApiLatencyBuckets reads the comma-separated, increasing millisecond
+// boundaries of apiLatencyBuckets. Invalid values fall back to the defaults.
+func getApiLatencyBuckets() []float64 {
+	raw := conf.GetConfigString("apiLatencyBuckets")
+	if raw == "" {
+		return defaultApiLatencyBuckets
+	}
+	buckets, err := parseHistogramBuckets(raw)
+	if err != nil {
+		logs.Error("apiLatencyBuckets: %v, using defaults", err)
+		return defaultApiLatencyBuckets
+	}
+	return buckets
+}
+
+func parseHistogramBuckets(raw string) ([]float64, error) {
+	buckets := []float64{}
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q", field)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be increasing, got %v after %v", bound, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no buckets in %q", raw)
+	}
+	return buckets, nil
+}
+
+// ObserveApiLatency records an API latency in milliseconds. When traceId is
+// set it is attached as an exemplar, so a slow bucket in Grafana links to
+// the trace of a request that landed in it.
+func ObserveApiLatency(path, method string, latencyMs float64, traceId string) {
+	observer := ApiLatency.WithLabelValues(path, method)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && traceId != "" {
+		exemplarObserver.ObserveWithExemplar(latencyMs, prometheus.Labels{"trace_id": traceId})
+		return
+	}
+	observer.Observe(latencyMs)
+}
+
+// otherTenantLabel replaces organizations outside the tenant allowlist so
+// that per-tenant metrics keep a bounded cardinality.
+const otherTenantLabel = "other"
+
+var (
+	tenantAllowlistMu  sync.Mutex
+	tenantAllowlistRaw string
+	tenantAllowlist    map[string]bool
 )
 
+// TenantMetricLabel returns org if it is in the comma-separated
+// metricsTenantAllowlist config, and "other" otherwise.
+func TenantMetricLabel(org string) string {
+	raw := conf.GetConfigString("metricsTenantAllowlist")
+
+	tenantAllowlistMu.Lock()
+	defer tenantAllowlistMu.Unlock()
+	if tenantAllowlist == nil || raw != tenantAllowlistRaw {
+		tenantAllowlist = map[string]bool{}
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				tenantAllowlist[name] = true
+			}
+		}
+		tenantAllowlistRaw = raw
+	}
+	if tenantAllowlist[org] {
+		return org
+	}
+	return otherTenantLabel
+}
+
 func ClearThroughputPerSecond() {
 	ticker := time.NewTicker(time.Second)
 	for range ticker.C {
@@ -0,0 +1,276 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/dbx"
+)
+
+// promptTemplatePlaceholder matches a {{variable}} of a prompt template.
+var promptTemplatePlaceholder = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// PromptTemplateMessage is a message a prompt template puts in front of a
+// chat request's messages.
+type PromptTemplateMessage struct {
+	Role    string `json:"role"` // "system", "developer", "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// PromptTemplateVariable is a variable of a prompt template. A variable
+// without a default must be given at every call unless it is optional.
+type PromptTemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+	Optional    bool   `json:"optional"` // rendered as "" when not given
+}
+
+// PromptTemplate is a version of an organization's named prompt template.
+// Versions are never changed: an update adds the next version, and chat
+// requests use the latest one unless they pin a version.
+type PromptTemplate struct {
+	Owner       string                   `db:"pk" json:"owner"` // org ID
+	Name        string                   `db:"pk" json:"name"`
+	Version     int                      `db:"pk" json:"version"`
+	CreatedTime string                   `json:"createdTime"`
+	Description string                   `json:"description"`
+	Messages    []PromptTemplateMessage  `db:"json" json:"messages"`
+	Variables   []PromptTemplateVariable `db:"json" json:"variables"`
+}
+
+func (t *PromptTemplate) GetId() string {
+	return fmt.Sprintf("%s/%s", t.Owner, t.Name)
+}
+
+// Render returns the template's messages with its variables replaced by
+// values, or by their defaults. Values are inserted as they are, so a value
+// containing a placeholder is not expanded.
+func (t *PromptTemplate) Render(values map[string]string) ([]PromptTemplateMessage, error) {
+	known := map[string]PromptTemplateVariable{}
+	for _, variable := range t.Variables {
+		known[variable.Name] = variable
+	}
+	unknown := []string{}
+	for name := range values {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("prompt template %s has no variables %s", t.Name, strings.Join(unknown, ", "))
+	}
+
+	resolved := map[string]string{}
+	for _, variable := range t.Variables {
+		value, ok := values[variable.Name]
+		if !ok {
+			if variable.Default == "" && !variable.Optional {
+				return nil, fmt.Errorf("prompt template %s needs the variable %s", t.Name, variable.Name)
+			}
+			value = variable.Default
+		}
+		resolved[variable.Name] = value
+	}
+
+	messages := make([]PromptTemplateMessage, 0, len(t.Messages))
+	for _, message := range t.Messages {
+		content := promptTemplatePlaceholder.ReplaceAllStringFunc(message.Content, func(placeholder string) string {
+			return resolved[promptTemplatePlaceholder.FindStringSubmatch(placeholder)[1]]
+		})
+		messages = append(messages, PromptTemplateMessage{Role: message.Role, Content: content})
+	}
+	return messages, nil
+}
+
+func validatePromptTemplate(t *PromptTemplate) error {
+	if t.Owner == "" || t.Name == "" {
+		return fmt.Errorf("owner and name are required")
+	}
+	if len(t.Messages) == 0 {
+		return fmt.Errorf("a prompt template needs at least one message")
+	}
+
+	declared := map[string]bool{}
+	for _, variable := range t.Variables {
+		if !promptTemplatePlaceholder.MatchString("{{" + variable.Name + "}}") {
+			return fmt.Errorf("invalid variable name %q", variable.Name)
+		}
+		if declared[variable.Name] {
+			return fmt.Errorf("variable %s is declared twice", variable.Name)
+		}
+		declared[variable.Name] = true
+	}
+	for i, message := range t.Messages {
+		switch message.Role {
+		case "system", "developer", "user", "assistant":
+		default:
+			return fmt.Errorf("messages[%d]: role must be system, developer, user or assistant", i)
+		}
+		for _, match := range promptTemplatePlaceholder.FindAllStringSubmatch(message.Content, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("messages[%d]: variable %s is not declared", i, match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// GetPromptTemplates returns the latest version of each of an
+// organization's prompt templates, in name order.
+func GetPromptTemplates(owner string) ([]*PromptTemplate, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	versions := []*PromptTemplate{}
+	err := findAll(adapter.db, "prompt_template", &versions, dbx.HashExp{"owner": owner}, "name", "version DESC")
+	if err != nil {
+		return versions, err
+	}
+	templates := []*PromptTemplate{}
+	for _, version := range versions {
+		if len(templates) == 0 || templates[len(templates)-1].Name != version.Name {
+			templates = append(templates, version)
+		}
+	}
+	return templates, nil
+}
+
+// GetPromptTemplateVersions returns every version of a prompt template,
+// newest first.
+func GetPromptTemplateVersions(owner string, name string) ([]*PromptTemplate, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	versions := []*PromptTemplate{}
+	err := findAll(adapter.db, "prompt_template", &versions, dbx.HashExp{"owner": owner, "name": name}, "version DESC")
+	if err != nil {
+		return versions, err
+	}
+	return versions, nil
+}
+
+// GetPromptTemplate returns a version of a prompt template, the latest one
+// when version is 0, or nil if there is none.
+func GetPromptTemplate(owner string, name string, version int) (*PromptTemplate, error) {
+	versions, err := GetPromptTemplateVersions(owner, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range versions {
+		if version == 0 || template.Version == version {
+			return template, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddPromptTemplate adds version 1 of a new prompt template.
+func AddPromptTemplate(t *PromptTemplate) (bool, error) {
+	existing, err := GetPromptTemplate(t.Owner, t.Name, 0)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, fmt.Errorf("prompt template %s already exists", t.GetId())
+	}
+	t.Version = 1
+	return insertPromptTemplate(t)
+}
+
+// UpdatePromptTemplate adds the next version of a prompt template.
+func UpdatePromptTemplate(owner string, name string, t *PromptTemplate) (bool, error) {
+	latest, err := GetPromptTemplate(owner, name, 0)
+	if err != nil {
+		return false, err
+	}
+	if latest == nil {
+		return false, nil
+	}
+	t.Owner = owner
+	t.Name = name
+	t.Version = latest.Version + 1
+	return insertPromptTemplate(t)
+}
+
+func insertPromptTemplate(t *PromptTemplate) (bool, error) {
+	if err := validatePromptTemplate(t); err != nil {
+		return false, err
+	}
+	t.CreatedTime = time.Now().Format(time.RFC3339)
+	err := insertRow(adapter.db, t)
+	if err != nil {
+		return false, err
+	}
+	invalidatePromptTemplateCache(t.Owner, t.Name)
+	return true, nil
+}
+
+// DeletePromptTemplate deletes every version of a prompt template.
+func DeletePromptTemplate(t *PromptTemplate) (bool, error) {
+	affected, err := deleteWhere(adapter.db, "prompt_template", dbx.HashExp{"owner": t.Owner, "name": t.Name})
+	if err != nil {
+		return false, err
+	}
+	invalidatePromptTemplateCache(t.Owner, t.Name)
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+
+// promptTemplateCache caches prompt templates by owner/name/version, the
+// latest version under version 0. Misses are not cached, so a template is
+// found as soon as it is added.
+var promptTemplateCache = cache.NewLoading[*PromptTemplate]("prompt-template", cache.Options{
+	MaxEntries: promptTemplateCacheMaxEntries,
+	TTL:        promptTemplateCacheTTL,
+	Shared:     true,
+})
+
+const (
+	promptTemplateCacheTTL        = 60 * time.Second
+	promptTemplateCacheMaxEntries = 4096
+)
+
+// errPromptTemplateNotFound keeps a miss out of promptTemplateCache.
+var errPromptTemplateNotFound = errors.New("prompt template not found")
+
+func invalidatePromptTemplateCache(owner string, name string) {
+	promptTemplateCache.InvalidatePrefix(fmt.Sprintf("%s/%s/", owner, name))
+}
+
+// GetCachedPromptTemplate is GetPromptTemplate with 60s TTL caching, for
+// rendering templates into chat requests.
+func GetCachedPromptTemplate(owner string, name string, version int) (*PromptTemplate, error) {
+	template, err := promptTemplateCache.Get(fmt.Sprintf("%s/%s/%d", owner, name, version), func() (*PromptTemplate, error) {
+		template, err := GetPromptTemplate(owner, name, version)
+		if err == nil && template == nil {
+			err = errPromptTemplateNotFound
+		}
+		return template, err
+	})
+	if errors.Is(err, errPromptTemplateNotFound) {
+		return nil, nil
+	}
+	return template, err
+}
@@ -0,0 +1,86 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testPromptTemplate() PromptTemplate {
+	return PromptTemplate{
+		Owner: "acme",
+		Name:  "support",
+		Messages: []PromptTemplateMessage{
+			{Role: "system", Content: "You support {{ product }} customers{{tone}}. Reply in {{language}}."},
+		},
+		Variables: []PromptTemplateVariable{
+			{Name: "product"},
+			{Name: "language", Default: "English"},
+			{Name: "tone", Optional: true},
+		},
+	}
+}
+
+func TestPromptTemplateRender(t *testing.T) {
+	template := testPromptTemplate()
+
+	got, err := template.Render(map[string]string{"product": "Vault {{language}}"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := []PromptTemplateMessage{{Role: "system", Content: "You support Vault {{language}} customers. Reply in English."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Render() = %+v, want %+v", got, want)
+	}
+
+	got, err = template.Render(map[string]string{"product": "Vault", "language": "French", "tone": " kindly"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "You support Vault customers kindly. Reply in French."; got[0].Content != want {
+		t.Errorf("Render() = %q, want %q", got[0].Content, want)
+	}
+
+	if _, err = template.Render(nil); err == nil {
+		t.Error("Render() without a required variable succeeded")
+	}
+	if _, err = template.Render(map[string]string{"product": "Vault", "prodcut": "Vault"}); err == nil {
+		t.Error("Render() with an undeclared variable succeeded")
+	}
+}
+
+func TestValidatePromptTemplate(t *testing.T) {
+	valid := testPromptTemplate()
+	if err := validatePromptTemplate(&valid); err != nil {
+		t.Fatalf("valid template rejected: %v", err)
+	}
+
+	tests := map[string]func(t *PromptTemplate){
+		"no name":             func(t *PromptTemplate) { t.Name = "" },
+		"no messages":         func(t *PromptTemplate) { t.Messages = nil },
+		"bad role":            func(t *PromptTemplate) { t.Messages = []PromptTemplateMessage{{Role: "tool", Content: "x"}} },
+		"undeclared variable": func(t *PromptTemplate) { t.Variables = t.Variables[1:] },
+		"bad variable name":   func(t *PromptTemplate) { t.Variables = append(t.Variables, PromptTemplateVariable{Name: "a-b"}) },
+		"duplicate variable":  func(t *PromptTemplate) { t.Variables = append(t.Variables, PromptTemplateVariable{Name: "tone"}) },
+	}
+	for name, mutate := range tests {
+		template := testPromptTemplate()
+		mutate(&template)
+		if err := validatePromptTemplate(&template); err == nil {
+			t.Errorf("%s: template accepted", name)
+		}
+	}
+}
@@ -46,6 +46,11 @@ type Provider struct {
 	ProviderUrl                  string             `json:"providerUrl"`
 	ApiVersion                   string             `json:"apiVersion"`
 	CompatibleProvider           string             `json:"compatibleProvider"`
+	HttpTimeout                  int                `json:"httpTimeout"`
+	HttpMaxIdleConns             int                `json:"httpMaxIdleConns"`
+	HttpKeepAlive                int                `json:"httpKeepAlive"`
+	HttpProxyUrl                 string             `json:"httpProxyUrl"`
+	HttpCaCert                   string             `json:"httpCaCert"`
 	McpTools                     agent.McpToolsList `json:"mcpTools"`
 	Text                         string             `json:"text"`
 	ConfigText                   string             `json:"configText"`
@@ -104,6 +109,9 @@ func GetMaskedProvider(provider *Provider, isMaskEnabled bool, user *iamsdk.User
 		if provider.SignKey != "" {
 			provider.SignKey = "***"
 		}
+		if provider.HttpProxyUrl != "" {
+			provider.HttpProxyUrl = "***"
+		}
 	}
 	return provider
 }
@@ -210,6 +218,8 @@ func UpdateProvider(id string, provider *Provider) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+		forgetCachedModelProvider(owner, name)
+		invalidateProviderSecrets(providerDb)
 		// return affected != 0
 		return true, nil
 	}
@@ -219,6 +229,10 @@ func UpdateProvider(id string, provider *Provider) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	forgetCachedModelProvider(owner, name)
+	// Saving a provider re-fetches its secrets, so a key rotated behind an
+	// unchanged reference applies as well.
+	invalidateProviderSecrets(providerDb)
 	// return affected != 0
 	return true, nil
 }
@@ -232,12 +246,14 @@ func AddProvider(provider *Provider) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+		forgetCachedModelProvider(provider.Owner, provider.Name)
 		return true, nil
 	}
 	err := insertRow(adapter.db, provider)
 	if err != nil {
 		return false, err
 	}
+	forgetCachedModelProvider(provider.Owner, provider.Name)
 	return true, nil
 }
 
@@ -247,12 +263,14 @@ func DeleteProvider(provider *Provider) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+		forgetCachedModelProvider(provider.Owner, provider.Name)
 		return affected != 0, nil
 	}
 	affected, err := deleteByPK(adapter.db, "provider", pk2(provider.Owner, provider.Name))
 	if err != nil {
 		return false, err
 	}
+	forgetCachedModelProvider(provider.Owner, provider.Name)
 	return affected != 0, nil
 }
 
@@ -274,7 +292,7 @@ func GetDefaultKubernetesProvider(lang string) (*Provider, error) {
 }
 
 func (p *Provider) GetStorageProviderObj(vectorStoreId string, lang string) (storage.StorageProvider, error) {
-	pProvider, err := storage.GetStorageProvider(p.Type, p.ClientId, p.ClientSecret, p.Name, vectorStoreId, lang)
+	pProvider, err := storage.GetStorageProvider(p.Type, p.ClientId, p.ClientSecret, p.Region, p.ProviderUrl, p.Name, vectorStoreId, lang)
 	if err != nil {
 		return nil, err
 	}
@@ -285,13 +303,27 @@ func (p *Provider) GetStorageProviderObj(vectorStoreId string, lang string) (sto
 }
 
 func (p *Provider) GetModelProvider(lang string) (model.ModelProvider, error) {
-	pProvider, err := model.GetModelProvider(p.Type, p.SubType, p.ClientId, p.ClientSecret, p.UserKey, p.Temperature, p.TopP, p.TopK, p.FrequencyPenalty, p.PresencePenalty, p.ProviderUrl, p.ApiVersion, p.CompatibleProvider, p.InputPricePerThousandTokens, p.OutputPricePerThousandTokens, p.Currency, p.EnableThinking)
+	clientId, apiVersion, providerUrl := p.ClientId, p.ApiVersion, p.ProviderUrl
+	if p.Type == "Azure" {
+		// Azure serves each model from a deployment (see provider_azure.go)
+		clientId, apiVersion = GetAzureDeployment(p, p.SubType), GetAzureApiVersion(p)
+	} else if p.Type == SelfHostedProviderType {
+		providerUrl = GetSelfHostedBaseUrl(p)
+	}
+	pProvider, err := model.GetModelProvider(p.Type, p.SubType, clientId, p.ClientSecret, p.UserKey, p.Temperature, p.TopP, p.TopK, p.FrequencyPenalty, p.PresencePenalty, providerUrl, apiVersion, p.CompatibleProvider, p.InputPricePerThousandTokens, p.OutputPricePerThousandTokens, p.Currency, p.EnableThinking)
 	if err != nil {
 		return nil, err
 	}
 	if pProvider == nil {
 		return nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "object:the model provider type: %s is not supported"), p.Type))
 	}
+	if setter, ok := pProvider.(model.HttpClientSetter); ok {
+		httpClient, err := p.GetHttpClient()
+		if err != nil {
+			return nil, err
+		}
+		setter.SetHttpClient(httpClient)
+	}
 	return pProvider, nil
 }
 
@@ -303,6 +335,13 @@ func (p *Provider) GetEmbeddingProvider(lang string) (embedding.EmbeddingProvide
 	if pProvider == nil {
 		return nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "object:the embedding provider type: %s is not supported"), p.Type))
 	}
+	if setter, ok := pProvider.(model.HttpClientSetter); ok {
+		httpClient, err := p.GetHttpClient()
+		if err != nil {
+			return nil, err
+		}
+		setter.SetHttpClient(httpClient)
+	}
 	return pProvider, nil
 }
 
@@ -577,6 +616,9 @@ func (p *Provider) processProviderParams(providerDb *Provider) {
 	if p.SignKey == "***" {
 		p.SignKey = providerDb.SignKey
 	}
+	if p.HttpProxyUrl == "***" {
+		p.HttpProxyUrl = providerDb.HttpProxyUrl
+	}
 	if p.ProviderKey == "" && p.Category == "Model" {
 		p.ProviderKey = generateProviderKey()
 	}
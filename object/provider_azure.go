@@ -0,0 +1,84 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"strings"
+
+	"github.com/hanzoai/cloud/conf"
+)
+
+// Azure OpenAI providers (Type "Azure") serve models from the deployments of
+// an Azure OpenAI resource. ProviderUrl is the resource endpoint
+// (https://{resource}.openai.azure.com), ApiVersion the REST API version and
+// ClientSecret the resource key. ClientId names the default deployment;
+// ConfigText maps models to their own deployments, one per line:
+//
+//	deployment:gpt-4o=prod-gpt4o
+//	deployment:gpt-4o-mini=prod-gpt4o-mini
+//
+// An organization routes gpt-* models through its own Azure capacity by
+// registering such a provider and pointing its org model routes at it.
+
+// AzureDefaultApiVersion is used when an Azure provider sets no ApiVersion
+// and azureApiVersion is not configured. It replaces the gateway's former
+// 2024-02-01 and the SDK's 2023-05-15 defaults; set azureApiVersion, or
+// ApiVersion per provider, to keep an older version.
+const AzureDefaultApiVersion = "2024-10-21"
+
+const azureDeploymentPrefix = "deployment:"
+
+// GetAzureDeployments returns the model → deployment mapping of an Azure
+// provider's ConfigText, keyed by lowercase model name.
+func GetAzureDeployments(provider *Provider) map[string]string {
+	deployments := map[string]string{}
+	for _, line := range strings.Split(provider.ConfigText, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, azureDeploymentPrefix) {
+			continue
+		}
+		model, deployment, ok := strings.Cut(strings.TrimPrefix(line, azureDeploymentPrefix), "=")
+		model = strings.ToLower(strings.TrimSpace(model))
+		deployment = strings.TrimSpace(deployment)
+		if ok && model != "" && deployment != "" {
+			deployments[model] = deployment
+		}
+	}
+	return deployments
+}
+
+// GetAzureDeployment returns the deployment serving model on an Azure
+// provider: its mapped deployment, else the default deployment, else a
+// deployment named after the model.
+func GetAzureDeployment(provider *Provider, model string) string {
+	if deployment, ok := GetAzureDeployments(provider)[strings.ToLower(model)]; ok {
+		return deployment
+	}
+	if provider.ClientId != "" {
+		return provider.ClientId
+	}
+	return model
+}
+
+// GetAzureApiVersion returns the REST API version of an Azure provider: its
+// ApiVersion, else the azureApiVersion config, else AzureDefaultApiVersion.
+func GetAzureApiVersion(provider *Provider) string {
+	if provider.ApiVersion != "" {
+		return provider.ApiVersion
+	}
+	if apiVersion := strings.TrimSpace(conf.GetConfigString("azureApiVersion")); apiVersion != "" {
+		return apiVersion
+	}
+	return AzureDefaultApiVersion
+}
@@ -0,0 +1,53 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestGetAzureDeployment(t *testing.T) {
+	provider := &Provider{
+		Type:       "Azure",
+		ClientId:   "default-deployment",
+		ConfigText: "kms-project:acme\ndeployment: GPT-4o = prod-gpt4o\ndeployment:gpt-4o-mini=\n",
+	}
+	tests := map[string]string{
+		"gpt-4o":      "prod-gpt4o",
+		"GPT-4O":      "prod-gpt4o",
+		"gpt-4o-mini": "default-deployment",
+		"o3":          "default-deployment",
+	}
+	for model, want := range tests {
+		if got := GetAzureDeployment(provider, model); got != want {
+			t.Errorf("GetAzureDeployment(%q) = %q, want %q", model, got, want)
+		}
+	}
+
+	provider.ClientId = ""
+	if got := GetAzureDeployment(provider, "o3"); got != "o3" {
+		t.Errorf("GetAzureDeployment without a default = %q, want o3", got)
+	}
+	if got := GetAzureApiVersion(provider); got != AzureDefaultApiVersion {
+		t.Errorf("GetAzureApiVersion = %q, want %q", got, AzureDefaultApiVersion)
+	}
+
+	t.Setenv("azureApiVersion", "2024-02-01")
+	if got := GetAzureApiVersion(provider); got != "2024-02-01" {
+		t.Errorf("GetAzureApiVersion with azureApiVersion set = %q, want 2024-02-01", got)
+	}
+	provider.ApiVersion = "2025-01-01-preview"
+	if got := GetAzureApiVersion(provider); got != "2025-01-01-preview" {
+		t.Errorf("GetAzureApiVersion with ApiVersion set = %q, want 2025-01-01-preview", got)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// providerTestTimeout bounds the completion of a provider connectivity test.
+const providerTestTimeout = 30 * time.Second
+
+// providerTestQuestion asks for a completion of a token or two.
+const providerTestQuestion = "Reply with the single word OK."
+
+// CheckProviderSecrets resolves every secret reference of a provider record
+// without changing it, so a reference to a missing secret is reported when
+// the provider is saved rather than on its first request. Unlike
+// ResolveProviderSecret, a kms:// reference fails when KMS is not configured
+// and no environment variable of its name is set.
+func CheckProviderSecrets(provider *Provider) error {
+	initKMS()
+	projectID, projectErr := kmsProjectForProvider(provider)
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"clientSecret", provider.ClientSecret},
+		{"userKey", provider.UserKey},
+		{"signKey", provider.SignKey},
+	}
+	for _, field := range fields {
+		resolver, ref, err := getProviderSecretResolver(provider, field.value, projectID)
+		if err != nil {
+			return err
+		}
+		if resolver == nil {
+			continue
+		}
+		if projectErr != nil {
+			return projectErr
+		}
+		if ref == "" {
+			return fmt.Errorf("secret: empty reference in field %s", field.name)
+		}
+		if _, err := resolver.Resolve(ref); err != nil {
+			return fmt.Errorf("secret %s of field %s does not resolve: %w", field.value, field.name, err)
+		}
+	}
+	return nil
+}
+
+// CheckModelProviderConnectivity asks a model provider for a tiny
+// completion, with its secrets resolved on a copy of the record.
+func CheckModelProviderConnectivity(provider *Provider, lang string, caller string) error {
+	p := *provider
+	if err := ResolveProviderSecretAs(&p, caller); err != nil {
+		return err
+	}
+	modelProvider, err := p.GetModelProvider(lang)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerTestTimeout)
+	defer cancel()
+	var writer MyWriter
+	_, err = modelProvider.QueryText(ctx, providerTestQuestion, &writer, nil, "", nil, nil, lang)
+	if err != nil {
+		return fmt.Errorf("provider %s failed the connectivity test: %w", provider.Name, err)
+	}
+	return nil
+}
+
+// CheckModelProvider validates a model provider before it is saved: its
+// secret references must resolve and, with connectivity set, it must answer
+// a completion. providerDb is the stored record an update replaces, whose
+// secrets stand in for masked ("***") ones; it is nil for a new provider.
+// caller is the admin checking it, for the secret audit log.
+func CheckModelProvider(provider *Provider, providerDb *Provider, connectivity bool, lang string, caller string) error {
+	p := *provider
+	if providerDb != nil {
+		p.processProviderParams(providerDb)
+	}
+	if err := CheckProviderSecrets(&p); err != nil {
+		return err
+	}
+	if !connectivity {
+		return nil
+	}
+	return CheckModelProviderConnectivity(&p, lang, caller)
+}
@@ -0,0 +1,64 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestCheckProviderSecrets(t *testing.T) {
+	t.Setenv("PROVIDER_CHECK_TEST_KEY", "sk-test")
+
+	cases := []struct {
+		provider Provider
+		wantErr  bool
+	}{
+		{Provider{Owner: "admin", Name: "plain", ClientSecret: "sk-plain"}, false},
+		{Provider{Owner: "admin", Name: "env", ClientSecret: "env://PROVIDER_CHECK_TEST_KEY"}, false},
+		{Provider{Owner: "admin", Name: "missing", ClientSecret: "env://PROVIDER_CHECK_MISSING"}, true},
+		{Provider{Owner: "admin", Name: "user-key", UserKey: "env://PROVIDER_CHECK_MISSING"}, true},
+		{Provider{Owner: "admin", Name: "empty", SignKey: "env://"}, true},
+	}
+	for _, tc := range cases {
+		err := CheckProviderSecrets(&tc.provider)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("CheckProviderSecrets(%s) = %v, wantErr %v", tc.provider.Name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCheckModelProvider(t *testing.T) {
+	t.Setenv("PROVIDER_CHECK_TEST_KEY", "sk-test")
+
+	provider := &Provider{Owner: "admin", Name: "dummy", Category: "Model", Type: "Dummy", ClientSecret: "***"}
+	stored := &Provider{Owner: "admin", Name: "dummy", Category: "Model", Type: "Dummy", ClientSecret: "env://PROVIDER_CHECK_TEST_KEY"}
+	if err := CheckModelProvider(provider, stored, true, "en", "admin/alice"); err != nil {
+		t.Errorf("CheckModelProvider() = %v for a masked secret of a stored provider", err)
+	}
+	if provider.ClientSecret != "***" || provider.ProviderKey != "" {
+		t.Error("CheckModelProvider() changed the provider it checked")
+	}
+
+	stored.ClientSecret = "env://PROVIDER_CHECK_MISSING"
+	if err := CheckModelProvider(provider, stored, false, "en", "admin/alice"); err == nil {
+		t.Error("CheckModelProvider() accepted a secret that does not resolve")
+	}
+
+	unsupported := &Provider{Owner: "admin", Name: "bogus", Category: "Model", Type: "Bogus"}
+	if err := CheckModelProvider(unsupported, nil, false, "en", "admin/alice"); err != nil {
+		t.Errorf("CheckModelProvider() without a connectivity test = %v", err)
+	}
+	if err := CheckModelProvider(unsupported, nil, true, "en", "admin/alice"); err == nil {
+		t.Error("CheckModelProvider() passed the connectivity test of an unsupported provider")
+	}
+}
@@ -15,11 +15,12 @@ package object
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
+	"github.com/hanzoai/cloud/cache"
 	"github.com/hanzoai/cloud/i18n"
 	"github.com/hanzoai/cloud/model"
+	"github.com/hanzoai/cloud/util"
 	"github.com/hanzoai/dbx"
 )
 
@@ -212,53 +213,135 @@ func GetDefaultSpeechToTextProvider() (*Provider, error) {
 	return &provider, nil
 }
 
-// providerByNameEntry caches a provider lookup by name to avoid per-request DB queries.
-type providerByNameEntry struct {
-	provider  *Provider
-	fetchedAt time.Time
-}
+// providerByNameCache caches provider lookups by owner/name to avoid
+// per-request DB queries. A nil provider caches a miss. Expired entries are
+// served for a while longer as the row is reloaded in the background. The
+// providers carry resolved secrets, so they are never shared through the
+// distributed cache; only their invalidations are. Every replica drops the
+// HTTP clients of the providers invalidated with them.
+var providerByNameCache = cache.NewLoading[*Provider]("provider", cache.Options{
+	MaxEntries:   providerByNameCacheMaxEntries,
+	TTL:          providerByNameCacheTTL,
+	StaleTTL:     providerByNameStaleTTL,
+	OnInvalidate: forgetProviderHttpClients,
+})
 
-var (
-	providerByNameCache    = make(map[string]*providerByNameEntry)
-	providerByNameCacheMu  sync.RWMutex
-	providerByNameCacheTTL = 60 * time.Second
+const (
+	providerByNameCacheTTL        = 60 * time.Second
+	providerByNameStaleTTL        = 5 * time.Minute
+	providerByNameCacheMaxEntries = 4096
 )
 
-// GetModelProviderByName retrieves a Model-category provider by its Name field
-// (e.g. "do-ai", "fireworks", "openai-direct"). Results are cached for 60 seconds.
-func GetModelProviderByName(name string) (*Provider, error) {
-	providerByNameCacheMu.RLock()
-	entry, ok := providerByNameCache[name]
-	providerByNameCacheMu.RUnlock()
-	if ok && time.Since(entry.fetchedAt) < providerByNameCacheTTL {
-		if entry.provider == nil {
-			return nil, nil
+// GetModelProviderByName retrieves an admin-owned Model-category provider by
+// its Name field (e.g. "do-ai", "fireworks", "openai-direct"). Results are
+// cached for 60 seconds, then refreshed in the background. caller is
+// recorded in the secret audit log when the lookup resolves its secrets
+// (see ResolveProviderSecretAs).
+func GetModelProviderByName(name string, caller string) (*Provider, error) {
+	return getCachedModelProvider("admin", name, caller)
+}
+
+// GetModelProviderForOrg retrieves the Model-category provider named name
+// for an organization's traffic: a provider the organization registered
+// under that name (with its own keys and KMS project) takes
+// precedence over the admin one.
+func GetModelProviderForOrg(owner string, name string, caller string) (*Provider, error) {
+	if owner != "" && owner != "admin" {
+		provider, err := getCachedModelProvider(owner, name, caller)
+		if err != nil {
+			return nil, err
+		}
+		if provider != nil {
+			return provider, nil
 		}
-		// Return a shallow copy so callers can mutate fields (e.g. SubType)
-		// without corrupting the cached value.
-		cp := *entry.provider
-		return &cp, nil
-	}
-	provider, err := getProvider("admin", name)
-	if err != nil {
-		return nil, err
 	}
-	if provider != nil {
-		// Resolve KMS-backed secrets (e.g. "kms://DO_AI_API_KEY" → actual key).
-		if err := ResolveProviderSecret(provider); err != nil {
+	return getCachedModelProvider("admin", name, caller)
+}
+
+// GetEmbeddingProviderForOrg retrieves the Embedding-category provider named
+// name for an organization's traffic, preferring one the organization
+// registered itself over the admin one.
+func GetEmbeddingProviderForOrg(owner string, name string, caller string) (*Provider, error) {
+	owners := []string{"admin"}
+	if owner != "" && owner != "admin" {
+		owners = []string{owner, "admin"}
+	}
+	for _, o := range owners {
+		provider, err := getProvider(o, name)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil || provider.Category != "Embedding" {
+			continue
+		}
+		if err = ResolveProviderSecretAs(provider, caller); err != nil {
 			return nil, err
 		}
+		return provider, nil
 	}
-	providerByNameCacheMu.Lock()
-	providerByNameCache[name] = &providerByNameEntry{provider: provider, fetchedAt: time.Now()}
-	providerByNameCacheMu.Unlock()
-	if provider == nil {
-		return nil, nil
+	return nil, nil
+}
+
+// getCachedModelProvider looks up owner's provider named name through the
+// cache. Rows outside the Model category are ignored for organizations, so a
+// storage provider that happens to share a name never captures model traffic.
+func getCachedModelProvider(owner string, name string, caller string) (*Provider, error) {
+	provider, err := providerByNameCache.Get(util.GetIdFromOwnerAndName(owner, name), func() (*Provider, error) {
+		provider, err := getProvider(owner, name)
+		if err != nil {
+			return nil, err
+		}
+		if provider != nil && owner != "admin" && provider.Category != "Model" {
+			return nil, nil
+		}
+		if provider != nil {
+			// Resolve KMS-backed secrets (e.g. "kms://DO_AI_API_KEY" → actual key).
+			if err := ResolveProviderSecretAs(provider, caller); err != nil {
+				return nil, err
+			}
+		}
+		return provider, nil
+	})
+	if err != nil || provider == nil {
+		return nil, err
 	}
+	// Return a shallow copy so callers can mutate fields (e.g. SubType)
+	// without corrupting the cached value.
 	cp := *provider
 	return &cp, nil
 }
 
+// forgetCachedModelProvider drops a cached provider lookup after the row
+// changes, so edits to an organization's provider apply immediately.
+func forgetCachedModelProvider(owner string, name string) {
+	providerByNameCache.Invalidate(util.GetIdFromOwnerAndName(owner, name))
+}
+
+// forgetCachedModelProvidersOf drops every cached provider lookup of owner.
+func forgetCachedModelProvidersOf(owner string) {
+	providerByNameCache.InvalidatePrefix(owner + "/")
+}
+
+// InvalidateModelProviderByName drops the cached admin provider and its
+// cached KMS secrets, so the next GetModelProviderByName re-resolves rotated
+// keys.
+func InvalidateModelProviderByName(name string) error {
+	return InvalidateModelProviderForOrg("admin", name)
+}
+
+// InvalidateModelProviderForOrg drops the cached provider owner registered
+// under name, and its cached KMS secrets.
+func InvalidateModelProviderForOrg(owner string, name string) error {
+	forgetCachedModelProvider(owner, name)
+
+	provider, err := getProvider(owner, name)
+	if err != nil {
+		return err
+	}
+	invalidateProviderSecrets(provider)
+	return nil
+}
+
 // GetModelProviderByType retrieves a model provider by its type (e.g. "OpenAI", "Claude", "Fireworks").
 func GetModelProviderByType(providerType string) (*Provider, error) {
 	provider := &Provider{}
@@ -0,0 +1,58 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"testing"
+)
+
+func TestGetModelProviderForOrg(t *testing.T) {
+	seed := map[string]*Provider{
+		"admin/fireworks":   {Owner: "admin", Name: "fireworks", Category: "Model", ClientSecret: "admin-key"},
+		"acme/fireworks":    {Owner: "acme", Name: "fireworks", Category: "Model", ClientSecret: "acme-key"},
+		"initech/fireworks": nil, // cached miss: initech has no provider of its own
+	}
+	for key, provider := range seed {
+		providerByNameCache.Set(key, provider)
+	}
+	defer func() {
+		for key := range seed {
+			providerByNameCache.Invalidate(key)
+		}
+	}()
+
+	cases := []struct {
+		owner string
+		want  string
+	}{
+		{"acme", "acme"},
+		{"initech", "admin"},
+		{"", "admin"},
+		{"admin", "admin"},
+	}
+	for _, tc := range cases {
+		provider, err := GetModelProviderForOrg(tc.owner, "fireworks", "acme/alice")
+		if err != nil || provider == nil || provider.Owner != tc.want {
+			t.Errorf("GetModelProviderForOrg(%q) = %+v, %v; want the %s provider", tc.owner, provider, err, tc.want)
+		}
+	}
+
+	// Callers get a copy and cannot corrupt the cached provider
+	provider, _ := GetModelProviderForOrg("acme", "fireworks", "acme/alice")
+	provider.SubType = "changed"
+	if cached, _ := GetModelProviderForOrg("acme", "fireworks", "acme/alice"); cached.SubType != "" {
+		t.Error("mutating a returned provider changed the cached one")
+	}
+}
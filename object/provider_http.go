@@ -0,0 +1,157 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/proxy"
+)
+
+// Providers tune the HTTP client used for their upstream with HttpTimeout
+// and HttpKeepAlive (seconds), HttpMaxIdleConns, HttpProxyUrl and HttpCaCert
+// (PEM). Unset fields keep the defaults below, so a slow provider can be
+// given minutes while fast ones fail quickly. Every model and embedding
+// provider sends its upstream calls through this client; a local endpoint
+// with a self-signed certificate sets it as HttpCaCert.
+//
+// ConfigText adds provider quirks without code changes, one per line: extra
+// headers sent with every upstream request (overriding the defaults) and
+// default fields merged into JSON request bodies (the request's own fields
+// win). Body values are JSON, or plain strings when not valid JSON:
+//
+//	header:HTTP-Referer=https://hanzo.ai
+//	header:X-Title=Hanzo Cloud
+//	body:transforms=["middle-out"]
+//	body:safe_prompt=true
+
+const defaultProviderHttpTimeout = 120 * time.Second
+
+const (
+	providerHeaderPrefix = "header:"
+	providerBodyPrefix   = "body:"
+)
+
+type providerHttpClientEntry struct {
+	options    proxy.HttpClientOptions
+	configText string
+	client     *http.Client
+}
+
+// providerHttpClients keeps one client per provider so connections are
+// pooled across requests; it is rebuilt when the provider's settings change.
+var (
+	providerHttpClients   = map[string]*providerHttpClientEntry{}
+	providerHttpClientsMu sync.Mutex
+)
+
+// forgetProviderHttpClients drops the HTTP client of the provider id, or
+// with prefix set those of every provider whose id starts with it, and
+// closes their idle connections.
+func forgetProviderHttpClients(id string, prefix bool) {
+	providerHttpClientsMu.Lock()
+	defer providerHttpClientsMu.Unlock()
+	for key, entry := range providerHttpClients {
+		if key == id || (prefix && strings.HasPrefix(key, id)) {
+			entry.client.CloseIdleConnections()
+			delete(providerHttpClients, key)
+		}
+	}
+}
+
+// GetHttpClientOptions returns the HTTP client settings of the provider.
+func (p *Provider) GetHttpClientOptions() proxy.HttpClientOptions {
+	options := proxy.HttpClientOptions{
+		Timeout:      defaultProviderHttpTimeout,
+		MaxIdleConns: p.HttpMaxIdleConns,
+		ProxyUrl:     p.HttpProxyUrl,
+		CaCert:       p.HttpCaCert,
+	}
+	if options.ProxyUrl == "" && !p.isLocalEndpoint() {
+		// Hosted upstreams go through the configured socks5 proxy, if any.
+		options.ProxyUrl = proxy.ProxyUrl
+	}
+	if p.HttpTimeout > 0 {
+		options.Timeout = time.Duration(p.HttpTimeout) * time.Second
+	}
+	if p.HttpKeepAlive != 0 {
+		options.KeepAlive = time.Duration(p.HttpKeepAlive) * time.Second
+	}
+	return options
+}
+
+// getProviderConfigLines returns the values of the ConfigText lines of p
+// starting with prefix, split at the first "=".
+func getProviderConfigLines(p *Provider, prefix string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(p.ConfigText, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, prefix), "=")
+		name = strings.TrimSpace(name)
+		if ok && name != "" {
+			values[name] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// GetProviderHeaders returns the extra upstream headers of the provider.
+func GetProviderHeaders(p *Provider) map[string]string {
+	return getProviderConfigLines(p, providerHeaderPrefix)
+}
+
+// GetProviderExtraBody returns the default JSON body fields of the provider.
+func GetProviderExtraBody(p *Provider) map[string]json.RawMessage {
+	body := map[string]json.RawMessage{}
+	for name, value := range getProviderConfigLines(p, providerBodyPrefix) {
+		if json.Valid([]byte(value)) {
+			body[name] = json.RawMessage(value)
+		} else {
+			body[name], _ = json.Marshal(value)
+		}
+	}
+	return body
+}
+
+// isLocalEndpoint reports whether the provider serves from a URL of the
+// deployment's own rather than a hosted API.
+func (p *Provider) isLocalEndpoint() bool {
+	return p.Type == "Local" || p.Type == "Ollama" || p.Type == SelfHostedProviderType
+}
+
+// GetHttpClient returns the HTTP client for calls to the provider's upstream.
+func (p *Provider) GetHttpClient() (*http.Client, error) {
+	options := p.GetHttpClientOptions()
+	id := p.GetId()
+
+	providerHttpClientsMu.Lock()
+	defer providerHttpClientsMu.Unlock()
+	if entry, ok := providerHttpClients[id]; ok && entry.options == options && entry.configText == p.ConfigText {
+		return entry.client, nil
+	}
+	client, err := proxy.NewHttpClient(options)
+	if err != nil {
+		return nil, err
+	}
+	client = proxy.WithRequestDefaults(client, GetProviderHeaders(p), GetProviderExtraBody(p))
+	providerHttpClients[id] = &providerHttpClientEntry{options: options, configText: p.ConfigText, client: client}
+	return client, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hanzoai/cloud/proxy"
+	"github.com/hanzoai/dbx"
+)
+
+func TestGetProviderHttpClient(t *testing.T) {
+	provider := &Provider{Owner: "admin", Name: "http-client-test"}
+	client, err := provider.GetHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout != defaultProviderHttpTimeout {
+		t.Errorf("default timeout = %v, want %v", client.Timeout, defaultProviderHttpTimeout)
+	}
+	if again, _ := provider.GetHttpClient(); again != client {
+		t.Error("GetHttpClient() built a new client for unchanged settings")
+	}
+
+	provider.HttpTimeout = 600
+	provider.HttpMaxIdleConns = 50
+	provider.HttpProxyUrl = "http://proxy.internal:3128"
+	tuned, err := provider.GetHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tuned == client {
+		t.Fatal("GetHttpClient() reused the client after the settings changed")
+	}
+	if tuned.Timeout != 600*time.Second {
+		t.Errorf("timeout = %v, want 10m", tuned.Timeout)
+	}
+	transport := tuned.Transport.(*proxy.RateLimitTransport).Base.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 50 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 50", transport.MaxIdleConnsPerHost)
+	}
+	proxyUrl, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "api.fireworks.ai"}})
+	if err != nil || proxyUrl == nil || proxyUrl.Host != "proxy.internal:3128" {
+		t.Errorf("proxy = %v, %v, want proxy.internal:3128", proxyUrl, err)
+	}
+
+	provider.HttpCaCert = "not a certificate"
+	if _, err = provider.GetHttpClient(); err == nil {
+		t.Error("GetHttpClient() accepted an invalid CA certificate")
+	}
+}
+
+func TestForgetProviderHttpClients(t *testing.T) {
+	provider := &Provider{Owner: "forget-test", Name: "fireworks", HttpTimeout: 30}
+	client, err := provider.GetHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgetCachedModelProvider(provider.Owner, provider.Name)
+	again, err := provider.GetHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == client {
+		t.Error("GetHttpClient() reused the client of an invalidated provider")
+	}
+
+	forgetCachedModelProvidersOf(provider.Owner)
+	if third, _ := provider.GetHttpClient(); third == again {
+		t.Error("GetHttpClient() reused the client after its owner's providers were invalidated")
+	}
+}
+
+func TestAddColumns(t *testing.T) {
+	db, err := dbx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Each connection to :memory: is a database of its own.
+	db.DB().SetMaxOpenConns(1)
+	if _, err = db.NewQuery("CREATE TABLE provider (owner VARCHAR(100), name VARCHAR(100))").Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.NewQuery("INSERT INTO provider (owner, name) VALUES ('admin', 'old')").Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Adapter{driverName: "sqlite", db: db}
+	a.addColumns()
+	a.addColumns()
+
+	var provider struct {
+		Name             string
+		HttpTimeout      int
+		HttpMaxIdleConns int
+		HttpKeepAlive    int
+		HttpProxyUrl     string
+		HttpCaCert       string
+	}
+	if err = db.Select().From("provider").One(&provider); err != nil {
+		t.Fatalf("reading the provider with the added columns: %v", err)
+	}
+	if provider.Name != "old" || provider.HttpTimeout != 0 || provider.HttpCaCert != "" {
+		t.Errorf("provider = %+v, want the old row with zero settings", provider)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProviderRequestDefaults(t *testing.T) {
+	var gotHeader string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Title")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+	}))
+	defer server.Close()
+
+	provider := &Provider{
+		Owner: "admin",
+		Name:  "request-defaults-test",
+		ConfigText: "header:X-Title=Hanzo Cloud\n" +
+			"body:transforms=[\"middle-out\"]\n" +
+			"body:route=fallback\n" +
+			"body:model=ignored",
+	}
+	client, err := provider.GetHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString(`{"model":"gpt-4o"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if gotHeader != "Hanzo Cloud" {
+		t.Errorf("X-Title = %q, want %q", gotHeader, "Hanzo Cloud")
+	}
+	if gotBody["model"] != "gpt-4o" {
+		t.Errorf("model = %v, want the request's own value", gotBody["model"])
+	}
+	if gotBody["route"] != "fallback" {
+		t.Errorf("route = %v, want fallback", gotBody["route"])
+	}
+	if transforms, ok := gotBody["transforms"].([]interface{}); !ok || len(transforms) != 1 || transforms[0] != "middle-out" {
+		t.Errorf("transforms = %v, want [middle-out]", gotBody["transforms"])
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSelfHostedBaseUrl(t *testing.T) {
+	tests := map[string]string{
+		"":                             "",
+		"http://vllm.internal:8000":    "http://vllm.internal:8000/v1",
+		"http://vllm.internal:8000/":   "http://vllm.internal:8000/v1",
+		"https://tgi.internal/v1":      "https://tgi.internal/v1",
+		"localhost:11434":              "http://localhost:11434/v1",
+		"http://ollama.internal/v1///": "http://ollama.internal/v1",
+	}
+	for providerUrl, want := range tests {
+		if got := GetSelfHostedBaseUrl(&Provider{ProviderUrl: providerUrl}); got != want {
+			t.Errorf("GetSelfHostedBaseUrl(%q) = %q, want %q", providerUrl, got, want)
+		}
+	}
+}
+
+func TestCheckSelfHostedProvider(t *testing.T) {
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer local-key" || !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer server.Close()
+
+	provider := &Provider{Name: "vllm", Type: SelfHostedProviderType, ProviderUrl: server.URL, ClientSecret: "local-key"}
+	if err := CheckSelfHostedProvider(provider); err != nil {
+		t.Fatalf("healthy runtime: %v", err)
+	}
+	up = false
+	if err := CheckSelfHostedProvider(provider); err == nil {
+		t.Error("unavailable runtime reported healthy")
+	}
+	if err := CheckSelfHostedProvider(&Provider{Name: "none"}); err == nil {
+		t.Error("provider without URL reported healthy")
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/dbx"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// defaultRequestLogRetentionDays applies when requestLogRetentionDays is unset.
+	defaultRequestLogRetentionDays = 30
+
+	// defaultRequestLogCaptureChars caps captured prompts and responses when
+	// requestLogCaptureMaxChars is unset.
+	defaultRequestLogCaptureChars = 4096
+)
+
+// RequestLog is one gateway request. Prompt and Response are only captured
+// for organizations that opted in (see RequestLogSetting), and are truncated.
+type RequestLog struct {
+	Id               int     `db:"pk" json:"id"`
+	Owner            string  `json:"owner"` // organization
+	CreatedTime      string  `json:"createdTime"`
+	RequestId        string  `json:"requestId"`
+	User             string  `json:"user"`
+	Model            string  `json:"model"`
+	Provider         string  `json:"provider"`
+	Status           string  `json:"status"`
+	ErrorMsg         string  `json:"errorMsg"`
+	ErrorClass       string  `json:"errorClass"`
+	Stream           bool    `json:"stream"`
+	LatencyMs        int64   `json:"latencyMs"`
+	TtftMs           int64   `json:"ttftMs"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	TokensPerSecond  float64 `json:"tokensPerSecond"`
+	ClientIp         string  `json:"clientIp"`
+	Prompt           string  `json:"prompt"`
+	Response         string  `json:"response"`
+
+	// Guardrails are the guardrail policies that matched, as
+	// "stage:policy:action".
+	Guardrails StringSlice `json:"guardrails"`
+
+	// Experiment and ExperimentArm name the experiment arm that served the
+	// request; see Experiment.
+	Experiment    string `json:"experiment"`
+	ExperimentArm string `json:"experimentArm"`
+	// Rating is the caller's feedback on the answer: 1 good, -1 bad, 0 none.
+	Rating int `json:"rating"`
+}
+
+// RequestLogSetting holds an organization's request logging preferences.
+type RequestLogSetting struct {
+	Owner          string `db:"pk" json:"owner"`
+	UpdatedTime    string `json:"updatedTime"`
+	CapturePrompts bool   `json:"capturePrompts"`
+}
+
+// RequestLogFilter narrows a request log query. Empty fields match anything;
+// From and To are RFC3339 times.
+type RequestLogFilter struct {
+	Owner      string
+	User       string
+	Model      string
+	Provider   string
+	Status     string
+	ErrorClass string
+	From       string
+	To         string
+	BeforeId   int // only logs with a lower id, to page through an export
+}
+
+func (f *RequestLogFilter) where() dbx.Expression {
+	exps := []dbx.Expression{}
+	for column, value := range map[string]string{
+		"owner": f.Owner, "user": f.User, "model": f.Model, "provider": f.Provider, "status": f.Status,
+		"error_class": f.ErrorClass,
+	} {
+		if value != "" {
+			exps = append(exps, dbx.HashExp{column: value})
+		}
+	}
+	if f.From != "" {
+		exps = append(exps, dbx.NewExp("created_time >= {:from}", dbx.Params{"from": f.From}))
+	}
+	if f.To != "" {
+		exps = append(exps, dbx.NewExp("created_time < {:to}", dbx.Params{"to": f.To}))
+	}
+	if f.BeforeId > 0 {
+		exps = append(exps, dbx.NewExp("id < {:before_id}", dbx.Params{"before_id": f.BeforeId}))
+	}
+	return dbx.And(exps...)
+}
+
+func GetRequestLogCount(filter *RequestLogFilter) (int64, error) {
+	var count int64
+	err := adapter.db.Select("COUNT(*)").From("request_log").Where(filter.where()).Row(&count)
+	return count, err
+}
+
+func GetPaginationRequestLogs(filter *RequestLogFilter, offset, limit int) ([]*RequestLog, error) {
+	requestLogs := []*RequestLog{}
+	q := adapter.db.Select().From("request_log").Where(filter.where()).OrderBy("id DESC")
+	if limit > 0 {
+		q = q.Offset(int64(offset)).Limit(int64(limit))
+	}
+	err := q.All(&requestLogs)
+	if err != nil {
+		return requestLogs, err
+	}
+	return requestLogs, nil
+}
+
+func GetRequestLog(id int) (*RequestLog, error) {
+	requestLog := RequestLog{}
+	existed, err := getOne(adapter.db, "request_log", &requestLog, pkID(id))
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		return nil, nil
+	}
+	return &requestLog, nil
+}
+
+// RequestLogUsage totals the request logs of one model.
+type RequestLogUsage struct {
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	Errors           int64  `json:"errors"`
+	PromptTokens     int64  `json:"promptTokens"`
+	CompletionTokens int64  `json:"completionTokens"`
+	TotalTokens      int64  `json:"totalTokens"`
+}
+
+// GetRequestLogUsages returns per-model request and token totals for the
+// request logs matching filter, ordered by model.
+func GetRequestLogUsages(filter *RequestLogFilter) ([]*RequestLogUsage, error) {
+	usages := []*RequestLogUsage{}
+	err := adapter.db.Select(
+		"model",
+		"COUNT(*) AS requests",
+		"SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) AS errors",
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+		"COALESCE(SUM(total_tokens), 0) AS total_tokens",
+	).From("request_log").Where(filter.where()).GroupBy("model").OrderBy("model").All(&usages)
+	return usages, err
+}
+
+// RequestLogUserUsage totals the request logs of one user for one model.
+type RequestLogUserUsage struct {
+	User             string `json:"user"`
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	Errors           int64  `json:"errors"`
+	PromptTokens     int64  `json:"promptTokens"`
+	CompletionTokens int64  `json:"completionTokens"`
+	TotalTokens      int64  `json:"totalTokens"`
+}
+
+// GetRequestLogUserUsages returns per-user, per-model request and token
+// totals for the request logs matching filter, ordered by user and model.
+func GetRequestLogUserUsages(filter *RequestLogFilter) ([]*RequestLogUserUsage, error) {
+	usages := []*RequestLogUserUsage{}
+	err := adapter.db.Select(
+		"user",
+		"model",
+		"COUNT(*) AS requests",
+		"SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) AS errors",
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+		"COALESCE(SUM(total_tokens), 0) AS total_tokens",
+	).From("request_log").Where(filter.where()).GroupBy("user", "model").OrderBy("user", "model").All(&usages)
+	return usages, err
+}
+
+// truncateRunes limits s to maxChars characters without splitting a UTF-8
+// sequence, marking truncated values with an ellipsis.
+func truncateRunes(s string, maxChars int) string {
+	if utf8.RuneCountInString(s) <= maxChars {
+		return s
+	}
+	i, n := 0, 0
+	for i = range s {
+		if n == maxChars {
+			break
+		}
+		n++
+	}
+	return s[:i] + "…"
+}
+
+func getRequestLogCaptureChars() int {
+	if n := conf.GetConfigInt("requestLogCaptureMaxChars"); n > 0 {
+		return n
+	}
+	return defaultRequestLogCaptureChars
+}
+
+// AddRequestLog stores a request log. Prompt and Response are dropped unless
+// the organization opted in to capture, and scrubbed of PII (when its PII
+// setting asks for it) and truncated otherwise.
+func AddRequestLog(requestLog *RequestLog) error {
+	if adapter == nil || adapter.db == nil {
+		return nil
+	}
+
+	setting, err := getCachedRequestLogSetting(requestLog.Owner)
+	if err != nil {
+		return err
+	}
+	if setting == nil || !setting.CapturePrompts {
+		requestLog.Prompt = ""
+		requestLog.Response = ""
+	} else {
+		// Scrub before truncating so no half-cut PII escapes the patterns.
+		pii, err := GetActivePiiSetting(requestLog.Owner)
+		if err != nil {
+			return err
+		}
+		if pii != nil && pii.ScrubLogs {
+			report := PiiReport{}
+			requestLog.Prompt = pii.Scrub(requestLog.Prompt, report)
+			requestLog.Response = pii.Scrub(requestLog.Response, report)
+		}
+
+		maxChars := getRequestLogCaptureChars()
+		requestLog.Prompt = truncateRunes(requestLog.Prompt, maxChars)
+		requestLog.Response = truncateRunes(requestLog.Response, maxChars)
+	}
+
+	requestLog.Id = 0
+	if requestLog.CreatedTime == "" {
+		requestLog.CreatedTime = time.Now().UTC().Format(time.RFC3339)
+	}
+	return insertRow(adapter.db, requestLog)
+}
+
+// SetRequestLogRating records the feedback of user (owner/name) of org on
+// the answer of a request. It reports false when user made no such
+// request.
+func SetRequestLogRating(org string, user string, requestId string, rating int) (bool, error) {
+	where := dbx.HashExp{"owner": org, "user": user, "request_id": requestId}
+	count, err := countWhere(adapter.db, "request_log", where)
+	if err != nil || count == 0 {
+		return false, err
+	}
+	_, err = updateCols(adapter.db, "request_log", where, dbx.Params{"rating": rating})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ── Per-organization settings ────────────────────────────────────────────
+
+type requestLogSettingEntry struct {
+	setting   *RequestLogSetting
+	fetchedAt time.Time
+}
+
+var (
+	requestLogSettingCache    = make(map[string]*requestLogSettingEntry)
+	requestLogSettingCacheMu  sync.RWMutex
+	requestLogSettingCacheTTL = 60 * time.Second
+)
+
+func GetRequestLogSetting(owner string) (*RequestLogSetting, error) {
+	setting := RequestLogSetting{Owner: owner}
+	existed, err := getOne(adapter.db, "request_log_setting", &setting, dbx.HashExp{"owner": owner})
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		return nil, nil
+	}
+	return &setting, nil
+}
+
+func getCachedRequestLogSetting(owner string) (*RequestLogSetting, error) {
+	requestLogSettingCacheMu.RLock()
+	entry, ok := requestLogSettingCache[owner]
+	requestLogSettingCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < requestLogSettingCacheTTL {
+		return entry.setting, nil
+	}
+
+	setting, err := GetRequestLogSetting(owner)
+	if err != nil {
+		return nil, err
+	}
+	requestLogSettingCacheMu.Lock()
+	requestLogSettingCache[owner] = &requestLogSettingEntry{setting: setting, fetchedAt: time.Now()}
+	requestLogSettingCacheMu.Unlock()
+	return setting, nil
+}
+
+// UpdateRequestLogSetting creates or replaces an organization's settings.
+func UpdateRequestLogSetting(setting *RequestLogSetting) (bool, error) {
+	setting.UpdatedTime = time.Now().UTC().Format(time.RFC3339)
+
+	existing, err := GetRequestLogSetting(setting.Owner)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		err = insertRow(adapter.db, setting)
+	} else {
+		err = adapter.db.Model(setting).Update()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	requestLogSettingCacheMu.Lock()
+	delete(requestLogSettingCache, setting.Owner)
+	requestLogSettingCacheMu.Unlock()
+	return true, nil
+}
+
+// ── Retention ────────────────────────────────────────────────────────────
+
+func getRequestLogRetentionDays() int {
+	if days := conf.GetConfigInt("requestLogRetentionDays"); days > 0 {
+		return days
+	}
+	return defaultRequestLogRetentionDays
+}
+
+// DeleteExpiredRequestLogs removes request logs older than the retention
+// period and returns how many were deleted.
+func DeleteExpiredRequestLogs() (int64, error) {
+	cutoff := time.Now().UTC().AddDate(0, 0, -getRequestLogRetentionDays()).Format(time.RFC3339)
+	return deleteWhere(adapter.db, "request_log", dbx.NewExp("created_time < {:cutoff}", dbx.Params{"cutoff": cutoff}))
+}
+
+func deleteExpiredRequestLogsNoError() {
+	if adapter == nil || adapter.db == nil {
+		return
+	}
+	deleted, err := DeleteExpiredRequestLogs()
+	if err != nil {
+		logs.Error("deleteExpiredRequestLogsNoError() error: %s", err.Error())
+		return
+	}
+	if deleted > 0 {
+		logs.Info("request log: deleted %d logs older than %d days", deleted, getRequestLogRetentionDays())
+	}
+}
+
+func InitRequestLogRetention() {
+	deleteExpiredRequestLogsNoError()
+	cronJob := cron.New()
+	schedule := fmt.Sprintf("@every %ds", 3600)
+	_, err := cronJob.AddFunc(schedule, deleteExpiredRequestLogsNoError)
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
@@ -0,0 +1,36 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestTruncateRunes(t *testing.T) {
+	cases := []struct {
+		s        string
+		maxChars int
+		want     string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 5, "hello…"},
+		{"héllo wörld", 7, "héllo w…"},
+		{"日本語テキスト", 3, "日本語…"},
+	}
+	for _, tc := range cases {
+		if got := truncateRunes(tc.s, tc.maxChars); got != tc.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tc.s, tc.maxChars, got, tc.want)
+		}
+	}
+}
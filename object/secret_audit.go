@@ -0,0 +1,229 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/dbx"
+)
+
+// SecretAudit records one resolution of a provider secret reference. Secret
+// values are never stored, only the reference (e.g. "kms://OPENAI_API_KEY@v3").
+//
+// Rows are hash-chained per node: Hash is an HMAC, under the server's audit
+// key, of the row's fields and the Hash of the node's previous row, so
+// editing or deleting a row breaks the chain from that point on (see
+// VerifySecretAudits), and database access alone is not enough to forge a
+// consistent one. Chains are per node because replicas write concurrently.
+type SecretAudit struct {
+	Id          int    `db:"pk" json:"id"`
+	Owner       string `json:"owner"` // provider owner
+	CreatedTime string `json:"createdTime"`
+	Node        string `json:"node"`
+	Provider    string `json:"provider"`
+	Field       string `json:"field"`
+	Secret      string `json:"secret"` // the reference, never the value
+	Project     string `json:"project"`
+	Caller      string `json:"caller"`
+	Result      string `json:"result"` // "success" or "failure"
+	Error       string `json:"error"`
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+// computeHash returns the chain hash of the audit row given its PrevHash,
+// keyed by key.
+func (a *SecretAudit) computeHash(key []byte) string {
+	h := hmac.New(sha256.New, key)
+	for _, field := range []string{
+		a.PrevHash, a.CreatedTime, a.Node, a.Owner, a.Provider, a.Field,
+		a.Secret, a.Project, a.Caller, a.Result, a.Error,
+	} {
+		// Length-prefix each field so values cannot be shifted across fields.
+		fmt.Fprintf(h, "%d:%s|", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// secretAuditQueueSize bounds the rows waiting for the writer; rows beyond
+// it are dropped and counted rather than holding up the request that
+// resolved the secret.
+const secretAuditQueueSize = 1024
+
+var (
+	secretAuditOnce  sync.Once
+	secretAuditQueue chan *SecretAudit
+	secretAuditNode  string
+
+	secretAuditKeyOnce sync.Once
+	secretAuditKey     []byte
+)
+
+// getSecretAuditKey returns the HMAC key of the audit chain, from KMS
+// (SECRET_AUDIT_KEY) or secretAuditKey config. Without either the chain is
+// keyed by nothing and only detects accidental edits.
+func getSecretAuditKey() []byte {
+	secretAuditKeyOnce.Do(func() {
+		key := ""
+		if v, err := GetKMSSecret("SECRET_AUDIT_KEY"); err == nil && v != "" {
+			key = strings.TrimSpace(v)
+		}
+		if key == "" {
+			v, err := ResolveSecretRef(conf.GetConfigString("secretAuditKey"))
+			if err != nil {
+				logs.Error("secret audit: failed to resolve secretAuditKey: %v", err)
+			}
+			key = v
+		}
+		if key == "" {
+			logs.Warn("secret audit: no SECRET_AUDIT_KEY or secretAuditKey configured, the audit chain is not keyed")
+		}
+		secretAuditKey = []byte(key)
+	})
+	return secretAuditKey
+}
+
+// startSecretAuditWriter starts the single writer that appends audit rows in
+// order, so the per-node chain is never forked within a process.
+func startSecretAuditWriter() {
+	secretAuditOnce.Do(func() {
+		secretAuditNode, _ = os.Hostname()
+		if secretAuditNode == "" {
+			secretAuditNode = "unknown"
+		}
+		secretAuditQueue = make(chan *SecretAudit, secretAuditQueueSize)
+		go writeSecretAudits(secretAuditQueue)
+	})
+}
+
+func writeSecretAudits(queue <-chan *SecretAudit) {
+	key := getSecretAuditKey()
+	prevHash := ""
+	loaded := false
+	for audit := range queue {
+		if adapter == nil || adapter.db == nil {
+			continue
+		}
+		if !loaded {
+			last := SecretAudit{}
+			err := adapter.db.Select().From("secret_audit").
+				Where(dbx.HashExp{"node": secretAuditNode}).
+				OrderBy("id DESC").Limit(1).One(&last)
+			if err != nil && err != sql.ErrNoRows {
+				// Appending without the chain head would fork the chain.
+				logs.Error("secret audit: failed to load chain head, dropping %s/%s %s: %v", audit.Owner, audit.Provider, audit.Field, err)
+				continue
+			}
+			prevHash = last.Hash
+			loaded = true
+		}
+
+		audit.PrevHash = prevHash
+		audit.Hash = audit.computeHash(key)
+		if err := insertRow(adapter.db, audit); err != nil {
+			logs.Error("secret audit: failed to record %s/%s %s: %v", audit.Owner, audit.Provider, audit.Field, err)
+			continue
+		}
+		prevHash = audit.Hash
+	}
+}
+
+// recordSecretAudit queues an audit row without blocking; when the queue is
+// full the row is dropped, logged and counted in SecretAuditsDropped.
+func recordSecretAudit(audit *SecretAudit) {
+	startSecretAuditWriter()
+	audit.CreatedTime = time.Now().UTC().Format(time.RFC3339Nano)
+	audit.Node = secretAuditNode
+	select {
+	case secretAuditQueue <- audit:
+	default:
+		SecretAuditsDropped.Inc()
+		logs.Error("secret audit: queue full, dropped %s/%s %s by %s", audit.Owner, audit.Provider, audit.Field, audit.Caller)
+	}
+}
+
+func GetSecretAuditCount(owner, field, value string) (int64, error) {
+	session := GetDbQuery(owner, -1, -1, field, value, "", "")
+	return queryCount(session, "secret_audit")
+}
+
+func GetPaginationSecretAudits(owner string, offset, limit int, field, value, sortField, sortOrder string) ([]*SecretAudit, error) {
+	audits := []*SecretAudit{}
+	session := GetDbQuery(owner, offset, limit, field, value, sortField, sortOrder)
+	err := queryFind(session, "secret_audit", &audits)
+	if err != nil {
+		return audits, err
+	}
+	return audits, nil
+}
+
+// SecretAuditVerification is the result of checking the audit hash chains.
+type SecretAuditVerification struct {
+	Checked  int    `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenId int    `json:"brokenId,omitempty"`
+	Node     string `json:"node,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifySecretAudits walks every node's chain in id order and reports the
+// first row whose hash or link to its predecessor does not match.
+func VerifySecretAudits() (*SecretAuditVerification, error) {
+	key := getSecretAuditKey()
+	res := &SecretAuditVerification{Valid: true}
+	prevHashes := map[string]string{}
+	const batchSize = 1000
+	lastId := 0
+	for {
+		audits := []*SecretAudit{}
+		err := adapter.db.Select().From("secret_audit").
+			Where(dbx.NewExp("id > {:id}", dbx.Params{"id": lastId})).
+			OrderBy("id ASC").Limit(batchSize).All(&audits)
+		if err != nil {
+			return nil, err
+		}
+		for _, audit := range audits {
+			res.Checked++
+			reason := ""
+			if audit.PrevHash != prevHashes[audit.Node] {
+				reason = "link to previous row does not match"
+			} else if !hmac.Equal([]byte(audit.Hash), []byte(audit.computeHash(key))) {
+				reason = "row contents do not match hash"
+			}
+			if reason != "" {
+				res.Valid = false
+				res.BrokenId = audit.Id
+				res.Node = audit.Node
+				res.Reason = reason
+				return res, nil
+			}
+			prevHashes[audit.Node] = audit.Hash
+			lastId = audit.Id
+		}
+		if len(audits) < batchSize {
+			return res, nil
+		}
+	}
+}
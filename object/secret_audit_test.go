@@ -0,0 +1,57 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestSecretAuditHash(t *testing.T) {
+	audit := &SecretAudit{
+		CreatedTime: "2026-01-01T00:00:00Z",
+		Node:        "cloud-0",
+		Owner:       "admin",
+		Provider:    "openai",
+		Field:       "clientSecret",
+		Secret:      "kms://OPENAI_API_KEY",
+		Caller:      "system",
+		Result:      "success",
+	}
+	key := []byte("audit-key")
+	hash := audit.computeHash(key)
+	if hash != audit.computeHash(key) {
+		t.Fatal("computeHash should be deterministic")
+	}
+
+	tampered := *audit
+	tampered.Caller = "admin/alice"
+	if tampered.computeHash(key) == hash {
+		t.Error("changing a field should change the hash")
+	}
+
+	shifted := *audit
+	shifted.Provider, shifted.Field = "openaic", "lientSecret"
+	if shifted.computeHash(key) == hash {
+		t.Error("moving characters across fields should change the hash")
+	}
+
+	next := *audit
+	next.PrevHash = hash
+	if next.computeHash(key) == hash {
+		t.Error("the hash should cover the previous row's hash")
+	}
+
+	if audit.computeHash([]byte("other-key")) == hash {
+		t.Error("the hash should depend on the audit key")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsSecretResolver reads secrets from AWS Secrets Manager.
+//
+// Reference format: awssm://SECRET_ID[#KEY]
+//   - SECRET_ID is a secret name or full ARN
+//   - KEY selects a field when the secret string is a JSON object
+//
+// Credentials and region come from the default AWS chain (AWS_REGION,
+// AWS_ACCESS_KEY_ID, shared config, IRSA, instance roles). For ARNs the
+// region embedded in the ARN is used.
+type awsSecretResolver struct {
+	httpClient *http.Client
+	cfgOnce    sync.Once
+	cfg        aws.Config
+	cfgErr     error
+}
+
+func newAwsSecretResolver() *awsSecretResolver {
+	return &awsSecretResolver{httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (r *awsSecretResolver) getConfig(ctx context.Context) (aws.Config, error) {
+	r.cfgOnce.Do(func() {
+		r.cfg, r.cfgErr = awsconfig.LoadDefaultConfig(ctx)
+	})
+	return r.cfg, r.cfgErr
+}
+
+// awsRegionFromARN returns the region of an "arn:aws:secretsmanager:REGION:..." ID.
+func awsRegionFromARN(secretID string) string {
+	parts := strings.Split(secretID, ":")
+	if len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	return ""
+}
+
+func (r *awsSecretResolver) Resolve(ref string) (string, error) {
+	secretID, key, _ := strings.Cut(ref, "#")
+	if secretID == "" {
+		return "", fmt.Errorf("awssm: invalid reference %q (expected awssm://SECRET_ID[#KEY])", ref)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg, err := r.getConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("awssm: failed to load AWS config: %w", err)
+	}
+	region := awsRegionFromARN(secretID)
+	if region == "" {
+		region = cfg.Region
+	}
+	if region == "" {
+		return "", fmt.Errorf("awssm: no region for %q (set AWS_REGION or use a full ARN)", secretID)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("awssm: failed to retrieve AWS credentials: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", region)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("awssm: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	hash := sha256.Sum256(payload)
+	if err = v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "secretsmanager", region, time.Now()); err != nil {
+		return "", fmt.Errorf("awssm: failed to sign request: %w", err)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("awssm: request failed for %q: %w", secretID, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("awssm: failed to read response for %q: %w", secretID, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("awssm: %q returned status %d: %s", secretID, resp.StatusCode, string(body))
+	}
+
+	var smResp struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err = json.Unmarshal(body, &smResp); err != nil {
+		return "", fmt.Errorf("awssm: failed to parse response for %q: %w", secretID, err)
+	}
+	if smResp.SecretString == nil {
+		return "", fmt.Errorf("awssm: %q has no SecretString (binary secrets are not supported)", secretID)
+	}
+	if key == "" {
+		return *smResp.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal([]byte(*smResp.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("awssm: %q is not a JSON object, cannot select key %q", secretID, key)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("awssm: key %q not found in %q", key, secretID)
+	}
+	return value, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretResolver resolves secret references of one URI scheme. ref is the
+// part after "scheme://", e.g. "OPENAI_API_KEY@v3" for "kms://OPENAI_API_KEY@v3".
+//
+// Built-in schemes:
+//   - kms://NAME[@vN]                      Hanzo KMS (see kms.go)
+//   - env://NAME                           process environment
+//   - vault://MOUNT/PATH[#KEY][@vN]        HashiCorp Vault KV v2
+//   - awssm://SECRET_ID[#KEY]              AWS Secrets Manager
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"env":   envSecretResolver{},
+		"vault": newCachedSecretResolver(newVaultSecretResolver()),
+		"awssm": newCachedSecretResolver(newAwsSecretResolver()),
+	}
+)
+
+// RegisterSecretResolver installs a resolver for scheme, replacing any
+// existing one. The "kms" scheme is handled per provider and cannot be
+// overridden here.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	secretResolvers[scheme] = resolver
+	secretResolversMu.Unlock()
+}
+
+// splitSecretURI splits "scheme://ref". ok is false for plain values.
+func splitSecretURI(value string) (scheme string, ref string, ok bool) {
+	scheme, ref, ok = strings.Cut(value, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, "/:") {
+		return "", "", false
+	}
+	return scheme, ref, true
+}
+
+// getSecretResolver returns the resolver for a secret URI, or nil when value
+// is not a reference to a known backend. kmsProjectID scopes kms:// lookups.
+func getSecretResolver(value string, kmsProjectID string) (SecretResolver, string) {
+	scheme, ref, ok := splitSecretURI(value)
+	if !ok {
+		return nil, ""
+	}
+	if scheme == "kms" {
+		return &kmsSecretResolver{projectID: kmsProjectID}, ref
+	}
+
+	secretResolversMu.RLock()
+	resolver := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	return resolver, ref
+}
+
+// ResolveSecretRef resolves value when it is a secret URI of a known scheme;
+// other values are returned unchanged.
+func ResolveSecretRef(value string) (string, error) {
+	resolver, ref := getSecretResolver(value, "")
+	if resolver == nil {
+		return value, nil
+	}
+	if ref == "" {
+		return "", fmt.Errorf("secret: empty reference %q", value)
+	}
+	return resolver.Resolve(ref)
+}
+
+// parseVersionedSecretRef splits a reference of the form "NAME" or "NAME@v3"
+// into its name and pinned version. A version of 0 means the latest version.
+func parseVersionedSecretRef(ref string) (string, int, error) {
+	name, versionText, pinned := strings.Cut(ref, "@")
+	if name == "" {
+		return "", 0, fmt.Errorf("secret: empty secret name in reference %q", ref)
+	}
+	if !pinned {
+		return name, 0, nil
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(versionText, "v"))
+	if err != nil || version <= 0 || !strings.HasPrefix(versionText, "v") {
+		return "", 0, fmt.Errorf("secret: invalid version in reference %q (expected NAME@v<N>)", ref)
+	}
+	return name, version, nil
+}
+
+// ── env:// ──────────────────────────────────────────────────────────────────
+
+type envSecretResolver struct{}
+
+func (envSecretResolver) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("secret: environment variable %q is not set", ref)
+	}
+	return value, nil
+}
+
+// ── Caching wrapper ─────────────────────────────────────────────────────────
+
+// secretCacheTTL matches the KMS in-memory cache TTL.
+const secretCacheTTL = 5 * time.Minute
+
+type secretCacheEntry struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// cachedSecretResolver memoizes a remote resolver for secretCacheTTL.
+type cachedSecretResolver struct {
+	resolver SecretResolver
+	mu       sync.RWMutex
+	entries  map[string]*secretCacheEntry
+}
+
+func newCachedSecretResolver(resolver SecretResolver) *cachedSecretResolver {
+	return &cachedSecretResolver{resolver: resolver, entries: make(map[string]*secretCacheEntry)}
+}
+
+func (r *cachedSecretResolver) Resolve(ref string) (string, error) {
+	r.mu.RLock()
+	entry, ok := r.entries[ref]
+	r.mu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < secretCacheTTL {
+		return entry.value, nil
+	}
+
+	value, err := r.resolver.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.entries[ref] = &secretCacheEntry{value: value, fetchedAt: time.Now()}
+	r.mu.Unlock()
+	return value, nil
+}
+
+// invalidate drops ref from the cache, or everything when ref is empty.
+func (r *cachedSecretResolver) invalidate(ref string) {
+	r.mu.Lock()
+	if ref == "" {
+		r.entries = make(map[string]*secretCacheEntry)
+	} else {
+		delete(r.entries, ref)
+	}
+	r.mu.Unlock()
+}
+
+// invalidateSecretRef drops any cached value for a non-KMS secret URI.
+func invalidateSecretRef(value string) {
+	resolver, ref := getSecretResolver(value, "")
+	if cached, ok := resolver.(*cachedSecretResolver); ok {
+		cached.invalidate(ref)
+	}
+}
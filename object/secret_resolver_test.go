@@ -0,0 +1,78 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestResolveSecretRef(t *testing.T) {
+	t.Setenv("SECRET_RESOLVER_TEST", "s3cret")
+
+	cases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain-value", "plain-value", false},
+		{"https://example.com/key", "https://example.com/key", false},
+		{"env://SECRET_RESOLVER_TEST", "s3cret", false},
+		{"env://SECRET_RESOLVER_MISSING", "", true},
+		{"env://", "", true},
+	}
+	for _, tc := range cases {
+		got, err := ResolveSecretRef(tc.value)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ResolveSecretRef(%q) = %q, %v; want %q, err=%v", tc.value, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestParseVaultSecretRef(t *testing.T) {
+	mount, path, key, version, err := parseVaultSecretRef("secret/llm/openai#api_key@v2")
+	if err != nil || mount != "secret" || path != "llm/openai" || key != "api_key" || version != 2 {
+		t.Errorf("parseVaultSecretRef = %q, %q, %q, %d, %v", mount, path, key, version, err)
+	}
+
+	_, _, key, _, err = parseVaultSecretRef("secret/openai")
+	if err != nil || key != "value" {
+		t.Errorf("parseVaultSecretRef default key = %q, %v; want \"value\"", key, err)
+	}
+
+	if _, _, _, _, err = parseVaultSecretRef("secret"); err == nil {
+		t.Error("parseVaultSecretRef(\"secret\") should fail without a path")
+	}
+}
+
+func TestSplitKMSProjectRef(t *testing.T) {
+	cases := []struct {
+		ref       string
+		projectID string
+		secretRef string
+		wantErr   bool
+	}{
+		{"OPENAI_API_KEY", "", "OPENAI_API_KEY", false},
+		{"proj-123/OPENAI_API_KEY", "proj-123", "OPENAI_API_KEY", false},
+		{"proj-123/OPENAI_API_KEY@v2", "proj-123", "OPENAI_API_KEY@v2", false},
+		{"/OPENAI_API_KEY", "", "", true},
+		{"proj-123/", "", "", true},
+		{"a/b/c", "", "", true},
+	}
+	for _, tc := range cases {
+		projectID, secretRef, err := splitKMSProjectRef(tc.ref)
+		if (err != nil) != tc.wantErr || projectID != tc.projectID || secretRef != tc.secretRef {
+			t.Errorf("splitKMSProjectRef(%q) = %q, %q, %v; want %q, %q, err=%v",
+				tc.ref, projectID, secretRef, err, tc.projectID, tc.secretRef, tc.wantErr)
+		}
+	}
+}
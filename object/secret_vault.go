@@ -0,0 +1,121 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultSecretResolver reads secrets from a HashiCorp Vault KV v2 engine.
+//
+// Reference format: vault://MOUNT/PATH[#KEY][@vN]
+//   - MOUNT is the KV v2 mount (e.g. "secret")
+//   - KEY selects a field of the secret's data (default: "value")
+//   - @vN pins a version, otherwise the latest version is read
+//
+// Environment variables:
+//   - VAULT_ADDR:       Base URL (e.g. https://vault.example.com:8200)
+//   - VAULT_TOKEN:      Token sent as X-Vault-Token
+//   - VAULT_NAMESPACE:  Optional Vault Enterprise namespace
+type vaultSecretResolver struct {
+	httpClient *http.Client
+}
+
+func newVaultSecretResolver() *vaultSecretResolver {
+	return &vaultSecretResolver{httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type vaultKVResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// parseVaultSecretRef splits "MOUNT/PATH#KEY@vN" into its parts.
+func parseVaultSecretRef(ref string) (mount string, path string, key string, version int, err error) {
+	name, version, err := parseVersionedSecretRef(ref)
+	if err != nil {
+		return "", "", "", 0, err
+	}
+	name, key, _ = strings.Cut(name, "#")
+	if key == "" {
+		key = "value"
+	}
+	mount, path, _ = strings.Cut(strings.Trim(name, "/"), "/")
+	if mount == "" || path == "" {
+		return "", "", "", 0, fmt.Errorf("vault: invalid reference %q (expected vault://MOUNT/PATH[#KEY])", ref)
+	}
+	return mount, path, key, version, nil
+}
+
+func (r *vaultSecretResolver) Resolve(ref string) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("vault: not configured (set VAULT_ADDR and VAULT_TOKEN)")
+	}
+	mount, path, key, version, err := parseVaultSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", addr, mount, path)
+	if version > 0 {
+		url += fmt.Sprintf("?version=%d", version)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("vault: failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: request failed for %s/%s: %w", mount, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("vault: failed to read response for %s/%s: %w", mount, path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s/%s returned status %d: %s", mount, path, resp.StatusCode, string(body))
+	}
+
+	var vaultResp vaultKVResponse
+	if err = json.Unmarshal(body, &vaultResp); err != nil {
+		return "", fmt.Errorf("vault: failed to parse response for %s/%s: %w", mount, path, err)
+	}
+	value, ok := vaultResp.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("vault: key %q not found in %s/%s", key, mount, path)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault: key %q in %s/%s is not a string", key, mount, path)
+	}
+	return s, nil
+}
@@ -0,0 +1,349 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/storage"
+	"github.com/hanzoai/dbx"
+	"github.com/robfig/cron/v3"
+)
+
+// RetentionReasonExpired is the reason label of the retention metrics for
+// files deleted past their rule's age.
+const RetentionReasonExpired = "expired"
+
+// defaultStorageRetentionSweepMinutes is how often the sweeper runs when
+// storageRetentionSweepMinutes is unset.
+const defaultStorageRetentionSweepMinutes = 60
+
+// StorageRetention is a retention rule for the files of an organization. A
+// rule with a store applies to that store; the rule without one applies to
+// the org's other stores and also caps the total size of all its files:
+// uploads past the cap are rejected, never made room for by deleting older
+// files. Zero limits are unlimited. Hold is the admin override: while it is
+// set, nothing in the rule's scope is deleted, and an org-wide rule's cap
+// is lifted.
+type StorageRetention struct {
+	Owner         string `db:"pk" json:"owner"` // org ID
+	Store         string `db:"pk" json:"store"` // store name, empty for the org-wide rule
+	CreatedTime   string `json:"createdTime"`
+	UpdatedTime   string `json:"updatedTime"`
+	ExpireDays    int    `json:"expireDays"`
+	MaxTotalBytes int64  `json:"maxTotalBytes"` // org-wide rule only
+	Hold          bool   `json:"hold"`
+}
+
+func (r *StorageRetention) GetId() string {
+	return fmt.Sprintf("%s/%s", r.Owner, r.Store)
+}
+
+func GetStorageRetentions(owner string) ([]*StorageRetention, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	rules := []*StorageRetention{}
+	var cond dbx.Expression
+	if owner != "" {
+		cond = dbx.HashExp{"owner": owner}
+	}
+	err := findAll(adapter.db, "storage_retention", &rules, cond, "owner", "store")
+	if err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+func GetStorageRetention(owner string, store string) (*StorageRetention, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	rule := StorageRetention{Owner: owner, Store: store}
+	existed, err := getOne(adapter.db, "storage_retention", &rule, dbx.HashExp{"owner": owner, "store": store})
+	if err != nil {
+		return &rule, err
+	}
+	if existed {
+		return &rule, nil
+	}
+	return nil, nil
+}
+
+func validateStorageRetention(rule *StorageRetention) error {
+	if rule.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if rule.ExpireDays < 0 || rule.MaxTotalBytes < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+	if rule.Store != "" && rule.MaxTotalBytes != 0 {
+		return fmt.Errorf("maxTotalBytes can only be set on the org-wide rule")
+	}
+	return nil
+}
+
+func AddStorageRetention(rule *StorageRetention) (bool, error) {
+	if err := validateStorageRetention(rule); err != nil {
+		return false, err
+	}
+	rule.CreatedTime = time.Now().Format(time.RFC3339)
+	rule.UpdatedTime = rule.CreatedTime
+	err := insertRow(adapter.db, rule)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func UpdateStorageRetention(owner string, store string, rule *StorageRetention) (bool, error) {
+	rule.Owner = owner
+	rule.Store = store
+	if err := validateStorageRetention(rule); err != nil {
+		return false, err
+	}
+	rule.UpdatedTime = time.Now().Format(time.RFC3339)
+	err := adapter.db.Model(rule).Update()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func DeleteStorageRetention(rule *StorageRetention) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "storage_retention", dbx.HashExp{"owner": rule.Owner, "store": rule.Store})
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+// StorageQuotaExceededError is returned for an upload that would take an
+// organization's files past the size cap of its retention rule.
+type StorageQuotaExceededError struct {
+	Limit int64
+}
+
+func (e *StorageQuotaExceededError) Error() string {
+	return fmt.Sprintf("the organization's files would exceed their limit of %d bytes", e.Limit)
+}
+
+// checkStorageQuota returns a StorageQuotaExceededError when adding size
+// bytes to the files of owner takes them past the cap of its org-wide rule.
+func checkStorageQuota(owner string, size int64) error {
+	rule, err := GetStorageRetention(owner, "")
+	if err != nil || rule == nil || rule.Hold || rule.MaxTotalBytes <= 0 {
+		return err
+	}
+	var total int64
+	err = adapter.db.Select("COALESCE(SUM(size), 0)").From("file").Where(dbx.HashExp{"owner": owner}).Row(&total)
+	if err != nil {
+		return err
+	}
+	if total+size > rule.MaxTotalBytes {
+		return &StorageQuotaExceededError{Limit: rule.MaxTotalBytes}
+	}
+	return nil
+}
+
+// retentionDeletion is a file the sweep deletes, and why.
+type retentionDeletion struct {
+	file   *File
+	reason string
+}
+
+// planRetentionSweep returns the files that rules expire as of now. Files
+// under a held rule are kept.
+func planRetentionSweep(rules []*StorageRetention, files []*File, now time.Time) []retentionDeletion {
+	ruleOf := map[string]*StorageRetention{}
+	for _, rule := range rules {
+		ruleOf[rule.Owner+"/"+rule.Store] = rule
+	}
+	fileRule := func(file *File) *StorageRetention {
+		if rule, ok := ruleOf[file.Owner+"/"+file.Store]; ok {
+			return rule
+		}
+		return ruleOf[file.Owner+"/"]
+	}
+
+	deletions := []retentionDeletion{}
+	for _, file := range files {
+		rule := fileRule(file)
+		if rule == nil || rule.Hold || rule.ExpireDays <= 0 {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, file.CreatedTime)
+		if err == nil && now.Sub(created) > time.Duration(rule.ExpireDays)*24*time.Hour {
+			deletions = append(deletions, retentionDeletion{file: file, reason: RetentionReasonExpired})
+		}
+	}
+	return deletions
+}
+
+// RetentionSweepReport is the outcome of a retention sweep.
+type RetentionSweepReport struct {
+	DryRun       bool     `json:"dryRun"`
+	DeletedFiles int      `json:"deletedFiles"`
+	DeletedBytes int64    `json:"deletedBytes"`
+	Errors       int      `json:"errors"`
+	Files        []string `json:"files"`
+}
+
+var storageRetentionSweepMu sync.Mutex
+
+// SweepStorageRetention enforces the retention rules of owner, or of every
+// org when owner is empty. A dry run reports what would be deleted.
+func SweepStorageRetention(owner string, dryRun bool) (*RetentionSweepReport, error) {
+	storageRetentionSweepMu.Lock()
+	defer storageRetentionSweepMu.Unlock()
+
+	rules, err := GetStorageRetentions(owner)
+	if err != nil {
+		return nil, err
+	}
+	report := &RetentionSweepReport{DryRun: dryRun, Files: []string{}}
+	if len(rules) == 0 {
+		return report, nil
+	}
+
+	var files []*File
+	if owner == "" {
+		files, err = GetGlobalFiles()
+	} else {
+		files, err = GetFiles(owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	providers := map[string]storage.StorageProvider{}
+	for _, deletion := range planRetentionSweep(rules, files, time.Now()) {
+		file := deletion.file
+		report.Files = append(report.Files, fmt.Sprintf("%s/%s", file.Owner, file.Name))
+		if dryRun {
+			report.DeletedFiles++
+			report.DeletedBytes += file.Size
+			continue
+		}
+
+		err = deleteRetainedFile(file, providers)
+		if err != nil {
+			logs.Error("Storage retention: failed to delete %s/%s: %v", file.Owner, file.Name, err)
+			StorageRetentionErrors.Inc()
+			report.Errors++
+			continue
+		}
+		logs.Info("Storage retention: deleted %s/%s (%d bytes, %s)", file.Owner, file.Name, file.Size, deletion.reason)
+		StorageRetentionDeletedFiles.WithLabelValues(deletion.reason).Inc()
+		StorageRetentionDeletedBytes.WithLabelValues(deletion.reason).Add(float64(file.Size))
+		report.DeletedFiles++
+		report.DeletedBytes += file.Size
+	}
+	if !dryRun {
+		StorageRetentionLastSweep.SetToCurrentTime()
+	}
+	return report, nil
+}
+
+// deleteRetainedFile deletes a file from its store's storage, its vectors and
+// its record. providers caches the storage provider of each store.
+func deleteRetainedFile(file *File, providers map[string]storage.StorageProvider) error {
+	storeId := fmt.Sprintf("%s/%s", file.Owner, file.Store)
+	storageProviderObj, ok := providers[storeId]
+	if !ok {
+		store, err := getStore(file.Owner, file.Store)
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return fmt.Errorf("the store: %s is not found", storeId)
+		}
+		storageProviderObj, err = store.GetStorageProviderObj("en")
+		if err != nil {
+			return err
+		}
+		providers[storeId] = storageProviderObj
+	}
+
+	objectKey := strings.TrimPrefix(file.Name, file.Store+"_")
+	err := storageProviderObj.DeleteObject(objectKey)
+	if err != nil {
+		return err
+	}
+	_, err = DeleteVectorsByFile(file.Owner, file.Store, objectKey)
+	if err != nil {
+		return err
+	}
+	return deleteFileRecord(file.Owner, file.Store, objectKey)
+}
+
+// storageRetentionSweepLock is the shared cache whose counters elect the
+// replica that runs each scheduled sweep.
+const storageRetentionSweepLock = "storage-retention-sweep"
+
+// claimStorageRetentionSweep reports whether this replica runs the sweep of
+// the current interval: the first to count it in the shared cache does.
+// Without a shared cache the process runs alone; when the cache is down no
+// replica sweeps until it is back.
+func claimStorageRetentionSweep(interval time.Duration) bool {
+	if !cache.Distributed() {
+		return true
+	}
+	slot := time.Now().Unix() / int64(interval/time.Second)
+	count, err := cache.AddShared(storageRetentionSweepLock, strconv.FormatInt(slot, 10), 1, interval)
+	if err != nil {
+		logs.Warn("Storage retention: failed to claim the sweep: %v", err)
+		return false
+	}
+	return count == 1
+}
+
+func sweepStorageRetentionNoError(interval time.Duration) {
+	if !claimStorageRetentionSweep(interval) {
+		return
+	}
+	_, err := SweepStorageRetention("", false)
+	if err != nil {
+		logs.Error("sweepStorageRetentionNoError() error: %s", err.Error())
+	}
+}
+
+// InitStorageRetentionSweeper schedules the retention sweep every
+// storageRetentionSweepMinutes (default 60, negative to disable). With
+// replicas sharing a cache, one of them runs each sweep.
+func InitStorageRetentionSweeper() {
+	minutes := conf.GetConfigInt("storageRetentionSweepMinutes")
+	if minutes < 0 {
+		return
+	}
+	if minutes == 0 {
+		minutes = defaultStorageRetentionSweepMinutes
+	}
+	interval := time.Duration(minutes) * time.Minute
+	cronJob := cron.New()
+	schedule := fmt.Sprintf("@every %dm", minutes)
+	_, err := cronJob.AddFunc(schedule, func() { sweepStorageRetentionNoError(interval) })
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
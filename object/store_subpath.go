@@ -15,7 +15,10 @@ package object
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/hanzoai/cloud/storage"
 )
@@ -63,6 +66,98 @@ func (w *SubpathStorageProvider) DeleteObject(key string) error {
 	return w.provider.DeleteObject(fullKey)
 }
 
+// PresignGetObject passes presigning through to providers that support it.
+func (w *SubpathStorageProvider) PresignGetObject(key string, expires time.Duration) (string, error) {
+	presigner, ok := w.provider.(storage.PresignedStorageProvider)
+	if !ok {
+		return "", fmt.Errorf("the storage provider does not support presigned URLs")
+	}
+	return presigner.PresignGetObject(w.buildFullPath(key), expires)
+}
+
+func (w *SubpathStorageProvider) PresignPutObject(key string, size int64, expires time.Duration) (string, error) {
+	presigner, ok := w.provider.(storage.PresignedStorageProvider)
+	if !ok {
+		return "", fmt.Errorf("the storage provider does not support presigned URLs")
+	}
+	return presigner.PresignPutObject(w.buildFullPath(key), size, expires)
+}
+
+func (w *SubpathStorageProvider) StatObject(key string) (*storage.Object, error) {
+	presigner, ok := w.provider.(storage.PresignedStorageProvider)
+	if !ok {
+		return nil, fmt.Errorf("the storage provider does not support presigned URLs")
+	}
+	stored, err := presigner.StatObject(w.buildFullPath(key))
+	if err != nil {
+		return nil, err
+	}
+	stored.Key = key
+	return stored, nil
+}
+
+// PutObjectStream streams to providers that support it and buffers the
+// object for the others.
+func (w *SubpathStorageProvider) PutObjectStream(user string, parent string, key string, reader io.Reader, size int64) (string, error) {
+	fullKey := w.buildFullPath(key)
+	if streaming, ok := w.provider.(storage.StreamingStorageProvider); ok {
+		return streaming.PutObjectStream(user, parent, fullKey, reader, size)
+	}
+	fileBuffer := bytes.NewBuffer(nil)
+	if _, err := io.Copy(fileBuffer, reader); err != nil {
+		return "", err
+	}
+	return w.provider.PutObject(user, parent, fullKey, fileBuffer)
+}
+
+func (w *SubpathStorageProvider) multipart() (storage.MultipartStorageProvider, error) {
+	multipart, ok := w.provider.(storage.MultipartStorageProvider)
+	if !ok {
+		return nil, fmt.Errorf("the storage provider does not support multipart uploads")
+	}
+	return multipart, nil
+}
+
+func (w *SubpathStorageProvider) CreateMultipartUpload(key string) (string, error) {
+	multipart, err := w.multipart()
+	if err != nil {
+		return "", err
+	}
+	return multipart.CreateMultipartUpload(w.buildFullPath(key))
+}
+
+func (w *SubpathStorageProvider) UploadPart(key string, uploadId string, partNumber int32, reader io.Reader, size int64) (string, error) {
+	multipart, err := w.multipart()
+	if err != nil {
+		return "", err
+	}
+	return multipart.UploadPart(w.buildFullPath(key), uploadId, partNumber, reader, size)
+}
+
+func (w *SubpathStorageProvider) ListParts(key string, uploadId string) ([]storage.Part, error) {
+	multipart, err := w.multipart()
+	if err != nil {
+		return nil, err
+	}
+	return multipart.ListParts(w.buildFullPath(key), uploadId)
+}
+
+func (w *SubpathStorageProvider) CompleteMultipartUpload(key string, uploadId string, parts []storage.Part) (string, error) {
+	multipart, err := w.multipart()
+	if err != nil {
+		return "", err
+	}
+	return multipart.CompleteMultipartUpload(w.buildFullPath(key), uploadId, parts)
+}
+
+func (w *SubpathStorageProvider) AbortMultipartUpload(key string, uploadId string) error {
+	multipart, err := w.multipart()
+	if err != nil {
+		return err
+	}
+	return multipart.AbortMultipartUpload(w.buildFullPath(key), uploadId)
+}
+
 // Constructs the full path by combining subpath and path
 func (w *SubpathStorageProvider) buildFullPath(path string) string {
 	if w.subpath == "" {
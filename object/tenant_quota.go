@@ -0,0 +1,181 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/dbx"
+)
+
+// Tenant quota periods. Each is a sliding window ending now.
+const (
+	QuotaPeriodDaily   = "daily"
+	QuotaPeriodMonthly = "monthly"
+)
+
+// TenantQuota caps an organization's gateway usage over a sliding window.
+// Zero limits are unlimited.
+type TenantQuota struct {
+	Owner         string `db:"pk" json:"owner"`  // org ID
+	Period        string `db:"pk" json:"period"` // "daily" (24h) or "monthly" (30d)
+	CreatedTime   string `json:"createdTime"`
+	UpdatedTime   string `json:"updatedTime"`
+	MaxRequests   int64  `json:"maxRequests"`
+	MaxTokens     int64  `json:"maxTokens"`     // prompt + completion tokens
+	MaxSpendCents int64  `json:"maxSpendCents"` // billed cost, from the model pricing table
+	Enabled       bool   `json:"enabled"`
+}
+
+func (q *TenantQuota) GetId() string {
+	return fmt.Sprintf("%s/%s", q.Owner, q.Period)
+}
+
+// Window returns the length of the quota's sliding window.
+func (q *TenantQuota) Window() time.Duration {
+	if q.Period == QuotaPeriodMonthly {
+		return 30 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func GetTenantQuotas(owner string) ([]*TenantQuota, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	quotas := []*TenantQuota{}
+	err := findAll(adapter.db, "tenant_quota", &quotas, dbx.HashExp{"owner": owner}, "period")
+	if err != nil {
+		return quotas, err
+	}
+	return quotas, nil
+}
+
+func GetTenantQuota(owner string, period string) (*TenantQuota, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	quota := TenantQuota{Owner: owner, Period: period}
+	existed, err := getOne(adapter.db, "tenant_quota", &quota, dbx.HashExp{"owner": owner, "period": period})
+	if err != nil {
+		return &quota, err
+	}
+	if existed {
+		return &quota, nil
+	}
+	return nil, nil
+}
+
+func validateTenantQuota(quota *TenantQuota) error {
+	if quota.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if quota.Period != QuotaPeriodDaily && quota.Period != QuotaPeriodMonthly {
+		return fmt.Errorf("period must be %q or %q", QuotaPeriodDaily, QuotaPeriodMonthly)
+	}
+	if quota.MaxRequests < 0 || quota.MaxTokens < 0 || quota.MaxSpendCents < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+	return nil
+}
+
+func AddTenantQuota(quota *TenantQuota) (bool, error) {
+	if err := validateTenantQuota(quota); err != nil {
+		return false, err
+	}
+	quota.CreatedTime = time.Now().Format(time.RFC3339)
+	quota.UpdatedTime = quota.CreatedTime
+	err := insertRow(adapter.db, quota)
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantQuotaCache()
+	return true, nil
+}
+
+func UpdateTenantQuota(owner string, period string, quota *TenantQuota) (bool, error) {
+	quota.Owner = owner
+	quota.Period = period
+	if err := validateTenantQuota(quota); err != nil {
+		return false, err
+	}
+	quota.UpdatedTime = time.Now().Format(time.RFC3339)
+	err := adapter.db.Model(quota).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantQuotaCache()
+	return true, nil
+}
+
+func DeleteTenantQuota(quota *TenantQuota) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "tenant_quota", dbx.HashExp{"owner": quota.Owner, "period": quota.Period})
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantQuotaCache()
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type tenantQuotaCacheEntry struct {
+	quotas    []*TenantQuota
+	fetchedAt time.Time
+}
+
+var (
+	tenantQuotaCache    = make(map[string]*tenantQuotaCacheEntry)
+	tenantQuotaCacheMu  sync.RWMutex
+	tenantQuotaCacheTTL = 60 * time.Second
+)
+
+func invalidateTenantQuotaCache() {
+	tenantQuotaCacheMu.Lock()
+	tenantQuotaCache = make(map[string]*tenantQuotaCacheEntry)
+	tenantQuotaCacheMu.Unlock()
+}
+
+// GetActiveTenantQuotas returns an organization's enabled quotas with 60s
+// TTL caching. Rows that fail validation, e.g. written before it applied,
+// are skipped.
+func GetActiveTenantQuotas(owner string) ([]*TenantQuota, error) {
+	tenantQuotaCacheMu.RLock()
+	entry, ok := tenantQuotaCache[owner]
+	tenantQuotaCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < tenantQuotaCacheTTL {
+		return entry.quotas, nil
+	}
+	quotas, err := GetTenantQuotas(owner)
+	if err != nil {
+		return nil, err
+	}
+	active := []*TenantQuota{}
+	for _, quota := range quotas {
+		if !quota.Enabled {
+			continue
+		}
+		if err = validateTenantQuota(quota); err != nil {
+			logs.Warn("tenant quota: ignoring %s: %v", quota.GetId(), err)
+			continue
+		}
+		active = append(active, quota)
+	}
+	tenantQuotaCacheMu.Lock()
+	tenantQuotaCache[owner] = &tenantQuotaCacheEntry{quotas: active, fetchedAt: time.Now()}
+	tenantQuotaCacheMu.Unlock()
+	return active, nil
+}
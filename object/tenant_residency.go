@@ -0,0 +1,198 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package object
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// Data residency regions.
+const (
+	ResidencyEU = "eu"
+	ResidencyUS = "us"
+)
+
+// TenantResidency pins an organization's model traffic to the upstream
+// providers of one region.
+type TenantResidency struct {
+	Owner       string `db:"pk" json:"owner"` // org ID
+	CreatedTime string `json:"createdTime"`
+	UpdatedTime string `json:"updatedTime"`
+	Region      string `json:"region"` // "eu" or "us"
+}
+
+// azureResidencies maps Azure region names, which carry no geography
+// prefix, to their residency region.
+var azureResidencies = map[string]string{
+	"westeurope":         ResidencyEU,
+	"northeurope":        ResidencyEU,
+	"francecentral":      ResidencyEU,
+	"francesouth":        ResidencyEU,
+	"germanywestcentral": ResidencyEU,
+	"germanynorth":       ResidencyEU,
+	"italynorth":         ResidencyEU,
+	"polandcentral":      ResidencyEU,
+	"spaincentral":       ResidencyEU,
+	"swedencentral":      ResidencyEU,
+	"swedensouth":        ResidencyEU,
+	"eastus":             ResidencyUS,
+	"eastus2":            ResidencyUS,
+	"centralus":          ResidencyUS,
+	"northcentralus":     ResidencyUS,
+	"southcentralus":     ResidencyUS,
+	"westcentralus":      ResidencyUS,
+	"westus":             ResidencyUS,
+	"westus2":            ResidencyUS,
+	"westus3":            ResidencyUS,
+}
+
+// GetProviderResidency returns the residency region a provider serves from,
+// derived from its Region: "eu" or "us" themselves, an AWS or GCP region
+// such as "eu-west-1", "europe-west4" or "us-east-1", or an Azure region
+// such as "westeurope" or "eastus2". It returns "" for providers without a
+// recognized region, which no residency region admits.
+func GetProviderResidency(provider *Provider) string {
+	region := strings.ToLower(strings.TrimSpace(provider.Region))
+	switch {
+	case region == ResidencyEU || strings.HasPrefix(region, "eu-") || strings.HasPrefix(region, "europe-"):
+		return ResidencyEU
+	case region == ResidencyUS || strings.HasPrefix(region, "us-"):
+		return ResidencyUS
+	default:
+		return azureResidencies[region]
+	}
+}
+
+func GetTenantResidencies() ([]*TenantResidency, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	residencies := []*TenantResidency{}
+	err := findAll(adapter.db, "tenant_residency", &residencies, nil, "owner")
+	if err != nil {
+		return residencies, err
+	}
+	return residencies, nil
+}
+
+func GetTenantResidency(owner string) (*TenantResidency, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	residency := TenantResidency{Owner: owner}
+	existed, err := getOne(adapter.db, "tenant_residency", &residency, dbx.HashExp{"owner": owner})
+	if err != nil {
+		return &residency, err
+	}
+	if existed {
+		return &residency, nil
+	}
+	return nil, nil
+}
+
+func validateTenantResidency(residency *TenantResidency) error {
+	if residency.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if residency.Region != ResidencyEU && residency.Region != ResidencyUS {
+		return fmt.Errorf("region must be %q or %q", ResidencyEU, ResidencyUS)
+	}
+	return nil
+}
+
+func AddTenantResidency(residency *TenantResidency) (bool, error) {
+	if err := validateTenantResidency(residency); err != nil {
+		return false, err
+	}
+	residency.CreatedTime = time.Now().Format(time.RFC3339)
+	residency.UpdatedTime = residency.CreatedTime
+	err := insertRow(adapter.db, residency)
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantResidencyCache()
+	return true, nil
+}
+
+func UpdateTenantResidency(owner string, residency *TenantResidency) (bool, error) {
+	residency.Owner = owner
+	if err := validateTenantResidency(residency); err != nil {
+		return false, err
+	}
+	residency.UpdatedTime = time.Now().Format(time.RFC3339)
+	err := adapter.db.Model(residency).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantResidencyCache()
+	return true, nil
+}
+
+func DeleteTenantResidency(residency *TenantResidency) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "tenant_residency", dbx.HashExp{"owner": residency.Owner})
+	if err != nil {
+		return false, err
+	}
+	invalidateTenantResidencyCache()
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type tenantResidencyCacheEntry struct {
+	region    string
+	fetchedAt time.Time
+}
+
+var (
+	tenantResidencyCache    = make(map[string]*tenantResidencyCacheEntry)
+	tenantResidencyCacheMu  sync.RWMutex
+	tenantResidencyCacheTTL = 60 * time.Second
+)
+
+func invalidateTenantResidencyCache() {
+	tenantResidencyCacheMu.Lock()
+	tenantResidencyCache = make(map[string]*tenantResidencyCacheEntry)
+	tenantResidencyCacheMu.Unlock()
+}
+
+// GetTenantResidencyRegion returns the residency region of an organization,
+// "" when it has none, with 60s TTL caching.
+func GetTenantResidencyRegion(owner string) (string, error) {
+	if owner == "" {
+		return "", nil
+	}
+	tenantResidencyCacheMu.RLock()
+	entry, ok := tenantResidencyCache[owner]
+	tenantResidencyCacheMu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < tenantResidencyCacheTTL {
+		return entry.region, nil
+	}
+	residency, err := GetTenantResidency(owner)
+	if err != nil {
+		return "", err
+	}
+	region := ""
+	if residency != nil {
+		region = residency.Region
+	}
+	tenantResidencyCacheMu.Lock()
+	tenantResidencyCache[owner] = &tenantResidencyCacheEntry{region: region, fetchedAt: time.Now()}
+	tenantResidencyCacheMu.Unlock()
+	return region, nil
+}
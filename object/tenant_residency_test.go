@@ -0,0 +1,40 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestGetProviderResidency(t *testing.T) {
+	tests := map[string]string{
+		"eu":            ResidencyEU,
+		"EU":            ResidencyEU,
+		"eu-west-1":     ResidencyEU,
+		"europe-west4":  ResidencyEU,
+		"us":            ResidencyUS,
+		"us-east-1":     ResidencyUS,
+		"westeurope":    ResidencyEU,
+		"SwedenCentral": ResidencyEU,
+		"eastus":        ResidencyUS,
+		"westus3":       ResidencyUS,
+		"uksouth":       "",
+		"ap-south-1":    "",
+		"":              "",
+	}
+	for region, want := range tests {
+		if got := GetProviderResidency(&Provider{Region: region}); got != want {
+			t.Errorf("GetProviderResidency(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
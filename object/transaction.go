@@ -29,8 +29,6 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
-var CloudHost = ""
-
 // commerceClient returns an HTTP client and the Commerce billing endpoint URL.
 // Returns ("", nil) if Commerce is not configured.
 func commerceClient() (string, string, *http.Client) {
@@ -15,81 +15,122 @@ package object
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"strings"
+	"time"
 
 	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/i18n"
+	"github.com/hanzoai/cloud/storage"
 	"github.com/hanzoai/cloud/util"
 )
 
+// defaultMaxUploadFileMB is the size limit of an uploaded file when
+// maxUploadFileMB is unset.
+const defaultMaxUploadFileMB = 4096
+
 func UpdateTreeFile(storeId string, key string, file *TreeFile) bool {
 	return true
 }
 
-func AddTreeFile(storeId string, userName string, key string, isLeaf bool, filename string, file multipart.File, lang string) (bool, []byte, error) {
+// GetMaxUploadFileSize returns the size limit of an uploaded file in bytes,
+// from maxUploadFileMB (default 4 GB).
+func GetMaxUploadFileSize() int64 {
+	mb := int64(conf.GetConfigInt("maxUploadFileMB"))
+	if mb <= 0 {
+		mb = defaultMaxUploadFileMB
+	}
+	return mb << 20
+}
+
+// AddTreeFile stores a file, or a folder when isLeaf is false, under key in
+// the store. The file is streamed to storage; size is its length, or -1 if
+// unknown. A file that takes the org past its storage cap is rejected.
+func AddTreeFile(storeId string, userName string, key string, isLeaf bool, filename string, file io.Reader, size int64, lang string) (bool, error) {
 	store, err := GetStore(storeId)
 	if err != nil {
-		return false, nil, err
+		return false, err
 	}
 	if store == nil {
-		return false, nil, nil
+		return false, nil
 	}
 	storageProviderObj, err := store.GetStorageProviderObj(lang)
 	if err != nil {
-		return false, nil, err
+		return false, err
 	}
-	var objectKey string
-	var fileBuffer *bytes.Buffer
 	if isLeaf {
-		objectKey = fmt.Sprintf("%s/%s", key, filename)
-		objectKey = strings.TrimLeft(objectKey, "/")
-		fileBuffer = bytes.NewBuffer(nil)
-		_, err = io.Copy(fileBuffer, file)
+		objectKey := strings.TrimLeft(fmt.Sprintf("%s/%s", key, filename), "/")
+		err = checkStorageQuota(store.Owner, max(size, 0))
 		if err != nil {
-			return false, nil, err
+			return false, err
 		}
-		bs := fileBuffer.Bytes()
-		fileUrl, err := storageProviderObj.PutObject(userName, store.Name, objectKey, fileBuffer)
+		counter := &countingReader{reader: file}
+		fileUrl, err := storage.PutObjectFromReader(storageProviderObj, userName, store.Name, objectKey, counter, size, GetMaxUploadFileSize())
 		if err != nil {
-			return false, nil, err
+			return false, err
 		}
-		// Persist file information in the file table
-		fileRecord := &File{
-			Owner:           store.Owner,
-			Name:            getFileName(store.Name, objectKey),
-			CreatedTime:     util.GetCurrentTime(),
-			Filename:        filename,
-			Size:            int64(len(bs)),
-			Store:           store.Name,
-			StorageProvider: store.StorageProvider,
-			Url:             fileUrl,
-			TokenCount:      0,
-			Status:          FileStatusPending, // Initial status before embedding
+		if size < 0 {
+			err = checkStorageQuota(store.Owner, counter.n)
+			if err != nil {
+				return false, errors.Join(err, storageProviderObj.DeleteObject(objectKey))
+			}
 		}
-		_, err = AddFile(fileRecord)
+		err = addTreeFileRecord(store, objectKey, filename, counter.n, fileUrl, lang)
 		if err != nil {
-			return false, nil, err
+			return false, err
 		}
-		go func() {
-			_, vectorErr := AddVectorsForFile(store, objectKey, fileUrl, lang)
-			if vectorErr != nil {
-				logs.Error("Failed to generate vectors for file %s: %v", objectKey, vectorErr)
-			}
-		}()
-		return true, bs, nil
+		return true, nil
 	} else {
-		objectKey = fmt.Sprintf("%s/%s/_hidden.ini", key, filename)
-		objectKey = strings.TrimLeft(objectKey, "/")
-		fileBuffer = bytes.NewBuffer(nil)
-		bs := fileBuffer.Bytes()
-		_, err = storageProviderObj.PutObject(userName, store.Name, objectKey, fileBuffer)
+		objectKey := strings.TrimLeft(fmt.Sprintf("%s/%s/_hidden.ini", key, filename), "/")
+		_, err = storageProviderObj.PutObject(userName, store.Name, objectKey, bytes.NewBuffer(nil))
 		if err != nil {
-			return false, nil, err
+			return false, err
 		}
-		return true, bs, nil
+		return true, nil
+	}
+}
+
+// addTreeFileRecord persists an uploaded file in the file table and
+// generates its vectors in the background.
+func addTreeFileRecord(store *Store, objectKey string, filename string, size int64, fileUrl string, lang string) error {
+	fileRecord := &File{
+		Owner:           store.Owner,
+		Name:            getFileName(store.Name, objectKey),
+		CreatedTime:     util.GetCurrentTime(),
+		Filename:        filename,
+		Size:            size,
+		Store:           store.Name,
+		StorageProvider: store.StorageProvider,
+		Url:             fileUrl,
+		TokenCount:      0,
+		Status:          FileStatusPending, // Initial status before embedding
+	}
+	_, err := AddFile(fileRecord)
+	if err != nil {
+		return err
 	}
+	go func() {
+		_, vectorErr := AddVectorsForFile(store, objectKey, fileUrl, lang)
+		if vectorErr != nil {
+			logs.Error("Failed to generate vectors for file %s: %v", objectKey, vectorErr)
+		}
+	}()
+	return nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
 }
 
 func DeleteTreeFile(storeId string, key string, isLeaf bool, lang string) (bool, error) {
@@ -145,3 +186,86 @@ func DeleteTreeFile(storeId string, key string, isLeaf bool, lang string) (bool,
 	}
 	return true, nil
 }
+
+func getStorePresigner(storeId string, lang string) (*Store, storage.PresignedStorageProvider, error) {
+	store, err := GetStore(storeId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store == nil {
+		return nil, nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "account:The store: %s is not found"), storeId))
+	}
+	storageProviderObj, err := store.GetStorageProviderObj(lang)
+	if err != nil {
+		return nil, nil, err
+	}
+	presigner, ok := storageProviderObj.(storage.PresignedStorageProvider)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s", i18n.Translate(lang, "object:The storage provider does not support presigned URLs"))
+	}
+	return store, presigner, nil
+}
+
+// GetPresignedTreeFileUrl returns a URL that reads (GET) or writes (PUT) the
+// file at key in the store's storage until it expires. Only providers that
+// can presign, such as S3, support it. A PUT URL only accepts a file of
+// size bytes, within the upload limit and the org's storage cap; the upload
+// is added to the store by CompletePresignedTreeFileUpload.
+func GetPresignedTreeFileUrl(storeId string, key string, method string, size int64, expires time.Duration, lang string) (string, error) {
+	store, presigner, err := getStorePresigner(storeId, lang)
+	if err != nil {
+		return "", err
+	}
+
+	key = strings.TrimLeft(key, "/")
+	switch method {
+	case "GET":
+		return presigner.PresignGetObject(key, expires)
+	case "PUT":
+		if size <= 0 {
+			return "", fmt.Errorf("%s", i18n.Translate(lang, "object:The size of the file to upload is required"))
+		}
+		if limit := GetMaxUploadFileSize(); size > limit {
+			return "", &storage.ObjectTooLargeError{Limit: limit}
+		}
+		if err = checkStorageQuota(store.Owner, size); err != nil {
+			return "", err
+		}
+		return presigner.PresignPutObject(key, size, expires)
+	default:
+		return "", fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "object:Unsupported presign method: %s"), method))
+	}
+}
+
+// CompletePresignedTreeFileUpload adds a file uploaded through a presigned
+// PUT URL to the store like an uploaded tree file. Files over the size
+// limit or the org's storage cap are deleted.
+func CompletePresignedTreeFileUpload(storeId string, key string, lang string) (bool, error) {
+	store, presigner, err := getStorePresigner(storeId, lang)
+	if err != nil {
+		return false, err
+	}
+
+	key = strings.TrimLeft(key, "/")
+	stored, err := presigner.StatObject(key)
+	if err != nil {
+		return false, err
+	}
+	if limit := GetMaxUploadFileSize(); stored.Size > limit {
+		err = presigner.(storage.StorageProvider).DeleteObject(key)
+		if err != nil {
+			return false, err
+		}
+		return false, &storage.ObjectTooLargeError{Limit: limit}
+	}
+	if err = checkStorageQuota(store.Owner, stored.Size); err != nil {
+		return false, errors.Join(err, presigner.(storage.StorageProvider).DeleteObject(key))
+	}
+
+	filename := key[strings.LastIndex(key, "/")+1:]
+	err = addTreeFileRecord(store, key, filename, stored.Size, stored.Url, lang)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hanzoai/cloud/i18n"
+	"github.com/hanzoai/cloud/storage"
+)
+
+// Resumable uploads send a large file as parts of a multipart upload. The
+// upload lives in the storage provider until it is completed or aborted, so
+// a client that lost its connection lists the parts already stored and
+// sends the rest.
+
+func getStoreMultipartProvider(storeId string, lang string) (*Store, storage.MultipartStorageProvider, error) {
+	store, err := GetStore(storeId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store == nil {
+		return nil, nil, fmt.Errorf("%s", fmt.Sprintf(i18n.Translate(lang, "account:The store: %s is not found"), storeId))
+	}
+	storageProviderObj, err := store.GetStorageProviderObj(lang)
+	if err != nil {
+		return nil, nil, err
+	}
+	multipart, ok := storageProviderObj.(storage.MultipartStorageProvider)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s", i18n.Translate(lang, "object:The storage provider does not support multipart uploads"))
+	}
+	return store, multipart, nil
+}
+
+// CreateTreeFileUpload starts the upload of filename under key in the store
+// and returns the object key and upload ID to send its parts to.
+func CreateTreeFileUpload(storeId string, key string, filename string, lang string) (string, string, error) {
+	_, multipart, err := getStoreMultipartProvider(storeId, lang)
+	if err != nil {
+		return "", "", err
+	}
+	objectKey := strings.TrimLeft(fmt.Sprintf("%s/%s", key, filename), "/")
+	uploadId, err := multipart.CreateMultipartUpload(objectKey)
+	if err != nil {
+		return "", "", err
+	}
+	return objectKey, uploadId, nil
+}
+
+// UploadTreeFilePart stores part partNumber of an upload and returns its ETag.
+// Sending a part again replaces it. Parts taking the upload over the size
+// limit are refused.
+func UploadTreeFilePart(storeId string, objectKey string, uploadId string, partNumber int32, reader io.Reader, size int64, lang string) (string, error) {
+	_, multipart, err := getStoreMultipartProvider(storeId, lang)
+	if err != nil {
+		return "", err
+	}
+	return storage.UploadPartWithinLimit(multipart, objectKey, uploadId, partNumber, reader, size, GetMaxUploadFileSize())
+}
+
+// GetTreeFileUploadParts returns the parts of an upload stored so far.
+func GetTreeFileUploadParts(storeId string, objectKey string, uploadId string, lang string) ([]storage.Part, error) {
+	_, multipart, err := getStoreMultipartProvider(storeId, lang)
+	if err != nil {
+		return nil, err
+	}
+	return multipart.ListParts(objectKey, uploadId)
+}
+
+// CompleteTreeFileUpload assembles the stored parts of an upload into the file
+// and adds it to the store like an uploaded tree file. Uploads over the size
+// limit are aborted.
+func CompleteTreeFileUpload(storeId string, objectKey string, uploadId string, lang string) (bool, error) {
+	store, multipart, err := getStoreMultipartProvider(storeId, lang)
+	if err != nil {
+		return false, err
+	}
+	parts, err := multipart.ListParts(objectKey, uploadId)
+	if err != nil {
+		return false, err
+	}
+	if len(parts) == 0 {
+		return false, fmt.Errorf("%s", i18n.Translate(lang, "object:The upload has no parts"))
+	}
+
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+	}
+	if limit := GetMaxUploadFileSize(); size > limit {
+		err = multipart.AbortMultipartUpload(objectKey, uploadId)
+		if err != nil {
+			return false, err
+		}
+		return false, &storage.ObjectTooLargeError{Limit: limit}
+	}
+
+	fileUrl, err := multipart.CompleteMultipartUpload(objectKey, uploadId, parts)
+	if err != nil {
+		return false, err
+	}
+	filename := objectKey[strings.LastIndex(objectKey, "/")+1:]
+	err = addTreeFileRecord(store, objectKey, filename, size, fileUrl, lang)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AbortTreeFileUpload discards an upload and the parts stored for it.
+func AbortTreeFileUpload(storeId string, objectKey string, uploadId string, lang string) error {
+	_, multipart, err := getStoreMultipartProvider(storeId, lang)
+	if err != nil {
+		return err
+	}
+	return multipart.AbortMultipartUpload(objectKey, uploadId)
+}
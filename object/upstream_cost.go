@@ -0,0 +1,138 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"time"
+
+	"github.com/hanzoai/dbx"
+)
+
+// Statuses of an upstream cost.
+const (
+	UpstreamCostPending  = "Pending"
+	UpstreamCostApproved = "Approved"
+)
+
+// UpstreamCost is a provider's published price of an upstream model that
+// differs from the cost in the model config: pending until an admin
+// approves it, then applied over the config's cost until the config is
+// changed. An upstream has at most one cost of each status.
+type UpstreamCost struct {
+	Provider                 string  `db:"pk" json:"provider"`
+	Upstream                 string  `db:"pk" json:"upstream"`
+	Status                   string  `db:"pk" json:"status"`
+	UpdatedTime              string  `json:"updatedTime"`
+	InputPerMillion          float64 `json:"inputPerMillion"`
+	OutputPerMillion         float64 `json:"outputPerMillion"`
+	PreviousInputPerMillion  float64 `json:"previousInputPerMillion"` // the config's cost it replaces
+	PreviousOutputPerMillion float64 `json:"previousOutputPerMillion"`
+}
+
+// GetUpstreamCosts returns the upstream costs of status, by provider and
+// upstream.
+func GetUpstreamCosts(status string) ([]*UpstreamCost, error) {
+	costs := []*UpstreamCost{}
+	if adapter == nil || adapter.db == nil {
+		return costs, nil
+	}
+	err := findAll(adapter.db, "upstream_cost", &costs, dbx.HashExp{"status": status}, "provider", "upstream")
+	if err != nil {
+		return costs, err
+	}
+	return costs, nil
+}
+
+func getUpstreamCost(provider string, upstream string, status string) (*UpstreamCost, error) {
+	cost := UpstreamCost{Provider: provider, Upstream: upstream, Status: status}
+	existed, err := getOne(adapter.db, "upstream_cost", &cost, upstreamCostPk(provider, upstream, status))
+	if err != nil || !existed {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+func upstreamCostPk(provider string, upstream string, status string) dbx.HashExp {
+	return dbx.HashExp{"provider": provider, "upstream": upstream, "status": status}
+}
+
+// setUpstreamCost stores cost under its status, replacing the one there.
+func setUpstreamCost(tx dbx.Builder, cost *UpstreamCost) error {
+	cost.UpdatedTime = time.Now().UTC().Format(time.RFC3339)
+	_, err := tx.Delete("upstream_cost", upstreamCostPk(cost.Provider, cost.Upstream, cost.Status)).Execute()
+	if err != nil {
+		return err
+	}
+	return tx.Model(cost).Insert()
+}
+
+// AddPendingUpstreamCosts stores published prices awaiting approval,
+// replacing those pending for the same upstreams.
+func AddPendingUpstreamCosts(costs []*UpstreamCost) error {
+	return adapter.db.Transactional(func(tx *dbx.Tx) error {
+		for _, cost := range costs {
+			cost.Status = UpstreamCostPending
+			if err := setUpstreamCost(tx, cost); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ApproveUpstreamCost makes the pending cost of an upstream its approved
+// one, and returns it, or nil when none is pending. A cost pending over an
+// approved one replaces the config's cost that one replaced.
+func ApproveUpstreamCost(provider string, upstream string) (*UpstreamCost, error) {
+	cost, err := getUpstreamCost(provider, upstream, UpstreamCostPending)
+	if err != nil || cost == nil {
+		return nil, err
+	}
+	approved, err := getUpstreamCost(provider, upstream, UpstreamCostApproved)
+	if err != nil {
+		return nil, err
+	}
+	if approved != nil && approved.InputPerMillion == cost.PreviousInputPerMillion && approved.OutputPerMillion == cost.PreviousOutputPerMillion {
+		cost.PreviousInputPerMillion = approved.PreviousInputPerMillion
+		cost.PreviousOutputPerMillion = approved.PreviousOutputPerMillion
+	}
+	err = adapter.db.Transactional(func(tx *dbx.Tx) error {
+		affected, err := tx.Delete("upstream_cost", upstreamCostPk(provider, upstream, UpstreamCostPending)).Execute()
+		if err != nil {
+			return err
+		}
+		if n, err := affected.RowsAffected(); err != nil || n == 0 {
+			// Approved or rejected concurrently.
+			cost = nil
+			return err
+		}
+		cost.Status = UpstreamCostApproved
+		return setUpstreamCost(tx, cost)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cost, nil
+}
+
+// RejectUpstreamCost discards the pending cost of an upstream, reporting
+// whether there was one.
+func RejectUpstreamCost(provider string, upstream string) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "upstream_cost", upstreamCostPk(provider, upstream, UpstreamCostPending))
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanzoai/cloud/util"
+	"github.com/hanzoai/dbx"
+)
+
+// Webhook event types.
+const (
+	WebhookEventRequestCompleted = "request.completed"
+	WebhookEventRequestFailed    = "request.failed"
+	WebhookEventSpendThreshold   = "spend.threshold"
+	WebhookEventModelDeprecated  = "model.deprecated"
+)
+
+var WebhookEventTypes = []string{
+	WebhookEventRequestCompleted, WebhookEventRequestFailed, WebhookEventSpendThreshold, WebhookEventModelDeprecated,
+}
+
+// webhookSecretPrefix marks the generated signing secrets.
+const webhookSecretPrefix = "whsec_"
+
+// Webhook is an endpoint an organization receives events on. Payloads are
+// signed with Secret; see webhook_delivery.go.
+type Webhook struct {
+	Owner       string      `db:"pk" json:"owner"` // org ID
+	Name        string      `db:"pk" json:"name"`
+	CreatedTime string      `json:"createdTime"`
+	UpdatedTime string      `json:"updatedTime"`
+	Url         string      `json:"url"`
+	Secret      string      `json:"secret"` // generated when empty
+	Events      StringSlice `json:"events"` // event types to send; empty means all
+	Enabled     bool        `json:"enabled"`
+}
+
+func (w *Webhook) GetId() string {
+	return fmt.Sprintf("%s/%s", w.Owner, w.Name)
+}
+
+// Wants reports whether the webhook subscribes to eventType.
+func (w *Webhook) Wants(eventType string) bool {
+	return len(w.Events) == 0 || util.InSlice(w.Events, eventType)
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(b), nil
+}
+
+func GetWebhooks(owner string) ([]*Webhook, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	webhooks := []*Webhook{}
+	err := findAll(adapter.db, "webhook", &webhooks, dbx.HashExp{"owner": owner}, "name")
+	if err != nil {
+		return webhooks, err
+	}
+	return webhooks, nil
+}
+
+func GetWebhook(owner string, name string) (*Webhook, error) {
+	if adapter == nil || adapter.db == nil {
+		return nil, nil
+	}
+	webhook := Webhook{Owner: owner, Name: name}
+	existed, err := getOne(adapter.db, "webhook", &webhook, pk2(owner, name))
+	if err != nil {
+		return &webhook, err
+	}
+	if existed {
+		return &webhook, nil
+	}
+	return nil, nil
+}
+
+func GetMaskedWebhook(webhook *Webhook, errs ...error) (*Webhook, error) {
+	if len(errs) > 0 && errs[0] != nil {
+		return nil, errs[0]
+	}
+	if webhook == nil {
+		return nil, nil
+	}
+	if webhook.Secret != "" {
+		webhook.Secret = "***"
+	}
+	return webhook, nil
+}
+
+func GetMaskedWebhooks(webhooks []*Webhook, errs ...error) ([]*Webhook, error) {
+	if len(errs) > 0 && errs[0] != nil {
+		return nil, errs[0]
+	}
+	var err error
+	for _, webhook := range webhooks {
+		webhook, err = GetMaskedWebhook(webhook)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return webhooks, nil
+}
+
+func validateWebhook(webhook *Webhook) error {
+	if webhook.Owner == "" || webhook.Name == "" {
+		return fmt.Errorf("owner and name are required")
+	}
+	u, err := url.Parse(webhook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if err = validateWebhookHost(u.Hostname()); err != nil {
+		return err
+	}
+	for _, eventType := range webhook.Events {
+		if !util.InSlice(WebhookEventTypes, eventType) {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+	return nil
+}
+
+// validateWebhookHost refuses webhook hosts on internal networks. Host
+// names are checked again, once resolved, by the delivery client.
+func validateWebhookHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url must not point to localhost")
+	}
+	if ip := net.ParseIP(host); ip != nil && !util.IsPublicIp(ip) {
+		return fmt.Errorf("url must point to a public address")
+	}
+	return nil
+}
+
+// AddWebhook adds a webhook, generating its secret when none is given.
+func AddWebhook(webhook *Webhook) (bool, error) {
+	if err := validateWebhook(webhook); err != nil {
+		return false, err
+	}
+	if webhook.Secret == "" || webhook.Secret == "***" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return false, err
+		}
+		webhook.Secret = secret
+	}
+	webhook.CreatedTime = time.Now().Format(time.RFC3339)
+	webhook.UpdatedTime = webhook.CreatedTime
+	err := insertRow(adapter.db, webhook)
+	if err != nil {
+		return false, err
+	}
+	invalidateWebhookCache()
+	return true, nil
+}
+
+// UpdateWebhook replaces a webhook. A masked or empty secret keeps the
+// current one.
+func UpdateWebhook(owner string, name string, webhook *Webhook) (bool, error) {
+	webhook.Owner = owner
+	webhook.Name = name
+	if err := validateWebhook(webhook); err != nil {
+		return false, err
+	}
+	existing, err := GetWebhook(owner, name)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, nil
+	}
+	if webhook.Secret == "" || webhook.Secret == "***" {
+		webhook.Secret = existing.Secret
+	}
+	webhook.CreatedTime = existing.CreatedTime
+	webhook.UpdatedTime = time.Now().Format(time.RFC3339)
+	err = adapter.db.Model(webhook).Update()
+	if err != nil {
+		return false, err
+	}
+	invalidateWebhookCache()
+	return true, nil
+}
+
+func DeleteWebhook(webhook *Webhook) (bool, error) {
+	affected, err := deleteByPK(adapter.db, "webhook", pk2(webhook.Owner, webhook.Name))
+	if err != nil {
+		return false, err
+	}
+	invalidateWebhookCache()
+	return affected != 0, nil
+}
+
+// ── Cached resolution for hot path ──────────────────────────────────────
+type webhookCacheEntry struct {
+	webhooks  []*Webhook
+	fetchedAt time.Time
+}
+
+var (
+	webhookCache    = make(map[string]*webhookCacheEntry)
+	webhookCacheMu  sync.RWMutex
+	webhookCacheTTL = 60 * time.Second
+)
+
+func invalidateWebhookCache() {
+	webhookCacheMu.Lock()
+	webhookCache = make(map[string]*webhookCacheEntry)
+	webhookCacheMu.Unlock()
+}
+
+// GetActiveWebhooks returns an organization's enabled webhooks that want
+// eventType, with 60s TTL caching.
+func GetActiveWebhooks(owner string, eventType string) ([]*Webhook, error) {
+	webhookCacheMu.RLock()
+	entry, ok := webhookCache[owner]
+	webhookCacheMu.RUnlock()
+	if !ok || time.Since(entry.fetchedAt) >= webhookCacheTTL {
+		webhooks, err := GetWebhooks(owner)
+		if err != nil {
+			return nil, err
+		}
+		entry = &webhookCacheEntry{webhooks: webhooks, fetchedAt: time.Now()}
+		webhookCacheMu.Lock()
+		webhookCache[owner] = entry
+		webhookCacheMu.Unlock()
+	}
+
+	active := []*Webhook{}
+	for _, webhook := range entry.webhooks {
+		if webhook.Enabled && webhook.Wants(eventType) {
+			active = append(active, webhook)
+		}
+	}
+	return active, nil
+}
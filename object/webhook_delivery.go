@@ -0,0 +1,476 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+	"github.com/hanzoai/dbx"
+	"github.com/robfig/cron/v3"
+)
+
+// Webhook deliveries. Every event an organization's webhook wants is stored
+// in the webhook_delivery table and POSTed to the webhook's URL as JSON:
+//
+//	{"id": "evt_…", "type": "request.completed", "created": 1767225600, "org": "acme", "data": {…}}
+//
+// with these headers, the signature being the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook's secret:
+//
+//	X-Webhook-Id:        evt_…
+//	X-Webhook-Event:     request.completed
+//	X-Webhook-Signature: t=<unix timestamp>,v1=<signature>
+//
+// A 2xx response delivers the event. Other responses and errors are retried
+// after webhookRetryDelays by a sweep every minute, then the delivery fails.
+// Replays send a stored payload again as a new delivery with the same event
+// ID, so receivers can deduplicate.
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending" // waiting for its next attempt
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // out of attempts
+)
+
+const (
+	// webhookTimeout bounds one delivery attempt.
+	webhookTimeout = 10 * time.Second
+
+	// webhookAttemptLease keeps an attempt in progress from being picked up
+	// by the retry sweep of this or another instance.
+	webhookAttemptLease = time.Minute
+
+	// webhookRetryBatch is the most deliveries one sweep retries.
+	webhookRetryBatch = 100
+
+	// webhookMaxConcurrentAttempts bounds the first attempts made in the
+	// background. Deliveries beyond it wait for the retry sweep.
+	webhookMaxConcurrentAttempts = 64
+
+	// webhookMaxErrorChars caps the stored error of a failed attempt.
+	webhookMaxErrorChars = 1000
+
+	defaultWebhookDeliveryRetentionDays = 30
+)
+
+// webhookRetryDelays are the waits after each failed attempt. A delivery
+// fails for good after the attempt following the last one.
+var webhookRetryDelays = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 8 * time.Hour,
+}
+
+// webhookHTTPClient only connects to public addresses, so a webhook cannot
+// reach the gateway's internal network, whatever its host resolves to.
+var webhookHTTPClient = newWebhookHTTPClient()
+
+// webhookAttempts holds a slot per first attempt in progress.
+var webhookAttempts = make(chan struct{}, webhookMaxConcurrentAttempts)
+
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: util.PublicOnlyDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// WebhookEvent is the payload of a delivery.
+type WebhookEvent struct {
+	Id      string      `json:"id"`
+	Type    string      `json:"type"`
+	Created int64       `json:"created"`
+	Org     string      `json:"org"`
+	Data    interface{} `json:"data"`
+}
+
+// WebhookDelivery is one event sent, or being sent, to one webhook.
+type WebhookDelivery struct {
+	Id              int    `db:"pk" json:"id"`
+	Owner           string `json:"owner"`   // organization
+	Webhook         string `json:"webhook"` // webhook name
+	EventId         string `json:"eventId"`
+	Event           string `json:"event"`
+	Payload         string `json:"payload"`
+	Status          string `json:"status"` // "pending", "succeeded" or "failed"
+	Attempts        int    `json:"attempts"`
+	ResponseCode    int    `json:"responseCode"`
+	Error           string `json:"error"`
+	CreatedTime     string `json:"createdTime"`
+	UpdatedTime     string `json:"updatedTime"`
+	NextAttemptTime string `json:"nextAttemptTime"`
+}
+
+// SignWebhookPayload returns the X-Webhook-Signature value of body sent at
+// timestamp.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// VerifyWebhookSignature checks an X-Webhook-Signature value received with
+// body, for the services posting signed events to the gateway. Signatures
+// made more than tolerance away from now are refused, so a captured event
+// cannot be replayed later.
+func VerifyWebhookSignature(secret string, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var sums []string
+	for _, field := range strings.Split(signature, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch name {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature timestamp %q", value)
+			}
+			timestamp = parsed
+		case "v1":
+			sums = append(sums, value)
+		}
+	}
+	if timestamp == 0 || len(sums) == 0 {
+		return fmt.Errorf("malformed signature")
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside the %s tolerance", tolerance)
+	}
+
+	want := SignWebhookPayload(secret, timestamp, body)
+	want = want[strings.Index(want, "v1=")+len("v1="):]
+	for _, sum := range sums {
+		if hmac.Equal([]byte(sum), []byte(want)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// SendWebhookEvent stores a delivery of the event for each of the
+// organization's webhooks that want it and makes their first attempts in
+// the background. When webhookMaxConcurrentAttempts are already in
+// progress, the stored deliveries are left to the retry sweep.
+func SendWebhookEvent(owner string, eventType string, data interface{}) error {
+	if adapter == nil || adapter.db == nil || owner == "" {
+		return nil
+	}
+	webhooks, err := GetActiveWebhooks(owner, eventType)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	event := &WebhookEvent{
+		Id:      "evt_" + strings.ReplaceAll(util.GenerateUUID(), "-", ""),
+		Type:    eventType,
+		Created: time.Now().Unix(),
+		Org:     owner,
+		Data:    data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		delivery, err := addWebhookDelivery(webhook, event.Id, eventType, string(payload))
+		if err != nil {
+			return err
+		}
+		select {
+		case webhookAttempts <- struct{}{}:
+			go func() {
+				defer func() { <-webhookAttempts }()
+				attemptWebhookDelivery(webhook, delivery)
+			}()
+		default:
+		}
+	}
+	return nil
+}
+
+// addWebhookDelivery stores a pending delivery, leased for its first
+// attempt.
+func addWebhookDelivery(webhook *Webhook, eventId string, eventType string, payload string) (*WebhookDelivery, error) {
+	now := time.Now().UTC()
+	delivery := &WebhookDelivery{
+		Owner:           webhook.Owner,
+		Webhook:         webhook.Name,
+		EventId:         eventId,
+		Event:           eventType,
+		Payload:         payload,
+		Status:          WebhookDeliveryPending,
+		CreatedTime:     now.Format(time.RFC3339),
+		UpdatedTime:     now.Format(time.RFC3339),
+		NextAttemptTime: now.Add(webhookAttemptLease).Format(time.RFC3339),
+	}
+	if err := insertRow(adapter.db, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// postWebhook sends payload to the webhook and returns the response status.
+func postWebhook(webhook *Webhook, eventId string, eventType string, payload []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Hanzo-Webhooks/1.0")
+	request.Header.Set("X-Webhook-Id", eventId)
+	request.Header.Set("X-Webhook-Event", eventType)
+	request.Header.Set("X-Webhook-Signature", SignWebhookPayload(webhook.Secret, time.Now().Unix(), payload))
+
+	response, err := webhookHTTPClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, webhookMaxErrorChars))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("HTTP %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return response.StatusCode, nil
+}
+
+// attemptWebhookDelivery makes one attempt and records its outcome,
+// scheduling the next attempt of a failed one.
+func attemptWebhookDelivery(webhook *Webhook, delivery *WebhookDelivery) {
+	code, err := postWebhook(webhook, delivery.EventId, delivery.Event, []byte(delivery.Payload))
+	finishWebhookAttempt(delivery, code, err, time.Now().UTC())
+	if err := updateWebhookDelivery(delivery); err != nil {
+		logs.Warn("webhook: failed to record delivery id=%d: %v", delivery.Id, err)
+	}
+}
+
+// finishWebhookAttempt applies the outcome of an attempt made at now.
+func finishWebhookAttempt(delivery *WebhookDelivery, code int, err error, now time.Time) {
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.UpdatedTime = now.Format(time.RFC3339)
+	delivery.NextAttemptTime = ""
+	if err == nil {
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.Error = ""
+		WebhookDeliveries.WithLabelValues(delivery.Event, WebhookDeliverySucceeded).Inc()
+		return
+	}
+
+	delivery.Error = truncateRunes(err.Error(), webhookMaxErrorChars)
+	if delivery.Attempts > len(webhookRetryDelays) {
+		delivery.Status = WebhookDeliveryFailed
+		WebhookDeliveries.WithLabelValues(delivery.Event, WebhookDeliveryFailed).Inc()
+		logs.Warn("webhook: delivery id=%d to %s/%s failed after %d attempts: %v",
+			delivery.Id, delivery.Owner, delivery.Webhook, delivery.Attempts, err)
+		return
+	}
+	delivery.Status = WebhookDeliveryPending
+	delivery.NextAttemptTime = now.Add(webhookRetryDelays[delivery.Attempts-1]).Format(time.RFC3339)
+	WebhookDeliveries.WithLabelValues(delivery.Event, "retrying").Inc()
+}
+
+func updateWebhookDelivery(delivery *WebhookDelivery) error {
+	return adapter.db.Model(delivery).Update()
+}
+
+// claimWebhookDelivery leases a due delivery for an attempt. It fails when
+// another sweep claimed it first.
+func claimWebhookDelivery(delivery *WebhookDelivery, now time.Time) (bool, error) {
+	lease := now.Add(webhookAttemptLease).Format(time.RFC3339)
+	affected, err := updateCols(adapter.db, "webhook_delivery",
+		dbx.HashExp{"id": delivery.Id, "next_attempt_time": delivery.NextAttemptTime},
+		dbx.Params{"next_attempt_time": lease})
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	delivery.NextAttemptTime = lease
+	return true, nil
+}
+
+// RetryWebhookDeliveries attempts the pending deliveries that are due and
+// returns how many it attempted. Deliveries of deleted or disabled
+// webhooks fail.
+func RetryWebhookDeliveries() (int, error) {
+	now := time.Now().UTC()
+	deliveries := []*WebhookDelivery{}
+	err := adapter.db.Select().From("webhook_delivery").
+		Where(dbx.And(
+			dbx.HashExp{"status": WebhookDeliveryPending},
+			dbx.NewExp("next_attempt_time <= {:now}", dbx.Params{"now": now.Format(time.RFC3339)}),
+		)).
+		OrderBy("id").Limit(webhookRetryBatch).All(&deliveries)
+	if err != nil {
+		return 0, err
+	}
+
+	attempted := 0
+	for _, delivery := range deliveries {
+		claimed, err := claimWebhookDelivery(delivery, now)
+		if err != nil {
+			return attempted, err
+		}
+		if !claimed {
+			continue
+		}
+		webhook, err := GetWebhook(delivery.Owner, delivery.Webhook)
+		if err != nil {
+			return attempted, err
+		}
+		if webhook == nil || !webhook.Enabled {
+			delivery.Status = WebhookDeliveryFailed
+			delivery.Error = "the webhook was deleted or disabled"
+			delivery.NextAttemptTime = ""
+			delivery.UpdatedTime = now.Format(time.RFC3339)
+			if err = updateWebhookDelivery(delivery); err != nil {
+				return attempted, err
+			}
+			continue
+		}
+		attemptWebhookDelivery(webhook, delivery)
+		attempted++
+	}
+	return attempted, nil
+}
+
+// ReplayWebhookDelivery sends the payload of one of owner's deliveries
+// again as a new delivery, attempted at once, and returns it.
+func ReplayWebhookDelivery(owner string, id int) (*WebhookDelivery, error) {
+	delivery, err := GetWebhookDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil || delivery.Owner != owner {
+		return nil, fmt.Errorf("webhook delivery %d does not exist", id)
+	}
+	webhook, err := GetWebhook(delivery.Owner, delivery.Webhook)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, fmt.Errorf("webhook %s/%s does not exist", delivery.Owner, delivery.Webhook)
+	}
+
+	replay, err := addWebhookDelivery(webhook, delivery.EventId, delivery.Event, delivery.Payload)
+	if err != nil {
+		return nil, err
+	}
+	attemptWebhookDelivery(webhook, replay)
+	return replay, nil
+}
+
+// WebhookDeliveryFilter narrows a delivery query. Empty fields match
+// anything.
+type WebhookDeliveryFilter struct {
+	Owner   string
+	Webhook string
+	Event   string
+	Status  string
+}
+
+func (f *WebhookDeliveryFilter) where() dbx.Expression {
+	exps := []dbx.Expression{}
+	for column, value := range map[string]string{
+		"owner": f.Owner, "webhook": f.Webhook, "event": f.Event, "status": f.Status,
+	} {
+		if value != "" {
+			exps = append(exps, dbx.HashExp{column: value})
+		}
+	}
+	return dbx.And(exps...)
+}
+
+func GetWebhookDeliveryCount(filter *WebhookDeliveryFilter) (int64, error) {
+	return countWhere(adapter.db, "webhook_delivery", filter.where())
+}
+
+func GetPaginationWebhookDeliveries(filter *WebhookDeliveryFilter, offset, limit int) ([]*WebhookDelivery, error) {
+	deliveries := []*WebhookDelivery{}
+	q := adapter.db.Select().From("webhook_delivery").Where(filter.where()).OrderBy("id DESC")
+	if limit > 0 {
+		q = q.Offset(int64(offset)).Limit(int64(limit))
+	}
+	err := q.All(&deliveries)
+	if err != nil {
+		return deliveries, err
+	}
+	return deliveries, nil
+}
+
+func GetWebhookDelivery(id int) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	existed, err := getOne(adapter.db, "webhook_delivery", &delivery, pkID(id))
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		return nil, nil
+	}
+	return &delivery, nil
+}
+
+// ── Retries and retention ───────────────────────────────────────────────
+
+func getWebhookDeliveryRetentionDays() int {
+	if days := conf.GetConfigInt("webhookDeliveryRetentionDays"); days > 0 {
+		return days
+	}
+	return defaultWebhookDeliveryRetentionDays
+}
+
+// DeleteExpiredWebhookDeliveries removes deliveries older than the
+// retention period and returns how many were deleted.
+func DeleteExpiredWebhookDeliveries() (int64, error) {
+	cutoff := time.Now().UTC().AddDate(0, 0, -getWebhookDeliveryRetentionDays()).Format(time.RFC3339)
+	return deleteWhere(adapter.db, "webhook_delivery", dbx.NewExp("created_time < {:cutoff}", dbx.Params{"cutoff": cutoff}))
+}
+
+func sweepWebhookDeliveriesNoError() {
+	if adapter == nil || adapter.db == nil {
+		return
+	}
+	if _, err := RetryWebhookDeliveries(); err != nil {
+		logs.Error("sweepWebhookDeliveriesNoError() error: %s", err.Error())
+	}
+	if _, err := DeleteExpiredWebhookDeliveries(); err != nil {
+		logs.Error("sweepWebhookDeliveriesNoError() error: %s", err.Error())
+	}
+}
+
+// InitWebhookDeliveries schedules the retry of failed deliveries, and the
+// removal of deliveries older than webhookDeliveryRetentionDays (default
+// 30), every minute.
+func InitWebhookDeliveries() {
+	cronJob := cron.New()
+	_, err := cronJob.AddFunc("@every 1m", sweepWebhookDeliveriesNoError)
+	if err != nil {
+		panic(err)
+	}
+	cronJob.Start()
+}
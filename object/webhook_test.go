@@ -0,0 +1,130 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"request.completed"}`)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1767225600." + string(body)))
+	want := "t=1767225600,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhookPayload("whsec_test", 1767225600, body); got != want {
+		t.Errorf("SignWebhookPayload() = %q, want %q", got, want)
+	}
+	if got := SignWebhookPayload("whsec_other", 1767225600, body); got == want {
+		t.Errorf("SignWebhookPayload() with another secret = %q, want a different signature", got)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"type":"balance.updated","user":"acme/alice"}`)
+	now := time.Unix(1767225600, 0)
+	signature := SignWebhookPayload("whsec_test", now.Unix(), body)
+
+	if err := VerifyWebhookSignature("whsec_test", signature, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Errorf("VerifyWebhookSignature() = %v, want nil", err)
+	}
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		body      []byte
+		now       time.Time
+	}{
+		{"other secret", "whsec_other", signature, body, now},
+		{"tampered body", "whsec_test", signature, []byte(`{"type":"balance.updated","user":"acme/bob"}`), now},
+		{"expired", "whsec_test", signature, body, now.Add(10 * time.Minute)},
+		{"future", "whsec_test", signature, body, now.Add(-10 * time.Minute)},
+		{"no sum", "whsec_test", "t=1767225600", body, now},
+		{"no timestamp", "whsec_test", signature[strings.Index(signature, ",")+1:], body, now},
+		{"bad timestamp", "whsec_test", "t=soon," + signature[strings.Index(signature, ",")+1:], body, now},
+		{"empty", "whsec_test", "", body, now},
+	}
+	for _, test := range tests {
+		if err := VerifyWebhookSignature(test.secret, test.signature, test.body, 5*time.Minute, test.now); err == nil {
+			t.Errorf("%s: VerifyWebhookSignature() = nil, want an error", test.name)
+		}
+	}
+}
+
+func TestFinishWebhookAttempt(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	delivery := &WebhookDelivery{Event: WebhookEventRequestFailed, Status: WebhookDeliveryPending}
+
+	for i, delay := range webhookRetryDelays {
+		finishWebhookAttempt(delivery, 503, errors.New("HTTP 503"), now)
+		if delivery.Status != WebhookDeliveryPending || delivery.Attempts != i+1 {
+			t.Fatalf("attempt %d: status %q, attempts %d", i+1, delivery.Status, delivery.Attempts)
+		}
+		if want := now.Add(delay).Format(time.RFC3339); delivery.NextAttemptTime != want {
+			t.Errorf("attempt %d: next attempt %s, want %s", i+1, delivery.NextAttemptTime, want)
+		}
+	}
+
+	finishWebhookAttempt(delivery, 0, errors.New("connection refused"), now)
+	if delivery.Status != WebhookDeliveryFailed || delivery.NextAttemptTime != "" {
+		t.Errorf("last attempt: status %q, next attempt %q, want failed", delivery.Status, delivery.NextAttemptTime)
+	}
+
+	delivery = &WebhookDelivery{Event: WebhookEventRequestCompleted, Status: WebhookDeliveryPending, Error: "HTTP 500"}
+	finishWebhookAttempt(delivery, 204, nil, now)
+	if delivery.Status != WebhookDeliverySucceeded || delivery.Error != "" || delivery.ResponseCode != 204 {
+		t.Errorf("success: got %+v", delivery)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	valid := &Webhook{Owner: "acme", Name: "ops", Url: "https://hooks.example.com/cloud", Events: StringSlice{WebhookEventSpendThreshold}}
+	if err := validateWebhook(valid); err != nil {
+		t.Errorf("validateWebhook() = %v", err)
+	}
+
+	for _, invalid := range []*Webhook{
+		{Owner: "acme", Url: "https://hooks.example.com"},
+		{Owner: "acme", Name: "ops", Url: "ftp://hooks.example.com"},
+		{Owner: "acme", Name: "ops", Url: "hooks.example.com/cloud"},
+		{Owner: "acme", Name: "ops", Url: "https://hooks.example.com", Events: StringSlice{"request.started"}},
+		{Owner: "acme", Name: "ops", Url: "http://localhost:8080/hook"},
+		{Owner: "acme", Name: "ops", Url: "http://127.0.0.1/hook"},
+		{Owner: "acme", Name: "ops", Url: "http://10.0.0.7/hook"},
+		{Owner: "acme", Name: "ops", Url: "http://169.254.169.254/latest/meta-data"},
+		{Owner: "acme", Name: "ops", Url: "http://[::1]/hook"},
+	} {
+		if err := validateWebhook(invalid); err == nil {
+			t.Errorf("validateWebhook(%+v) = nil, want an error", invalid)
+		}
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	all := &Webhook{}
+	if !all.Wants(WebhookEventModelDeprecated) {
+		t.Error("a webhook without events should want every event")
+	}
+	some := &Webhook{Events: StringSlice{WebhookEventRequestFailed}}
+	if !some.Wants(WebhookEventRequestFailed) || some.Wants(WebhookEventRequestCompleted) {
+		t.Errorf("Wants() of %v is wrong", some.Events)
+	}
+}
@@ -0,0 +1,848 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	modulePath     = "github.com/hanzoai/cloud"
+	schemaRefBase  = "#/components/schemas/"
+)
+
+// route is one method of a path registered in routers/router.go.
+type route struct {
+	path   string // OpenAPI form, e.g. /v1/wecom-bot/callback/{botId}
+	params []string
+	method string // lowercase
+	fn     string // controller method
+}
+
+// annotation is what a controller method's doc comment declares.
+type annotation struct {
+	tags        []string
+	description string
+	params      []*paramAnnotation
+	responses   []*responseAnnotation
+	router      string // path within the /v1 namespace
+	wrapped     bool   // answers through ResponseOk, inside controllers.Response
+	file        *ast.File
+}
+
+// paramAnnotation is "@Param name in type required description".
+type paramAnnotation struct {
+	name        string
+	in          string
+	typ         string
+	required    bool
+	description string
+}
+
+// responseAnnotation is "@Success code {kind} type description".
+type responseAnnotation struct {
+	code        string
+	kind        string // object, array, string, stream or file
+	typ         string
+	description string
+}
+
+// goPackage indexes the type declarations of a Go package.
+type goPackage struct {
+	name  string
+	types map[string]*typeDecl
+}
+
+type typeDecl struct {
+	spec *ast.TypeSpec
+	file *ast.File
+	pkg  *goPackage
+}
+
+type generator struct {
+	root        string
+	fset        *token.FileSet
+	doc         *Document
+	packages    map[string]*goPackage // import path → package
+	names       map[string]string     // import path → package name
+	controllers *goPackage
+}
+
+// Generate builds the OpenAPI document of the source tree at root.
+func Generate(root string) (*Document, error) {
+	g := &generator{
+		root:     root,
+		fset:     token.NewFileSet(),
+		packages: map[string]*goPackage{},
+		names:    map[string]string{},
+		doc: &Document{
+			OpenAPI: openAPIVersion,
+			Paths:   map[string]*PathItem{},
+			Components: Components{
+				Schemas:         map[string]*Schema{},
+				SecuritySchemes: map[string]*SecurityScheme{},
+			},
+		},
+	}
+
+	routes, err := g.parseRoutes(filepath.Join(root, "routers", "router.go"))
+	if err != nil {
+		return nil, err
+	}
+	g.controllers, err = g.loadPackage(modulePath + "/controllers")
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := g.parseAnnotations(filepath.Join(root, "controllers"))
+	if err != nil {
+		return nil, err
+	}
+
+	operationIds := assignOperationIds(routes, annotations)
+	tags := map[string]bool{}
+	for i, r := range routes {
+		ann := annotations[r.fn]
+		op := g.buildOperation(r, ann)
+		op.OperationId = operationIds[i]
+		for _, tag := range op.Tags {
+			tags[tag] = true
+		}
+		item := g.doc.Paths[r.path]
+		if item == nil {
+			item = &PathItem{}
+			g.doc.Paths[r.path] = item
+		}
+		(*item)[r.method] = op
+	}
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.doc.Tags = append(g.doc.Tags, Tag{Name: name})
+	}
+	return g.doc, nil
+}
+
+// Bytes encodes the document as indented JSON.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ── Routes ──────────────────────────────────────────────────────────────
+
+// parseRoutes reads the beego.Router calls of router.go, in order, and the
+// API description from its package comment.
+func (g *generator) parseRoutes(filename string) ([]*route, error) {
+	file, err := parser.ParseFile(g.fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if file.Doc != nil {
+		g.parseInfo(file.Doc)
+	}
+
+	routes := []*route{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 3 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Router" {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "beego" {
+			return true
+		}
+		routePath, err1 := stringLit(call.Args[0])
+		mapping, err2 := stringLit(call.Args[2])
+		if err1 != nil || err2 != nil {
+			return true
+		}
+
+		openAPIPath, params := convertPath(routePath)
+		for _, pair := range strings.Split(mapping, ";") {
+			method, fn, found := strings.Cut(pair, ":")
+			if !found {
+				continue
+			}
+			methods := []string{strings.ToLower(method)}
+			if method == "*" {
+				methods = []string{"get", "post"}
+			}
+			for _, m := range methods {
+				routes = append(routes, &route{path: openAPIPath, params: params, method: m, fn: fn})
+			}
+		}
+		return true
+	})
+	return routes, nil
+}
+
+func stringLit(expr ast.Expr) (string, error) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("not a string literal")
+	}
+	return strconv.Unquote(lit.Value)
+}
+
+// convertPath turns beego's :param and :param(regexp) segments into
+// OpenAPI's {param}.
+func convertPath(routePath string) (string, []string) {
+	params := []string{}
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(segment, ":"), "(")
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// parseInfo reads @APIVersion, @Title, @Description, @Contact and
+// @SecurityDefinition from the routers package comment.
+func (g *generator) parseInfo(doc *ast.CommentGroup) {
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		key, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		rest = strings.TrimSpace(rest)
+		switch key {
+		case "@APIVersion":
+			g.doc.Info.Version = rest
+		case "@Title":
+			g.doc.Info.Title = rest
+		case "@Description":
+			g.doc.Info.Description = rest
+		case "@Contact":
+			g.doc.Info.Contact = &Contact{Email: rest}
+		case "@SecurityDefinition":
+			fields := strings.Fields(rest)
+			if len(fields) >= 4 {
+				g.doc.Components.SecuritySchemes[fields[0]] = &SecurityScheme{Type: fields[1], Name: fields[2], In: fields[3]}
+			}
+		}
+	}
+}
+
+// assignOperationIds names each route after its controller method. A
+// method routed more than once keeps its name on the path of its @router
+// annotation, or else its first path, and numbers the others.
+func assignOperationIds(routes []*route, annotations map[string]*annotation) []string {
+	primary := map[string]int{}
+	for i, r := range routes {
+		ann := annotations[r.fn]
+		if ann != nil && ann.router != "" && r.path == "/v1"+ann.router {
+			if _, ok := primary[r.fn]; !ok {
+				primary[r.fn] = i
+			}
+		}
+	}
+	for i, r := range routes {
+		if _, ok := primary[r.fn]; !ok {
+			primary[r.fn] = i
+		}
+	}
+
+	ids := make([]string, len(routes))
+	counts := map[string]int{}
+	for i, r := range routes {
+		if primary[r.fn] == i {
+			ids[i] = r.fn
+			continue
+		}
+		counts[r.fn]++
+		ids[i] = fmt.Sprintf("%s_%d", r.fn, counts[r.fn]+1)
+	}
+	return ids
+}
+
+// ── Annotations ─────────────────────────────────────────────────────────
+
+// parseAnnotations reads the annotations of the controller methods in dir.
+func (g *generator) parseAnnotations(dir string) (map[string]*annotation, error) {
+	filenames, err := goFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]*annotation{}
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(g.fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Doc == nil {
+				continue
+			}
+			ann := parseAnnotation(fn.Doc)
+			ann.file = file
+			ann.wrapped = callsResponseOk(fn)
+			annotations[fn.Name.Name] = ann
+		}
+	}
+	return annotations, nil
+}
+
+func parseAnnotation(doc *ast.CommentGroup) *annotation {
+	ann := &annotation{}
+	var description []string
+	inDescription := false
+	for _, comment := range doc.List {
+		line := strings.TrimPrefix(strings.TrimPrefix(comment.Text, "//"), " ")
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "@") {
+			if inDescription {
+				description = append(description, strings.TrimRight(line, " \t"))
+			}
+			continue
+		}
+
+		inDescription = false
+		key, rest, _ := strings.Cut(trimmed, " ")
+		rest = strings.TrimSpace(rest)
+		switch key {
+		case "@Tag":
+			ann.tags = append(ann.tags, rest)
+		case "@Description":
+			description = []string{rest}
+			inDescription = true
+		case "@Param":
+			if param := parseParam(rest); param != nil {
+				ann.params = append(ann.params, param)
+			}
+		case "@Success", "@Failure":
+			if response := parseResponse(rest); response != nil {
+				ann.responses = append(ann.responses, response)
+			}
+		case "@router":
+			ann.router, _, _ = strings.Cut(rest, " ")
+		}
+	}
+	for len(description) > 0 && description[len(description)-1] == "" {
+		description = description[:len(description)-1]
+	}
+	ann.description = strings.Join(description, "\n")
+	return ann
+}
+
+// splitAnnotation splits an annotation into fields, keeping quoted text
+// together and without its quotes.
+func splitAnnotation(s string) []string {
+	fields := []string{}
+	var field strings.Builder
+	quoted, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if started {
+				fields = append(fields, field.String())
+				field.Reset()
+				started = false
+			}
+		default:
+			field.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+func parseParam(s string) *paramAnnotation {
+	fields := splitAnnotation(s)
+	if len(fields) < 4 {
+		return nil
+	}
+	required, _ := strconv.ParseBool(fields[3])
+	return &paramAnnotation{
+		name:        fields[0],
+		in:          fields[1],
+		typ:         fields[2],
+		required:    required,
+		description: strings.Join(fields[4:], " "),
+	}
+}
+
+func parseResponse(s string) *responseAnnotation {
+	fields := splitAnnotation(s)
+	if len(fields) == 0 {
+		return nil
+	}
+	response := &responseAnnotation{code: fields[0]}
+	rest := fields[1:]
+	if len(rest) >= 2 && strings.HasPrefix(rest[0], "{") && strings.HasSuffix(rest[0], "}") {
+		response.kind = strings.Trim(rest[0], "{}")
+		response.typ = rest[1]
+		rest = rest[2:]
+	}
+	response.description = strings.Join(rest, " ")
+	return response
+}
+
+func callsResponseOk(fn *ast.FuncDecl) bool {
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "ResponseOk" {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// ── Operations ──────────────────────────────────────────────────────────
+
+func (g *generator) buildOperation(r *route, ann *annotation) *Operation {
+	op := &Operation{Responses: map[string]*Response{}}
+	annotated := map[string]bool{}
+	if ann != nil {
+		op.Tags = ann.tags
+		op.Description = ann.description
+		var form *Schema
+		for _, p := range ann.params {
+			switch p.in {
+			case "body":
+				op.RequestBody = &RequestBody{
+					Description: p.description,
+					Required:    p.required,
+					Content:     map[string]*MediaType{"application/json": {Schema: g.typeSchema(p.typ, ann.file)}},
+				}
+			case "formData":
+				if form == nil {
+					form = &Schema{Type: "object", Properties: map[string]*Schema{}}
+					op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"multipart/form-data": {Schema: form}}}
+				}
+				schema := paramSchema(p.typ)
+				schema.Description = p.description
+				form.Properties[p.name] = schema
+			case "query", "header", "path":
+				annotated[p.name] = true
+				op.Parameters = append(op.Parameters, &Parameter{
+					Name:        p.name,
+					In:          p.in,
+					Description: p.description,
+					Required:    p.required || p.in == "path",
+					Schema:      paramSchema(p.typ),
+				})
+			}
+		}
+		for _, response := range ann.responses {
+			op.Responses[response.code] = g.buildResponse(response, ann)
+		}
+	}
+	for _, name := range r.params {
+		if !annotated[name] {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	if len(op.Responses) == 0 {
+		response := &Response{Description: http.StatusText(http.StatusOK)}
+		if ann != nil && ann.wrapped {
+			response.Content = map[string]*MediaType{"application/json": {Schema: g.ref(g.controllers, "Response")}}
+		}
+		op.Responses["200"] = response
+	}
+	return op
+}
+
+func (g *generator) buildResponse(r *responseAnnotation, ann *annotation) *Response {
+	response := &Response{Description: r.description}
+	if response.Description == "" {
+		if code, err := strconv.Atoi(r.code); err == nil {
+			response.Description = http.StatusText(code)
+		}
+	}
+
+	switch r.kind {
+	case "string":
+		response.Content = map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	case "stream":
+		response.Content = map[string]*MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+	case "file":
+		response.Content = map[string]*MediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case "":
+	default:
+		schema := g.typeSchema(r.typ, ann.file)
+		if r.kind == "array" {
+			schema = &Schema{Type: "array", Items: schema}
+		}
+		if ann.wrapped && r.typ != "Response" && r.typ != "controllers.Response" {
+			schema = &Schema{AllOf: []*Schema{
+				g.ref(g.controllers, "Response"),
+				{Type: "object", Properties: map[string]*Schema{"data": schema}},
+			}}
+		}
+		response.Content = map[string]*MediaType{"application/json": {Schema: schema}}
+	}
+	return response
+}
+
+// paramSchema is the schema of a query, header, path or form parameter.
+func paramSchema(typ string) *Schema {
+	switch typ {
+	case "int", "integer", "int64":
+		return &Schema{Type: "integer"}
+	case "number", "float", "float64":
+		return &Schema{Type: "number"}
+	case "bool", "boolean":
+		return &Schema{Type: "boolean"}
+	case "file":
+		return &Schema{Type: "string", Format: "binary"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// typeSchema is the schema of a type named in an annotation of file.
+func (g *generator) typeSchema(typ string, file *ast.File) *Schema {
+	if typ == "object" || typ == "" {
+		return &Schema{Type: "object"}
+	}
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return &Schema{Type: "object"}
+	}
+	if schema := g.schemaOf(expr, g.controllers, file); schema != nil {
+		return schema
+	}
+	return &Schema{Type: "object"}
+}
+
+// ── Schemas ─────────────────────────────────────────────────────────────
+
+var basicSchemas = map[string]Schema{
+	"string":  {Type: "string"},
+	"bool":    {Type: "boolean"},
+	"int":     {Type: "integer"},
+	"int8":    {Type: "integer"},
+	"int16":   {Type: "integer"},
+	"int32":   {Type: "integer", Format: "int32"},
+	"int64":   {Type: "integer", Format: "int64"},
+	"uint":    {Type: "integer"},
+	"uint8":   {Type: "integer"},
+	"uint16":  {Type: "integer"},
+	"uint32":  {Type: "integer", Format: "int32"},
+	"uint64":  {Type: "integer", Format: "int64"},
+	"byte":    {Type: "integer"},
+	"rune":    {Type: "integer", Format: "int32"},
+	"float32": {Type: "number", Format: "float"},
+	"float64": {Type: "number", Format: "double"},
+	"error":   {Type: "string"},
+	"any":     {},
+}
+
+// wellKnownSchemas are the schemas of standard library types that encode
+// differently from their declaration.
+var wellKnownSchemas = map[string]Schema{
+	"time.Time":       {Type: "string", Format: "date-time"},
+	"time.Duration":   {Type: "integer", Format: "int64"},
+	"json.RawMessage": {},
+	"json.Number":     {Type: "number"},
+}
+
+// schemaOf is the schema of the type expression expr, appearing in file of
+// pkg. It returns nil for types JSON cannot encode.
+func (g *generator) schemaOf(expr ast.Expr, pkg *goPackage, file *ast.File) *Schema {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if basic, ok := basicSchemas[t.Name]; ok {
+			return &basic
+		}
+		return g.ref(pkg, t.Name)
+	case *ast.SelectorExpr:
+		qualifier, ok := t.X.(*ast.Ident)
+		if !ok {
+			return &Schema{}
+		}
+		if known, ok := wellKnownSchemas[qualifier.Name+"."+t.Sel.Name]; ok {
+			return &known
+		}
+		if qualifier.Name == pkg.name {
+			return g.ref(pkg, t.Sel.Name)
+		}
+		target := g.importedPackage(file, qualifier.Name)
+		if target == nil {
+			return &Schema{Type: "object"}
+		}
+		return g.ref(target, t.Sel.Name)
+	case *ast.StarExpr:
+		return g.schemaOf(t.X, pkg, file)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" && t.Len == nil {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		items := g.schemaOf(t.Elt, pkg, file)
+		if items == nil {
+			return nil
+		}
+		return &Schema{Type: "array", Items: items}
+	case *ast.MapType:
+		values := g.schemaOf(t.Value, pkg, file)
+		if values == nil {
+			return nil
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}
+	case *ast.InterfaceType:
+		return &Schema{}
+	case *ast.StructType:
+		return g.structSchema(t, pkg, file)
+	case *ast.IndexExpr:
+		return g.schemaOf(t.X, pkg, file)
+	case *ast.IndexListExpr:
+		return g.schemaOf(t.X, pkg, file)
+	case *ast.FuncType, *ast.ChanType:
+		return nil
+	default:
+		return &Schema{}
+	}
+}
+
+// ref returns a reference to the schema of pkg's type name, adding it to
+// the components on first use.
+func (g *generator) ref(pkg *goPackage, name string) *Schema {
+	decl := pkg.types[name]
+	if decl == nil {
+		return &Schema{Type: "object"}
+	}
+	key := pkg.name + "." + name
+	if _, ok := g.doc.Components.Schemas[key]; !ok {
+		// The placeholder ends the recursion of self-referencing types.
+		g.doc.Components.Schemas[key] = &Schema{}
+		schema := g.schemaOf(decl.spec.Type, decl.pkg, decl.file)
+		if schema == nil {
+			schema = &Schema{}
+		}
+		g.doc.Components.Schemas[key] = schema
+	}
+	return &Schema{Ref: schemaRefBase + key}
+}
+
+// structSchema is the schema of a struct's JSON encoding. Embedded structs
+// without a JSON name contribute their fields.
+func (g *generator) structSchema(st *ast.StructType, pkg *goPackage, file *ast.File) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range st.Fields.List {
+		name, skip := "", false
+		if field.Tag != nil {
+			if tag, err := strconv.Unquote(field.Tag.Value); err == nil {
+				jsonName, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+				name, skip = jsonName, jsonName == "-"
+			}
+		}
+		if skip {
+			continue
+		}
+
+		if len(field.Names) == 0 {
+			if name == "" {
+				if embedded := g.embeddedStruct(field.Type, pkg, file); embedded != nil {
+					for key, value := range embedded.Properties {
+						if _, ok := schema.Properties[key]; !ok {
+							schema.Properties[key] = value
+						}
+					}
+				}
+				continue
+			}
+			if fieldSchema := g.schemaOf(field.Type, pkg, file); fieldSchema != nil {
+				schema.Properties[name] = fieldSchema
+			}
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			fieldName := name
+			if fieldName == "" {
+				fieldName = ident.Name
+			}
+			if fieldSchema := g.schemaOf(field.Type, pkg, file); fieldSchema != nil {
+				schema.Properties[fieldName] = fieldSchema
+			}
+		}
+	}
+	return schema
+}
+
+// embeddedStruct resolves the struct of an embedded field, or nil when it
+// is not a struct declared in the sources.
+func (g *generator) embeddedStruct(expr ast.Expr, pkg *goPackage, file *ast.File) *Schema {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var decl *typeDecl
+	switch t := expr.(type) {
+	case *ast.Ident:
+		decl = pkg.types[t.Name]
+	case *ast.SelectorExpr:
+		if qualifier, ok := t.X.(*ast.Ident); ok {
+			if target := g.importedPackage(file, qualifier.Name); target != nil {
+				decl = target.types[t.Sel.Name]
+			}
+		}
+	}
+	if decl == nil {
+		return nil
+	}
+	st, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	return g.structSchema(st, decl.pkg, decl.file)
+}
+
+// ── Packages ────────────────────────────────────────────────────────────
+
+// importedPackage loads the package file imports as qualifier, or returns
+// nil when it cannot be found.
+func (g *generator) importedPackage(file *ast.File, qualifier string) *goPackage {
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			if spec.Name.Name != qualifier {
+				continue
+			}
+		} else if g.packageName(importPath) != qualifier {
+			continue
+		}
+		pkg, err := g.loadPackage(importPath)
+		if err != nil {
+			return nil
+		}
+		return pkg
+	}
+	return nil
+}
+
+func (g *generator) packageDir(importPath string) (string, error) {
+	if importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/") {
+		return filepath.Join(g.root, strings.TrimPrefix(importPath, modulePath)), nil
+	}
+	p, err := build.Import(importPath, g.root, build.FindOnly)
+	if err != nil {
+		return "", err
+	}
+	return p.Dir, nil
+}
+
+// packageName reads the name of a package from its package clause.
+func (g *generator) packageName(importPath string) string {
+	if name, ok := g.names[importPath]; ok {
+		return name
+	}
+	name := path.Base(importPath)
+	if dir, err := g.packageDir(importPath); err == nil {
+		if filenames, err := goFiles(dir); err == nil && len(filenames) > 0 {
+			if file, err := parser.ParseFile(g.fset, filenames[0], nil, parser.PackageClauseOnly); err == nil {
+				name = file.Name.Name
+			}
+		}
+	}
+	g.names[importPath] = name
+	return name
+}
+
+// loadPackage parses the type declarations of a package.
+func (g *generator) loadPackage(importPath string) (*goPackage, error) {
+	if pkg, ok := g.packages[importPath]; ok {
+		return pkg, nil
+	}
+	dir, err := g.packageDir(importPath)
+	if err != nil {
+		return nil, err
+	}
+	filenames, err := goFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &goPackage{name: g.packageName(importPath), types: map[string]*typeDecl{}}
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(g.fset, filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if _, ok := pkg.types[typeSpec.Name.Name]; !ok {
+					pkg.types[typeSpec.Name.Name] = &typeDecl{spec: typeSpec, file: file, pkg: pkg}
+				}
+			}
+		}
+	}
+	g.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// goFiles lists the non-test Go files of dir, sorted.
+func goFiles(dir string) ([]string, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, "_test.go") {
+			files = append(files, filename)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
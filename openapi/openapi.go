@@ -0,0 +1,117 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openapi generates the OpenAPI 3 document of the gateway from the
+// sources: the routes registered in routers/router.go, the @Title, @Tag,
+// @Description, @Param, @Success and @Failure annotations of the controller
+// methods they name, and the Go types those annotations reference.
+//
+// The document is checked in as openapi.json and embedded as Spec. After
+// changing routes or annotations, regenerate it with
+//
+//	go generate ./openapi
+//
+// TestSpecUpToDate fails while the checked-in document is stale.
+package openapi
+
+import (
+	_ "embed"
+)
+
+//go:generate go test -run TestSpecUpToDate -update
+
+// Spec is the generated OpenAPI document, served at /v1/openapi.json.
+//
+//go:embed openapi.json
+var Spec []byte
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Version     string   `json:"version"`
+	Contact     *Contact `json:"contact,omitempty"`
+}
+
+type Contact struct {
+	Email string `json:"email,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations of a path by lowercase HTTP method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationId string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	In   string `json:"in,omitempty"`
+}
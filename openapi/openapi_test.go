@@ -0,0 +1,115 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json")
+
+func TestSpecUpToDate(t *testing.T) {
+	doc, err := Generate("..")
+	if err != nil {
+		t.Fatalf("Generate() = %v", err)
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() = %v", err)
+	}
+	if *update {
+		if err = os.WriteFile("openapi.json", data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if !bytes.Equal(data, Spec) {
+		t.Fatal("openapi.json is stale, run: go generate ./openapi")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate("..")
+	if err != nil {
+		t.Fatalf("Generate() = %v", err)
+	}
+	if doc.OpenAPI != openAPIVersion || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Errorf("info = %+v", doc.Info)
+	}
+
+	operation := func(path string, method string) *Operation {
+		t.Helper()
+		item := doc.Paths[path]
+		if item == nil || (*item)[method] == nil {
+			t.Fatalf("%s %s is missing", method, path)
+		}
+		return (*item)[method]
+	}
+
+	chat := operation("/v1/chat/completions", "post")
+	if chat.RequestBody == nil || chat.RequestBody.Content["application/json"].Schema.Ref != schemaRefBase+"openai.ChatCompletionRequest" {
+		t.Errorf("chat completions request body = %+v", chat.RequestBody)
+	}
+	if operation("/v1/chat", "post").OperationId != "ChatCompletions" || chat.OperationId == "ChatCompletions" {
+		t.Errorf("chat operation ids: /v1/chat keeps the name, got %q for /v1/chat/completions", chat.OperationId)
+	}
+	if request := doc.Components.Schemas["openai.ChatCompletionRequest"]; request == nil || request.Properties["messages"] == nil {
+		t.Errorf("openai.ChatCompletionRequest = %+v", request)
+	}
+
+	messages := operation("/v1/messages", "post")
+	if messages.Tags[0] != "Anthropic Compatible API" {
+		t.Errorf("messages tags = %v", messages.Tags)
+	}
+	operation("/v1/zap", "get")
+	operation("/v1/zap", "post")
+
+	// Admin endpoints answer inside controllers.Response.
+	webhooks := operation("/v1/get-webhooks", "get")
+	schema := webhooks.Responses["200"].Content["application/json"].Schema
+	if len(schema.AllOf) != 2 || schema.AllOf[0].Ref != schemaRefBase+"controllers.Response" {
+		t.Fatalf("get-webhooks response = %+v", schema)
+	}
+	if data := schema.AllOf[1].Properties["data"]; data.Type != "array" || data.Items.Ref != schemaRefBase+"object.Webhook" {
+		t.Errorf("get-webhooks data = %+v", data)
+	}
+	if webhook := doc.Components.Schemas["object.Webhook"]; webhook == nil || webhook.Properties["secret"] == nil || webhook.Properties["events"].Ref != schemaRefBase+"object.StringSlice" {
+		t.Errorf("object.Webhook = %+v", webhook)
+	}
+	if slice := doc.Components.Schemas["object.StringSlice"]; slice == nil || slice.Type != "array" || slice.Items.Type != "string" {
+		t.Errorf("object.StringSlice = %+v", slice)
+	}
+
+	callback := operation("/v1/wecom-bot/callback/{botId}", "post")
+	if len(callback.Parameters) == 0 || callback.Parameters[len(callback.Parameters)-1].In != "path" {
+		t.Errorf("callback parameters = %+v", callback.Parameters)
+	}
+}
+
+func TestSplitAnnotation(t *testing.T) {
+	got := splitAnnotation(`body   body  object.Webhook true "The details of the webhook"`)
+	want := []string{"body", "body", "object.Webhook", "true", "The details of the webhook"}
+	if len(got) != len(want) {
+		t.Fatalf("splitAnnotation() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// HttpClientOptions tunes an HTTP client for one upstream. Zero values keep
+// Go's transport defaults; a negative KeepAlive disables keep-alives.
+type HttpClientOptions struct {
+	Timeout      time.Duration
+	MaxIdleConns int
+	KeepAlive    time.Duration
+	ProxyUrl     string // http://, https:// or socks5:// proxy
+	CaCert       string // PEM certificates trusted on top of the system roots
+}
+
+// NewHttpClient builds an HTTP client with options applied.
+func NewHttpClient(options HttpClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: options.KeepAlive}
+	transport.DialContext = dialer.DialContext
+	if options.KeepAlive < 0 {
+		transport.DisableKeepAlives = true
+	}
+	if options.MaxIdleConns > 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+		transport.MaxIdleConnsPerHost = options.MaxIdleConns
+	}
+
+	if options.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(options.ProxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", options.ProxyUrl, err)
+		}
+		switch proxyUrl.Scheme {
+		case "http", "https":
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		case "socks5", "socks5h":
+			socksDialer, err := proxy.FromURL(proxyUrl, dialer)
+			if err != nil {
+				return nil, err
+			}
+			transport.Proxy = nil
+			transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				if contextDialer, ok := socksDialer.(proxy.ContextDialer); ok {
+					return contextDialer.DialContext(ctx, network, addr)
+				}
+				return socksDialer.Dial(network, addr)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+		}
+	}
+
+	if options.CaCert != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM([]byte(options.CaCert)) {
+			return nil, fmt.Errorf("no valid PEM certificate in CA cert")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	return &http.Client{Transport: &RateLimitTransport{Base: transport}, Timeout: options.Timeout}, nil
+}
+
+// requestDefaultsTransport sets headers on every request and adds default
+// fields to JSON request bodies; fields the request sets itself win.
+type requestDefaultsTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+	body    map[string]json.RawMessage
+}
+
+// closeIdleConnections closes the idle connections of a wrapped transport.
+func closeIdleConnections(base http.RoundTripper) {
+	if closer, ok := base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func (t *requestDefaultsTransport) CloseIdleConnections() {
+	closeIdleConnections(t.base)
+}
+
+// WithRequestDefaults returns a copy of client that sends headers with every
+// request and merges body into its JSON request bodies.
+func WithRequestDefaults(client *http.Client, headers map[string]string, body map[string]json.RawMessage) *http.Client {
+	if len(headers) == 0 && len(body) == 0 {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &requestDefaultsTransport{base: base, headers: headers, body: body}
+	return &wrapped
+}
+
+func (t *requestDefaultsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if len(t.body) > 0 && req.Body != nil && req.Body != http.NoBody &&
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		data = mergeJsonDefaults(data, t.body)
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.ContentLength = int64(len(data))
+	}
+	return t.base.RoundTrip(req)
+}
+
+// mergeJsonDefaults adds the fields of defaults missing from the JSON object
+// data. Bodies that are not JSON objects are returned unchanged.
+func mergeJsonDefaults(data []byte, defaults map[string]json.RawMessage) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data
+	}
+	for name, value := range defaults {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return data
+	}
+	return merged
+}
@@ -29,6 +29,9 @@ import (
 var (
 	DefaultHttpClient *http.Client
 	ProxyHttpClient   *http.Client
+	// ProxyUrl is the socks5 proxy ProxyHttpClient goes through, empty when
+	// it connects directly.
+	ProxyUrl string
 )
 
 func InitHttpClient() {
@@ -59,11 +62,11 @@ func isAddressOpen(address string) bool {
 func getProxyHttpClient() *http.Client {
 	socks5Proxy := conf.GetConfigString("socks5Proxy")
 	if socks5Proxy == "" {
-		return &http.Client{}
+		return &http.Client{Transport: &RateLimitTransport{Base: http.DefaultTransport}}
 	}
 
 	if !isAddressOpen(socks5Proxy) {
-		return &http.Client{}
+		return &http.Client{Transport: &RateLimitTransport{Base: http.DefaultTransport}}
 	}
 
 	// https://stackoverflow.com/questions/33585587/creating-a-go-socks5-client
@@ -72,9 +75,10 @@ func getProxyHttpClient() *http.Client {
 		panic(err)
 	}
 
+	ProxyUrl = "socks5://" + socks5Proxy
 	tr := &http.Transport{Dial: dialer.Dial, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	return &http.Client{
-		Transport: tr,
+		Transport: &RateLimitTransport{Base: tr},
 	}
 }
 
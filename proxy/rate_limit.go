@@ -0,0 +1,129 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider SDKs turn an upstream 429 into an error without its headers. The
+// transport of the upstream clients therefore notes how long a rate-limiting
+// provider asked to wait on the RateLimitRecorder of the request's context.
+
+// RateLimitRecorder holds the last rate limit response of the upstream
+// requests made with its context.
+type RateLimitRecorder struct {
+	mu         sync.Mutex
+	limited    bool
+	retryAfter time.Duration
+}
+
+type rateLimitRecorderKey struct{}
+
+// WithRateLimitRecorder returns a context whose upstream requests report
+// their rate limit responses to the returned recorder.
+func WithRateLimitRecorder(ctx context.Context) (context.Context, *RateLimitRecorder) {
+	recorder := &RateLimitRecorder{}
+	return context.WithValue(ctx, rateLimitRecorderKey{}, recorder), recorder
+}
+
+// RetryAfter returns how long the provider asked to wait, zero when it did
+// not say, and whether any upstream request was rate limited.
+func (r *RateLimitRecorder) RetryAfter() (time.Duration, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.retryAfter, r.limited
+}
+
+func (r *RateLimitRecorder) record(retryAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limited = true
+	r.retryAfter = retryAfter
+}
+
+// RateLimitTransport reports the 429 responses of Base to the recorder of
+// the request's context. The upstream clients of this package use it.
+type RateLimitTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	if recorder, ok := req.Context().Value(rateLimitRecorderKey{}).(*RateLimitRecorder); ok {
+		recorder.record(ParseRetryAfter(resp.Header, time.Now()))
+	}
+	return resp, err
+}
+
+func (t *RateLimitTransport) CloseIdleConnections() {
+	closeIdleConnections(t.Base)
+}
+
+// retryAfterResetHeaders give when a provider's rate limit resets, as a
+// duration ("6m0s", OpenAI) or a time (RFC 3339, Anthropic).
+var retryAfterResetHeaders = []string{
+	"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens",
+	"Anthropic-Ratelimit-Requests-Reset", "Anthropic-Ratelimit-Tokens-Reset",
+}
+
+// ParseRetryAfter returns how long a 429 response received at now asks to
+// wait: its retry-after-ms or Retry-After header (seconds or an HTTP date),
+// or else the latest reset of its rate limit headers. It is zero when the
+// response does not say.
+func ParseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After-Ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if at, err := http.ParseTime(value); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+
+	var latest time.Duration
+	for _, name := range retryAfterResetHeaders {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		reset, err := time.ParseDuration(value)
+		if err != nil {
+			if at, err := time.Parse(time.RFC3339, value); err == nil {
+				reset = at.Sub(now)
+			}
+		}
+		if reset > latest {
+			latest = reset
+		}
+	}
+	return latest
+}
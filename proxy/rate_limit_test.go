@@ -0,0 +1,90 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"none", nil, 0},
+		{"seconds", map[string]string{"Retry-After": "7"}, 7 * time.Second},
+		{"date", map[string]string{"Retry-After": now.Add(30 * time.Second).Format(http.TimeFormat)}, 30 * time.Second},
+		{"past date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+		{"milliseconds first", map[string]string{"Retry-After-Ms": "1500", "Retry-After": "2"}, 1500 * time.Millisecond},
+		{"openai resets", map[string]string{"X-Ratelimit-Reset-Requests": "1s", "X-Ratelimit-Reset-Tokens": "6m0s"}, 6 * time.Minute},
+		{"anthropic reset", map[string]string{"Anthropic-Ratelimit-Tokens-Reset": now.Add(20 * time.Second).Format(time.RFC3339)}, 20 * time.Second},
+		{"garbage", map[string]string{"Retry-After": "soon"}, 0},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		for name, value := range tc.header {
+			header.Set(name, value)
+		}
+		if got := ParseRetryAfter(header, now); got != tc.want {
+			t.Errorf("%s: ParseRetryAfter() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "12")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &RateLimitTransport{Base: http.DefaultTransport}}
+
+	get := func(ctx context.Context, path string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	ctx, limits := WithRateLimitRecorder(context.Background())
+	get(ctx, "/ok")
+	if _, limited := limits.RetryAfter(); limited {
+		t.Fatal("a 200 response was recorded as rate limited")
+	}
+	get(ctx, "/limited")
+	if retryAfter, limited := limits.RetryAfter(); !limited || retryAfter != 12*time.Second {
+		t.Errorf("RetryAfter() = %v, %v, want 12s, true", retryAfter, limited)
+	}
+
+	// Requests without a recorder pass through.
+	get(context.Background(), "/limited")
+	var none *RateLimitRecorder
+	if _, limited := none.RetryAfter(); limited {
+		t.Error("a nil recorder reported a rate limit")
+	}
+}
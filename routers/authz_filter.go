@@ -38,6 +38,10 @@ func AuthzFilter(ctx *context.Context) {
 			controllers.DenyRequest(ctx)
 		}
 	}
+	if policy := getRoutePolicy(urlPath); policy != nil {
+		routePolicyFilter(ctx, policy)
+		return
+	}
 	permissionFilter(ctx)
 }
 
@@ -0,0 +1,176 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/beego/beego/context"
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/controllers"
+	"github.com/hanzoai/cloud/object"
+)
+
+// requestBodyTooLargeKey is the context data key set when a body without a
+// Content-Length turned out to exceed its limit while beego buffered it.
+const requestBodyTooLargeKey = "requestBodyTooLarge"
+
+// requestBodyLimit is the maximum body size of a group of endpoints, in
+// megabytes unless overridden by its config key.
+type requestBodyLimit struct {
+	paths     []string
+	configKey string
+	defaultMB int64
+	// deferred bodies are decoded by the handler straight from the
+	// connection instead of being buffered by beego first.
+	deferred bool
+}
+
+// requestBodyLimits are the per-endpoint limits. Other endpoints are held to
+// maxRequestBodyMB, and multipart uploads to maxUploadFileMB.
+var requestBodyLimits = []requestBodyLimit{
+	{
+		// Chat requests carry images and long conversations.
+		paths:     []string{"/v1/chat", "/v1/chat/completions", "/v1/completions", "/v1/messages"},
+		configKey: "maxChatRequestBodyMB",
+		defaultMB: 32,
+		deferred:  true,
+	},
+	{
+		paths:     []string{"/v1/embeddings", "/v1/rerank"},
+		configKey: "maxEmbeddingsRequestBodyMB",
+		defaultMB: 8,
+		deferred:  true,
+	},
+	{
+		// Parts of resumable uploads are streamed to storage by the handler.
+		paths:     []string{"/v1/upload-tree-file-part"},
+		configKey: "maxUploadPartMB",
+		defaultMB: 64,
+		deferred:  true,
+	},
+}
+
+// uploadFormOverhead is allowed on top of maxUploadFileMB for the other
+// fields and the boundaries of a multipart upload form.
+const uploadFormOverhead = 1 << 20
+
+// defaultMaxRequestBodyMB is the limit of the endpoints not listed in
+// requestBodyLimits, when maxRequestBodyMB is unset.
+const defaultMaxRequestBodyMB = 16
+
+// getRequestBodyLimit returns the limit in bytes for path, and whether its
+// body is decoded by the handler.
+func getRequestBodyLimit(path string) (int64, bool) {
+	// /v1/cloud/* is rewritten to /v1/* only after the body is read.
+	if strings.HasPrefix(path, "/v1/cloud/") {
+		path = "/v1/" + strings.TrimPrefix(path, "/v1/cloud/")
+	}
+	for _, limit := range requestBodyLimits {
+		for _, p := range limit.paths {
+			if path == p {
+				return megabytes(limit.configKey, limit.defaultMB), limit.deferred
+			}
+		}
+	}
+	return megabytes("maxRequestBodyMB", defaultMaxRequestBodyMB), false
+}
+
+func megabytes(configKey string, defaultMB int64) int64 {
+	mb := int64(conf.GetConfigInt(configKey))
+	if mb <= 0 {
+		mb = defaultMB
+	}
+	return mb << 20
+}
+
+// RequestBodyLimitFilter rejects request bodies over the limit of their
+// endpoint with a 413. It runs before beego buffers the body: bodies that
+// declare their length are rejected at once, others are cut off at the
+// limit and rejected by RequestBodyTooLargeFilter, or by the handler for
+// deferred bodies.
+func RequestBodyLimitFilter(ctx *context.Context) {
+	if ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead || ctx.Request.Body == nil {
+		return
+	}
+
+	limit, deferred := getRequestBodyLimit(ctx.Request.URL.Path)
+	if ctx.Input.IsUpload() {
+		// Beego spills multipart uploads to disk instead of buffering them.
+		limit, deferred = object.GetMaxUploadFileSize()+uploadFormOverhead, false
+	}
+	if ctx.Request.ContentLength > limit {
+		respondRequestBodyTooLarge(ctx, limit)
+		return
+	}
+
+	ctx.Request.Body = &limitedBody{
+		ReadCloser: http.MaxBytesReader(ctx.ResponseWriter, ctx.Request.Body, limit),
+		ctx:        ctx,
+	}
+	if deferred {
+		controllers.DeferRequestBody(ctx)
+	}
+}
+
+// RequestBodyTooLargeFilter rejects the requests whose body was cut off at
+// its limit while beego buffered it.
+func RequestBodyTooLargeFilter(ctx *context.Context) {
+	if limit, ok := ctx.Input.GetData(requestBodyTooLargeKey).(int64); ok {
+		respondRequestBodyTooLarge(ctx, limit)
+	}
+}
+
+// limitedBody notes in the context when the body hits its limit, since
+// beego ignores read errors when buffering bodies.
+type limitedBody struct {
+	io.ReadCloser
+	ctx *context.Context
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.ctx.Input.SetData(requestBodyTooLargeKey, tooLarge.Limit)
+	}
+	return n, err
+}
+
+// respondRequestBodyTooLarge writes a 413 in the error format of the
+// endpoint's API.
+func respondRequestBodyTooLarge(ctx *context.Context, limit int64) {
+	path := ctx.Request.URL.Path
+	logs.Info("request_body_too_large path=%s content_length=%d limit=%d", path, ctx.Request.ContentLength, limit)
+
+	message := fmt.Sprintf("Request body exceeds the limit of %d bytes", limit)
+	var body string
+	if strings.HasSuffix(path, "/v1/messages") {
+		body = fmt.Sprintf(`{"type":"error","error":{"type":"request_too_large","message":%q}}`, message)
+	} else {
+		body = fmt.Sprintf(`{"error":{"message":%q,"type":"invalid_request_error","code":"request_too_large"}}`, message)
+	}
+
+	// The rest of the body is not read; the connection cannot be reused.
+	ctx.ResponseWriter.Header().Set("Connection", "close")
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/json")
+	ctx.ResponseWriter.WriteHeader(http.StatusRequestEntityTooLarge)
+	ctx.ResponseWriter.Write([]byte(body))
+}
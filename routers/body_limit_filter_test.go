@@ -0,0 +1,114 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beego/beego/context"
+)
+
+func newBodyLimitContext(path string, body []byte, chunked bool) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "https://example.com"+path, bytes.NewReader(body))
+	if chunked {
+		req.ContentLength = -1
+	}
+	resp := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(resp, req)
+	return ctx, resp
+}
+
+func TestRequestBodyLimitFilterContentLength(t *testing.T) {
+	t.Setenv("maxChatRequestBodyMB", "1")
+
+	ctx, resp := newBodyLimitContext("/v1/chat/completions", make([]byte, 2<<20), false)
+	RequestBodyLimitFilter(ctx)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), `"code":"request_too_large"`) {
+		t.Errorf("body = %s", resp.Body.String())
+	}
+
+	ctx, resp = newBodyLimitContext("/v1/messages", make([]byte, 2<<20), false)
+	RequestBodyLimitFilter(ctx)
+	if resp.Code != http.StatusRequestEntityTooLarge || !strings.Contains(resp.Body.String(), `"type":"request_too_large"`) {
+		t.Errorf("Anthropic endpoint: status = %d, body = %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestRequestBodyLimitFilterUpload(t *testing.T) {
+	t.Setenv("maxUploadFileMB", "1")
+
+	ctx, resp := newBodyLimitContext("/v1/add-tree-file", make([]byte, 3<<20), false)
+	ctx.Request.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	RequestBodyLimitFilter(ctx)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", resp.Code)
+	}
+
+	ctx, resp = newBodyLimitContext("/v1/add-tree-file", make([]byte, 1<<20), false)
+	ctx.Request.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	RequestBodyLimitFilter(ctx)
+	if resp.Code != http.StatusOK {
+		t.Errorf("an upload within the limit was rejected with %d", resp.Code)
+	}
+}
+
+func TestRequestBodyLimitFilterChunked(t *testing.T) {
+	t.Setenv("maxRequestBodyMB", "1")
+
+	ctx, resp := newBodyLimitContext("/v1/update-store", make([]byte, 2<<20), true)
+	RequestBodyLimitFilter(ctx)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("a body without a length was rejected before it was read")
+	}
+	ctx.Input.CopyBody(64 << 20)
+	RequestBodyTooLargeFilter(ctx)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", resp.Code)
+	}
+
+	ctx, resp = newBodyLimitContext("/v1/update-store", []byte(`{"name":"store"}`), true)
+	RequestBodyLimitFilter(ctx)
+	ctx.Input.CopyBody(64 << 20)
+	RequestBodyTooLargeFilter(ctx)
+	if resp.Code != http.StatusOK || string(ctx.Input.RequestBody) != `{"name":"store"}` {
+		t.Errorf("status = %d, body = %q; want the small body passed through", resp.Code, ctx.Input.RequestBody)
+	}
+}
+
+func TestRequestBodyLimitFilterDefersModelBodies(t *testing.T) {
+	ctx, resp := newBodyLimitContext("/v1/cloud/chat", []byte(`{"model":"zen4"}`), false)
+	RequestBodyLimitFilter(ctx)
+	ctx.Input.CopyBody(64 << 20)
+
+	if resp.Code != http.StatusOK || len(ctx.Input.RequestBody) != 0 {
+		t.Fatalf("status = %d, buffered %q; want the body left to the handler", resp.Code, ctx.Input.RequestBody)
+	}
+	body, ok := ctx.Input.GetData("deferredRequestBody").(io.Reader)
+	if !ok {
+		t.Fatal("the body was not deferred")
+	}
+	if data, _ := io.ReadAll(body); string(data) != `{"model":"zen4"}` {
+		t.Errorf("deferred body = %q", data)
+	}
+}
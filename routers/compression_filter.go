@@ -0,0 +1,93 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"compress/gzip"
+	"strings"
+
+	"github.com/beego/beego/context"
+	"github.com/hanzoai/cloud/conf"
+)
+
+// defaultCompressedRoutes are the route prefixes whose responses are
+// compressed when responseCompressionRoutes is unset: the model APIs, whose
+// completions, listings and conversations are the largest JSON bodies served.
+var defaultCompressedRoutes = []string{
+	"/v1/chat",
+	"/v1/completions",
+	"/v1/messages",
+	"/v1/models",
+	"/v1/embeddings",
+	"/v1/rerank",
+	"/v1/conversations",
+}
+
+// defaultResponseCompressionMinBytes is the smallest body compressed when
+// responseCompressionMinBytes is unset; smaller ones gain too little.
+const defaultResponseCompressionMinBytes = 1024
+
+var compressedRoutes []string
+
+// InitResponseCompression configures gzip/deflate compression of response
+// bodies from app.conf / env:
+//
+//	responseCompressionRoutes   = /v1/chat,/v1/models ; route prefixes, "off" to disable
+//	responseCompressionMinBytes = 1024
+//	responseCompressionLevel    = 6                   ; 1 (fastest) to 9 (smallest)
+func InitResponseCompression() {
+	compressedRoutes = defaultCompressedRoutes
+	if routes := conf.GetConfigString("responseCompressionRoutes"); routes == "off" {
+		compressedRoutes = nil
+	} else if routes != "" {
+		compressedRoutes = nil
+		for _, route := range strings.Split(routes, ",") {
+			if route = strings.TrimSpace(route); route != "" {
+				compressedRoutes = append(compressedRoutes, route)
+			}
+		}
+	}
+
+	minBytes := conf.GetConfigInt("responseCompressionMinBytes")
+	if minBytes <= 0 {
+		minBytes = defaultResponseCompressionMinBytes
+	}
+	level := conf.GetConfigInt("responseCompressionLevel")
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	context.InitGzip(minBytes, level, []string{"GET", "POST"})
+}
+
+// CompressionFilter compresses the response body on the configured routes
+// for clients that accept gzip or deflate. Only bodies written whole with
+// Output.Body are compressed; SSE streams are written as events are
+// generated and are left alone.
+func CompressionFilter(ctx *context.Context) {
+	if !isCompressedRoute(ctx.Request.URL.Path) || strings.Contains(ctx.Request.Header.Get("Accept"), "text/event-stream") {
+		return
+	}
+	ctx.Output.EnableGzip = true
+	ctx.ResponseWriter.Header().Add("Vary", "Accept-Encoding")
+}
+
+func isCompressedRoute(path string) bool {
+	for _, route := range compressedRoutes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
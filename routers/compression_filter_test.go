@@ -0,0 +1,91 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beego/beego/context"
+)
+
+func serveCompressed(t *testing.T, method string, path string, header http.Header, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "https://example.com"+path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(resp, req)
+
+	CompressionFilter(ctx)
+	if err := ctx.Output.Body(body); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCompressionFilter(t *testing.T) {
+	t.Setenv("responseCompressionRoutes", "")
+	InitResponseCompression()
+	body := []byte(strings.Repeat(`{"id":"zen4","object":"model"},`, 100))
+	gzipHeader := http.Header{"Accept-Encoding": {"gzip, deflate"}}
+
+	resp := serveCompressed(t, http.MethodPost, "/v1/chat/completions", gzipHeader, body)
+	if resp.Header().Get("Content-Encoding") != "gzip" || resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers = %v, want a gzip body varying on Accept-Encoding", resp.Header())
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(reader); !bytes.Equal(decoded, body) {
+		t.Error("the decompressed body differs")
+	}
+
+	if resp = serveCompressed(t, http.MethodGet, "/v1/models", nil, body); resp.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed for a client that accepts no encoding")
+	}
+	if resp = serveCompressed(t, http.MethodGet, "/v1/get-stores", gzipHeader, body); resp.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed a route that is not configured")
+	}
+	if resp = serveCompressed(t, http.MethodGet, "/v1/models", gzipHeader, []byte(`{"data":[]}`)); resp.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed a body under the minimum size")
+	}
+	sseHeader := http.Header{"Accept-Encoding": {"gzip"}, "Accept": {"text/event-stream"}}
+	if resp = serveCompressed(t, http.MethodPost, "/v1/chat", sseHeader, body); resp.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed a response to a client expecting a stream")
+	}
+}
+
+func TestCompressionFilterRoutesConfig(t *testing.T) {
+	t.Setenv("responseCompressionRoutes", "/v1/get-stores")
+	InitResponseCompression()
+	if !isCompressedRoute("/v1/get-stores") || isCompressedRoute("/v1/chat") {
+		t.Errorf("routes = %q, want only /v1/get-stores", compressedRoutes)
+	}
+
+	t.Setenv("responseCompressionRoutes", "off")
+	InitResponseCompression()
+	if isCompressedRoute("/v1/chat") {
+		t.Error("compression was not turned off")
+	}
+}
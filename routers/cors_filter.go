@@ -19,10 +19,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/beego/beego/context"
+	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/conf"
-	"github.com/hanzoai/cloud/object"
+	"github.com/hanzoai/cloud/controllers"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 )
 
@@ -53,8 +56,93 @@ var allowedOriginSuffixes = []string{
 	"zenlm.org",
 }
 
+// publicModelRoutes are the model API routes that any origin may call with
+// an API key. They are answered with a wildcard origin and no credentials,
+// so browsers never send the IAM session cookie along.
+var publicModelRoutes = []string{
+	"/v1/chat",
+	"/v1/completions",
+	"/v1/messages",
+	"/v1/models",
+	"/v1/embeddings",
+	"/v1/rerank",
+	"/v1/conversations",
+	"/v1/feedback",
+}
+
+// corsOrigin is an entry of the configured origin allowlist. An empty
+// scheme or port matches any; a host starting with "*." matches its
+// subdomains.
+type corsOrigin struct {
+	scheme string
+	host   string
+	port   string
+}
+
+var corsAllowedOrigins []corsOrigin
+
+// InitCors configures the origins allowed on top of the first-party
+// domains from app.conf / env:
+//
+//	corsAllowedOrigins = https://app.example.com,https://*.example.org,*.example.net
+//
+// An entry without a scheme allows http and https. The same allowlist
+// gates cross-site ZAP WebSocket upgrades.
+func InitCors() {
+	corsAllowedOrigins = nil
+	for _, entry := range strings.Split(conf.GetConfigString("corsAllowedOrigins"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		origin, err := parseCorsOrigin(entry)
+		if err != nil {
+			logs.Warn("cors: ignoring corsAllowedOrigins entry %q: %v", entry, err)
+			continue
+		}
+		corsAllowedOrigins = append(corsAllowedOrigins, origin)
+	}
+	controllers.SetZapOriginCheck(isStaticAllowedOrigin)
+}
+
+func parseCorsOrigin(entry string) (corsOrigin, error) {
+	scheme := ""
+	if i := strings.Index(entry, "://"); i >= 0 {
+		scheme = strings.ToLower(entry[:i])
+		entry = entry[i+3:]
+	}
+	if scheme != "" && scheme != "http" && scheme != "https" {
+		return corsOrigin{}, fmt.Errorf("scheme must be http or https")
+	}
+	entry = strings.TrimSuffix(entry, "/")
+	u, err := url.Parse("http://" + entry)
+	if err != nil || u.Hostname() == "" || u.Host != entry {
+		return corsOrigin{}, fmt.Errorf("not an origin")
+	}
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return corsOrigin{}, fmt.Errorf("only a leading *. wildcard is supported")
+	}
+	return corsOrigin{scheme: scheme, host: host, port: u.Port()}, nil
+}
+
+func (o corsOrigin) matches(u *url.URL) bool {
+	if o.scheme != "" && o.scheme != u.Scheme {
+		return false
+	}
+	if o.port != "" && o.port != u.Port() {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if suffix, ok := strings.CutPrefix(o.host, "*"); ok {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == o.host
+}
+
 // isStaticAllowedOrigin checks the origin against the hard-coded allowlist
-// and also permits any localhost/127.0.0.1 origin (for local development).
+// and the configured corsAllowedOrigins, and also permits any
+// localhost/127.0.0.1 origin (for local development).
 func isStaticAllowedOrigin(origin string) bool {
 	u, err := url.Parse(origin)
 	if err != nil || u.Host == "" {
@@ -72,9 +160,25 @@ func isStaticAllowedOrigin(origin string) bool {
 			return true
 		}
 	}
+	for _, allowed := range corsAllowedOrigins {
+		if allowed.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPublicModelRoute(path string) bool {
+	for _, route := range publicModelRoutes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return true
+		}
+	}
 	return false
 }
 
+// setCorsHeaders allows origin to call the API. Credentials are allowed
+// unless origin is "*".
 func setCorsHeaders(ctx *context.Context, origin string) {
 	// Skip CORS when behind the KrakenD API gateway, which adds its own
 	// CORS headers.  KrakenD forwards requests with X-Forwarded-Host set;
@@ -92,7 +196,10 @@ func setCorsHeaders(ctx *context.Context, origin string) {
 		"Origin, X-Requested-With, Content-Type, Accept, Authorization, X-IAM-Org-Id, X-IAM-User-Id, X-IAM-User-Email, X-IAM-Project-Id, X-IAM-Env, X-API-Version, X-SDK-Name, X-SDK-Version",
 	)
 	ctx.Output.Header(headerExposeHeaders, "Content-Length")
-	ctx.Output.Header(headerAllowCredentials, "true")
+	if origin != "*" {
+		ctx.Output.Header(headerAllowCredentials, "true")
+		ctx.Output.Header("Vary", "Origin")
+	}
 
 	if ctx.Input.Method() == "OPTIONS" {
 		ctx.ResponseWriter.WriteHeader(http.StatusOK)
@@ -115,9 +222,6 @@ func CorsFilter(ctx *context.Context) {
 	// 1. Static allowlist — always works, even when IAM is down.
 	if isStaticAllowedOrigin(origin) {
 		setCorsHeaders(ctx, origin)
-		if object.CloudHost == "" {
-			object.CloudHost = origin
-		}
 		return
 	}
 
@@ -131,6 +235,15 @@ func CorsFilter(ctx *context.Context) {
 
 	// 3. Dynamic check via IAM application RedirectUris.
 	ok, err := isOriginAllowed(origin)
+
+	// 4. The public model endpoints take API keys from any other origin.
+	// Preflight requests carry no Authorization header, so this goes by
+	// path; the wildcard keeps browsers from sending cookies.
+	if (err != nil || !ok) && isPublicModelRoute(ctx.Request.URL.Path) {
+		setCorsHeaders(ctx, "*")
+		return
+	}
+
 	if err != nil {
 		// If IAM is not configured at all, reject — no more open fallback.
 		ctx.ResponseWriter.WriteHeader(http.StatusForbidden)
@@ -145,38 +258,75 @@ func CorsFilter(ctx *context.Context) {
 	}
 
 	setCorsHeaders(ctx, origin)
-	if object.CloudHost == "" {
-		object.CloudHost = origin
-	}
 }
 
+// iamOriginsTTL is how long the origins of the IAM application's redirect
+// URIs are cached. When IAM cannot be reached, the last origins fetched
+// keep being used.
+const iamOriginsTTL = 60 * time.Second
+
+type iamOriginsEntry struct {
+	origins   map[string]bool
+	fetchedAt time.Time
+}
+
+var (
+	iamOrigins   *iamOriginsEntry
+	iamOriginsMu sync.Mutex
+)
+
 func isOriginAllowed(origin string) (bool, error) {
+	origins, err := getIamOrigins()
+	if err != nil {
+		return false, err
+	}
+	return origins[origin], nil
+}
+
+// getIamOrigins returns the origins of the IAM application's redirect URIs.
+func getIamOrigins() (map[string]bool, error) {
+	iamOriginsMu.Lock()
+	defer iamOriginsMu.Unlock()
+	if iamOrigins != nil && time.Since(iamOrigins.fetchedAt) < iamOriginsTTL {
+		return iamOrigins.origins, nil
+	}
+
+	origins, err := fetchIamOrigins()
+	if err != nil {
+		if iamOrigins != nil {
+			logs.Warn("cors: failed to refresh the IAM application, keeping its last origins: %v", err)
+			iamOrigins.fetchedAt = time.Now()
+			return iamOrigins.origins, nil
+		}
+		return nil, err
+	}
+	iamOrigins = &iamOriginsEntry{origins: origins, fetchedAt: time.Now()}
+	return origins, nil
+}
+
+func fetchIamOrigins() (map[string]bool, error) {
 	iamEndpoint := conf.GetConfigString("iamEndpoint")
 	iamApplication := conf.GetConfigString("iamApplication")
 
 	if iamEndpoint == "" || iamApplication == "" {
-		return false, fmt.Errorf("iamEndpoint or iamApplication is empty")
+		return nil, fmt.Errorf("iamEndpoint or iamApplication is empty")
 	}
 
 	application, err := iamsdk.GetApplication(iamApplication)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if application == nil {
-		return false, fmt.Errorf("The application: %s does not exist", iamApplication)
+		return nil, fmt.Errorf("The application: %s does not exist", iamApplication)
 	}
 
-	// Check if origin matches any RedirectUri
+	origins := map[string]bool{}
 	for _, redirectUri := range application.RedirectUris {
 		parsedUrl, err := url.Parse(redirectUri)
 		if err != nil {
 			continue
 		}
-		allowedOrigin := parsedUrl.Scheme + "://" + parsedUrl.Host
-		if origin == allowedOrigin {
-			return true, nil
-		}
+		origins[parsedUrl.Scheme+"://"+parsedUrl.Host] = true
 	}
-
-	return false, nil
+	return origins, nil
 }
@@ -0,0 +1,118 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/context"
+)
+
+func serveCors(method string, path string, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "https://api.hanzo.ai"+path, nil)
+	req.Header.Set("Origin", origin)
+	resp := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(resp, req)
+	CorsFilter(ctx)
+	return resp
+}
+
+// withIamOrigins stands in for the IAM application's redirect URIs.
+func withIamOrigins(t *testing.T, origins ...string) {
+	t.Helper()
+	entry := &iamOriginsEntry{origins: map[string]bool{}, fetchedAt: time.Now()}
+	for _, origin := range origins {
+		entry.origins[origin] = true
+	}
+	iamOrigins = entry
+	t.Cleanup(func() { iamOrigins = nil })
+}
+
+func TestCorsAllowedOrigins(t *testing.T) {
+	t.Setenv("corsAllowedOrigins", "https://app.example.com, *.example.org,http://localhost.test:8080,ftp://files.example.com,https://*bad.example.com")
+	InitCors()
+	t.Cleanup(func() { corsAllowedOrigins = nil })
+	if len(corsAllowedOrigins) != 3 {
+		t.Fatalf("parsed %d origins, want 3 with the invalid ones skipped: %+v", len(corsAllowedOrigins), corsAllowedOrigins)
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"http://app.example.com", false},
+		{"https://evil-app.example.com", false},
+		{"https://www.example.org", true},
+		{"http://a.b.example.org:3000", true},
+		{"https://example.org", false},
+		{"http://localhost.test:8080", true},
+		{"http://localhost.test:8081", false},
+		{"https://cloud.hanzo.ai", true},
+		{"http://localhost:5173", true},
+		{"https://example.net", false},
+	}
+	for _, tt := range tests {
+		if got := isStaticAllowedOrigin(tt.origin); got != tt.allowed {
+			t.Errorf("isStaticAllowedOrigin(%q) = %v, want %v", tt.origin, got, tt.allowed)
+		}
+	}
+}
+
+func TestCorsFilter(t *testing.T) {
+	withIamOrigins(t, "https://partner.example.com")
+
+	resp := serveCors(http.MethodOptions, "/v1/get-providers", "https://partner.example.com")
+	if resp.Header().Get(headerAllowOrigin) != "https://partner.example.com" || resp.Header().Get(headerAllowCredentials) != "true" {
+		t.Errorf("IAM redirect origin: headers = %v", resp.Header())
+	}
+
+	resp = serveCors(http.MethodOptions, "/v1/get-providers", "https://evil.example.com")
+	if resp.Code != http.StatusForbidden || resp.Header().Get(headerAllowOrigin) != "" {
+		t.Errorf("unknown origin on an admin route: code %d, headers = %v", resp.Code, resp.Header())
+	}
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/models", "/v1/conversations/abc/completions"} {
+		resp = serveCors(http.MethodOptions, path, "https://evil.example.com")
+		if resp.Code != http.StatusOK || resp.Header().Get(headerAllowOrigin) != "*" || resp.Header().Get(headerAllowCredentials) != "" {
+			t.Errorf("unknown origin on %s: code %d, headers = %v", path, resp.Code, resp.Header())
+		}
+	}
+
+	resp = serveCors(http.MethodOptions, "/v1/chat/completions", "https://partner.example.com")
+	if resp.Header().Get(headerAllowOrigin) != "https://partner.example.com" || resp.Header().Get(headerAllowCredentials) != "true" {
+		t.Errorf("IAM redirect origin on a model route: headers = %v", resp.Header())
+	}
+}
+
+func TestIamOriginsKeptWhenIamFails(t *testing.T) {
+	t.Setenv("iamEndpoint", "")
+	withIamOrigins(t, "https://partner.example.com")
+	iamOrigins.fetchedAt = time.Now().Add(-2 * iamOriginsTTL)
+
+	ok, err := isOriginAllowed("https://partner.example.com")
+	if err != nil || !ok {
+		t.Errorf("isOriginAllowed with IAM down = %v, %v; want the cached origins", ok, err)
+	}
+
+	iamOrigins = nil
+	if _, err = isOriginAllowed("https://partner.example.com"); err == nil {
+		t.Error("isOriginAllowed without IAM or cached origins succeeded")
+	}
+}
@@ -16,19 +16,23 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/beego/beego/context"
 	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/cache"
 	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/controllers"
+	"github.com/hanzoai/cloud/util"
 	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"golang.org/x/sync/singleflight"
 )
 
 // ── Service key exemption ────────────────────────────────────────────────────
@@ -58,8 +62,16 @@ const (
 	// in the background, so requests are never blocked on Commerce latency.
 	balanceCacheTTL = 30 * time.Second
 
-	// balanceCacheCleanupInterval is how often stale cache entries are evicted.
-	balanceCacheCleanupInterval = 5 * time.Minute
+	// balanceStaleTTL is how long past balanceCacheTTL a stale balance is
+	// still served; older entries are fetched synchronously again.
+	balanceStaleTTL = 5 * time.Minute
+
+	// balanceCacheMaxEntries bounds the balance cache; the least recently
+	// checked users are evicted first.
+	balanceCacheMaxEntries = 50000
+
+	// userKeyCacheMaxEntries bounds the user key and rejected key caches.
+	userKeyCacheMaxEntries = 100000
 
 	// balanceHTTPTimeout is the per-request timeout for Commerce balance lookups.
 	balanceHTTPTimeout = 5 * time.Second
@@ -68,32 +80,32 @@ const (
 	// cached. IAM key lookups are expensive (HTTP call); JWTs are cheap to
 	// parse but we cache them too for consistency.
 	userKeyCacheTTL = 5 * time.Minute
+
+	// rejectedKeyTTL controls how long an hk- key that IAM rejected is
+	// remembered, so invalid keys cannot trigger an IAM call per request.
+	rejectedKeyTTL = 30 * time.Second
 )
 
 // ── Balance cache ───────────────────────────────────────────────────────────
 
-// balanceCacheEntry holds a cached balance check result for a single user.
-type balanceCacheEntry struct {
-	balanceCents int64
-	fetchedAt    time.Time
-}
-
 // BalanceGate caches user balance checks to avoid hitting Commerce on every
 // request. Stale entries are served immediately while an async refresh runs
-// in the background — the hot path never blocks on network I/O.
+// in the background — the hot path never blocks on network I/O — and
+// concurrent fetches for the same user are coalesced into one. With a
+// distributed cache configured (see package cache) the replicas share
+// balances and resolved keys.
 type BalanceGate struct {
-	mu      sync.RWMutex
-	entries map[string]*balanceCacheEntry
+	balances *cache.Loading[int64] // user key -> balance in cents
+
+	// userKeys maps Bearer token -> "owner/name" to avoid re-parsing JWTs
+	// or re-calling IAM on every request for the same token.
+	userKeys *cache.Loading[string]
 
-	// userKeyCache maps Bearer token -> "owner/name" to avoid re-parsing
-	// JWTs or re-calling IAM on every request for the same token.
-	userKeyMu    sync.RWMutex
-	userKeyCache map[string]*userKeyCacheEntry
+	// rejectedKeys is a negative cache of hk- keys IAM rejected.
+	rejectedKeys *cache.Loading[bool]
 
-	// inflight tracks user keys currently being refreshed to deduplicate
-	// concurrent async fetches.
-	inflightMu sync.Mutex
-	inflight   map[string]struct{}
+	// iamGroup collapses concurrent IAM lookups for the same key.
+	iamGroup singleflight.Group
 
 	endpoint string       // Commerce base URL (e.g. "http://commerce:8001")
 	token    string       // Bearer token for Commerce API
@@ -104,12 +116,6 @@ type BalanceGate struct {
 	clientSecret string // IAM application client secret
 }
 
-// userKeyCacheEntry maps an API token to the resolved "owner/name" user key.
-type userKeyCacheEntry struct {
-	userKey   string
-	fetchedAt time.Time
-}
-
 // balanceGate is the package-level singleton, initialized by InitBalanceGate.
 var balanceGate *BalanceGate
 
@@ -133,9 +139,24 @@ func InitBalanceGate() {
 	clientSecret := conf.GetConfigString("clientSecret")
 
 	bg := &BalanceGate{
-		entries:      make(map[string]*balanceCacheEntry),
-		userKeyCache: make(map[string]*userKeyCacheEntry),
-		inflight:     make(map[string]struct{}),
+		balances: cache.NewLoading[int64]("balance", cache.Options{
+			MaxEntries: balanceCacheMaxEntries,
+			TTL:        balanceCacheTTL,
+			StaleTTL:   balanceStaleTTL,
+			Shared:     true,
+		}),
+		userKeys: cache.NewLoading[string]("user-key", cache.Options{
+			MaxEntries: userKeyCacheMaxEntries,
+			TTL:        userKeyCacheTTL,
+			Shared:     true,
+			HashKeys:   true,
+		}),
+		rejectedKeys: cache.NewLoading[bool]("rejected-key", cache.Options{
+			MaxEntries: userKeyCacheMaxEntries,
+			TTL:        rejectedKeyTTL,
+			Shared:     true,
+			HashKeys:   true,
+		}),
 		endpoint:     endpoint,
 		token:        token,
 		client:       &http.Client{Timeout: balanceHTTPTimeout},
@@ -144,9 +165,8 @@ func InitBalanceGate() {
 		clientSecret: clientSecret,
 	}
 
-	go bg.cleanupLoop()
-
 	balanceGate = bg
+	controllers.OnCommerceBalanceChange(bg.applyBalanceChange)
 	logs.Info("balance_gate: initialized (endpoint=%s, ttl=%v)", endpoint, balanceCacheTTL)
 }
 
@@ -161,6 +181,8 @@ func InitBalanceGate() {
 // Design: fail-open. If Commerce is unreachable or the user cannot be
 // identified, the request is allowed through. The controller-level balance
 // check in resolveProviderForUser remains as a defense-in-depth backstop.
+// An hk- key IAM rejected, or could not be checked, is refused here rather
+// than let past the balance check (see refuseUnverifiedKey).
 func BalanceGateFilter(ctx *context.Context) {
 	if balanceGate == nil {
 		return
@@ -177,7 +199,11 @@ func BalanceGateFilter(ctx *context.Context) {
 		return
 	}
 
-	userKey := resolveUserKey(ctx)
+	userKey, err := resolveUserKey(ctx)
+	if err != nil {
+		refuseUnverifiedKey(ctx, err)
+		return
+	}
 	if userKey == "" {
 		// Cannot identify user — let downstream auth filters handle rejection.
 		return
@@ -204,8 +230,16 @@ func isBalanceExempt(path string) bool {
 	switch {
 	case path == "/v1/health" || path == "/health":
 		return true
+	case path == "/v1/ready" || path == "/ready":
+		return true
+	case path == "/healthz" || path == "/readyz":
+		return true
 	case path == "/v1/metrics" || path == "/metrics":
 		return true
+	case path == "/v1/openapi.json":
+		return true
+	case path == "/v1/commerce-balance-webhook":
+		return true
 	// /api/models and /v1/models require authentication (R-04).
 	// Removed from balance exemption — callers must have a valid token.
 	case strings.HasPrefix(path, "/v1/get-version-info"):
@@ -229,60 +263,131 @@ func isBalanceExempt(path string) bool {
 //  2. JWT Bearer token (parsed locally, no network call)
 //  3. IAM API key (hk- prefix, resolved via cached IAM lookup)
 //
-// Returns "" if the user cannot be identified (fail-open: filter skips).
-func resolveUserKey(ctx *context.Context) string {
+// Returns "" if the user cannot be identified (fail-open: filter skips),
+// except for an hk- key IAM rejected (errKeyRejected) or could not be asked
+// about (errKeyUnverified).
+func resolveUserKey(ctx *context.Context) (string, error) {
 	// Source 1: session user from AutoSigninFilter.
 	user := GetSessionUser(ctx)
 	if user != nil && user.Owner != "" && user.Name != "" {
-		return user.Owner + "/" + user.Name
+		return user.Owner + "/" + user.Name, nil
 	}
 
 	// Source 2/3: Bearer token.
 	token := parseBearerToken(ctx)
 	if token == "" {
-		return ""
+		return "", nil
 	}
 
 	// Provider keys (sk-), publishable keys (pk-), and widget keys (hz_)
 	// don't map to IAM users with Commerce balances — skip.
 	if strings.HasPrefix(token, "sk-") || strings.HasPrefix(token, "pk-") || strings.HasPrefix(token, "hz_") {
-		return ""
+		return "", nil
 	}
 
 	// Exempt service account keys (e.g. cloud agent internal keys).
 	if _, exempt := balanceExemptKeys[token]; exempt {
-		return ""
+		return "", nil
 	}
 
 	// Check user key cache first.
 	if cached := balanceGate.getUserKeyCached(token); cached != "" {
-		return cached
+		return cached, nil
 	}
 
 	// JWT token: parse locally (cheap, no network).
 	if isJwtTokenLike(token) {
 		claims, err := iamsdk.ParseJwtToken(token)
 		if err != nil {
-			return ""
+			return "", nil
 		}
 		userKey := claims.User.Owner + "/" + claims.User.Name
 		if claims.User.Owner != "" && claims.User.Name != "" {
 			balanceGate.setUserKeyCache(token, userKey)
-			return userKey
+			return userKey, nil
 		}
-		return ""
+		return "", nil
 	}
 
-	// IAM API key (hk- prefix): resolve via IAM (cached).
+	// IAM API key (hk- prefix): resolve via IAM (cached, negatively cached
+	// on rejection, and deduplicated across concurrent requests).
 	if strings.HasPrefix(token, "hk-") {
-		userKey := balanceGate.resolveIAMKeyUser(token)
-		if userKey != "" {
-			balanceGate.setUserKeyCache(token, userKey)
+		if balanceGate.iamEndpoint == "" {
+			return "", nil
+		}
+		if balanceGate.isKeyRejected(token) {
+			return "", errKeyRejected
+		}
+		v, _, _ := balanceGate.iamGroup.Do(token, func() (interface{}, error) {
+			userKey, rejected := balanceGate.resolveIAMKeyUser(token)
+			if userKey == "" {
+				// IAM's get-user knows neither rotated-out keys in their
+				// overlap window nor the cloud-agent key; the controllers
+				// accept both, so only refuse what they refuse too.
+				user, refused := verifyAccessKey(token, util.GetClientIP(ctx.Request))
+				if user != nil {
+					userKey, rejected = user.Owner+"/"+user.Name, false
+				} else {
+					rejected = refused
+				}
+			}
+			if userKey != "" {
+				balanceGate.setUserKeyCache(token, userKey)
+			} else if rejected {
+				balanceGate.setKeyRejected(token)
+			}
+			return iamKeyLookup{userKey: userKey, rejected: rejected}, nil
+		})
+		lookup, _ := v.(iamKeyLookup)
+		switch {
+		case lookup.userKey != "":
+			return lookup.userKey, nil
+		case lookup.rejected:
+			return "", errKeyRejected
+		default:
+			return "", errKeyUnverified
 		}
-		return userKey
 	}
 
-	return ""
+	return "", nil
+}
+
+// iamKeyLookup is the shared result of one IAM lookup of an hk- key.
+type iamKeyLookup struct {
+	userKey  string
+	rejected bool
+}
+
+var (
+	// errKeyRejected means IAM refused the hk- key, e.g. it was revoked.
+	errKeyRejected = errors.New("invalid API key")
+
+	// errKeyUnverified means IAM could not be asked about the hk- key.
+	errKeyUnverified = errors.New("could not verify the API key, retry later")
+)
+
+// verifyAccessKey resolves an hk- key as the controllers do; replaced in tests.
+var verifyAccessKey = controllers.VerifyAccessKey
+
+// refuseUnverifiedKey answers a request whose hk- key IAM rejected (401) or
+// could not check (503).
+func refuseUnverifiedKey(ctx *context.Context, err error) {
+	status, errType, code := http.StatusUnauthorized, "authentication_error", "invalid_api_key"
+	if errors.Is(err, errKeyUnverified) {
+		status, errType, code = http.StatusServiceUnavailable, "api_error", "key_verification_unavailable"
+	}
+	logs.Warning("balance_gate: refused %s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": err.Error(),
+			"type":    errType,
+			"code":    code,
+		},
+	})
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/json")
+	ctx.ResponseWriter.WriteHeader(status)
+	ctx.ResponseWriter.Write(body)
 }
 
 // isJwtTokenLike checks if a token looks like a JWT (3 dot-separated segments).
@@ -296,69 +401,34 @@ func isJwtTokenLike(token string) bool {
 // checkBalance returns whether the user has a positive balance. On cache hit
 // within TTL, returns the cached result immediately. On stale cache entry,
 // returns the stale result and kicks off an async refresh. On cache miss,
-// fetches synchronously (with timeout) on first request, then caches.
+// fetches synchronously (with timeout), sharing the fetch with concurrent
+// requests for the same user, then caches.
 //
 // Fail-open: any error from Commerce results in (true, 0) — the request is
 // allowed through, and the controller-level check provides a backstop.
 func (bg *BalanceGate) checkBalance(userKey string) (sufficient bool, balanceCents int64) {
-	bg.mu.RLock()
-	entry, ok := bg.entries[userKey]
-	bg.mu.RUnlock()
-
-	if ok {
-		age := time.Since(entry.fetchedAt)
-		if age <= balanceCacheTTL {
-			// Fresh cache hit.
-			return entry.balanceCents > 0, entry.balanceCents
+	balance, err := bg.balances.Get(userKey, func() (int64, error) {
+		balance, err := bg.fetchBalance(userKey)
+		if err != nil {
+			logs.Warning("balance_gate: Commerce lookup failed for user=%s: %v", userKey, err)
 		}
-		// Stale: serve stale result, refresh asynchronously.
-		bg.refreshAsync(userKey)
-		return entry.balanceCents > 0, entry.balanceCents
-	}
-
-	// Cache miss: fetch synchronously so the first request gets a real check.
-	// The timeout is capped at balanceHTTPTimeout (5s) to avoid blocking too long.
-	balance, err := bg.fetchBalance(userKey)
+		return balance, err
+	})
 	if err != nil {
-		logs.Warning("balance_gate: Commerce lookup failed for user=%s: %v (fail-open)", userKey, err)
+		// Fail-open.
 		return true, 0
 	}
-
-	bg.mu.Lock()
-	bg.entries[userKey] = &balanceCacheEntry{balanceCents: balance, fetchedAt: time.Now()}
-	bg.mu.Unlock()
-
 	return balance > 0, balance
 }
 
-// refreshAsync kicks off a background goroutine to refresh the cached balance
-// for a user. Deduplicates concurrent refreshes for the same user key.
-func (bg *BalanceGate) refreshAsync(userKey string) {
-	bg.inflightMu.Lock()
-	if _, running := bg.inflight[userKey]; running {
-		bg.inflightMu.Unlock()
-		return
+// applyBalanceChange takes a balance change Commerce pushed. The user's
+// cached balance is dropped on every replica; a new balance is then stored
+// (and shared), otherwise the next request looks it up again.
+func (bg *BalanceGate) applyBalanceChange(userKey string, available *int64) {
+	bg.balances.Invalidate(userKey)
+	if available != nil {
+		bg.balances.Set(userKey, *available)
 	}
-	bg.inflight[userKey] = struct{}{}
-	bg.inflightMu.Unlock()
-
-	go func() {
-		defer func() {
-			bg.inflightMu.Lock()
-			delete(bg.inflight, userKey)
-			bg.inflightMu.Unlock()
-		}()
-
-		balance, err := bg.fetchBalance(userKey)
-		if err != nil {
-			logs.Warning("balance_gate: async refresh failed for user=%s: %v", userKey, err)
-			return
-		}
-
-		bg.mu.Lock()
-		bg.entries[userKey] = &balanceCacheEntry{balanceCents: balance, fetchedAt: time.Now()}
-		bg.mu.Unlock()
-	}()
 }
 
 // commerceBalanceResponse is the expected JSON shape from Commerce balance endpoint.
@@ -403,21 +473,24 @@ func (bg *BalanceGate) fetchBalance(userKey string) (int64, error) {
 
 // getUserKeyCached returns the cached userKey for a token, or "" on miss/stale.
 func (bg *BalanceGate) getUserKeyCached(token string) string {
-	bg.userKeyMu.RLock()
-	entry, ok := bg.userKeyCache[token]
-	bg.userKeyMu.RUnlock()
-
-	if !ok || time.Since(entry.fetchedAt) > userKeyCacheTTL {
-		return ""
-	}
-	return entry.userKey
+	userKey, _ := bg.userKeys.Peek(token)
+	return userKey
 }
 
 // setUserKeyCache stores a token -> userKey mapping in the cache.
 func (bg *BalanceGate) setUserKeyCache(token, userKey string) {
-	bg.userKeyMu.Lock()
-	bg.userKeyCache[token] = &userKeyCacheEntry{userKey: userKey, fetchedAt: time.Now()}
-	bg.userKeyMu.Unlock()
+	bg.userKeys.Set(token, userKey)
+}
+
+// isKeyRejected reports whether IAM recently rejected the given key.
+func (bg *BalanceGate) isKeyRejected(token string) bool {
+	rejected, _ := bg.rejectedKeys.Peek(token)
+	return rejected
+}
+
+// setKeyRejected records an IAM rejection for rejectedKeyTTL.
+func (bg *BalanceGate) setKeyRejected(token string) {
+	bg.rejectedKeys.Set(token, true)
 }
 
 // ── IAM key resolution ──────────────────────────────────────────────────────
@@ -433,27 +506,35 @@ type iamUserResponse struct {
 }
 
 // resolveIAMKeyUser calls IAM to resolve an hk- API key to an "owner/name"
-// user key. Returns "" on any error (fail-open).
-func (bg *BalanceGate) resolveIAMKeyUser(apiKey string) string {
-	if bg.iamEndpoint == "" {
-		return ""
+// user key. See fetchIAMKeyUser.
+func (bg *BalanceGate) resolveIAMKeyUser(apiKey string) (userKey string, rejected bool) {
+	return fetchIAMKeyUser(bg.client, bg.iamEndpoint, bg.clientId, bg.clientSecret, apiKey)
+}
+
+// fetchIAMKeyUser calls IAM to resolve an hk- API key to an "owner/name"
+// user key. Returns "" on any error; rejected is true only when
+// IAM answered and definitively refused the key, as opposed to a transport
+// or decode failure.
+func fetchIAMKeyUser(client *http.Client, iamEndpoint, clientId, clientSecret, apiKey string) (userKey string, rejected bool) {
+	if iamEndpoint == "" {
+		return "", false
 	}
 
-	iamURL := fmt.Sprintf("%s/api/get-user?accessKey=%s", bg.iamEndpoint, url.QueryEscape(apiKey))
-	if bg.clientId != "" && bg.clientSecret != "" {
-		iamURL += "&clientId=" + url.QueryEscape(bg.clientId) + "&clientSecret=" + url.QueryEscape(bg.clientSecret)
+	iamURL := fmt.Sprintf("%s/api/get-user?accessKey=%s", iamEndpoint, url.QueryEscape(apiKey))
+	if clientId != "" && clientSecret != "" {
+		iamURL += "&clientId=" + url.QueryEscape(clientId) + "&clientSecret=" + url.QueryEscape(clientSecret)
 	}
 
 	req, err := http.NewRequest(http.MethodGet, iamURL, nil)
 	if err != nil {
-		logs.Warning("balance_gate: IAM request build failed for key=%s: %v", maskKey(apiKey), err)
-		return ""
+		logs.Warning("iam_key: IAM request build failed for key=%s: %v", maskKey(apiKey), err)
+		return "", false
 	}
 
-	resp, err := bg.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		logs.Warning("balance_gate: IAM request failed for key=%s: %v", maskKey(apiKey), err)
-		return ""
+		logs.Warning("iam_key: IAM request failed for key=%s: %v", maskKey(apiKey), err)
+		return "", false
 	}
 	defer func() {
 		io.Copy(io.Discard, resp.Body)
@@ -461,51 +542,23 @@ func (bg *BalanceGate) resolveIAMKeyUser(apiKey string) string {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		logs.Warning("balance_gate: IAM returned %d for key=%s", resp.StatusCode, maskKey(apiKey))
-		return ""
+		logs.Warning("iam_key: IAM returned %d for key=%s", resp.StatusCode, maskKey(apiKey))
+		return "", false
 	}
 
 	var result iamUserResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		logs.Warning("balance_gate: IAM response decode failed for key=%s: %v", maskKey(apiKey), err)
-		return ""
+		logs.Warning("iam_key: IAM response decode failed for key=%s: %v", maskKey(apiKey), err)
+		return "", false
 	}
 
 	if result.Status != "ok" || result.Data == nil {
-		return ""
+		return "", true
 	}
 
 	if result.Data.Owner == "" || result.Data.Name == "" {
-		return ""
+		return "", true
 	}
 
-	return result.Data.Owner + "/" + result.Data.Name
-}
-
-// ── Cleanup ─────────────────────────────────────────────────────────────────
-
-// cleanupLoop periodically evicts stale entries from both caches.
-func (bg *BalanceGate) cleanupLoop() {
-	ticker := time.NewTicker(balanceCacheCleanupInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		now := time.Now()
-
-		bg.mu.Lock()
-		for key, entry := range bg.entries {
-			if now.Sub(entry.fetchedAt) > 2*balanceCacheTTL {
-				delete(bg.entries, key)
-			}
-		}
-		bg.mu.Unlock()
-
-		bg.userKeyMu.Lock()
-		for key, entry := range bg.userKeyCache {
-			if now.Sub(entry.fetchedAt) > 2*userKeyCacheTTL {
-				delete(bg.userKeyCache, key)
-			}
-		}
-		bg.userKeyMu.Unlock()
-	}
+	return result.Data.Owner + "/" + result.Data.Name, false
 }
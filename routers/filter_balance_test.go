@@ -0,0 +1,171 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/context"
+	"github.com/beego/beego/session"
+	"github.com/hanzoai/cloud/cache"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func TestApplyBalanceChange(t *testing.T) {
+	var lookups atomic.Int64
+	commerce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		fmt.Fprint(w, `{"available":0}`)
+	}))
+	defer commerce.Close()
+
+	bg := &BalanceGate{
+		balances: cache.NewLoading[int64]("balance-test", cache.Options{
+			MaxEntries: 10,
+			TTL:        time.Hour,
+		}),
+		endpoint: commerce.URL,
+		client:   commerce.Client(),
+	}
+
+	// A drained user is stopped as soon as Commerce says so.
+	bg.balances.Set("acme/alice", 500)
+	drained := int64(0)
+	bg.applyBalanceChange("acme/alice", &drained)
+	if sufficient, balance := bg.checkBalance("acme/alice"); sufficient || balance != 0 {
+		t.Errorf("after a drain: checkBalance() = %v, %d, want false, 0", sufficient, balance)
+	}
+
+	// A top-up lets the user in without a lookup.
+	toppedUp := int64(1250)
+	bg.applyBalanceChange("acme/alice", &toppedUp)
+	if sufficient, balance := bg.checkBalance("acme/alice"); !sufficient || balance != 1250 {
+		t.Errorf("after a top-up: checkBalance() = %v, %d, want true, 1250", sufficient, balance)
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Errorf("Commerce was called %d times, want 0", n)
+	}
+
+	// A change without a balance makes the next check look it up again.
+	bg.applyBalanceChange("acme/alice", nil)
+	if sufficient, balance := bg.checkBalance("acme/alice"); sufficient || balance != 0 {
+		t.Errorf("after an invalidation: checkBalance() = %v, %d, want false, 0", sufficient, balance)
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("Commerce was called %d times, want 1", n)
+	}
+}
+
+func TestIsBalanceExempt(t *testing.T) {
+	if !isBalanceExempt("/v1/commerce-balance-webhook") {
+		t.Error("expected the Commerce balance webhook to be exempt")
+	}
+	if isBalanceExempt("/v1/chat/completions") {
+		t.Error("expected /v1/chat/completions to NOT be exempt")
+	}
+}
+
+func TestBalanceGateFilterUnverifiedKeys(t *testing.T) {
+	iamUp := true
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !iamUp {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.URL.Query().Get("accessKey") == "hk-alice" {
+			fmt.Fprint(w, `{"status":"ok","data":{"owner":"acme","name":"alice"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"error","msg":"user not found"}`)
+	}))
+	defer iam.Close()
+	commerce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"available":500}`)
+	}))
+	defer commerce.Close()
+
+	// hk-rotated stands for a rotated-out key IAM's get-user no longer
+	// knows but the controllers still accept.
+	oldVerify := verifyAccessKey
+	verifyAccessKey = func(accessKey string, source string) (*iamsdk.User, bool) {
+		if !iamUp {
+			return nil, false
+		}
+		if accessKey == "hk-rotated" {
+			return &iamsdk.User{Owner: "acme", Name: "bob"}, false
+		}
+		return nil, true
+	}
+	oldGate := balanceGate
+	balanceGate = &BalanceGate{
+		balances:     cache.NewLoading[int64]("balance-filter-test", cache.Options{MaxEntries: 10, TTL: time.Hour}),
+		userKeys:     cache.NewLoading[string]("user-key-filter-test", cache.Options{MaxEntries: 10, TTL: time.Hour}),
+		rejectedKeys: cache.NewLoading[bool]("rejected-key-filter-test", cache.Options{MaxEntries: 10, TTL: time.Hour}),
+		endpoint:     commerce.URL,
+		client:       commerce.Client(),
+		iamEndpoint:  iam.URL,
+	}
+	defer func() {
+		verifyAccessKey = oldVerify
+		balanceGate = oldGate
+	}()
+
+	sessions, err := session.NewManager("memory", &session.ManagerConfig{CookieName: "test", Gclifetime: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(resp, req)
+		store, err := sessions.SessionStart(resp, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Input.CruSession = store
+		BalanceGateFilter(ctx)
+		return resp.Code
+	}
+
+	// While IAM is down, keys it never vouched for are not let past.
+	iamUp = false
+	if code := serve("hk-alice"); code != http.StatusServiceUnavailable {
+		t.Errorf("key checked during an IAM outage: got %d, want 503", code)
+	}
+
+	iamUp = true
+	if code := serve("hk-alice"); code != http.StatusOK {
+		t.Errorf("valid key: got %d, want 200", code)
+	}
+	if code := serve("hk-rotated"); code != http.StatusOK {
+		t.Errorf("rotated key: got %d, want 200", code)
+	}
+	if code := serve("hk-revoked"); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: got %d, want 401", code)
+	}
+
+	// The rejection is remembered even once IAM goes down again.
+	iamUp = false
+	if code := serve("hk-revoked"); code != http.StatusUnauthorized {
+		t.Errorf("revoked key during an IAM outage: got %d, want 401", code)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"strings"
+
+	"github.com/beego/beego/context"
+)
+
+// legacyApiAliases maps the /api paths of the OpenAI and Anthropic
+// compatible APIs, from before everything moved under /v1, and of the
+// OpenAPI document, to their /v1 routes. Only these paths are aliased: the
+// rest of the API never lived under /api for SDK clients, and /api is
+// otherwise left to the subdomain.
+var legacyApiAliases = map[string]string{
+	"/api/chat":             "/v1/chat",
+	"/api/chat/completions": "/v1/chat/completions",
+	"/api/completions":      "/v1/completions",
+	"/api/models":           "/v1/models",
+	"/api/embeddings":       "/v1/embeddings",
+	"/api/messages":         "/v1/messages",
+	"/api/openapi.json":     "/v1/openapi.json",
+}
+
+// legacyApiPrefixAliases are the aliased paths that take a trailing
+// parameter, such as /api/models/{id}.
+var legacyApiPrefixAliases = map[string]string{
+	"/api/models/": "/v1/models/",
+}
+
+// LegacyApiRewriteFilter rewrites the legacy /api paths of the compatible
+// APIs to their /v1 routes, so clients still configured with an .../api base
+// URL keep working. It runs before every other filter, so body limits, rate
+// limits and authentication see the /v1 path.
+//
+// Example: /api/chat/completions → /v1/chat/completions
+func LegacyApiRewriteFilter(ctx *context.Context) {
+	newPath, ok := legacyApiAliases[ctx.Request.URL.Path]
+	if !ok {
+		for prefix, target := range legacyApiPrefixAliases {
+			if rest, found := strings.CutPrefix(ctx.Request.URL.Path, prefix); found && rest != "" {
+				newPath, ok = target+rest, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return
+	}
+	ctx.Request.URL.Path = newPath
+	ctx.Request.RequestURI = newPath
+	if ctx.Request.URL.RawQuery != "" {
+		ctx.Request.RequestURI = newPath + "?" + ctx.Request.URL.RawQuery
+	}
+}
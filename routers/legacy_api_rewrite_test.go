@@ -0,0 +1,49 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beego/beego/context"
+)
+
+func TestLegacyApiRewriteFilter(t *testing.T) {
+	tests := []struct {
+		target  string
+		wantURI string
+	}{
+		{"/api/chat/completions", "/v1/chat/completions"},
+		{"/api/messages?beta=true", "/v1/messages?beta=true"},
+		{"/api/models", "/v1/models"},
+		{"/api/models/zen4", "/v1/models/zen4"},
+		{"/api/models/openai/gpt-4o", "/v1/models/openai/gpt-4o"},
+		{"/api/models/", "/api/models/"},
+		{"/api/get-stores", "/api/get-stores"},
+		{"/v1/chat/completions", "/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		ctx := context.NewContext()
+		ctx.Reset(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+		LegacyApiRewriteFilter(ctx)
+
+		if ctx.Request.RequestURI != tt.wantURI {
+			t.Errorf("%s: RequestURI = %q, want %q", tt.target, ctx.Request.RequestURI, tt.wantURI)
+		}
+	}
+}
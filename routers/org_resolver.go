@@ -15,20 +15,19 @@
 package routers
 
 import (
-	"strings"
-
 	"github.com/beego/beego/context"
 	"github.com/hanzoai/cloud/conf"
 )
 
 // GetEffectiveOrg resolves the organization for data-scoping in filters.
 // Resolution order:
-//  1. X-IAM-Org-Id header (injected by gateway auth middleware from JWT)
+//  1. X-IAM-Org-Id header, once TenantContextFilter verified it against the
+//     request's credentials
 //  2. Authenticated session user's Owner field
 //  3. Config default (iamOrganization env/config value)
 func GetEffectiveOrg(ctx *context.Context) string {
-	// 1. Gateway-injected header (trusted, set after JWT validation)
-	if orgID := strings.TrimSpace(ctx.Input.Header("X-IAM-Org-Id")); orgID != "" {
+	// 1. Verified tenant org
+	if orgID := GetTenantOrgID(ctx); orgID != "" {
 		return orgID
 	}
 
@@ -33,7 +33,6 @@ func recordSystemInfo(systemInfo *util.SystemInfo) {
 }
 
 func PrometheusFilter(ctx *context.Context) {
-	method := ctx.Input.Method()
 	path := ctx.Input.URL()
 	if strings.HasPrefix(path, "/v1/metrics") {
 		systemInfo, err := util.GetSystemInfo()
@@ -43,9 +42,10 @@ func PrometheusFilter(ctx *context.Context) {
 		return
 	}
 
-	if strings.HasPrefix(path, "/api") {
+	// The per-path metrics are recorded by the controller, labelled with
+	// the matched route rather than the URL.
+	if strings.HasPrefix(path, "/api") || strings.HasPrefix(path, "/v1/") {
 		ctx.Input.SetData("startTime", time.Now())
 		object.TotalThroughput.Inc()
-		object.ApiThroughput.WithLabelValues(path, method).Inc()
 	}
 }
@@ -20,6 +20,8 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,6 +30,9 @@ import (
 	"github.com/beego/beego/context"
 	"github.com/beego/beego/logs"
 	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/controllers"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
 	"golang.org/x/time/rate"
 )
 
@@ -52,11 +57,20 @@ var tierLimits = map[Tier]int{
 	TierZenCustom:     100000,
 }
 
+// ipBucketPrefix marks rate limit buckets keyed by client IP rather than API
+// key. Requests without any API key fall back to a per-IP bucket.
+const ipBucketPrefix = "ip:"
+
+// orgBucketPrefix marks the rate limit buckets shared by all the requests of
+// an organization, on top of their key or IP bucket.
+const orgBucketPrefix = "org:"
+
 // keyEntry holds the rate limiter and last-seen time for a single API key.
 type keyEntry struct {
 	limiter  *rate.Limiter
 	lastSeen time.Time
 	tier     Tier
+	limit    int // requests per minute
 }
 
 // RateLimiter tracks per-key rate limiters with automatic cleanup of stale entries.
@@ -66,11 +80,30 @@ type RateLimiter struct {
 	tierFunc func(apiKey string) Tier
 	stopCh   chan struct{}
 
+	// limitFunc optionally resolves an explicit per-minute limit for a bucket
+	// key, taking precedence over the tier limit. A return of 0 falls back
+	// to the tier.
+	limitFunc func(key string) int
+
+	// redis optionally holds the buckets in Redis so all replicas share
+	// them; see ratelimit_redis.go. Nil keeps them in process.
+	redis *redisRateLimitStore
+
 	// Metrics counters — accessed atomically.
 	totalAllowed uint64
 	totalDenied  uint64
 }
 
+// rateLimitResult is the outcome of taking a request from a bucket and the
+// state of the bucket afterwards.
+type rateLimitResult struct {
+	allowed    bool
+	limit      int           // per-minute allowance
+	remaining  int           // requests available immediately
+	reset      time.Duration // until the bucket is full again
+	retryAfter int           // seconds until the next request is allowed, when denied
+}
+
 // NewRateLimiter creates a RateLimiter that starts a background goroutine to
 // evict stale entries every cleanupInterval. The tierFunc callback resolves an
 // API key to its Tier; pass nil to always use TierZenFree.
@@ -107,6 +140,32 @@ func (rl *RateLimiter) Allow(apiKey string) bool {
 	return false
 }
 
+// Take counts a request against bucket and reports whether it is allowed,
+// along with the bucket's state. With a Redis store the bucket is shared by
+// all replicas; if Redis fails, the in-process bucket is used instead so
+// an outage never denies service.
+func (rl *RateLimiter) Take(bucket string) rateLimitResult {
+	if rl.redis != nil {
+		result, err := rl.redis.take(bucket, rl.limitFor(bucket))
+		if err == nil {
+			if result.allowed {
+				atomic.AddUint64(&rl.totalAllowed, 1)
+			} else {
+				atomic.AddUint64(&rl.totalDenied, 1)
+			}
+			return result
+		}
+		logs.Warning("rate_limit: redis unavailable, using local bucket for key=%s: %v", maskKey(bucket), err)
+	}
+
+	result := rateLimitResult{allowed: rl.Allow(bucket)}
+	result.limit, result.remaining, result.reset = rl.State(bucket)
+	if !result.allowed {
+		result.retryAfter = rl.RetryAfter(bucket)
+	}
+	return result
+}
+
 // RetryAfter returns the number of seconds until the next token is available
 // for the given API key. Returns 0 if the key has no entry.
 func (rl *RateLimiter) RetryAfter(apiKey string) int {
@@ -129,14 +188,42 @@ func (rl *RateLimiter) RetryAfter(apiKey string) int {
 	return seconds
 }
 
+// State returns the per-minute limit, the number of requests that can be made
+// immediately, and the time until the bucket is fully refilled for the given
+// key. Unknown keys report their would-be limit with a full bucket.
+func (rl *RateLimiter) State(apiKey string) (limit int, remaining int, reset time.Duration) {
+	rl.mu.RLock()
+	entry, ok := rl.keys[apiKey]
+	rl.mu.RUnlock()
+
+	if !ok {
+		limit = rl.limitFor(apiKey)
+		return limit, burstFor(limit), 0
+	}
+
+	tokens := entry.limiter.Tokens()
+	if tokens < 0 {
+		tokens = 0
+	}
+	missing := float64(entry.limiter.Burst()) - tokens
+	if missing > 0 && entry.limiter.Limit() > 0 {
+		reset = time.Duration(missing / float64(entry.limiter.Limit()) * float64(time.Second))
+	}
+	return entry.limit, int(tokens), reset
+}
+
 // Metrics returns the current rate limit hit/pass counters.
 func (rl *RateLimiter) Metrics() (allowed, denied uint64) {
 	return atomic.LoadUint64(&rl.totalAllowed), atomic.LoadUint64(&rl.totalDenied)
 }
 
-// Stop terminates the background cleanup goroutine.
+// Stop terminates the background cleanup goroutine and closes the Redis
+// store, if any.
 func (rl *RateLimiter) Stop() {
 	close(rl.stopCh)
+	if rl.redis != nil {
+		_ = rl.redis.Close()
+	}
 }
 
 // getOrCreate returns an existing entry or creates a new one for the given key.
@@ -149,24 +236,24 @@ func (rl *RateLimiter) getOrCreate(apiKey string) *keyEntry {
 		return entry
 	}
 
-	tier := rl.tierFunc(apiKey)
-	reqPerMin := tierLimits[tier]
-	if reqPerMin == 0 {
-		reqPerMin = tierLimits[TierZenFree]
+	var tier Tier
+	reqPerMin := 0
+	if rl.limitFunc != nil {
+		reqPerMin = rl.limitFunc(apiKey)
+	}
+	if reqPerMin <= 0 {
+		tier = rl.tierFunc(apiKey)
+		reqPerMin = tierLimit(tier)
 	}
 
-	// rate.Limit is events per second; burst allows short spikes up to 20%
-	// of the per-minute allowance (minimum burst of 1).
+	// rate.Limit is events per second.
 	perSecond := rate.Limit(float64(reqPerMin) / 60.0)
-	burst := reqPerMin / 5
-	if burst < 1 {
-		burst = 1
-	}
 
 	entry = &keyEntry{
-		limiter:  rate.NewLimiter(perSecond, burst),
+		limiter:  rate.NewLimiter(perSecond, burstFor(reqPerMin)),
 		lastSeen: time.Now(),
 		tier:     tier,
+		limit:    reqPerMin,
 	}
 
 	rl.mu.Lock()
@@ -181,6 +268,64 @@ func (rl *RateLimiter) getOrCreate(apiKey string) *keyEntry {
 	return entry
 }
 
+// limitFor resolves the per-minute limit a new bucket for key would receive.
+func (rl *RateLimiter) limitFor(apiKey string) int {
+	if rl.limitFunc != nil {
+		if n := rl.limitFunc(apiKey); n > 0 {
+			return n
+		}
+	}
+	return tierLimit(rl.tierFunc(apiKey))
+}
+
+// burstFor allows short spikes up to 20% of the per-minute allowance
+// (minimum burst of 1).
+func burstFor(reqPerMin int) int {
+	burst := reqPerMin / 5
+	if burst < 1 {
+		burst = 1
+	}
+	return burst
+}
+
+// tierLimit returns the per-minute request allowance for a tier. Operators
+// can override the built-in values with RATE_LIMIT_TIER_LIMITS, e.g.
+// "zen-free=30,zen-pro=1000", or with features.rate_limits.tiers in
+// models.yaml. Unknown tiers get the zen-free allowance.
+func tierLimit(tier Tier) int {
+	if n := configuredTierLimit(parseLimitConfig("RATE_LIMIT_TIER_LIMITS"), tier); n > 0 {
+		return n
+	}
+	if n := configuredTierLimit(controllers.GetModelConfig().RateLimits().Tiers, tier); n > 0 {
+		return n
+	}
+	if n := tierLimits[tier]; n > 0 {
+		return n
+	}
+	return tierLimits[TierZenFree]
+}
+
+// configuredTierLimit returns the positive limit limits sets for tier, or 0.
+// When several names map to the tier (e.g. "pro" and "zen-pro"), the
+// canonical name wins, then the first in sorted order, so the result never
+// depends on map iteration order.
+func configuredTierLimit(limits map[string]int, tier Tier) int {
+	if n := limits[string(tier)]; n > 0 {
+		return n
+	}
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if n := limits[name]; n > 0 && mapPlanToTier(name) == tier {
+			return n
+		}
+	}
+	return 0
+}
+
 // cleanup periodically evicts entries not seen for staleThreshold (10 minutes).
 func (rl *RateLimiter) cleanup(interval time.Duration) {
 	const staleThreshold = 10 * time.Minute
@@ -208,21 +353,31 @@ func (rl *RateLimiter) cleanup(interval time.Duration) {
 // rateLimiterInstance is the singleton initialized by InitRateLimiter.
 var rateLimiterInstance *RateLimiter
 
-// InitRateLimiter creates the global rate limiter. Must be called once during
+// InitRateLimiter creates the global rate limiter, sharing its buckets
+// through Redis when rateLimitBackend is set. Must be called once during
 // startup (before beego.Run). Returns the instance so the caller can call
 // Stop() on shutdown.
 func InitRateLimiter(tierFunc func(string) Tier) *RateLimiter {
 	rateLimiterInstance = NewRateLimiter(tierFunc, 10*time.Minute)
+	rateLimiterInstance.limitFunc = DefaultLimitFunc
+	rateLimiterInstance.redis = initRateLimitStore()
+	controllers.SetZapRateLimit(allowZapRequest)
+	if rateLimiterInstance.redis != nil {
+		logs.Info("rate_limit: sharing buckets through %s", conf.GetConfigString("rateLimitBackend"))
+	}
 	return rateLimiterInstance
 }
 
 // RateLimitFilter is a Beego BeforeRouter filter that enforces per-key rate
 // limits on API endpoints. It extracts the API key from the Authorization
-// header (Bearer token) or X-API-Key header.
+// header (Bearer token) or X-API-Key header, falling back to the client IP
+// for requests that carry no key. Requests of an organization with an org
+// limit also take from the org's bucket. Every limited response carries the
+// X-RateLimit-* headers of the most constrained bucket; denied requests get
+// 429 with Retry-After.
 //
-// Rate-limited paths: /api/chat/completions, /api/messages, /v1/messages,
-// and other API endpoints that carry a bearer token.
-// Excluded: health, metrics, models (read-only), static, UI routes.
+// Rate-limited paths: all /v1/ API endpoints.
+// Excluded: health, readiness, metrics, version/system info.
 func RateLimitFilter(ctx *context.Context) {
 	if rateLimiterInstance == nil {
 		return
@@ -241,47 +396,146 @@ func RateLimitFilter(ctx *context.Context) {
 	}
 
 	apiKey := extractAPIKey(ctx)
-	if apiKey == "" {
-		// No key means unauthenticated — other filters will handle auth errors.
-		return
-	}
-
-	if rateLimiterInstance.Allow(apiKey) {
+	buckets := rateLimitBuckets(apiKey, util.GetClientIP(ctx.Request), rateLimitOrg(ctx, apiKey))
+	result, bucket := rateLimiterInstance.takeAll(buckets)
+	setRateLimitHeaders(ctx, result)
+	if result.allowed {
+		// Subscribers listen on their key, whichever bucket is running low.
+		controllers.NotifyRateLimitWarning(buckets[0], result.limit, result.remaining, result.reset)
 		return
 	}
 
 	// Rate limit exceeded — log and respond with 429.
-	retryAfter := rateLimiterInstance.RetryAfter(apiKey)
 	allowed, denied := rateLimiterInstance.Metrics()
 
-	// Mask the key for structured logging (show first 6 chars).
-	maskedKey := apiKey
-	if len(maskedKey) > 6 {
-		maskedKey = maskedKey[:6] + "..."
-	}
-
 	logs.Info("rate_limit_exceeded key=%s path=%s retry_after=%d total_allowed=%d total_denied=%d",
-		maskedKey, path, retryAfter, allowed, denied)
+		maskKey(bucket), path, result.retryAfter, allowed, denied)
 
-	ctx.ResponseWriter.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	ctx.ResponseWriter.Header().Set("Retry-After", fmt.Sprintf("%d", result.retryAfter))
 	ctx.ResponseWriter.Header().Set("X-RateLimit-Remaining", "0")
 	ctx.ResponseWriter.Header().Set("Content-Type", "application/json")
 	ctx.ResponseWriter.WriteHeader(http.StatusTooManyRequests)
 
 	body := fmt.Sprintf(
 		`{"error":{"message":"Rate limit exceeded. Retry after %d seconds.","type":"rate_limit_error","code":429}}`,
-		retryAfter,
+		result.retryAfter,
 	)
 	ctx.ResponseWriter.Write([]byte(body))
 }
 
+// rateLimitBuckets returns the buckets a request takes from: its API key's,
+// or its client IP's when it carries no key so anonymous callers cannot
+// bypass the limiter by omitting credentials, then its org's when the org
+// has a limit.
+func rateLimitBuckets(apiKey string, clientIP string, org string) []string {
+	buckets := []string{apiKey}
+	if apiKey == "" {
+		buckets[0] = ipBucketPrefix + clientIP
+	}
+	if org != "" && orgRateLimit(org) > 0 {
+		buckets = append(buckets, orgBucketPrefix+org)
+	}
+	return buckets
+}
+
+// takeAll takes a request from each of buckets and returns the result of
+// the most constrained one. The first denying bucket ends the request, so a
+// request denied by its key does not also use up its org's allowance.
+func (rl *RateLimiter) takeAll(buckets []string) (rateLimitResult, string) {
+	var result rateLimitResult
+	bucket := ""
+	for i, b := range buckets {
+		r := rl.Take(b)
+		if i == 0 || !r.allowed || r.remaining < result.remaining {
+			result, bucket = r, b
+		}
+		if !r.allowed {
+			break
+		}
+	}
+	return result, bucket
+}
+
+// allowZapRequest applies the limits of RateLimitFilter to one request
+// frame of a ZAP WebSocket connection, whose frames never pass through the
+// filter. auth is the frame's Authorization value.
+func allowZapRequest(auth string, clientIP string) (bool, int) {
+	if rateLimiterInstance == nil {
+		return true, 0
+	}
+	apiKey := strings.TrimPrefix(auth, "Bearer ")
+	result, bucket := rateLimiterInstance.takeAll(rateLimitBuckets(apiKey, clientIP, rateLimitKeyOrg(apiKey)))
+	if !result.allowed {
+		logs.Info("rate_limit_exceeded key=%s path=/v1/zap frame retry_after=%d", maskKey(bucket), result.retryAfter)
+	}
+	return result.allowed, result.retryAfter
+}
+
+// rateLimitOrg resolves the organization a request is counted against
+// from its credentials, without blocking: the org of its API key (see
+// rateLimitKeyOrg) or of the session user. The X-IAM-Org-Id header is not
+// trusted here, since the filter runs before it is verified. Unlike
+// GetEffectiveOrg it never falls back to the default org, which would put
+// every unresolved request in one bucket.
+func rateLimitOrg(ctx *context.Context, apiKey string) string {
+	if apiKey != "" {
+		return rateLimitKeyOrg(apiKey)
+	}
+	if ctx.Input.CruSession != nil {
+		if user := GetSessionUser(ctx); user != nil {
+			return user.Owner
+		}
+	}
+	return ""
+}
+
+// rateLimitKeyOrg resolves the organization of an API key without
+// blocking: the org of a key this replica has already authenticated, or of
+// a JWT.
+func rateLimitKeyOrg(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	if org := controllers.CachedAccessKeyOrg(apiKey); org != "" {
+		return org
+	}
+	if identity := tenantMembership.get(apiKey); identity != nil {
+		return identity.Owner
+	}
+	if isJwtTokenLike(apiKey) {
+		if claims, err := iamsdk.ParseJwtToken(apiKey); err == nil && claims.User.Owner != "" {
+			identity := identityFromUser(&claims.User)
+			tenantMembership.set(apiKey, identity)
+			return identity.Owner
+		}
+	}
+	return ""
+}
+
+// setRateLimitHeaders writes the standard X-RateLimit-* headers describing
+// a bucket's state. X-RateLimit-Limit is the per-minute allowance,
+// X-RateLimit-Remaining is the number of requests available immediately, and
+// X-RateLimit-Reset is the number of seconds until the bucket is full again.
+func setRateLimitHeaders(ctx *context.Context, result rateLimitResult) {
+	header := ctx.ResponseWriter.Header()
+	header.Set("X-RateLimit-Limit", fmt.Sprintf("%d", result.limit))
+	header.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", result.remaining))
+	header.Set("X-RateLimit-Reset", fmt.Sprintf("%d", int(math.Ceil(result.reset.Seconds()))))
+}
+
 // isRateLimitExempt returns true for paths that should bypass rate limiting.
 func isRateLimitExempt(path string) bool {
 	switch {
 	case path == "/v1/health" || path == "/health":
 		return true
+	case path == "/v1/ready" || path == "/ready":
+		return true
+	case path == "/healthz" || path == "/readyz":
+		return true
 	case path == "/v1/metrics" || path == "/metrics":
 		return true
+	case path == "/v1/commerce-balance-webhook":
+		return true
 	case strings.HasPrefix(path, "/v1/get-version-info"):
 		return true
 	case strings.HasPrefix(path, "/v1/get-system-info"):
@@ -359,6 +613,100 @@ func DefaultTierFunc(apiKey string) Tier {
 	return TierZenFree
 }
 
+// DefaultLimitFunc resolves explicit per-minute limits that take precedence
+// over tier limits:
+//
+//  1. Per-IP buckets (anonymous requests) use RATE_LIMIT_IP_LIMIT, then
+//     features.rate_limits.ip in models.yaml, defaulting to the zen-free
+//     tier allowance.
+//  2. Per-org buckets use orgRateLimit.
+//  3. Per-key overrides from RATE_LIMIT_KEY_LIMITS ("hk-abc=1200,hk-def=30"),
+//     exact match first, then prefix match like RATE_LIMIT_TIERS.
+//
+// Returns 0 when no explicit limit applies, so the key's tier limit is used.
+func DefaultLimitFunc(key string) int {
+	if strings.HasPrefix(key, ipBucketPrefix) {
+		if n := conf.GetConfigInt("RATE_LIMIT_IP_LIMIT"); n > 0 {
+			return n
+		}
+		if n := controllers.GetModelConfig().RateLimits().IP; n > 0 {
+			return n
+		}
+		return tierLimit(TierZenFree)
+	}
+	if strings.HasPrefix(key, orgBucketPrefix) {
+		return orgRateLimit(strings.TrimPrefix(key, orgBucketPrefix))
+	}
+
+	keyLimits := parseLimitConfig("RATE_LIMIT_KEY_LIMITS")
+	if n, ok := keyLimits[key]; ok {
+		return n
+	}
+	// The longest matching prefix wins, whatever the map iteration order.
+	prefixes := make([]string, 0, len(keyLimits))
+	for prefix := range keyLimits {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return keyLimits[prefix]
+		}
+	}
+	return 0
+}
+
+// orgRateLimit returns the per-minute limit shared by all requests of org:
+// its own limit from RATE_LIMIT_ORG_LIMITS ("acme=5000,initech=600") or
+// features.rate_limits.orgs in models.yaml, else the default for every org
+// from RATE_LIMIT_ORG_LIMIT or features.rate_limits.org. Returns 0 when the
+// org is not limited.
+func orgRateLimit(org string) int {
+	limits := controllers.GetModelConfig().RateLimits()
+	if n, ok := parseLimitConfig("RATE_LIMIT_ORG_LIMITS")[org]; ok {
+		return n
+	}
+	if n := limits.Orgs[org]; n > 0 {
+		return n
+	}
+	if n := conf.GetConfigInt("RATE_LIMIT_ORG_LIMIT"); n > 0 {
+		return n
+	}
+	if limits.Org > 0 {
+		return limits.Org
+	}
+	return 0
+}
+
+// parseLimitConfig reads a "name1=limit1,name2=limit2" setting from env (or
+// Beego app.conf) into a map of per-minute limits. Malformed and non-positive
+// entries are skipped.
+func parseLimitConfig(configKey string) map[string]int {
+	raw := strings.TrimSpace(conf.GetConfigString(configKey))
+	if raw == "" {
+		return nil
+	}
+
+	result := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || n <= 0 {
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = n
+	}
+	return result
+}
+
 // parseTierConfig reads RATE_LIMIT_TIERS from env (or Beego app.conf).
 // Format: "prefix1=tier1,prefix2=tier2"
 // Accepts both canonical zen-* names and legacy names (mapped automatically).
@@ -0,0 +1,168 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hanzoai/cloud/cache"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/redis/go-redis/v9"
+)
+
+// The rate limit buckets live in process by default, so each replica allows
+// the full limit. With a Redis backend every replica takes from the same
+// buckets:
+//
+//	rateLimitBackend  = redis                      ; "redis" or "valkey"; empty keeps buckets per process
+//	rateLimitRedisUrl = redis://:secret@valkey:6379/1 ; defaults to cacheRedisUrl
+
+const (
+	// rateLimitKeyPrefix prefixes the bucket keys. Buckets are stored under
+	// the SHA-256 of their name so API keys never reach Redis.
+	rateLimitKeyPrefix = "cloud:ratelimit:"
+
+	// rateLimitRedisTimeout bounds every bucket update; a slow Redis falls
+	// back to the in-process buckets instead of stalling the request.
+	rateLimitRedisTimeout = 250 * time.Millisecond
+)
+
+// tokenBucketScript refills the bucket in KEYS[1] at ARGV[1] tokens per
+// millisecond up to ARGV[2] tokens and takes one token if it can. It returns
+// whether the token was taken and the tokens left. Redis's clock is used so
+// replicas with skewed clocks agree on the refill.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// redisRateLimitStore keeps token buckets in Redis, shared by all replicas.
+type redisRateLimitStore struct {
+	client *redis.Client
+}
+
+// newRedisRateLimitStore connects to the server at url
+// (redis://[user:password@]host:port[/db], or rediss:// for TLS).
+func newRedisRateLimitStore(url string) (*redisRateLimitStore, error) {
+	if url == "" {
+		return nil, fmt.Errorf("rateLimitRedisUrl is not configured")
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &redisRateLimitStore{client: client}, nil
+}
+
+// initRateLimitStore returns the configured Redis store, or nil to keep the
+// buckets in process. It panics on a misconfigured backend, like the caches.
+func initRateLimitStore() *redisRateLimitStore {
+	kind := strings.ToLower(conf.GetConfigString("rateLimitBackend"))
+	switch kind {
+	case "", "memory":
+		return nil
+	case "redis", "valkey":
+	default:
+		panic(fmt.Sprintf("ratelimit: unknown rateLimitBackend %q", kind))
+	}
+
+	url := conf.GetConfigString("rateLimitRedisUrl")
+	if url == "" {
+		url = conf.GetConfigString("cacheRedisUrl")
+	}
+	store, err := newRedisRateLimitStore(url)
+	if err != nil {
+		panic(fmt.Sprintf("ratelimit: failed to connect to %s: %v", kind, err))
+	}
+	return store
+}
+
+// take takes a token from bucket, which allows limit requests per minute.
+func (s *redisRateLimitStore) take(bucket string, limit int) (rateLimitResult, error) {
+	burst := burstFor(limit)
+	perMilli := float64(limit) / 60000
+
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitRedisTimeout)
+	defer cancel()
+	reply, err := tokenBucketScript.Run(ctx, s.client, []string{rateLimitKeyPrefix + cache.HashKey(bucket)},
+		strconv.FormatFloat(perMilli, 'g', -1, 64), burst).Slice()
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	if len(reply) != 2 {
+		return rateLimitResult{}, fmt.Errorf("unexpected token bucket reply %v", reply)
+	}
+	allowed, _ := reply[0].(int64)
+	raw, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	return bucketResult(allowed == 1, limit, burst, tokens, perMilli), nil
+}
+
+// bucketResult describes a token bucket of burst tokens refilled at perMilli
+// tokens per millisecond that has tokens left after a take.
+func bucketResult(allowed bool, limit int, burst int, tokens float64, perMilli float64) rateLimitResult {
+	if tokens < 0 {
+		tokens = 0
+	}
+	result := rateLimitResult{
+		allowed:   allowed,
+		limit:     limit,
+		remaining: int(tokens),
+		reset:     time.Duration((float64(burst) - tokens) / perMilli * float64(time.Millisecond)),
+	}
+	if !allowed {
+		result.retryAfter = int(math.Ceil((1 - tokens) / perMilli / 1000))
+		if result.retryAfter < 1 {
+			result.retryAfter = 1
+		}
+	}
+	return result
+}
+
+func (s *redisRateLimitStore) Close() error {
+	return s.client.Close()
+}
@@ -15,8 +15,12 @@
 package routers
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/beego/beego/context"
 )
 
 func TestRateLimiterAllow(t *testing.T) {
@@ -190,8 +194,12 @@ func TestIsRateLimitExempt(t *testing.T) {
 	exemptPaths := []string{
 		"/v1/health",
 		"/health",
+		"/v1/ready",
+		"/healthz",
+		"/readyz",
 		"/v1/metrics",
 		"/metrics",
+		"/v1/commerce-balance-webhook",
 		"/v1/get-version-info",
 		"/v1/get-system-info",
 	}
@@ -285,3 +293,235 @@ func TestMapPlanToTier(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimiterLimitFuncOverride(t *testing.T) {
+	rl := NewRateLimiter(func(string) Tier { return TierZenEnterprise }, time.Hour)
+	defer rl.Stop()
+	rl.limitFunc = func(key string) int {
+		if key == "hk-capped" {
+			return 10 // burst = 2
+		}
+		return 0
+	}
+
+	for i := 0; i < 2; i++ {
+		if !rl.Allow("hk-capped") {
+			t.Fatalf("request %d should have been allowed", i)
+		}
+	}
+	if rl.Allow("hk-capped") {
+		t.Fatal("per-key limit should override the enterprise tier")
+	}
+
+	limit, _, _ := rl.State("hk-uncapped")
+	if limit != tierLimits[TierZenEnterprise] {
+		t.Errorf("expected tier limit %d for key without override, got %d", tierLimits[TierZenEnterprise], limit)
+	}
+}
+
+func TestRateLimiterState(t *testing.T) {
+	rl := NewRateLimiter(func(string) Tier { return TierZenFree }, time.Hour)
+	defer rl.Stop()
+
+	key := "hk-state-test"
+	limit, remaining, reset := rl.State(key)
+	if limit != 60 || remaining != 12 || reset != 0 {
+		t.Fatalf("unseen key: got limit=%d remaining=%d reset=%v", limit, remaining, reset)
+	}
+
+	for i := 0; i < 12; i++ {
+		rl.Allow(key)
+	}
+	limit, remaining, reset = rl.State(key)
+	if limit != 60 {
+		t.Errorf("expected limit 60, got %d", limit)
+	}
+	if remaining != 0 {
+		t.Errorf("expected 0 remaining after burst, got %d", remaining)
+	}
+	if reset <= 0 {
+		t.Errorf("expected positive reset after burst, got %v", reset)
+	}
+}
+
+func TestDefaultLimitFunc(t *testing.T) {
+	t.Setenv("RATE_LIMIT_KEY_LIMITS", "hk-exact=42,hk-pre=7,hk-prefix=9,hk-=3")
+	t.Setenv("RATE_LIMIT_IP_LIMIT", "15")
+
+	tests := []struct {
+		key      string
+		expected int
+	}{
+		{"hk-exact", 42},
+		{"hk-prefixed-key", 9},
+		{"hk-pre-key", 7},
+		{"hk-other", 3},
+		{"sk-other", 0},
+		{ipBucketPrefix + "203.0.113.7", 15},
+	}
+	// Repeat so a result that depends on map iteration order shows up.
+	for i := 0; i < 20; i++ {
+		for _, tt := range tests {
+			if got := DefaultLimitFunc(tt.key); got != tt.expected {
+				t.Fatalf("DefaultLimitFunc(%q) = %d, want %d", tt.key, got, tt.expected)
+			}
+		}
+	}
+}
+
+func TestTierLimitOverride(t *testing.T) {
+	t.Setenv("RATE_LIMIT_TIER_LIMITS", "free=30,zen-pro=900,bogus")
+
+	if got := tierLimit(TierZenFree); got != 30 {
+		t.Errorf("expected overridden zen-free limit 30, got %d", got)
+	}
+	if got := tierLimit(TierZenPro); got != 900 {
+		t.Errorf("expected overridden zen-pro limit 900, got %d", got)
+	}
+	if got := tierLimit(TierZenTeam); got != tierLimits[TierZenTeam] {
+		t.Errorf("expected built-in zen-team limit, got %d", got)
+	}
+}
+
+func TestTierLimitOverrideAliases(t *testing.T) {
+	t.Setenv("RATE_LIMIT_TIER_LIMITS", "pro=500,zen-pro=900,free=20,developer=40")
+
+	for i := 0; i < 20; i++ {
+		if got := tierLimit(TierZenPro); got != 900 {
+			t.Fatalf("expected canonical zen-pro limit 900, got %d", got)
+		}
+		if got := tierLimit(TierZenFree); got != 40 {
+			t.Fatalf("expected first sorted zen-free alias limit 40, got %d", got)
+		}
+	}
+}
+
+func TestOrgRateLimit(t *testing.T) {
+	if got := orgRateLimit("acme"); got != 0 {
+		t.Errorf("orgRateLimit() without config = %d, want 0 (unlimited)", got)
+	}
+
+	t.Setenv("RATE_LIMIT_ORG_LIMIT", "300")
+	t.Setenv("RATE_LIMIT_ORG_LIMITS", "acme=5000")
+	if got := orgRateLimit("acme"); got != 5000 {
+		t.Errorf("orgRateLimit(acme) = %d, want 5000", got)
+	}
+	if got := orgRateLimit("initech"); got != 300 {
+		t.Errorf("orgRateLimit(initech) = %d, want the default 300", got)
+	}
+	if got := DefaultLimitFunc(orgBucketPrefix + "acme"); got != 5000 {
+		t.Errorf("DefaultLimitFunc(org:acme) = %d, want 5000", got)
+	}
+}
+
+func newRateLimitContext(apiKey string, org string) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/v1/chat/completions", nil)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if org != "" {
+		req.Header.Set("X-IAM-Org-Id", org)
+	}
+	resp := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(resp, req)
+	return ctx, resp
+}
+
+func TestRateLimitFilterOrgBucket(t *testing.T) {
+	// 10/min per org => burst of 2 shared by all of acme's keys.
+	t.Setenv("RATE_LIMIT_ORG_LIMITS", "acme=10")
+
+	previous := rateLimiterInstance
+	rateLimiterInstance = NewRateLimiter(nil, time.Hour)
+	rateLimiterInstance.limitFunc = DefaultLimitFunc
+	defer func() {
+		rateLimiterInstance.Stop()
+		rateLimiterInstance = previous
+	}()
+
+	for _, key := range []string{"hk-acme-a", "hk-acme-b", "hk-acme-c"} {
+		tenantMembership.set(key, &tenantIdentity{Owner: "acme", Name: key})
+	}
+	tenantMembership.set("hk-other", &tenantIdentity{Owner: "initech", Name: "other"})
+
+	for i, key := range []string{"hk-acme-a", "hk-acme-b"} {
+		ctx, resp := newRateLimitContext(key, "")
+		RateLimitFilter(ctx)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, resp.Code)
+		}
+		if got := resp.Header().Get("X-RateLimit-Limit"); got != "10" {
+			t.Errorf("request %d X-RateLimit-Limit = %q, want the org's 10", i, got)
+		}
+	}
+
+	ctx, resp := newRateLimitContext("hk-acme-c", "initech")
+	RateLimitFilter(ctx)
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 once the org bucket is empty, whatever org the header claims", resp.Code)
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After on a 429")
+	}
+
+	// Another org's keys are unaffected, even when they claim acme.
+	ctx, resp = newRateLimitContext("hk-other", "acme")
+	RateLimitFilter(ctx)
+	if resp.Code != http.StatusOK {
+		t.Errorf("other org status = %d, want 200", resp.Code)
+	}
+	if got := resp.Header().Get("X-RateLimit-Limit"); got != "60" {
+		t.Errorf("other org X-RateLimit-Limit = %q, want the key's 60", got)
+	}
+}
+
+func TestAllowZapRequest(t *testing.T) {
+	// 10/min per key => burst of 2, shared with the key's HTTP requests.
+	t.Setenv("RATE_LIMIT_KEY_LIMITS", "hk-zap=10")
+
+	previous := rateLimiterInstance
+	rateLimiterInstance = NewRateLimiter(nil, time.Hour)
+	rateLimiterInstance.limitFunc = DefaultLimitFunc
+	defer func() {
+		rateLimiterInstance.Stop()
+		rateLimiterInstance = previous
+	}()
+
+	ctx, resp := newRateLimitContext("hk-zap", "")
+	RateLimitFilter(ctx)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("upgrade status = %d, want 200", resp.Code)
+	}
+	if allowed, _ := allowZapRequest("Bearer hk-zap", "10.0.0.1"); !allowed {
+		t.Fatal("first frame denied, want allowed")
+	}
+	allowed, retryAfter := allowZapRequest("Bearer hk-zap", "10.0.0.1")
+	if allowed {
+		t.Fatal("frame allowed past the key's burst, want denied")
+	}
+	if retryAfter < 1 {
+		t.Errorf("retryAfter = %d, want at least 1", retryAfter)
+	}
+
+	// Frames without auth are limited per client IP, not by the key.
+	if allowed, _ := allowZapRequest("", "10.0.0.1"); !allowed {
+		t.Error("anonymous frame denied by another key's bucket")
+	}
+}
+
+func TestBucketResult(t *testing.T) {
+	// 60/min => 0.001 tokens per ms and a burst of 12.
+	result := bucketResult(true, 60, 12, 11.5, 0.001)
+	if !result.allowed || result.limit != 60 || result.remaining != 11 {
+		t.Errorf("bucketResult() = %+v", result)
+	}
+	if result.reset != 500*time.Millisecond {
+		t.Errorf("reset = %v, want 500ms", result.reset)
+	}
+
+	result = bucketResult(false, 60, 12, 0.25, 0.001)
+	if result.allowed || result.remaining != 0 || result.retryAfter != 1 {
+		t.Errorf("denied bucketResult() = %+v", result)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Route policies. The endpoints listed in routePolicies are authorized here
+// rather than by permissionFilter's admin-only rule or by checks in their
+// controllers. Each names the access it needs to the organization whose
+// resources it reads or changes: the "owner" of the query and of the JSON
+// body, and the owner of the "store" of the query.
+//
+//	accessAdmin      global admins only
+//	accessOrgAdmin   admins of the owner organization, and global admins
+//	accessOrgMember  members of the owner organization, and the above
+//
+// Every admin endpoint is listed, GETs included: route policies hold
+// whether or not preview mode is enabled.
+//
+// Organization admins are granted through IAM permissions: an enabled
+// permission with the "Admin" action makes the users it lists, and the
+// holders of the roles it lists, admins of their own organization.
+// Resources owned by "admin" are global and need a global admin.
+
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/context"
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/controllers"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+type routeAccess int
+
+const (
+	accessOrgMember routeAccess = iota
+	accessOrgAdmin
+	accessAdmin
+)
+
+// routePolicy is the access a route needs. A pattern ending in "*" matches
+// the paths it prefixes.
+type routePolicy struct {
+	pattern string
+	access  routeAccess
+}
+
+var routePolicies = []routePolicy{
+	{"/v1/add-model-provider", accessAdmin},
+	{"/v1/update-model-provider", accessAdmin},
+	{"/v1/delete-model-provider", accessAdmin},
+	{"/v1/test-model-provider", accessAdmin},
+
+	{"/v1/update-kms-secret", accessAdmin},
+	{"/v1/refresh-kms-secrets", accessAdmin},
+	{"/v1/get-secret-audits", accessAdmin},
+	{"/v1/verify-secret-audits", accessAdmin},
+	{"/v1/get-kms-projects", accessAdmin},
+	{"/v1/add-kms-project", accessAdmin},
+	{"/v1/update-kms-project", accessAdmin},
+	{"/v1/delete-kms-project", accessAdmin},
+
+	{"/v1/get-admin-audits", accessAdmin},
+	{"/v1/get-request-logs", accessAdmin},
+	{"/v1/get-request-log", accessAdmin},
+	{"/v1/export-request-logs", accessAdmin},
+	{"/v1/tail-requests", accessAdmin},
+	{"/v1/get-request-log-setting", accessAdmin},
+	{"/v1/update-request-log-setting", accessAdmin},
+	{"/v1/get-pii-setting", accessAdmin},
+	{"/v1/update-pii-setting", accessAdmin},
+
+	{"/v1/validate-model-config", accessAdmin},
+	{"/v1/get-model-config-generations", accessAdmin},
+	{"/v1/rollback-model-config", accessAdmin},
+	{"/v1/freeze-live-pricing", accessAdmin},
+	{"/v1/get-pending-upstream-costs", accessAdmin},
+	{"/v1/approve-upstream-costs", accessAdmin},
+	{"/v1/reject-upstream-costs", accessAdmin},
+	{"/v1/sync-upstream-costs", accessAdmin},
+
+	{"/v1/get-model-entitlements", accessAdmin},
+	{"/v1/add-model-entitlement", accessAdmin},
+	{"/v1/update-model-entitlement", accessAdmin},
+	{"/v1/delete-model-entitlement", accessAdmin},
+	{"/v1/get-tenant-quotas", accessAdmin},
+	{"/v1/add-tenant-quota", accessAdmin},
+	{"/v1/update-tenant-quota", accessAdmin},
+	{"/v1/delete-tenant-quota", accessAdmin},
+	{"/v1/get-tenant-residencies", accessAdmin},
+	{"/v1/add-tenant-residency", accessAdmin},
+	{"/v1/update-tenant-residency", accessAdmin},
+	{"/v1/delete-tenant-residency", accessAdmin},
+
+	{"/v1/get-storage-retentions", accessAdmin},
+	{"/v1/add-storage-retention", accessAdmin},
+	{"/v1/update-storage-retention", accessAdmin},
+	{"/v1/delete-storage-retention", accessAdmin},
+	{"/v1/sweep-storage-retention", accessAdmin},
+
+	{"/v1/get-webhooks", accessOrgAdmin},
+	{"/v1/add-webhook", accessOrgAdmin},
+	{"/v1/update-webhook", accessOrgAdmin},
+	{"/v1/delete-webhook", accessOrgAdmin},
+	{"/v1/get-webhook-deliveries", accessOrgAdmin},
+	{"/v1/replay-webhook-delivery", accessOrgAdmin},
+
+	{"/v1/get-org-member-usages", accessAdmin},
+	{"/v1/update-org-member-limit", accessAdmin},
+	{"/v1/update-org-member-keys", accessAdmin},
+
+	{"/v1/get-prometheus-info", accessAdmin},
+	{"/v1/metrics", accessAdmin},
+
+	{"/v1/get-guardrail-policies", accessOrgMember},
+	{"/v1/add-guardrail-policy", accessOrgAdmin},
+	{"/v1/update-guardrail-policy", accessOrgAdmin},
+	{"/v1/delete-guardrail-policy", accessOrgAdmin},
+
+	{"/v1/get-prompt-template*", accessOrgMember},
+	{"/v1/add-prompt-template", accessOrgAdmin},
+	{"/v1/update-prompt-template", accessOrgAdmin},
+	{"/v1/delete-prompt-template", accessOrgAdmin},
+
+	{"/v1/get-eval-*", accessOrgMember},
+	{"/v1/add-eval-suite", accessOrgAdmin},
+	{"/v1/update-eval-suite", accessOrgAdmin},
+	{"/v1/delete-eval-suite", accessOrgAdmin},
+	{"/v1/run-eval-suite", accessOrgAdmin},
+
+	{"/v1/get-experiment*", accessOrgMember},
+	{"/v1/add-experiment", accessOrgAdmin},
+	{"/v1/update-experiment", accessOrgAdmin},
+	{"/v1/delete-experiment", accessOrgAdmin},
+	{"/v1/start-experiment", accessOrgAdmin},
+	{"/v1/stop-experiment", accessOrgAdmin},
+
+	{"/v1/create-tree-file-upload", accessOrgAdmin},
+	{"/v1/upload-tree-file-part", accessOrgAdmin},
+	{"/v1/get-tree-file-upload-parts", accessOrgAdmin},
+	{"/v1/complete-tree-file-upload", accessOrgAdmin},
+	{"/v1/abort-tree-file-upload", accessOrgAdmin},
+	{"/v1/get-presigned-url", accessOrgMember},
+	{"/v1/complete-presigned-upload", accessOrgAdmin},
+}
+
+// routeWriteAccess is the access a route needs instead of its policy's when
+// the request writes through its "method" query: get-presigned-url signs
+// PUT URLs as well as GETs.
+var routeWriteAccess = map[string]routeAccess{
+	"/v1/get-presigned-url": accessOrgAdmin,
+}
+
+func getRoutePolicy(path string) *routePolicy {
+	for i, policy := range routePolicies {
+		if prefix, ok := strings.CutSuffix(policy.pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return &routePolicies[i]
+			}
+		} else if path == policy.pattern {
+			return &routePolicies[i]
+		}
+	}
+	return nil
+}
+
+// routePolicyFilter lets the request through if its caller has the access
+// policy requires, and records the caller for the admin audit trail.
+func routePolicyFilter(ctx *context.Context, policy *routePolicy) {
+	if access, ok := routeWriteAccess[ctx.Request.URL.Path]; ok {
+		if method := strings.ToUpper(ctx.Input.Query("method")); method != "" && method != http.MethodGet {
+			policy = &routePolicy{pattern: policy.pattern, access: access}
+		}
+	}
+
+	identity := resolveTenantIdentity(ctx)
+	if identity == nil {
+		responseError(ctx, "auth:Please sign in first")
+		return
+	}
+	if !isRouteAllowed(identity, policy, requestOwners(ctx)) {
+		responseError(ctx, "auth:this operation requires admin privilege")
+		return
+	}
+	ctx.Input.SetData(controllers.AuthorizedUserKey, identity.Owner+"/"+identity.Name)
+}
+
+func isRouteAllowed(identity *tenantIdentity, policy *routePolicy, owners []string) bool {
+	if identity.Admin {
+		return true
+	}
+	if policy.access == accessAdmin || len(owners) == 0 {
+		return false
+	}
+	for _, owner := range owners {
+		if owner == "admin" || owner != identity.Owner {
+			return false
+		}
+	}
+	return policy.access == accessOrgMember || orgAdmins.isAdmin(identity)
+}
+
+// requestOwners returns the owners a request names, in its query and its
+// JSON body. A store id names its owner; a malformed one names none that
+// any organization matches.
+func requestOwners(ctx *context.Context) []string {
+	owners := []string{}
+	if owner := ctx.Input.Query("owner"); owner != "" {
+		owners = append(owners, owner)
+	}
+	if store := ctx.Input.Query("store"); store != "" {
+		owner, _, ok := strings.Cut(store, "/")
+		if !ok {
+			owner = ""
+		}
+		owners = append(owners, owner)
+	}
+	var body struct {
+		Owner string `json:"owner"`
+	}
+	if len(ctx.Input.RequestBody) > 0 && json.Unmarshal(ctx.Input.RequestBody, &body) == nil && body.Owner != "" {
+		owners = append(owners, body.Owner)
+	}
+	return owners
+}
+
+// orgAdminsTTL is how long the IAM permissions granting organization admin
+// are cached. When IAM cannot be reached, the last ones fetched are kept.
+const orgAdminsTTL = 60 * time.Second
+
+type orgAdminGrants struct {
+	mu        sync.Mutex
+	users     map[string]bool // "org/name", or "org/*" for every user of org
+	roles     map[string]bool // "org/role"
+	fetchedAt time.Time
+}
+
+var orgAdmins = &orgAdminGrants{}
+
+func (g *orgAdminGrants) isAdmin(identity *tenantIdentity) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.users == nil || time.Since(g.fetchedAt) >= orgAdminsTTL {
+		permissions, err := iamsdk.GetPermissions()
+		if err != nil {
+			logs.Warn("route policy: failed to get the IAM permissions: %v", err)
+			g.fetchedAt = time.Now()
+		} else {
+			g.load(permissions)
+		}
+	}
+
+	if g.users[identity.Owner+"/"+identity.Name] || g.users[identity.Owner+"/*"] {
+		return true
+	}
+	for _, role := range identity.Roles {
+		if g.roles[role] && strings.HasPrefix(role, identity.Owner+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// load replaces the grants with those of the enabled, approved permissions
+// allowing the "Admin" action.
+func (g *orgAdminGrants) load(permissions []*iamsdk.Permission) {
+	g.users = map[string]bool{}
+	g.roles = map[string]bool{}
+	g.fetchedAt = time.Now()
+	for _, permission := range permissions {
+		if !permission.IsEnabled || strings.EqualFold(permission.Effect, "Deny") ||
+			(permission.State != "" && permission.State != "Approved") {
+			continue
+		}
+		admin := false
+		for _, action := range permission.Actions {
+			admin = admin || strings.EqualFold(action, "Admin")
+		}
+		if !admin {
+			continue
+		}
+		for _, user := range permission.Users {
+			g.users[user] = true
+		}
+		for _, role := range permission.Roles {
+			g.roles[role] = true
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2023-2026 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beego/beego/context"
+	"github.com/hanzoai/cloud/controllers"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+func TestGetRoutePolicy(t *testing.T) {
+	tests := map[string]routeAccess{
+		"/v1/get-experiment-results":       accessOrgMember,
+		"/v1/get-prompt-template-versions": accessOrgMember,
+		"/v1/start-experiment":             accessOrgAdmin,
+		"/v1/run-eval-suite":               accessOrgAdmin,
+		"/v1/test-model-provider":          accessAdmin,
+		"/v1/get-tenant-quotas":            accessAdmin,
+		"/v1/sweep-storage-retention":      accessAdmin,
+		"/v1/update-pii-setting":           accessAdmin,
+		"/v1/get-webhooks":                 accessOrgAdmin,
+		"/v1/upload-tree-file-part":        accessOrgAdmin,
+		"/v1/get-tree-file-upload-parts":   accessOrgAdmin,
+	}
+	for path, access := range tests {
+		if policy := getRoutePolicy(path); policy == nil || policy.access != access {
+			t.Errorf("getRoutePolicy(%s) = %+v, want access %d", path, policy, access)
+		}
+	}
+	if policy := getRoutePolicy("/v1/get-providers"); policy != nil {
+		t.Errorf("getRoutePolicy(/v1/get-providers) = %+v, want none", policy)
+	}
+}
+
+func TestIsRouteAllowed(t *testing.T) {
+	orgAdmins.load([]*iamsdk.Permission{
+		{IsEnabled: true, Actions: []string{"Admin"}, Users: []string{"acme/alice"}, Roles: []string{"globex/admins"}},
+		{IsEnabled: false, Actions: []string{"Admin"}, Users: []string{"acme/mallory"}},
+		{IsEnabled: true, Actions: []string{"Read"}, Users: []string{"acme/bob"}},
+	})
+	t.Cleanup(func() { orgAdmins.users = nil })
+
+	alice := &tenantIdentity{Owner: "acme", Name: "alice"}
+	bob := &tenantIdentity{Owner: "acme", Name: "bob"}
+	mallory := &tenantIdentity{Owner: "acme", Name: "mallory"}
+	carol := &tenantIdentity{Owner: "globex", Name: "carol", Roles: []string{"globex/admins"}}
+	root := &tenantIdentity{Owner: "built-in", Name: "root", Admin: true}
+	member := &routePolicy{access: accessOrgMember}
+	orgAdmin := &routePolicy{access: accessOrgAdmin}
+	admin := &routePolicy{access: accessAdmin}
+
+	tests := []struct {
+		name     string
+		identity *tenantIdentity
+		policy   *routePolicy
+		owners   []string
+		allowed  bool
+	}{
+		{"member reads own org", bob, member, []string{"acme"}, true},
+		{"member changes own org", bob, orgAdmin, []string{"acme"}, false},
+		{"member reads other org", bob, member, []string{"globex"}, false},
+		{"org admin by user", alice, orgAdmin, []string{"acme", "acme"}, true},
+		{"org admin by role", carol, orgAdmin, []string{"globex"}, true},
+		{"disabled grant", mallory, orgAdmin, []string{"acme"}, false},
+		{"org admin moves to other org", alice, orgAdmin, []string{"acme", "globex"}, false},
+		{"org admin on global resources", alice, orgAdmin, []string{"admin"}, false},
+		{"no owner", alice, orgAdmin, nil, false},
+		{"org admin on admin route", alice, admin, []string{"acme"}, false},
+		{"global admin", root, admin, nil, true},
+	}
+	for _, tt := range tests {
+		if got := isRouteAllowed(tt.identity, tt.policy, tt.owners); got != tt.allowed {
+			t.Errorf("%s: isRouteAllowed = %v, want %v", tt.name, got, tt.allowed)
+		}
+	}
+}
+
+func TestRoutePolicyFilter(t *testing.T) {
+	orgAdmins.load([]*iamsdk.Permission{{IsEnabled: true, Actions: []string{"Admin"}, Users: []string{"acme/alice"}}})
+	t.Cleanup(func() { orgAdmins.users = nil })
+	tenantMembership.set("hk-test-policy-alice", &tenantIdentity{Owner: "acme", Name: "alice"})
+	tenantMembership.set("hk-test-policy-bob", &tenantIdentity{Owner: "acme", Name: "bob"})
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		token   string
+		body    string
+		allowed bool
+	}{
+		{"anonymous", http.MethodPost, "/v1/add-experiment", "", `{"owner":"acme"}`, false},
+		{"member", http.MethodPost, "/v1/add-experiment", "hk-test-policy-bob", `{"owner":"acme"}`, false},
+		{"org admin", http.MethodPost, "/v1/add-experiment", "hk-test-policy-alice", `{"owner":"acme"}`, true},
+		{"org admin, other org", http.MethodPost, "/v1/add-experiment", "hk-test-policy-alice", `{"owner":"globex"}`, false},
+		{"anonymous GET", http.MethodGet, "/v1/get-experiments?owner=acme", "", "", false},
+		{"anonymous GET of an admin route", http.MethodGet, "/v1/get-tenant-quotas", "", "", false},
+		{"org admin on an admin route", http.MethodPost, "/v1/add-model-provider", "hk-test-policy-alice", `{"owner":"acme"}`, false},
+		{"member uploads to a store", http.MethodPost, "/v1/create-tree-file-upload?store=acme/docs&key=a&filename=b", "hk-test-policy-bob", "", false},
+		{"member lists upload parts", http.MethodGet, "/v1/get-tree-file-upload-parts?store=acme/docs&key=a&uploadId=1", "hk-test-policy-bob", "", false},
+		{"member aborts an upload", http.MethodPost, "/v1/abort-tree-file-upload?store=acme/docs&key=a&uploadId=1", "hk-test-policy-bob", "", false},
+		{"org admin uploads to own store", http.MethodPost, "/v1/upload-tree-file-part?store=acme/docs&key=a&uploadId=1&partNumber=1", "hk-test-policy-alice", "", true},
+		{"org admin completes own upload", http.MethodPost, "/v1/complete-tree-file-upload?store=acme/docs&key=a&uploadId=1", "hk-test-policy-alice", "", true},
+		{"org admin uploads to other store", http.MethodPost, "/v1/create-tree-file-upload?store=globex/docs&key=a&filename=b", "hk-test-policy-alice", "", false},
+		{"org admin uploads to a global store", http.MethodPost, "/v1/create-tree-file-upload?store=admin/docs&key=a&filename=b", "hk-test-policy-alice", "", false},
+		{"org admin, malformed store", http.MethodPost, "/v1/create-tree-file-upload?store=acme&key=a&filename=b", "hk-test-policy-alice", "", false},
+		{"member presigns a download", http.MethodGet, "/v1/get-presigned-url?store=acme/docs&key=a", "hk-test-policy-bob", "", true},
+		{"member presigns an upload", http.MethodGet, "/v1/get-presigned-url?store=acme/docs&key=a&method=put&size=1", "hk-test-policy-bob", "", false},
+		{"member presigns a download from other store", http.MethodGet, "/v1/get-presigned-url?store=globex/docs&key=a", "hk-test-policy-bob", "", false},
+		{"org admin presigns an upload", http.MethodGet, "/v1/get-presigned-url?store=acme/docs&key=a&method=PUT&size=1", "hk-test-policy-alice", "", true},
+		{"member completes a presigned upload", http.MethodPost, "/v1/complete-presigned-upload?store=acme/docs&key=a", "hk-test-policy-bob", "", false},
+		{"org admin completes a presigned upload", http.MethodPost, "/v1/complete-presigned-upload?store=acme/docs&key=a", "hk-test-policy-alice", "", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(resp, req)
+		ctx.Input.RequestBody = []byte(tt.body)
+
+		AuthzFilter(ctx)
+
+		denied := resp.Body.Len() > 0
+		if denied == tt.allowed {
+			t.Errorf("%s: body = %q, want allowed %v", tt.name, resp.Body.String(), tt.allowed)
+		}
+		want := "acme/alice"
+		if tt.token == "hk-test-policy-bob" {
+			want = "acme/bob"
+		}
+		if tt.allowed && ctx.Input.GetData(controllers.AuthorizedUserKey) != want {
+			t.Errorf("%s: authorized user = %v", tt.name, ctx.Input.GetData(controllers.AuthorizedUserKey))
+		}
+	}
+}
@@ -46,6 +46,10 @@ func initAPI() {
 	beego.Router("/v1/signin", &controllers.ApiController{}, "POST:Signin")
 	beego.Router("/v1/signout", &controllers.ApiController{}, "POST:Signout")
 	beego.Router("/v1/get-account", &controllers.ApiController{}, "GET:GetAccount")
+	beego.Router("/v1/rotate-access-key", &controllers.ApiController{}, "POST:RotateAccessKey")
+	beego.Router("/v1/oauth/token", &controllers.ApiController{}, "POST:OAuthToken")
+	beego.Router("/v1/create-ephemeral-key", &controllers.ApiController{}, "POST:CreateEphemeralKey")
+	beego.Router("/v1/revoke-ephemeral-key", &controllers.ApiController{}, "POST:RevokeEphemeralKey")
 
 	beego.Router("/v1/get-global-videos", &controllers.ApiController{}, "GET:GetGlobalVideos")
 	beego.Router("/v1/get-videos", &controllers.ApiController{}, "GET:GetVideos")
@@ -71,7 +75,22 @@ func initAPI() {
 	beego.Router("/v1/update-provider", &controllers.ApiController{}, "POST:UpdateProvider")
 	beego.Router("/v1/add-provider", &controllers.ApiController{}, "POST:AddProvider")
 	beego.Router("/v1/delete-provider", &controllers.ApiController{}, "POST:DeleteProvider")
+	beego.Router("/v1/test-model-provider", &controllers.ApiController{}, "POST:TestModelProvider")
 	beego.Router("/v1/refresh-mcp-tools", &controllers.ApiController{}, "POST:RefreshMcpTools")
+	beego.Router("/v1/update-kms-secret", &controllers.ApiController{}, "POST:UpdateKmsSecret")
+	beego.Router("/v1/refresh-kms-secrets", &controllers.ApiController{}, "POST:RefreshKmsSecrets")
+	beego.Router("/v1/get-secret-audits", &controllers.ApiController{}, "GET:GetSecretAudits")
+	beego.Router("/v1/verify-secret-audits", &controllers.ApiController{}, "GET:VerifySecretAudits")
+	beego.Router("/v1/get-admin-audits", &controllers.ApiController{}, "GET:GetAdminAudits")
+
+	beego.Router("/v1/get-request-logs", &controllers.ApiController{}, "GET:GetRequestLogs")
+	beego.Router("/v1/get-request-log", &controllers.ApiController{}, "GET:GetRequestLog")
+	beego.Router("/v1/export-request-logs", &controllers.ApiController{}, "GET:ExportRequestLogs")
+	beego.Router("/v1/tail-requests", &controllers.ApiController{}, "GET:TailRequests")
+	beego.Router("/v1/get-request-log-setting", &controllers.ApiController{}, "GET:GetRequestLogSetting")
+	beego.Router("/v1/update-request-log-setting", &controllers.ApiController{}, "POST:UpdateRequestLogSetting")
+	beego.Router("/v1/get-pii-setting", &controllers.ApiController{}, "GET:GetPiiSetting")
+	beego.Router("/v1/update-pii-setting", &controllers.ApiController{}, "POST:UpdatePiiSetting")
 
 	beego.Router("/v1/get-global-files", &controllers.ApiController{}, "GET:GetGlobalFiles")
 	beego.Router("/v1/get-files", &controllers.ApiController{}, "GET:GetFiles")
@@ -184,6 +203,13 @@ func initAPI() {
 	beego.Router("/v1/update-tree-file", &controllers.ApiController{}, "POST:UpdateTreeFile")
 	beego.Router("/v1/add-tree-file", &controllers.ApiController{}, "POST:AddTreeFile")
 	beego.Router("/v1/delete-tree-file", &controllers.ApiController{}, "POST:DeleteTreeFile")
+	beego.Router("/v1/get-presigned-url", &controllers.ApiController{}, "GET:GetPresignedUrl")
+	beego.Router("/v1/complete-presigned-upload", &controllers.ApiController{}, "POST:CompletePresignedUpload")
+	beego.Router("/v1/create-tree-file-upload", &controllers.ApiController{}, "POST:CreateTreeFileUpload")
+	beego.Router("/v1/upload-tree-file-part", &controllers.ApiController{}, "POST:UploadTreeFilePart")
+	beego.Router("/v1/get-tree-file-upload-parts", &controllers.ApiController{}, "GET:GetTreeFileUploadParts")
+	beego.Router("/v1/complete-tree-file-upload", &controllers.ApiController{}, "POST:CompleteTreeFileUpload")
+	beego.Router("/v1/abort-tree-file-upload", &controllers.ApiController{}, "POST:AbortTreeFileUpload")
 	beego.Router("/v1/activate-file", &controllers.ApiController{}, "POST:ActivateFile")
 	beego.Router("/v1/get-active-file", &controllers.ApiController{}, "GET:GetActiveFile")
 
@@ -244,6 +270,8 @@ func initAPI() {
 	beego.Router("/v1/add-node-tunnel", &controllers.ApiController{}, "POST:AddNodeTunnel")
 	beego.Router("/v1/get-node-tunnel", &controllers.ApiController{}, "GET:GetNodeTunnel")
 	beego.Router("/v1/dev-bridge", &controllers.ApiController{}, "GET:DevBridge")
+	beego.Router("/v1/zap", &controllers.ApiController{}, "GET:ZapWebSocket;POST:ZapBatch")
+	beego.Router("/v1/mcp", &controllers.ApiController{}, "POST:McpServer")
 
 	beego.Router("/v1/get-sessions", &controllers.ApiController{}, "GET:GetSessions")
 	beego.Router("/v1/get-session", &controllers.ApiController{}, "GET:GetSession")
@@ -305,23 +333,101 @@ func initAPI() {
 	beego.Router("/v1/get-system-info", &controllers.ApiController{}, "GET:GetSystemInfo")
 	beego.Router("/v1/get-version-info", &controllers.ApiController{}, "GET:GetVersionInfo")
 	beego.Router("/v1/health", &controllers.ApiController{}, "GET:Health")
+	beego.Router("/v1/ready", &controllers.ApiController{}, "GET:Ready")
+	beego.Router("/healthz", &controllers.ApiController{}, "GET:Health")
+	beego.Router("/readyz", &controllers.ApiController{}, "GET:Ready")
 	beego.Router("/v1/get-prometheus-info", &controllers.ApiController{}, "GET:GetPrometheusInfo")
 	beego.Router("/v1/metrics", &controllers.ApiController{}, "GET:GetMetrics")
+	beego.Router("/v1/openapi.json", &controllers.ApiController{}, "GET:GetOpenAPISpec")
 
 	// Unified chat — OpenAI-compatible completions with optional RAG.
 	// /v1/chat is the new canonical route; /v1/chat/completions is kept as an
-	// alias for OpenAI SDK compatibility.
+	// alias for OpenAI SDK compatibility. The legacy /api paths of these and
+	// /v1/messages are rewritten here by LegacyApiRewriteFilter.
 	beego.Router("/v1/chat", &controllers.ApiController{}, "POST:ChatCompletions")
 	beego.Router("/v1/chat/completions", &controllers.ApiController{}, "POST:ChatCompletions")
 	beego.Router("/v1/completions", &controllers.ApiController{}, "POST:ChatCompletions")
 	beego.Router("/v1/models", &controllers.ApiController{}, "GET:ListModels")
+	beego.Router("/v1/models/:id(.+)", &controllers.ApiController{}, "GET:GetModel")
+	beego.Router("/v1/embeddings", &controllers.ApiController{}, "POST:Embeddings")
+	beego.Router("/v1/rerank", &controllers.ApiController{}, "POST:Rerank")
+	beego.Router("/v1/conversations", &controllers.ApiController{}, "POST:CreateConversation")
+	beego.Router("/v1/conversations/:id", &controllers.ApiController{}, "GET:GetConversation")
+	beego.Router("/v1/conversations/:id/messages", &controllers.ApiController{}, "POST:AppendConversationMessages")
+	beego.Router("/v1/conversations/:id/completions", &controllers.ApiController{}, "POST:ContinueConversation")
+	beego.Router("/v1/feedback", &controllers.ApiController{}, "POST:Feedback")
 	beego.Router("/v1/reload-model-config", &controllers.ApiController{}, "POST:ReloadModelConfig")
+	beego.Router("/v1/validate-model-config", &controllers.ApiController{}, "POST:ValidateModelConfig")
+	beego.Router("/v1/get-model-config-generations", &controllers.ApiController{}, "GET:GetModelConfigGenerations")
+	beego.Router("/v1/rollback-model-config", &controllers.ApiController{}, "POST:RollbackModelConfig")
+	beego.Router("/v1/freeze-live-pricing", &controllers.ApiController{}, "POST:FreezeLivePricing")
+	beego.Router("/v1/get-pending-upstream-costs", &controllers.ApiController{}, "GET:GetPendingUpstreamCosts")
+	beego.Router("/v1/approve-upstream-costs", &controllers.ApiController{}, "POST:ApproveUpstreamCosts")
+	beego.Router("/v1/reject-upstream-costs", &controllers.ApiController{}, "POST:RejectUpstreamCosts")
+	beego.Router("/v1/sync-upstream-costs", &controllers.ApiController{}, "POST:SyncUpstreamCosts")
 
 	beego.Router("/v1/get-model-routes", &controllers.ApiController{}, "GET:GetModelRoutes")
 	beego.Router("/v1/get-model-route", &controllers.ApiController{}, "GET:GetModelRoute")
 	beego.Router("/v1/add-model-route", &controllers.ApiController{}, "POST:AddModelRoute")
 	beego.Router("/v1/update-model-route", &controllers.ApiController{}, "POST:UpdateModelRoute")
 	beego.Router("/v1/delete-model-route", &controllers.ApiController{}, "POST:DeleteModelRoute")
+	beego.Router("/v1/get-model-entitlements", &controllers.ApiController{}, "GET:GetModelEntitlements")
+	beego.Router("/v1/add-model-entitlement", &controllers.ApiController{}, "POST:AddModelEntitlement")
+	beego.Router("/v1/update-model-entitlement", &controllers.ApiController{}, "POST:UpdateModelEntitlement")
+	beego.Router("/v1/delete-model-entitlement", &controllers.ApiController{}, "POST:DeleteModelEntitlement")
+	beego.Router("/v1/get-tenant-quotas", &controllers.ApiController{}, "GET:GetTenantQuotas")
+	beego.Router("/v1/add-tenant-quota", &controllers.ApiController{}, "POST:AddTenantQuota")
+	beego.Router("/v1/update-tenant-quota", &controllers.ApiController{}, "POST:UpdateTenantQuota")
+	beego.Router("/v1/delete-tenant-quota", &controllers.ApiController{}, "POST:DeleteTenantQuota")
+	beego.Router("/v1/get-storage-retentions", &controllers.ApiController{}, "GET:GetStorageRetentions")
+	beego.Router("/v1/add-storage-retention", &controllers.ApiController{}, "POST:AddStorageRetention")
+	beego.Router("/v1/update-storage-retention", &controllers.ApiController{}, "POST:UpdateStorageRetention")
+	beego.Router("/v1/delete-storage-retention", &controllers.ApiController{}, "POST:DeleteStorageRetention")
+	beego.Router("/v1/sweep-storage-retention", &controllers.ApiController{}, "POST:SweepStorageRetention")
+	beego.Router("/v1/get-guardrail-policies", &controllers.ApiController{}, "GET:GetGuardrailPolicies")
+	beego.Router("/v1/add-guardrail-policy", &controllers.ApiController{}, "POST:AddGuardrailPolicy")
+	beego.Router("/v1/update-guardrail-policy", &controllers.ApiController{}, "POST:UpdateGuardrailPolicy")
+	beego.Router("/v1/delete-guardrail-policy", &controllers.ApiController{}, "POST:DeleteGuardrailPolicy")
+	beego.Router("/v1/get-prompt-templates", &controllers.ApiController{}, "GET:GetPromptTemplates")
+	beego.Router("/v1/get-prompt-template", &controllers.ApiController{}, "GET:GetPromptTemplate")
+	beego.Router("/v1/get-prompt-template-versions", &controllers.ApiController{}, "GET:GetPromptTemplateVersions")
+	beego.Router("/v1/add-prompt-template", &controllers.ApiController{}, "POST:AddPromptTemplate")
+	beego.Router("/v1/update-prompt-template", &controllers.ApiController{}, "POST:UpdatePromptTemplate")
+	beego.Router("/v1/delete-prompt-template", &controllers.ApiController{}, "POST:DeletePromptTemplate")
+	beego.Router("/v1/get-eval-suites", &controllers.ApiController{}, "GET:GetEvalSuites")
+	beego.Router("/v1/get-eval-suite", &controllers.ApiController{}, "GET:GetEvalSuite")
+	beego.Router("/v1/add-eval-suite", &controllers.ApiController{}, "POST:AddEvalSuite")
+	beego.Router("/v1/update-eval-suite", &controllers.ApiController{}, "POST:UpdateEvalSuite")
+	beego.Router("/v1/delete-eval-suite", &controllers.ApiController{}, "POST:DeleteEvalSuite")
+	beego.Router("/v1/run-eval-suite", &controllers.ApiController{}, "POST:RunEvalSuite")
+	beego.Router("/v1/get-eval-runs", &controllers.ApiController{}, "GET:GetEvalRuns")
+	beego.Router("/v1/get-eval-run", &controllers.ApiController{}, "GET:GetEvalRun")
+	beego.Router("/v1/get-experiments", &controllers.ApiController{}, "GET:GetExperiments")
+	beego.Router("/v1/get-experiment", &controllers.ApiController{}, "GET:GetExperiment")
+	beego.Router("/v1/get-experiment-results", &controllers.ApiController{}, "GET:GetExperimentResults")
+	beego.Router("/v1/add-experiment", &controllers.ApiController{}, "POST:AddExperiment")
+	beego.Router("/v1/update-experiment", &controllers.ApiController{}, "POST:UpdateExperiment")
+	beego.Router("/v1/delete-experiment", &controllers.ApiController{}, "POST:DeleteExperiment")
+	beego.Router("/v1/start-experiment", &controllers.ApiController{}, "POST:StartExperiment")
+	beego.Router("/v1/stop-experiment", &controllers.ApiController{}, "POST:StopExperiment")
+	beego.Router("/v1/get-webhooks", &controllers.ApiController{}, "GET:GetWebhooks")
+	beego.Router("/v1/add-webhook", &controllers.ApiController{}, "POST:AddWebhook")
+	beego.Router("/v1/update-webhook", &controllers.ApiController{}, "POST:UpdateWebhook")
+	beego.Router("/v1/delete-webhook", &controllers.ApiController{}, "POST:DeleteWebhook")
+	beego.Router("/v1/get-webhook-deliveries", &controllers.ApiController{}, "GET:GetWebhookDeliveries")
+	beego.Router("/v1/replay-webhook-delivery", &controllers.ApiController{}, "POST:ReplayWebhookDelivery")
+	beego.Router("/v1/commerce-balance-webhook", &controllers.ApiController{}, "POST:CommerceBalanceWebhook")
+	beego.Router("/v1/get-tenant-residencies", &controllers.ApiController{}, "GET:GetTenantResidencies")
+	beego.Router("/v1/add-tenant-residency", &controllers.ApiController{}, "POST:AddTenantResidency")
+	beego.Router("/v1/update-tenant-residency", &controllers.ApiController{}, "POST:UpdateTenantResidency")
+	beego.Router("/v1/delete-tenant-residency", &controllers.ApiController{}, "POST:DeleteTenantResidency")
+	beego.Router("/v1/get-kms-projects", &controllers.ApiController{}, "GET:GetKmsProjects")
+	beego.Router("/v1/add-kms-project", &controllers.ApiController{}, "POST:AddKmsProject")
+	beego.Router("/v1/update-kms-project", &controllers.ApiController{}, "POST:UpdateKmsProject")
+	beego.Router("/v1/delete-kms-project", &controllers.ApiController{}, "POST:DeleteKmsProject")
+	beego.Router("/v1/get-org-member-usages", &controllers.ApiController{}, "GET:GetOrgMemberUsages")
+	beego.Router("/v1/update-org-member-limit", &controllers.ApiController{}, "POST:UpdateOrgMemberLimit")
+	beego.Router("/v1/update-org-member-keys", &controllers.ApiController{}, "POST:UpdateOrgMemberKeys")
 
 	// Anthropic Messages API compatible endpoints
 	beego.Router("/v1/messages", &controllers.ApiController{}, "POST:AnthropicMessages")
@@ -52,6 +52,10 @@ func StaticFilter(ctx *context.Context) {
 	if strings.HasPrefix(urlPath, "/v1/") || strings.HasPrefix(urlPath, "/v1/") {
 		return
 	}
+	// Kubernetes probes are served by the API router.
+	if urlPath == "/healthz" || urlPath == "/readyz" {
+		return
+	}
 
 	landingFolder := conf.GetConfigString("landingFolder")
 	if landingFolder != "" {
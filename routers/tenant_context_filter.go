@@ -15,13 +15,15 @@
 package routers
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/beego/beego/context"
+	"github.com/hanzoai/cloud/controllers"
 )
 
 const (
-	tenantContextOrgIDKey     = "tenant.orgId"
+	tenantContextOrgIDKey     = controllers.TenantOrgKey
 	tenantContextUserIDKey    = "tenant.userId"
 	tenantContextProjectIDKey = "tenant.projectId"
 	tenantContextEnvKey       = "tenant.env"
@@ -33,12 +35,32 @@ func getTenantHeader(ctx *context.Context, name string) string {
 
 // TenantContextFilter captures IAM identity headers from the gateway.
 // All headers use the X-IAM-* prefix — generic, not vendor-specific.
+//
+// The org is derived from the request's credentials, never from the headers
+// alone: the claimed org and user must match the authenticated caller (only
+// admins may claim another org), and claims the filter cannot verify are
+// rejected, so tenant scoping can be trusted downstream.
 func TenantContextFilter(ctx *context.Context) {
 	orgID := getTenantHeader(ctx, "X-IAM-Org-Id")
 	userID := getTenantHeader(ctx, "X-IAM-User-Id")
 	projectID := getTenantHeader(ctx, "X-IAM-Project-Id")
 	env := getTenantHeader(ctx, "X-IAM-Env")
 
+	if orgID != "" || userID != "" {
+		identity := resolveTenantIdentity(ctx)
+		if identity == nil {
+			rejectTenantClaims(ctx, nil, fmt.Errorf("X-IAM-Org-Id and X-IAM-User-Id require credentials that identify the caller"))
+			return
+		}
+		if err := checkTenantClaims(identity, orgID, userID); err != nil {
+			rejectTenantClaims(ctx, identity, err)
+			return
+		}
+		if orgID == "" {
+			orgID = identity.Owner
+		}
+	}
+
 	if orgID != "" {
 		ctx.Input.SetData(tenantContextOrgIDKey, orgID)
 	}
@@ -65,7 +87,8 @@ func getTenantContextValue(ctx *context.Context, key string) string {
 	return strings.TrimSpace(text)
 }
 
-// GetTenantOrgID returns the org from IAM context.
+// GetTenantOrgID returns the org from IAM context, verified against the
+// request's credentials by TenantContextFilter.
 func GetTenantOrgID(ctx *context.Context) string {
 	return getTenantContextValue(ctx, tenantContextOrgIDKey)
 }
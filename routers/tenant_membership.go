@@ -0,0 +1,206 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/context"
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+	"github.com/hanzoai/cloud/util"
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+	"golang.org/x/sync/singleflight"
+)
+
+// tenantIdentity is the authenticated caller of a request, as established
+// from the session, a JWT or an hk- API key.
+type tenantIdentity struct {
+	Owner string
+	Name  string
+	Id    string // IAM user id; unknown for hk- keys
+	Admin bool
+	Roles []string // IAM roles held, as "org/role"; unknown for hk- keys
+}
+
+// tenantMembershipCache remembers the identity behind each bearer token, so
+// validating tenant headers does not parse a JWT or call IAM per request.
+type tenantMembershipCache struct {
+	mu       sync.RWMutex
+	entries  map[string]*tenantMembershipEntry
+	rejected map[string]time.Time // hk- keys IAM refused, until the time given
+	group    singleflight.Group
+	client   *http.Client
+}
+
+type tenantMembershipEntry struct {
+	identity  *tenantIdentity
+	fetchedAt time.Time
+}
+
+var tenantMembership = &tenantMembershipCache{
+	entries:  map[string]*tenantMembershipEntry{},
+	rejected: map[string]time.Time{},
+	client:   &http.Client{Timeout: balanceHTTPTimeout},
+}
+
+func (m *tenantMembershipCache) get(token string) *tenantIdentity {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[token]
+	if !ok || time.Since(entry.fetchedAt) > userKeyCacheTTL {
+		return nil
+	}
+	return entry.identity
+}
+
+func (m *tenantMembershipCache) set(token string, identity *tenantIdentity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.entries[token] = &tenantMembershipEntry{identity: identity, fetchedAt: now}
+	for key, entry := range m.entries {
+		if now.Sub(entry.fetchedAt) > userKeyCacheTTL {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *tenantMembershipCache) isRejected(token string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	expiresAt, ok := m.rejected[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (m *tenantMembershipCache) setRejected(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.rejected[token] = now.Add(rejectedKeyTTL)
+	for key, expiresAt := range m.rejected {
+		if now.After(expiresAt) {
+			delete(m.rejected, key)
+		}
+	}
+}
+
+func identityFromUser(user *iamsdk.User) *tenantIdentity {
+	identity := &tenantIdentity{Owner: user.Owner, Name: user.Name, Id: user.Id, Admin: util.IsAdmin(user)}
+	for _, role := range user.Roles {
+		if role == nil {
+			continue
+		}
+		identity.Roles = append(identity.Roles, role.Owner+"/"+role.Name)
+	}
+	return identity
+}
+
+// resolveTenantIdentity returns the authenticated caller of ctx, or nil when
+// the request carries no credentials the filters can verify (no credentials,
+// provider sk- keys, publishable keys, or an unreachable IAM).
+func resolveTenantIdentity(ctx *context.Context) *tenantIdentity {
+	if ctx.Input.CruSession != nil {
+		if user := GetSessionUser(ctx); user != nil && user.Owner != "" {
+			return identityFromUser(user)
+		}
+	}
+
+	token := parseBearerToken(ctx)
+	if token == "" {
+		return nil
+	}
+	if identity := tenantMembership.get(token); identity != nil {
+		return identity
+	}
+
+	if isJwtTokenLike(token) {
+		claims, err := iamsdk.ParseJwtToken(token)
+		if err != nil || claims.User.Owner == "" {
+			return nil
+		}
+		identity := identityFromUser(&claims.User)
+		tenantMembership.set(token, identity)
+		return identity
+	}
+
+	if strings.HasPrefix(token, "hk-") {
+		if tenantMembership.isRejected(token) {
+			return nil
+		}
+		v, _, _ := tenantMembership.group.Do(token, func() (interface{}, error) {
+			userKey, rejected := fetchIAMKeyUser(tenantMembership.client,
+				strings.TrimRight(conf.GetConfigString("iamEndpoint"), "/"),
+				conf.GetConfigString("clientId"), conf.GetConfigString("clientSecret"), token)
+			if rejected {
+				tenantMembership.setRejected(token)
+			}
+			if userKey == "" {
+				return nil, nil
+			}
+			owner, name := util.GetOwnerAndNameFromIdNoCheck(userKey)
+			identity := &tenantIdentity{Owner: owner, Name: name}
+			tenantMembership.set(token, identity)
+			return identity, nil
+		})
+		identity, _ := v.(*tenantIdentity)
+		return identity
+	}
+
+	return nil
+}
+
+// checkTenantClaims verifies that identity may act as the claimed org and
+// user. Callers belong to the org that owns them; admins may claim any org.
+func checkTenantClaims(identity *tenantIdentity, orgID string, userID string) error {
+	if orgID != "" && orgID != identity.Owner && !identity.Admin {
+		return fmt.Errorf("X-IAM-Org-Id %s does not match the organization of the authenticated user", orgID)
+	}
+	if userID != "" && !identity.Admin &&
+		userID != identity.Name && userID != identity.Owner+"/"+identity.Name && (identity.Id == "" || userID != identity.Id) {
+		return fmt.Errorf("X-IAM-User-Id %s does not match the authenticated user", userID)
+	}
+	return nil
+}
+
+// rejectTenantClaims answers a request whose tenant headers contradict its
+// credentials, or that has no credentials to check them against (identity
+// is nil).
+func rejectTenantClaims(ctx *context.Context, identity *tenantIdentity, err error) {
+	status, errType, code := http.StatusForbidden, "permission_error", "tenant_mismatch"
+	if identity == nil {
+		status, errType, code = http.StatusUnauthorized, "authentication_error", "tenant_unverified"
+		logs.Warning("tenant_context: rejected %s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+	} else {
+		logs.Warning("tenant_context: rejected %s %s for %s/%s: %v",
+			ctx.Request.Method, ctx.Request.URL.Path, identity.Owner, identity.Name, err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": err.Error(),
+			"type":    errType,
+			"code":    code,
+		},
+	})
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/json")
+	ctx.ResponseWriter.WriteHeader(status)
+	ctx.ResponseWriter.Write(body)
+}
@@ -0,0 +1,85 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beego/beego/context"
+)
+
+func TestCheckTenantClaims(t *testing.T) {
+	member := &tenantIdentity{Owner: "acme", Name: "alice", Id: "u-1"}
+	admin := &tenantIdentity{Owner: "hanzo", Name: "root", Admin: true}
+
+	tests := []struct {
+		name     string
+		identity *tenantIdentity
+		orgID    string
+		userID   string
+		wantErr  bool
+	}{
+		{"own org", member, "acme", "", false},
+		{"own user by name", member, "acme", "alice", false},
+		{"own user by id", member, "", "acme/alice", false},
+		{"own user by iam id", member, "", "u-1", false},
+		{"other org", member, "globex", "", true},
+		{"other user", member, "acme", "bob", true},
+		{"admin claims any org", admin, "globex", "bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTenantClaims(tt.identity, tt.orgID, tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkTenantClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTenantContextFilterRejectsMismatch(t *testing.T) {
+	tenantMembership.set("hk-test-acme", &tenantIdentity{Owner: "acme", Name: "alice"})
+
+	for _, tt := range []struct {
+		token      string
+		org        string
+		wantStatus int
+	}{
+		{"hk-test-acme", "acme", http.StatusOK},
+		{"hk-test-acme", "globex", http.StatusForbidden},
+		{"", "globex", http.StatusUnauthorized},
+		{"sk-provider-key", "globex", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		req.Header.Set("X-IAM-Org-Id", tt.org)
+		resp := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(resp, req)
+
+		TenantContextFilter(ctx)
+
+		if resp.Code != tt.wantStatus {
+			t.Errorf("org %s: status = %d, want %d", tt.org, resp.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusOK && GetTenantOrgID(ctx) != tt.org {
+			t.Errorf("org %s: tenant org = %q", tt.org, GetTenantOrgID(ctx))
+		}
+	}
+}
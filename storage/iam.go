@@ -16,7 +16,10 @@ package storage
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 
 	"github.com/hanzoai/cloud/conf"
 	"github.com/hanzoai/cloud/i18n"
@@ -76,6 +79,55 @@ func (p *IamProvider) PutObject(user string, parent string, key string, fileBuff
 	return fileUrl, nil
 }
 
+// PutObjectStream uploads an object to IAM as a multipart form written from
+// reader while it is sent, so the file is never held in memory whole.
+func (p *IamProvider) PutObjectStream(user string, parent string, key string, reader io.Reader, size int64) (string, error) {
+	queryMap := map[string]string{
+		"owner":        conf.GetConfigString("iamOrganization"),
+		"user":         user,
+		"application":  conf.GetConfigString("iamApplication"),
+		"tag":          "HanzoCloud",
+		"parent":       parent,
+		"fullFilePath": fmt.Sprintf("Direct/%s/%s", p.providerName, key),
+	}
+
+	body, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	written := make(chan error, 1)
+	go func() {
+		part, err := form.CreateFormFile("file", "file")
+		if err == nil {
+			_, err = io.Copy(part, reader)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		_ = bodyWriter.CloseWithError(err)
+		written <- err
+	}()
+
+	respBytes, err := iamsdk.DoPostBytesRaw(iamsdk.GetUrl("upload-resource", queryMap), form.FormDataContentType(), body)
+	_ = body.Close()
+	// A failed read of reader, such as an ObjectTooLargeError, explains a
+	// failed request better than the request's own error.
+	if writeErr := <-written; writeErr != nil && writeErr != io.ErrClosedPipe {
+		return "", writeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var response iamsdk.Response
+	if err = json.Unmarshal(respBytes, &response); err != nil {
+		return "", err
+	}
+	if response.Status != "ok" {
+		return "", fmt.Errorf("%s", response.Msg)
+	}
+	fileUrl, _ := response.Data.(string)
+	return fileUrl, nil
+}
+
 func (p *IamProvider) DeleteObject(key string) error {
 	resource := iamsdk.Resource{
 		Name: key,
@@ -70,6 +70,10 @@ func (p *LocalFileSystemStorageProvider) ListObjects(prefix string) ([]*Object,
 }
 
 func (p *LocalFileSystemStorageProvider) PutObject(user string, parent string, key string, fileBuffer *bytes.Buffer) (string, error) {
+	return p.PutObjectStream(user, parent, key, fileBuffer, int64(fileBuffer.Len()))
+}
+
+func (p *LocalFileSystemStorageProvider) PutObjectStream(user string, parent string, key string, reader io.Reader, size int64) (string, error) {
 	fullPath := filepath.Join(p.path, key)
 	err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
 	if err != nil {
@@ -82,8 +86,14 @@ func (p *LocalFileSystemStorageProvider) PutObject(user string, parent string, k
 	}
 	defer dst.Close()
 
-	_, err = io.Copy(dst, fileBuffer)
-	return fullPath, err
+	_, err = io.Copy(dst, reader)
+	if err != nil {
+		// Don't leave a partial file behind, e.g. when an upload exceeds its limit
+		dst.Close()
+		os.Remove(fullPath)
+		return "", err
+	}
+	return fullPath, nil
 }
 
 func (p *LocalFileSystemStorageProvider) DeleteObject(key string) error {
@@ -29,13 +29,15 @@ type StorageProvider interface {
 	DeleteObject(key string) error
 }
 
-func GetStorageProvider(typ string, clientId string, clientSecret string, providerName string, vectorStoreId string, lang string) (StorageProvider, error) {
+func GetStorageProvider(typ string, clientId string, clientSecret string, region string, providerUrl string, providerName string, vectorStoreId string, lang string) (StorageProvider, error) {
 	var p StorageProvider
 	var err error
 	if typ == "Local File System" {
 		p, err = NewLocalFileSystemStorageProvider(clientId)
 	} else if typ == "OpenAI File System" {
 		p, err = NewOpenAIFileSystemStorageProvider(vectorStoreId, clientSecret)
+	} else if typ == "AWS S3" || typ == "MinIO" {
+		p, err = NewS3StorageProvider(clientId, clientSecret, region, providerUrl)
 	} else {
 		p, err = NewIamProvider(providerName, lang)
 	}
@@ -0,0 +1,431 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3Timeout       = 60 * time.Second
+	// s3PartSize is the part size of streamed uploads; S3 needs at least 5 MB
+	// and allows 10,000 parts, so objects up to ~160 GB can be streamed.
+	s3PartSize = 16 << 20
+)
+
+// PresignedStorageProvider is implemented by storage providers that can hand
+// out time-limited URLs, so clients read and write objects directly instead
+// of streaming them through this server. A presigned upload only accepts a
+// body of the size it was signed for; StatObject then finds what was
+// uploaded.
+type PresignedStorageProvider interface {
+	PresignGetObject(key string, expires time.Duration) (string, error)
+	PresignPutObject(key string, size int64, expires time.Duration) (string, error)
+	StatObject(key string) (*Object, error)
+}
+
+// S3StorageProvider stores objects in an S3-compatible bucket (AWS S3, MinIO,
+// Hanzo Storage). Buckets are addressed path-style so any endpoint works.
+type S3StorageProvider struct {
+	client   *s3.Client
+	endpoint string
+	bucket   string
+	prefix   string
+}
+
+// NewS3StorageProvider creates a provider from the provider's URL, of the form
+// https://endpoint/bucket[/prefix]. Keys are stored under the optional prefix.
+func NewS3StorageProvider(accessKey string, secretKey string, region string, providerUrl string) (*S3StorageProvider, error) {
+	endpoint, bucket, prefix, err := parseS3ProviderUrl(providerUrl)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	cfg := aws.Config{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+	})
+	return &S3StorageProvider{client: client, endpoint: endpoint, bucket: bucket, prefix: prefix}, nil
+}
+
+// parseS3ProviderUrl splits https://endpoint/bucket/prefix into its endpoint,
+// bucket and key prefix.
+func parseS3ProviderUrl(providerUrl string) (string, string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(providerUrl))
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid S3 provider URL %q: %v", providerUrl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", "", "", fmt.Errorf("invalid S3 provider URL %q: expected http(s)://endpoint/bucket", providerUrl)
+	}
+
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return "", "", "", fmt.Errorf("invalid S3 provider URL %q: the bucket is missing", providerUrl)
+	}
+	return u.Scheme + "://" + u.Host, bucket, prefix, nil
+}
+
+func (p *S3StorageProvider) fullKey(key string) string {
+	key = strings.TrimPrefix(key, "/")
+	if p.prefix == "" {
+		return key
+	}
+	return p.prefix + "/" + key
+}
+
+func (p *S3StorageProvider) relativeKey(fullKey string) string {
+	if p.prefix == "" {
+		return fullKey
+	}
+	return strings.TrimPrefix(fullKey, p.prefix+"/")
+}
+
+func (p *S3StorageProvider) objectUrl(fullKey string) string {
+	return fmt.Sprintf("%s/%s/%s", p.endpoint, p.bucket, fullKey)
+}
+
+func (p *S3StorageProvider) ListObjects(prefix string) ([]*Object, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	objects := []*Object{}
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(p.fullKey(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Contents {
+			key := aws.ToString(item.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+
+			lastModified := ""
+			if item.LastModified != nil {
+				lastModified = item.LastModified.Format(time.RFC3339)
+			}
+			objects = append(objects, &Object{
+				Key:          p.relativeKey(key),
+				LastModified: lastModified,
+				Size:         aws.ToInt64(item.Size),
+				Url:          p.objectUrl(key),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (p *S3StorageProvider) PutObject(user string, parent string, key string, fileBuffer *bytes.Buffer) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	fullKey := p.fullKey(key)
+	_, err := p.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(fullKey),
+		Body:   bytes.NewReader(fileBuffer.Bytes()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return p.objectUrl(fullKey), nil
+}
+
+// DeleteObject deletes an object. Like the local file system provider, the
+// "_hidden.ini" placeholder of a folder stands for the whole folder.
+func (p *S3StorageProvider) DeleteObject(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	if !strings.HasSuffix(key, "_hidden.ini") {
+		_, err := p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(p.bucket),
+			Key:    aws.String(p.fullKey(key)),
+		})
+		return err
+	}
+
+	folder := strings.TrimSuffix(key, "_hidden.ini")
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(p.fullKey(folder)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		identifiers := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, item := range page.Contents {
+			identifiers = append(identifiers, types.ObjectIdentifier{Key: item.Key})
+		}
+		_, err = p.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(p.bucket),
+			Delete: &types.Delete{Objects: identifiers, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PresignGetObject returns a URL that downloads key until it expires.
+func (p *S3StorageProvider) PresignGetObject(key string, expires time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	req, err := s3.NewPresignClient(p.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(p.fullKey(key)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// PresignPutObject returns a URL that uploads key with an HTTP PUT until it
+// expires. The Content-Length header is signed, so the upload must be
+// exactly size bytes.
+func (p *S3StorageProvider) PresignPutObject(key string, size int64, expires time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	req, err := s3.NewPresignClient(p.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(p.bucket),
+		Key:           aws.String(p.fullKey(key)),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// StatObject returns the stored object at key.
+func (p *S3StorageProvider) StatObject(key string) (*Object, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	fullKey := p.fullKey(key)
+	output, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(fullKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified := ""
+	if output.LastModified != nil {
+		lastModified = output.LastModified.Format(time.RFC3339)
+	}
+	return &Object{
+		Key:          key,
+		LastModified: lastModified,
+		Size:         aws.ToInt64(output.ContentLength),
+		Url:          p.objectUrl(fullKey),
+	}, nil
+}
+
+// PutObjectStream stores an object from reader with a multipart upload,
+// holding one part in memory at a time. Objects that fit in a single part
+// are stored with a plain PutObject.
+func (p *S3StorageProvider) PutObjectStream(user string, parent string, key string, reader io.Reader, size int64) (string, error) {
+	fullKey := p.fullKey(key)
+	buf := make([]byte, s3PartSize)
+	n, readErr := io.ReadFull(reader, buf)
+	if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+		ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+		defer cancel()
+
+		_, err := p.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(p.bucket),
+			Key:    aws.String(fullKey),
+			Body:   bytes.NewReader(buf[:n]),
+		})
+		if err != nil {
+			return "", err
+		}
+		return p.objectUrl(fullKey), nil
+	}
+	if readErr != nil {
+		return "", readErr
+	}
+
+	uploadId, err := p.CreateMultipartUpload(key)
+	if err != nil {
+		return "", err
+	}
+	parts := []Part{}
+	for partNumber := int32(1); ; partNumber++ {
+		etag, err := p.UploadPart(key, uploadId, partNumber, bytes.NewReader(buf[:n]), int64(n))
+		if err != nil {
+			p.AbortMultipartUpload(key, uploadId)
+			return "", err
+		}
+		parts = append(parts, Part{Number: partNumber, ETag: etag, Size: int64(n)})
+		if readErr == io.ErrUnexpectedEOF {
+			break
+		}
+
+		n, readErr = io.ReadFull(reader, buf)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			p.AbortMultipartUpload(key, uploadId)
+			return "", readErr
+		}
+	}
+	return p.CompleteMultipartUpload(key, uploadId, parts)
+}
+
+func (p *S3StorageProvider) CreateMultipartUpload(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	output, err := p.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(p.fullKey(key)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.UploadId), nil
+}
+
+// UploadPart uploads part partNumber of an upload and returns its ETag. The
+// part is read into memory first: S3 needs a seekable body to sign it.
+func (p *S3StorageProvider) UploadPart(key string, uploadId string, partNumber int32, reader io.Reader, size int64) (string, error) {
+	body, ok := reader.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		body, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	output, err := p.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(p.bucket),
+		Key:           aws.String(p.fullKey(key)),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.ETag), nil
+}
+
+func (p *S3StorageProvider) ListParts(key string, uploadId string) ([]Part, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	parts := []Part{}
+	paginator := s3.NewListPartsPaginator(p.client, &s3.ListPartsInput{
+		Bucket:   aws.String(p.bucket),
+		Key:      aws.String(p.fullKey(key)),
+		UploadId: aws.String(uploadId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, Part{
+				Number: aws.ToInt32(part.PartNumber),
+				ETag:   aws.ToString(part.ETag),
+				Size:   aws.ToInt64(part.Size),
+			})
+		}
+	}
+	return parts, nil
+}
+
+func (p *S3StorageProvider) CompleteMultipartUpload(key string, uploadId string, parts []Part) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(part.Number),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	fullKey := p.fullKey(key)
+	_, err := p.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(p.bucket),
+		Key:             aws.String(fullKey),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", err
+	}
+	return p.objectUrl(fullKey), nil
+}
+
+func (p *S3StorageProvider) AbortMultipartUpload(key string, uploadId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	_, err := p.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(p.bucket),
+		Key:      aws.String(p.fullKey(key)),
+		UploadId: aws.String(uploadId),
+	})
+	return err
+}
@@ -0,0 +1,76 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseS3ProviderUrl(t *testing.T) {
+	tests := []struct {
+		providerUrl              string
+		endpoint, bucket, prefix string
+		wantErr                  bool
+	}{
+		{providerUrl: "https://s3.hanzo.ai/files", endpoint: "https://s3.hanzo.ai", bucket: "files"},
+		{providerUrl: "http://minio:9000/files/stores/docs/", endpoint: "http://minio:9000", bucket: "files", prefix: "stores/docs"},
+		{providerUrl: "https://s3.hanzo.ai", wantErr: true},
+		{providerUrl: "s3.hanzo.ai/files", wantErr: true},
+	}
+	for _, test := range tests {
+		endpoint, bucket, prefix, err := parseS3ProviderUrl(test.providerUrl)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseS3ProviderUrl(%q) error = %v, wantErr %v", test.providerUrl, err, test.wantErr)
+			continue
+		}
+		if endpoint != test.endpoint || bucket != test.bucket || prefix != test.prefix {
+			t.Errorf("parseS3ProviderUrl(%q) = %q, %q, %q", test.providerUrl, endpoint, bucket, prefix)
+		}
+	}
+}
+
+func TestS3StorageProviderPresign(t *testing.T) {
+	p, err := NewS3StorageProvider("access", "secret", "", "http://minio:9000/files/stores")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, presign := range map[string]func(string, time.Duration) (string, error){
+		"GET": p.PresignGetObject,
+		"PUT": func(key string, expires time.Duration) (string, error) { return p.PresignPutObject(key, 1024, expires) },
+	} {
+		rawUrl, err := presign("docs/a.pdf", 10*time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Host != "minio:9000" || u.Path != "/files/stores/docs/a.pdf" {
+			t.Errorf("%s URL = %s, want the path-style key under the prefix", name, rawUrl)
+		}
+		query := u.Query()
+		if query.Get("X-Amz-Expires") != "600" || !strings.HasPrefix(query.Get("X-Amz-Credential"), "access/") || query.Get("X-Amz-Signature") == "" {
+			t.Errorf("%s URL = %s, want a signed URL valid for 600s", name, rawUrl)
+		}
+		if signed := query.Get("X-Amz-SignedHeaders"); (name == "PUT") != strings.Contains(signed, "content-length") {
+			t.Errorf("%s URL signs headers %q", name, signed)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// StreamingStorageProvider is implemented by storage providers that can store
+// an object from a reader without holding it in memory. size is -1 when the
+// length of the reader is unknown.
+type StreamingStorageProvider interface {
+	PutObjectStream(user string, parent string, key string, reader io.Reader, size int64) (string, error)
+}
+
+// Part is an uploaded part of a multipart upload.
+type Part struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// MultipartStorageProvider is implemented by storage providers that can
+// assemble an object from parts uploaded separately. An upload survives
+// failed requests until it is completed or aborted, so clients resume it by
+// listing the parts stored so far and sending the rest.
+type MultipartStorageProvider interface {
+	CreateMultipartUpload(key string) (string, error)
+	UploadPart(key string, uploadId string, partNumber int32, reader io.Reader, size int64) (string, error)
+	ListParts(key string, uploadId string) ([]Part, error)
+	CompleteMultipartUpload(key string, uploadId string, parts []Part) (string, error)
+	AbortMultipartUpload(key string, uploadId string) error
+}
+
+// ObjectTooLargeError is returned when an object exceeds the size limit of an
+// upload.
+type ObjectTooLargeError struct {
+	Limit int64
+}
+
+func (e *ObjectTooLargeError) Error() string {
+	return fmt.Sprintf("the file exceeds the upload limit of %d bytes", e.Limit)
+}
+
+// PutObjectFromReader stores an object of at most maxSize bytes from reader,
+// streaming it when the provider supports it and buffering it otherwise.
+// size is the length of reader if known, or -1.
+func PutObjectFromReader(p StorageProvider, user string, parent string, key string, reader io.Reader, size int64, maxSize int64) (string, error) {
+	if size > maxSize {
+		return "", &ObjectTooLargeError{Limit: maxSize}
+	}
+	limited := &limitedReader{reader: reader, remaining: maxSize, limit: maxSize}
+
+	if streaming, ok := p.(StreamingStorageProvider); ok {
+		return streaming.PutObjectStream(user, parent, key, limited, size)
+	}
+
+	fileBuffer := bytes.NewBuffer(nil)
+	if size > 0 {
+		fileBuffer.Grow(int(size))
+	}
+	if _, err := io.Copy(fileBuffer, limited); err != nil {
+		return "", err
+	}
+	return p.PutObject(user, parent, key, fileBuffer)
+}
+
+// UploadPartWithinLimit uploads part partNumber of an upload unless it takes
+// the upload's stored parts, other than an earlier copy of this part, over
+// maxSize bytes. size is the length of reader if known, or -1.
+func UploadPartWithinLimit(p MultipartStorageProvider, key string, uploadId string, partNumber int32, reader io.Reader, size int64, maxSize int64) (string, error) {
+	parts, err := p.ListParts(key, uploadId)
+	if err != nil {
+		return "", err
+	}
+	remaining := maxSize
+	for _, part := range parts {
+		if part.Number != partNumber {
+			remaining -= part.Size
+		}
+	}
+	if remaining < 0 || size > remaining {
+		return "", &ObjectTooLargeError{Limit: maxSize}
+	}
+	return p.UploadPart(key, uploadId, partNumber, &limitedReader{reader: reader, remaining: remaining, limit: maxSize}, size)
+}
+
+// limitedReader fails with an ObjectTooLargeError once more than limit bytes
+// are read, unlike io.LimitReader which silently truncates.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, &ObjectTooLargeError{Limit: r.limit}
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, &ObjectTooLargeError{Limit: r.limit}
+	}
+	return n, err
+}
@@ -0,0 +1,175 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	iamsdk "github.com/hanzoai/iamsdk/v2/iamsdk"
+)
+
+// bufferedProvider is a provider that can only store whole buffers.
+type bufferedProvider struct {
+	stored map[string]string
+}
+
+func (p *bufferedProvider) ListObjects(prefix string) ([]*Object, error) {
+	return nil, nil
+}
+
+func (p *bufferedProvider) PutObject(user string, parent string, key string, fileBuffer *bytes.Buffer) (string, error) {
+	p.stored[key] = fileBuffer.String()
+	return "mem://" + key, nil
+}
+
+func (p *bufferedProvider) DeleteObject(key string) error {
+	return nil
+}
+
+func TestPutObjectFromReader(t *testing.T) {
+	dir := t.TempDir()
+	local, _ := NewLocalFileSystemStorageProvider(dir)
+	buffered := &bufferedProvider{stored: map[string]string{}}
+	content := strings.Repeat("knowledge ", 100)
+
+	for name, p := range map[string]StorageProvider{"streaming": local, "buffered": buffered} {
+		if _, err := PutObjectFromReader(p, "alice", "store", "docs/a.txt", strings.NewReader(content), -1, int64(len(content))); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var tooLarge *ObjectTooLargeError
+		_, err := PutObjectFromReader(p, "alice", "store", "docs/b.txt", strings.NewReader(content), -1, int64(len(content))-1)
+		if !errors.As(err, &tooLarge) {
+			t.Errorf("%s: a stream over the limit gave %v", name, err)
+		}
+		_, err = PutObjectFromReader(p, "alice", "store", "docs/c.txt", strings.NewReader(content), int64(len(content)), 10)
+		if !errors.As(err, &tooLarge) {
+			t.Errorf("%s: a declared size over the limit gave %v", name, err)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs/a.txt")); string(data) != content {
+		t.Errorf("streamed file = %d bytes, want %d", len(data), len(content))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs/b.txt")); !os.IsNotExist(err) {
+		t.Error("the partial file of a rejected stream was kept")
+	}
+	if buffered.stored["docs/a.txt"] != content {
+		t.Error("the buffered provider got a different object")
+	}
+	if _, ok := buffered.stored["docs/b.txt"]; ok {
+		t.Error("the buffered provider stored an object over the limit")
+	}
+}
+
+// partsProvider is a multipart provider keeping the parts it was sent.
+type partsProvider struct {
+	parts map[int32][]byte
+}
+
+func (p *partsProvider) CreateMultipartUpload(key string) (string, error) {
+	return "upload", nil
+}
+
+func (p *partsProvider) UploadPart(key string, uploadId string, partNumber int32, reader io.Reader, size int64) (string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	p.parts[partNumber] = data
+	return "etag", nil
+}
+
+func (p *partsProvider) ListParts(key string, uploadId string) ([]Part, error) {
+	parts := []Part{}
+	for number, data := range p.parts {
+		parts = append(parts, Part{Number: number, Size: int64(len(data))})
+	}
+	return parts, nil
+}
+
+func (p *partsProvider) CompleteMultipartUpload(key string, uploadId string, parts []Part) (string, error) {
+	return "mem://" + key, nil
+}
+
+func (p *partsProvider) AbortMultipartUpload(key string, uploadId string) error {
+	return nil
+}
+
+func TestUploadPartWithinLimit(t *testing.T) {
+	p := &partsProvider{parts: map[int32][]byte{}}
+	upload := func(partNumber int32, content string, size int64) error {
+		_, err := UploadPartWithinLimit(p, "docs/a.bin", "upload", partNumber, strings.NewReader(content), size, 10)
+		return err
+	}
+
+	if err := upload(1, "123456", 6); err != nil {
+		t.Fatalf("first part: %v", err)
+	}
+	var tooLarge *ObjectTooLargeError
+	if err := upload(2, "12345", 5); !errors.As(err, &tooLarge) {
+		t.Errorf("a declared size over the limit gave %v", err)
+	}
+	if err := upload(2, "12345", -1); !errors.As(err, &tooLarge) {
+		t.Errorf("a stream over the limit gave %v", err)
+	}
+	if err := upload(1, "12345678", 8); err != nil {
+		t.Errorf("replacing a part within the limit: %v", err)
+	}
+	if err := upload(2, "12", -1); err != nil {
+		t.Errorf("the last part within the limit: %v", err)
+	}
+}
+
+func TestIamProviderPutObjectStream(t *testing.T) {
+	content := strings.Repeat("knowledge ", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fullFilePath") != "Direct/files/docs/a.txt" {
+			// The upload over the limit, cut off by the client.
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("upload without a file: %v", err)
+			return
+		}
+		if data, _ := io.ReadAll(file); string(data) != content {
+			t.Errorf("uploaded %d bytes, want %d", len(data), len(content))
+		}
+		_, _ = w.Write([]byte(`{"status":"ok","data":"https://files.example.com/docs/a.txt"}`))
+	}))
+	defer server.Close()
+	iamsdk.InitConfig(server.URL, "client", "secret", "", "hanzo", "app")
+
+	p, _ := NewIamProvider("files", "en")
+	fileUrl, err := PutObjectFromReader(p, "alice", "store", "docs/a.txt", strings.NewReader(content), -1, int64(len(content)))
+	if err != nil || fileUrl != "https://files.example.com/docs/a.txt" {
+		t.Fatalf("PutObjectFromReader() = %q, %v", fileUrl, err)
+	}
+
+	var tooLarge *ObjectTooLargeError
+	_, err = PutObjectFromReader(p, "alice", "store", "docs/b.txt", strings.NewReader(content), -1, 10)
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("a stream over the limit gave %v", err)
+	}
+}
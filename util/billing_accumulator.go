@@ -0,0 +1,163 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// MicroCentsPerCent is the number of micro-cents in one cent. Usage costs are
+// computed in micro-cents so that sub-cent calls (e.g. a 500-token gpt-4o-mini
+// request) are not rounded up to a whole cent.
+const MicroCentsPerCent = 1_000_000
+
+// SettleFunc is invoked by the accumulator when a user's pending sub-cent
+// remainder is settled. cents is the whole-cent amount to bill (may be 0 when
+// the remainder rounds down) and microCents is the exact remainder settled.
+type SettleFunc func(user string, cents int64, microCents int64)
+
+// pendingUsage holds the unbilled sub-cent remainder for a single user.
+type pendingUsage struct {
+	microCents int64
+	lastSeen   time.Time
+}
+
+// BillingAccumulator carries fractional (sub-cent) usage costs per user
+// between calls. Every call adds its exact micro-cent cost; whole cents are
+// released immediately and the remainder is carried forward. A background
+// goroutine periodically settles idle remainders so that no usage goes
+// unbilled indefinitely.
+//
+// State is in-memory and per-process: each replica settles its own
+// remainders, so the maximum rounding error per user is half a cent per
+// replica per settlement interval.
+type BillingAccumulator struct {
+	mu       sync.Mutex
+	pending  map[string]*pendingUsage
+	interval time.Duration
+	settle   SettleFunc
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewBillingAccumulator creates an accumulator and starts its settlement loop.
+// Remainders idle for at least interval are settled via settle.
+func NewBillingAccumulator(interval time.Duration, settle SettleFunc) *BillingAccumulator {
+	a := &BillingAccumulator{
+		pending:  make(map[string]*pendingUsage),
+		interval: interval,
+		settle:   settle,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+
+	go a.settleLoop()
+	return a
+}
+
+// Add records microCents of usage for user and returns the whole cents that
+// should be billed now. The sub-cent remainder is carried to the next call.
+func (a *BillingAccumulator) Add(user string, microCents int64) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.pending[user]
+	if !ok {
+		entry = &pendingUsage{}
+		a.pending[user] = entry
+	}
+	entry.microCents += microCents
+	entry.lastSeen = time.Now()
+
+	cents := entry.microCents / MicroCentsPerCent
+	entry.microCents -= cents * MicroCentsPerCent
+	return cents
+}
+
+// Pending returns the unbilled micro-cent remainder for user.
+func (a *BillingAccumulator) Pending(user string) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if entry, ok := a.pending[user]; ok {
+		return entry.microCents
+	}
+	return 0
+}
+
+// Flush settles every pending remainder regardless of age. Called on shutdown
+// so that carried fractions are not lost when the process exits.
+func (a *BillingAccumulator) Flush() {
+	a.settleOlderThan(0)
+}
+
+// Stop terminates the settlement loop and flushes all pending remainders.
+func (a *BillingAccumulator) Stop() {
+	close(a.stopCh)
+	<-a.doneCh
+	a.Flush()
+}
+
+// settleLoop periodically settles remainders that have been idle for at least
+// one interval.
+func (a *BillingAccumulator) settleLoop() {
+	defer close(a.doneCh)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-ticker.C:
+			a.settleOlderThan(a.interval)
+		}
+	}
+}
+
+// settleOlderThan rounds each remainder idle for at least age to the nearest
+// cent, reports it via the settle callback, and drops the entry.
+func (a *BillingAccumulator) settleOlderThan(age time.Duration) {
+	type settlement struct {
+		user       string
+		cents      int64
+		microCents int64
+	}
+
+	now := time.Now()
+	var due []settlement
+
+	a.mu.Lock()
+	for user, entry := range a.pending {
+		if now.Sub(entry.lastSeen) < age {
+			continue
+		}
+		if entry.microCents > 0 {
+			cents := (entry.microCents + MicroCentsPerCent/2) / MicroCentsPerCent
+			due = append(due, settlement{user: user, cents: cents, microCents: entry.microCents})
+		}
+		delete(a.pending, user)
+	}
+	a.mu.Unlock()
+
+	if a.settle == nil {
+		return
+	}
+	for _, s := range due {
+		a.settle(s.user, s.cents, s.microCents)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBillingAccumulatorCarriesRemainder(t *testing.T) {
+	a := NewBillingAccumulator(time.Hour, nil)
+	defer a.Stop()
+
+	// 0.4 cents per call: nothing billed until the third call crosses 1 cent.
+	if got := a.Add("hanzo/alice", 400_000); got != 0 {
+		t.Fatalf("call 1: expected 0 cents, got %d", got)
+	}
+	if got := a.Add("hanzo/alice", 400_000); got != 0 {
+		t.Fatalf("call 2: expected 0 cents, got %d", got)
+	}
+	if got := a.Add("hanzo/alice", 400_000); got != 1 {
+		t.Fatalf("call 3: expected 1 cent, got %d", got)
+	}
+	if got := a.Pending("hanzo/alice"); got != 200_000 {
+		t.Fatalf("expected 200000 micro-cents pending, got %d", got)
+	}
+}
+
+func TestBillingAccumulatorSeparateUsers(t *testing.T) {
+	a := NewBillingAccumulator(time.Hour, nil)
+	defer a.Stop()
+
+	a.Add("hanzo/alice", 900_000)
+	if got := a.Add("hanzo/bob", 900_000); got != 0 {
+		t.Fatalf("bob should not inherit alice's remainder, got %d cents", got)
+	}
+	if got := a.Add("hanzo/alice", 2_300_000); got != 3 {
+		t.Fatalf("expected 3 cents for alice, got %d", got)
+	}
+}
+
+func TestBillingAccumulatorFlushRoundsToNearestCent(t *testing.T) {
+	var mu sync.Mutex
+	settled := map[string]int64{}
+	a := NewBillingAccumulator(time.Hour, func(user string, cents int64, microCents int64) {
+		mu.Lock()
+		settled[user] = cents
+		mu.Unlock()
+	})
+
+	a.Add("hanzo/alice", 600_000)
+	a.Add("hanzo/bob", 300_000)
+	a.Stop()
+
+	if settled["hanzo/alice"] != 1 {
+		t.Errorf("expected alice to settle 1 cent, got %d", settled["hanzo/alice"])
+	}
+	if settled["hanzo/bob"] != 0 {
+		t.Errorf("expected bob to settle 0 cents, got %d", settled["hanzo/bob"])
+	}
+	if a.Pending("hanzo/alice") != 0 || a.Pending("hanzo/bob") != 0 {
+		t.Error("expected no pending remainders after flush")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/beego/beego/logs"
+	"github.com/hanzoai/cloud/conf"
+)
+
+// defaultTrustedProxies is used when trustedProxies is not configured:
+// loopback and private ranges, which covers in-cluster load balancers and
+// ingress controllers.
+var defaultTrustedProxies = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+	"::1/128", "fc00::/7",
+}
+
+// trustedProxies holds the parsed trusted proxy list once it is loaded from
+// config or set by SetTrustedProxies.
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+// ParseCIDRList parses a list of CIDRs or bare IPs (treated as /32 or /128).
+func ParseCIDRList(items []string) ([]*net.IPNet, error) {
+	var res []*net.IPNet
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ipNet)
+	}
+	return res, nil
+}
+
+// SetTrustedProxies replaces the trusted proxy list. It is safe to call while
+// requests are served.
+func SetTrustedProxies(items []string) error {
+	nets, err := ParseCIDRList(items)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Store(&nets)
+	return nil
+}
+
+func getTrustedProxies() []*net.IPNet {
+	if nets := trustedProxies.Load(); nets != nil {
+		return *nets
+	}
+
+	items := defaultTrustedProxies
+	if value := conf.GetConfigString("trustedProxies"); value != "" {
+		items = strings.Split(value, ",")
+	}
+	nets, err := ParseCIDRList(items)
+	if err != nil {
+		logs.Warning("invalid trustedProxies %q: %v, falling back to defaults", items, err)
+		nets, _ = ParseCIDRList(defaultTrustedProxies)
+	}
+	// A list set concurrently by SetTrustedProxies wins over the config.
+	if !trustedProxies.CompareAndSwap(nil, &nets) {
+		return *trustedProxies.Load()
+	}
+	return nets
+}
+
+// IPInCIDRs reports whether ip falls within any of nets.
+func IPInCIDRs(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the originating client IP for req. X-Forwarded-For and
+// X-Real-IP are honored only when the direct peer is a trusted proxy, and
+// X-Forwarded-For is walked right to left so that a client cannot spoof its
+// address by prepending entries. Use this for usage records, rate limiting
+// and IP allowlists.
+func GetClientIP(req *http.Request) string {
+	remoteIP := parseRemoteAddr(req.RemoteAddr)
+	nets := getTrustedProxies()
+	if !IPInCIDRs(net.ParseIP(remoteIP), nets) {
+		return remoteIP
+	}
+
+	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !IPInCIDRs(ip, nets) {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return remoteIP
+}
+
+func parseRemoteAddr(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return strings.Trim(remoteAddr, "[]")
+	}
+	return host
+}
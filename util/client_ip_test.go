@@ -0,0 +1,85 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(defaultTrustedProxies)
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:5000", "1.2.3.4", "5.6.7.8", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:443", "198.51.100.9", "", "198.51.100.9"},
+		{"spoofed leading entry ignored", "10.1.2.3:443", "1.2.3.4, 198.51.100.9", "", "198.51.100.9"},
+		{"proxy chain", "10.1.2.3:443", "198.51.100.9, 192.168.1.1, 10.0.0.5", "", "198.51.100.9"},
+		{"x-real-ip fallback", "10.1.2.3:443", "", "198.51.100.10", "198.51.100.10"},
+		{"garbage header", "10.1.2.3:443", "not-an-ip", "", "10.1.2.3"},
+		{"ipv6 peer", "[2001:db8::1]:443", "1.2.3.4", "", "2001:db8::1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{RemoteAddr: tc.remoteAddr, Header: http.Header{}}
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			if tc.realIP != "" {
+				req.Header.Set("X-Real-IP", tc.realIP)
+			}
+			if got := GetClientIP(req); got != tc.want {
+				t.Errorf("GetClientIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxiesConcurrent(t *testing.T) {
+	defer SetTrustedProxies(defaultTrustedProxies)
+
+	req := &http.Request{RemoteAddr: "10.1.2.3:443", Header: http.Header{}}
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if got := GetClientIP(req); got != "198.51.100.9" {
+				t.Errorf("GetClientIP() = %q, want 198.51.100.9", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
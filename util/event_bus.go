@@ -0,0 +1,254 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/logs"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// eventBusQueueSize is the capacity of the in-memory event buffer.
+	eventBusQueueSize = 16384
+
+	// eventBusBatchSize is the most events handed to the publisher at once.
+	eventBusBatchSize = 256
+
+	// eventBusPublishTimeout bounds a single publish call.
+	eventBusPublishTimeout = 10 * time.Second
+
+	// eventBusShutdownTimeout is the maximum time to wait for queue drain on shutdown.
+	eventBusShutdownTimeout = 10 * time.Second
+)
+
+// Event is a pre-serialized message for the event bus.
+type Event struct {
+	Key  string // partition key, e.g. the user; keeps a user's events in order
+	Body []byte // JSON payload, serialized by the caller
+}
+
+// EventPublisher delivers batches of events to a broker topic.
+type EventPublisher interface {
+	Publish(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+// EventBus is a buffered, best-effort event publisher. Events are enqueued
+// without blocking the HTTP handler and published by a background worker.
+// Unlike BillingQueue, events are dropped rather than retried when the
+// broker is down: the bus feeds analytics, not billing.
+type EventBus struct {
+	publisher EventPublisher
+	name      string
+	ch        chan *Event
+	wg        sync.WaitGroup
+	stop      chan struct{}
+}
+
+// NewEventBus creates a publisher for kind ("kafka" or "nats") and starts
+// the bus. brokers is a comma-separated list of broker addresses (Kafka) or
+// server URLs (NATS); topic is the Kafka topic or NATS subject.
+func NewEventBus(kind, brokers, topic string) (*EventBus, error) {
+	addrs := []string{}
+	for _, addr := range strings.Split(brokers, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("event bus: no brokers configured")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("event bus: no topic configured")
+	}
+
+	var publisher EventPublisher
+	switch kind {
+	case "kafka":
+		publisher = newKafkaPublisher(addrs, topic)
+	case "nats":
+		p, err := newNatsPublisher(addrs, topic)
+		if err != nil {
+			return nil, err
+		}
+		publisher = p
+	default:
+		return nil, fmt.Errorf("event bus: unsupported type %q (want kafka or nats)", kind)
+	}
+
+	return StartEventBus(fmt.Sprintf("%s:%s", kind, topic), publisher), nil
+}
+
+// StartEventBus starts a bus on an existing publisher.
+func StartEventBus(name string, publisher EventPublisher) *EventBus {
+	b := &EventBus{
+		publisher: publisher,
+		name:      name,
+		ch:        make(chan *Event, eventBusQueueSize),
+		stop:      make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.worker()
+	return b
+}
+
+// Enqueue adds an event to the queue. If the queue is full, the event is
+// dropped and an error is logged. This never blocks the caller.
+func (b *EventBus) Enqueue(event *Event) {
+	select {
+	case b.ch <- event:
+	default:
+		logs.Error("event_bus: dropped event key=%s on %s (queue full)", event.Key, b.name)
+	}
+}
+
+// Shutdown stops the worker after it publishes queued events (up to
+// eventBusShutdownTimeout), then closes the publisher. Returns the number of
+// events still pending when the timeout expired.
+func (b *EventBus) Shutdown() int {
+	close(b.stop)
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	remaining := 0
+	select {
+	case <-done:
+	case <-time.After(eventBusShutdownTimeout):
+		remaining = len(b.ch)
+		logs.Error("event_bus: shutdown timed out, %d events pending on %s", remaining, b.name)
+	}
+	if err := b.publisher.Close(); err != nil {
+		logs.Warning("event_bus: failed to close %s: %v", b.name, err)
+	}
+	return remaining
+}
+
+// worker drains the queue in batches.
+func (b *EventBus) worker() {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case event := <-b.ch:
+			b.publish(b.collect(event))
+		case <-b.stop:
+			for {
+				select {
+				case event := <-b.ch:
+					b.publish(b.collect(event))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// collect returns first plus any events already queued, up to a batch.
+func (b *EventBus) collect(first *Event) []*Event {
+	batch := []*Event{first}
+	for len(batch) < eventBusBatchSize {
+		select {
+		case event := <-b.ch:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (b *EventBus) publish(batch []*Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), eventBusPublishTimeout)
+	defer cancel()
+
+	if err := b.publisher.Publish(ctx, batch); err != nil {
+		logs.Error("event_bus: dropped %d events on %s: %v", len(batch), b.name, err)
+	}
+}
+
+// ── Kafka ────────────────────────────────────────────────────────────────
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func newKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+		BatchTimeout: 50 * time.Millisecond,
+	}}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, events []*Event) error {
+	messages := make([]kafka.Message, len(events))
+	for i, event := range events {
+		messages[i] = kafka.Message{Key: []byte(event.Key), Value: event.Body}
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// ── NATS ─────────────────────────────────────────────────────────────────
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func newNatsPublisher(servers []string, subject string) (*natsPublisher, error) {
+	conn, err := nats.Connect(strings.Join(servers, ","),
+		nats.Name("hanzo-cloud"),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("event bus: failed to connect to NATS: %w", err)
+	}
+	return &natsPublisher{conn: conn, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, events []*Event) error {
+	for _, event := range events {
+		if err := p.conn.Publish(p.subject, event.Body); err != nil {
+			return err
+		}
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *natsPublisher) Close() error {
+	err := p.conn.Drain()
+	if err == nats.ErrConnectionClosed {
+		return nil
+	}
+	return err
+}
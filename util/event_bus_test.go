@@ -0,0 +1,73 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []*Event
+	closed bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, events []*Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func TestEventBusDrainsOnShutdown(t *testing.T) {
+	p := &recordingPublisher{}
+	bus := StartEventBus("test", p)
+
+	for i := 0; i < 1000; i++ {
+		bus.Enqueue(&Event{Key: "hanzo/alice", Body: []byte(fmt.Sprintf(`{"n":%d}`, i))})
+	}
+	if remaining := bus.Shutdown(); remaining != 0 {
+		t.Fatalf("expected queue drained, %d events pending", remaining)
+	}
+
+	if len(p.events) != 1000 {
+		t.Errorf("expected 1000 published events, got %d", len(p.events))
+	}
+	if string(p.events[999].Body) != `{"n":999}` {
+		t.Errorf("events published out of order: last = %s", p.events[999].Body)
+	}
+	if !p.closed {
+		t.Error("expected publisher closed on shutdown")
+	}
+}
+
+func TestNewEventBusRejectsBadConfig(t *testing.T) {
+	if _, err := NewEventBus("kafka", "", "cloud.usage"); err == nil {
+		t.Error("expected error for missing brokers")
+	}
+	if _, err := NewEventBus("rabbitmq", "localhost:5672", "cloud.usage"); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
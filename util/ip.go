@@ -114,19 +114,8 @@ func GetIPInfo(clientIP string) string {
 	return res
 }
 
+// GetIPFromRequest returns the client IP formatted by GetIPInfo. Forwarding
+// headers are only honored from trusted proxies (see GetClientIP).
 func GetIPFromRequest(req *http.Request) string {
-	clientIP := req.Header.Get("x-forwarded-for")
-	if clientIP == "" {
-		ipPort := strings.Split(req.RemoteAddr, ":")
-		if len(ipPort) >= 1 && len(ipPort) <= 2 {
-			clientIP = ipPort[0]
-		} else if len(ipPort) > 2 {
-			idx := strings.LastIndex(req.RemoteAddr, ":")
-			clientIP = req.RemoteAddr[0:idx]
-			clientIP = strings.TrimLeft(clientIP, "[")
-			clientIP = strings.TrimRight(clientIP, "]")
-		}
-	}
-
-	return GetIPInfo(clientIP)
+	return GetIPInfo(GetClientIP(req))
 }
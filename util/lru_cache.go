@@ -0,0 +1,168 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LruCache is a bounded cache of loaded values. Entries younger than ttl are
+// served as is; entries up to staleTtl older than that are served while one
+// background load refreshes them (stale-while-revalidate); older entries are
+// loaded synchronously. Concurrent loads of the same key are coalesced into
+// one, so an expiring hot key never sends a burst of fetches upstream. Once
+// maxEntries is reached the least recently used entry is evicted.
+type LruCache[V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	staleTtl   time.Duration
+	order      *list.List // front is the most recently used
+	items      map[string]*list.Element
+	generation uint64 // bumped on removal so in-flight loads don't resurrect entries
+	group      singleflight.Group
+}
+
+type lruCacheEntry[V any] struct {
+	key       string
+	value     V
+	fetchedAt time.Time
+}
+
+// NewLruCache creates a cache of at most maxEntries values.
+func NewLruCache[V any](maxEntries int, ttl time.Duration, staleTtl time.Duration) *LruCache[V] {
+	return &LruCache[V]{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		staleTtl:   staleTtl,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value of key, calling load when it is missing or expired.
+// Errors are returned to every caller waiting on the load and not cached.
+func (c *LruCache[V]) Get(key string, load func() (V, error)) (V, error) {
+	value, age, ok := c.lookup(key)
+	if ok && age < c.ttl {
+		return value, nil
+	}
+	if ok && age < c.ttl+c.staleTtl {
+		c.group.DoChan(key, c.loader(key, load))
+		return value, nil
+	}
+
+	result, err, _ := c.group.Do(key, c.loader(key, load))
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return result.(V), nil
+}
+
+// Peek returns the value of key if it is fresh, without loading it.
+func (c *LruCache[V]) Peek(key string) (V, bool) {
+	value, age, ok := c.lookup(key)
+	if !ok || age >= c.ttl {
+		var zero V
+		return zero, false
+	}
+	return value, true
+}
+
+// Set stores value for key as freshly loaded.
+func (c *LruCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, value)
+}
+
+// Remove drops the entry of key.
+func (c *LruCache[V]) Remove(key string) {
+	c.RemoveFunc(func(k string) bool { return k == key })
+}
+
+// RemoveFunc drops every entry whose key matches.
+func (c *LruCache[V]) RemoveFunc(match func(key string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, element := range c.items {
+		if match(key) {
+			c.order.Remove(element)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *LruCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *LruCache[V]) lookup(key string) (V, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*lruCacheEntry[V])
+	return entry.value, time.Since(entry.fetchedAt), true
+}
+
+// loader wraps load to store its result, unless the cache was invalidated
+// while it ran.
+func (c *LruCache[V]) loader(key string, load func() (V, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		c.mu.Lock()
+		generation := c.generation
+		c.mu.Unlock()
+
+		value, err := load()
+		if err != nil {
+			return value, err
+		}
+
+		c.mu.Lock()
+		if c.generation == generation {
+			c.store(key, value)
+		}
+		c.mu.Unlock()
+		return value, nil
+	}
+}
+
+func (c *LruCache[V]) store(key string, value V) {
+	if element, ok := c.items[key]; ok {
+		element.Value = &lruCacheEntry[V]{key: key, value: value, fetchedAt: time.Now()}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruCacheEntry[V]{key: key, value: value, fetchedAt: time.Now()})
+	for c.maxEntries > 0 && len(c.items) > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruCacheEntry[V]).key)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLruCacheCoalescesLoads(t *testing.T) {
+	cache := NewLruCache[int](10, time.Minute, 0)
+	var loads int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.Get("alice", func() (int, error) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+				return 42, nil
+			})
+			if err != nil || value != 42 {
+				t.Errorf("Get() = %d, %v, want 42", value, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("concurrent misses loaded %d times, want 1", loads)
+	}
+}
+
+func TestLruCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLruCache[string](2, time.Minute, 0)
+	cache.Set("a", "a")
+	cache.Set("b", "b")
+	_, _ = cache.Get("a", nil) // a is now more recent than b
+	cache.Set("c", "c")
+
+	if cache.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", cache.Len())
+	}
+	loaded := false
+	value, _ := cache.Get("b", func() (string, error) {
+		loaded = true
+		return "b2", nil
+	})
+	if !loaded || value != "b2" {
+		t.Error("b should have been evicted as the least recently used entry")
+	}
+}
+
+func TestLruCacheServesStaleWhileRevalidating(t *testing.T) {
+	cache := NewLruCache[int](10, 10*time.Millisecond, time.Minute)
+	cache.Set("alice", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	value, err := cache.Get("alice", func() (int, error) {
+		defer close(refreshed)
+		return 2, nil
+	})
+	if err != nil || value != 1 {
+		t.Fatalf("stale Get() = %d, %v, want the stale 1", value, err)
+	}
+	<-refreshed
+	time.Sleep(5 * time.Millisecond)
+	if value, _ = cache.Get("alice", nil); value != 2 {
+		t.Errorf("Get() after the refresh = %d, want 2", value)
+	}
+}
+
+func TestLruCacheDoesNotCacheErrors(t *testing.T) {
+	cache := NewLruCache[int](10, time.Minute, 0)
+	if _, err := cache.Get("alice", func() (int, error) { return 0, errors.New("down") }); err == nil {
+		t.Fatal("Get() should return the load error")
+	}
+	value, err := cache.Get("alice", func() (int, error) { return 3, nil })
+	if err != nil || value != 3 {
+		t.Errorf("Get() after an error = %d, %v, want a fresh load", value, err)
+	}
+
+	cache.Remove("alice")
+	if cache.Len() != 0 {
+		t.Errorf("Len() after Remove = %d, want 0", cache.Len())
+	}
+}
@@ -15,10 +15,40 @@
 package util
 
 import (
+	"fmt"
 	"net"
 	"strings"
+	"syscall"
 )
 
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIp reports whether ip is a public unicast address: not loopback,
+// private, shared, link-local (where cloud metadata services live),
+// multicast or unspecified.
+func IsPublicIp(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip) &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// PublicOnlyDialControl is a net.Dialer Control refusing connections to
+// addresses that are not public. It checks the address being dialed, so
+// host names resolving (or re-resolving) to internal addresses are caught
+// too.
+func PublicOnlyDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIp(net.ParseIP(host)) {
+		return fmt.Errorf("connections to %s are not allowed", host)
+	}
+	return nil
+}
+
 func IsInternetIp(ip string) bool {
 	ipStr, _, err := net.SplitHostPort(ip)
 	if err != nil {
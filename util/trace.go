@@ -0,0 +1,47 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GetTraceId returns the trace ID propagated by the caller, from a W3C
+// traceparent header ("00-<trace-id>-<parent-id>-<flags>") or a Zipkin
+// X-B3-TraceId header. Returns "" when the request is not traced.
+func GetTraceId(r *http.Request) string {
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && isTraceHex(parts[1], 32) {
+		return parts[1]
+	}
+	if traceId := r.Header.Get("X-B3-TraceId"); isTraceHex(traceId, 16) || isTraceHex(traceId, 32) {
+		return traceId
+	}
+	return ""
+}
+
+// isTraceHex reports whether s is n lowercase hex digits and not all zero,
+// which the trace specs reserve as invalid.
+func isTraceHex(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
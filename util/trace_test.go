@@ -0,0 +1,42 @@
+// Copyright 2023-2025 Hanzo AI Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetTraceId(t *testing.T) {
+	cases := []struct {
+		header string
+		value  string
+		want   string
+	}{
+		{"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"traceparent", "not-a-trace", ""},
+		{"X-B3-TraceId", "463ac35c9f6413ad", "463ac35c9f6413ad"},
+		{"X-B3-TraceId", "463AC35C9F6413AD", ""},
+		{"X-Request-Id", "463ac35c9f6413ad", ""},
+	}
+	for _, tc := range cases {
+		r, _ := http.NewRequest(http.MethodGet, "/api/health", nil)
+		r.Header.Set(tc.header, tc.value)
+		if got := GetTraceId(r); got != tc.want {
+			t.Errorf("GetTraceId(%s: %s) = %q, want %q", tc.header, tc.value, got, tc.want)
+		}
+	}
+}
@@ -74,6 +74,9 @@ class ProviderEditPage extends React.Component {
       }
     }
     if (provider.category === "Storage") {
+      if (Setting.isS3StorageProvider(provider)) {
+        return Setting.getLabel(i18next.t("general:Access key"), i18next.t("general:Access key - Tooltip"));
+      }
       return Setting.getLabel(i18next.t("store:Storage subpath"), i18next.t("store:Storage subpath - Tooltip"));
     }
     if (provider.category === "Bot") {
@@ -369,6 +372,8 @@ class ProviderEditPage extends React.Component {
                   this.updateProviderField("subType", "llama3.3:70b");
                 } else if (value === "Local") {
                   this.updateProviderField("subType", "custom-model");
+                } else if (value === "Self-hosted") {
+                  this.updateProviderField("subType", "meta-llama/Llama-3.3-70B-Instruct");
                 } else if (value === "Azure") {
                   this.updateProviderField("subType", "gpt-4");
                 } else if (value === "Cohere") {
@@ -461,7 +466,7 @@ class ProviderEditPage extends React.Component {
                 {Setting.getLabel(i18next.t("provider:Sub type"), i18next.t("provider:Sub type - Tooltip"))} :
               </Col>
               <Col span={22} >
-                {this.state.provider.type === "Ollama" ? (
+                {(this.state.provider.type === "Ollama" || this.state.provider.type === "Self-hosted") ? (
                   <AutoComplete
                     style={{width: "100%"}}
                     value={this.state.provider.subType}
@@ -561,7 +566,7 @@ class ProviderEditPage extends React.Component {
           ) : null
         }
         {
-          !(this.state.provider.category === "Model" && (this.state.provider.type === "Local" || this.state.provider.type === "Ollama")) ? null : (
+          !(this.state.provider.category === "Model" && (this.state.provider.type === "Local" || this.state.provider.type === "Ollama" || this.state.provider.type === "Self-hosted")) ? null : (
             <>
               <Row style={{marginTop: "20px"}} >
                 <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
@@ -603,7 +608,7 @@ class ProviderEditPage extends React.Component {
           )
         }
         {
-          (this.state.provider.type === "Local" || this.state.provider.type === "Ollama") ? (
+          (this.state.provider.type === "Local" || this.state.provider.type === "Ollama" || this.state.provider.type === "Self-hosted") ? (
             <>
               <Row style={{marginTop: "20px"}} >
                 <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
@@ -656,7 +661,7 @@ class ProviderEditPage extends React.Component {
         }
         {
           (
-            (this.state.provider.category === "Storage" && this.state.provider.type !== "OpenAI File System") ||
+            (this.state.provider.category === "Storage" && this.state.provider.type !== "OpenAI File System" && !Setting.isS3StorageProvider(this.state.provider)) ||
             (this.state.provider.category === "Agent" && this.state.provider.type === "MCP") ||
             (this.state.provider.category === "Blockchain" && this.state.provider.type === "ChainMaker") ||
             this.state.provider.category === "Scan" ||
@@ -752,7 +757,7 @@ class ProviderEditPage extends React.Component {
           )
         }
         {
-          ["Storage", "Model", "Embedding", "Agent", "Text-to-Speech", "Speech-to-Text", "Scan"].includes(this.state.provider.category) || (this.state.provider.category === "Blockchain" && this.state.provider.type === "Ethereum") || (this.state.provider.category === "Private Cloud" && this.state.provider.type === "Kubernetes") ? null : (
+          (["Storage", "Model", "Embedding", "Agent", "Text-to-Speech", "Speech-to-Text", "Scan"].includes(this.state.provider.category) && !Setting.isS3StorageProvider(this.state.provider)) || (this.state.provider.category === "Blockchain" && this.state.provider.type === "Ethereum") || (this.state.provider.category === "Private Cloud" && this.state.provider.type === "Kubernetes") ? null : (
             <Row style={{marginTop: "20px"}} >
               <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
                 {this.getRegionLabel(this.state.provider)} :
@@ -1179,6 +1184,62 @@ class ProviderEditPage extends React.Component {
             }} />
           </Col>
         </Row>
+        {
+          this.state.provider.category !== "Model" ? null : (
+            <>
+              <Row style={{marginTop: "20px"}} >
+                <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
+                  {Setting.getLabel(i18next.t("provider:HTTP timeout (s)"), i18next.t("provider:HTTP timeout (s) - Tooltip"))} :
+                </Col>
+                <Col span={22} >
+                  <InputNumber min={0} value={this.state.provider.httpTimeout} onChange={value => {
+                    this.updateProviderField("httpTimeout", value);
+                  }} />
+                </Col>
+              </Row>
+              <Row style={{marginTop: "20px"}} >
+                <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
+                  {Setting.getLabel(i18next.t("provider:Max idle conns"), i18next.t("provider:Max idle conns - Tooltip"))} :
+                </Col>
+                <Col span={22} >
+                  <InputNumber min={0} value={this.state.provider.httpMaxIdleConns} onChange={value => {
+                    this.updateProviderField("httpMaxIdleConns", value);
+                  }} />
+                </Col>
+              </Row>
+              <Row style={{marginTop: "20px"}} >
+                <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
+                  {Setting.getLabel(i18next.t("provider:Keep-alive (s)"), i18next.t("provider:Keep-alive (s) - Tooltip"))} :
+                </Col>
+                <Col span={22} >
+                  <InputNumber min={-1} value={this.state.provider.httpKeepAlive} onChange={value => {
+                    this.updateProviderField("httpKeepAlive", value);
+                  }} />
+                </Col>
+              </Row>
+              <Row style={{marginTop: "20px"}} >
+                <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
+                  {Setting.getLabel(i18next.t("provider:Proxy URL"), i18next.t("provider:Proxy URL - Tooltip"))} :
+                </Col>
+                <Col span={22} >
+                  <Input prefix={<LinkOutlined />} placeholder="http://proxy.internal:3128" value={this.state.provider.httpProxyUrl} onChange={e => {
+                    this.updateProviderField("httpProxyUrl", e.target.value);
+                  }} />
+                </Col>
+              </Row>
+              <Row style={{marginTop: "20px"}} >
+                <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
+                  {Setting.getLabel(i18next.t("provider:CA certificate"), i18next.t("provider:CA certificate - Tooltip"))} :
+                </Col>
+                <Col span={22} >
+                  <Input.TextArea rows={4} placeholder="-----BEGIN CERTIFICATE-----" value={this.state.provider.httpCaCert} onChange={e => {
+                    this.updateProviderField("httpCaCert", e.target.value);
+                  }} />
+                </Col>
+              </Row>
+            </>
+          )
+        }
         <Row style={{marginTop: "20px"}} >
           <Col style={{marginTop: "5px"}} span={(Setting.isMobile()) ? 22 : 2}>
             {Setting.getLabel(i18next.t("store:Is default"), i18next.t("store:Is default - Tooltip"))} :
@@ -786,6 +786,10 @@ export function getOtherProviderInfo() {
         logo: `${StaticBaseUrl}/img/social_xai.png`,
         url: "https://x.ai/",
       },
+      "Groq": {
+        logo: `${StaticBaseUrl}/img/social_groq.png`,
+        url: "https://groq.com/",
+      },
       "OpenRouter": {
         logo: `${StaticBaseUrl}/img/social_openrouter.png`,
         url: "https://openrouter.ai/",
@@ -814,6 +818,10 @@ export function getOtherProviderInfo() {
         logo: `${StaticBaseUrl}/img/social_local.jpg`,
         url: "",
       },
+      "Self-hosted": {
+        logo: `${StaticBaseUrl}/img/social_local.jpg`,
+        url: "https://docs.vllm.ai/",
+      },
       "Azure": {
         logo: `${StaticBaseUrl}/img/social_azure.png`,
         url: "https://azure.microsoft.com/",
@@ -1245,11 +1253,17 @@ export function isProviderSupportWebSearch(provider) {
   return false;
 }
 
+export function isS3StorageProvider(provider) {
+  return provider.category === "Storage" && ["AWS S3", "MinIO"].includes(provider.type);
+}
+
 export function getProviderTypeOptions(category) {
   if (category === "Storage") {
     return (
       [
         {id: "Local File System", name: "Local File System"},
+        {id: "AWS S3", name: "AWS S3"},
+        {id: "MinIO", name: "MinIO"},
         {id: "OpenAI File System", name: "OpenAI File System"},
       ]
     );
@@ -1261,6 +1275,7 @@ export function getProviderTypeOptions(category) {
         {id: "Hugging Face", name: "Hugging Face"},
         {id: "Claude", name: "Claude"},
         {id: "Grok", name: "Grok"},
+        {id: "Groq", name: "Groq"},
         {id: "OpenRouter", name: "OpenRouter"},
         {id: "Baidu Cloud", name: "Baidu Cloud"},
         {id: "iFlytek", name: "iFlytek"},
@@ -1268,6 +1283,7 @@ export function getProviderTypeOptions(category) {
         {id: "MiniMax", name: "MiniMax"},
         {id: "Ollama", name: "Ollama"},
         {id: "Local", name: "Local"},
+        {id: "Self-hosted", name: "Self-hosted (vLLM, Ollama, TGI)"},
         {id: "Azure", name: "Azure"},
         {id: "Cohere", name: "Cohere"},
         {id: "Moonshot", name: "Moonshot"},
@@ -1292,6 +1308,7 @@ export function getProviderTypeOptions(category) {
         {id: "Gemini", name: "Gemini"},
         {id: "Hugging Face", name: "Hugging Face"},
         {id: "Cohere", name: "Cohere"},
+        {id: "Mistral", name: "Mistral"},
         {id: "Baidu Cloud", name: "Baidu Cloud"},
         {id: "Ollama", name: "Ollama"},
         {id: "Local", name: "Local"},
@@ -1650,6 +1667,14 @@ export function getModelSubTypeOptions(type) {
     return [
       {id: "custom-model", name: "custom-model"},
     ];
+  } else if (type === "Self-hosted") {
+    return [
+      {id: "meta-llama/Llama-3.3-70B-Instruct", name: "meta-llama/Llama-3.3-70B-Instruct"},
+      {id: "meta-llama/Llama-3.1-8B-Instruct", name: "meta-llama/Llama-3.1-8B-Instruct"},
+      {id: "Qwen/Qwen2.5-72B-Instruct", name: "Qwen/Qwen2.5-72B-Instruct"},
+      {id: "Qwen/Qwen2.5-Coder-32B-Instruct", name: "Qwen/Qwen2.5-Coder-32B-Instruct"},
+      {id: "llama3.3:70b", name: "llama3.3:70b"},
+    ];
   } else if (type === "Moonshot") {
     return [
       {id: "moonshot-v1-8k", name: "moonshot-v1-8k"},
@@ -1827,6 +1852,11 @@ export function getModelSubTypeOptions(type) {
     ];
   } else if (type === "Grok") {
     return [
+      {id: "grok-4", name: "grok-4"},
+      {id: "grok-4-fast-reasoning", name: "grok-4-fast-reasoning"},
+      {id: "grok-code-fast-1", name: "grok-code-fast-1"},
+      {id: "grok-3", name: "grok-3"},
+      {id: "grok-3-mini", name: "grok-3-mini"},
       {id: "grok-3-latest", name: "grok-3-latest"},
       {id: "grok-3-fast-latest", name: "grok-3-fast-latest"},
       {id: "grok-3-mini-latest", name: "grok-3-mini-latest"},
@@ -1834,6 +1864,17 @@ export function getModelSubTypeOptions(type) {
       {id: "grok-2-latest", name: "grok-2-latest"},
       {id: "grok-2-image-latest", name: "grok-2-image-latest"},
     ];
+  } else if (type === "Groq") {
+    return [
+      {id: "llama-3.3-70b-versatile", name: "llama-3.3-70b-versatile"},
+      {id: "llama-3.1-8b-instant", name: "llama-3.1-8b-instant"},
+      {id: "meta-llama/llama-4-maverick-17b-128e-instruct", name: "meta-llama/llama-4-maverick-17b-128e-instruct"},
+      {id: "meta-llama/llama-4-scout-17b-16e-instruct", name: "meta-llama/llama-4-scout-17b-16e-instruct"},
+      {id: "openai/gpt-oss-120b", name: "openai/gpt-oss-120b"},
+      {id: "openai/gpt-oss-20b", name: "openai/gpt-oss-20b"},
+      {id: "qwen/qwen3-32b", name: "qwen/qwen3-32b"},
+      {id: "moonshotai/kimi-k2-instruct", name: "moonshotai/kimi-k2-instruct"},
+    ];
   } else if (type === "Writer") {
     return [
       {id: "palmyra-x5", name: "Palmyra X5"},
@@ -1868,6 +1909,13 @@ export function getEmbeddingSubTypeOptions(type) {
       {id: "embed-english-light-v2.0", name: "embed-english-light-v2.0"},
       {id: "embed-multilingual-v2.0", name: "embed-multilingual-v2.0"},
       {id: "embed-english-v3.0", name: "embed-english-v3.0"},
+      {id: "embed-multilingual-v3.0", name: "embed-multilingual-v3.0"},
+      {id: "embed-v4.0", name: "embed-v4.0"},
+    ];
+  } else if (type === "Mistral") {
+    return [
+      {id: "mistral-embed", name: "mistral-embed"},
+      {id: "codestral-embed", name: "codestral-embed"},
     ];
   } else if (type === "MiniMax") {
     return [